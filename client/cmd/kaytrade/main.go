@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 
+	"github.com/Phantomvv1/KayTrade/client/internal/export"
 	"github.com/Phantomvv1/KayTrade/client/internal/model"
 	"github.com/Phantomvv1/KayTrade/client/internal/requests"
 	tea "github.com/charmbracelet/bubbletea"
@@ -48,6 +50,15 @@ func main() {
 
 	setupBaseUrl(env)
 
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := export.Run(os.Args[2:], &http.Client{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	err := makeNeededDirs(env)
 	if err != nil {
 		log.Println(err)
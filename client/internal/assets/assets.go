@@ -0,0 +1,200 @@
+package assets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type Asset struct {
+	Symbol       string `json:"symbol"`
+	Class        string `json:"class"`
+	Tradable     bool   `json:"tradable"`
+	Fractionable bool   `json:"fractionable"`
+	Shortable    bool   `json:"shortable"`
+	EasyToBorrow bool   `json:"easy_to_borrow"`
+}
+
+type Quote struct {
+	Bid float64 `json:"bp"`
+	Ask float64 `json:"ap"`
+}
+
+// InfoMsg is sent back to the order page that asked for the asset and its latest quote
+type InfoMsg struct {
+	Symbol string
+	Asset  *Asset
+	Quote  *Quote
+	Err    error
+}
+
+// Borrow describes if the shares of the asset can be borrowed to sell them short
+func (a Asset) Borrow() string {
+	switch {
+	case !a.Shortable:
+		return "not shortable"
+	case a.EasyToBorrow:
+		return "easy to borrow"
+	default:
+		return "hard to borrow"
+	}
+}
+
+// Get returns the trading attributes of the asset
+func Get(symbol string, client *http.Client, tokenStore *basemodel.TokenStore) (*Asset, error) {
+	body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/assets/"+messages.PathSymbol(symbol), nil, client, tokenStore)
+	if err != nil {
+		return nil, err
+	}
+
+	asset := Asset{}
+	if err := json.Unmarshal(body, &asset); err != nil {
+		return nil, fmt.Errorf("failed to parse the asset: %v", err)
+	}
+
+	return &asset, nil
+}
+
+// Fetch gets the trading attributes of the asset along with its latest quote. The quote is only used
+// for estimates, so the asset is still sent when it's missing.
+func Fetch(symbol string, client *http.Client, tokenStore *basemodel.TokenStore) tea.Cmd {
+	return func() tea.Msg {
+		asset, err := Get(symbol, client, tokenStore)
+		if err != nil {
+			return InfoMsg{Symbol: symbol, Err: err}
+		}
+
+		quote, _ := fetchQuote(symbol, client, tokenStore)
+
+		return InfoMsg{Symbol: symbol, Asset: asset, Quote: quote}
+	}
+}
+
+func fetchQuote(symbol string, client *http.Client, tokenStore *basemodel.TokenStore) (*Quote, error) {
+	path := "/data/quotes/latest"
+	if messages.IsCrypto(symbol) {
+		path = "/data/crypto/quotes/latest"
+	}
+
+	body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+path+"?symbols="+url.QueryEscape(symbol), nil, client, tokenStore)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Quotes map[string]Quote `json:"quotes"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	for _, quote := range response.Quotes {
+		return &quote, nil
+	}
+
+	return nil, errors.New("there is no quote for " + symbol)
+}
+
+// Price is what a share is expected to cost on the given side of the order
+func (q Quote) Price(side string) float64 {
+	if side == "sell" && q.Bid > 0 {
+		return q.Bid
+	}
+
+	if q.Ask > 0 {
+		return q.Ask
+	}
+
+	return q.Bid
+}
+
+// Fractional tells if the order can be for a part of a share. Crypto always can, while stocks need to be fractionable
+// and can only be bought in fractions with market day orders. An asset that isn't known yet is left for the server to check.
+func Fractional(asset *Asset, symbol, orderType, timeInForce string) bool {
+	if messages.IsCrypto(symbol) {
+		return true
+	}
+
+	if messages.IsOption(symbol) || orderType != "market" || timeInForce != "day" {
+		return false
+	}
+
+	return asset == nil || asset.Fractionable
+}
+
+// ParseAmount reads the quantity or the dollar amount typed into an order form
+func ParseAmount(value string) (float64, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", ".")
+	if value == "" {
+		return 0, errors.New("Error the amount is required")
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount <= 0 {
+		return 0, errors.New("Error invalid number")
+	}
+
+	return amount, nil
+}
+
+// ValidateShortSale checks that the shares of the asset can be borrowed and sold short. Alpaca only lends
+// whole shares of easy to borrow assets. An asset that isn't known yet is left for the server to check.
+func ValidateShortSale(asset *Asset, symbol string) error {
+	if messages.IsCrypto(symbol) || messages.IsOption(symbol) {
+		return errors.New("Error only stocks can be sold short")
+	}
+
+	if asset == nil {
+		return nil
+	}
+
+	if !asset.Shortable {
+		return errors.New("Error " + symbol + " can't be sold short")
+	}
+
+	if !asset.EasyToBorrow {
+		return errors.New("Error " + symbol + " is hard to borrow, so it can't be sold short")
+	}
+
+	return nil
+}
+
+// ValidateNotional checks that the order can be for a dollar amount instead of a number of shares
+func ValidateNotional(asset *Asset, symbol, orderType, timeInForce string) error {
+	if messages.IsOption(symbol) {
+		return errors.New("Error options can't be traded for a dollar amount")
+	}
+
+	if asset != nil && !asset.Fractionable && !messages.IsCrypto(symbol) {
+		return errors.New("Error " + symbol + " can't be traded in fractions, so it can't be traded for a dollar amount")
+	}
+
+	if orderType != "market" {
+		return errors.New("Error only market orders can be for a dollar amount")
+	}
+
+	if !messages.IsCrypto(symbol) && timeInForce != "day" {
+		return errors.New("Error orders for a dollar amount have to be day orders")
+	}
+
+	return nil
+}
+
+// EstimatedShares is the number of shares the amount buys at the price, rounded down like Alpaca does
+func EstimatedShares(amount, price float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+
+	return math.Floor(amount/price*1e9) / 1e9
+}
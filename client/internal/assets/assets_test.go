@@ -0,0 +1,83 @@
+package assets
+
+import "testing"
+
+func TestFractional(t *testing.T) {
+	fractionable := &Asset{Symbol: "AAPL", Fractionable: true}
+
+	tests := []struct {
+		asset       *Asset
+		symbol      string
+		orderType   string
+		timeInForce string
+		expected    bool
+	}{
+		{fractionable, "AAPL", "market", "day", true},
+		{nil, "AAPL", "market", "day", true},
+		{&Asset{Symbol: "BRK.A"}, "BRK.A", "market", "day", false},
+		{fractionable, "AAPL", "limit", "day", false},
+		{fractionable, "AAPL", "market", "gtc", false},
+		{nil, "BTC/USD", "limit", "gtc", true},
+		{nil, "AAPL250620C00192500", "market", "day", false},
+	}
+
+	for _, tt := range tests {
+		if fractional := Fractional(tt.asset, tt.symbol, tt.orderType, tt.timeInForce); fractional != tt.expected {
+			t.Fatalf("%s %s %s: expected %v, got %v", tt.symbol, tt.orderType, tt.timeInForce, tt.expected, fractional)
+		}
+	}
+}
+
+func TestValidateNotional(t *testing.T) {
+	if err := ValidateNotional(&Asset{Fractionable: true}, "AAPL", "market", "day"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateNotional(nil, "BTC/USD", "market", "gtc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateNotional(&Asset{}, "BRK.A", "market", "day"); err == nil {
+		t.Fatal("expected an asset that isn't fractionable to be rejected")
+	}
+
+	if err := ValidateNotional(nil, "AAPL", "limit", "day"); err == nil {
+		t.Fatal("expected a limit order to be rejected")
+	}
+
+	if err := ValidateNotional(nil, "AAPL250620C00192500", "market", "day"); err == nil {
+		t.Fatal("expected an options contract to be rejected")
+	}
+}
+
+func TestEstimatedShares(t *testing.T) {
+	if shares := EstimatedShares(100, 300); shares != 0.333333333 {
+		t.Fatalf("expected the shares to be rounded down, got %v", shares)
+	}
+
+	if shares := EstimatedShares(100, 0); shares != 0 {
+		t.Fatalf("expected no shares without a price, got %v", shares)
+	}
+}
+
+func TestValidateShortSale(t *testing.T) {
+	if err := ValidateShortSale(&Asset{Shortable: true, EasyToBorrow: true}, "TSLA"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateShortSale(nil, "TSLA"); err != nil {
+		t.Fatalf("expected the server to check an unknown asset, got %v", err)
+	}
+
+	if err := ValidateShortSale(&Asset{Shortable: true}, "GME"); err == nil {
+		t.Fatal("expected a hard to borrow asset to be rejected")
+	}
+
+	if err := ValidateShortSale(nil, "BTC/USD"); err == nil {
+		t.Fatal("expected crypto to be rejected")
+	}
+
+	if borrow := (Asset{Shortable: true}).Borrow(); borrow != "hard to borrow" {
+		t.Fatalf("unexpected borrow %q", borrow)
+	}
+}
@@ -0,0 +1,456 @@
+package basketpage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Leg struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Qty      float64 `json:"qty,omitempty"`
+	Notional float64 `json:"notional,omitempty"`
+}
+
+type Result struct {
+	Leg
+	OrderID string `json:"order_id,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+type BasketPlacedMsg struct {
+	status string
+	legs   []Result
+	err    error
+}
+
+var (
+	titleStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#00FFFF")).
+			Bold(true).
+			Padding(0, 2).
+			MarginBottom(1)
+
+	boxStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#BB88FF")).
+			Padding(1, 2)
+
+	selectedRowStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#00FFFF")).
+				Background(lipgloss.Color("#2a2a4e")).
+				Bold(true)
+
+	rowStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFFFF"))
+
+	helpStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#666666"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF0000")).
+			Bold(true)
+
+	successStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#00FF00")).
+			Bold(true)
+
+	warningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFF00")).
+			Bold(true)
+)
+
+type row struct {
+	symbol   string
+	selected bool
+	shares   bool
+	amount   textinput.Model
+}
+
+type BasketPage struct {
+	BaseModel       basemodel.BaseModel
+	Symbols         []string
+	rows            []row
+	cursor          int
+	cancelOnFailure bool
+	submitting      bool
+	spinner         spinner.Model
+	status          string
+	results         []Result
+	formErr         string
+	Reloaded        bool
+}
+
+func New(client *http.Client, tokenStore *basemodel.TokenStore) BasketPage {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF"))
+
+	return BasketPage{
+		BaseModel: basemodel.BaseModel{Client: client, TokenStore: tokenStore},
+		spinner:   s,
+		Reloaded:  true,
+	}
+}
+
+func (b BasketPage) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// SetSymbols is called with the symbols of the watchlist every time the page is opened
+func (b *BasketPage) SetSymbols(symbols []string) {
+	b.Symbols = symbols
+	b.buildRows()
+}
+
+// Builds a row for every symbol, keeping what was already entered for the same symbols
+func (b *BasketPage) buildRows() {
+	existing := make(map[string]row, len(b.rows))
+	for _, r := range b.rows {
+		existing[r.symbol] = r
+	}
+
+	rows := make([]row, 0, len(b.Symbols))
+	for _, symbol := range b.Symbols {
+		if r, ok := existing[symbol]; ok {
+			rows = append(rows, r)
+			continue
+		}
+
+		amount := textinput.New()
+		amount.Placeholder = "0"
+		amount.Width = 10
+		amount.CharLimit = 10
+		amount.Prompt = ""
+		rows = append(rows, row{symbol: symbol, amount: amount})
+	}
+
+	b.rows = rows
+	if b.cursor >= len(b.rows) {
+		b.cursor = 0
+	}
+
+	b.focusRow()
+}
+
+func (b *BasketPage) focusRow() {
+	for i := range b.rows {
+		if i == b.cursor {
+			b.rows[i].amount.Focus()
+		} else {
+			b.rows[i].amount.Blur()
+		}
+	}
+}
+
+// Legs turns the ticked rows into the orders of the basket
+func (b BasketPage) Legs() ([]Leg, error) {
+	var legs []Leg
+	for _, r := range b.rows {
+		if !r.selected {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(r.amount.Value()), 64)
+		if err != nil || amount <= 0 {
+			return nil, errors.New("Error invalid amount for " + r.symbol)
+		}
+
+		leg := Leg{Symbol: r.symbol, Side: "buy"}
+		if r.shares {
+			leg.Qty = amount
+		} else {
+			if amount < 1 {
+				return nil, errors.New("Error the amount for " + r.symbol + " should be at least $1")
+			}
+			leg.Notional = amount
+		}
+
+		legs = append(legs, leg)
+	}
+
+	if len(legs) == 0 {
+		return nil, errors.New("Error tick at least one symbol with space")
+	}
+
+	return legs, nil
+}
+
+func (b BasketPage) placeBasket(legs []Leg) tea.Cmd {
+	return func() tea.Msg {
+		jsonData, err := json.Marshal(map[string]any{
+			"legs":              legs,
+			"cancel_on_failure": b.cancelOnFailure,
+		})
+		if err != nil {
+			return BasketPlacedMsg{err: err}
+		}
+
+		body, err := requests.MakeRequest(http.MethodPost, requests.BaseURL+"/trading/baskets", bytes.NewReader(jsonData), b.BaseModel.Client, b.BaseModel.TokenStore)
+		if err != nil {
+			return BasketPlacedMsg{err: err}
+		}
+
+		var info struct {
+			Status string   `json:"status"`
+			Legs   []Result `json:"legs"`
+		}
+		if err := json.Unmarshal(body, &info); err != nil {
+			return BasketPlacedMsg{err: err}
+		}
+
+		return BasketPlacedMsg{status: info.Status, legs: info.Legs}
+	}
+}
+
+func (b BasketPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case BasketPlacedMsg:
+		b.submitting = false
+		if msg.err != nil {
+			b.formErr = msg.err.Error()
+			return b, nil
+		}
+
+		b.status = msg.status
+		b.results = msg.legs
+		return b, func() tea.Msg {
+			return messages.ReloadMsg{
+				Page: messages.ProfilePageNumber,
+			}
+		}
+
+	case spinner.TickMsg:
+		if b.submitting {
+			b.spinner, cmd = b.spinner.Update(msg)
+			return b, cmd
+		}
+		return b, nil
+
+	case tea.KeyMsg:
+		if b.submitting {
+			return b, nil
+		}
+
+		if b.results != nil {
+			switch msg.String() {
+			case "esc":
+				b.Reload()
+				return b, func() tea.Msg {
+					return messages.SmartPageSwitchMsg{
+						Page: messages.WatchlistPageNumber,
+					}
+				}
+			case "enter":
+				b.Reload()
+				b.buildRows()
+				return b, nil
+			case "q", "ctrl+c":
+				return b, func() tea.Msg {
+					return messages.QuitMsg{}
+				}
+			}
+
+			return b, nil
+		}
+
+		switch msg.String() {
+		case "ctrl+c":
+			return b, func() tea.Msg {
+				return messages.QuitMsg{}
+			}
+
+		case "esc":
+			return b, func() tea.Msg {
+				return messages.SmartPageSwitchMsg{
+					Page: messages.WatchlistPageNumber,
+				}
+			}
+
+		case "up", "ctrl+k":
+			if b.cursor > 0 {
+				b.cursor--
+				b.focusRow()
+			}
+			return b, nil
+
+		case "down", "ctrl+j", "tab":
+			if b.cursor < len(b.rows)-1 {
+				b.cursor++
+				b.focusRow()
+			}
+			return b, nil
+
+		case " ":
+			if len(b.rows) != 0 {
+				b.rows[b.cursor].selected = !b.rows[b.cursor].selected
+			}
+			return b, nil
+
+		case "left", "right", "h", "l":
+			if len(b.rows) != 0 {
+				b.rows[b.cursor].shares = !b.rows[b.cursor].shares
+			}
+			return b, nil
+
+		case "ctrl+x":
+			b.cancelOnFailure = !b.cancelOnFailure
+			return b, nil
+
+		case "enter":
+			legs, err := b.Legs()
+			if err != nil {
+				b.formErr = err.Error()
+				return b, nil
+			}
+
+			b.formErr = ""
+			b.submitting = true
+			return b, tea.Batch(b.spinner.Tick, b.placeBasket(legs))
+		}
+
+		if len(b.rows) == 0 {
+			return b, nil
+		}
+
+		// Only numbers go into the amount, typing one ticks the symbol as well
+		if msg.Type == tea.KeyRunes {
+			for _, r := range msg.Runes {
+				if (r < '0' || r > '9') && r != '.' {
+					return b, nil
+				}
+			}
+
+			b.rows[b.cursor].selected = true
+		}
+
+		b.rows[b.cursor].amount, cmd = b.rows[b.cursor].amount.Update(msg)
+		return b, cmd
+	}
+
+	return b, nil
+}
+
+func (b BasketPage) View() string {
+	header := lipgloss.PlaceHorizontal(b.BaseModel.Width, lipgloss.Center, titleStyle.Render("🧺 Basket Order"))
+
+	var content string
+	switch {
+	case b.submitting:
+		content = b.spinner.View() + " Placing the orders..."
+	case b.results != nil:
+		content = b.renderResults()
+	case len(b.Symbols) == 0:
+		content = lipgloss.JoinVertical(lipgloss.Center,
+			"Your watchlist is empty.",
+			"",
+			helpStyle.Render("esc: back"),
+		)
+	default:
+		content = b.renderBuilder()
+	}
+
+	return header + "\n" + lipgloss.Place(b.BaseModel.Width, b.BaseModel.Height-4, lipgloss.Center, lipgloss.Center, content)
+}
+
+func (b BasketPage) renderBuilder() string {
+	lines := []string{fmt.Sprintf("    %-8s %-8s %s", "Symbol", "In", "Amount")}
+	for i, r := range b.rows {
+		check := "[ ]"
+		if r.selected {
+			check = "[x]"
+		}
+
+		unit := "$"
+		if r.shares {
+			unit = "shares"
+		}
+
+		line := fmt.Sprintf("%s %-8s %-8s %s", check, r.symbol, "◀ "+unit+" ▶", r.amount.View())
+		if i == b.cursor {
+			lines = append(lines, selectedRowStyle.Render(line))
+		} else {
+			lines = append(lines, rowStyle.Render(line))
+		}
+	}
+
+	mode := "place what succeeds"
+	if b.cancelOnFailure {
+		mode = "all or nothing"
+	}
+
+	content := lipgloss.JoinVertical(lipgloss.Left,
+		boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...)),
+		"",
+		"On failure: "+warningStyle.Render(mode),
+	)
+
+	if b.formErr != "" {
+		content = lipgloss.JoinVertical(lipgloss.Center, content, "", errorStyle.Render("❌ "+b.formErr))
+	}
+
+	help := helpStyle.Render("↑/↓: move • space: tick • ←/→: dollars/shares • ctrl+x: on failure • enter: place • esc: back")
+	return lipgloss.JoinVertical(lipgloss.Center, content, "", help)
+}
+
+func (b BasketPage) renderResults() string {
+	var title string
+	switch b.status {
+	case "placed":
+		title = successStyle.Render("✓ All orders were placed")
+	case "partial":
+		title = warningStyle.Render("⚠ Some of the orders failed")
+	case "canceled":
+		title = warningStyle.Render("⚠ An order failed, so the rest were canceled")
+	default:
+		title = errorStyle.Render("✗ None of the orders were placed")
+	}
+
+	lines := []string{title, ""}
+	for _, result := range b.results {
+		amount := fmt.Sprintf("$%.2f", result.Notional)
+		if result.Qty != 0 {
+			amount = strconv.FormatFloat(result.Qty, 'f', -1, 64) + " shares"
+		}
+
+		line := fmt.Sprintf("%-8s %-14s %s", result.Symbol, amount, result.Status)
+		if result.Error != "" {
+			line += "  " + errorStyle.Render(result.Error)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Center,
+		boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...)),
+		"",
+		helpStyle.Render("enter: new basket • esc: back • q: quit"),
+	)
+}
+
+func (b *BasketPage) Reload() {
+	b.rows = nil
+	b.cursor = 0
+	b.cancelOnFailure = false
+	b.submitting = false
+	b.status = ""
+	b.results = nil
+	b.formErr = ""
+	b.Reloaded = true
+}
@@ -0,0 +1,102 @@
+package basketpage
+
+import (
+	"net/http"
+	"testing"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestPage() BasketPage {
+	b := New(&http.Client{}, &basemodel.TokenStore{})
+	b.BaseModel.Width = 120
+	b.BaseModel.Height = 40
+	b.SetSymbols([]string{"AAPL", "MSFT", "VOO"})
+	return b
+}
+
+func press(b BasketPage, keys ...tea.KeyMsg) BasketPage {
+	for _, key := range keys {
+		model, _ := b.Update(key)
+		b = model.(BasketPage)
+	}
+
+	return b
+}
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestLegs(t *testing.T) {
+	b := newTestPage()
+
+	if _, err := b.Legs(); err == nil {
+		t.Fatal("expected an error without any ticked symbols")
+	}
+
+	b = press(b, runes("1"), runes("0"), runes("0"))
+	b = press(b, tea.KeyMsg{Type: tea.KeyDown}, tea.KeyMsg{Type: tea.KeyDown}, tea.KeyMsg{Type: tea.KeyRight}, runes("2"), runes("."), runes("5"))
+
+	legs, err := b.Legs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(legs) != 2 {
+		t.Fatalf("expected 2 legs, got %d", len(legs))
+	}
+
+	if legs[0].Symbol != "AAPL" || legs[0].Notional != 100 || legs[0].Qty != 0 {
+		t.Fatalf("unexpected first leg %+v", legs[0])
+	}
+
+	if legs[1].Symbol != "VOO" || legs[1].Qty != 2.5 || legs[1].Notional != 0 {
+		t.Fatalf("unexpected second leg %+v", legs[1])
+	}
+}
+
+func TestUpdate_OnlyNumbersAreTyped(t *testing.T) {
+	b := press(newTestPage(), runes("x"))
+	if b.rows[0].selected || b.rows[0].amount.Value() != "" {
+		t.Fatal("expected letters to be ignored")
+	}
+
+	b = press(b, runes(" "))
+	if !b.rows[0].selected {
+		t.Fatal("expected space to tick the symbol")
+	}
+
+	b = press(b, runes("0"), runes("."), runes("5"))
+	if _, err := b.Legs(); err == nil {
+		t.Fatal("expected an error for less than $1")
+	}
+}
+
+func TestSetSymbols_KeepsEnteredAmounts(t *testing.T) {
+	b := press(newTestPage(), runes("5"), runes("0"))
+
+	b.SetSymbols([]string{"TSLA", "AAPL"})
+	if len(b.rows) != 2 || b.rows[1].symbol != "AAPL" || b.rows[1].amount.Value() != "50" || !b.rows[1].selected {
+		t.Fatal("expected the amount of AAPL to be kept")
+	}
+}
+
+func TestUpdate_Results(t *testing.T) {
+	b := newTestPage()
+
+	model, cmd := b.Update(BasketPlacedMsg{status: "partial", legs: []Result{
+		{Leg: Leg{Symbol: "AAPL", Notional: 100}, Status: "placed", OrderID: "1"},
+		{Leg: Leg{Symbol: "MSFT", Notional: 100}, Status: "failed", Error: "insufficient buying power"},
+	}})
+	b = model.(BasketPage)
+	if cmd == nil || b.results == nil {
+		t.Fatal("expected the results to be shown and the profile to be reloaded")
+	}
+
+	b = press(b, tea.KeyMsg{Type: tea.KeyEnter})
+	if b.results != nil || len(b.rows) != 3 {
+		t.Fatal("expected a new basket to be started")
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/Phantomvv1/KayTrade/client/internal/assets"
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/estimation"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
 	"github.com/Phantomvv1/KayTrade/client/internal/requests"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -41,6 +44,12 @@ type BuyPage struct {
 	totalFields      int
 	err              string
 	success          string
+	estimating       bool
+	estimate         *estimation.Estimate
+	order            map[string]any
+	notional         bool
+	asset            *assets.Asset
+	quote            *assets.Quote
 }
 
 var (
@@ -117,9 +126,9 @@ func NewBuyPage(client *http.Client, tokenStore *basemodel.TokenStore) BuyPage {
 		BaseModel:       basemodel.BaseModel{Client: client, TokenStore: tokenStore},
 		quantity:        quantity,
 		side:            "buy",
-		purchaseType:    []string{"market", "limit", "stop", "stop_limit", "trailing_stop"},
+		purchaseType:    stockPurchaseTypes,
 		purchaseTypeIdx: 0,
-		timeInForce:     []string{"day", "gtc", "opg", "cls", "ioc", "fok"},
+		timeInForce:     stockTimeInForce,
 		timeInForceIdx:  0,
 		additionalFields: map[string][]textinput.Model{
 			"limit": {
@@ -145,8 +154,74 @@ func NewBuyPage(client *http.Client, tokenStore *basemodel.TokenStore) BuyPage {
 	}
 }
 
+// The order types and the times in force Alpaca accepts for crypto and options
+var (
+	cryptoPurchaseTypes = []string{"market", "limit", "stop_limit"}
+	cryptoTimeInForce   = []string{"gtc", "ioc"}
+	optionPurchaseTypes = []string{"market", "limit", "stop", "stop_limit"}
+	optionTimeInForce   = []string{"day", "gtc"}
+	stockPurchaseTypes  = []string{"market", "limit", "stop", "stop_limit", "trailing_stop"}
+	stockTimeInForce    = []string{"day", "gtc", "opg", "cls", "ioc", "fok"}
+)
+
+// SetSymbol changes the symbol of the order along with the options it has. Crypto and options contracts have fewer
+// order types and don't support take profit and stop loss orders.
+func (b *BuyPage) SetSymbol(symbol string) {
+	b.Symbol = symbol
+	b.purchaseType = stockPurchaseTypes
+	b.timeInForce = stockTimeInForce
+	if messages.IsCrypto(symbol) {
+		b.purchaseType = cryptoPurchaseTypes
+		b.timeInForce = cryptoTimeInForce
+	} else if messages.IsOption(symbol) {
+		b.purchaseType = optionPurchaseTypes
+		b.timeInForce = optionTimeInForce
+	}
+
+	b.purchaseTypeIdx = 0
+	b.timeInForceIdx = 0
+	b.cursor = 0
+	b.setNotional(false)
+	b.asset = nil
+	b.quote = nil
+}
+
+// setNotional switches between ordering a number of shares and a dollar amount. A dollar amount
+// can only be spent with a market order, which for stocks also has to be a day order.
+func (b *BuyPage) setNotional(notional bool) {
+	b.notional = notional
+	if !notional {
+		b.quantity.Placeholder = "Quantity"
+		return
+	}
+
+	b.quantity.Placeholder = "Amount in $"
+	for i, purchaseType := range b.purchaseType {
+		if purchaseType == "market" {
+			b.purchaseTypeIdx = i
+		}
+	}
+
+	if !messages.IsCrypto(b.Symbol) {
+		for i, timeInForce := range b.timeInForce {
+			if timeInForce == "day" {
+				b.timeInForceIdx = i
+			}
+		}
+	}
+}
+
+// singleLeg tells if the symbol can only be bought without a take profit and a stop loss
+func (b *BuyPage) singleLeg() bool {
+	return messages.IsCrypto(b.Symbol) || messages.IsOption(b.Symbol)
+}
+
 func (b BuyPage) Init() tea.Cmd {
-	return textinput.Blink
+	if b.Symbol == "" || messages.IsOption(b.Symbol) {
+		return textinput.Blink
+	}
+
+	return tea.Batch(textinput.Blink, assets.Fetch(b.Symbol, b.BaseModel.Client, b.BaseModel.TokenStore))
 }
 
 func (b *BuyPage) calculateTotalFields() int {
@@ -156,7 +231,9 @@ func (b *BuyPage) calculateTotalFields() int {
 		count += len(fields)
 	}
 
-	count += 3 // takeProfit.limitPrice, stopLoss.stopPrice, stopLoss.limitPrice
+	if !b.singleLeg() {
+		count += 3 // takeProfit.limitPrice, stopLoss.stopPrice, stopLoss.limitPrice
+	}
 
 	return count
 }
@@ -167,7 +244,40 @@ func (b BuyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	b.totalFields = b.calculateTotalFields()
 
 	switch msg := msg.(type) {
+	case estimation.EstimateMsg:
+		b.estimating = false
+		if msg.Err != nil {
+			b.err = msg.Err.Error()
+			return b, nil
+		}
+
+		b.estimate = msg.Estimate
+		return b, nil
+
+	case assets.InfoMsg:
+		// Without the asset the server is left to check the order
+		if msg.Symbol == b.Symbol && msg.Err == nil {
+			b.asset = msg.Asset
+			b.quote = msg.Quote
+		}
+
+		return b, nil
+
 	case tea.KeyMsg:
+		if b.estimating {
+			if msg.String() == "ctrl+c" {
+				return b, func() tea.Msg {
+					return messages.QuitMsg{}
+				}
+			}
+
+			return b, nil
+		}
+
+		if b.estimate != nil {
+			return b.updateConfirmation(msg)
+		}
+
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return b, func() tea.Msg {
@@ -236,24 +346,42 @@ func (b BuyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return b, nil
 			}
 
+		case "tab":
+			b.err = ""
+			b.success = ""
+			if messages.IsOption(b.Symbol) {
+				b.err = "Error options can't be traded for a dollar amount"
+				return b, nil
+			}
+
+			b.setNotional(!b.notional)
+			b.totalFields = b.calculateTotalFields()
+			return b, nil
+
 		case "enter":
 			b.err = ""
 			b.success = ""
-			if err := b.submitOrder(); err != nil {
+			order, err := b.buildOrder()
+			if err != nil {
 				b.err = err.Error()
-			} else {
-				b.success = "Order submitted successfully!"
+				return b, nil
 			}
 
-			return b, func() tea.Msg {
-				return messages.ReloadMsg{
-					Page: messages.ProfilePageNumber,
-				}
-			}
+			b.order = order
+			b.estimating = true
+			return b, estimation.Fetch(order, b.BaseModel.Client, b.BaseModel.TokenStore)
 
 		case "esc":
 			b.err = ""
 			b.success = ""
+			if messages.IsOption(b.Symbol) {
+				return b, func() tea.Msg {
+					return messages.SmartPageSwitchMsg{
+						Page: messages.OptionsChainPageNumber,
+					}
+				}
+			}
+
 			return b, func() tea.Msg {
 				return messages.PageSwitchMsg{
 					Page: messages.CompanyPageNumber,
@@ -280,7 +408,7 @@ func (b BuyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		default:
 			key := msg.String()
-			if len(key) == 1 {
+			if len(key) == 1 && key != "." && key != "," {
 				if []byte(key)[0] < '0' || []byte(key)[0] > '9' {
 					return b, nil
 				}
@@ -329,6 +457,10 @@ func (b *BuyPage) getFieldIndex() int {
 		}
 	}
 
+	if b.singleLeg() {
+		return -1
+	}
+
 	// Take profit
 	if b.cursor == idx {
 		return 200
@@ -415,7 +547,14 @@ func (b BuyPage) View() string {
 	} else {
 		b.quantity.Blur()
 	}
-	fields = append(fields, b.renderField("Quantity", b.quantity.View(), b.cursor == idx, false))
+	if b.notional {
+		fields = append(fields, b.renderField("Amount ($)", b.quantity.View(), b.cursor == idx, false))
+		if shares := b.estimatedShares(); shares != "" {
+			fields = append(fields, helpStyle.Render(shares))
+		}
+	} else {
+		fields = append(fields, b.renderField("Quantity", b.quantity.View(), b.cursor == idx, false))
+	}
 	idx++
 
 	// Purchase Type (slider)
@@ -452,30 +591,32 @@ func (b BuyPage) View() string {
 		}
 	}
 
-	// Take Profit (optional)
-	if b.cursor == idx {
-		b.takeProfit.limitPrice.Focus()
-	} else {
-		b.takeProfit.limitPrice.Blur()
-	}
-	fields = append(fields, b.renderField("Take Profit (opt)", b.takeProfit.limitPrice.View(), b.cursor == idx, false))
-	idx++
+	if !b.singleLeg() {
+		// Take Profit (optional)
+		if b.cursor == idx {
+			b.takeProfit.limitPrice.Focus()
+		} else {
+			b.takeProfit.limitPrice.Blur()
+		}
+		fields = append(fields, b.renderField("Take Profit (opt)", b.takeProfit.limitPrice.View(), b.cursor == idx, false))
+		idx++
 
-	// Stop Loss (optional)
-	if b.cursor == idx {
-		b.stopLoss.stopPrice.Focus()
-	} else {
-		b.stopLoss.stopPrice.Blur()
-	}
-	fields = append(fields, b.renderField("Stop Loss Stop (opt)", b.stopLoss.stopPrice.View(), b.cursor == idx, false))
-	idx++
+		// Stop Loss (optional)
+		if b.cursor == idx {
+			b.stopLoss.stopPrice.Focus()
+		} else {
+			b.stopLoss.stopPrice.Blur()
+		}
+		fields = append(fields, b.renderField("Stop Loss Stop (opt)", b.stopLoss.stopPrice.View(), b.cursor == idx, false))
+		idx++
 
-	if b.cursor == idx {
-		b.stopLoss.limitPrice.Focus()
-	} else {
-		b.stopLoss.limitPrice.Blur()
+		if b.cursor == idx {
+			b.stopLoss.limitPrice.Focus()
+		} else {
+			b.stopLoss.limitPrice.Blur()
+		}
+		fields = append(fields, b.renderField("Stop Loss Limit (opt)", b.stopLoss.limitPrice.View(), b.cursor == idx, false))
 	}
-	fields = append(fields, b.renderField("Stop Loss Limit (opt)", b.stopLoss.limitPrice.View(), b.cursor == idx, false))
 
 	content := lipgloss.JoinVertical(lipgloss.Center, fields...)
 
@@ -487,7 +628,17 @@ func (b BuyPage) View() string {
 		content = lipgloss.JoinVertical(lipgloss.Center, content, "", successStyle.Render("✓ "+b.success))
 	}
 
-	help := helpStyle.Render("j/k/↑/↓: navigate • h/l/←/→: change slider • enter: submit • esc: back • w: watchlist page • i: information page • q: quit")
+	if b.estimating {
+		content = lipgloss.JoinVertical(lipgloss.Center, content, "", helpStyle.Render("Estimating the order..."))
+	}
+
+	help := helpStyle.Render("j/k/↑/↓: navigate • h/l/←/→: change slider • tab: quantity/amount • enter: review order • esc: back • w: watchlist page • i: information page • q: quit")
+
+	// The estimate replaces the form until the order is confirmed or edited
+	if b.estimate != nil {
+		content = estimation.Render(*b.estimate)
+		help = helpStyle.Render("enter/y: place the order • esc/n: edit the order • ctrl+c: quit")
+	}
 
 	// Calculate vertical spacing
 	headerHeight := lipgloss.Height(header)
@@ -555,6 +706,25 @@ func (b BuyPage) renderField(label, value string, focused, slider bool) string {
 	return lipgloss.JoinVertical(lipgloss.Center, styledLabel, styledValue)
 }
 
+// estimatedShares is the number of shares the amount buys at the latest quote
+func (b BuyPage) estimatedShares() string {
+	if b.quote == nil {
+		return ""
+	}
+
+	amount, err := assets.ParseAmount(b.quantity.Value())
+	if err != nil {
+		return ""
+	}
+
+	price := b.quote.Price(b.side)
+	if price <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("≈ %s shares at $%.2f", strconv.FormatFloat(assets.EstimatedShares(amount, price), 'f', -1, 64), price)
+}
+
 func (b BuyPage) renderSlider(options []string, selectedIdx int, focused bool) string {
 	selected := strings.ToUpper(options[selectedIdx])
 
@@ -570,7 +740,48 @@ func (b BuyPage) renderSlider(options []string, selectedIdx int, focused bool) s
 		Render("◀ " + selected + " ▶")
 }
 
+// updateConfirmation handles the keys while the estimate of the order is shown
+func (b BuyPage) updateConfirmation(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return b, func() tea.Msg {
+			return messages.QuitMsg{}
+		}
+
+	case "enter", "y":
+		order := b.order
+		b.estimate = nil
+		b.order = nil
+		if err := b.placeOrder(order); err != nil {
+			b.err = err.Error()
+			return b, nil
+		}
+
+		b.success = "Order submitted successfully!"
+		return b, func() tea.Msg {
+			return messages.ReloadMsg{
+				Page: messages.ProfilePageNumber,
+			}
+		}
+
+	case "esc", "n":
+		b.estimate = nil
+		b.order = nil
+	}
+
+	return b, nil
+}
+
 func (b *BuyPage) submitOrder() error {
+	data, err := b.buildOrder()
+	if err != nil {
+		return err
+	}
+
+	return b.placeOrder(data)
+}
+
+func (b *BuyPage) buildOrder() (map[string]any, error) {
 	// Validate and build request
 	data := make(map[string]any)
 
@@ -579,23 +790,36 @@ func (b *BuyPage) submitOrder() error {
 	data["type"] = b.purchaseType[b.purchaseTypeIdx]
 	data["time_in_force"] = b.timeInForce[b.timeInForceIdx]
 
-	qty := strings.TrimSpace(b.quantity.Value())
-	if qty == "" {
-		return fmt.Errorf("quantity is required")
-	}
+	if b.notional {
+		if err := assets.ValidateNotional(b.asset, b.Symbol, data["type"].(string), data["time_in_force"].(string)); err != nil {
+			return nil, err
+		}
 
-	qty = strings.ReplaceAll(qty, ",", ".")
-	dotCount := strings.Count(qty, ".")
-	if dotCount > 1 {
-		return errors.New("Error invalid number")
-	}
+		amount, err := assets.ParseAmount(b.quantity.Value())
+		if err != nil {
+			return nil, err
+		}
 
-	if b.purchaseType[b.purchaseTypeIdx] == "market" && b.timeInForce[b.timeInForceIdx] == "day" {
-		// can be a float
-		data["qty"] = qty
+		data["notional"] = strconv.FormatFloat(amount, 'f', 2, 64)
 	} else {
-		if dotCount > 0 {
-			return errors.New("Error quantity must be an integer")
+		qty := strings.TrimSpace(b.quantity.Value())
+		if qty == "" {
+			return nil, fmt.Errorf("quantity is required")
+		}
+
+		qty = strings.ReplaceAll(qty, ",", ".")
+		dotCount := strings.Count(qty, ".")
+		if dotCount > 1 {
+			return nil, errors.New("Error invalid number")
+		}
+
+		// Options contracts are always bought in whole units and stocks only in fractions if they're fractionable
+		if dotCount > 0 && !assets.Fractional(b.asset, b.Symbol, data["type"].(string), data["time_in_force"].(string)) {
+			if b.asset != nil && !b.asset.Fractionable && !messages.IsOption(b.Symbol) {
+				return nil, errors.New("Error " + b.Symbol + " can't be traded in fractions")
+			}
+
+			return nil, errors.New("Error quantity must be an integer")
 		}
 
 		data["qty"] = qty
@@ -609,12 +833,12 @@ func (b *BuyPage) submitOrder() error {
 			trailPercent := strings.TrimSpace(fields[1].Value())
 
 			if trailPrice == "" && trailPercent == "" {
-				return errors.New("Error either trail price or trail percent is required")
+				return nil, errors.New("Error either trail price or trail percent is required")
 			}
 
 			if trailPrice != "" {
 				if strings.Count(trailPrice, ".") > 1 {
-					return errors.New("Error invalid trail price number")
+					return nil, errors.New("Error invalid trail price number")
 				}
 
 				data["trail_price"] = trailPrice
@@ -622,7 +846,7 @@ func (b *BuyPage) submitOrder() error {
 
 			if trailPercent != "" {
 				if strings.Count(trailPercent, ".") > 1 {
-					return errors.New("Error invalid trail price number")
+					return nil, errors.New("Error invalid trail price number")
 				}
 
 				data["trail_percent"] = trailPercent
@@ -632,7 +856,7 @@ func (b *BuyPage) submitOrder() error {
 			for i, field := range fields {
 				val := strings.TrimSpace(field.Value())
 				if val == "" {
-					return fmt.Errorf("Error %s is required", field.Placeholder)
+					return nil, fmt.Errorf("Error %s is required", field.Placeholder)
 				}
 
 				data[fieldNames[i]] = val
@@ -640,10 +864,18 @@ func (b *BuyPage) submitOrder() error {
 		}
 	}
 
+	if b.singleLeg() {
+		return data, nil
+	}
+
+	if b.notional && (b.takeProfit.limitPrice.Value() != "" || b.stopLoss.stopPrice.Value() != "" || b.stopLoss.limitPrice.Value() != "") {
+		return nil, errors.New("Error orders for a dollar amount can't have a take profit or a stop loss")
+	}
+
 	// Add take profit if provided
 	if tp := strings.TrimSpace(b.takeProfit.limitPrice.Value()); tp != "" {
 		if strings.Count(tp, ".") > 1 {
-			return errors.New("Error invalid take profit value")
+			return nil, errors.New("Error invalid take profit value")
 		}
 
 		data["take_profit"] = map[string]string{"limit_price": tp}
@@ -658,23 +890,27 @@ func (b *BuyPage) submitOrder() error {
 			stopLoss := make(map[string]string)
 
 			if strings.Count(slStop, ".") > 1 {
-				return errors.New("Error invalid stop loss stop price")
+				return nil, errors.New("Error invalid stop loss stop price")
 			}
 
 			stopLoss["stop_price"] = slStop
 
 			if strings.Count(slLimit, ".") > 1 {
-				return errors.New("Error invalid stop loss limit price")
+				return nil, errors.New("Error invalid stop loss limit price")
 			}
 
 			stopLoss["limit_price"] = slLimit
 
 			data["stop_loss"] = stopLoss
 		} else {
-			return errors.New("Error both stop price and stop limit are required if you want to have a stop loss")
+			return nil, errors.New("Error both stop price and stop limit are required if you want to have a stop loss")
 		}
 	}
 
+	return data, nil
+}
+
+func (b *BuyPage) placeOrder(data map[string]any) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("failed to encode request: %v", err)
@@ -705,6 +941,7 @@ func (b BuyPage) getFieldNames(purchaseType string) []string {
 
 func (b *BuyPage) Reload() {
 	b.cursor = 0
+	b.setNotional(false)
 	b.quantity.SetValue("1")
 	b.purchaseTypeIdx = 0
 	b.timeInForceIdx = 0
@@ -718,4 +955,7 @@ func (b *BuyPage) Reload() {
 	b.stopLoss.limitPrice.SetValue("")
 	b.err = ""
 	b.success = ""
+	b.estimating = false
+	b.estimate = nil
+	b.order = nil
 }
@@ -1,8 +1,11 @@
 package buypage
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/Phantomvv1/KayTrade/client/internal/assets"
+	"github.com/Phantomvv1/KayTrade/client/internal/estimation"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -214,3 +217,166 @@ func TestBuyPage_ReloadResetsState(t *testing.T) {
 		t.Fatal("expected sliders reset")
 	}
 }
+
+func TestBuyPage_EnterEstimatesBeforeSubmitting(t *testing.T) {
+	b := NewBuyPage(nil, nil)
+	b.Symbol = "AAPL"
+
+	m, cmd := b.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	p := m.(BuyPage)
+
+	if cmd == nil || !p.estimating || p.order["qty"] != "1" {
+		t.Fatalf("expected an estimate to be requested, got %+v", p.order)
+	}
+
+	m, _ = p.Update(estimation.EstimateMsg{Estimate: &estimation.Estimate{Symbol: "AAPL", Side: "buy", Qty: 1}})
+	p = m.(BuyPage)
+
+	if p.estimating || p.estimate == nil {
+		t.Fatal("expected the estimate to be shown")
+	}
+
+	m, cmd = p.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	p = m.(BuyPage)
+
+	if cmd != nil || p.estimate != nil || p.order != nil {
+		t.Fatal("expected esc to go back to the form")
+	}
+}
+
+func TestBuyPage_EstimateError(t *testing.T) {
+	b := NewBuyPage(nil, nil)
+	b.estimating = true
+
+	m, _ := b.Update(estimation.EstimateMsg{Err: errors.New("Error couldn't find a price for AAPL")})
+	p := m.(BuyPage)
+
+	if p.estimating || p.estimate != nil || p.err == "" {
+		t.Fatalf("expected the error to be shown, got %q", p.err)
+	}
+}
+
+func TestBuyPage_InvalidOrderIsNotEstimated(t *testing.T) {
+	b := NewBuyPage(nil, nil)
+	b.quantity.SetValue("")
+
+	m, cmd := b.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	p := m.(BuyPage)
+
+	if cmd != nil || p.estimating || p.err == "" {
+		t.Fatal("expected the validation error without an estimate")
+	}
+}
+
+func TestBuyPage_CryptoOrderOptions(t *testing.T) {
+	b := NewBuyPage(nil, nil)
+	b.SetSymbol("BTC/USD")
+
+	if len(b.purchaseType) != 3 || b.timeInForce[b.timeInForceIdx] != "gtc" {
+		t.Fatalf("expected the crypto order options, got %v %v", b.purchaseType, b.timeInForce)
+	}
+
+	// quantity, type and time in force, without take profit and stop loss
+	if b.calculateTotalFields() != 3 {
+		t.Fatalf("expected 3 fields, got %d", b.calculateTotalFields())
+	}
+
+	b.purchaseTypeIdx = 1 // limit
+	b.quantity.SetValue("0.25")
+	b.additionalFields["limit"][0].SetValue("60000")
+
+	order, err := b.buildOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order["qty"] != "0.25" || order["time_in_force"] != "gtc" || order["take_profit"] != nil {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	b.SetSymbol("AAPL")
+	if len(b.purchaseType) != 5 || b.calculateTotalFields() != 6 {
+		t.Fatal("expected the stock order options back")
+	}
+}
+
+func TestBuyPage_OptionOrderOptions(t *testing.T) {
+	b := NewBuyPage(nil, nil)
+	b.SetSymbol("AAPL250620C00192500")
+
+	if len(b.purchaseType) != 4 || len(b.timeInForce) != 2 || b.calculateTotalFields() != 3 {
+		t.Fatalf("expected the options order options, got %v %v", b.purchaseType, b.timeInForce)
+	}
+
+	b.quantity.SetValue("1.5")
+	if _, err := b.buildOrder(); err == nil {
+		t.Fatal("expected a fractional quantity of contracts to be rejected")
+	}
+
+	b.quantity.SetValue("2")
+	order, err := b.buildOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order["qty"] != "2" || order["type"] != "market" || order["take_profit"] != nil {
+		t.Fatalf("unexpected order %v", order)
+	}
+}
+
+func TestBuyPage_NotionalOrder(t *testing.T) {
+	b := NewBuyPage(nil, nil)
+	b.SetSymbol("AAPL")
+	b.purchaseTypeIdx = 1 // limit
+	b.timeInForceIdx = 1  // gtc
+
+	m, _ := b.Update(tea.KeyMsg{Type: tea.KeyTab})
+	b = m.(BuyPage)
+	if !b.notional || b.purchaseType[b.purchaseTypeIdx] != "market" || b.timeInForce[b.timeInForceIdx] != "day" {
+		t.Fatalf("expected a market day order for an amount, got %s %s", b.purchaseType[b.purchaseTypeIdx], b.timeInForce[b.timeInForceIdx])
+	}
+
+	m, _ = b.Update(assets.InfoMsg{Symbol: "AAPL", Asset: &assets.Asset{Symbol: "AAPL", Fractionable: true}, Quote: &assets.Quote{Bid: 199, Ask: 200}})
+	b = m.(BuyPage)
+
+	b.quantity.SetValue("150")
+	if shares := b.estimatedShares(); shares != "≈ 0.75 shares at $200.00" {
+		t.Fatalf("unexpected estimate %q", shares)
+	}
+
+	order, err := b.buildOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order["notional"] != "150.00" || order["qty"] != nil {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	b.purchaseTypeIdx = 1 // limit
+	if _, err := b.buildOrder(); err == nil {
+		t.Fatal("expected a limit order for an amount to be rejected")
+	}
+}
+
+func TestBuyPage_FractionableAsset(t *testing.T) {
+	b := NewBuyPage(nil, nil)
+	b.SetSymbol("BRK.A")
+	b.quantity.SetValue("0.5")
+
+	if _, err := b.buildOrder(); err != nil {
+		t.Fatalf("expected the server to check an unknown asset, got %v", err)
+	}
+
+	m, _ := b.Update(assets.InfoMsg{Symbol: "BRK.A", Asset: &assets.Asset{Symbol: "BRK.A"}})
+	b = m.(BuyPage)
+	if _, err := b.buildOrder(); err == nil {
+		t.Fatal("expected a fraction of an asset that isn't fractionable to be rejected")
+	}
+
+	m, _ = b.Update(tea.KeyMsg{Type: tea.KeyTab})
+	b = m.(BuyPage)
+	if _, err := b.buildOrder(); err == nil {
+		t.Fatal("expected an amount of an asset that isn't fractionable to be rejected")
+	}
+}
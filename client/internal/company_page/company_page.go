@@ -2,14 +2,17 @@ package companypage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/NimbleMarkets/ntcharts/linechart/timeserieslinechart"
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	corporateactions "github.com/Phantomvv1/KayTrade/client/internal/corporate_actions"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
 	"github.com/Phantomvv1/KayTrade/client/internal/requests"
 	tea "github.com/charmbracelet/bubbletea"
@@ -43,10 +46,20 @@ const (
 	TimeFrameDay
 	TimeFrameWeek
 	TimeFrameMonth
+	// The bars of these are built by the server from the minutes of the sessions
+	TimeFrame5Min
+	TimeFrame15Min
+	TimeFrame4Hour
+	TimeFrame2Day
 )
 
-var timeFrameStrings = []string{"1T", "1H", "1D", "1W", "1M"}
-var timeFrameLabels = []string{"Minute", "Hour", "Day", "Week", "Month"}
+var timeFrameStrings = []string{"1T", "1H", "1D", "1W", "1M", "5T", "15T", "4H", "2D"}
+var timeFrameLabels = []string{"Minute", "Hour", "Day", "Week", "Month", "5 Min", "15 Min", "4 Hour", "2 Day"}
+
+// sessions reports whether the bars of the timeframe follow the sessions of the market
+func (t TimeFrame) sessions() bool {
+	return t >= TimeFrame5Min
+}
 
 type BarData struct {
 	Timestamp time.Time `json:"t"`
@@ -67,6 +80,7 @@ type WebSocketMsg struct {
 	Price  float64 `json:"p"`
 	Size   float64 `json:"s"`
 	Time   string  `json:"t"`
+	Replay bool    `json:"replay"`
 }
 
 type CompanyPage struct {
@@ -86,6 +100,12 @@ type CompanyPage struct {
 	chartLoading bool
 	chartError   string
 
+	// The moving averages over the chart and the RSI under it
+	showIndicators  bool
+	indicators      indicatorsMsg
+	indicatorsError string
+	rsiChart        timeserieslinechart.Model
+
 	// Live chart state
 	liveChart     timeserieslinechart.Model
 	liveData      []timeserieslinechart.TimePoint
@@ -97,6 +117,14 @@ type CompanyPage struct {
 	low           float64
 	volume        float64
 	liveError     string
+	liveStatus    wsStatusMsg
+	liveClosed    *wsClosedMsg
+	extendedHours bool
+	replay        bool // the server replays a past day instead of the real time data
+
+	// The upcoming corporate actions of the company
+	notices       []string
+	noticesSymbol string
 }
 
 type fetchDataMsg struct {
@@ -114,6 +142,12 @@ type wsErrorMsg struct {
 
 type wsConnectedMsg struct{}
 
+// wsStatusMsg tells if the server is connected to the upstream stream or is reconnecting to it
+type wsStatusMsg struct {
+	status string
+	msg    string
+}
+
 type addCompanyMsg struct {
 	err error
 }
@@ -189,12 +223,20 @@ func (c *CompanyPage) redrawChart() {
 			lipgloss.NewStyle().Foreground(lipgloss.Color("#7C0A02")), lipgloss.NewStyle().Foreground(lipgloss.Color("#0B6623")))
 	}
 
-	c.chart.Draw()
+	if c.showIndicators && c.indicators.timeFrame == c.timeFrame && c.indicators.err == nil {
+		c.drawIndicators()
+	} else {
+		c.chart.Draw()
+	}
 	// c.chart.DrawBraille()
 }
 
 func (c CompanyPage) Init() tea.Cmd {
-	return nil
+	if c.CompanyInfo == nil || messages.IsCrypto(c.CompanyInfo.Symbol) {
+		return nil
+	}
+
+	return corporateactions.Fetch(c.CompanyInfo.Symbol, c.BaseModel.Client, c.BaseModel.TokenStore)
 }
 
 func (c CompanyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -203,6 +245,17 @@ func (c CompanyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	switch msg := msg.(type) {
+	case corporateactions.NoticesMsg:
+		if msg.Err != nil {
+			log.Println(msg.Err)
+			return c, nil
+		}
+
+		c.notices = msg.Notices
+		c.noticesSymbol = msg.Symbol
+
+		return c, nil
+
 	case fetchDataMsg:
 		c.chartLoading = false
 		if msg.err != nil {
@@ -217,10 +270,51 @@ func (c CompanyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		c.chartData = msg.data
 		c.redrawChart()
 
+		if c.showIndicators {
+			return c, c.fetchIndicatorsCmd()
+		}
+
+		return c, nil
+
+	case indicatorsMsg:
+		// The timeframe might have changed while they were calculated
+		if msg.timeFrame != c.timeFrame {
+			return c, nil
+		}
+
+		c.indicatorsError = ""
+		if msg.err != nil {
+			log.Println(msg.err)
+			c.indicatorsError = msg.err.Error()
+		}
+
+		c.indicators = msg
+		c.redrawChart()
+
 		return c, nil
 
 	case wsConnectedMsg:
 		c.liveConnected = true
+		c.liveStatus = wsStatusMsg{}
+
+		// The recorded trades are the latest ones, so they don't belong before those of a replayed day
+		if os.Getenv("KAYTRADE_REPLAY") != "" {
+			return c, nil
+		}
+
+		return c, c.fetchRecordedCmd()
+
+	case recordedMsg:
+		// The recorded trades only fill in the chart, so it works the same without them
+		if msg.err != nil {
+			log.Println(msg.err)
+			return c, nil
+		}
+
+		if c.CompanyInfo != nil && msg.symbol == c.CompanyInfo.Symbol {
+			c.backfill(msg.points)
+		}
+
 		return c, nil
 
 	case wsDataMsg:
@@ -231,6 +325,30 @@ func (c CompanyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		return c, c.listenWebSocket()
 
+	case wsStatusMsg:
+		c.liveStatus = msg
+		if msg.status == "open" {
+			c.liveClosed = nil
+		}
+
+		return c, c.listenWebSocket()
+
+	case wsClosedMsg:
+		first := c.liveClosed == nil
+		c.liveClosed = &msg
+		if !first {
+			return c, c.listenWebSocket()
+		}
+
+		return c, tea.Batch(c.listenWebSocket(), countdown())
+
+	case countdownMsg:
+		if c.liveClosed == nil {
+			return c, nil
+		}
+
+		return c, countdown()
+
 	case wsErrorMsg:
 		c.liveError = msg.err.Error()
 		c.liveConnected = false
@@ -273,7 +391,7 @@ func (c CompanyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case "esc":
 			if c.ws != nil {
-				c.ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+				c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 				c.ws.Close()
 				c.liveConnected = false
 			}
@@ -309,7 +427,7 @@ func (c CompanyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Clean up WebSocket when leaving live tab
 			if c.tabs[oldTab] == tabLiveUpdate && c.tabs[c.activeTab] != tabLiveUpdate {
 				if c.ws != nil {
-					c.ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+					c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 					c.ws.Close()
 					c.ws = nil
 					c.liveConnected = false
@@ -342,7 +460,7 @@ func (c CompanyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Clean up WebSocket when leaving live tab
 			if c.tabs[oldTab] == tabLiveUpdate && c.tabs[c.activeTab] != tabLiveUpdate {
 				if c.ws != nil {
-					c.ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+					c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 					c.ws.Close()
 					c.ws = nil
 					c.liveConnected = false
@@ -363,12 +481,46 @@ func (c CompanyPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					Symbol: c.CompanyInfo.Symbol,
 				}
 			}
+
+		case "o", "O":
+			return c, c.openOptionsChain()
+
+		case "s", "S":
+			return c, c.openShortSale()
 		}
 	}
 
 	return c, nil
 }
 
+// openShortSale opens the sell page without a position, so the order is a short sale. Only stocks can be sold short.
+func (c CompanyPage) openShortSale() tea.Cmd {
+	if messages.IsCrypto(c.CompanyInfo.Symbol) {
+		return nil
+	}
+
+	return func() tea.Msg {
+		return messages.PageSwitchMsg{
+			Page:   messages.SellPageNumber,
+			Symbol: c.CompanyInfo.Symbol,
+		}
+	}
+}
+
+// openOptionsChain shows the options contracts of the company, crypto pairs don't have any
+func (c CompanyPage) openOptionsChain() tea.Cmd {
+	if messages.IsCrypto(c.CompanyInfo.Symbol) {
+		return nil
+	}
+
+	return func() tea.Msg {
+		return messages.PageSwitchMsg{
+			Page:   messages.OptionsChainPageNumber,
+			Symbol: c.CompanyInfo.Symbol,
+		}
+	}
+}
+
 func (c CompanyPage) getDataFromBar(bar BarData) (string, string, string, string) {
 	return fmt.Sprintf("%.2f", bar.Open), fmt.Sprintf("%.2f", bar.High), fmt.Sprintf("%.2f", bar.Low), fmt.Sprintf("%.2f", bar.Close)
 
@@ -394,6 +546,23 @@ func (c *CompanyPage) handleChartKeys(key string) (tea.Model, tea.Cmd) {
 		return *c, c.fetchDataCmd()
 	case "5":
 		c.timeFrame = TimeFrameMonth
+		c.chartLoading = true
+		return *c, c.fetchDataCmd()
+	case "6", "7", "8", "9":
+		// The bars of crypto don't have sessions to follow
+		if messages.IsCrypto(c.CompanyInfo.Symbol) {
+			return *c, nil
+		}
+
+		c.timeFrame = TimeFrame5Min + TimeFrame(key[0]-'6')
+		c.chartLoading = true
+		return *c, c.fetchDataCmd()
+	case "e":
+		c.extendedHours = !c.extendedHours
+		if !c.timeFrame.sessions() {
+			return *c, nil
+		}
+
 		c.chartLoading = true
 		return *c, c.fetchDataCmd()
 	// case "ctrl+h", "ctrl+left":
@@ -465,7 +634,7 @@ func (c *CompanyPage) handleChartKeys(key string) (tea.Model, tea.Cmd) {
 		// Clean up WebSocket when leaving live tab
 		if c.tabs[oldTab] == tabLiveUpdate && c.tabs[c.activeTab] != tabLiveUpdate {
 			if c.ws != nil {
-				c.ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+				c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 				c.ws.Close()
 				c.ws = nil
 				c.liveConnected = false
@@ -498,7 +667,7 @@ func (c *CompanyPage) handleChartKeys(key string) (tea.Model, tea.Cmd) {
 		// Clean up WebSocket when leaving live tab
 		if c.tabs[oldTab] == tabLiveUpdate && c.tabs[c.activeTab] != tabLiveUpdate {
 			if c.ws != nil {
-				c.ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+				c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 				c.ws.Close()
 				c.ws = nil
 				c.liveConnected = false
@@ -515,6 +684,12 @@ func (c *CompanyPage) handleChartKeys(key string) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case "o", "O":
+		return *c, c.openOptionsChain()
+
+	case "s", "S":
+		return *c, c.openShortSale()
+
 	case "q", "ctrl+c":
 		if c.ws != nil {
 			c.ws.Close()
@@ -525,7 +700,7 @@ func (c *CompanyPage) handleChartKeys(key string) (tea.Model, tea.Cmd) {
 
 	case "esc":
 		if c.ws != nil {
-			c.ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+			c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 			c.ws.Close()
 		}
 		return *c, func() tea.Msg {
@@ -536,17 +711,36 @@ func (c *CompanyPage) handleChartKeys(key string) (tea.Model, tea.Cmd) {
 	case "r":
 		c.chartLoading = true
 		return *c, c.fetchDataCmd()
+	case "i":
+		// The indicators are calculated from the stock bars
+		if messages.IsCrypto(c.CompanyInfo.Symbol) {
+			return *c, nil
+		}
+
+		c.showIndicators = !c.showIndicators
+		c.indicatorsError = ""
+		if !c.showIndicators {
+			c.redrawChart()
+			return *c, nil
+		}
+
+		return *c, c.fetchIndicatorsCmd()
 	}
 	return *c, nil
 }
 
 func (c *CompanyPage) handleLiveChartKeys(key string) (tea.Model, tea.Cmd) {
 	switch key {
-	case "r":
+	case "r", "e":
+		if key == "e" {
+			c.extendedHours = !c.extendedHours
+		}
+
 		if c.ws != nil {
 			c.ws.Close()
 		}
 		c.liveData = make([]timeserieslinechart.TimePoint, 0)
+		c.liveClosed = nil
 		c.liveConnected = false
 		c.liveError = ""
 		cmd := c.connectWebSocket()
@@ -580,7 +774,7 @@ func (c *CompanyPage) handleLiveChartKeys(key string) (tea.Model, tea.Cmd) {
 		// Clean up WebSocket when leaving live tab
 		if c.tabs[oldTab] == tabLiveUpdate && c.tabs[c.activeTab] != tabLiveUpdate {
 			if c.ws != nil {
-				c.ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+				c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 				c.ws.Close()
 				c.ws = nil
 				c.liveConnected = false
@@ -613,7 +807,7 @@ func (c *CompanyPage) handleLiveChartKeys(key string) (tea.Model, tea.Cmd) {
 		// Clean up WebSocket when leaving live tab
 		if c.tabs[oldTab] == tabLiveUpdate && c.tabs[c.activeTab] != tabLiveUpdate {
 			if c.ws != nil {
-				c.ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+				c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 				c.ws.Close()
 				c.ws = nil
 				c.liveConnected = false
@@ -630,6 +824,12 @@ func (c *CompanyPage) handleLiveChartKeys(key string) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case "o", "O":
+		return *c, c.openOptionsChain()
+
+	case "s", "S":
+		return *c, c.openShortSale()
+
 	case "q", "ctrl+c":
 		if c.ws != nil {
 			c.ws.Close()
@@ -640,7 +840,7 @@ func (c *CompanyPage) handleLiveChartKeys(key string) (tea.Model, tea.Cmd) {
 
 	case "esc":
 		if c.ws != nil {
-			c.ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+			c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 			c.ws.Close()
 		}
 		return *c, func() tea.Msg {
@@ -690,7 +890,7 @@ func (c CompanyPage) View() string {
 		BorderForeground(purple).
 		Padding(2, 4).
 		Width(c.BaseModel.Width - 10).
-		Height(c.BaseModel.Height - 10)
+		Height(c.BaseModel.Height - 10 - len(c.currentNotices()))
 
 	// Get content for active tab
 	var content string
@@ -727,11 +927,17 @@ func (c CompanyPage) View() string {
 	if c.tabs[c.activeTab] == tabChart {
 		help = "1-5: timeframe • r: refresh • h/l/←/→: tabs • a: add company to watchlist • b: buy • esc: back • q: quit"
 	} else if c.tabs[c.activeTab] == tabLiveUpdate {
-		help = "r: reconnect • h/l/←/→: tabs • a: add company to watchlist • b: buy • esc: back • q: quit"
+		help = "r: reconnect • e: extended hours • h/l/←/→: tabs • a: add company to watchlist • b: buy • esc: back • q: quit"
 	} else {
 		help = "← → / h l: switch tabs • a: add company to watchlist • b: buy • esc: back • q: quit"
 	}
 
+	if !messages.IsCrypto(c.CompanyInfo.Symbol) {
+		help = strings.Replace(help, "b: buy", "b: buy • s: sell short • o: options", 1)
+		help = strings.Replace(help, "r: refresh", "r: refresh • i: indicators", 1)
+		help = strings.Replace(help, "1-5: timeframe", "1-9: timeframe • e: extended hours", 1)
+	}
+
 	helpStyle := lipgloss.NewStyle().
 		Foreground(gray).
 		Italic(true).
@@ -742,13 +948,22 @@ func (c CompanyPage) View() string {
 	helpText := helpStyle.Render(help)
 
 	// Combine everything
-	return lipgloss.JoinVertical(
-		lipgloss.Center,
-		title,
-		tabsStr.String(),
-		contentBox,
-		helpText,
-	)
+	parts := []string{title}
+	if notices := c.currentNotices(); len(notices) > 0 {
+		parts = append(parts, corporateactions.Render(notices))
+	}
+	parts = append(parts, tabsStr.String(), contentBox, helpText)
+
+	return lipgloss.JoinVertical(lipgloss.Center, parts...)
+}
+
+// currentNotices returns the notices only if they were fetched for the company that is shown
+func (c CompanyPage) currentNotices() []string {
+	if c.CompanyInfo == nil || c.noticesSymbol != c.CompanyInfo.Symbol {
+		return nil
+	}
+
+	return c.notices
 }
 
 func (c CompanyPage) renderOverview() string {
@@ -760,6 +975,14 @@ func (c CompanyPage) renderOverview() string {
 		Foreground(lipgloss.Color("#FFFFFF"))
 
 	var lines []string
+	if messages.IsCrypto(c.CompanyInfo.Symbol) {
+		lines = append(lines, labelStyle.Render("Name:            ")+valueStyle.Render(c.CompanyInfo.Name))
+		lines = append(lines, labelStyle.Render("Crypto Pair:     ")+valueStyle.Render(c.CompanyInfo.Symbol))
+		lines = append(lines, labelStyle.Render("Trading Hours:   ")+valueStyle.Render("24/7"))
+
+		return strings.Join(lines, "\n")
+	}
+
 	lines = append(lines, labelStyle.Render("Company Name:    ")+valueStyle.Render(c.CompanyInfo.Name))
 	lines = append(lines, labelStyle.Render("Stock Symbol:    ")+valueStyle.Render(c.CompanyInfo.Symbol))
 	lines = append(lines, labelStyle.Render("Domain:          ")+valueStyle.Render(c.CompanyInfo.Domain))
@@ -841,6 +1064,14 @@ func (c CompanyPage) renderChart() string {
 	// Render timeframe selector
 	var tfParts []string
 	for i, label := range timeFrameLabels {
+		if TimeFrame(i).sessions() && messages.IsCrypto(c.CompanyInfo.Symbol) {
+			continue
+		}
+
+		if TimeFrame(i).sessions() && c.extendedHours {
+			label += " (ext)"
+		}
+
 		if TimeFrame(i) == c.timeFrame {
 			tfParts = append(tfParts, lipgloss.NewStyle().
 				Foreground(lipgloss.Color("#00FF00")).
@@ -854,13 +1085,12 @@ func (c CompanyPage) renderChart() string {
 	}
 	timeFrameSelector := strings.Join(tfParts, "  ")
 
-	return lipgloss.JoinVertical(
-		lipgloss.Left,
-		timeFrameSelector,
-		"",
-		c.chart.View(),
-		"",
-	)
+	parts := []string{timeFrameSelector, "", c.chart.View(), ""}
+	if c.showIndicators {
+		parts = append(parts, c.renderIndicators(), "")
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
 }
 
 func (c CompanyPage) renderLivePrice() string {
@@ -882,6 +1112,32 @@ func (c CompanyPage) renderLivePrice() string {
 		Foreground(lipgloss.Color("#FF0000")).
 		Bold(true).
 		Render("🔴 LIVE")
+	if c.replay {
+		indicator = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#00AAFF")).
+			Bold(true).
+			Render("⏪ REPLAY")
+	}
+	if c.extendedHours {
+		indicator += " (extended hours)"
+	}
+
+	if c.liveClosed != nil {
+		return c.renderMarketClosed()
+	}
+
+	// The server is reconnecting to the market data, so the prices may be stale for a while
+	if c.liveStatus.status != "" && c.liveStatus.status != "connected" && c.liveStatus.status != "open" {
+		text := "⚠ " + strings.ToUpper(c.liveStatus.status)
+		if c.liveStatus.msg != "" {
+			text += ": " + c.liveStatus.msg
+		}
+
+		indicator = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFAA00")).
+			Bold(true).
+			Render(text)
+	}
 
 	// Stats
 	stats := ""
@@ -947,10 +1203,11 @@ func (c *CompanyPage) processWebSocketData(msg WebSocketMsg) error {
 	c.liveData = append(c.liveData, point)
 
 	// Keep only last 200 points
-	if len(c.liveData) > 200 {
-		c.liveData = c.liveData[len(c.liveData)-200:]
+	if len(c.liveData) > livePoints {
+		c.liveData = c.liveData[len(c.liveData)-livePoints:]
 	}
 
+	c.replay = msg.Replay
 	c.lastPrice = msg.Price
 	if len(c.liveData) > 1 {
 		c.lastChange = c.lastPrice - c.liveData[0].Value
@@ -965,59 +1222,83 @@ func (c *CompanyPage) processWebSocketData(msg WebSocketMsg) error {
 
 	c.volume += msg.Size
 
-	c.liveChart = timeserieslinechart.New(120, 30, timeserieslinechart.WithStyle(
-		lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")),
-	))
+	c.drawLiveChart()
 
-	for _, point := range c.liveData {
-		c.liveChart.Push(point)
-	}
+	return nil
+}
+
+// chartStart returns where the chart of the current timeframe starts
+func (c *CompanyPage) chartStart() (string, error) {
+	start := ""
+	switch c.timeFrame {
+	case TimeFrameMinute:
+		// Crypto trades around the clock, so there's no last market day to look for
+		if messages.IsCrypto(c.CompanyInfo.Symbol) {
+			start = time.Now().UTC().Add(-time.Hour * 24).Format(time.RFC3339)
+			break
+		}
 
-	c.liveChart.Draw()
+		resp, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/last-market-open-day", nil, c.BaseModel.Client, c.BaseModel.TokenStore)
+		if err != nil {
+			log.Println(err)
+			return "", err
+		}
 
-	return nil
+		var data map[string]time.Time
+		err = json.Unmarshal(resp, &data)
+		if err != nil {
+			log.Println(err)
+			return "", err
+		}
+
+		start = data["result"].Format(time.RFC3339)
+		log.Println("Minute")
+	case TimeFrameHour:
+		log.Println("Hour")
+		start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 14).Format(time.RFC3339) // 14 days
+	case TimeFrameDay:
+		log.Println("Day")
+		start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 365).Format(time.RFC3339) // 1 year
+	case TimeFrameWeek:
+		log.Println("Week")
+		start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 365 * 6).Format(time.RFC3339) // 6 years
+	case TimeFrameMonth:
+		log.Println("Month")
+		start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 365 * 20).Format(time.RFC3339) // 20 years
+	case TimeFrame5Min:
+		start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 3).Format(time.RFC3339) // 3 days
+	case TimeFrame15Min:
+		start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 7).Format(time.RFC3339) // 1 week
+	case TimeFrame4Hour:
+		start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 60).Format(time.RFC3339) // 2 months
+	case TimeFrame2Day:
+		start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 180).Format(time.RFC3339) // 6 months
+	}
+
+	return start, nil
 }
 
 func (c *CompanyPage) fetchDataCmd() tea.Cmd {
 	return func() tea.Msg {
-		start := ""
-		switch c.timeFrame {
-		case TimeFrameMinute:
-			resp, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/last-market-open-day", nil, c.BaseModel.Client, c.BaseModel.TokenStore)
-			if err != nil {
-				log.Println(err)
-				return fetchDataMsg{err: err}
-			}
-
-			var data map[string]time.Time
-			err = json.Unmarshal(resp, &data)
-			if err != nil {
-				log.Println(err)
-				return fetchDataMsg{err: err}
-			}
+		start, err := c.chartStart()
+		if err != nil {
+			return fetchDataMsg{err: err}
+		}
 
-			start = data["result"].Format(time.RFC3339)
-			log.Println("Minute")
-		case TimeFrameHour:
-			log.Println("Hour")
-			start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 14).Format(time.RFC3339) // 14 days
-		case TimeFrameDay:
-			log.Println("Day")
-			start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 365).Format(time.RFC3339) // 1 year
-		case TimeFrameWeek:
-			log.Println("Week")
-			start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 365 * 6).Format(time.RFC3339) // 6 years
-		case TimeFrameMonth:
-			log.Println("Month")
-			start = time.Now().UTC().Truncate(time.Hour * 24).Add(-time.Hour * 24 * 365 * 20).Format(time.RFC3339) // 20 years
+		// The server follows the pages of the stock bars, so the chart isn't cut off on long ranges
+		path := "/data/bars"
+		if messages.IsCrypto(c.CompanyInfo.Symbol) {
+			path = "/data/crypto/bars"
 		}
 
 		url := fmt.Sprintf(
-			"%s/data/bars?symbols=%s&start=%s&timeframe=%s",
+			"%s%s?symbols=%s&start=%s&timeframe=%s&all=true%s",
 			requests.BaseURL,
+			path,
 			c.CompanyInfo.Symbol,
 			start,
 			timeFrameStrings[c.timeFrame],
+			c.sessionParam(c.timeFrame),
 		)
 
 		body, err := requests.MakeRequest(
@@ -1047,6 +1328,18 @@ func (c *CompanyPage) fetchDataCmd() tea.Cmd {
 	}
 }
 
+// sessionParam tells the server which sessions the bars of the timeframe are built from
+func (c *CompanyPage) sessionParam(timeFrame TimeFrame) string {
+	switch {
+	case !timeFrame.sessions():
+		return ""
+	case c.extendedHours:
+		return "&session=extended"
+	default:
+		return "&session=core"
+	}
+}
+
 func (c *CompanyPage) padBars(response BarsResponse) BarsResponse {
 	lastBar := response.Bars[c.CompanyInfo.Symbol][len(response.Bars[c.CompanyInfo.Symbol])-1]
 	lastBar.Timestamp = time.Now().UTC()
@@ -1058,7 +1351,7 @@ func (c *CompanyPage) padBars(response BarsResponse) BarsResponse {
 
 func (c *CompanyPage) connectWebSocket() tea.Cmd {
 	host, _ := strings.CutPrefix(requests.BaseURL, "http://")
-	url := fmt.Sprintf("ws://%s/data/stocks/live/%s", host, c.CompanyInfo.Symbol)
+	url := fmt.Sprintf("ws://%s/data/stream", host) + c.streamQuery()
 
 	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
 	if err != nil {
@@ -1067,6 +1360,19 @@ func (c *CompanyPage) connectWebSocket() tea.Cmd {
 		}
 	}
 
+	// The live chart only shows the trades
+	subscription := map[string]any{
+		"action": "subscribe",
+		"trades": []string{c.CompanyInfo.Symbol},
+	}
+
+	if err := ws.WriteJSON(subscription); err != nil {
+		ws.Close()
+		return func() tea.Msg {
+			return wsErrorMsg{err: fmt.Errorf("failed to subscribe: %w", err)}
+		}
+	}
+
 	c.ws = ws
 	return func() tea.Msg {
 		return wsConnectedMsg{}
@@ -1080,13 +1386,32 @@ func (c *CompanyPage) listenWebSocket() tea.Cmd {
 		}
 
 		var msg map[string]interface{}
-		err := c.ws.ReadJSON(&msg)
-		if err != nil {
-			return wsErrorMsg{err: fmt.Errorf("connection lost: %w", err)}
-		}
+		for {
+			err := c.ws.ReadJSON(&msg)
+			if err != nil {
+				return wsErrorMsg{err: fmt.Errorf("connection lost: %w", err)}
+			}
 
-		if errMsg, ok := msg["error"].(string); ok {
-			return wsErrorMsg{err: fmt.Errorf("There was an error: %s", errMsg)}
+			if errMsg, ok := msg["error"].(string); ok {
+				return wsErrorMsg{err: fmt.Errorf("There was an error: %s", errMsg)}
+			}
+
+			if msg["T"] == "status" {
+				status, _ := msg["status"].(string)
+				text, _ := msg["msg"].(string)
+				return wsStatusMsg{status: status, msg: text}
+			}
+
+			if msg["T"] == "closed" {
+				return parseClosed(msg, c.CompanyInfo.Symbol)
+			}
+
+			// The acknowledgements of the subscription aren't drawn
+			if msg["T"] == "t" {
+				break
+			}
+
+			msg = nil
 		}
 
 		msgBytes, err := json.Marshal(msg)
@@ -1105,6 +1430,10 @@ func (c *CompanyPage) listenWebSocket() tea.Cmd {
 
 func (c CompanyPage) addCompanyToWatchlist() tea.Cmd {
 	return func() tea.Msg {
+		if messages.IsCrypto(c.CompanyInfo.Symbol) {
+			return addCompanyMsg{err: errors.New("Error crypto pairs can't be added to the watchlist")}
+		}
+
 		_, err := requests.MakeRequest(http.MethodPost, requests.BaseURL+"/watchlist/"+c.CompanyInfo.Symbol, nil, c.BaseModel.Client, c.BaseModel.TokenStore)
 		if err != nil {
 			return addCompanyMsg{err: err}
@@ -1127,4 +1456,8 @@ func (c *CompanyPage) Reload() {
 	c.liveConnected = false
 	c.chartError = ""
 	c.liveError = ""
+	c.liveStatus = wsStatusMsg{}
+	c.liveClosed = nil
+	c.notices = nil
+	c.noticesSymbol = ""
 }
@@ -2,10 +2,13 @@ package companypage
 
 import (
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/NimbleMarkets/ntcharts/linechart/timeserieslinechart"
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	corporateactions "github.com/Phantomvv1/KayTrade/client/internal/corporate_actions"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -107,6 +110,76 @@ func TestCompanyPage_Update_wsErrorMsg(t *testing.T) {
 	}
 }
 
+func TestCompanyPage_Update_wsStatusMsg(t *testing.T) {
+	p := newTestPage()
+	p.liveConnected = true
+
+	m, cmd := p.Update(wsStatusMsg{status: "reconnecting", msg: "The connection was lost"})
+	cp := m.(CompanyPage)
+
+	if cmd == nil {
+		t.Fatal("expected to keep listening while the server reconnects")
+	}
+	if !cp.liveConnected || cp.liveError != "" {
+		t.Fatal("expected a status update not to be treated as an error")
+	}
+	if !strings.Contains(cp.renderLivePrice(), "RECONNECTING") {
+		t.Fatal("expected the live view to show that the server is reconnecting")
+	}
+
+	m, _ = cp.Update(wsStatusMsg{status: "connected"})
+	if strings.Contains(m.(CompanyPage).renderLivePrice(), "RECONNECTING") {
+		t.Fatal("expected the reconnecting status to be cleared")
+	}
+}
+
+func TestCompanyPage_MarketClosedCountdown(t *testing.T) {
+	p := newTestPage()
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "AAPL"}
+	p.liveConnected = true
+
+	closed := parseClosed(map[string]any{
+		"T":         "closed",
+		"session":   "closed",
+		"next_open": time.Now().Add(2*time.Hour + 30*time.Minute).UTC().Format(time.RFC3339),
+		"snapshots": map[string]any{"AAPL": map[string]any{"latestTrade": map[string]any{"p": 187.5}}},
+	}, "AAPL")
+
+	if closed.lastPrice != 187.5 {
+		t.Fatalf("expected the last price from the snapshot, got %v", closed.lastPrice)
+	}
+
+	m, cmd := p.Update(closed)
+	if cmd == nil {
+		t.Fatal("expected to keep listening and count down")
+	}
+
+	view := m.(CompanyPage).renderLivePrice()
+	if !strings.Contains(view, "MARKET CLOSED") || !strings.Contains(view, "$187.50") || !strings.Contains(view, "Opens in 2h") {
+		t.Fatalf("expected the countdown to the open, got %s", view)
+	}
+
+	m, _ = m.(CompanyPage).Update(wsStatusMsg{status: "open"})
+	if m.(CompanyPage).liveClosed != nil {
+		t.Fatal("expected the market closed notice to be cleared once it opens")
+	}
+}
+
+func TestFormatCountdown(t *testing.T) {
+	tests := map[time.Duration]string{
+		-time.Second:                "any moment now",
+		90 * time.Second:            "0h 01m 30s",
+		26*time.Hour + time.Minute:  "1d 2h 1m",
+		3*time.Hour + 5*time.Second: "3h 00m 05s",
+	}
+
+	for d, expected := range tests {
+		if got := formatCountdown(d); got != expected {
+			t.Fatalf("expected %s for %v, got %s", expected, d, got)
+		}
+	}
+}
+
 func TestCompanyPage_Update_addCompanyMsg_Error(t *testing.T) {
 	p := newTestPage()
 
@@ -129,6 +202,16 @@ func TestCompanyPage_Update_addCompanyMsg_Success(t *testing.T) {
 	}
 }
 
+func TestCompanyPage_CryptoIsNotAddedToTheWatchlist(t *testing.T) {
+	p := newTestPage()
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "BTC/USD", Class: "crypto"}
+
+	msg := p.addCompanyToWatchlist()().(addCompanyMsg)
+	if msg.err == nil {
+		t.Fatal("expected an error for a crypto pair")
+	}
+}
+
 func TestCompanyPage_Update_KeySwitchBuyPage(t *testing.T) {
 	p := newTestPage()
 	p.CompanyInfo = &messages.CompanyInfo{Symbol: "AAPL"}
@@ -141,6 +224,40 @@ func TestCompanyPage_Update_KeySwitchBuyPage(t *testing.T) {
 	}
 }
 
+func TestCompanyPage_Update_KeySwitchOptionsChain(t *testing.T) {
+	p := newTestPage()
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "AAPL"}
+
+	_, cmd := p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+
+	msg := cmd().(messages.PageSwitchMsg)
+	if msg.Page != messages.OptionsChainPageNumber || msg.Symbol != "AAPL" {
+		t.Fatalf("expected a switch to the options chain of AAPL, got %+v", msg)
+	}
+
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "BTC/USD", Class: "crypto"}
+	if _, cmd := p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")}); cmd != nil {
+		t.Fatal("expected crypto pairs to have no options chain")
+	}
+}
+
+func TestCompanyPage_Update_KeySwitchShortSale(t *testing.T) {
+	p := newTestPage()
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "TSLA"}
+
+	_, cmd := p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+
+	msg := cmd().(messages.PageSwitchMsg)
+	if msg.Page != messages.SellPageNumber || msg.Symbol != "TSLA" || msg.MaxQuantity != 0 {
+		t.Fatalf("expected a short sale of TSLA, got %+v", msg)
+	}
+
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "BTC/USD"}
+	if _, cmd := p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")}); cmd != nil {
+		t.Fatal("expected crypto not to be sold short")
+	}
+}
+
 func TestCompanyPage_TabSwitching_DoesNotPanic(t *testing.T) {
 	p := newTestPage()
 	p.CompanyInfo = &messages.CompanyInfo{Symbol: "AAPL"}
@@ -151,3 +268,130 @@ func TestCompanyPage_TabSwitching_DoesNotPanic(t *testing.T) {
 		_, _ = p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
 	}
 }
+
+func TestCompanyPage_ShowsTheCorporateActionsOfTheCompany(t *testing.T) {
+	p := newTestPage()
+	p.BaseModel.Width = 160
+	p.BaseModel.Height = 40
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "AAPL", Name: "Apple"}
+
+	model, _ := p.Update(corporateactions.NoticesMsg{Symbol: "AAPL", Notices: []string{"AAPL pays a $0.26 dividend per share, ex-date tomorrow"}})
+	p = model.(CompanyPage)
+	if !strings.Contains(p.View(), "ex-date tomorrow") {
+		t.Fatal("expected the notice to be shown")
+	}
+
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "MSFT", Name: "Microsoft"}
+	if strings.Contains(p.View(), "ex-date tomorrow") {
+		t.Fatal("expected the notice of another company to be hidden")
+	}
+}
+
+func TestCompanyPage_Update_indicatorsMsg(t *testing.T) {
+	p := newTestPage()
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "AAPL"}
+	p.timeFrame = TimeFrameDay
+	p.showIndicators = true
+
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	p.chartData = []BarData{{Timestamp: day, Close: 10}, {Timestamp: day.Add(24 * time.Hour), Close: 11}}
+
+	rsi, sma := 62.5, 10.5
+	bars := p.chartData
+	msg := indicatorsMsg{
+		timeFrame: TimeFrameDay,
+		sma:       indicatorPoints(bars, []*float64{nil, &sma}),
+		rsi:       indicatorPoints(bars, []*float64{nil, &rsi}),
+	}
+
+	if len(msg.sma) != 1 || !msg.sma[0].Time.Equal(bars[1].Timestamp) {
+		t.Fatalf("expected only the warmed up value on the time of its bar, got %v", msg.sma)
+	}
+
+	m, _ := p.Update(indicatorsMsg{timeFrame: TimeFrameHour, rsi: msg.rsi})
+	if m.(CompanyPage).indicators.rsi != nil {
+		t.Fatal("expected the indicators of another timeframe to be ignored")
+	}
+
+	m, _ = p.Update(msg)
+	if view := m.(CompanyPage).renderChart(); !strings.Contains(view, "RSI 14: 62.5") || !strings.Contains(view, "SMA 20") {
+		t.Fatal("expected the chart to show the indicators")
+	}
+}
+
+func TestCompanyPage_SessionTimeFrames(t *testing.T) {
+	p := newTestPage()
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "AAPL"}
+	p.activeTab = 3
+
+	m, cmd := p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("8")})
+	cp := m.(CompanyPage)
+	if cp.timeFrame != TimeFrame4Hour || cmd == nil {
+		t.Fatal("expected 8 to fetch the 4 hour bars")
+	}
+	if cp.sessionParam(cp.timeFrame) != "&session=core" || cp.sessionParam(TimeFrameHour) != "" {
+		t.Fatal("expected only the session bars to follow the core session")
+	}
+
+	m, _ = cp.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	if cp = m.(CompanyPage); cp.sessionParam(cp.timeFrame) != "&session=extended" {
+		t.Fatal("expected e to include the extended hours")
+	}
+
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "BTC/USD"}
+	if m, cmd := p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("6")}); cmd != nil || m.(CompanyPage).timeFrame != TimeFrameMinute {
+		t.Fatal("expected crypto not to have session bars")
+	}
+}
+
+func TestCompanyPage_Update_recordedMsg(t *testing.T) {
+	p := newTestPage()
+	p.CompanyInfo = &messages.CompanyInfo{Symbol: "AAPL"}
+
+	now := time.Now().UTC()
+	p.liveData = []timeserieslinechart.TimePoint{{Time: now, Value: 103}}
+
+	m, _ := p.Update(recordedMsg{symbol: p.CompanyInfo.Symbol, points: []timeserieslinechart.TimePoint{
+		{Time: now.Add(-2 * time.Second), Value: 101},
+		{Time: now.Add(-time.Second), Value: 102},
+		{Time: now, Value: 103},
+	}})
+	cp := m.(CompanyPage)
+
+	if len(cp.liveData) != 3 || cp.liveData[0].Value != 101 || cp.liveData[2].Value != 103 {
+		t.Fatalf("expected the recorded trades before the live one, got %v", cp.liveData)
+	}
+
+	if cp.lastChange != 2 || cp.low != 101 {
+		t.Fatalf("unexpected stats: change %v, low %v", cp.lastChange, cp.low)
+	}
+}
+
+func TestCompanyPage_ShowsThatTheDataIsReplayed(t *testing.T) {
+	p := newTestPage()
+	p.liveConnected = true
+
+	if err := p.processWebSocketData(WebSocketMsg{Type: "t", Symbol: "AAPL", Price: 100, Time: "2024-03-15T14:30:00Z", Replay: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if view := p.renderLivePrice(); !strings.Contains(view, "REPLAY") || strings.Contains(view, "LIVE") {
+		t.Fatal("expected the live view to show that the data is replayed")
+	}
+}
+
+func TestCompanyPage_streamQuery(t *testing.T) {
+	p := newTestPage()
+
+	if query := p.streamQuery(); query != "" {
+		t.Fatalf("expected no query, got %s", query)
+	}
+
+	t.Setenv("KAYTRADE_REPLAY", "2024-03-15")
+	t.Setenv("KAYTRADE_REPLAY_SPEED", "10")
+	p.extendedHours = true
+
+	if query := p.streamQuery(); query != "?extended_hours=true&replay=2024-03-15&speed=10" {
+		t.Fatalf("unexpected query %s", query)
+	}
+}
@@ -0,0 +1,143 @@
+package companypage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/NimbleMarkets/ntcharts/linechart/timeserieslinechart"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// The indicators drawn over the chart and the one drawn under it
+	smaIndicator = "sma:20"
+	emaIndicator = "ema:50"
+	rsiIndicator = "rsi:14"
+)
+
+var (
+	smaStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFAA00"))
+	emaStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#00AAFF"))
+	rsiStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#AA66FF"))
+)
+
+type IndicatorsResponse struct {
+	Bars       []BarData             `json:"bars"`
+	Indicators map[string][]*float64 `json:"indicators"`
+}
+
+// indicatorsMsg has the points of the indicators of the timeframe they were calculated for
+type indicatorsMsg struct {
+	timeFrame TimeFrame
+	sma       []timeserieslinechart.TimePoint
+	ema       []timeserieslinechart.TimePoint
+	rsi       []timeserieslinechart.TimePoint
+	err       error
+}
+
+// indicatorPoints pairs the values of the indicator with the time of their bars, leaving out the ones without a value
+func indicatorPoints(bars []BarData, values []*float64) []timeserieslinechart.TimePoint {
+	points := make([]timeserieslinechart.TimePoint, 0, len(values))
+	for i, value := range values {
+		if value == nil || i >= len(bars) {
+			continue
+		}
+
+		points = append(points, timeserieslinechart.TimePoint{Time: bars[i].Timestamp, Value: *value})
+	}
+
+	return points
+}
+
+func (c *CompanyPage) fetchIndicatorsCmd() tea.Cmd {
+	timeFrame := c.timeFrame
+
+	return func() tea.Msg {
+		start, err := c.chartStart()
+		if err != nil {
+			return indicatorsMsg{timeFrame: timeFrame, err: err}
+		}
+
+		url := fmt.Sprintf(
+			"%s/data/indicators?symbol=%s&start=%s&timeframe=%s&ind=%s,%s,%s%s",
+			requests.BaseURL,
+			c.CompanyInfo.Symbol,
+			start,
+			timeFrameStrings[timeFrame],
+			smaIndicator, emaIndicator, rsiIndicator,
+			c.sessionParam(timeFrame),
+		)
+
+		body, err := requests.MakeRequest(http.MethodGet, url, nil, c.BaseModel.Client, c.BaseModel.TokenStore)
+		if err != nil {
+			return indicatorsMsg{timeFrame: timeFrame, err: err}
+		}
+
+		var response IndicatorsResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return indicatorsMsg{timeFrame: timeFrame, err: err}
+		}
+
+		return indicatorsMsg{
+			timeFrame: timeFrame,
+			sma:       indicatorPoints(response.Bars, response.Indicators[smaIndicator]),
+			ema:       indicatorPoints(response.Bars, response.Indicators[emaIndicator]),
+			rsi:       indicatorPoints(response.Bars, response.Indicators[rsiIndicator]),
+		}
+	}
+}
+
+// drawIndicators draws the moving averages over the chart and the RSI in a chart of its own
+func (c *CompanyPage) drawIndicators() {
+	for _, point := range c.indicators.sma {
+		c.chart.PushDataSet(smaIndicator, point)
+	}
+
+	for _, point := range c.indicators.ema {
+		c.chart.PushDataSet(emaIndicator, point)
+	}
+
+	c.chart.SetDataSetStyle(smaIndicator, smaStyle)
+	c.chart.SetDataSetStyle(emaIndicator, emaStyle)
+	c.chart.DrawAll()
+
+	c.rsiChart = timeserieslinechart.New(120, 8,
+		timeserieslinechart.WithStyle(rsiStyle),
+		timeserieslinechart.WithYRange(0, 100),
+		timeserieslinechart.WithXYSteps(8, 2),
+	)
+
+	// The RSI chart spans the same time as the price chart, so the two line up
+	if len(c.chartData) > 0 {
+		c.rsiChart.SetTimeRange(c.chartData[0].Timestamp, c.chartData[len(c.chartData)-1].Timestamp)
+		c.rsiChart.SetViewTimeRange(c.chartData[0].Timestamp, c.chartData[len(c.chartData)-1].Timestamp)
+	}
+
+	for _, point := range c.indicators.rsi {
+		c.rsiChart.Push(point)
+	}
+
+	c.rsiChart.Draw()
+}
+
+func (c CompanyPage) renderIndicators() string {
+	if c.indicatorsError != "" {
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Render("Indicators: " + c.indicatorsError)
+	}
+
+	if c.indicators.timeFrame != c.timeFrame || c.indicators.rsi == nil {
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("#FFAA00")).Render("Loading indicators...")
+	}
+
+	legend := smaStyle.Render("── SMA 20") + "  " + emaStyle.Render("── EMA 50")
+
+	rsi := "RSI 14"
+	if len(c.indicators.rsi) > 0 {
+		rsi = fmt.Sprintf("RSI 14: %.1f", c.indicators.rsi[len(c.indicators.rsi)-1].Value)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, legend, "", rsiStyle.Render(rsi), c.rsiChart.View())
+}
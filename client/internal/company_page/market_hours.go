@@ -0,0 +1,86 @@
+package companypage
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// wsClosedMsg is sent by the server while the stock isn't streaming because the market is closed
+type wsClosedMsg struct {
+	session   string
+	nextOpen  time.Time
+	lastPrice float64
+}
+
+// countdownMsg redraws the time left until the market opens
+type countdownMsg struct{}
+
+func countdown() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return countdownMsg{}
+	})
+}
+
+// parseClosed reads the next open and the last price of the symbol from the snapshot the server sent
+func parseClosed(msg map[string]any, symbol string) wsClosedMsg {
+	closed := wsClosedMsg{}
+	closed.session, _ = msg["session"].(string)
+
+	if nextOpen, ok := msg["next_open"].(string); ok {
+		closed.nextOpen, _ = time.Parse(time.RFC3339, nextOpen)
+	}
+
+	snapshots, _ := msg["snapshots"].(map[string]any)
+	snapshot, _ := snapshots[symbol].(map[string]any)
+	if trade, ok := snapshot["latestTrade"].(map[string]any); ok {
+		closed.lastPrice, _ = trade["p"].(float64)
+	}
+
+	return closed
+}
+
+func formatCountdown(d time.Duration) string {
+	if d <= 0 {
+		return "any moment now"
+	}
+
+	d = d.Round(time.Second)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, d/time.Hour, (d%time.Hour)/time.Minute)
+	}
+
+	return fmt.Sprintf("%dh %02dm %02ds", d/time.Hour, (d%time.Hour)/time.Minute, (d%time.Minute)/time.Second)
+}
+
+func (c CompanyPage) renderMarketClosed() string {
+	session := "MARKET CLOSED"
+	if c.liveClosed.session == "pre_market" || c.liveClosed.session == "after_hours" {
+		session = "EXTENDED HOURS (press 'e' to stream them)"
+	}
+
+	lines := []string{
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#FFAA00")).Bold(true).Render("🌙 " + session),
+		"",
+	}
+
+	if c.liveClosed.lastPrice > 0 {
+		lines = append(lines, fmt.Sprintf("Last: $%.2f", c.liveClosed.lastPrice))
+	}
+
+	if !c.liveClosed.nextOpen.IsZero() {
+		lines = append(lines, fmt.Sprintf("Opens in %s (%s)",
+			formatCountdown(time.Until(c.liveClosed.nextOpen)),
+			c.liveClosed.nextOpen.Local().Format("Mon Jan 2 15:04"),
+		))
+	}
+
+	lines = append(lines, "", "The live updates start by themselves once the market opens")
+
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
@@ -0,0 +1,114 @@
+package companypage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/NimbleMarkets/ntcharts/linechart/timeserieslinechart"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The most points the live chart keeps
+const livePoints = 200
+
+type RecordedResponse struct {
+	Trades map[string][]WebSocketMsg `json:"trades"`
+}
+
+// recordedMsg has the trades the server recorded before the live chart connected, oldest first
+type recordedMsg struct {
+	symbol string
+	points []timeserieslinechart.TimePoint
+	err    error
+}
+
+// fetchRecordedCmd gets the last trades the server recorded, so the live chart doesn't start out empty after
+// connecting again
+func (c *CompanyPage) fetchRecordedCmd() tea.Cmd {
+	return func() tea.Msg {
+		symbol := c.CompanyInfo.Symbol
+
+		query := url.Values{}
+		query.Set("symbols", symbol)
+		query.Set("kind", "trades")
+		query.Set("start", time.Now().UTC().Add(-24*time.Hour).Format(time.RFC3339))
+		query.Set("limit", fmt.Sprint(livePoints))
+		query.Set("sort", "desc")
+
+		body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/data/recorded?"+query.Encode(), nil, c.BaseModel.Client, c.BaseModel.TokenStore)
+		if err != nil {
+			return recordedMsg{symbol: symbol, err: err}
+		}
+
+		var response RecordedResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return recordedMsg{symbol: symbol, err: err}
+		}
+
+		trades := response.Trades[symbol]
+		slices.Reverse(trades)
+
+		points := make([]timeserieslinechart.TimePoint, 0, len(trades))
+		for _, trade := range trades {
+			t, err := time.Parse(time.RFC3339, trade.Time)
+			if err != nil {
+				continue
+			}
+
+			points = append(points, timeserieslinechart.TimePoint{Time: t, Value: trade.Price})
+		}
+
+		return recordedMsg{symbol: symbol, points: points}
+	}
+}
+
+// backfill puts the recorded points before the ones that came live
+func (c *CompanyPage) backfill(points []timeserieslinechart.TimePoint) {
+	if len(c.liveData) > 0 {
+		first := c.liveData[0].Time
+		points = slices.DeleteFunc(points, func(point timeserieslinechart.TimePoint) bool {
+			return !point.Time.Before(first)
+		})
+	}
+
+	if len(points) == 0 {
+		return
+	}
+
+	c.liveData = append(points, c.liveData...)
+	if len(c.liveData) > livePoints {
+		c.liveData = c.liveData[len(c.liveData)-livePoints:]
+	}
+
+	c.lastPrice = c.liveData[len(c.liveData)-1].Value
+	c.lastChange = c.lastPrice - c.liveData[0].Value
+
+	for _, point := range points {
+		if c.high == 0 || point.Value > c.high {
+			c.high = point.Value
+		}
+		if c.low == 0 || point.Value < c.low {
+			c.low = point.Value
+		}
+	}
+
+	c.drawLiveChart()
+}
+
+func (c *CompanyPage) drawLiveChart() {
+	c.liveChart = timeserieslinechart.New(120, 30, timeserieslinechart.WithStyle(
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")),
+	))
+
+	for _, point := range c.liveData {
+		c.liveChart.Push(point)
+	}
+
+	c.liveChart.Draw()
+}
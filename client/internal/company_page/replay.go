@@ -0,0 +1,28 @@
+package companypage
+
+import (
+	"net/url"
+	"os"
+)
+
+// streamQuery is the query of the live stream. KAYTRADE_REPLAY=2024-03-15 and KAYTRADE_REPLAY_SPEED=10 replay a
+// past day instead, which is handy when the market is closed.
+func (c *CompanyPage) streamQuery() string {
+	query := url.Values{}
+	if c.extendedHours {
+		query.Set("extended_hours", "true")
+	}
+
+	if replay := os.Getenv("KAYTRADE_REPLAY"); replay != "" {
+		query.Set("replay", replay)
+		if speed := os.Getenv("KAYTRADE_REPLAY_SPEED"); speed != "" {
+			query.Set("speed", speed)
+		}
+	}
+
+	if len(query) == 0 {
+		return ""
+	}
+
+	return "?" + query.Encode()
+}
@@ -0,0 +1,366 @@
+package conditionalorderspage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type ConditionalOrder struct {
+	ID          string         `json:"id"`
+	Symbol      string         `json:"symbol"`
+	Condition   string         `json:"condition"`
+	Threshold   float64        `json:"threshold"`
+	Order       map[string]any `json:"order"`
+	OCOGroup    string         `json:"oco_group"`
+	Status      string         `json:"status"`
+	OrderID     string         `json:"order_id"`
+	Error       string         `json:"error"`
+	CreatedAt   time.Time      `json:"created_at"`
+	TriggeredAt *time.Time     `json:"triggered_at"`
+}
+
+func (o ConditionalOrder) FilterValue() string {
+	return o.Symbol
+}
+
+func (o ConditionalOrder) Title() string {
+	side, _ := o.Order["side"].(string)
+	symbol, _ := o.Order["symbol"].(string)
+
+	amount := ""
+	if qty, ok := o.Order["qty"]; ok {
+		amount = fmt.Sprintf("%v", qty)
+	} else if notional, ok := o.Order["notional"]; ok {
+		amount = fmt.Sprintf("$%v of", notional)
+	}
+
+	return fmt.Sprintf("%s %s %s if %s", side, amount, symbol, o.condition())
+}
+
+func (o ConditionalOrder) condition() string {
+	switch o.Condition {
+	case "price_crosses_above":
+		return fmt.Sprintf("%s crosses above $%.2f", o.Symbol, o.Threshold)
+	case "price_crosses_below":
+		return fmt.Sprintf("%s crosses below $%.2f", o.Symbol, o.Threshold)
+	case "rsi_below":
+		return fmt.Sprintf("RSI of %s < %.0f", o.Symbol, o.Threshold)
+	case "rsi_above":
+		return fmt.Sprintf("RSI of %s > %.0f", o.Symbol, o.Threshold)
+	default:
+		return o.Condition
+	}
+}
+
+func (o ConditionalOrder) Description() string {
+	statusSymbol := ""
+	switch o.Status {
+	case "triggered":
+		statusSymbol = "✓"
+	case "pending":
+		statusSymbol = "⋯"
+	case "failed", "canceled":
+		statusSymbol = "✗"
+	default:
+		statusSymbol = "•"
+	}
+
+	description := fmt.Sprintf("%s %s", statusSymbol, o.Status)
+	if o.TriggeredAt != nil {
+		description += " at " + o.TriggeredAt.Format("2006-01-02 15:04")
+	}
+
+	if o.OCOGroup != "" {
+		description += ", OCO: " + o.OCOGroup
+	}
+
+	if o.Error != "" {
+		description += ", " + o.Error
+	}
+
+	return description
+}
+
+type ConditionalOrdersLoadedMsg struct {
+	orders []ConditionalOrder
+	err    error
+}
+
+type ConditionalOrderCanceledMsg struct {
+	id  string
+	err error
+}
+
+type ConditionalOrdersPage struct {
+	BaseModel basemodel.BaseModel
+	orders    list.Model
+	titleBar  string
+	loaded    bool
+	spinner   spinner.Model
+	err       error
+	Reloaded  bool
+	filtering bool
+	hasFilter bool
+}
+
+func New(client *http.Client, tokenStore *basemodel.TokenStore) ConditionalOrdersPage {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF"))
+
+	delegate := list.NewDefaultDelegate()
+
+	cyan := lipgloss.Color("#00FFFF")
+	purple := lipgloss.Color("#A020F0")
+
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
+		Foreground(cyan).
+		BorderForeground(purple)
+	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.
+		Foreground(lipgloss.Color("#888888")).
+		BorderForeground(purple)
+
+	l := list.New([]list.Item{}, delegate, 0, 0)
+	l.DisableQuitKeybindings()
+	l.Title = ""
+	l.SetFilteringEnabled(true)
+	l.Styles.Title = lipgloss.NewStyle().
+		Foreground(cyan).
+		Bold(true).
+		Padding(0, 1)
+	l.Styles.PaginationStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262"))
+	l.Styles.HelpStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262"))
+
+	l.AdditionalFullHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+			key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "cancel")),
+			key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh")),
+		}
+	}
+
+	return ConditionalOrdersPage{
+		BaseModel: basemodel.BaseModel{Client: client, TokenStore: tokenStore},
+		orders:    l,
+		titleBar:  "CONDITIONAL ORDERS",
+		loaded:    false,
+		spinner:   s,
+		Reloaded:  true,
+	}
+}
+
+func (c ConditionalOrdersPage) Init() tea.Cmd {
+	return tea.Batch(
+		c.spinner.Tick,
+		c.loadOrders,
+	)
+}
+
+func (c ConditionalOrdersPage) loadOrders() tea.Msg {
+	body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/trading/conditional", nil, c.BaseModel.Client, c.BaseModel.TokenStore)
+	if err != nil {
+		return ConditionalOrdersLoadedMsg{err: err}
+	}
+
+	var info struct {
+		Orders []ConditionalOrder `json:"conditional_orders"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return ConditionalOrdersLoadedMsg{err: err}
+	}
+
+	return ConditionalOrdersLoadedMsg{orders: info.Orders}
+}
+
+func (c ConditionalOrdersPage) cancelOrder(id string) tea.Cmd {
+	return func() tea.Msg {
+		_, err := requests.MakeRequest(http.MethodDelete, requests.BaseURL+"/trading/conditional/"+id, nil, c.BaseModel.Client, c.BaseModel.TokenStore)
+		return ConditionalOrderCanceledMsg{id: id, err: err}
+	}
+}
+
+func (c ConditionalOrdersPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case ConditionalOrdersLoadedMsg:
+		c.loaded = true
+		c.err = msg.err
+		if msg.err == nil {
+			items := make([]list.Item, len(msg.orders))
+			for i, order := range msg.orders {
+				items[i] = order
+			}
+
+			c.orders.SetItems(items)
+			c.orders.SetSize(c.BaseModel.Width/2, c.BaseModel.Height/2)
+		}
+
+		return c, nil
+
+	case ConditionalOrderCanceledMsg:
+		if msg.err != nil {
+			return c, func() tea.Msg {
+				return messages.PageSwitchMsg{
+					Page: messages.ErrorPageNumber,
+					Err:  msg.err,
+				}
+			}
+		}
+
+		for i, item := range c.orders.Items() {
+			order := item.(ConditionalOrder)
+			if order.ID == msg.id {
+				order.Status = "canceled"
+				c.orders.SetItem(i, order)
+				break
+			}
+		}
+
+		return c, nil
+
+	case spinner.TickMsg:
+		if !c.loaded {
+			c.spinner, cmd = c.spinner.Update(msg)
+			return c, cmd
+		}
+		return c, nil
+
+	case tea.KeyMsg:
+		if c.filtering {
+			switch msg.String() {
+			case "enter":
+				c.filtering = false
+				c.hasFilter = true
+			case "esc":
+				c.filtering = false
+			}
+
+			break
+		}
+
+		switch msg.String() {
+		case "q":
+			return c, func() tea.Msg {
+				return messages.QuitMsg{}
+			}
+
+		case "/":
+			c.filtering = true
+
+		case "c", "C":
+			if !c.loaded || c.err != nil {
+				return c, nil
+			}
+
+			order, ok := c.orders.SelectedItem().(ConditionalOrder)
+			if ok && order.Status == "pending" {
+				return c, c.cancelOrder(order.ID)
+			}
+
+			return c, nil
+
+		case "r", "R":
+			c.Reload()
+			return c, c.Init()
+
+		case "esc":
+			if !c.hasFilter {
+				return c, func() tea.Msg {
+					return messages.SmartPageSwitchMsg{
+						Page: messages.ProfilePageNumber,
+					}
+				}
+			}
+
+			c.hasFilter = false
+		}
+	}
+
+	if c.loaded && c.err == nil {
+		c.orders, cmd = c.orders.Update(msg)
+	}
+
+	return c, cmd
+}
+
+func (c ConditionalOrdersPage) View() string {
+	cyan := lipgloss.Color("#00FFFF")
+	purple := lipgloss.Color("#A020F0")
+	red := lipgloss.Color("#D30000")
+	gray := lipgloss.Color("#626262")
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(cyan).
+		Bold(true).
+		Padding(0, 2).
+		MarginBottom(1).
+		Align(lipgloss.Center)
+	header := "\n" + headerStyle.Render(c.titleBar) + "\n\n"
+
+	if !c.loaded {
+		return lipgloss.Place(c.BaseModel.Width, c.BaseModel.Height, lipgloss.Center, lipgloss.Center, c.spinner.View())
+	}
+
+	if c.err != nil {
+		errorMsg := lipgloss.NewStyle().
+			Foreground(red).
+			Padding(1, 2).
+			Render(fmt.Sprintf("Error loading conditional orders: %v", c.err))
+		help := lipgloss.NewStyle().
+			Foreground(gray).
+			Render("esc: back • r: retry • q: quit")
+		content := lipgloss.JoinVertical(lipgloss.Left, errorMsg, "", help)
+		return header + content
+	}
+
+	if len(c.orders.Items()) == 0 {
+		msg := lipgloss.NewStyle().
+			Padding(1, 1).
+			Render(strings.Join([]string{
+				"No conditional orders found.",
+				"Orders placed when a price or RSI condition is met will appear here.",
+			}, "\n"))
+		centerContent := lipgloss.Place(c.BaseModel.Width, c.BaseModel.Height-6, lipgloss.Center, lipgloss.Center, msg)
+		return header + centerContent
+	}
+
+	listView := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(purple).
+		Padding(0, 1).
+		Render(c.orders.View())
+
+	header = lipgloss.PlaceHorizontal(c.BaseModel.Width, lipgloss.Center, header)
+
+	centeredList := lipgloss.Place(
+		c.BaseModel.Width,
+		c.BaseModel.Height-6,
+		lipgloss.Center,
+		lipgloss.Center,
+		listView,
+	)
+
+	return header + centeredList
+}
+
+func (c *ConditionalOrdersPage) Reload() {
+	c.loaded = false
+	c.err = nil
+	c.orders.SetItems([]list.Item{})
+	c.Reloaded = true
+}
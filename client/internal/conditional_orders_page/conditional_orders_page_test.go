@@ -0,0 +1,95 @@
+package conditionalorderspage
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestPage() ConditionalOrdersPage {
+	p := New(&http.Client{}, &basemodel.TokenStore{})
+	p.BaseModel.Width = 120
+	p.BaseModel.Height = 40
+	return p
+}
+
+func TestConditionalOrder_Title(t *testing.T) {
+	order := ConditionalOrder{
+		Symbol:    "AAPL",
+		Condition: "price_crosses_above",
+		Threshold: 200,
+		Order:     map[string]any{"side": "buy", "qty": "10", "symbol": "AAPL"},
+	}
+
+	if title := order.Title(); title != "buy 10 AAPL if AAPL crosses above $200.00" {
+		t.Fatalf("unexpected title %q", title)
+	}
+
+	order.Condition = "rsi_below"
+	order.Threshold = 30
+	order.Order["side"] = "sell"
+	if title := order.Title(); !strings.Contains(title, "RSI of AAPL < 30") {
+		t.Fatalf("unexpected title %q", title)
+	}
+}
+
+func TestUpdate_Loaded(t *testing.T) {
+	p := newTestPage()
+
+	model, _ := p.Update(ConditionalOrdersLoadedMsg{orders: []ConditionalOrder{
+		{ID: "1", Symbol: "AAPL", Status: "pending", Order: map[string]any{}},
+		{ID: "2", Symbol: "MSFT", Status: "triggered", Order: map[string]any{}},
+	}})
+	p = model.(ConditionalOrdersPage)
+
+	if !p.loaded {
+		t.Fatal("expected page to be loaded")
+	}
+
+	if len(p.orders.Items()) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(p.orders.Items()))
+	}
+}
+
+func TestUpdate_Canceled(t *testing.T) {
+	p := newTestPage()
+
+	model, _ := p.Update(ConditionalOrdersLoadedMsg{orders: []ConditionalOrder{
+		{ID: "1", Symbol: "AAPL", Status: "pending", Order: map[string]any{}},
+	}})
+	p = model.(ConditionalOrdersPage)
+
+	model, _ = p.Update(ConditionalOrderCanceledMsg{id: "1"})
+	p = model.(ConditionalOrdersPage)
+
+	if p.orders.Items()[0].(ConditionalOrder).Status != "canceled" {
+		t.Fatal("expected the order to be canceled")
+	}
+
+	_, cmd := p.Update(ConditionalOrderCanceledMsg{id: "1", err: errors.New("boom")})
+	if cmd == nil {
+		t.Fatal("expected a command for the error")
+	}
+
+	if msg, ok := cmd().(messages.PageSwitchMsg); !ok || msg.Page != messages.ErrorPageNumber {
+		t.Fatal("expected to switch to the error page")
+	}
+}
+
+func TestUpdate_Escape(t *testing.T) {
+	p := newTestPage()
+
+	_, cmd := p.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+
+	if msg, ok := cmd().(messages.SmartPageSwitchMsg); !ok || msg.Page != messages.ProfilePageNumber {
+		t.Fatal("expected to go back to the profile page")
+	}
+}
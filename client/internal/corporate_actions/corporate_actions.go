@@ -0,0 +1,130 @@
+package corporateactions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Announcement struct {
+	ID               string     `json:"id"`
+	Type             string     `json:"ca_type"`
+	SubType          string     `json:"ca_sub_type"`
+	InitiatingSymbol string     `json:"initiating_symbol"`
+	TargetSymbol     string     `json:"target_symbol"`
+	ExDate           *time.Time `json:"ex_date"`
+	RecordDate       *time.Time `json:"record_date"`
+	PayableDate      *time.Time `json:"payable_date"`
+	Cash             float64    `json:"cash"`
+	OldRate          float64    `json:"old_rate"`
+	NewRate          float64    `json:"new_rate"`
+}
+
+// NoticesMsg is sent back to the page that asked for the corporate actions of the symbol
+type NoticesMsg struct {
+	Symbol  string
+	Notices []string
+	Err     error
+}
+
+var noticeStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#FFD700")).
+	Bold(true)
+
+// Date is the day the action changes the positions: the ex-date, or the closest date to it that was announced
+func (a Announcement) Date() *time.Time {
+	for _, date := range []*time.Time{a.ExDate, a.RecordDate, a.PayableDate} {
+		if date != nil {
+			return date
+		}
+	}
+
+	return nil
+}
+
+// Notice describes the action and when it happens
+func (a Announcement) Notice(now time.Time) string {
+	var text string
+	switch a.Type {
+	case "dividend":
+		if a.SubType == "stock" {
+			text = fmt.Sprintf("%s pays a stock dividend", a.InitiatingSymbol)
+		} else {
+			text = fmt.Sprintf("%s pays a $%.4g dividend per share", a.InitiatingSymbol, a.Cash)
+		}
+	case "split":
+		text = fmt.Sprintf("%s has a %g-for-%g split", a.InitiatingSymbol, a.NewRate, a.OldRate)
+	case "merger":
+		text = fmt.Sprintf("%s merges into %s", a.TargetSymbol, a.InitiatingSymbol)
+	case "spinoff":
+		text = fmt.Sprintf("%s spins off %s", a.InitiatingSymbol, a.TargetSymbol)
+	default:
+		text = fmt.Sprintf("%s has a corporate action (%s)", a.InitiatingSymbol, a.Type)
+	}
+
+	date := a.Date()
+	if date == nil {
+		return text
+	}
+
+	days := int(date.Sub(now.UTC().Truncate(24*time.Hour)).Hours() / 24)
+	switch {
+	case days == 0:
+		return text + ", ex-date today"
+	case days == 1:
+		return text + ", ex-date tomorrow"
+	default:
+		return fmt.Sprintf("%s, ex-date %s (in %d days)", text, date.Format("2006-01-02"), days)
+	}
+}
+
+// Upcoming returns the notices of the actions that haven't reached their ex-date yet, the closest first
+func Upcoming(announcements []Announcement, now time.Time) []string {
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	var notices []string
+	for _, a := range announcements {
+		if date := a.Date(); date != nil && !date.Before(today) {
+			notices = append(notices, a.Notice(now))
+		}
+	}
+
+	return notices
+}
+
+// Fetch gets the notices of the upcoming corporate actions of the symbol
+func Fetch(symbol string, client *http.Client, tokenStore *basemodel.TokenStore) tea.Cmd {
+	return func() tea.Msg {
+		body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/corporate-actions?symbol="+url.QueryEscape(symbol), nil, client, tokenStore)
+		if err != nil {
+			return NoticesMsg{Symbol: symbol, Err: err}
+		}
+
+		var response struct {
+			CorporateActions []Announcement `json:"corporate_actions"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return NoticesMsg{Symbol: symbol, Err: err}
+		}
+
+		return NoticesMsg{Symbol: symbol, Notices: Upcoming(response.CorporateActions, time.Now())}
+	}
+}
+
+// Render shows the notices one under the other
+func Render(notices []string) string {
+	lines := make([]string, len(notices))
+	for i, notice := range notices {
+		lines[i] = noticeStyle.Render("⚠ " + notice)
+	}
+
+	return strings.Join(lines, "\n")
+}
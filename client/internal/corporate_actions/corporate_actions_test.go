@@ -0,0 +1,47 @@
+package corporateactions
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+func TestNotice(t *testing.T) {
+	now := time.Date(2025, time.May, 9, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		a        Announcement
+		expected string
+	}{
+		{Announcement{Type: "dividend", SubType: "cash", InitiatingSymbol: "AAPL", Cash: 0.26, ExDate: date(2025, time.May, 12)},
+			"AAPL pays a $0.26 dividend per share, ex-date 2025-05-12 (in 3 days)"},
+		{Announcement{Type: "split", InitiatingSymbol: "NVDA", OldRate: 1, NewRate: 10, ExDate: date(2025, time.May, 10)},
+			"NVDA has a 10-for-1 split, ex-date tomorrow"},
+		{Announcement{Type: "merger", InitiatingSymbol: "NEW", TargetSymbol: "OLD", PayableDate: date(2025, time.May, 9)},
+			"OLD merges into NEW, ex-date today"},
+	}
+
+	for _, tt := range tests {
+		if notice := tt.a.Notice(now); notice != tt.expected {
+			t.Fatalf("expected %q, got %q", tt.expected, notice)
+		}
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	now := time.Date(2025, time.May, 9, 15, 0, 0, 0, time.UTC)
+	announcements := []Announcement{
+		{Type: "dividend", InitiatingSymbol: "AAPL", Cash: 0.25, ExDate: date(2025, time.February, 10)},
+		{Type: "dividend", InitiatingSymbol: "AAPL", Cash: 0.26, ExDate: date(2025, time.May, 12)},
+		{Type: "split", InitiatingSymbol: "AAPL", OldRate: 1, NewRate: 4},
+	}
+
+	notices := Upcoming(announcements, now)
+	if len(notices) != 1 {
+		t.Fatalf("expected only the upcoming dividend, got %v", notices)
+	}
+}
@@ -0,0 +1,374 @@
+package cryptowalletpage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The assets that can be deposited to the wallet of the account
+var walletAssets = []string{"BTC", "ETH", "USDC", "USDT"}
+
+type Wallet struct {
+	Chain     string    `json:"chain"`
+	Address   string    `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Transfer struct {
+	ID          string    `json:"id"`
+	TxHash      string    `json:"tx_hash"`
+	Direction   string    `json:"direction"`
+	Status      string    `json:"status"`
+	Amount      string    `json:"amount"`
+	UsdValue    string    `json:"usd_value"`
+	NetworkFee  string    `json:"network_fee"`
+	Chain       string    `json:"chain"`
+	Asset       string    `json:"asset"`
+	FromAddress string    `json:"from_address"`
+	ToAddress   string    `json:"to_address"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (t Transfer) FilterValue() string {
+	return t.Asset
+}
+
+func (t Transfer) Title() string {
+	return fmt.Sprintf("%s %s  %s %s", t.CreatedAt.Format("2006-01-02 15:04"), t.Direction, t.Amount, t.Asset)
+}
+
+func (t Transfer) Description() string {
+	statusSymbol := ""
+	switch t.Status {
+	case "COMPLETE":
+		statusSymbol = "✓"
+	case "PROCESSING":
+		statusSymbol = "⋯"
+	case "FAILED":
+		statusSymbol = "✗"
+	default:
+		statusSymbol = "•"
+	}
+
+	return fmt.Sprintf("%s %s  $%s", statusSymbol, t.Status, t.UsdValue)
+}
+
+type WalletLoadedMsg struct {
+	asset  string
+	wallet *Wallet
+	err    error
+}
+
+type TransfersLoadedMsg struct {
+	transfers []Transfer
+	err       error
+}
+
+type CryptoWalletPage struct {
+	BaseModel basemodel.BaseModel
+	transfers list.Model
+	assetIdx  int
+	wallet    *Wallet
+	walletErr error
+	loaded    bool
+	spinner   spinner.Model
+	err       error
+	Reloaded  bool
+	filtering bool
+	hasFilter bool
+}
+
+func New(client *http.Client, tokenStore *basemodel.TokenStore) CryptoWalletPage {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF"))
+
+	delegate := list.NewDefaultDelegate()
+
+	cyan := lipgloss.Color("#00FFFF")
+	purple := lipgloss.Color("#A020F0")
+
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
+		Foreground(cyan).
+		BorderForeground(purple)
+	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.
+		Foreground(lipgloss.Color("#888888")).
+		BorderForeground(purple)
+
+	l := list.New([]list.Item{}, delegate, 0, 0)
+	l.DisableQuitKeybindings()
+	l.Title = "Crypto Transfers"
+	l.SetFilteringEnabled(true)
+	l.Styles.Title = lipgloss.NewStyle().
+		Foreground(cyan).
+		Bold(true).
+		Padding(0, 1)
+	l.Styles.PaginationStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262"))
+	l.Styles.HelpStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262"))
+
+	l.AdditionalFullHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(key.WithKeys("h", "l"), key.WithHelp("h/l", "change asset")),
+			key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+		}
+	}
+
+	return CryptoWalletPage{
+		BaseModel: basemodel.BaseModel{Client: client, TokenStore: tokenStore},
+		transfers: l,
+		spinner:   s,
+		Reloaded:  true,
+	}
+}
+
+func (w CryptoWalletPage) Init() tea.Cmd {
+	return tea.Batch(
+		w.spinner.Tick,
+		w.loadTransfers,
+		w.loadWallet(walletAssets[w.assetIdx]),
+	)
+}
+
+func (w CryptoWalletPage) loadTransfers() tea.Msg {
+	body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/crypto/wallets/transfers", nil, w.BaseModel.Client, w.BaseModel.TokenStore)
+	if err != nil {
+		return TransfersLoadedMsg{err: err}
+	}
+
+	var transfers []Transfer
+	if err := json.Unmarshal(body, &transfers); err != nil {
+		return TransfersLoadedMsg{err: err}
+	}
+
+	return TransfersLoadedMsg{transfers: transfers}
+}
+
+// loadWallet gets the deposit address of the account for the asset
+func (w CryptoWalletPage) loadWallet(asset string) tea.Cmd {
+	return func() tea.Msg {
+		body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/crypto/wallets?asset="+asset, nil, w.BaseModel.Client, w.BaseModel.TokenStore)
+		if err != nil {
+			return WalletLoadedMsg{asset: asset, err: err}
+		}
+
+		var wallet Wallet
+		if err := json.Unmarshal(body, &wallet); err != nil {
+			return WalletLoadedMsg{asset: asset, err: err}
+		}
+
+		return WalletLoadedMsg{asset: asset, wallet: &wallet}
+	}
+}
+
+func (w CryptoWalletPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case TransfersLoadedMsg:
+		w.loaded = true
+		w.err = msg.err
+		if msg.err == nil {
+			items := make([]list.Item, len(msg.transfers))
+			for i, transfer := range msg.transfers {
+				items[i] = transfer
+			}
+
+			w.transfers.SetItems(items)
+			w.transfers.SetSize(w.BaseModel.Width/2, w.BaseModel.Height/2)
+		}
+
+		return w, nil
+
+	case WalletLoadedMsg:
+		// The user may have moved on to another asset while this one was loading
+		if msg.asset != walletAssets[w.assetIdx] {
+			return w, nil
+		}
+
+		w.wallet = msg.wallet
+		w.walletErr = msg.err
+		return w, nil
+
+	case spinner.TickMsg:
+		if !w.loaded {
+			w.spinner, cmd = w.spinner.Update(msg)
+			return w, cmd
+		}
+		return w, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q":
+			if !w.filtering {
+				return w, func() tea.Msg {
+					return messages.QuitMsg{}
+				}
+			}
+
+		case "h", "left", "l", "right":
+			if !w.filtering {
+				cmd = w.changeAsset(msg.String() == "l" || msg.String() == "right")
+				return w, cmd
+			}
+
+		case "/":
+			w.filtering = true
+
+		case "enter":
+			if w.filtering {
+				w.filtering = false
+				w.hasFilter = true
+			}
+
+		case "esc":
+			if !w.filtering && !w.hasFilter {
+				return w, func() tea.Msg {
+					return messages.SmartPageSwitchMsg{
+						Page: messages.ProfilePageNumber,
+					}
+				}
+			} else if w.filtering {
+				w.filtering = false
+			} else if w.hasFilter {
+				w.hasFilter = false
+			}
+		}
+	}
+
+	if w.loaded && w.err == nil {
+		w.transfers, cmd = w.transfers.Update(msg)
+	}
+
+	return w, cmd
+}
+
+// changeAsset moves to the next (or the previous) asset and loads its deposit address
+func (w *CryptoWalletPage) changeAsset(next bool) tea.Cmd {
+	if next {
+		w.assetIdx = (w.assetIdx + 1) % len(walletAssets)
+	} else {
+		w.assetIdx = (w.assetIdx - 1 + len(walletAssets)) % len(walletAssets)
+	}
+
+	w.wallet = nil
+	w.walletErr = nil
+
+	return w.loadWallet(walletAssets[w.assetIdx])
+}
+
+func (w CryptoWalletPage) renderWallet() string {
+	cyan := lipgloss.Color("#00FFFF")
+	purple := lipgloss.Color("#A020F0")
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#00AAFF")).
+		Bold(true)
+
+	valueStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFFFF"))
+
+	var assets []string
+	for i, asset := range walletAssets {
+		if i == w.assetIdx {
+			assets = append(assets, lipgloss.NewStyle().Foreground(cyan).Bold(true).Render("▸ "+asset))
+		} else {
+			assets = append(assets, lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render("  "+asset))
+		}
+	}
+
+	lines := []string{strings.Join(assets, "  "), ""}
+	switch {
+	case w.walletErr != nil:
+		lines = append(lines, lipgloss.NewStyle().Foreground(lipgloss.Color("#D30000")).Render(fmt.Sprintf("Error loading the wallet: %v", w.walletErr)))
+	case w.wallet == nil:
+		lines = append(lines, valueStyle.Render("Loading the deposit address..."))
+	default:
+		lines = append(lines, labelStyle.Render("Chain:           ")+valueStyle.Render(w.wallet.Chain))
+		lines = append(lines, labelStyle.Render("Deposit Address: ")+valueStyle.Render(w.wallet.Address))
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(purple).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n"))
+}
+
+func (w CryptoWalletPage) View() string {
+	cyan := lipgloss.Color("#00FFFF")
+	purple := lipgloss.Color("#A020F0")
+	red := lipgloss.Color("#D30000")
+	gray := lipgloss.Color("#626262")
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(cyan).
+		Bold(true).
+		Padding(0, 2).
+		MarginBottom(1).
+		Align(lipgloss.Center)
+	header := "\n" + headerStyle.Render("CRYPTO WALLET") + "\n\n"
+	header = lipgloss.PlaceHorizontal(w.BaseModel.Width, lipgloss.Center, header)
+
+	if !w.loaded {
+		return lipgloss.Place(w.BaseModel.Width, w.BaseModel.Height, lipgloss.Center, lipgloss.Center, w.spinner.View())
+	}
+
+	if w.err != nil {
+		errorMsg := lipgloss.NewStyle().
+			Foreground(red).
+			Padding(1, 2).
+			Render(fmt.Sprintf("Error loading the crypto transfers: %v", w.err))
+		help := lipgloss.NewStyle().
+			Foreground(gray).
+			Render("esc: back • q: quit")
+		content := lipgloss.JoinVertical(lipgloss.Left, errorMsg, "", help)
+		return header + content
+	}
+
+	var transfersView string
+	if len(w.transfers.Items()) == 0 {
+		transfersView = lipgloss.NewStyle().
+			Padding(1, 1).
+			Render("No crypto transfers found.\nDeposits and withdrawals will appear here.\n\n" +
+				lipgloss.NewStyle().Foreground(gray).Render("h/l: change asset • esc: back • q: quit"))
+	} else {
+		transfersView = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(purple).
+			Padding(0, 1).
+			Render(w.transfers.View())
+	}
+
+	content := lipgloss.JoinVertical(lipgloss.Center, w.renderWallet(), "", transfersView)
+
+	return header + lipgloss.Place(
+		w.BaseModel.Width,
+		w.BaseModel.Height-6,
+		lipgloss.Center,
+		lipgloss.Center,
+		content,
+	)
+}
+
+func (w *CryptoWalletPage) Reload() {
+	w.loaded = false
+	w.err = nil
+	w.wallet = nil
+	w.walletErr = nil
+	w.transfers.SetItems([]list.Item{})
+	w.Reloaded = true
+}
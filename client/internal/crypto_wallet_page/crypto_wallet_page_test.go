@@ -0,0 +1,67 @@
+package cryptowalletpage
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestPage() CryptoWalletPage {
+	w := New(&http.Client{}, &basemodel.TokenStore{})
+	w.BaseModel.Width = 120
+	w.BaseModel.Height = 40
+	return w
+}
+
+func TestUpdate_ShowsTheWalletAndTheTransfers(t *testing.T) {
+	w := newTestPage()
+
+	model, _ := w.Update(TransfersLoadedMsg{transfers: []Transfer{{ID: "1", Direction: "INCOMING", Amount: "0.5", Asset: "BTC", Status: "COMPLETE"}}})
+	w = model.(CryptoWalletPage)
+
+	model, _ = w.Update(WalletLoadedMsg{asset: "BTC", wallet: &Wallet{Chain: "BTC", Address: "bc1qexample"}})
+	w = model.(CryptoWalletPage)
+
+	view := w.View()
+	if !strings.Contains(view, "bc1qexample") || !strings.Contains(view, "INCOMING") {
+		t.Fatal("expected the deposit address and the transfer to be shown")
+	}
+}
+
+func TestUpdate_ChangingTheAssetIgnoresTheOldWallet(t *testing.T) {
+	w := newTestPage()
+
+	model, cmd := w.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	w = model.(CryptoWalletPage)
+	if cmd == nil || walletAssets[w.assetIdx] != "ETH" {
+		t.Fatal("expected the wallet of the next asset to be loaded")
+	}
+
+	model, _ = w.Update(WalletLoadedMsg{asset: "BTC", wallet: &Wallet{Address: "bc1qexample"}})
+	if model.(CryptoWalletPage).wallet != nil {
+		t.Fatal("expected the wallet of the previous asset to be ignored")
+	}
+
+	model, _ = w.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+	if walletAssets[model.(CryptoWalletPage).assetIdx] != "BTC" {
+		t.Fatal("expected to go back to the first asset")
+	}
+}
+
+func TestUpdate_EscGoesBackToTheProfile(t *testing.T) {
+	w := newTestPage()
+
+	_, cmd := w.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+
+	msg, ok := cmd().(messages.SmartPageSwitchMsg)
+	if !ok || msg.Page != messages.ProfilePageNumber {
+		t.Fatalf("expected a switch to the profile page, got %v", msg)
+	}
+}
@@ -0,0 +1,146 @@
+package estimation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Fees struct {
+	Commission float64 `json:"commission"`
+	Regulatory float64 `json:"regulatory"`
+	Total      float64 `json:"total"`
+}
+
+type Estimate struct {
+	Symbol               string    `json:"symbol"`
+	Side                 string    `json:"side"`
+	Qty                  float64   `json:"qty"`
+	Bid                  float64   `json:"bid"`
+	Ask                  float64   `json:"ask"`
+	Price                float64   `json:"estimated_price"`
+	Value                float64   `json:"value"`
+	Fees                 Fees      `json:"fees"`
+	Total                float64   `json:"total"`
+	BuyingPower          float64   `json:"buying_power"`
+	PostTradeBuyingPower float64   `json:"post_trade_buying_power"`
+	Cash                 float64   `json:"cash"`
+	Position             float64   `json:"position_qty"`
+	PostTradePosition    float64   `json:"post_trade_position_qty"`
+	MarketOpen           bool      `json:"market_open"`
+	Warnings             []Warning `json:"warnings"`
+}
+
+// EstimateMsg is sent back to the page that asked for the estimate
+type EstimateMsg struct {
+	Estimate *Estimate
+	Err      error
+}
+
+var (
+	boxStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#BB88FF")).
+			Padding(1, 2)
+
+	labelStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#BB88FF")).
+			Width(24)
+
+	valueStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#00FFFF")).
+			Bold(true)
+
+	totalStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFFFF")).
+			Bold(true)
+
+	warningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFF00"))
+)
+
+// Fetch asks the server how the order would affect the account before it's placed
+func Fetch(order map[string]any, client *http.Client, tokenStore *basemodel.TokenStore) tea.Cmd {
+	return func() tea.Msg {
+		jsonData, err := json.Marshal(order)
+		if err != nil {
+			return EstimateMsg{Err: err}
+		}
+
+		body, err := requests.MakeRequest(http.MethodPost, requests.BaseURL+"/trading/orders/estimation", bytes.NewReader(jsonData), client, tokenStore)
+		if err != nil {
+			return EstimateMsg{Err: err}
+		}
+
+		estimate := Estimate{}
+		if err := json.Unmarshal(body, &estimate); err != nil {
+			return EstimateMsg{Err: err}
+		}
+
+		return EstimateMsg{Estimate: &estimate}
+	}
+}
+
+func money(value float64) string {
+	if value < 0 {
+		return fmt.Sprintf("-$%.2f", -value)
+	}
+
+	return fmt.Sprintf("$%.2f", value)
+}
+
+func shares(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func row(label, value string) string {
+	return labelStyle.Render(label+":") + " " + valueStyle.Render(value)
+}
+
+// Render shows the estimate as the confirmation step of an order
+func Render(e Estimate) string {
+	total := "Total cost"
+	if e.Side == "sell" {
+		total = "Proceeds"
+	}
+
+	rows := []string{
+		row("Bid / Ask", money(e.Bid)+" / "+money(e.Ask)),
+		row("Estimated price", money(e.Price)),
+		row("Quantity", shares(e.Qty)),
+		row("Order value", money(e.Value)),
+		row("Commission", money(e.Fees.Commission)),
+		row("Regulatory fees", money(e.Fees.Regulatory)),
+		labelStyle.Render(total+":") + " " + totalStyle.Render(money(e.Total)),
+		"",
+		row("Buying power", money(e.BuyingPower)+" → "+money(e.PostTradeBuyingPower)),
+		row("Position", shares(e.Position)+" → "+shares(e.PostTradePosition)),
+	}
+
+	if len(e.Warnings) > 0 {
+		rows = append(rows, "")
+		for _, warning := range e.Warnings {
+			rows = append(rows, warningStyle.Render("⚠ "+warning.Message))
+		}
+	}
+
+	title := strings.ToUpper(e.Side) + " " + shares(e.Qty) + " " + e.Symbol
+	return lipgloss.JoinVertical(lipgloss.Center,
+		valueStyle.Render(title),
+		"",
+		boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)),
+	)
+}
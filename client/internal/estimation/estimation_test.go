@@ -0,0 +1,41 @@
+package estimation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMoney(t *testing.T) {
+	if money(12.5) != "$12.50" || money(-3) != "-$3.00" {
+		t.Fatalf("unexpected formatting %s %s", money(12.5), money(-3))
+	}
+}
+
+func TestRender(t *testing.T) {
+	e := Estimate{
+		Symbol:               "AAPL",
+		Side:                 "sell",
+		Qty:                  2.5,
+		Price:                100,
+		Value:                250,
+		Total:                249.99,
+		BuyingPower:          1000,
+		PostTradeBuyingPower: 1249.99,
+		Position:             5,
+		PostTradePosition:    2.5,
+		Warnings:             []Warning{{Code: "market_closed", Message: "the market is closed"}},
+	}
+
+	view := Render(e)
+
+	for _, want := range []string{"SELL 2.5 AAPL", "Proceeds", "$249.99", "$1249.99", "the market is closed"} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("expected %q in the view:\n%s", want, view)
+		}
+	}
+
+	e.Side = "buy"
+	if !strings.Contains(Render(e), "Total cost") {
+		t.Fatal("expected the total cost for a buy")
+	}
+}
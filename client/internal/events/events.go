@@ -0,0 +1,164 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gorilla/websocket"
+)
+
+type frame struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+const (
+	minBackoff = 2 * time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// Returned when the server rejects the token, reconnecting with the same one won't help
+var ErrUnauthorized = errors.New("the events stream rejected the token")
+
+// Listener holds the single /events websocket that is opened for the whole session
+type Listener struct {
+	TokenStore *basemodel.TokenStore
+
+	// Listen runs in a command goroutine while Close is called from the update loop
+	mu       sync.Mutex
+	ws       *websocket.Conn
+	failures int
+}
+
+func NewListener(tokenStore *basemodel.TokenStore) *Listener {
+	return &Listener{TokenStore: tokenStore}
+}
+
+func (l *Listener) connect() error {
+	host, _ := strings.CutPrefix(requests.BaseURL, "http://")
+	url := fmt.Sprintf("ws://%s/events", host)
+
+	header := http.Header{}
+	header.Add("Authorization", "Bearer "+l.TokenStore.Token)
+
+	ws, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return ErrUnauthorized
+		}
+
+		return fmt.Errorf("failed to connect to the events stream: %w", err)
+	}
+
+	l.mu.Lock()
+	l.ws = ws
+	l.mu.Unlock()
+
+	return nil
+}
+
+func (l *Listener) conn() *websocket.Conn {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.ws
+}
+
+// Listen waits for the next account event. The connection is opened lazily, so
+// after an error the same command can be used again to reconnect.
+func (l *Listener) Listen() tea.Cmd {
+	return func() tea.Msg {
+		ws := l.conn()
+		if ws == nil {
+			if err := l.connect(); err != nil {
+				l.fail()
+				return messages.AccountEventMsg{Err: err}
+			}
+
+			ws = l.conn()
+		}
+
+		var f frame
+		if err := ws.ReadJSON(&f); err != nil {
+			l.fail()
+			l.Close()
+			return messages.AccountEventMsg{Err: fmt.Errorf("events connection lost: %w", err)}
+		}
+
+		l.mu.Lock()
+		l.failures = 0
+		l.mu.Unlock()
+
+		return Decode(f.Stream, f.Data)
+	}
+}
+
+func (l *Listener) fail() {
+	l.mu.Lock()
+	l.failures++
+	l.mu.Unlock()
+}
+
+// Backoff returns how long to wait before reconnecting. It doubles with every failure in a row.
+func (l *Listener) Backoff() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return backoff(l.failures)
+}
+
+func backoff(failures int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxBackoff)
+}
+
+func Decode(stream string, data []byte) messages.AccountEventMsg {
+	msg := messages.AccountEventMsg{Stream: stream}
+
+	var err error
+	switch stream {
+	case "trades":
+		msg.Trade = &messages.TradeEvent{}
+		err = json.Unmarshal(data, msg.Trade)
+	case "transfers":
+		msg.Transfer = &messages.TransferEvent{}
+		err = json.Unmarshal(data, msg.Transfer)
+	case "accounts":
+		msg.Account = &messages.AccountStatusEvent{}
+		err = json.Unmarshal(data, msg.Account)
+	case "options":
+		msg.Option = &messages.OptionEvent{}
+		err = json.Unmarshal(data, msg.Option)
+	}
+
+	if err != nil {
+		return messages.AccountEventMsg{Stream: stream, Err: err}
+	}
+
+	return msg
+}
+
+func (l *Listener) Close() {
+	l.mu.Lock()
+	ws := l.ws
+	l.ws = nil
+	l.mu.Unlock()
+
+	if ws != nil {
+		ws.WriteMessage(websocket.TextMessage, []byte("exit"))
+		ws.Close()
+	}
+}
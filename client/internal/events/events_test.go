@@ -0,0 +1,103 @@
+package events
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	"github.com/gorilla/websocket"
+)
+
+func TestDecode(t *testing.T) {
+	msg := Decode("trades", []byte(`{"event":"fill","order":{"id":"1","symbol":"AAPL"},"position_qty":"3"}`))
+	if msg.Err != nil {
+		t.Fatal(msg.Err)
+	}
+
+	if msg.Trade == nil || msg.Trade.Order.Symbol != "AAPL" || msg.Trade.PositionQty != "3" {
+		t.Fatalf("unexpected trade event: %+v", msg.Trade)
+	}
+
+	msg = Decode("transfers", []byte(`{"transfer_id":"t1","status_to":"COMPLETE"}`))
+	if msg.Transfer == nil || msg.Transfer.StatusTo != "COMPLETE" {
+		t.Fatalf("unexpected transfer event: %+v", msg.Transfer)
+	}
+
+	msg = Decode("options", []byte(`{"entry_type":"OPASN","symbol":"AAPL250620C00192500","qty":"1"}`))
+	if msg.Option == nil || msg.Option.EntryType != "OPASN" || msg.Option.Symbol != "AAPL250620C00192500" {
+		t.Fatalf("unexpected options event: %+v", msg.Option)
+	}
+
+	msg = Decode("trades", []byte(`not json`))
+	if msg.Err == nil {
+		t.Fatal("expected an error for invalid json")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  minBackoff,
+		1:  minBackoff,
+		2:  2 * minBackoff,
+		4:  8 * minBackoff,
+		20: maxBackoff,
+	}
+
+	for failures, want := range cases {
+		if got := backoff(failures); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", failures, got, want)
+		}
+	}
+}
+
+func TestListenUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	baseURL := requests.BaseURL
+	requests.BaseURL = server.URL
+	defer func() { requests.BaseURL = baseURL }()
+
+	msg := NewListener(&basemodel.TokenStore{Token: "expired"}).Listen()().(messages.AccountEventMsg)
+	if !errors.Is(msg.Err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", msg.Err)
+	}
+}
+
+func TestCloseWhileListening(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+
+		ws.ReadMessage()
+	}))
+	defer server.Close()
+
+	baseURL := requests.BaseURL
+	requests.BaseURL = server.URL
+	defer func() { requests.BaseURL = baseURL }()
+
+	listener := NewListener(&basemodel.TokenStore{Token: "token"})
+	done := make(chan messages.AccountEventMsg)
+	go func() { done <- listener.Listen()().(messages.AccountEventMsg) }()
+
+	for listener.conn() == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	listener.Close()
+	if msg := <-done; msg.Err == nil {
+		t.Fatal("expected the read to fail after closing")
+	}
+}
@@ -0,0 +1,371 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	kinds   = []string{"bars", "trades", "quotes"}
+	formats = []string{"csv", "parquet", "ndjson"}
+)
+
+// Options are the flags of kaytrade export
+type Options struct {
+	Symbols   []string
+	Kind      string
+	Start     string
+	End       string
+	TimeFrame string
+	Session   string
+	Format    string
+	Output    string
+}
+
+func ParseArgs(args []string) (Options, error) {
+	var opts Options
+	var symbols string
+
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.StringVar(&symbols, "symbols", "", "comma separated symbols to export, like AAPL,MSFT or BTC/USD")
+	flags.StringVar(&opts.Kind, "kind", "bars", "what to export: bars, trades or quotes")
+	flags.StringVar(&opts.Start, "start", "", "the start of the export as a date or RFC3339 time (default today)")
+	flags.StringVar(&opts.End, "end", "", "the end of the export as a date or RFC3339 time (default now)")
+	flags.StringVar(&opts.TimeFrame, "timeframe", "1D", "the timeframe of the bars, like 1T, 15T, 4H or 1D")
+	flags.StringVar(&opts.Session, "session", "", "build the bars of stocks from the core or extended session")
+	flags.StringVar(&opts.Format, "format", "csv", "the format of the file: csv, parquet or ndjson")
+	flags.StringVar(&opts.Output, "output", "", "the file to write to (default the name the server gives)")
+
+	if err := flags.Parse(args); err != nil {
+		return Options{}, err
+	}
+
+	for _, symbol := range strings.Split(symbols, ",") {
+		if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+			opts.Symbols = append(opts.Symbols, symbol)
+		}
+	}
+
+	if len(opts.Symbols) == 0 {
+		return Options{}, errors.New("Error at least one symbol is required")
+	}
+
+	if !slices.Contains(kinds, opts.Kind) {
+		return Options{}, errors.New("Error the kind should be bars, trades or quotes")
+	}
+
+	if !slices.Contains(formats, opts.Format) {
+		return Options{}, errors.New("Error the format should be csv, parquet or ndjson")
+	}
+
+	if opts.Session != "" && opts.Session != "core" && opts.Session != "extended" {
+		return Options{}, errors.New("Error the session should be core or extended")
+	}
+
+	return opts, nil
+}
+
+func (o Options) URL() string {
+	query := url.Values{}
+	query.Set("symbols", strings.Join(o.Symbols, ","))
+	query.Set("kind", o.Kind)
+	query.Set("format", o.Format)
+
+	if o.Kind == "bars" {
+		query.Set("timeframe", o.TimeFrame)
+
+		if o.Session != "" {
+			query.Set("session", o.Session)
+		}
+	}
+
+	if o.Start != "" {
+		query.Set("start", o.Start)
+	}
+
+	if o.End != "" {
+		query.Set("end", o.End)
+	}
+
+	return requests.BaseURL + "/data/export?" + query.Encode()
+}
+
+// span is the time the export covers, with the same defaults the server uses
+func (o Options) span(now time.Time) (time.Time, time.Time) {
+	start, end := now.UTC().Truncate(24*time.Hour), now.UTC()
+
+	if t, err := parseTime(o.Start); err == nil {
+		start = t
+	}
+
+	if t, err := parseTime(o.End); err == nil {
+		end = t
+	}
+
+	return start, end
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, value)
+}
+
+type progressMsg struct {
+	bytes   int64
+	percent float64
+}
+
+type doneMsg struct {
+	err error
+}
+
+// tracker follows the rows as they're written to work out how far the export is. The server sends the symbols one
+// after another and the rows of each one oldest first, so the progress is the share of the symbols that are done
+// plus how far into the time of the current one the last row is. The rows of parquet files can't be read while
+// they're downloading, so only their size is shown.
+type tracker struct {
+	format     string
+	symbols    int
+	start, end time.Time
+	send       func(tea.Msg)
+
+	seen    map[string]bool
+	partial []byte
+	bytes   int64
+	percent float64
+	err     error
+}
+
+func newTracker(opts Options, now time.Time, send func(tea.Msg)) *tracker {
+	start, end := opts.span(now)
+
+	return &tracker{
+		format:  opts.Format,
+		symbols: len(opts.Symbols),
+		start:   start,
+		end:     end,
+		send:    send,
+		seen:    make(map[string]bool),
+	}
+}
+
+func (t *tracker) Write(p []byte) (int, error) {
+	t.bytes += int64(len(p))
+
+	if t.format != "parquet" {
+		t.partial = append(t.partial, p...)
+
+		for {
+			i := bytes.IndexByte(t.partial, '\n')
+			if i < 0 {
+				break
+			}
+
+			t.line(t.partial[:i])
+			t.partial = t.partial[i+1:]
+		}
+	}
+
+	t.send(progressMsg{bytes: t.bytes, percent: t.percent})
+	return len(p), nil
+}
+
+// line reads the symbol and the time of a row. The server reports errors after the first page as a line of its
+// own in NDJSON files.
+func (t *tracker) line(line []byte) {
+	var symbol, timestamp string
+
+	if t.format == "ndjson" {
+		var row struct {
+			Symbol    string `json:"symbol"`
+			Timestamp string `json:"timestamp"`
+			Error     string `json:"error"`
+		}
+
+		if err := json.Unmarshal(line, &row); err != nil {
+			return
+		}
+
+		if row.Error != "" {
+			t.err = errors.New(row.Error)
+			return
+		}
+
+		symbol, timestamp = row.Symbol, row.Timestamp
+	} else {
+		fields := strings.SplitN(string(line), ",", 3)
+		if len(fields) < 2 {
+			return
+		}
+
+		symbol, timestamp = fields[0], fields[1]
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return
+	}
+
+	t.seen[symbol] = true
+
+	done := 1.0
+	if total := t.end.Sub(t.start); total > 0 {
+		done = min(max(float64(at.Sub(t.start))/float64(total), 0), 1)
+	}
+
+	t.percent = max(t.percent, min((float64(len(t.seen)-1)+done)/float64(max(t.symbols, 1)), 1))
+}
+
+type model struct {
+	output  string
+	bar     progress.Model
+	bytes   int64
+	percent float64
+	done    bool
+	err     error
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" || msg.String() == "q" {
+			return m, tea.Quit
+		}
+	case progressMsg:
+		m.bytes = msg.bytes
+		m.percent = msg.percent
+	case doneMsg:
+		m.done = true
+		m.err = msg.err
+		if msg.err == nil {
+			m.percent = 1
+		}
+
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m model) View() string {
+	size := fmt.Sprintf("%.1f MB", float64(m.bytes)/(1<<20))
+	help := lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render("ctrl+c/q: cancel")
+
+	return "\n  Exporting to " + m.output + "\n\n  " + m.bar.ViewAs(m.percent) + "  " + size + "\n\n  " + help + "\n"
+}
+
+// filename is the name of the file the server suggests, without any directories
+func filename(res *http.Response) string {
+	_, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition"))
+	if err != nil || params["filename"] == "" {
+		return ""
+	}
+
+	return filepath.Base(params["filename"])
+}
+
+// Run downloads the export described by the args to disk, showing how far it is with a progress bar. A failed
+// or canceled export doesn't leave a partial file behind.
+func Run(args []string, client *http.Client) error {
+	opts, err := ParseArgs(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.URL(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		var info map[string]string
+		json.NewDecoder(res.Body).Decode(&info)
+		return errors.New(info["error"])
+	}
+
+	output := opts.Output
+	if output == "" {
+		output = filename(res)
+	}
+
+	if output == "" {
+		output = "export." + opts.Format
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+
+	p := tea.NewProgram(model{output: output, bar: progress.New(progress.WithDefaultGradient())})
+	tracker := newTracker(opts, time.Now(), p.Send)
+
+	go func() {
+		_, err := io.Copy(io.MultiWriter(file, tracker), res.Body)
+		if err == nil {
+			err = tracker.err
+		}
+
+		p.Send(doneMsg{err: err})
+	}()
+
+	final, err := p.Run()
+	cancel()
+
+	m, _ := final.(model)
+	if err == nil && !m.done {
+		err = errors.New("Error the export was canceled")
+	} else if err == nil {
+		err = m.err
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(output)
+		return err
+	}
+
+	fmt.Printf("Exported %s (%.1f MB)\n", output, float64(m.bytes)/(1<<20))
+	return nil
+}
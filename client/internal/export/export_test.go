@@ -0,0 +1,70 @@
+package export
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseArgs(t *testing.T) {
+	opts, err := ParseArgs([]string{"-symbols", "aapl, msft", "-kind", "trades", "-format", "parquet", "-start", "2025-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(opts.Symbols) != 2 || opts.Symbols[1] != "MSFT" || opts.Kind != "trades" || opts.Format != "parquet" {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+
+	u, err := url.Parse(opts.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+	if query.Get("symbols") != "AAPL,MSFT" || query.Get("start") != "2025-01-01" || query.Has("timeframe") || query.Has("end") {
+		t.Fatalf("unexpected query %v", query)
+	}
+
+	invalid := [][]string{
+		{},
+		{"-symbols", "AAPL", "-kind", "auctions"},
+		{"-symbols", "AAPL", "-format", "xlsx"},
+		{"-symbols", "AAPL", "-session", "overnight"},
+	}
+
+	for _, args := range invalid {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	opts := Options{Symbols: []string{"AAPL", "MSFT"}, Format: "csv", Start: "2025-01-01", End: "2025-01-11"}
+
+	var last progressMsg
+	tracker := newTracker(opts, time.Now(), func(msg tea.Msg) { last = msg.(progressMsg) })
+
+	// The rows can be split anywhere between writes
+	tracker.Write([]byte("symbol,timestamp,open\nAAPL,2025-01-06T00:00:00Z,1\nMS"))
+	if last.percent != 0.25 {
+		t.Fatalf("expected 0.25, got %v", last.percent)
+	}
+
+	tracker.Write([]byte("FT,2025-01-06T00:00:00Z,2\n"))
+	if last.percent != 0.75 || last.bytes != int64(len("symbol,timestamp,open\nAAPL,2025-01-06T00:00:00Z,1\nMSFT,2025-01-06T00:00:00Z,2\n")) {
+		t.Fatalf("unexpected progress %+v", last)
+	}
+
+	opts.Format = "ndjson"
+	tracker = newTracker(opts, time.Now(), func(tea.Msg) {})
+	tracker.Write([]byte(`{"symbol":"AAPL","timestamp":"2025-01-11T00:00:00Z"}` + "\n" + `{"error":"Error coludn't export all of the market data for these symbols"}` + "\n"))
+
+	if tracker.percent != 0.5 || tracker.err == nil || !strings.Contains(tracker.err.Error(), "coludn't export") {
+		t.Fatalf("unexpected tracker state %v %v", tracker.percent, tracker.err)
+	}
+}
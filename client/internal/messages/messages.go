@@ -1,5 +1,10 @@
 package messages
 
+import (
+	"regexp"
+	"strings"
+)
+
 const (
 	LandingPageNumber = iota
 	WatchlistPageNumber
@@ -18,6 +23,12 @@ const (
 	TransfersPageNumber
 	ViewTransfersPageNumber
 	DocumentsPageNumber
+	ConditionalOrdersPageNumber
+	RecurringPlansPageNumber
+	RebalancePageNumber
+	BasketPageNumber
+	CryptoWalletPageNumber
+	OptionsChainPageNumber
 	ErrorPageNumber
 )
 
@@ -30,6 +41,7 @@ type PageSwitchMsg struct {
 	Order              *Order
 	Position           *Position
 	FundingInformation *FundingInformation
+	Symbols            []string
 }
 
 type LoginSuccessMsg struct {
@@ -58,6 +70,7 @@ type CompanyInfo struct {
 	Description  string  `json:"description"`
 	FoundedYear  int     `json:"founded_year"`
 	Domain       string  `json:"domain"`
+	Class        string  `json:"class,omitempty"`
 }
 
 type Order struct {
@@ -117,3 +130,98 @@ type FundingInformation struct {
 	RelationshipId string
 	BankId         string
 }
+
+type TradeEvent struct {
+	Event       string `json:"event"`
+	Order       Order  `json:"order"`
+	Price       string `json:"price"`
+	Qty         string `json:"qty"`
+	PositionQty string `json:"position_qty"`
+	Timestamp   string `json:"timestamp"`
+}
+
+type TransferEvent struct {
+	TransferID string `json:"transfer_id"`
+	StatusFrom string `json:"status_from"`
+	StatusTo   string `json:"status_to"`
+	At         string `json:"at"`
+}
+
+type AccountStatusEvent struct {
+	StatusFrom string `json:"status_from"`
+	StatusTo   string `json:"status_to"`
+	At         string `json:"at"`
+}
+
+// An expiration (OPEXP), an assignment (OPASN) or an exercise (OPEXC) of an options contract
+type OptionEvent struct {
+	EntryType   string `json:"entry_type"`
+	Symbol      string `json:"symbol"`
+	Qty         string `json:"qty"`
+	Date        string `json:"date"`
+	Description string `json:"description"`
+}
+
+// Sent for every frame received on the /events websocket. Only the field matching Stream is filled.
+type AccountEventMsg struct {
+	Stream   string
+	Trade    *TradeEvent
+	Transfer *TransferEvent
+	Account  *AccountStatusEvent
+	Option   *OptionEvent
+	Err      error
+}
+
+// Sent with the mode of the account. Changed is set when the user just switched between the paper and the live account.
+type PaperModeMsg struct {
+	Enabled bool
+	Changed bool
+	Err     error
+}
+
+// The OCC symbol of an options contract, e.g. AAPL250620C00192500
+var contractPattern = regexp.MustCompile(`^[A-Z]{1,6}\d{6}[CP]\d{8}$`)
+
+// The currencies crypto pairs are quoted in
+var quoteCurrencies = []string{"USDT", "USDC", "USD", "BTC"}
+
+// IsCrypto tells apart the crypto pairs (BTC/USD) from the stocks
+func IsCrypto(symbol string) bool {
+	return strings.Contains(symbol, "/")
+}
+
+// CryptoPair puts the slash back into the symbols of crypto positions (BTCUSD -> BTC/USD)
+func CryptoPair(symbol string) string {
+	symbol = strings.ToUpper(symbol)
+	if IsCrypto(symbol) {
+		return symbol
+	}
+
+	for _, quote := range quoteCurrencies {
+		if base, ok := strings.CutSuffix(symbol, quote); ok && base != "" {
+			return base + "/" + quote
+		}
+	}
+
+	return symbol
+}
+
+// PathSymbol is the symbol as it's put in the path of a url, crypto pairs lose their slash
+func PathSymbol(symbol string) string {
+	return strings.ReplaceAll(symbol, "/", "")
+}
+
+// IsOption tells apart the symbols of options contracts from the ones of stocks and crypto pairs
+func IsOption(symbol string) bool {
+	return contractPattern.MatchString(strings.ToUpper(symbol))
+}
+
+// Underlying returns the symbol of the stock an options contract is for
+func Underlying(symbol string) string {
+	symbol = strings.ToUpper(symbol)
+	if !IsOption(symbol) {
+		return symbol
+	}
+
+	return symbol[:len(symbol)-15]
+}
@@ -8,20 +8,29 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"time"
 
 	bankrelationshipcreationpage "github.com/Phantomvv1/KayTrade/client/internal/bank_relationship_creation_page"
 	bankrelationshippage "github.com/Phantomvv1/KayTrade/client/internal/bank_relationship_page"
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	basketpage "github.com/Phantomvv1/KayTrade/client/internal/basket_page"
 	buypage "github.com/Phantomvv1/KayTrade/client/internal/buy_page"
 	companypage "github.com/Phantomvv1/KayTrade/client/internal/company_page"
+	conditionalorderspage "github.com/Phantomvv1/KayTrade/client/internal/conditional_orders_page"
+	cryptowalletpage "github.com/Phantomvv1/KayTrade/client/internal/crypto_wallet_page"
 	documentspage "github.com/Phantomvv1/KayTrade/client/internal/documents_page"
 	errorpage "github.com/Phantomvv1/KayTrade/client/internal/error_page"
+	"github.com/Phantomvv1/KayTrade/client/internal/events"
 	landingpage "github.com/Phantomvv1/KayTrade/client/internal/landing_page"
 	loginpage "github.com/Phantomvv1/KayTrade/client/internal/login_page"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	optionschainpage "github.com/Phantomvv1/KayTrade/client/internal/options_chain_page"
 	orderpage "github.com/Phantomvv1/KayTrade/client/internal/order_page"
+	"github.com/Phantomvv1/KayTrade/client/internal/paper"
 	positionpage "github.com/Phantomvv1/KayTrade/client/internal/position_page"
 	profilepage "github.com/Phantomvv1/KayTrade/client/internal/profile_page"
+	rebalancepage "github.com/Phantomvv1/KayTrade/client/internal/rebalance_page"
+	recurringplanspage "github.com/Phantomvv1/KayTrade/client/internal/recurring_plans_page"
 	"github.com/Phantomvv1/KayTrade/client/internal/requests"
 	searchpage "github.com/Phantomvv1/KayTrade/client/internal/search_page"
 	sellpage "github.com/Phantomvv1/KayTrade/client/internal/sell_page"
@@ -31,6 +40,7 @@ import (
 	viewtransferspage "github.com/Phantomvv1/KayTrade/client/internal/view_transfers_page"
 	watchlistpage "github.com/Phantomvv1/KayTrade/client/internal/watchlist_page"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 type Model struct {
@@ -52,8 +62,20 @@ type Model struct {
 	transfersPage                transferspage.TransfersPage
 	viewTransfersPage            viewtransferspage.ViewTransfersPage
 	documentsPage                documentspage.DocumentsPage
+	conditionalOrdersPage        conditionalorderspage.ConditionalOrdersPage
+	recurringPlansPage           recurringplanspage.RecurringPlansPage
+	rebalancePage                rebalancepage.RebalancePage
+	basketPage                   basketpage.BasketPage
+	cryptoWalletPage             cryptowalletpage.CryptoWalletPage
+	optionsChainPage             optionschainpage.OptionsChainPage
 	client                       *http.Client
 	tokenStore                   *basemodel.TokenStore
+	events                       *events.Listener
+	subscribed                   bool
+	paperChecked                 bool
+	paper                        bool
+	width                        int
+	height                       int
 	currentPage                  int
 }
 
@@ -79,14 +101,21 @@ func NewModel() Model {
 		sellPage:                     sellpage.NewSellPage(client, tokenStore),
 		signUpPage:                   signuppage.NewSignUpPage(client, tokenStore),
 		orderPage:                    orderpage.NewOrderPage(client),
-		positionPage:                 positionpage.NewPositionPage(client),
+		positionPage:                 positionpage.NewPositionPage(client, tokenStore),
 		bankRelationshipPage:         bankrelationshippage.NewBankRelationshipPage(client, tokenStore),
 		bankRelationshipCreationPage: bankrelationshipcreationpage.NewBankRelationship(client, tokenStore),
 		transfersPage:                transferspage.NewTransfersPage(client, tokenStore),
 		viewTransfersPage:            viewtransferspage.New(client, tokenStore),
 		documentsPage:                documentspage.New(client, tokenStore),
+		conditionalOrdersPage:        conditionalorderspage.New(client, tokenStore),
+		recurringPlansPage:           recurringplanspage.New(client, tokenStore),
+		rebalancePage:                rebalancepage.New(client, tokenStore),
+		basketPage:                   basketpage.New(client, tokenStore),
+		cryptoWalletPage:             cryptowalletpage.New(client, tokenStore),
+		optionsChainPage:             optionschainpage.New(client, tokenStore),
 		client:                       client,
 		tokenStore:                   tokenStore,
+		events:                       events.NewListener(tokenStore),
 		currentPage:                  messages.LandingPageNumber,
 	}
 
@@ -165,8 +194,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		if msg.Symbol != "" {
-			m.buyPage.Symbol = msg.Symbol
-			m.sellPage.Symbol = msg.Symbol
+			m.buyPage.SetSymbol(msg.Symbol)
+			m.sellPage.SetSymbol(msg.Symbol)
+		}
+
+		if msg.Page == messages.OptionsChainPageNumber && msg.Symbol != "" {
+			m.optionsChainPage.SetUnderlying(msg.Symbol)
 		}
 
 		if msg.MaxQuantity != 0 {
@@ -182,24 +215,75 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.transfersPage.FundingInformation = msg.FundingInformation
 		}
 
+		if msg.Symbols != nil {
+			m.basketPage.SetSymbols(msg.Symbols)
+		}
+
+		subscribe := m.subscribeToEvents()
 		model := m.getModelFromPageNumber()
-		return m, model.Init()
+		return m, tea.Batch(model.Init(), subscribe, m.checkPaperMode())
 	case messages.LoginSuccessMsg:
 		m.tokenStore.Token = msg.Token
 		m.currentPage = msg.Page
+		subscribe := m.subscribeToEvents()
 		model := m.getModelFromPageNumber()
-		return m, model.Init()
+		return m, tea.Batch(model.Init(), subscribe, m.checkPaperMode())
 	case messages.ReloadMsg:
 		m.Reload(msg.Page)
 		return m, nil
 	case messages.SmartPageSwitchMsg:
 		m.currentPage = msg.Page
+		subscribe := m.subscribeToEvents()
 		if m.Reloaded(msg.Page) {
-			return m, m.getModelFromPageNumber().Init()
+			return m, tea.Batch(m.getModelFromPageNumber().Init(), subscribe, m.checkPaperMode())
+		}
+
+		return m, tea.Batch(subscribe, m.checkPaperMode())
+	case messages.PaperModeMsg:
+		if msg.Err != nil {
+			log.Println(msg.Err)
+			return m, nil
+		}
+
+		m.paper = msg.Enabled
+		m.profilePage.Paper = msg.Enabled
+		m.setSize(m.width, m.height)
+
+		if !msg.Changed {
+			return m, nil
+		}
+
+		// Everything on the profile belongs to the other account now
+		m.Reload(messages.ProfilePageNumber)
+		if m.currentPage == messages.ProfilePageNumber && m.Reloaded(messages.ProfilePageNumber) {
+			return m, m.profilePage.Init()
 		}
 
 		return m, nil
+	case messages.AccountEventMsg:
+		if msg.Err != nil {
+			log.Println(msg.Err)
+			if errors.Is(msg.Err, events.ErrUnauthorized) {
+				// The token expired, it gets refreshed by the next regular request and the
+				// stream is opened again on the next page switch
+				m.subscribed = false
+				return m, nil
+			}
+
+			return m, tea.Tick(m.events.Backoff(), func(time.Time) tea.Msg {
+				return m.events.Listen()()
+			})
+		}
+
+		m.profilePage.ApplyEvent(msg)
+		m.viewTransfersPage.ApplyEvent(msg)
+
+		return m, m.events.Listen()
 	case messages.QuitMsg:
+		if m.events != nil {
+			m.events.Close()
+		}
+
 		if err := m.saveRefreshToken(); err != nil {
 			log.Println("Unable to save the refresh token")
 			log.Println(err)
@@ -266,6 +350,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case messages.DocumentsPageNumber:
 		page, cmd = m.documentsPage.Update(msg)
 		m.documentsPage = page.(documentspage.DocumentsPage)
+	case messages.ConditionalOrdersPageNumber:
+		page, cmd = m.conditionalOrdersPage.Update(msg)
+		m.conditionalOrdersPage = page.(conditionalorderspage.ConditionalOrdersPage)
+	case messages.RecurringPlansPageNumber:
+		page, cmd = m.recurringPlansPage.Update(msg)
+		m.recurringPlansPage = page.(recurringplanspage.RecurringPlansPage)
+	case messages.RebalancePageNumber:
+		page, cmd = m.rebalancePage.Update(msg)
+		m.rebalancePage = page.(rebalancepage.RebalancePage)
+	case messages.BasketPageNumber:
+		page, cmd = m.basketPage.Update(msg)
+		m.basketPage = page.(basketpage.BasketPage)
+	case messages.CryptoWalletPageNumber:
+		page, cmd = m.cryptoWalletPage.Update(msg)
+		m.cryptoWalletPage = page.(cryptowalletpage.CryptoWalletPage)
+	case messages.OptionsChainPageNumber:
+		page, cmd = m.optionsChainPage.Update(msg)
+		m.optionsChainPage = page.(optionschainpage.OptionsChainPage)
 
 	default:
 		if m.currentPage != messages.ErrorPageNumber {
@@ -280,6 +382,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) View() string {
+	if !m.paper {
+		return m.pageView()
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, paper.Badge(m.width), m.pageView())
+}
+
+func (m Model) pageView() string {
 	switch m.currentPage {
 	case messages.LandingPageNumber:
 		return m.landingPage.View()
@@ -317,6 +427,18 @@ func (m Model) View() string {
 		return m.viewTransfersPage.View()
 	case messages.DocumentsPageNumber:
 		return m.documentsPage.View()
+	case messages.ConditionalOrdersPageNumber:
+		return m.conditionalOrdersPage.View()
+	case messages.RecurringPlansPageNumber:
+		return m.recurringPlansPage.View()
+	case messages.RebalancePageNumber:
+		return m.rebalancePage.View()
+	case messages.BasketPageNumber:
+		return m.basketPage.View()
+	case messages.CryptoWalletPageNumber:
+		return m.cryptoWalletPage.View()
+	case messages.OptionsChainPageNumber:
+		return m.optionsChainPage.View()
 
 	default:
 		return m.errorPage.View()
@@ -324,6 +446,14 @@ func (m Model) View() string {
 }
 
 func (m *Model) setSize(width, height int) {
+	m.width = width
+	m.height = height
+
+	// The paper trading badge takes the first line of the screen
+	if m.paper && height > 0 {
+		height--
+	}
+
 	m.landingPage.BaseModel.Width = width
 	m.landingPage.BaseModel.Height = height
 
@@ -377,6 +507,20 @@ func (m *Model) setSize(width, height int) {
 
 	m.documentsPage.BaseModel.Width = width
 	m.documentsPage.BaseModel.Height = height
+
+	m.conditionalOrdersPage.BaseModel.Width = width
+	m.conditionalOrdersPage.BaseModel.Height = height
+
+	m.recurringPlansPage.BaseModel.Width = width
+	m.recurringPlansPage.BaseModel.Height = height
+	m.rebalancePage.BaseModel.Width = width
+	m.rebalancePage.BaseModel.Height = height
+	m.basketPage.BaseModel.Width = width
+	m.basketPage.BaseModel.Height = height
+	m.cryptoWalletPage.BaseModel.Width = width
+	m.cryptoWalletPage.BaseModel.Height = height
+	m.optionsChainPage.BaseModel.Width = width
+	m.optionsChainPage.BaseModel.Height = height
 }
 
 func (m *Model) getModelFromPageNumber() tea.Model {
@@ -417,11 +561,43 @@ func (m *Model) getModelFromPageNumber() tea.Model {
 		return m.viewTransfersPage
 	case messages.DocumentsPageNumber:
 		return m.documentsPage
+	case messages.ConditionalOrdersPageNumber:
+		return m.conditionalOrdersPage
+	case messages.RecurringPlansPageNumber:
+		return m.recurringPlansPage
+	case messages.RebalancePageNumber:
+		return m.rebalancePage
+	case messages.BasketPageNumber:
+		return m.basketPage
+	case messages.CryptoWalletPageNumber:
+		return m.cryptoWalletPage
+	case messages.OptionsChainPageNumber:
+		return m.optionsChainPage
 	default:
 		return nil
 	}
 }
 
+// Opens the account events stream once per session, as soon as the user is logged in
+func (m *Model) subscribeToEvents() tea.Cmd {
+	if m.subscribed || m.events == nil || m.tokenStore.Token == "" {
+		return nil
+	}
+
+	m.subscribed = true
+	return m.events.Listen()
+}
+
+// Asks once per session if the user trades with the paper account, as soon as the user is logged in
+func (m *Model) checkPaperMode() tea.Cmd {
+	if m.paperChecked || m.tokenStore.Token == "" {
+		return nil
+	}
+
+	m.paperChecked = true
+	return paper.Fetch(m.client, m.tokenStore)
+}
+
 func (m *Model) Reload(page int) {
 	switch page {
 	case messages.LandingPageNumber:
@@ -448,6 +624,18 @@ func (m *Model) Reload(page int) {
 		m.viewTransfersPage.Reload()
 	case messages.DocumentsPageNumber:
 		m.documentsPage.Reload()
+	case messages.ConditionalOrdersPageNumber:
+		m.conditionalOrdersPage.Reload()
+	case messages.RecurringPlansPageNumber:
+		m.recurringPlansPage.Reload()
+	case messages.RebalancePageNumber:
+		m.rebalancePage.Reload()
+	case messages.BasketPageNumber:
+		m.basketPage.Reload()
+	case messages.CryptoWalletPageNumber:
+		m.cryptoWalletPage.Reload()
+	case messages.OptionsChainPageNumber:
+		m.optionsChainPage.Reload()
 	default:
 		return
 	}
@@ -495,6 +683,54 @@ func (m *Model) Reloaded(page int) bool {
 
 		return reloaded
 
+	case messages.ConditionalOrdersPageNumber:
+		reloaded := m.conditionalOrdersPage.Reloaded
+		if reloaded {
+			m.conditionalOrdersPage.Reloaded = false
+		}
+
+		return reloaded
+
+	case messages.RecurringPlansPageNumber:
+		reloaded := m.recurringPlansPage.Reloaded
+		if reloaded {
+			m.recurringPlansPage.Reloaded = false
+		}
+
+		return reloaded
+
+	case messages.RebalancePageNumber:
+		reloaded := m.rebalancePage.Reloaded
+		if reloaded {
+			m.rebalancePage.Reloaded = false
+		}
+
+		return reloaded
+
+	case messages.BasketPageNumber:
+		reloaded := m.basketPage.Reloaded
+		if reloaded {
+			m.basketPage.Reloaded = false
+		}
+
+		return reloaded
+
+	case messages.CryptoWalletPageNumber:
+		reloaded := m.cryptoWalletPage.Reloaded
+		if reloaded {
+			m.cryptoWalletPage.Reloaded = false
+		}
+
+		return reloaded
+
+	case messages.OptionsChainPageNumber:
+		reloaded := m.optionsChainPage.Reloaded
+		if reloaded {
+			m.optionsChainPage.Reloaded = false
+		}
+
+		return reloaded
+
 	case messages.SearchPageNumber:
 		return true
 
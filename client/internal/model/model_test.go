@@ -5,21 +5,28 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"strings"
 	"testing"
 
 	bankrelationshipcreationpage "github.com/Phantomvv1/KayTrade/client/internal/bank_relationship_creation_page"
 	bankrelationshippage "github.com/Phantomvv1/KayTrade/client/internal/bank_relationship_page"
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	basketpage "github.com/Phantomvv1/KayTrade/client/internal/basket_page"
 	buypage "github.com/Phantomvv1/KayTrade/client/internal/buy_page"
 	companypage "github.com/Phantomvv1/KayTrade/client/internal/company_page"
+	conditionalorderspage "github.com/Phantomvv1/KayTrade/client/internal/conditional_orders_page"
+	cryptowalletpage "github.com/Phantomvv1/KayTrade/client/internal/crypto_wallet_page"
 	documentspage "github.com/Phantomvv1/KayTrade/client/internal/documents_page"
 	errorpage "github.com/Phantomvv1/KayTrade/client/internal/error_page"
 	landingpage "github.com/Phantomvv1/KayTrade/client/internal/landing_page"
 	loginpage "github.com/Phantomvv1/KayTrade/client/internal/login_page"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	optionschainpage "github.com/Phantomvv1/KayTrade/client/internal/options_chain_page"
 	orderpage "github.com/Phantomvv1/KayTrade/client/internal/order_page"
 	positionpage "github.com/Phantomvv1/KayTrade/client/internal/position_page"
 	profilepage "github.com/Phantomvv1/KayTrade/client/internal/profile_page"
+	rebalancepage "github.com/Phantomvv1/KayTrade/client/internal/rebalance_page"
+	recurringplanspage "github.com/Phantomvv1/KayTrade/client/internal/recurring_plans_page"
 	"github.com/Phantomvv1/KayTrade/client/internal/requests"
 	searchpage "github.com/Phantomvv1/KayTrade/client/internal/search_page"
 	sellpage "github.com/Phantomvv1/KayTrade/client/internal/sell_page"
@@ -54,12 +61,18 @@ func newTestModel() Model {
 		sellPage:                     sellpage.NewSellPage(client, tokenStore),
 		signUpPage:                   signuppage.NewSignUpPage(client, tokenStore),
 		orderPage:                    orderpage.NewOrderPage(client),
-		positionPage:                 positionpage.NewPositionPage(client),
+		positionPage:                 positionpage.NewPositionPage(client, tokenStore),
 		bankRelationshipPage:         bankrelationshippage.NewBankRelationshipPage(client, tokenStore),
 		bankRelationshipCreationPage: bankrelationshipcreationpage.NewBankRelationship(client, tokenStore),
 		transfersPage:                transferspage.NewTransfersPage(client, tokenStore),
 		viewTransfersPage:            viewtransferspage.New(client, tokenStore),
 		documentsPage:                documentspage.New(client, tokenStore),
+		conditionalOrdersPage:        conditionalorderspage.New(client, tokenStore),
+		recurringPlansPage:           recurringplanspage.New(client, tokenStore),
+		rebalancePage:                rebalancepage.New(client, tokenStore),
+		basketPage:                   basketpage.New(client, tokenStore),
+		cryptoWalletPage:             cryptowalletpage.New(client, tokenStore),
+		optionsChainPage:             optionschainpage.New(client, tokenStore),
 		client:                       client,
 		tokenStore:                   tokenStore,
 		currentPage:                  messages.LandingPageNumber,
@@ -94,6 +107,7 @@ func TestSetSize(t *testing.T) {
 		{"profile", m.profilePage.BaseModel.Width, m.profilePage.BaseModel.Height},
 		{"sell", m.sellPage.BaseModel.Width, m.sellPage.BaseModel.Height},
 		{"documents", m.documentsPage.BaseModel.Width, m.documentsPage.BaseModel.Height},
+		{"conditional orders", m.conditionalOrdersPage.BaseModel.Width, m.conditionalOrdersPage.BaseModel.Height},
 	}
 
 	for _, tt := range tests {
@@ -111,6 +125,12 @@ func TestGetModelFromPageNumber(t *testing.T) {
 		messages.WatchlistPageNumber,
 		messages.LoginPageNumber,
 		messages.DocumentsPageNumber,
+		messages.ConditionalOrdersPageNumber,
+		messages.RecurringPlansPageNumber,
+		messages.RebalancePageNumber,
+		messages.BasketPageNumber,
+		messages.CryptoWalletPageNumber,
+		messages.OptionsChainPageNumber,
 		messages.ErrorPageNumber,
 	}
 
@@ -265,6 +285,7 @@ func TestUpdatePageSwitchMsg(t *testing.T) {
 		Order:              order,
 		Position:           position,
 		FundingInformation: funding,
+		Symbols:            []string{"AAPL", "MSFT"},
 	})
 
 	updated := model.(Model)
@@ -284,6 +305,10 @@ func TestUpdatePageSwitchMsg(t *testing.T) {
 	if updated.sellPage.MaxQuantity != 12 {
 		t.Fatal("max quantity not set")
 	}
+
+	if len(updated.basketPage.Symbols) != 2 {
+		t.Fatal("basket symbols not set")
+	}
 }
 
 func TestUpdateLoginSuccessMsg(t *testing.T) {
@@ -356,3 +381,30 @@ func TestUpdateInvalidPageFallsBackToError(t *testing.T) {
 		t.Fatal("expected error")
 	}
 }
+
+func TestUpdatePaperModeMsg(t *testing.T) {
+	m := newTestModel()
+	m.setSize(100, 50)
+
+	model, _ := m.Update(messages.PaperModeMsg{Enabled: true})
+	updated := model.(Model)
+
+	if updated.documentsPage.BaseModel.Height != 49 || updated.documentsPage.BaseModel.Width != 100 {
+		t.Fatal("expected the pages to make room for the paper trading badge")
+	}
+
+	if !strings.Contains(updated.View(), "PAPER") {
+		t.Fatal("expected the paper trading badge in the view")
+	}
+
+	model, _ = updated.Update(messages.PaperModeMsg{Enabled: false, Changed: true})
+	updated = model.(Model)
+
+	if updated.documentsPage.BaseModel.Height != 50 || strings.Contains(updated.View(), "PAPER TRADING") {
+		t.Fatal("expected the badge to go away after switching to the live account")
+	}
+
+	if !updated.profilePage.Reloaded {
+		t.Fatal("expected the profile to be reloaded after switching accounts")
+	}
+}
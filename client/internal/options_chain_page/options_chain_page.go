@@ -0,0 +1,409 @@
+package optionschainpage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	sideCall = iota
+	sidePut
+)
+
+// The width of every column of the grid
+const cellWidth = 9
+
+type Greeks struct {
+	Delta float64 `json:"delta"`
+	Gamma float64 `json:"gamma"`
+	Theta float64 `json:"theta"`
+	Vega  float64 `json:"vega"`
+	Rho   float64 `json:"rho"`
+}
+
+type Quote struct {
+	Symbol            string   `json:"symbol"`
+	Bid               float64  `json:"bid"`
+	Ask               float64  `json:"ask"`
+	Last              float64  `json:"last"`
+	ImpliedVolatility *float64 `json:"implied_volatility"`
+	Greeks            *Greeks  `json:"greeks"`
+}
+
+type Strike struct {
+	Strike float64 `json:"strike"`
+	Call   *Quote  `json:"call"`
+	Put    *Quote  `json:"put"`
+}
+
+type Chain struct {
+	Underlying  string   `json:"underlying"`
+	Expirations []string `json:"expirations"`
+	Expiration  string   `json:"expiration"`
+	Strikes     []Strike `json:"strikes"`
+}
+
+type ChainLoadedMsg struct {
+	underlying string
+	chain      *Chain
+	err        error
+}
+
+type OptionsChainPage struct {
+	BaseModel  basemodel.BaseModel
+	Underlying string
+	chain      *Chain
+	cursor     int
+	side       int
+	loaded     bool
+	spinner    spinner.Model
+	err        error
+	Reloaded   bool
+}
+
+func New(client *http.Client, tokenStore *basemodel.TokenStore) OptionsChainPage {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF"))
+
+	return OptionsChainPage{
+		BaseModel: basemodel.BaseModel{Client: client, TokenStore: tokenStore},
+		spinner:   s,
+		Reloaded:  true,
+	}
+}
+
+// SetUnderlying changes the stock the chain is shown for. The chain is loaded again only when the stock changes.
+func (o *OptionsChainPage) SetUnderlying(symbol string) {
+	if symbol == o.Underlying {
+		return
+	}
+
+	o.Underlying = symbol
+	o.Reload()
+}
+
+func (o OptionsChainPage) Init() tea.Cmd {
+	return tea.Batch(o.spinner.Tick, o.loadChain(""))
+}
+
+// loadChain gets the strikes of one expiration, the nearest one when it's empty
+func (o OptionsChainPage) loadChain(expiration string) tea.Cmd {
+	underlying := o.Underlying
+	return func() tea.Msg {
+		query := url.Values{}
+		if expiration != "" {
+			query.Set("expiration", expiration)
+		}
+
+		body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/data/options/chain/"+underlying+"?"+query.Encode(), nil, o.BaseModel.Client, o.BaseModel.TokenStore)
+		if err != nil {
+			return ChainLoadedMsg{underlying: underlying, err: err}
+		}
+
+		var chain Chain
+		if err := json.Unmarshal(body, &chain); err != nil {
+			return ChainLoadedMsg{underlying: underlying, err: err}
+		}
+
+		return ChainLoadedMsg{underlying: underlying, chain: &chain}
+	}
+}
+
+func (o OptionsChainPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case ChainLoadedMsg:
+		// The user may have opened the chain of another stock while this one was loading
+		if msg.underlying != o.Underlying {
+			return o, nil
+		}
+
+		o.loaded = true
+		o.err = msg.err
+		if msg.err == nil {
+			o.chain = msg.chain
+			o.cursor = min(o.cursor, max(len(o.chain.Strikes)-1, 0))
+		}
+
+		return o, nil
+
+	case spinner.TickMsg:
+		if !o.loaded {
+			o.spinner, cmd = o.spinner.Update(msg)
+			return o, cmd
+		}
+		return o, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return o, func() tea.Msg {
+				return messages.QuitMsg{}
+			}
+
+		case "esc":
+			return o, func() tea.Msg {
+				return messages.PageSwitchMsg{
+					Page: messages.CompanyPageNumber,
+				}
+			}
+
+		case "r", "R":
+			if o.loaded {
+				o.loaded = false
+				return o, tea.Batch(o.spinner.Tick, o.loadChain(o.expiration()))
+			}
+
+		case "up", "k":
+			if o.cursor > 0 {
+				o.cursor--
+			}
+
+		case "down", "j":
+			if o.chain != nil && o.cursor < len(o.chain.Strikes)-1 {
+				o.cursor++
+			}
+
+		case "tab":
+			o.side = 1 - o.side
+
+		case "h", "left", "l", "right":
+			if o.loaded && o.chain != nil {
+				cmd = o.changeExpiration(msg.String() == "l" || msg.String() == "right")
+				return o, cmd
+			}
+
+		case "b", "B", "enter":
+			if quote := o.selected(); quote != nil {
+				symbol := quote.Symbol
+				return o, func() tea.Msg {
+					return messages.PageSwitchMsg{
+						Page:   messages.BuyPageNumber,
+						Symbol: symbol,
+					}
+				}
+			}
+		}
+	}
+
+	return o, nil
+}
+
+func (o OptionsChainPage) expiration() string {
+	if o.chain == nil {
+		return ""
+	}
+
+	return o.chain.Expiration
+}
+
+func (o OptionsChainPage) expirationIdx() int {
+	for i, expiration := range o.chain.Expirations {
+		if expiration == o.chain.Expiration {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// changeExpiration moves to the next (or the previous) expiration and loads its strikes
+func (o *OptionsChainPage) changeExpiration(next bool) tea.Cmd {
+	expirations := o.chain.Expirations
+	if len(expirations) < 2 {
+		return nil
+	}
+
+	idx := o.expirationIdx()
+
+	if next {
+		idx = (idx + 1) % len(expirations)
+	} else {
+		idx = (idx - 1 + len(expirations)) % len(expirations)
+	}
+
+	o.loaded = false
+	return tea.Batch(o.spinner.Tick, o.loadChain(expirations[idx]))
+}
+
+// selected returns the contract under the cursor, if there is one
+func (o OptionsChainPage) selected() *Quote {
+	if o.chain == nil || o.cursor >= len(o.chain.Strikes) {
+		return nil
+	}
+
+	strike := o.chain.Strikes[o.cursor]
+	if o.side == sideCall {
+		return strike.Call
+	}
+
+	return strike.Put
+}
+
+func renderCell(value string) string {
+	return lipgloss.NewStyle().Width(cellWidth).Align(lipgloss.Right).Render(value)
+}
+
+// renderQuote shows the bid, the ask, the last price, the delta and the implied volatility of one side of a strike
+func renderQuote(quote *Quote) string {
+	if quote == nil {
+		return strings.Repeat(renderCell("-"), 5)
+	}
+
+	delta, iv := "-", "-"
+	if quote.Greeks != nil {
+		delta = fmt.Sprintf("%.2f", quote.Greeks.Delta)
+	}
+	if quote.ImpliedVolatility != nil {
+		iv = fmt.Sprintf("%.1f%%", *quote.ImpliedVolatility*100)
+	}
+
+	return renderCell(fmt.Sprintf("%.2f", quote.Bid)) +
+		renderCell(fmt.Sprintf("%.2f", quote.Ask)) +
+		renderCell(fmt.Sprintf("%.2f", quote.Last)) +
+		renderCell(delta) +
+		renderCell(iv)
+}
+
+func (o OptionsChainPage) renderExpirations() string {
+	cyan := lipgloss.Color("#00FFFF")
+
+	var expirations []string
+	for _, expiration := range o.chain.Expirations {
+		if expiration == o.chain.Expiration {
+			expirations = append(expirations, lipgloss.NewStyle().Foreground(cyan).Bold(true).Render("▸ "+expiration))
+		} else {
+			expirations = append(expirations, lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render("  "+expiration))
+		}
+	}
+
+	// Only the expirations around the selected one fit on the screen
+	start := max(o.expirationIdx()-2, 0)
+	end := min(start+5, len(expirations))
+	return strings.Join(expirations[start:end], "  ")
+}
+
+func (o OptionsChainPage) renderGrid() string {
+	cyan := lipgloss.Color("#00FFFF")
+	purple := lipgloss.Color("#A020F0")
+	gray := lipgloss.Color("#626262")
+
+	columns := []string{"Bid", "Ask", "Last", "Delta", "IV"}
+	var head strings.Builder
+	for _, column := range columns {
+		head.WriteString(renderCell(column))
+	}
+
+	headerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#00AAFF")).Bold(true)
+	header := headerStyle.Render(head.String() + renderCell("Strike") + " " + head.String())
+	sides := headerStyle.Render(lipgloss.NewStyle().Width(cellWidth*5).Align(lipgloss.Center).Render("CALLS") +
+		renderCell("") + " " +
+		lipgloss.NewStyle().Width(cellWidth*5).Align(lipgloss.Center).Render("PUTS"))
+
+	// Only the strikes around the cursor fit on the screen
+	visible := max(o.BaseModel.Height-14, 5)
+	start := max(o.cursor-visible/2, 0)
+	end := min(start+visible, len(o.chain.Strikes))
+	start = max(end-visible, 0)
+
+	rows := []string{sides, header}
+	for i := start; i < end; i++ {
+		strike := o.chain.Strikes[i]
+
+		call := renderQuote(strike.Call)
+		put := renderQuote(strike.Put)
+		price := renderCell(fmt.Sprintf("%.2f", strike.Strike))
+
+		if i == o.cursor {
+			selected := lipgloss.NewStyle().Foreground(cyan).Bold(true).Reverse(true)
+			if o.side == sideCall {
+				call = selected.Render(call)
+			} else {
+				put = selected.Render(put)
+			}
+			price = lipgloss.NewStyle().Foreground(cyan).Bold(true).Render(price)
+		} else {
+			price = lipgloss.NewStyle().Foreground(gray).Render(price)
+		}
+
+		rows = append(rows, call+price+" "+put)
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(purple).
+		Padding(0, 1).
+		Render(strings.Join(rows, "\n"))
+}
+
+func (o OptionsChainPage) View() string {
+	cyan := lipgloss.Color("#00FFFF")
+	red := lipgloss.Color("#D30000")
+	gray := lipgloss.Color("#626262")
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(cyan).
+		Bold(true).
+		Padding(0, 2).
+		MarginBottom(1).
+		Align(lipgloss.Center)
+	header := "\n" + headerStyle.Render("OPTIONS CHAIN - "+o.Underlying) + "\n\n"
+	header = lipgloss.PlaceHorizontal(o.BaseModel.Width, lipgloss.Center, header)
+
+	if !o.loaded {
+		return lipgloss.Place(o.BaseModel.Width, o.BaseModel.Height, lipgloss.Center, lipgloss.Center, o.spinner.View())
+	}
+
+	helpStyle := lipgloss.NewStyle().Foreground(gray)
+
+	if o.err != nil {
+		errorMsg := lipgloss.NewStyle().
+			Foreground(red).
+			Padding(1, 2).
+			Render(fmt.Sprintf("Error loading the options chain: %v", o.err))
+		help := helpStyle.Render("r: retry • esc: back • q: quit")
+		content := lipgloss.JoinVertical(lipgloss.Left, errorMsg, "", help)
+		return header + content
+	}
+
+	var grid string
+	if len(o.chain.Strikes) == 0 {
+		grid = lipgloss.NewStyle().
+			Padding(1, 1).
+			Render("No contracts expire on " + o.chain.Expiration + ".")
+	} else {
+		grid = o.renderGrid()
+	}
+
+	help := helpStyle.Render("h/l: expiration • j/k: strike • tab: calls/puts • b: buy contract • r: refresh • esc: back • q: quit")
+	content := lipgloss.JoinVertical(lipgloss.Center, o.renderExpirations(), "", grid, "", help)
+
+	return header + lipgloss.Place(
+		o.BaseModel.Width,
+		o.BaseModel.Height-6,
+		lipgloss.Center,
+		lipgloss.Center,
+		content,
+	)
+}
+
+func (o *OptionsChainPage) Reload() {
+	o.loaded = false
+	o.err = nil
+	o.chain = nil
+	o.cursor = 0
+	o.side = sideCall
+	o.Reloaded = true
+}
@@ -0,0 +1,125 @@
+package optionschainpage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestPage() OptionsChainPage {
+	o := New(nil, &basemodel.TokenStore{})
+	o.BaseModel.Width = 160
+	o.BaseModel.Height = 40
+	o.SetUnderlying("AAPL")
+	return o
+}
+
+func testChain() *Chain {
+	iv := 0.5
+	return &Chain{
+		Underlying:  "AAPL",
+		Expirations: []string{"2025-06-20", "2025-07-18"},
+		Expiration:  "2025-06-20",
+		Strikes: []Strike{
+			{Strike: 190, Call: &Quote{Symbol: "AAPL250620C00190000", Bid: 5.1, Ask: 5.3, ImpliedVolatility: &iv}, Put: &Quote{Symbol: "AAPL250620P00190000"}},
+			{Strike: 200, Call: &Quote{Symbol: "AAPL250620C00200000", Greeks: &Greeks{Delta: 0.42}}},
+		},
+	}
+}
+
+func key(k string) tea.KeyMsg {
+	switch k {
+	case "tab":
+		return tea.KeyMsg{Type: tea.KeyTab}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	default:
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+	}
+}
+
+func TestUpdate_ShowsTheStrikeGrid(t *testing.T) {
+	o := newTestPage()
+
+	model, _ := o.Update(ChainLoadedMsg{underlying: "AAPL", chain: testChain()})
+	o = model.(OptionsChainPage)
+
+	view := o.View()
+	for _, text := range []string{"CALLS", "PUTS", "190.00", "200.00", "5.30", "0.42", "2025-07-18"} {
+		if !strings.Contains(view, text) {
+			t.Fatalf("expected %q in the view", text)
+		}
+	}
+}
+
+func TestUpdate_IgnoresTheChainOfAnotherStock(t *testing.T) {
+	o := newTestPage()
+
+	model, _ := o.Update(ChainLoadedMsg{underlying: "MSFT", chain: testChain()})
+	if model.(OptionsChainPage).loaded {
+		t.Fatal("expected the chain of the previous stock to be ignored")
+	}
+}
+
+func TestUpdate_BuysTheSelectedContract(t *testing.T) {
+	o := newTestPage()
+	model, _ := o.Update(ChainLoadedMsg{underlying: "AAPL", chain: testChain()})
+	o = model.(OptionsChainPage)
+
+	model, _ = o.Update(key("tab"))
+	o = model.(OptionsChainPage)
+
+	_, cmd := o.Update(key("b"))
+	msg, ok := cmd().(messages.PageSwitchMsg)
+	if !ok || msg.Page != messages.BuyPageNumber || msg.Symbol != "AAPL250620P00190000" {
+		t.Fatalf("expected to buy the put, got %+v", msg)
+	}
+
+	// The second strike doesn't have a put
+	model, _ = o.Update(key("j"))
+	o = model.(OptionsChainPage)
+	if _, cmd := o.Update(key("b")); cmd != nil {
+		t.Fatal("expected nothing to be bought without a contract")
+	}
+}
+
+func TestUpdate_ChangesTheExpiration(t *testing.T) {
+	o := newTestPage()
+	model, _ := o.Update(ChainLoadedMsg{underlying: "AAPL", chain: testChain()})
+	o = model.(OptionsChainPage)
+
+	model, cmd := o.Update(key("l"))
+	if cmd == nil || model.(OptionsChainPage).loaded {
+		t.Fatal("expected the next expiration to be loaded")
+	}
+}
+
+func TestUpdate_ShowsTheError(t *testing.T) {
+	o := newTestPage()
+
+	model, _ := o.Update(ChainLoadedMsg{underlying: "AAPL", err: errors.New("no contracts")})
+	if !strings.Contains(model.(OptionsChainPage).View(), "no contracts") {
+		t.Fatal("expected the error to be shown")
+	}
+}
+
+func TestSetUnderlying_KeepsTheChainOfTheSameStock(t *testing.T) {
+	o := newTestPage()
+	model, _ := o.Update(ChainLoadedMsg{underlying: "AAPL", chain: testChain()})
+	o = model.(OptionsChainPage)
+	o.Reloaded = false
+
+	o.SetUnderlying("AAPL")
+	if o.chain == nil || o.Reloaded {
+		t.Fatal("expected the chain to be kept")
+	}
+
+	o.SetUnderlying("MSFT")
+	if o.chain != nil || !o.Reloaded {
+		t.Fatal("expected the chain to be reloaded for another stock")
+	}
+}
@@ -0,0 +1,76 @@
+package paper
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Account struct {
+	Enabled      bool    `json:"enabled"`
+	StartingCash float64 `json:"starting_cash"`
+	Cash         float64 `json:"cash"`
+	SlippageBps  float64 `json:"slippage_bps"`
+	FillRatio    float64 `json:"fill_ratio"`
+}
+
+var badgeStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#000000")).
+	Background(lipgloss.Color("#FFFF00")).
+	Bold(true).
+	Align(lipgloss.Center)
+
+// Fetch asks the server if the user trades with the paper account
+func Fetch(client *http.Client, tokenStore *basemodel.TokenStore) tea.Cmd {
+	return func() tea.Msg {
+		body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/trading/paper", nil, client, tokenStore)
+		if err != nil {
+			return messages.PaperModeMsg{Err: err}
+		}
+
+		account := Account{}
+		if err := json.Unmarshal(body, &account); err != nil {
+			return messages.PaperModeMsg{Err: err}
+		}
+
+		return messages.PaperModeMsg{Enabled: account.Enabled}
+	}
+}
+
+// Switch moves the user to the paper account or back to the live one
+func Switch(enabled bool, client *http.Client, tokenStore *basemodel.TokenStore) tea.Cmd {
+	return func() tea.Msg {
+		jsonData, err := json.Marshal(map[string]bool{"enabled": enabled})
+		if err != nil {
+			return messages.PaperModeMsg{Err: err}
+		}
+
+		body, err := requests.MakeRequest(http.MethodPut, requests.BaseURL+"/trading/paper", bytes.NewReader(jsonData), client, tokenStore)
+		if err != nil {
+			return messages.PaperModeMsg{Err: err}
+		}
+
+		account := Account{}
+		if err := json.Unmarshal(body, &account); err != nil {
+			return messages.PaperModeMsg{Err: err}
+		}
+
+		return messages.PaperModeMsg{Enabled: account.Enabled, Changed: true}
+	}
+}
+
+// Badge is shown on top of every page while the user trades with the paper account
+func Badge(width int) string {
+	style := badgeStyle
+	if width > 0 {
+		style = style.Width(width)
+	}
+
+	return style.Render("📝 PAPER TRADING • orders don't touch your brokerage account")
+}
@@ -0,0 +1,20 @@
+package paper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestBadge(t *testing.T) {
+	badge := Badge(80)
+
+	if !strings.Contains(badge, "PAPER") {
+		t.Fatal("expected the badge to say PAPER")
+	}
+
+	if lipgloss.Height(badge) != 1 || lipgloss.Width(badge) != 80 {
+		t.Fatalf("expected the badge to take exactly one full line, got %dx%d", lipgloss.Width(badge), lipgloss.Height(badge))
+	}
+}
@@ -2,10 +2,12 @@ package positionpage
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	corporateactions "github.com/Phantomvv1/KayTrade/client/internal/corporate_actions"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -14,6 +16,10 @@ import (
 type PositionPage struct {
 	BaseModel basemodel.BaseModel
 	Position  *messages.Position
+
+	// The upcoming corporate actions of the symbol of the position
+	notices       []string
+	noticesSymbol string
 }
 
 var (
@@ -56,18 +62,32 @@ var (
 			Foreground(lipgloss.Color("#666666"))
 )
 
-func NewPositionPage(client *http.Client) PositionPage {
+func NewPositionPage(client *http.Client, tokenStore *basemodel.TokenStore) PositionPage {
 	return PositionPage{
-		BaseModel: basemodel.BaseModel{Client: client},
+		BaseModel: basemodel.BaseModel{Client: client, TokenStore: tokenStore},
 	}
 }
 
 func (p PositionPage) Init() tea.Cmd {
-	return nil
+	if p.Position == nil || p.Position.AssetClass != "us_equity" {
+		return nil
+	}
+
+	return corporateactions.Fetch(p.Position.Symbol, p.BaseModel.Client, p.BaseModel.TokenStore)
 }
 
 func (p PositionPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case corporateactions.NoticesMsg:
+		if msg.Err != nil {
+			log.Println(msg.Err)
+			return p, nil
+		}
+
+		p.notices = msg.Notices
+		p.noticesSymbol = msg.Symbol
+		return p, nil
+
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c":
@@ -116,11 +136,16 @@ func (p PositionPage) View() string {
 
 	centeredHeader := lipgloss.Place(p.BaseModel.Width, lipgloss.Height(header), lipgloss.Center, lipgloss.Top, header)
 
+	notices := ""
+	if p.noticesSymbol == p.Position.Symbol && len(p.notices) > 0 {
+		notices = corporateactions.Render(p.notices)
+	}
+
 	finalView := lipgloss.JoinVertical(
 		lipgloss.Center,
 		"\n",
 		centeredHeader,
-		"",
+		notices,
 		content,
 	)
 
@@ -0,0 +1,163 @@
+package profilepage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/NimbleMarkets/ntcharts/barchart"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The number of received dividends listed next to the chart
+const recentDividends = 5
+
+type Dividend struct {
+	ID           string    `json:"id"`
+	Symbol       string    `json:"symbol"`
+	ActivityType string    `json:"activity_type"`
+	NetAmount    float64   `json:"net_amount"`
+	Date         time.Time `json:"date"`
+}
+
+type Month struct {
+	Month  string  `json:"month"`
+	Amount float64 `json:"amount"`
+}
+
+type Payment struct {
+	Symbol    string    `json:"symbol"`
+	Date      time.Time `json:"date"`
+	Amount    float64   `json:"amount"`
+	Announced bool      `json:"announced"`
+}
+
+// Dividends is the received dividend income of the last 12 months and the projected one of the next 12
+type Dividends struct {
+	History           []Dividend `json:"history"`
+	Received          []Month    `json:"received"`
+	ReceivedTotal     float64    `json:"received_total"`
+	Projection        []Month    `json:"projection"`
+	ProjectedTotal    float64    `json:"projected_total"`
+	ProjectedPayments []Payment  `json:"projected_payments"`
+}
+
+type dividendsMsg struct {
+	dividends *Dividends
+	err       error
+}
+
+var (
+	receivedBarStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#00FF00"))
+
+	projectedBarStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#BB88FF"))
+
+	axisStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#666666"))
+
+	tabStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#666666")).
+			Padding(0, 2)
+
+	activeTabStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#00FFFF")).
+			Bold(true).
+			Underline(true).
+			Padding(0, 2)
+)
+
+func (p ProfilePage) fetchDividends() tea.Msg {
+	body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/trading/dividends", nil, p.BaseModel.Client, p.BaseModel.TokenStore)
+	if err != nil {
+		return dividendsMsg{err: err}
+	}
+
+	dividends := Dividends{}
+	if err := json.Unmarshal(body, &dividends); err != nil {
+		return dividendsMsg{err: fmt.Errorf("failed to parse dividends: %v", err)}
+	}
+
+	return dividendsMsg{dividends: &dividends}
+}
+
+// The received months are followed by the projected ones, so the chart reads from the past to the future
+func dividendBars(dividends Dividends) []barchart.BarData {
+	bars := make([]barchart.BarData, 0, len(dividends.Received)+len(dividends.Projection))
+	for _, m := range dividends.Received {
+		bars = append(bars, dividendBar(m, receivedBarStyle))
+	}
+
+	for _, m := range dividends.Projection {
+		bars = append(bars, dividendBar(m, projectedBarStyle))
+	}
+
+	return bars
+}
+
+func dividendBar(m Month, style lipgloss.Style) barchart.BarData {
+	label := m.Month
+	if month, err := time.Parse("2006-01", m.Month); err == nil {
+		label = month.Format("Jan")
+	}
+
+	return barchart.BarData{
+		Label:  label,
+		Values: []barchart.BarValue{{Name: m.Month, Value: m.Amount, Style: style}},
+	}
+}
+
+func (p ProfilePage) renderTabs() string {
+	overview, dividends := activeTabStyle, tabStyle
+	if p.showDividends {
+		overview, dividends = tabStyle, activeTabStyle
+	}
+
+	return overview.Render("Overview") + dividends.Render("Dividends") + tabStyle.Render("(tab to switch)")
+}
+
+func (p ProfilePage) renderDividends() string {
+	if p.dividendsErr != nil {
+		return noticeStyle.Render("Couldn't load the dividends: " + p.dividendsErr.Error())
+	}
+
+	if p.dividends == nil {
+		return "Loading dividends..."
+	}
+
+	chart := barchart.New(p.BaseModel.Width/2, p.BaseModel.Height/2,
+		barchart.WithDataSet(dividendBars(*p.dividends)),
+		barchart.WithStyles(axisStyle, axisStyle),
+	)
+	chart.Draw()
+
+	legend := receivedBarStyle.Render("■ received") + "   " + projectedBarStyle.Render("■ projected")
+
+	var summary []string
+	summary = append(summary, sectionTitleStyle.Render("💵 Dividend Income"))
+	summary = append(summary, labelStyle.Render("Last 12 Months:")+"  "+renderGain(p.dividends.ReceivedTotal))
+	summary = append(summary, labelStyle.Render("Next 12 Months:")+"  "+renderGain(p.dividends.ProjectedTotal))
+
+	summary = append(summary, sectionTitleStyle.Render("Recently Received"))
+	if len(p.dividends.History) == 0 {
+		summary = append(summary, valueStyle.Render("No dividends yet"))
+	}
+
+	for i, d := range p.dividends.History {
+		if i == recentDividends {
+			break
+		}
+
+		summary = append(summary, labelStyle.Render(d.Date.Format("2006-01-02")+" "+d.Symbol)+"  "+renderGain(d.NetAmount))
+	}
+
+	return lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		lipgloss.JoinVertical(lipgloss.Center, chart.View(), legend),
+		lipgloss.NewStyle().MarginLeft(2).Render(boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, summary...))),
+	)
+}
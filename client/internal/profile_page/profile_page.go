@@ -9,8 +9,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Phantomvv1/KayTrade/client/internal/assets"
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/paper"
 	"github.com/Phantomvv1/KayTrade/client/internal/requests"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
@@ -19,17 +21,28 @@ import (
 )
 
 type TradingDetails struct {
-	AccountNumber       string `json:"account_number"`
-	AccruedFees         string `json:"accrued_fees"`
-	BuyingPower         string `json:"buying_power"`
-	Cash                string `json:"cash"`
-	CashTransferable    string `json:"cash_transferable"`
-	CashWithdrawable    string `json:"cash_withdrawable"`
-	Currency            string `json:"currency"`
-	Equity              string `json:"equity"`
-	IntradayAdjustments string `json:"intraday_adjustments"`
-	InitialMargin       string `json:"initial_margin"`
-	Status              string `json:"status"`
+	AccountNumber         string `json:"account_number"`
+	AccruedFees           string `json:"accrued_fees"`
+	BuyingPower           string `json:"buying_power"`
+	RegTBuyingPower       string `json:"regt_buying_power"`
+	DaytradingBuyingPower string `json:"daytrading_buying_power"`
+	Cash                  string `json:"cash"`
+	CashTransferable      string `json:"cash_transferable"`
+	CashWithdrawable      string `json:"cash_withdrawable"`
+	Currency              string `json:"currency"`
+	Equity                string `json:"equity"`
+	LongMarketValue       string `json:"long_market_value"`
+	ShortMarketValue      string `json:"short_market_value"`
+	IntradayAdjustments   string `json:"intraday_adjustments"`
+	InitialMargin         string `json:"initial_margin"`
+	MaintenanceMargin     string `json:"maintenance_margin"`
+	Multiplier            string `json:"multiplier"`
+	ShortingEnabled       bool   `json:"shorting_enabled"`
+	PatternDayTrader      bool   `json:"pattern_day_trader"`
+	DaytradeCount         int    `json:"daytrade_count"`
+	MarginExcess          string `json:"margin_excess"`
+	MarginCall            bool   `json:"margin_call"`
+	Status                string `json:"status"`
 }
 
 type Contact struct {
@@ -73,15 +86,29 @@ type AlpacaAccount struct {
 	TrustedContact TrustedContact `json:"trusted_contact"`
 }
 
+// RealizedPnL is the realized profit and loss of the current year
+type RealizedPnL struct {
+	Method    string  `json:"method"`
+	ShortTerm float64 `json:"short_term"`
+	LongTerm  float64 `json:"long_term"`
+	Total     float64 `json:"total"`
+}
+
 type ProfilePage struct {
 	BaseModel      basemodel.BaseModel
 	tradingDetails TradingDetails
 	alpacaAccount  AlpacaAccount
+	realizedPnL    *RealizedPnL
 	orders         list.Model
 	positions      list.Model
 	filtering      bool
 	loading        bool
 	Reloaded       bool
+	Paper          bool
+	notice         string
+	showDividends  bool
+	dividends      *Dividends
+	dividendsErr   error
 }
 
 var (
@@ -126,13 +153,30 @@ var (
 				Border(lipgloss.RoundedBorder()).
 				BorderForeground(lipgloss.Color("#666666")).
 				Padding(0, 1)
+
+	noticeStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFD700")).
+			Bold(true)
+
+	marginCallStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF5555")).
+			Bold(true)
 )
 
+// What happened to an options contract, by the type of the event
+var optionEvents = map[string]string{
+	"OPEXP": "expired",
+	"OPASN": "was assigned",
+	"OPEXC": "was exercised",
+}
+
 type profileDataMsg struct {
 	tradingDetails TradingDetails
 	alpacaAccount  AlpacaAccount
 	orders         []messages.Order
 	positions      []messages.Position
+	realizedPnL    *RealizedPnL
+	borrow         map[string]string
 	err            error
 }
 
@@ -160,13 +204,31 @@ func (o orderItem) FilterValue() string { return o.order.Symbol }
 
 type positionItem struct {
 	position messages.Position
+	borrow   string
 }
 
 func (p positionItem) Title() string {
+	if p.position.AssetClass == "us_option" {
+		return p.position.Qty + "x " + p.position.Symbol + " (option)"
+	}
+
+	if p.position.Side == "short" {
+		return p.position.Qty + "x " + p.position.Symbol + " (short)"
+	}
+
 	return p.position.Qty + "x " + p.position.Symbol
 }
 
 func (p positionItem) Description() string {
+	if p.position.Side == "short" {
+		description := "Sold for: " + p.position.CostBasis + ", Price: " + p.position.CurrentPrice
+		if p.borrow != "" {
+			description += ", " + p.borrow
+		}
+
+		return description
+	}
+
 	return "Bought for: " + p.position.CostBasis + ", Price: " + p.position.CurrentPrice
 }
 
@@ -187,6 +249,12 @@ func NewProfilePage(client *http.Client, tokenStore *basemodel.TokenStore) Profi
 			key.NewBinding(key.WithKeys("s", "S"), key.WithHelp("s (sell)", "position")),
 			key.NewBinding(key.WithKeys("c"), key.WithHelp("c (cancel)", "order")),
 			key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh")),
+			key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "conditional orders")),
+			key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "recurring plans")),
+			key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "rebalance")),
+			key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "paper/live trading")),
+			key.NewBinding(key.WithKeys("w"), key.WithHelp("w", "crypto wallet")),
+			key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "dividends")),
 		}
 	}
 
@@ -214,9 +282,10 @@ func (p ProfilePage) fetchProfileData() tea.Msg {
 	alpacaAccount := AlpacaAccount{}
 	orders := []messages.Order{}
 	positions := []messages.Position{}
+	var realizedPnL *RealizedPnL
 
 	wg := sync.WaitGroup{}
-	wg.Add(4)
+	wg.Add(5)
 	var err1, err2, err3, err4 error
 	go func() {
 		defer wg.Done()
@@ -300,6 +369,30 @@ func (p ProfilePage) fetchProfileData() tea.Msg {
 		}
 	}()
 
+	// The realized P&L is only extra information, so the profile is still shown without it
+	go func() {
+		defer wg.Done()
+
+		from := time.Date(time.Now().Year(), time.January, 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+		body, err := requests.MakeRequest(
+			http.MethodGet,
+			requests.BaseURL+"/trading/pnl?from="+from,
+			nil,
+			p.BaseModel.Client,
+			p.BaseModel.TokenStore,
+		)
+		if err != nil {
+			return
+		}
+
+		pnl := RealizedPnL{}
+		if err := json.Unmarshal(body, &pnl); err != nil {
+			return
+		}
+
+		realizedPnL = &pnl
+	}()
+
 	wg.Wait()
 
 	if err1 != nil {
@@ -318,11 +411,25 @@ func (p ProfilePage) fetchProfileData() tea.Msg {
 		return profileDataMsg{err: err4}
 	}
 
+	// The borrow availability of the shorted stocks is only extra information as well
+	borrow := make(map[string]string)
+	for _, position := range positions {
+		if position.Side != "short" {
+			continue
+		}
+
+		if asset, err := assets.Get(position.Symbol, p.BaseModel.Client, p.BaseModel.TokenStore); err == nil {
+			borrow[position.Symbol] = asset.Borrow()
+		}
+	}
+
 	return profileDataMsg{
 		tradingDetails: tradingDetails,
 		alpacaAccount:  alpacaAccount,
 		orders:         orders,
 		positions:      positions,
+		realizedPnL:    realizedPnL,
+		borrow:         borrow,
 	}
 }
 
@@ -350,6 +457,24 @@ func (p ProfilePage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return p, cmd
 			}
+		} else if p.showDividends {
+			switch msg.String() {
+			case "q", "ctrl+c":
+				return p, func() tea.Msg {
+					return messages.QuitMsg{}
+				}
+
+			case "tab", "esc":
+				p.showDividends = false
+				return p, nil
+
+			case "r", "R":
+				p.dividends = nil
+				p.dividendsErr = nil
+				return p, p.fetchDividends
+			}
+
+			return p, nil
 		} else {
 			switch msg.String() {
 			case "q", "ctrl+c":
@@ -357,6 +482,15 @@ func (p ProfilePage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return messages.QuitMsg{}
 				}
 
+			case "tab":
+				p.showDividends = true
+				if p.dividends == nil {
+					p.dividendsErr = nil
+					return p, p.fetchDividends
+				}
+
+				return p, nil
+
 			case "esc":
 				activeFilterValue := ""
 				if p.orders.FilterInput.Focused() {
@@ -424,6 +558,17 @@ func (p ProfilePage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "s", "S":
 				if p.positions.FilterInput.Focused() {
 					position := p.positions.SelectedItem().(positionItem)
+
+					// A short position is covered by buying the shares back
+					if position.position.Side == "short" {
+						return p, func() tea.Msg {
+							return messages.PageSwitchMsg{
+								Page:   messages.BuyPageNumber,
+								Symbol: position.position.Symbol,
+							}
+						}
+					}
+
 					maxQuantity, err := strconv.ParseFloat(position.position.Qty, 64)
 					if err != nil {
 						return p, func() tea.Msg {
@@ -434,10 +579,15 @@ func (p ProfilePage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						}
 					}
 
+					symbol := position.position.Symbol
+					if position.position.AssetClass == "crypto" {
+						symbol = messages.CryptoPair(symbol)
+					}
+
 					return p, func() tea.Msg {
 						return messages.PageSwitchMsg{
 							Page:        messages.SellPageNumber,
-							Symbol:      position.position.Symbol,
+							Symbol:      symbol,
 							MaxQuantity: maxQuantity,
 						}
 					}
@@ -475,10 +625,41 @@ func (p ProfilePage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				}
 
+			case "o", "O":
+				return p, func() tea.Msg {
+					return messages.SmartPageSwitchMsg{
+						Page: messages.ConditionalOrdersPageNumber,
+					}
+				}
+
+			case "p", "P":
+				return p, func() tea.Msg {
+					return messages.SmartPageSwitchMsg{
+						Page: messages.RecurringPlansPageNumber,
+					}
+				}
+
+			case "a", "A":
+				return p, func() tea.Msg {
+					return messages.SmartPageSwitchMsg{
+						Page: messages.RebalancePageNumber,
+					}
+				}
+
+			case "w", "W":
+				return p, func() tea.Msg {
+					return messages.SmartPageSwitchMsg{
+						Page: messages.CryptoWalletPageNumber,
+					}
+				}
+
 			case "r", "R":
 				p.Reload()
 				return p, p.fetchProfileData
 
+			case "t", "T":
+				return p, paper.Switch(!p.Paper, p.BaseModel.Client, p.BaseModel.TokenStore)
+
 			default:
 				var cmd tea.Cmd
 				if p.orders.FilterInput.Focused() {
@@ -503,12 +684,13 @@ func (p ProfilePage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			p.tradingDetails = msg.tradingDetails
 			p.alpacaAccount = msg.alpacaAccount
+			p.realizedPnL = msg.realizedPnL
 			for i, order := range msg.orders {
 				p.orders.InsertItem(i, orderItem{order: order})
 			}
 
 			for i, position := range msg.positions {
-				p.positions.InsertItem(i, positionItem{position: position})
+				p.positions.InsertItem(i, positionItem{position: position, borrow: msg.borrow[position.Symbol]})
 			}
 		}
 
@@ -516,6 +698,11 @@ func (p ProfilePage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		p.positions.SetSize(p.BaseModel.Width/6, (2*p.BaseModel.Height)/3)
 
 		return p, nil
+
+	case dividendsMsg:
+		p.dividends = msg.dividends
+		p.dividendsErr = msg.err
+		return p, nil
 	}
 
 	return p, nil
@@ -534,8 +721,15 @@ func (p ProfilePage) View() string {
 
 	// FIX: Temporary fix
 	title := titleStyle.Render("👤 Profile")
+	if p.Paper {
+		title = titleStyle.Render("👤 Profile (paper account)")
+	}
 	// centeredTitle := lipgloss.Place(p.BaseModel.Width, lipgloss.Height(title), lipgloss.Center, lipgloss.Top, title)
 
+	if p.showDividends {
+		return lipgloss.JoinVertical(lipgloss.Center, "", title, p.renderTabs(), "", p.renderDividends())
+	}
+
 	personalInfo := p.renderPersonalInfo()
 
 	tradingAccount := p.renderTradingAccount()
@@ -544,8 +738,12 @@ func (p ProfilePage) View() string {
 
 	accountSettings := p.renderAccountSettings()
 
-	leftInfoColumn := lipgloss.JoinVertical(lipgloss.Left, personalInfo, contactInfo)
-	rightInfoColumn := lipgloss.JoinVertical(lipgloss.Left, tradingAccount, accountSettings)
+	margin := p.renderMargin()
+
+	realizedPnL := p.renderRealizedPnL()
+
+	leftInfoColumn := lipgloss.JoinVertical(lipgloss.Left, personalInfo, contactInfo, realizedPnL)
+	rightInfoColumn := lipgloss.JoinVertical(lipgloss.Left, tradingAccount, accountSettings, margin)
 
 	infoColumns := lipgloss.JoinHorizontal(
 		lipgloss.Top,
@@ -569,12 +767,22 @@ func (p ProfilePage) View() string {
 		positionsView,
 	)
 
+	notice := ""
+	if p.notice != "" {
+		notice = noticeStyle.Render(p.notice)
+	}
+
+	if p.tradingDetails.MarginCall {
+		notice = lipgloss.JoinVertical(lipgloss.Center, marginCallStyle.Render(p.marginCallWarning()), notice)
+	}
+
 	finalView := lipgloss.JoinVertical(
 		lipgloss.Center,
 		"",
 		// centeredTitle,
 		title,
-		"",
+		p.renderTabs(),
+		notice,
 		content,
 	)
 
@@ -684,6 +892,100 @@ func (p ProfilePage) renderAccountSettings() string {
 	return boxStyle.Width(p.BaseModel.Width / 5).Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
 }
 
+func (p ProfilePage) renderRealizedPnL() string {
+	var rows []string
+
+	rows = append(rows, sectionTitleStyle.Render(fmt.Sprintf("📈 Realized P&L %d", time.Now().Year())))
+
+	if p.realizedPnL == nil {
+		rows = append(rows, p.renderField("Realized", "unavailable"))
+		return boxStyle.Width(p.BaseModel.Width / 4).Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+	}
+
+	rows = append(rows, labelStyle.Render("Short Term:")+"  "+renderGain(p.realizedPnL.ShortTerm))
+	rows = append(rows, labelStyle.Render("Long Term:")+"  "+renderGain(p.realizedPnL.LongTerm))
+	rows = append(rows, labelStyle.Render("Total:")+"  "+renderGain(p.realizedPnL.Total))
+	rows = append(rows, p.renderField("Lot Method", strings.ToUpper(strings.ReplaceAll(p.realizedPnL.Method, "_", " "))))
+
+	return boxStyle.Width(p.BaseModel.Width / 4).Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+// The ratio of the equity to the maintenance margin, under which the margin is no longer considered healthy
+const healthyMargin = 1.5
+
+func (p ProfilePage) renderMargin() string {
+	var rows []string
+
+	rows = append(rows, sectionTitleStyle.Render("🏦 Margin"))
+
+	multiplier, _ := strconv.ParseFloat(p.tradingDetails.Multiplier, 64)
+	if multiplier <= 1 {
+		rows = append(rows, p.renderField("Account", "Cash"))
+		return boxStyle.Width(p.BaseModel.Width / 5).Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+	}
+
+	rows = append(rows, p.renderField("Account", fmt.Sprintf("Margin (%gx)", multiplier)))
+	rows = append(rows, labelStyle.Render("Health:")+"  "+p.renderMarginHealth())
+
+	excess, _ := strconv.ParseFloat(p.tradingDetails.MarginExcess, 64)
+	rows = append(rows, labelStyle.Render("Margin Excess:")+"  "+renderGain(excess))
+	rows = append(rows, p.renderField("Maintenance Margin", "$"+p.tradingDetails.MaintenanceMargin))
+	rows = append(rows, p.renderField("RegT Buying Power", "$"+p.tradingDetails.RegTBuyingPower))
+	rows = append(rows, p.renderField("Day Trading BP", "$"+p.tradingDetails.DaytradingBuyingPower))
+	rows = append(rows, p.renderField("Short Market Value", "$"+p.tradingDetails.ShortMarketValue))
+
+	shorting := "Disabled"
+	if p.tradingDetails.ShortingEnabled {
+		shorting = "Enabled"
+	}
+	rows = append(rows, p.renderField("Short Selling", shorting))
+
+	dayTrades := strconv.Itoa(p.tradingDetails.DaytradeCount)
+	if p.tradingDetails.PatternDayTrader {
+		dayTrades += " (pattern day trader)"
+	}
+	rows = append(rows, p.renderField("Day Trades", dayTrades))
+
+	return boxStyle.Width(p.BaseModel.Width / 5).Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+// renderMarginHealth compares the equity to the maintenance margin the positions need
+func (p ProfilePage) renderMarginHealth() string {
+	equity, _ := strconv.ParseFloat(p.tradingDetails.Equity, 64)
+	maintenance, _ := strconv.ParseFloat(p.tradingDetails.MaintenanceMargin, 64)
+
+	switch {
+	case p.tradingDetails.MarginCall:
+		return marginCallStyle.Render("Margin call")
+	case maintenance <= 0:
+		return statusActiveStyle.Render("Healthy")
+	case equity/maintenance < healthyMargin:
+		return noticeStyle.Render(fmt.Sprintf("At risk (%.0f%% of maintenance)", equity/maintenance*100))
+	default:
+		return statusActiveStyle.Render(fmt.Sprintf("Healthy (%.0f%% of maintenance)", equity/maintenance*100))
+	}
+}
+
+func (p ProfilePage) marginCallWarning() string {
+	excess, _ := strconv.ParseFloat(p.tradingDetails.MarginExcess, 64)
+	return fmt.Sprintf("⚠ Margin call: the equity is $%.2f below the maintenance margin. Deposit funds or close positions to cover it.", -excess)
+}
+
+func renderGain(gain float64) string {
+	style := valueStyle
+	if gain > 0 {
+		style = statusActiveStyle
+	} else if gain < 0 {
+		style = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Bold(true)
+	}
+
+	if gain < 0 {
+		return style.Render(fmt.Sprintf("-$%.2f", -gain))
+	}
+
+	return style.Render(fmt.Sprintf("$%.2f", gain))
+}
+
 func (p ProfilePage) renderField(label, value string) string {
 	return labelStyle.Render(label+":") + "  " + valueStyle.Render(value)
 }
@@ -698,11 +1000,128 @@ func (p ProfilePage) CancelOrder() error {
 	return nil
 }
 
+// Keeps the orders, positions and account status up to date with the events pushed by the server
+func (p *ProfilePage) ApplyEvent(msg messages.AccountEventMsg) {
+	if p.loading {
+		return
+	}
+
+	switch {
+	case msg.Trade != nil:
+		p.applyTradeEvent(*msg.Trade)
+	case msg.Option != nil:
+		p.applyOptionEvent(*msg.Option)
+	case msg.Account != nil:
+		p.alpacaAccount.Status = msg.Account.StatusTo
+		p.tradingDetails.Status = msg.Account.StatusTo
+	}
+}
+
+func (p *ProfilePage) applyTradeEvent(event messages.TradeEvent) {
+	order := event.Order
+
+	index := -1
+	for i, item := range p.orders.Items() {
+		if item.(orderItem).order.ID == order.ID {
+			index = i
+			break
+		}
+	}
+
+	if index == -1 {
+		p.orders.InsertItem(0, orderItem{order: order})
+	} else {
+		p.orders.SetItem(index, orderItem{order: order})
+	}
+
+	if event.Event != "fill" && event.Event != "partial_fill" {
+		return
+	}
+
+	qty, err := strconv.ParseFloat(event.PositionQty, 64)
+	if err != nil {
+		return
+	}
+
+	index = -1
+	for i, item := range p.positions.Items() {
+		if item.(positionItem).position.Symbol == order.Symbol {
+			index = i
+			break
+		}
+	}
+
+	if qty == 0 {
+		if index != -1 {
+			p.positions.RemoveItem(index)
+		}
+
+		return
+	}
+
+	side := "long"
+	if qty < 0 {
+		side = "short"
+	}
+
+	if index == -1 {
+		price, _ := strconv.ParseFloat(event.Price, 64)
+		if order.AssetClass == "us_option" {
+			price *= 100 // every contract is for 100 shares
+		}
+
+		p.positions.InsertItem(len(p.positions.Items()), positionItem{position: messages.Position{
+			AssetClass:    order.AssetClass,
+			AssetID:       order.AssetID,
+			Symbol:        order.Symbol,
+			Qty:           event.PositionQty,
+			Side:          side,
+			AvgEntryPrice: event.Price,
+			CurrentPrice:  event.Price,
+			CostBasis:     fmt.Sprintf("%.2f", qty*price),
+		}})
+
+		return
+	}
+
+	position := p.positions.Items()[index].(positionItem).position
+	position.Qty = event.PositionQty
+	position.Side = side
+	position.CurrentPrice = event.Price
+	p.positions.SetItem(index, positionItem{position: position})
+}
+
+// An expired, assigned or exercised contract is no longer held. The shares it turns into
+// show up with the next refresh of the positions.
+func (p *ProfilePage) applyOptionEvent(event messages.OptionEvent) {
+	action, ok := optionEvents[event.EntryType]
+	if !ok {
+		return
+	}
+
+	for i, item := range p.positions.Items() {
+		if item.(positionItem).position.Symbol == event.Symbol {
+			p.positions.RemoveItem(i)
+			break
+		}
+	}
+
+	p.notice = "The contract " + event.Symbol + " " + action
+	if action != "expired" {
+		p.notice += ", press r to see the updated positions"
+	}
+}
+
 func (p *ProfilePage) Reload() {
 	p.alpacaAccount = AlpacaAccount{}
 	p.tradingDetails = TradingDetails{}
+	p.realizedPnL = nil
 	p.orders.SetItems([]list.Item{})
 	p.positions.SetItems([]list.Item{})
+	p.notice = ""
+	p.showDividends = false
+	p.dividends = nil
+	p.dividendsErr = nil
 	p.loading = true
 	p.Reloaded = true
 }
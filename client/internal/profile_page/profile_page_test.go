@@ -3,6 +3,7 @@ package profilepage
 import (
 	"errors"
 	"net/http"
+	"strings"
 	"testing"
 
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
@@ -172,3 +173,202 @@ func TestProfilePage_OrderPageNavigation(t *testing.T) {
 
 	}
 }
+
+func TestProfilePage_ApplyTradeEvent(t *testing.T) {
+	p := fakeProfilePage()
+	p.loading = false
+
+	p.orders.SetItems([]list.Item{orderItem{order: messages.Order{ID: "1", Symbol: "AAPL", Status: "new"}}})
+
+	p.ApplyEvent(messages.AccountEventMsg{
+		Stream: "trades",
+		Trade: &messages.TradeEvent{
+			Event:       "fill",
+			Order:       messages.Order{ID: "1", Symbol: "AAPL", Status: "filled"},
+			Price:       "200",
+			PositionQty: "2",
+		},
+	})
+
+	if len(p.orders.Items()) != 1 {
+		t.Fatalf("expected the order to be updated in place, got %d orders", len(p.orders.Items()))
+	}
+
+	if p.orders.Items()[0].(orderItem).order.Status != "filled" {
+		t.Error("expected order status to be updated")
+	}
+
+	if len(p.positions.Items()) != 1 {
+		t.Fatalf("expected a new position, got %d", len(p.positions.Items()))
+	}
+
+	position := p.positions.Items()[0].(positionItem).position
+	if position.Qty != "2" || position.CostBasis != "400.00" {
+		t.Errorf("unexpected position: %+v", position)
+	}
+
+	p.ApplyEvent(messages.AccountEventMsg{
+		Stream: "trades",
+		Trade: &messages.TradeEvent{
+			Event:       "fill",
+			Order:       messages.Order{ID: "2", Symbol: "AAPL", Status: "filled"},
+			Price:       "210",
+			PositionQty: "0",
+		},
+	})
+
+	if len(p.orders.Items()) != 2 {
+		t.Error("expected the new order to be inserted")
+	}
+
+	if len(p.positions.Items()) != 0 {
+		t.Error("expected the closed position to be removed")
+	}
+}
+
+func TestProfilePage_ApplyOptionEvent(t *testing.T) {
+	p := fakeProfilePage()
+	p.loading = false
+
+	p.positions.SetItems([]list.Item{
+		positionItem{position: messages.Position{Symbol: "AAPL", Qty: "10", AssetClass: "us_equity"}},
+		positionItem{position: messages.Position{Symbol: "AAPL250620C00192500", Qty: "1", AssetClass: "us_option"}},
+	})
+
+	p.ApplyEvent(messages.AccountEventMsg{
+		Stream: "options",
+		Option: &messages.OptionEvent{EntryType: "OPASN", Symbol: "AAPL250620C00192500", Qty: "1"},
+	})
+
+	if len(p.positions.Items()) != 1 || p.positions.Items()[0].(positionItem).position.Symbol != "AAPL" {
+		t.Fatalf("expected the assigned contract to be removed, got %v", p.positions.Items())
+	}
+
+	if !strings.Contains(p.notice, "was assigned") {
+		t.Errorf("expected a notice about the assignment, got %q", p.notice)
+	}
+}
+
+func TestProfilePage_ApplyAccountEvent(t *testing.T) {
+	p := fakeProfilePage()
+	p.loading = false
+
+	p.ApplyEvent(messages.AccountEventMsg{
+		Stream:  "accounts",
+		Account: &messages.AccountStatusEvent{StatusFrom: "APPROVED", StatusTo: "ACTIVE"},
+	})
+
+	if p.alpacaAccount.Status != "ACTIVE" || p.tradingDetails.Status != "ACTIVE" {
+		t.Error("expected account status to be updated")
+	}
+}
+
+func TestProfilePage_RealizedPnL(t *testing.T) {
+	p := fakeProfilePage()
+	p.BaseModel.Width = 200
+	p.BaseModel.Height = 60
+
+	m, _ := p.Update(profileDataMsg{
+		realizedPnL: &RealizedPnL{Method: "highest_cost", ShortTerm: -12.5, LongTerm: 100, Total: 87.5},
+	})
+	model := m.(ProfilePage)
+
+	view := model.renderRealizedPnL()
+	for _, expected := range []string{"-$12.50", "$100.00", "$87.50", "HIGHEST COST"} {
+		if !strings.Contains(view, expected) {
+			t.Errorf("expected %q in the realized P&L section", expected)
+		}
+	}
+
+	model.Reload()
+	if model.realizedPnL != nil {
+		t.Error("expected the realized P&L to be reset")
+	}
+
+	if !strings.Contains(model.renderRealizedPnL(), "unavailable") {
+		t.Error("expected the realized P&L to be shown as unavailable")
+	}
+}
+
+func TestProfilePage_TogglePaperTrading(t *testing.T) {
+	p := fakeProfilePage()
+	p.loading = false
+
+	_, cmd := p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	if cmd == nil {
+		t.Fatal("expected a command that switches the account")
+	}
+
+	p.Paper = true
+	if !strings.Contains(p.View(), "paper account") {
+		t.Fatal("expected the title to show the paper account")
+	}
+}
+
+func TestProfilePage_DividendsTab(t *testing.T) {
+	p := fakeProfilePage()
+	p.loading = false
+
+	model, cmd := p.Update(tea.KeyMsg{Type: tea.KeyTab})
+	p = model.(ProfilePage)
+	if !p.showDividends {
+		t.Fatal("expected the dividends tab to be shown")
+	}
+	if cmd == nil {
+		t.Fatal("expected the dividends to be fetched")
+	}
+
+	model, _ = p.Update(dividendsMsg{dividends: &Dividends{
+		Received:       []Month{{Month: "2025-04", Amount: 12.5}},
+		ReceivedTotal:  12.5,
+		Projection:     []Month{{Month: "2025-05", Amount: 13}},
+		ProjectedTotal: 13,
+	}})
+	p = model.(ProfilePage)
+
+	bars := dividendBars(*p.dividends)
+	if len(bars) != 2 || bars[0].Label != "Apr" || bars[1].Values[0].Value != 13 {
+		t.Fatalf("unexpected bars %+v", bars)
+	}
+
+	model, cmd = p.Update(tea.KeyMsg{Type: tea.KeyTab})
+	p = model.(ProfilePage)
+	if p.showDividends || cmd != nil {
+		t.Fatal("expected to switch back to the overview without fetching")
+	}
+	if p.dividends == nil {
+		t.Fatal("expected the dividends to be kept")
+	}
+}
+
+func TestProfilePage_MarginAndShortPositions(t *testing.T) {
+	p := fakeProfilePage()
+
+	model, _ := p.Update(profileDataMsg{
+		tradingDetails: TradingDetails{Equity: "3000", MaintenanceMargin: "3500", MarginExcess: "-500", Multiplier: "2", MarginCall: true},
+		positions:      []messages.Position{{Symbol: "TSLA", Qty: "-10", Side: "short", CostBasis: "2000", CurrentPrice: "210"}},
+		borrow:         map[string]string{"TSLA": "easy to borrow"},
+	})
+	p = model.(ProfilePage)
+
+	item := p.positions.Items()[0].(positionItem)
+	if !strings.Contains(item.Title(), "(short)") || !strings.Contains(item.Description(), "easy to borrow") {
+		t.Fatalf("expected a short position with its borrow availability, got %q %q", item.Title(), item.Description())
+	}
+
+	if !strings.Contains(p.renderMarginHealth(), "Margin call") {
+		t.Fatalf("expected a margin call, got %q", p.renderMarginHealth())
+	}
+
+	if !strings.Contains(p.marginCallWarning(), "$500.00 below") {
+		t.Fatalf("unexpected warning %q", p.marginCallWarning())
+	}
+
+	p.positions.Select(0)
+	p.positions.FilterInput.Focus()
+	p.orders.FilterInput.Blur()
+	_, cmd := p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if msg := cmd().(messages.PageSwitchMsg); msg.Page != messages.BuyPageNumber || msg.Symbol != "TSLA" {
+		t.Fatalf("expected the short position to be covered with a buy, got %+v", msg)
+	}
+}
@@ -0,0 +1,526 @@
+package rebalancepage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type ModelPortfolio struct {
+	Weights   map[string]float64 `json:"weights"`
+	DriftBand float64            `json:"drift_band"`
+}
+
+type Allocation struct {
+	Symbol      string  `json:"symbol"`
+	MarketValue float64 `json:"market_value"`
+	Current     float64 `json:"current"`
+	Target      float64 `json:"target"`
+	Drift       float64 `json:"drift"`
+}
+
+type Trade struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Notional float64 `json:"notional,omitempty"`
+	Qty      float64 `json:"qty,omitempty"`
+	OrderID  string  `json:"order_id,omitempty"`
+	Status   string  `json:"status,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type Proposal struct {
+	Equity      float64      `json:"equity"`
+	Cash        float64      `json:"cash"`
+	Allocations []Allocation `json:"allocations"`
+	Trades      []Trade      `json:"trades"`
+}
+
+type ProposalLoadedMsg struct {
+	proposal *Proposal
+	executed bool
+	noModel  bool
+	err      error
+}
+
+var (
+	titleStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#00FFFF")).
+			Bold(true).
+			Padding(0, 2).
+			MarginBottom(1)
+
+	sectionTitleStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#BB88FF")).
+				Bold(true).
+				Underline(true).
+				MarginBottom(1)
+
+	boxStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#BB88FF")).
+			Padding(1, 2)
+
+	labelStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#BB88FF")).
+			Width(25).
+			Align(lipgloss.Center)
+
+	helpStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#666666"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF0000")).
+			Bold(true)
+
+	successStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#00FF00")).
+			Bold(true)
+
+	sellStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF5555"))
+
+	buyStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#00FF00"))
+)
+
+type RebalancePage struct {
+	BaseModel basemodel.BaseModel
+	proposal  *Proposal
+	loaded    bool
+	executed  bool
+	noModel   bool
+	confirm   bool
+	editing   bool
+	weights   textinput.Model
+	driftBand textinput.Model
+	cursor    int
+	spinner   spinner.Model
+	err       error
+	formErr   string
+	Reloaded  bool
+}
+
+func New(client *http.Client, tokenStore *basemodel.TokenStore) RebalancePage {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF"))
+
+	weights := textinput.New()
+	weights.Placeholder = "VOO=0.6, BND=0.4"
+	weights.Width = 40
+	weights.CharLimit = 200
+
+	driftBand := textinput.New()
+	driftBand.Placeholder = "0.05"
+	driftBand.Width = 40
+	driftBand.CharLimit = 5
+
+	return RebalancePage{
+		BaseModel: basemodel.BaseModel{Client: client, TokenStore: tokenStore},
+		weights:   weights,
+		driftBand: driftBand,
+		spinner:   s,
+		Reloaded:  true,
+	}
+}
+
+func (r RebalancePage) Init() tea.Cmd {
+	return tea.Batch(
+		r.spinner.Tick,
+		r.rebalance(true),
+	)
+}
+
+// Previews the trades on a dry run, otherwise places them
+func (r RebalancePage) rebalance(dryRun bool) tea.Cmd {
+	return func() tea.Msg {
+		url := requests.BaseURL + "/trading/rebalance"
+		if dryRun {
+			url += "?dry_run=true"
+		}
+
+		body, err := requests.MakeRequest(http.MethodPost, url, nil, r.BaseModel.Client, r.BaseModel.TokenStore)
+		if err != nil {
+			// There is nothing to rebalance towards until the user sets a model portfolio
+			if strings.Contains(err.Error(), "model portfolio") {
+				return ProposalLoadedMsg{noModel: true}
+			}
+
+			return ProposalLoadedMsg{err: err}
+		}
+
+		var info struct {
+			Proposal Proposal `json:"proposal"`
+		}
+		if err := json.Unmarshal(body, &info); err != nil {
+			return ProposalLoadedMsg{err: err}
+		}
+
+		return ProposalLoadedMsg{proposal: &info.Proposal, executed: !dryRun}
+	}
+}
+
+// ParseWeights reads weights written as "VOO=0.6, BND=0.4". Percentages like "VOO=60%" work as well.
+func ParseWeights(value string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for part := range strings.SplitSeq(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		symbol, weightString, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, errors.New("Error every weight should look like SYMBOL=0.5")
+		}
+
+		weightString = strings.TrimSpace(weightString)
+		percentage := strings.HasSuffix(weightString, "%")
+		weight, err := strconv.ParseFloat(strings.TrimSuffix(weightString, "%"), 64)
+		if err != nil {
+			return nil, errors.New("Error invalid weight for " + strings.TrimSpace(symbol))
+		}
+
+		if percentage {
+			weight /= 100
+		}
+
+		weights[strings.ToUpper(strings.TrimSpace(symbol))] = weight
+	}
+
+	if len(weights) == 0 {
+		return nil, errors.New("Error at least one symbol is required")
+	}
+
+	return weights, nil
+}
+
+func (r *RebalancePage) saveModel() error {
+	weights, err := ParseWeights(r.weights.Value())
+	if err != nil {
+		return err
+	}
+
+	driftBand := 0.0
+	if value := strings.TrimSpace(r.driftBand.Value()); value != "" {
+		driftBand, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("Error invalid drift band: must be a number")
+		}
+	}
+
+	jsonData, err := json.Marshal(ModelPortfolio{Weights: weights, DriftBand: driftBand})
+	if err != nil {
+		return err
+	}
+
+	_, err = requests.MakeRequest(http.MethodPut, requests.BaseURL+"/trading/rebalance/model", bytes.NewReader(jsonData), r.BaseModel.Client, r.BaseModel.TokenStore)
+	return err
+}
+
+func (r *RebalancePage) startEditing() {
+	r.editing = true
+	r.formErr = ""
+	r.cursor = 0
+	r.weights.Focus()
+	r.driftBand.Blur()
+
+	if r.proposal == nil || r.weights.Value() != "" {
+		return
+	}
+
+	// Start from the current model, so it only needs to be adjusted
+	var parts []string
+	for _, allocation := range r.proposal.Allocations {
+		if allocation.Target > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%g", allocation.Symbol, allocation.Target))
+		}
+	}
+	sort.Strings(parts)
+	r.weights.SetValue(strings.Join(parts, ", "))
+}
+
+func (r RebalancePage) updateForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "esc":
+		r.editing = false
+		r.formErr = ""
+		r.weights.Blur()
+		r.driftBand.Blur()
+		return r, nil
+
+	case "tab", "down", "up", "ctrl+j", "ctrl+k":
+		r.cursor = 1 - r.cursor
+		if r.cursor == 0 {
+			r.weights.Focus()
+			r.driftBand.Blur()
+		} else {
+			r.driftBand.Focus()
+			r.weights.Blur()
+		}
+		return r, nil
+
+	case "enter":
+		if err := r.saveModel(); err != nil {
+			r.formErr = err.Error()
+			return r, nil
+		}
+
+		r.editing = false
+		r.weights.Blur()
+		r.driftBand.Blur()
+		r.Reload()
+		return r, r.Init()
+	}
+
+	if r.cursor == 0 {
+		r.weights, cmd = r.weights.Update(msg)
+	} else {
+		r.driftBand, cmd = r.driftBand.Update(msg)
+	}
+
+	return r, cmd
+}
+
+func (r RebalancePage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case ProposalLoadedMsg:
+		r.loaded = true
+		r.err = msg.err
+		r.noModel = msg.noModel
+		r.executed = msg.executed
+		r.confirm = false
+		if msg.proposal != nil {
+			r.proposal = msg.proposal
+		}
+
+		if msg.executed {
+			return r, func() tea.Msg {
+				return messages.ReloadMsg{
+					Page: messages.ProfilePageNumber,
+				}
+			}
+		}
+
+		return r, nil
+
+	case spinner.TickMsg:
+		if !r.loaded {
+			r.spinner, cmd = r.spinner.Update(msg)
+			return r, cmd
+		}
+		return r, nil
+
+	case tea.KeyMsg:
+		if r.editing {
+			return r.updateForm(msg)
+		}
+
+		switch msg.String() {
+		case "q":
+			return r, func() tea.Msg {
+				return messages.QuitMsg{}
+			}
+
+		case "esc":
+			if r.confirm {
+				r.confirm = false
+				return r, nil
+			}
+
+			return r, func() tea.Msg {
+				return messages.SmartPageSwitchMsg{
+					Page: messages.ProfilePageNumber,
+				}
+			}
+
+		case "e", "E":
+			r.startEditing()
+			return r, textinput.Blink
+
+		case "r", "R":
+			r.Reload()
+			return r, r.Init()
+
+		case "a", "A":
+			if !r.loaded || r.executed || r.proposal == nil || len(r.proposal.Trades) == 0 {
+				return r, nil
+			}
+
+			r.confirm = true
+			return r, nil
+
+		case "y", "Y":
+			if !r.confirm {
+				return r, nil
+			}
+
+			r.confirm = false
+			r.loaded = false
+			return r, tea.Batch(r.spinner.Tick, r.rebalance(false))
+
+		case "n", "N":
+			r.confirm = false
+			return r, nil
+		}
+	}
+
+	return r, nil
+}
+
+func (r RebalancePage) View() string {
+	header := lipgloss.PlaceHorizontal(r.BaseModel.Width, lipgloss.Center, titleStyle.Render("⚖ Rebalance"))
+
+	if r.editing {
+		return header + "\n" + r.renderForm()
+	}
+
+	if !r.loaded {
+		return lipgloss.Place(r.BaseModel.Width, r.BaseModel.Height, lipgloss.Center, lipgloss.Center, r.spinner.View())
+	}
+
+	var content string
+	switch {
+	case r.err != nil:
+		content = lipgloss.JoinVertical(lipgloss.Center,
+			errorStyle.Render(fmt.Sprintf("Error: %v", r.err)),
+			"",
+			helpStyle.Render("r: retry • esc: back • q: quit"),
+		)
+
+	case r.noModel:
+		content = lipgloss.JoinVertical(lipgloss.Center,
+			"You haven't set a model portfolio yet.",
+			"Press e to choose the symbols you want to hold and their weights.",
+			"",
+			helpStyle.Render("e: edit model • esc: back • q: quit"),
+		)
+
+	default:
+		content = r.renderProposal()
+	}
+
+	return header + "\n" + lipgloss.Place(r.BaseModel.Width, r.BaseModel.Height-4, lipgloss.Center, lipgloss.Center, content)
+}
+
+func (r RebalancePage) renderProposal() string {
+	allocations := []string{sectionTitleStyle.Render("Current vs target")}
+	allocations = append(allocations, fmt.Sprintf("%-8s %12s %9s %9s %9s", "Symbol", "Value", "Current", "Target", "Drift"))
+	for _, a := range r.proposal.Allocations {
+		allocations = append(allocations, fmt.Sprintf("%-8s %12.2f %8.1f%% %8.1f%% %+8.1f%%",
+			a.Symbol, a.MarketValue, a.Current*100, a.Target*100, a.Drift*100))
+	}
+	allocations = append(allocations, "", fmt.Sprintf("Equity: $%.2f   Cash: $%.2f", r.proposal.Equity, r.proposal.Cash))
+
+	trades := []string{sectionTitleStyle.Render("Proposed trades")}
+	if r.executed {
+		trades[0] = sectionTitleStyle.Render("Placed trades")
+	}
+
+	if len(r.proposal.Trades) == 0 {
+		trades = append(trades, "Everything is within the drift band.")
+	}
+
+	for _, trade := range r.proposal.Trades {
+		amount := fmt.Sprintf("$%.2f", trade.Notional)
+		if trade.Qty != 0 {
+			amount = strconv.FormatFloat(trade.Qty, 'f', -1, 64) + " shares"
+		}
+
+		line := fmt.Sprintf("%-4s %-8s %s", strings.ToUpper(trade.Side), trade.Symbol, amount)
+		if trade.Side == "sell" {
+			line = sellStyle.Render(line)
+		} else {
+			line = buyStyle.Render(line)
+		}
+
+		if trade.Error != "" {
+			line += "  " + errorStyle.Render(trade.Error)
+		} else if trade.Status != "" {
+			line += "  " + trade.Status
+		}
+
+		trades = append(trades, line)
+	}
+
+	boxes := lipgloss.JoinHorizontal(lipgloss.Top,
+		boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, allocations...)),
+		" ",
+		boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, trades...)),
+	)
+
+	var footer string
+	switch {
+	case r.confirm:
+		footer = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFF00")).Bold(true).
+			Render(fmt.Sprintf("Place these %d orders? (y/n)", len(r.proposal.Trades)))
+	case r.executed:
+		footer = successStyle.Render("✓ The orders were sent") + "\n" + helpStyle.Render("r: new preview • esc: back • q: quit")
+	default:
+		footer = helpStyle.Render("a: approve trades • e: edit model • r: refresh • esc: back • q: quit")
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Center, boxes, "", footer)
+}
+
+func (r RebalancePage) renderForm() string {
+	fields := []string{
+		r.renderField("Weights", r.weights.View(), r.cursor == 0),
+		r.renderField("Drift band", r.driftBand.View(), r.cursor == 1),
+	}
+
+	content := lipgloss.JoinVertical(lipgloss.Center, fields...)
+	if r.formErr != "" {
+		content = lipgloss.JoinVertical(lipgloss.Center, content, "", errorStyle.Render("❌ "+r.formErr))
+	}
+
+	help := helpStyle.Render("tab/↑/↓: navigate • enter: save • esc: cancel")
+	content = lipgloss.JoinVertical(lipgloss.Center, content, "", help)
+
+	return lipgloss.Place(r.BaseModel.Width, r.BaseModel.Height-4, lipgloss.Center, lipgloss.Center, content)
+}
+
+func (r RebalancePage) renderField(label, value string, focused bool) string {
+	fieldStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFFFF")).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#666666")).
+		Padding(0, 1).
+		Width(46)
+
+	if focused {
+		fieldStyle = fieldStyle.
+			Foreground(lipgloss.Color("#00FFFF")).
+			Background(lipgloss.Color("#2a2a4e")).
+			BorderForeground(lipgloss.Color("#00FFFF"))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Center, labelStyle.Render(label), fieldStyle.Render(value))
+}
+
+func (r *RebalancePage) Reload() {
+	r.loaded = false
+	r.executed = false
+	r.noModel = false
+	r.confirm = false
+	r.err = nil
+	r.Reloaded = true
+}
@@ -0,0 +1,100 @@
+package rebalancepage
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestPage() RebalancePage {
+	r := New(&http.Client{}, &basemodel.TokenStore{})
+	r.BaseModel.Width = 120
+	r.BaseModel.Height = 40
+	return r
+}
+
+func testProposal() *Proposal {
+	return &Proposal{
+		Equity: 1000,
+		Cash:   100,
+		Allocations: []Allocation{
+			{Symbol: "VOO", MarketValue: 700, Current: 0.7, Target: 0.6, Drift: 0.1},
+			{Symbol: "BND", MarketValue: 200, Current: 0.2, Target: 0.4, Drift: -0.2},
+		},
+		Trades: []Trade{
+			{Symbol: "VOO", Side: "sell", Notional: 100},
+			{Symbol: "BND", Side: "buy", Notional: 200},
+		},
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	weights, err := ParseWeights("voo=0.6, BND = 40%")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if weights["VOO"] != 0.6 || weights["BND"] != 0.4 {
+		t.Fatalf("unexpected weights %v", weights)
+	}
+
+	for _, value := range []string{"", "VOO", "VOO=abc"} {
+		if _, err := ParseWeights(value); err == nil {
+			t.Fatalf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestUpdate_ApproveNeedsConfirmation(t *testing.T) {
+	r := newTestPage()
+
+	model, _ := r.Update(ProposalLoadedMsg{proposal: testProposal()})
+	r = model.(RebalancePage)
+	if !strings.Contains(r.View(), "Proposed trades") {
+		t.Fatal("expected the proposed trades to be shown")
+	}
+
+	model, _ = r.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	r = model.(RebalancePage)
+	if !r.confirm {
+		t.Fatal("expected a confirmation before placing the trades")
+	}
+
+	model, _ = r.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	r = model.(RebalancePage)
+	if r.confirm || !r.loaded {
+		t.Fatal("expected the confirmation to be dismissed")
+	}
+}
+
+func TestUpdate_NoModel(t *testing.T) {
+	r := newTestPage()
+
+	model, _ := r.Update(ProposalLoadedMsg{noModel: true})
+	r = model.(RebalancePage)
+
+	model, _ = r.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	r = model.(RebalancePage)
+	if r.confirm {
+		t.Fatal("expected nothing to approve without a model portfolio")
+	}
+
+	model, _ = r.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	r = model.(RebalancePage)
+	if !r.editing {
+		t.Fatal("expected the model form to be opened")
+	}
+}
+
+func TestStartEditing_PrefillsTargets(t *testing.T) {
+	r := newTestPage()
+	r.proposal = testProposal()
+
+	r.startEditing()
+	if value := r.weights.Value(); value != "BND=0.4, VOO=0.6" {
+		t.Fatalf("unexpected prefilled weights %q", value)
+	}
+}
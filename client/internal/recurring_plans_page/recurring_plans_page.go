@@ -0,0 +1,688 @@
+package recurringplanspage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/messages"
+	"github.com/Phantomvv1/KayTrade/client/internal/requests"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Plan struct {
+	ID         string     `json:"id"`
+	Symbol     string     `json:"symbol"`
+	Notional   float64    `json:"notional"`
+	Frequency  string     `json:"frequency"`
+	Weekday    string     `json:"weekday,omitempty"`
+	DayOfMonth int        `json:"day_of_month,omitempty"`
+	Status     string     `json:"status"`
+	NextRunAt  *time.Time `json:"next_run_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (p Plan) FilterValue() string {
+	return p.Symbol
+}
+
+func (p Plan) Title() string {
+	return fmt.Sprintf("$%.2f of %s %s", p.Notional, p.Symbol, p.schedule())
+}
+
+func (p Plan) schedule() string {
+	if p.Frequency == "weekly" && p.Weekday != "" {
+		return "every " + strings.ToUpper(p.Weekday[:1]) + p.Weekday[1:]
+	}
+
+	return "every month on day " + strconv.Itoa(p.DayOfMonth)
+}
+
+func (p Plan) Description() string {
+	statusSymbol := ""
+	switch p.Status {
+	case "active":
+		statusSymbol = "✓"
+	case "paused":
+		statusSymbol = "⏸"
+	default:
+		statusSymbol = "•"
+	}
+
+	if p.NextRunAt != nil {
+		return fmt.Sprintf("%s %s, next run: %s", statusSymbol, p.Status, p.NextRunAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	return fmt.Sprintf("%s %s", statusSymbol, p.Status)
+}
+
+type Execution struct {
+	ID         string    `json:"id"`
+	OrderID    string    `json:"order_id"`
+	Notional   float64   `json:"notional"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error"`
+	ExecutedAt time.Time `json:"executed_at"`
+}
+
+type PlansLoadedMsg struct {
+	plans []Plan
+	err   error
+}
+
+type ExecutionsLoadedMsg struct {
+	planID     string
+	executions []Execution
+	err        error
+}
+
+type PlanUpdatedMsg struct {
+	id  string
+	err error
+}
+
+var (
+	labelStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#BB88FF")).
+			Width(25).
+			Align(lipgloss.Center)
+
+	helpStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#666666"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF0000")).
+			Bold(true)
+)
+
+var weekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday"}
+
+const (
+	symbolField = iota
+	amountField
+	frequencyField
+	dayField
+	fieldCount
+)
+
+type RecurringPlansPage struct {
+	BaseModel    basemodel.BaseModel
+	plans        list.Model
+	executions   []Execution
+	selectedPlan string
+	titleBar     string
+	loaded       bool
+	spinner      spinner.Model
+	err          error
+	Reloaded     bool
+	filtering    bool
+	hasFilter    bool
+	creating     bool
+	symbol       textinput.Model
+	amount       textinput.Model
+	dayOfMonth   textinput.Model
+	monthly      bool
+	weekdayIdx   int
+	cursor       int
+	formErr      string
+}
+
+func New(client *http.Client, tokenStore *basemodel.TokenStore) RecurringPlansPage {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF"))
+
+	delegate := list.NewDefaultDelegate()
+
+	cyan := lipgloss.Color("#00FFFF")
+	purple := lipgloss.Color("#A020F0")
+
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
+		Foreground(cyan).
+		BorderForeground(purple)
+	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.
+		Foreground(lipgloss.Color("#888888")).
+		BorderForeground(purple)
+
+	l := list.New([]list.Item{}, delegate, 0, 0)
+	l.DisableQuitKeybindings()
+	l.Title = ""
+	l.SetFilteringEnabled(true)
+	l.Styles.Title = lipgloss.NewStyle().
+		Foreground(cyan).
+		Bold(true).
+		Padding(0, 1)
+	l.Styles.PaginationStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262"))
+	l.Styles.HelpStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262"))
+
+	l.AdditionalFullHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+			key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "history")),
+			key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "new plan")),
+			key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "pause/resume")),
+			key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "cancel")),
+			key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh")),
+		}
+	}
+
+	symbol := textinput.New()
+	symbol.Placeholder = "Symbol"
+	symbol.Width = 27
+	symbol.CharLimit = 10
+
+	amount := textinput.New()
+	amount.Placeholder = "Amount in $"
+	amount.Width = 27
+	amount.CharLimit = 20
+
+	dayOfMonth := textinput.New()
+	dayOfMonth.Placeholder = "Day of the month"
+	dayOfMonth.Width = 27
+	dayOfMonth.CharLimit = 2
+
+	return RecurringPlansPage{
+		BaseModel:  basemodel.BaseModel{Client: client, TokenStore: tokenStore},
+		plans:      l,
+		titleBar:   "RECURRING PLANS",
+		loaded:     false,
+		spinner:    s,
+		Reloaded:   true,
+		symbol:     symbol,
+		amount:     amount,
+		dayOfMonth: dayOfMonth,
+	}
+}
+
+func (r RecurringPlansPage) Init() tea.Cmd {
+	return tea.Batch(
+		r.spinner.Tick,
+		r.loadPlans,
+	)
+}
+
+func (r RecurringPlansPage) loadPlans() tea.Msg {
+	body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/trading/recurring", nil, r.BaseModel.Client, r.BaseModel.TokenStore)
+	if err != nil {
+		return PlansLoadedMsg{err: err}
+	}
+
+	var info struct {
+		Plans []Plan `json:"plans"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return PlansLoadedMsg{err: err}
+	}
+
+	return PlansLoadedMsg{plans: info.Plans}
+}
+
+func (r RecurringPlansPage) loadExecutions(id string) tea.Cmd {
+	return func() tea.Msg {
+		body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/trading/recurring/"+id, nil, r.BaseModel.Client, r.BaseModel.TokenStore)
+		if err != nil {
+			return ExecutionsLoadedMsg{planID: id, err: err}
+		}
+
+		var info struct {
+			Executions []Execution `json:"executions"`
+		}
+		if err := json.Unmarshal(body, &info); err != nil {
+			return ExecutionsLoadedMsg{planID: id, err: err}
+		}
+
+		return ExecutionsLoadedMsg{planID: id, executions: info.Executions}
+	}
+}
+
+func (r RecurringPlansPage) changePlan(method, id, action string) tea.Cmd {
+	return func() tea.Msg {
+		url := requests.BaseURL + "/trading/recurring/" + id
+		if action != "" {
+			url += "/" + action
+		}
+
+		_, err := requests.MakeRequest(method, url, nil, r.BaseModel.Client, r.BaseModel.TokenStore)
+		return PlanUpdatedMsg{id: id, err: err}
+	}
+}
+
+func (r *RecurringPlansPage) createPlan() error {
+	data := make(map[string]any)
+
+	symbol := strings.ToUpper(strings.TrimSpace(r.symbol.Value()))
+	if symbol == "" {
+		return errors.New("Error symbol is required")
+	}
+	data["symbol"] = symbol
+
+	amount, err := strconv.ParseFloat(strings.TrimSpace(r.amount.Value()), 64)
+	if err != nil {
+		return errors.New("Error invalid amount: must be a number")
+	}
+
+	if amount < 1 {
+		return errors.New("Error amount must be at least $1")
+	}
+	data["notional"] = amount
+
+	if r.monthly {
+		day, err := strconv.Atoi(strings.TrimSpace(r.dayOfMonth.Value()))
+		if err != nil || day < 1 || day > 31 {
+			return errors.New("Error the day of the month must be between 1 and 31")
+		}
+
+		data["frequency"] = "monthly"
+		data["day_of_month"] = day
+	} else {
+		data["frequency"] = "weekly"
+		data["weekday"] = weekdays[r.weekdayIdx]
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = requests.MakeRequest(http.MethodPost, requests.BaseURL+"/trading/recurring", bytes.NewReader(jsonData), r.BaseModel.Client, r.BaseModel.TokenStore)
+	return err
+}
+
+func (r *RecurringPlansPage) focusField() {
+	r.symbol.Blur()
+	r.amount.Blur()
+	r.dayOfMonth.Blur()
+
+	switch r.cursor {
+	case symbolField:
+		r.symbol.Focus()
+	case amountField:
+		r.amount.Focus()
+	case dayField:
+		if r.monthly {
+			r.dayOfMonth.Focus()
+		}
+	}
+}
+
+func (r *RecurringPlansPage) resetForm() {
+	r.creating = false
+	r.formErr = ""
+	r.cursor = symbolField
+	r.monthly = false
+	r.weekdayIdx = 0
+	r.symbol.SetValue("")
+	r.amount.SetValue("")
+	r.dayOfMonth.SetValue("")
+	r.focusField()
+	r.symbol.Blur()
+}
+
+func (r RecurringPlansPage) updateForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "esc":
+		r.resetForm()
+		return r, nil
+
+	case "ctrl+j", "down", "tab":
+		r.cursor = (r.cursor + 1) % fieldCount
+		r.focusField()
+		return r, nil
+
+	case "ctrl+k", "up":
+		r.cursor = (r.cursor + fieldCount - 1) % fieldCount
+		r.focusField()
+		return r, nil
+
+	case "h", "left", "l", "right":
+		step := 1
+		if msg.String() == "h" || msg.String() == "left" {
+			step = -1
+		}
+
+		switch {
+		case r.cursor == frequencyField:
+			r.monthly = !r.monthly
+			return r, nil
+		case r.cursor == dayField && !r.monthly:
+			r.weekdayIdx = (r.weekdayIdx + step + len(weekdays)) % len(weekdays)
+			return r, nil
+		}
+
+	case "enter":
+		r.formErr = ""
+		if err := r.createPlan(); err != nil {
+			r.formErr = err.Error()
+			return r, nil
+		}
+
+		r.resetForm()
+		r.Reload()
+		return r, r.Init()
+	}
+
+	switch {
+	case r.cursor == symbolField:
+		r.symbol, cmd = r.symbol.Update(msg)
+	case r.cursor == amountField:
+		r.amount, cmd = r.amount.Update(msg)
+	case r.cursor == dayField && r.monthly:
+		r.dayOfMonth, cmd = r.dayOfMonth.Update(msg)
+	}
+
+	return r, cmd
+}
+
+func (r RecurringPlansPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case PlansLoadedMsg:
+		r.loaded = true
+		r.err = msg.err
+		if msg.err == nil {
+			items := make([]list.Item, len(msg.plans))
+			for i, plan := range msg.plans {
+				items[i] = plan
+			}
+
+			r.plans.SetItems(items)
+			r.plans.SetSize(r.BaseModel.Width/2, r.BaseModel.Height/2)
+		}
+
+		return r, nil
+
+	case ExecutionsLoadedMsg:
+		if msg.err != nil {
+			return r, func() tea.Msg {
+				return messages.PageSwitchMsg{
+					Page: messages.ErrorPageNumber,
+					Err:  msg.err,
+				}
+			}
+		}
+
+		r.selectedPlan = msg.planID
+		r.executions = msg.executions
+		return r, nil
+
+	case PlanUpdatedMsg:
+		if msg.err != nil {
+			return r, func() tea.Msg {
+				return messages.PageSwitchMsg{
+					Page: messages.ErrorPageNumber,
+					Err:  msg.err,
+				}
+			}
+		}
+
+		// Resuming moves the next run, so the plans are fetched again
+		r.Reload()
+		return r, r.Init()
+
+	case spinner.TickMsg:
+		if !r.loaded {
+			r.spinner, cmd = r.spinner.Update(msg)
+			return r, cmd
+		}
+		return r, nil
+
+	case tea.KeyMsg:
+		if r.creating {
+			return r.updateForm(msg)
+		}
+
+		if r.filtering {
+			switch msg.String() {
+			case "enter":
+				r.filtering = false
+				r.hasFilter = true
+			case "esc":
+				r.filtering = false
+			}
+
+			break
+		}
+
+		switch msg.String() {
+		case "q":
+			return r, func() tea.Msg {
+				return messages.QuitMsg{}
+			}
+
+		case "/":
+			r.filtering = true
+
+		case "n", "N":
+			r.creating = true
+			r.cursor = symbolField
+			r.focusField()
+			return r, textinput.Blink
+
+		case "enter":
+			if plan, ok := r.plans.SelectedItem().(Plan); ok {
+				return r, r.loadExecutions(plan.ID)
+			}
+
+			return r, nil
+
+		case "p", "P":
+			plan, ok := r.plans.SelectedItem().(Plan)
+			if !ok {
+				return r, nil
+			}
+
+			switch plan.Status {
+			case "active":
+				return r, r.changePlan(http.MethodPatch, plan.ID, "pause")
+			case "paused":
+				return r, r.changePlan(http.MethodPatch, plan.ID, "resume")
+			}
+
+			return r, nil
+
+		case "c", "C":
+			if plan, ok := r.plans.SelectedItem().(Plan); ok {
+				return r, r.changePlan(http.MethodDelete, plan.ID, "")
+			}
+
+			return r, nil
+
+		case "r", "R":
+			r.Reload()
+			return r, r.Init()
+
+		case "esc":
+			if !r.hasFilter {
+				return r, func() tea.Msg {
+					return messages.SmartPageSwitchMsg{
+						Page: messages.ProfilePageNumber,
+					}
+				}
+			}
+
+			r.hasFilter = false
+		}
+	}
+
+	if r.loaded && r.err == nil {
+		r.plans, cmd = r.plans.Update(msg)
+	}
+
+	return r, cmd
+}
+
+func (r RecurringPlansPage) View() string {
+	cyan := lipgloss.Color("#00FFFF")
+	purple := lipgloss.Color("#A020F0")
+	red := lipgloss.Color("#D30000")
+	gray := lipgloss.Color("#626262")
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(cyan).
+		Bold(true).
+		Padding(0, 2).
+		MarginBottom(1).
+		Align(lipgloss.Center)
+	header := "\n" + headerStyle.Render(r.titleBar) + "\n\n"
+	header = lipgloss.PlaceHorizontal(r.BaseModel.Width, lipgloss.Center, header)
+
+	if r.creating {
+		return header + r.renderForm()
+	}
+
+	if !r.loaded {
+		return lipgloss.Place(r.BaseModel.Width, r.BaseModel.Height, lipgloss.Center, lipgloss.Center, r.spinner.View())
+	}
+
+	if r.err != nil {
+		errorMsg := lipgloss.NewStyle().
+			Foreground(red).
+			Padding(1, 2).
+			Render(fmt.Sprintf("Error loading plans: %v", r.err))
+		help := lipgloss.NewStyle().
+			Foreground(gray).
+			Render("esc: back • r: retry • q: quit")
+		content := lipgloss.JoinVertical(lipgloss.Left, errorMsg, "", help)
+		return header + content
+	}
+
+	if len(r.plans.Items()) == 0 {
+		msg := lipgloss.NewStyle().
+			Padding(1, 1).
+			Render("No recurring plans yet.\nPress n to buy the same amount of a stock every week or month.")
+		centerContent := lipgloss.Place(r.BaseModel.Width, r.BaseModel.Height-6, lipgloss.Center, lipgloss.Center, msg)
+		return header + centerContent
+	}
+
+	listView := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(purple).
+		Padding(0, 1).
+		Render(r.plans.View())
+
+	content := listView
+	if plan, ok := r.plans.SelectedItem().(Plan); ok && plan.ID == r.selectedPlan {
+		content = lipgloss.JoinHorizontal(lipgloss.Top, listView, "  ", r.renderExecutions())
+	}
+
+	centered := lipgloss.Place(
+		r.BaseModel.Width,
+		r.BaseModel.Height-6,
+		lipgloss.Center,
+		lipgloss.Center,
+		content,
+	)
+
+	return header + centered
+}
+
+func (r RecurringPlansPage) renderExecutions() string {
+	lines := []string{labelStyle.Render("History")}
+	if len(r.executions) == 0 {
+		lines = append(lines, helpStyle.Render("The plan hasn't run yet"))
+	}
+
+	for _, execution := range r.executions {
+		line := fmt.Sprintf("%s  $%.2f  %s", execution.ExecutedAt.Local().Format("2006-01-02 15:04"), execution.Notional, execution.Status)
+		if execution.Error != "" {
+			line += "  " + errorStyle.Render(execution.Error)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#666666")).
+		Padding(0, 1).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (r RecurringPlansPage) renderForm() string {
+	frequency := "WEEKLY"
+	if r.monthly {
+		frequency = "MONTHLY"
+	}
+
+	day := ""
+	if r.monthly {
+		day = r.dayOfMonth.View()
+	} else {
+		day = r.renderSlider(strings.ToUpper(weekdays[r.weekdayIdx]), r.cursor == dayField)
+	}
+
+	fields := []string{
+		r.renderField("Symbol", r.symbol.View(), r.cursor == symbolField),
+		r.renderField("Amount", r.amount.View(), r.cursor == amountField),
+		r.renderField("Frequency", r.renderSlider(frequency, r.cursor == frequencyField), r.cursor == frequencyField),
+		r.renderField("Day", day, r.cursor == dayField),
+	}
+
+	content := lipgloss.JoinVertical(lipgloss.Center, fields...)
+	if r.formErr != "" {
+		content = lipgloss.JoinVertical(lipgloss.Center, content, "", errorStyle.Render("❌ "+r.formErr))
+	}
+
+	help := helpStyle.Render("ctrl+j/ctrl+k/↑/↓: navigate • h/l/←/→: change option • enter: create • esc: cancel")
+	content = lipgloss.JoinVertical(lipgloss.Center, content, "", help)
+
+	return lipgloss.Place(r.BaseModel.Width, r.BaseModel.Height-6, lipgloss.Center, lipgloss.Center, content)
+}
+
+func (r RecurringPlansPage) renderField(label, value string, focused bool) string {
+	styledLabel := labelStyle.Render(label)
+
+	fieldStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFFFF")).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#666666")).
+		Padding(0, 1).
+		Width(32).
+		Align(lipgloss.Center)
+
+	if focused {
+		fieldStyle = fieldStyle.
+			Foreground(lipgloss.Color("#00FFFF")).
+			Background(lipgloss.Color("#2a2a4e")).
+			BorderForeground(lipgloss.Color("#00FFFF"))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Center, styledLabel, fieldStyle.Render(value))
+}
+
+func (r RecurringPlansPage) renderSlider(selected string, focused bool) string {
+	style := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFFFF")).
+		Align(lipgloss.Center)
+
+	if focused {
+		style = style.Foreground(lipgloss.Color("#00FFFF")).Bold(true)
+	}
+
+	return style.Render("◀ " + selected + " ▶")
+}
+
+func (r *RecurringPlansPage) Reload() {
+	r.loaded = false
+	r.err = nil
+	r.plans.SetItems([]list.Item{})
+	r.executions = nil
+	r.selectedPlan = ""
+	r.Reloaded = true
+}
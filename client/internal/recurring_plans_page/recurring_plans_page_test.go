@@ -0,0 +1,92 @@
+package recurringplanspage
+
+import (
+	"net/http"
+	"testing"
+
+	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestPage() RecurringPlansPage {
+	p := New(&http.Client{}, &basemodel.TokenStore{})
+	p.BaseModel.Width = 120
+	p.BaseModel.Height = 40
+	return p
+}
+
+func TestPlan_Title(t *testing.T) {
+	weekly := Plan{Symbol: "VOO", Notional: 50, Frequency: "weekly", Weekday: "monday"}
+	if title := weekly.Title(); title != "$50.00 of VOO every Monday" {
+		t.Fatalf("unexpected title %q", title)
+	}
+
+	monthly := Plan{Symbol: "VOO", Notional: 100, Frequency: "monthly", DayOfMonth: 1}
+	if title := monthly.Title(); title != "$100.00 of VOO every month on day 1" {
+		t.Fatalf("unexpected title %q", title)
+	}
+}
+
+func TestCreatePlan_Validation(t *testing.T) {
+	p := newTestPage()
+
+	if err := p.createPlan(); err == nil {
+		t.Fatal("expected an error without a symbol")
+	}
+
+	p.symbol.SetValue("voo")
+	p.amount.SetValue("0.5")
+	if err := p.createPlan(); err == nil {
+		t.Fatal("expected an error for an amount below $1")
+	}
+
+	p.amount.SetValue("50")
+	p.monthly = true
+	p.dayOfMonth.SetValue("32")
+	if err := p.createPlan(); err == nil {
+		t.Fatal("expected an error for an invalid day of the month")
+	}
+}
+
+func TestUpdate_Form(t *testing.T) {
+	p := newTestPage()
+
+	model, _ := p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	p = model.(RecurringPlansPage)
+	if !p.creating {
+		t.Fatal("expected the form to be opened")
+	}
+
+	p.cursor = frequencyField
+	model, _ = p.Update(tea.KeyMsg{Type: tea.KeyRight})
+	p = model.(RecurringPlansPage)
+	if !p.monthly {
+		t.Fatal("expected the frequency to change to monthly")
+	}
+
+	model, _ = p.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	p = model.(RecurringPlansPage)
+	if p.creating || p.monthly {
+		t.Fatal("expected the form to be closed and reset")
+	}
+}
+
+func TestUpdate_Loaded(t *testing.T) {
+	p := newTestPage()
+
+	model, _ := p.Update(PlansLoadedMsg{plans: []Plan{
+		{ID: "1", Symbol: "VOO", Notional: 50, Frequency: "weekly", Weekday: "monday", Status: "active"},
+	}})
+	p = model.(RecurringPlansPage)
+
+	if !p.loaded || len(p.plans.Items()) != 1 {
+		t.Fatal("expected the plans to be loaded")
+	}
+
+	model, _ = p.Update(ExecutionsLoadedMsg{planID: "1", executions: []Execution{{ID: "e", Status: "placed", Notional: 50}}})
+	p = model.(RecurringPlansPage)
+
+	if p.selectedPlan != "1" || len(p.executions) != 1 {
+		t.Fatal("expected the history of the plan to be loaded")
+	}
+}
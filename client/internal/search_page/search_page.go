@@ -28,6 +28,7 @@ type SearchPage struct {
 type Asset struct {
 	Symbol string `json:"symbol"`
 	Name   string `json:"name"`
+	Class  string `json:"class"`
 }
 
 type asset struct {
@@ -35,9 +36,21 @@ type asset struct {
 }
 
 func (c asset) Title() string       { return c.asset.Symbol }
-func (c asset) Description() string { return c.asset.Name }
+func (c asset) Description() string { return c.asset.description() }
 func (c asset) FilterValue() string { return c.asset.Symbol }
 
+// description marks the crypto pairs and the options contracts, so they aren't mistaken for stocks
+func (a Asset) description() string {
+	switch a.Class {
+	case "crypto":
+		return a.Name + " (crypto)"
+	case "us_option":
+		return a.Name + " (option)"
+	}
+
+	return a.Name
+}
+
 type itemMsg struct {
 	items []list.Item
 }
@@ -134,6 +147,17 @@ func (s SearchPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 
+			// A contract is opened in the options chain of its stock
+			if symbol := s.suggestions.SelectedItem().(asset).asset.Symbol; messages.IsOption(symbol) {
+				return s, func() tea.Msg {
+					return messages.PageSwitchMsg{
+						Page:    messages.OptionsChainPageNumber,
+						Company: company,
+						Symbol:  messages.Underlying(symbol),
+					}
+				}
+			}
+
 			return s, func() tea.Msg {
 				return messages.PageSwitchMsg{
 					Page:    messages.CompanyPageNumber,
@@ -213,7 +237,7 @@ func (s SearchPage) View() string {
 
 func (s SearchPage) GetCompanyInfo() (*messages.CompanyInfo, error) {
 	item := s.suggestions.SelectedItem().(asset)
-	body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/company-information/"+item.asset.Symbol, nil, s.BaseModel.Client, s.BaseModel.TokenStore)
+	body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/company-information/"+messages.PathSymbol(messages.Underlying(item.asset.Symbol)), nil, s.BaseModel.Client, s.BaseModel.TokenStore)
 	if err != nil {
 		return nil, err
 	}
@@ -9,7 +9,9 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/Phantomvv1/KayTrade/client/internal/assets"
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/estimation"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
 	"github.com/Phantomvv1/KayTrade/client/internal/requests"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -31,6 +33,30 @@ type SellPage struct {
 	totalFields     int
 	err             string
 	success         string
+	estimating      bool
+	estimate        *estimation.Estimate
+	order           map[string]any
+	notional        bool
+	asset           *assets.Asset
+	quote           *assets.Quote
+	account         *account
+}
+
+// account is the part of the trading details that decides if the account can sell short
+type account struct {
+	Multiplier      string `json:"multiplier"`
+	ShortingEnabled bool   `json:"shorting_enabled"`
+	MarginCall      bool   `json:"margin_call"`
+}
+
+type accountMsg struct {
+	account *account
+	err     error
+}
+
+func (a account) margin() bool {
+	multiplier, _ := strconv.ParseFloat(a.Multiplier, 64)
+	return multiplier > 1
 }
 
 var (
@@ -68,24 +94,149 @@ func NewSellPage(client *http.Client, tokenStore *basemodel.TokenStore) SellPage
 		BaseModel:       basemodel.BaseModel{Client: client, TokenStore: tokenStore},
 		quantity:        quantity,
 		side:            "sell",
-		purchaseType:    []string{"market", "limit", "stop", "stop_limit", "trailing_stop"},
+		purchaseType:    stockPurchaseTypes,
 		purchaseTypeIdx: 0,
-		timeInForce:     []string{"day", "gtc", "opg", "cls", "ioc", "fok"},
+		timeInForce:     stockTimeInForce,
 		timeInForceIdx:  0,
 		totalFields:     3,
 		cursor:          0,
 	}
 }
 
+// The order types and the times in force Alpaca accepts for crypto and options
+var (
+	cryptoPurchaseTypes = []string{"market"}
+	cryptoTimeInForce   = []string{"gtc", "ioc"}
+	optionPurchaseTypes = []string{"market", "limit", "stop", "stop_limit"}
+	optionTimeInForce   = []string{"day", "gtc"}
+	stockPurchaseTypes  = []string{"market", "limit", "stop", "stop_limit", "trailing_stop"}
+	stockTimeInForce    = []string{"day", "gtc", "opg", "cls", "ioc", "fok"}
+)
+
+// SetSymbol changes the symbol of the order along with the options it has
+func (s *SellPage) SetSymbol(symbol string) {
+	s.Symbol = symbol
+	s.purchaseType = stockPurchaseTypes
+	s.timeInForce = stockTimeInForce
+	if messages.IsCrypto(symbol) {
+		s.purchaseType = cryptoPurchaseTypes
+		s.timeInForce = cryptoTimeInForce
+	} else if messages.IsOption(symbol) {
+		s.purchaseType = optionPurchaseTypes
+		s.timeInForce = optionTimeInForce
+	}
+
+	s.purchaseTypeIdx = 0
+	s.timeInForceIdx = 0
+	s.cursor = 0
+	s.setNotional(false)
+	s.asset = nil
+	s.quote = nil
+	s.MaxQuantity = 0
+}
+
+// shortSale tells if the order opens a short position, which is the case for the stocks that aren't held
+func (s SellPage) shortSale() bool {
+	return s.MaxQuantity == 0 && s.Symbol != "" && !messages.IsCrypto(s.Symbol) && !messages.IsOption(s.Symbol)
+}
+
+// setNotional switches between selling a number of shares and a dollar amount. A dollar amount
+// can only be sold with a market order, which for stocks also has to be a day order.
+func (s *SellPage) setNotional(notional bool) {
+	s.notional = notional
+	if !notional {
+		s.quantity.Placeholder = "Quantity"
+		return
+	}
+
+	s.quantity.Placeholder = "Amount in $"
+	for i, purchaseType := range s.purchaseType {
+		if purchaseType == "market" {
+			s.purchaseTypeIdx = i
+		}
+	}
+
+	if !messages.IsCrypto(s.Symbol) {
+		for i, timeInForce := range s.timeInForce {
+			if timeInForce == "day" {
+				s.timeInForceIdx = i
+			}
+		}
+	}
+}
+
 func (s SellPage) Init() tea.Cmd {
-	return textinput.Blink
+	if s.Symbol == "" || messages.IsOption(s.Symbol) {
+		return textinput.Blink
+	}
+
+	if s.shortSale() {
+		return tea.Batch(textinput.Blink, assets.Fetch(s.Symbol, s.BaseModel.Client, s.BaseModel.TokenStore), s.fetchAccount)
+	}
+
+	return tea.Batch(textinput.Blink, assets.Fetch(s.Symbol, s.BaseModel.Client, s.BaseModel.TokenStore))
+}
+
+func (s SellPage) fetchAccount() tea.Msg {
+	body, err := requests.MakeRequest(http.MethodGet, requests.BaseURL+"/users/trading-details", nil, s.BaseModel.Client, s.BaseModel.TokenStore)
+	if err != nil {
+		return accountMsg{err: err}
+	}
+
+	acc := account{}
+	if err := json.Unmarshal(body, &acc); err != nil {
+		return accountMsg{err: fmt.Errorf("failed to parse the trading details: %v", err)}
+	}
+
+	return accountMsg{account: &acc}
 }
 
 func (s SellPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
+	case estimation.EstimateMsg:
+		s.estimating = false
+		if msg.Err != nil {
+			s.err = msg.Err.Error()
+			return s, nil
+		}
+
+		s.estimate = msg.Estimate
+		return s, nil
+
+	case assets.InfoMsg:
+		// Without the asset the server is left to check the order
+		if msg.Symbol == s.Symbol && msg.Err == nil {
+			s.asset = msg.Asset
+			s.quote = msg.Quote
+		}
+
+		return s, nil
+
+	case accountMsg:
+		// Without the account the server is left to check the short sale
+		if msg.err == nil {
+			s.account = msg.account
+		}
+
+		return s, nil
+
 	case tea.KeyMsg:
+		if s.estimating {
+			if msg.String() == "ctrl+c" {
+				return s, func() tea.Msg {
+					return messages.QuitMsg{}
+				}
+			}
+
+			return s, nil
+		}
+
+		if s.estimate != nil {
+			return s.updateConfirmation(msg)
+		}
+
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return s, func() tea.Msg {
@@ -150,25 +301,46 @@ func (s SellPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return s, nil
 			}
 
+		case "tab":
+			s.err = ""
+			s.success = ""
+			if messages.IsOption(s.Symbol) {
+				s.err = "Error options can't be traded for a dollar amount"
+				return s, nil
+			}
+
+			if s.shortSale() {
+				s.err = "Error short sales have to be for a whole number of shares"
+				return s, nil
+			}
+
+			s.setNotional(!s.notional)
+			return s, nil
+
 		case "enter":
 			s.err = ""
 			s.success = ""
-			if err := s.submitOrder(); err != nil {
+			order, err := s.buildOrder()
+			if err != nil {
 				s.err = err.Error()
 				return s, nil
-			} else {
-				s.success = "Order submitted successfully!"
 			}
 
-			return s, func() tea.Msg {
-				return messages.ReloadMsg{
-					Page: messages.ProfilePageNumber,
-				}
-			}
+			s.order = order
+			s.estimating = true
+			return s, estimation.Fetch(order, s.BaseModel.Client, s.BaseModel.TokenStore)
 
 		case "esc":
 			s.err = ""
 			s.success = ""
+			if s.shortSale() {
+				return s, func() tea.Msg {
+					return messages.PageSwitchMsg{
+						Page: messages.CompanyPageNumber,
+					}
+				}
+			}
+
 			return s, func() tea.Msg {
 				return messages.SmartPageSwitchMsg{
 					Page: messages.ProfilePageNumber,
@@ -195,7 +367,7 @@ func (s SellPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		default:
 			key := msg.String()
-			if len(key) == 1 {
+			if len(key) == 1 && key != "." && key != "," {
 				if []byte(key)[0] < '0' || []byte(key)[0] > '9' {
 					return s, nil
 				}
@@ -215,6 +387,9 @@ func (s SellPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (s SellPage) View() string {
 	// Build header
 	header := titleStyle.Render(fmt.Sprintf("📈 %s - %s Order", strings.ToUpper(s.Symbol), strings.ToUpper(s.side)))
+	if s.shortSale() {
+		header = titleStyle.Render(fmt.Sprintf("📉 %s - SHORT SELL Order", strings.ToUpper(s.Symbol)))
+	}
 
 	var fields []string
 	idx := 0
@@ -224,7 +399,14 @@ func (s SellPage) View() string {
 	} else {
 		s.quantity.Blur()
 	}
-	fields = append(fields, s.renderField("Quantity", s.quantity.View(), s.cursor == idx, false))
+	if s.notional {
+		fields = append(fields, s.renderField("Amount ($)", s.quantity.View(), s.cursor == idx, false))
+		if shares := s.estimatedShares(); shares != "" {
+			fields = append(fields, helpStyle.Render(shares))
+		}
+	} else {
+		fields = append(fields, s.renderField("Quantity", s.quantity.View(), s.cursor == idx, false))
+	}
 	idx++
 
 	// Purchase Type (slider)
@@ -235,6 +417,10 @@ func (s SellPage) View() string {
 	fields = append(fields, s.renderField("Time In Force", s.renderSlider(s.timeInForce, s.timeInForceIdx, s.cursor == idx), s.cursor == idx, true))
 	idx++
 
+	if s.shortSale() {
+		fields = append(fields, "", s.renderShortInfo())
+	}
+
 	content := lipgloss.JoinVertical(lipgloss.Center, fields...)
 
 	if s.err != "" {
@@ -244,7 +430,20 @@ func (s SellPage) View() string {
 		content = lipgloss.JoinVertical(lipgloss.Center, content, "", successStyle.Render("✓ "+s.success))
 	}
 
-	help := helpStyle.Render("j/k/↑/↓: navigate • h/l/←/→: change slider • enter: submit • esc: back • w: watchlist page • i: information page • q: quit")
+	if s.estimating {
+		content = lipgloss.JoinVertical(lipgloss.Center, content, "", helpStyle.Render("Estimating the order..."))
+	}
+
+	help := helpStyle.Render("j/k/↑/↓: navigate • h/l/←/→: change slider • tab: quantity/amount • enter: review order • esc: back • w: watchlist page • i: information page • q: quit")
+	if s.shortSale() {
+		help = strings.Replace(help, "tab: quantity/amount • ", "", 1)
+	}
+
+	// The estimate replaces the form until the order is confirmed or edited
+	if s.estimate != nil {
+		content = estimation.Render(*s.estimate)
+		help = helpStyle.Render("enter/y: place the order • esc/n: edit the order • ctrl+c: quit")
+	}
 
 	headerHeight := lipgloss.Height(header)
 	contentHeight := lipgloss.Height(content)
@@ -310,6 +509,52 @@ func (s SellPage) renderField(label, value string, focused, slider bool) string
 	return lipgloss.JoinVertical(lipgloss.Center, styledLabel, styledValue)
 }
 
+// renderShortInfo shows if the shares can be borrowed and if the account can sell them short
+func (s SellPage) renderShortInfo() string {
+	borrow := "checking the borrow availability..."
+	if s.asset != nil {
+		borrow = s.asset.Borrow()
+	}
+
+	style := successStyle
+	if s.asset != nil && (!s.asset.Shortable || !s.asset.EasyToBorrow) {
+		style = errorStyle
+	}
+
+	lines := []string{style.Render("Borrow: " + borrow)}
+	if s.account != nil {
+		switch {
+		case !s.account.margin() || !s.account.ShortingEnabled:
+			lines = append(lines, errorStyle.Render("Shorting needs a margin account with shorting enabled"))
+		case s.account.MarginCall:
+			lines = append(lines, errorStyle.Render("⚠ The account is in a margin call"))
+		default:
+			lines = append(lines, successStyle.Render(fmt.Sprintf("Margin account (%sx)", s.account.Multiplier)))
+		}
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Center, lines...)
+}
+
+// estimatedShares is the number of shares the amount sells at the latest quote
+func (s SellPage) estimatedShares() string {
+	if s.quote == nil {
+		return ""
+	}
+
+	amount, err := assets.ParseAmount(s.quantity.Value())
+	if err != nil {
+		return ""
+	}
+
+	price := s.quote.Price(s.side)
+	if price <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("≈ %s shares at $%.2f", strconv.FormatFloat(assets.EstimatedShares(amount, price), 'f', -1, 64), price)
+}
+
 func (s SellPage) renderSlider(options []string, selectedIdx int, focused bool) string {
 	selected := strings.ToUpper(options[selectedIdx])
 
@@ -325,7 +570,48 @@ func (s SellPage) renderSlider(options []string, selectedIdx int, focused bool)
 		Render("◀ " + selected + " ▶")
 }
 
+// updateConfirmation handles the keys while the estimate of the order is shown
+func (s SellPage) updateConfirmation(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return s, func() tea.Msg {
+			return messages.QuitMsg{}
+		}
+
+	case "enter", "y":
+		order := s.order
+		s.estimate = nil
+		s.order = nil
+		if err := s.placeOrder(order); err != nil {
+			s.err = err.Error()
+			return s, nil
+		}
+
+		s.success = "Order submitted successfully!"
+		return s, func() tea.Msg {
+			return messages.ReloadMsg{
+				Page: messages.ProfilePageNumber,
+			}
+		}
+
+	case "esc", "n":
+		s.estimate = nil
+		s.order = nil
+	}
+
+	return s, nil
+}
+
 func (s *SellPage) submitOrder() error {
+	data, err := s.buildOrder()
+	if err != nil {
+		return err
+	}
+
+	return s.placeOrder(data)
+}
+
+func (s *SellPage) buildOrder() (map[string]any, error) {
 	data := make(map[string]any)
 
 	data["symbol"] = s.Symbol
@@ -333,46 +619,111 @@ func (s *SellPage) submitOrder() error {
 	data["type"] = s.purchaseType[s.purchaseTypeIdx]
 	data["time_in_force"] = s.timeInForce[s.timeInForceIdx]
 
+	if s.shortSale() {
+		return s.buildShortSale(data)
+	}
+
+	if s.notional {
+		return s.buildNotional(data)
+	}
+
 	qty := strings.TrimSpace(s.quantity.Value())
 	if qty == "" {
-		return fmt.Errorf("quantity is required")
+		return nil, fmt.Errorf("quantity is required")
 	}
 
 	qty = strings.ReplaceAll(qty, ",", ".")
 	dotCount := strings.Count(qty, ".")
 	if dotCount > 1 {
-		return errors.New("Error invalid number")
+		return nil, errors.New("Error invalid number")
 	}
 
-	if s.purchaseType[s.purchaseTypeIdx] == "market" && s.timeInForce[s.timeInForceIdx] == "day" {
+	// Options contracts are always sold in whole units and stocks only in fractions if they're fractionable
+	if assets.Fractional(s.asset, s.Symbol, s.purchaseType[s.purchaseTypeIdx], s.timeInForce[s.timeInForceIdx]) {
 		// can be a float
 		quantity, err := strconv.ParseFloat(qty, 64)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if quantity > s.MaxQuantity {
-			return errors.New("Error the ammount of stock you are trying to sell is bigger than what you have")
+			return nil, errors.New("Error the ammount of stock you are trying to sell is bigger than what you have")
 		}
 
 		data["qty"] = quantity
 	} else {
 		if dotCount > 0 {
-			return errors.New("Error quantity must be an integer")
+			if s.asset != nil && !s.asset.Fractionable && !messages.IsOption(s.Symbol) {
+				return nil, errors.New("Error " + s.Symbol + " can't be traded in fractions")
+			}
+
+			return nil, errors.New("Error quantity must be an integer")
 		}
 
 		quantity, err := strconv.Atoi(qty)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if quantity > int(s.MaxQuantity) {
-			return errors.New("Error the ammount of stock you are trying to sell is bigger than what you have")
+			return nil, errors.New("Error the ammount of stock you are trying to sell is bigger than what you have")
 		}
 
 		data["qty"] = qty
 	}
 
+	return data, nil
+}
+
+// buildShortSale completes the order that opens a short position. The server checks it again with the
+// latest asset and account, in case they weren't known yet.
+func (s *SellPage) buildShortSale(data map[string]any) (map[string]any, error) {
+	if err := assets.ValidateShortSale(s.asset, s.Symbol); err != nil {
+		return nil, err
+	}
+
+	if s.account != nil {
+		if !s.account.margin() || !s.account.ShortingEnabled {
+			return nil, errors.New("Error short selling needs a margin account with shorting enabled")
+		}
+
+		if s.account.MarginCall {
+			return nil, errors.New("Error short sales aren't allowed while the account is in a margin call")
+		}
+	}
+
+	qty := strings.TrimSpace(s.quantity.Value())
+	quantity, err := strconv.Atoi(qty)
+	if err != nil || quantity <= 0 {
+		return nil, errors.New("Error short sales have to be for a whole number of shares")
+	}
+
+	data["qty"] = qty
+	data["position_intent"] = "sell_to_open"
+	return data, nil
+}
+
+// buildNotional completes the order for a dollar amount. The amount is compared to the position
+// at the latest quote, when there is one.
+func (s *SellPage) buildNotional(data map[string]any) (map[string]any, error) {
+	if err := assets.ValidateNotional(s.asset, s.Symbol, s.purchaseType[s.purchaseTypeIdx], s.timeInForce[s.timeInForceIdx]); err != nil {
+		return nil, err
+	}
+
+	amount, err := assets.ParseAmount(s.quantity.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	if s.quote != nil && s.MaxQuantity > 0 && assets.EstimatedShares(amount, s.quote.Price(s.side)) > s.MaxQuantity {
+		return nil, errors.New("Error the ammount of stock you are trying to sell is bigger than what you have")
+	}
+
+	data["notional"] = strconv.FormatFloat(amount, 'f', 2, 64)
+	return data, nil
+}
+
+func (s *SellPage) placeOrder(data map[string]any) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("failed to encode request: %v", err)
@@ -388,9 +739,13 @@ func (s *SellPage) submitOrder() error {
 
 func (s *SellPage) Reload() {
 	s.cursor = 0
+	s.setNotional(false)
 	s.quantity.SetValue("1")
 	s.purchaseTypeIdx = 0
 	s.timeInForceIdx = 0
 	s.err = ""
 	s.success = ""
+	s.estimating = false
+	s.estimate = nil
+	s.order = nil
 }
@@ -2,9 +2,12 @@ package sellpage
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/Phantomvv1/KayTrade/client/internal/assets"
 	basemodel "github.com/Phantomvv1/KayTrade/client/internal/base_model"
+	"github.com/Phantomvv1/KayTrade/client/internal/estimation"
 	"github.com/Phantomvv1/KayTrade/client/internal/messages"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -209,3 +212,106 @@ func TestSellPage_SubmitOverflowLimitStyle(t *testing.T) {
 		t.Error("expected overflow error")
 	}
 }
+
+func TestSellPage_ConfirmationIgnoresFormKeys(t *testing.T) {
+	s := newSellPage()
+	s.Symbol = "AAPL"
+	s.MaxQuantity = 5
+
+	m, cmd := s.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	p := m.(SellPage)
+
+	if cmd == nil || !p.estimating {
+		t.Fatal("expected an estimate to be requested")
+	}
+
+	m, _ = p.Update(estimation.EstimateMsg{Estimate: &estimation.Estimate{Symbol: "AAPL", Side: "sell", Qty: 1}})
+	p = m.(SellPage)
+
+	m, cmd = p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	p = m.(SellPage)
+
+	if cmd != nil || p.cursor != 0 || p.estimate == nil {
+		t.Fatal("expected the form keys to be ignored while confirming")
+	}
+
+	if !strings.Contains(p.View(), "place the order") {
+		t.Fatal("expected the confirmation to be shown")
+	}
+
+	p.Reload()
+	if p.estimate != nil || p.order != nil || p.estimating {
+		t.Fatal("expected the estimate to be cleared on reload")
+	}
+}
+
+func TestSellPage_NotionalOrder(t *testing.T) {
+	s := newSellPage()
+	s.SetSymbol("AAPL")
+	s.MaxQuantity = 1
+
+	m, _ := s.Update(tea.KeyMsg{Type: tea.KeyTab})
+	s = m.(SellPage)
+	if !s.notional {
+		t.Fatal("expected the amount to be entered")
+	}
+
+	m, _ = s.Update(assets.InfoMsg{Symbol: "AAPL", Asset: &assets.Asset{Symbol: "AAPL", Fractionable: true}, Quote: &assets.Quote{Bid: 100, Ask: 101}})
+	s = m.(SellPage)
+
+	s.quantity.SetValue("50")
+	order, err := s.buildOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order["notional"] != "50.00" || order["qty"] != nil {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	s.quantity.SetValue("150")
+	if _, err := s.buildOrder(); err == nil {
+		t.Fatal("expected an amount bigger than the position to be rejected")
+	}
+}
+
+func TestSellPage_ShortSale(t *testing.T) {
+	s := newSellPage()
+	s.SetSymbol("TSLA")
+
+	if !s.shortSale() || !strings.Contains(s.View(), "SHORT SELL") {
+		t.Fatal("expected a short sale without a position")
+	}
+
+	m, _ := s.Update(assets.InfoMsg{Symbol: "TSLA", Asset: &assets.Asset{Symbol: "TSLA", Shortable: true, EasyToBorrow: true}})
+	s = m.(SellPage)
+	m, _ = s.Update(accountMsg{account: &account{Multiplier: "2", ShortingEnabled: true}})
+	s = m.(SellPage)
+
+	s.quantity.SetValue("3")
+	order, err := s.buildOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order["qty"] != "3" || order["position_intent"] != "sell_to_open" {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	s.quantity.SetValue("1.5")
+	if _, err := s.buildOrder(); err == nil {
+		t.Fatal("expected a fractional short sale to be rejected")
+	}
+
+	s.quantity.SetValue("3")
+	s.asset.EasyToBorrow = false
+	if _, err := s.buildOrder(); err == nil {
+		t.Fatal("expected a hard to borrow stock to be rejected")
+	}
+
+	s.asset.EasyToBorrow = true
+	s.account.Multiplier = "1"
+	if _, err := s.buildOrder(); err == nil {
+		t.Fatal("expected a cash account to be rejected")
+	}
+}
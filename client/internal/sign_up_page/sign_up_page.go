@@ -126,6 +126,7 @@ type SignUpPage struct {
 	typing               bool
 	fundingCursor        int
 	fundingSelected      map[int]bool
+	crypto               bool
 	err                  string
 	success              string
 	showingPassword      bool
@@ -478,6 +479,11 @@ func (s SignUpPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 
 			case "enter":
+				if s.currentPage == identityPage && s.cursor == 9 {
+					s.crypto = !s.crypto
+					return s, nil
+				}
+
 				if s.currentPage == identityPage && s.cursor == 8 {
 					if s.fundingSelected[s.fundingCursor] {
 						delete(s.fundingSelected, s.fundingCursor)
@@ -560,7 +566,7 @@ func (s *SignUpPage) fieldCount() int {
 	case contactPage:
 		return 8
 	case identityPage:
-		return 9
+		return 10
 	case documentsPage:
 		return 4
 	case trustedContactPage:
@@ -862,6 +868,11 @@ func (s SignUpPage) submit() error {
 
 	s.accountInfo.Identity.FundingSource = sources
 
+	s.accountInfo.EnabledAssets = []string{"us_equity"}
+	if s.crypto {
+		s.accountInfo.EnabledAssets = append(s.accountInfo.EnabledAssets, "crypto")
+	}
+
 	if s.documentInputs.documentType.Value() != "" {
 		s.accountInfo.Documents = []Document{
 			{
@@ -927,6 +938,7 @@ func (s SignUpPage) renderCurrentPageFields(fields *[]string) {
 		s.addInput(fields, "Tax Residence", s.identityInputs.countryOfTaxResidence, 7)
 
 		*fields = append(*fields, s.renderFundingSources())
+		*fields = append(*fields, s.renderCryptoToggle())
 
 	case documentsPage:
 		s.addInput(fields, "Doc Type", s.documentInputs.documentType, 0)
@@ -968,6 +980,38 @@ func (s SignUpPage) addInput(fields *[]string, label string, input textinput.Mod
 		renderInput(label, input, s.cursor == index, s.typing),
 	)
 }
+
+// renderCryptoToggle shows whether the account will be able to trade crypto. Enabling it signs the crypto agreement.
+func (s SignUpPage) renderCryptoToggle() string {
+	style := fundingIdleStyle
+	if s.typing {
+		if s.crypto {
+			style = fundingSelectedStyle
+		}
+		if s.cursor == 9 {
+			style = fundingFocusedStyle
+		}
+	}
+
+	value := "[ ] disabled"
+	if s.crypto {
+		value = "[x] enabled"
+	}
+
+	prefix := "  "
+	if s.cursor == 9 {
+		prefix = " ▸ "
+	}
+
+	block := lipgloss.JoinVertical(
+		lipgloss.Center,
+		labelStyle.Render("Crypto Trading (enter to toggle)"),
+		style.Render(prefix+value),
+	)
+
+	return formRowStyle.Render(block)
+}
+
 func (s SignUpPage) renderFundingSources() string {
 	var rows []string
 
@@ -176,6 +176,26 @@ func TestFundingSelection_Toggle(t *testing.T) {
 	}
 }
 
+func TestCryptoTrading_Toggle(t *testing.T) {
+	p := newTestPage()
+	p.currentPage = identityPage
+	p.cursor = 9
+
+	msg := tea.KeyMsg{Type: tea.KeyEnter}
+
+	m, _ := p.Update(msg)
+	np := m.(SignUpPage)
+
+	if !np.crypto {
+		t.Fatal("expected crypto trading to be enabled")
+	}
+
+	m, _ = np.Update(msg)
+	if m.(SignUpPage).crypto {
+		t.Fatal("expected crypto trading to be disabled")
+	}
+}
+
 func TestTogglePasswordVisibility(t *testing.T) {
 	p := newTestPage()
 
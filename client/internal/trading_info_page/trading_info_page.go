@@ -56,7 +56,8 @@ var (
 func NewTradingInfoPage() TradingInfoPage {
 	return TradingInfoPage{
 		qunatityExplanation: `For equities, the number of shares to trade. Can be fractionable for only market and day order types.
-For Fixed Income securities, qty represents the order size in par value (face value). For example, to place an order for 1 bond with a face value of $1,000, provide a qty of 1000.`,
+For Fixed Income securities, qty represents the order size in par value (face value). For example, to place an order for 1 bond with a face value of $1,000, provide a qty of 1000.
+Press tab to enter a dollar amount (notional) instead. The amount is spent at the market price, so it only works for market orders of fractionable assets, which for equities also have to be day orders.`,
 		timeInForceExplanation: `day: A day order is eligible for execution only on the day it is live. By default, the order is only valid during Regular Trading Hours (9:30am - 4:00pm ET). If unfilled after the closing auction, it is automatically canceled. If submitted after the close, it is queued and submitted the following trading day. However, if marked as eligible for extended hours, the order can also execute during supported extended hours.
 
 gtc: The order is good until canceled. Non-marketable GTC limit orders are subject to price adjustments to offset corporate actions affecting the issue. We do not currently support Do Not Reduce(DNR) orders to opt out of such price adjustments.
@@ -271,6 +271,31 @@ func (t ViewTransfersPage) View() string {
 	return header + centeredList
 }
 
+// Updates the status of a transfer in place when the server pushes a status change for it
+func (t *ViewTransfersPage) ApplyEvent(msg messages.AccountEventMsg) {
+	if msg.Transfer == nil || !t.loaded {
+		return
+	}
+
+	for i, item := range t.transfers.Items() {
+		transfer := item.(Transfer)
+		if transfer.ID != msg.Transfer.TransferID {
+			continue
+		}
+
+		transfer.Status = msg.Transfer.StatusTo
+		if at, err := time.Parse(time.RFC3339, msg.Transfer.At); err == nil {
+			transfer.UpdatedAt = at
+		}
+
+		t.transfers.SetItem(i, transfer)
+		return
+	}
+
+	// We don't know about this transfer yet, so the list is fetched again the next time the page is opened
+	t.Reload()
+}
+
 func (t *ViewTransfersPage) Reload() {
 	t.loaded = false
 	t.err = nil
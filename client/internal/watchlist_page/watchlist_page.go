@@ -71,6 +71,7 @@ func NewWatchlistPage(client *http.Client, tokenStore *basemodel.TokenStore) Wat
 			key.NewBinding(key.WithKeys("d", "D"), key.WithHelp("d", "remove all companies")),
 			key.NewBinding(key.WithKeys("p", "P"), key.WithHelp("p", "profile page")),
 			key.NewBinding(key.WithKeys("b", "B"), key.WithHelp("b", "bank relationship page")),
+			key.NewBinding(key.WithKeys("o", "O"), key.WithHelp("o", "basket order")),
 		}
 	}
 
@@ -263,6 +264,23 @@ func (w WatchlistPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				}
 
+			case "o", "O":
+				if len(w.companies.Items()) == 0 {
+					return w, nil
+				}
+
+				var symbols []string
+				for _, item := range w.companies.Items() {
+					symbols = append(symbols, item.(companyItem).company.Symbol)
+				}
+
+				return w, func() tea.Msg {
+					return messages.PageSwitchMsg{
+						Page:    messages.BasketPageNumber,
+						Symbols: symbols,
+					}
+				}
+
 			case "q", "ctrl+c":
 				return w, func() tea.Msg {
 					return messages.QuitMsg{}
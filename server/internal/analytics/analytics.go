@@ -0,0 +1,303 @@
+package analytics
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/auth"
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultBenchmark = "SPY"
+
+var periodPattern = regexp.MustCompile(`^[1-9][0-9]*[DWMA]$`)
+
+// The timeframes of the portfolio history with the number of periods they have in a year
+// and the timeframe of the bars of the benchmark that match them
+var timeframes = map[string]struct {
+	periodsPerYear float64
+	bars           marketdata.TimeFrame
+}{
+	"1Min":  {tradingDays * 390, "1T"},
+	"5Min":  {tradingDays * 78, "5T"},
+	"15Min": {tradingDays * 26, "15T"},
+	"1H":    {tradingDays * 6.5, "1H"},
+	"1D":    {tradingDays, "1D"},
+}
+
+type history struct {
+	Timestamp []int64    `json:"timestamp"`
+	Equity    []*float64 `json:"equity"`
+}
+
+type HistoryPoint struct {
+	Time             time.Time `json:"time"`
+	Equity           float64   `json:"equity"`
+	Return           float64   `json:"return"`
+	CumulativeReturn float64   `json:"cumulative_return"`
+}
+
+type Benchmark struct {
+	Symbol string `json:"symbol"`
+	Metrics
+	ExcessReturn float64  `json:"excess_return"`
+	Beta         *float64 `json:"beta"`
+	Correlation  *float64 `json:"correlation"`
+}
+
+type Report struct {
+	Timeframe           string    `json:"timeframe"`
+	Start               time.Time `json:"start"`
+	End                 time.Time `json:"end"`
+	StartingEquity      float64   `json:"starting_equity"`
+	EndingEquity        float64   `json:"ending_equity"`
+	NetDeposits         float64   `json:"net_deposits"`
+	MoneyWeightedReturn *float64  `json:"money_weighted_return"`
+	RiskFreeRate        float64   `json:"risk_free_rate"`
+	Metrics
+	Benchmark *Benchmark     `json:"benchmark"`
+	History   []HistoryPoint `json:"history"`
+	returns   []Return
+}
+
+// Analyze measures the performance of the equity of the account, leaving out the money that was moved in or out of it
+func Analyze(points []Point, flows []Flow, timeframe string, riskFreeRate float64) Report {
+	points = trim(points)
+	report := Report{Timeframe: timeframe, RiskFreeRate: riskFreeRate, History: []HistoryPoint{}}
+	if len(points) == 0 {
+		return report
+	}
+
+	first, last := points[0], points[len(points)-1]
+	report.Start = first.Time
+	report.End = last.Time
+	report.StartingEquity = first.Equity
+	report.EndingEquity = last.Equity
+
+	for _, amount := range netFlows(points, flows) {
+		report.NetDeposits += amount
+	}
+
+	report.returns = Returns(points, flows)
+	report.MoneyWeightedReturn = MoneyWeightedReturn(points, flows)
+	report.Metrics = Calculate(report.returns, first.Time, timeframes[timeframe].periodsPerYear, riskFreeRate)
+
+	report.History = append(report.History, HistoryPoint{Time: first.Time, Equity: first.Equity})
+	cumulative := Cumulative(report.returns)
+	equity := make(map[int64]float64, len(points))
+	for _, p := range points {
+		equity[p.Time.Unix()] = p.Equity
+	}
+
+	for i, r := range report.returns {
+		report.History = append(report.History, HistoryPoint{
+			Time:             r.Time,
+			Equity:           equity[r.Time.Unix()],
+			Return:           r.Value,
+			CumulativeReturn: cumulative[i],
+		})
+	}
+
+	return report
+}
+
+// Compare measures the benchmark over the same periods as the account
+func (r *Report) Compare(symbol string, prices []Point) {
+	var inRange []Point
+	for _, p := range prices {
+		if !p.Time.Before(r.Start) && !p.Time.After(r.End) {
+			inRange = append(inRange, p)
+		}
+	}
+
+	returns := Returns(trim(inRange), nil)
+	benchmark := Benchmark{
+		Symbol:  symbol,
+		Metrics: Calculate(returns, r.Start, timeframes[r.Timeframe].periodsPerYear, r.RiskFreeRate),
+	}
+
+	benchmark.ExcessReturn = r.TimeWeightedReturn - benchmark.TimeWeightedReturn
+	benchmark.Beta, benchmark.Correlation = Relative(r.returns, returns)
+
+	r.Benchmark = &benchmark
+}
+
+// normalize puts the daily points on the start of their day in UTC, so the portfolio, the bars and the transfers line up
+func normalize(t time.Time, timeframe string) time.Time {
+	t = t.UTC()
+	if timeframe == "1D" {
+		return t.Truncate(24 * time.Hour)
+	}
+
+	return t
+}
+
+func getHistory(id string, query url.Values) ([]Point, error) {
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		400: "Malformed input",
+		404: "Resource doesn't exist",
+	}
+
+	body, err := SendRequest[history](http.MethodGet, BaseURL+Trading+id+"/account/portfolio/history?"+query.Encode(), nil, errs, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(body.Timestamp))
+	for i, timestamp := range body.Timestamp {
+		if i >= len(body.Equity) || body.Equity[i] == nil {
+			continue
+		}
+
+		points = append(points, Point{
+			Time:   normalize(time.Unix(timestamp, 0), query.Get("timeframe")),
+			Equity: *body.Equity[i],
+		})
+	}
+
+	return points, nil
+}
+
+// getFlows returns the money that was moved in or out of the account by the transfers that went through
+func getFlows(id, timeframe string) ([]Flow, error) {
+	transfers, err := auth.GetTransfers(id)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := make([]Flow, 0, len(transfers))
+	for _, t := range transfers {
+		if t.Status != "COMPLETE" {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(t.Amount, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.Direction == "OUTGOING" {
+			amount = -amount
+		}
+
+		flows = append(flows, Flow{Time: normalize(t.UpdatedAt, timeframe), Amount: amount})
+	}
+
+	return flows, nil
+}
+
+// parseQuery checks the parameters of the portfolio history. Alpaca accepts only 2 of period, start and end.
+func parseQuery(c *gin.Context) (url.Values, error) {
+	query := url.Values{}
+
+	timeframe := c.DefaultQuery("timeframe", "1D")
+	if _, ok := timeframes[timeframe]; !ok {
+		return nil, errors.New("the timeframe should be one of 1Min, 5Min, 15Min, 1H and 1D")
+	}
+	query.Set("timeframe", timeframe)
+
+	period := strings.ToUpper(c.Query("period"))
+	if period != "" {
+		if !periodPattern.MatchString(period) {
+			return nil, errors.New("the period should be a number followed by D, W, M or A")
+		}
+		query.Set("period", period)
+	}
+
+	given := 0
+	for _, name := range []string{"start", "end"} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return nil, errors.New(name + " should be a date in the format YYYY-MM-DD")
+		}
+
+		// The end is inclusive
+		if name == "end" {
+			date = date.AddDate(0, 0, 1)
+		}
+
+		query.Set(name, date.Format(time.RFC3339))
+		given++
+	}
+
+	if period != "" && given == 2 {
+		return nil, errors.New("only 2 of period, start and end can be given at a time")
+	}
+
+	if query.Has("start") && query.Has("end") && query.Get("start") >= query.Get("end") {
+		return nil, errors.New("start can't be after end")
+	}
+
+	return query, nil
+}
+
+func GetAnalytics(c *gin.Context) {
+	id := c.GetString("id")
+
+	query, err := parseQuery(c)
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	timeframe := query.Get("timeframe")
+
+	riskFreeRate := 0.0
+	if value := c.Query("risk_free_rate"); value != "" {
+		riskFreeRate, err = strconv.ParseFloat(value, 64)
+		if err != nil || riskFreeRate < 0 || riskFreeRate > 1 {
+			ErrorExit(c, http.StatusBadRequest, "the risk free rate should be a fraction between 0 and 1", err)
+			return
+		}
+	}
+
+	benchmark := strings.ToUpper(c.DefaultQuery("benchmark", defaultBenchmark))
+
+	points, err := getHistory(id, query)
+	if err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't get the portfolio history of the account", err)
+		return
+	}
+
+	flows, err := getFlows(id, timeframe)
+	if err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't get the transfers of the account", err)
+		return
+	}
+
+	report := Analyze(points, flows, timeframe, riskFreeRate)
+	if benchmark == "NONE" || len(report.History) < 2 {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	// The bars of the last period start at its timestamp, so the end has to be a bit later to include it
+	bars, err := marketdata.GetBars(benchmark, timeframes[timeframe].bars, report.Start, report.End.Add(time.Minute))
+	if err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't get the bars of the benchmark", err)
+		return
+	}
+
+	prices := make([]Point, 0, len(bars))
+	for _, bar := range bars {
+		prices = append(prices, Point{Time: normalize(bar.Time, timeframe), Equity: bar.Close})
+	}
+
+	report.Compare(benchmark, prices)
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,194 @@
+package analytics
+
+import (
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func day(d int) time.Time {
+	return time.Date(2025, time.March, d, 0, 0, 0, 0, time.UTC)
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func series(equity ...float64) []Point {
+	points := make([]Point, len(equity))
+	for i, e := range equity {
+		points[i] = Point{Time: day(i + 1), Equity: e}
+	}
+
+	return points
+}
+
+func TestReturns_LeavesOutDeposits(t *testing.T) {
+	points := series(1000, 1100, 2200)
+	flows := []Flow{{Time: day(3), Amount: 1000}}
+
+	returns := Returns(points, flows)
+	if len(returns) != 2 {
+		t.Fatalf("expected 2 returns, got %d", len(returns))
+	}
+
+	if !near(returns[0].Value, 0.1) || !near(returns[1].Value, 0.1/1.1) {
+		t.Fatalf("unexpected returns %v", returns)
+	}
+
+	cumulative := Cumulative(returns)
+	if !near(cumulative[1], 0.2) {
+		t.Fatalf("expected a time weighted return of 20%%, got %f", cumulative[1])
+	}
+}
+
+func TestReturns_IgnoresFlowsOutsideTheHistory(t *testing.T) {
+	points := series(1000, 1100)
+	flows := []Flow{{Time: day(1), Amount: 1000}, {Time: day(5), Amount: 500}}
+
+	net := netFlows(points, flows)
+	if net[0] != 0 || net[1] != 0 {
+		t.Fatalf("expected no flows inside the history, got %v", net)
+	}
+}
+
+func TestMoneyWeightedReturn(t *testing.T) {
+	withoutFlows := MoneyWeightedReturn(series(1000, 1050, 1100), nil)
+	if withoutFlows == nil || !near(*withoutFlows, 0.1) {
+		t.Fatalf("expected the money weighted return to match the simple return without flows, got %v", withoutFlows)
+	}
+
+	// A deposit right before the account doubles weighs more than the starting equity
+	points := series(1000, 2000, 4000)
+	flows := []Flow{{Time: day(2), Amount: 1000}}
+
+	mwr := MoneyWeightedReturn(points, flows)
+	if mwr == nil {
+		t.Fatal("expected a money weighted return")
+	}
+
+	// 1000 * g + 1000 * g^0.5 = 4000
+	growth := math.Pow((-1+math.Sqrt(17))/2, 2)
+	if !near(*mwr, growth-1) {
+		t.Fatalf("expected %f, got %f", growth-1, *mwr)
+	}
+}
+
+func TestMaxDrawdown(t *testing.T) {
+	returns := Returns(series(100, 120, 90, 60, 100, 130, 117), nil)
+
+	drawdown := MaxDrawdown(returns, day(1))
+	if !near(drawdown.Value, -0.5) {
+		t.Fatalf("expected a drawdown of 50%%, got %f", drawdown.Value)
+	}
+
+	if !drawdown.PeakAt.Equal(day(2)) || !drawdown.TroughAt.Equal(day(4)) || !drawdown.RecoveryAt.Equal(day(6)) {
+		t.Fatalf("unexpected dates %v %v %v", drawdown.PeakAt, drawdown.TroughAt, drawdown.RecoveryAt)
+	}
+
+	none := MaxDrawdown(Returns(series(100, 110, 120), nil), day(1))
+	if none.Value != 0 || none.PeakAt != nil {
+		t.Fatal("expected no drawdown for a rising account")
+	}
+}
+
+func TestDaily_CompoundsIntradayReturns(t *testing.T) {
+	start := day(1).Add(14 * time.Hour)
+	returns := []Return{
+		{Time: start, Value: 0.1},
+		{Time: start.Add(time.Hour), Value: 0.1},
+		{Time: start.Add(24 * time.Hour), Value: -0.05},
+	}
+
+	days := Daily(returns)
+	if len(days) != 2 || days[0].Date != "2025-03-01" || !near(days[0].Return, 0.21) || !near(days[1].Return, -0.05) {
+		t.Fatalf("unexpected days %v", days)
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	returns := Returns(series(100, 102, 99, 103, 101), nil)
+
+	metrics := Calculate(returns, day(1), tradingDays, 0)
+	if !near(metrics.TimeWeightedReturn, 0.01) {
+		t.Fatalf("unexpected time weighted return %f", metrics.TimeWeightedReturn)
+	}
+
+	if metrics.AnnualizedVolatility <= 0 || metrics.SharpeRatio == nil || metrics.SortinoRatio == nil {
+		t.Fatal("expected the volatility and the ratios to be calculated")
+	}
+
+	if *metrics.SortinoRatio <= *metrics.SharpeRatio {
+		t.Fatal("expected the sortino ratio to be higher than the sharpe ratio with few down days")
+	}
+
+	if metrics.BestDay.Date != "2025-03-04" || metrics.WorstDay.Date != "2025-03-03" {
+		t.Fatalf("unexpected best and worst days %v %v", metrics.BestDay, metrics.WorstDay)
+	}
+
+	flat := Calculate(Returns(series(100, 100, 100), nil), day(1), tradingDays, 0)
+	if flat.SharpeRatio != nil || flat.SortinoRatio != nil {
+		t.Fatal("expected no ratios without volatility")
+	}
+}
+
+func TestAnalyze_ComparesToTheBenchmark(t *testing.T) {
+	points := append(series(0), series(100, 110, 99, 118.8)...)
+	points[0].Time = day(1).Add(-24 * time.Hour)
+
+	report := Analyze(points, nil, "1D", 0)
+	if report.StartingEquity != 100 || report.EndingEquity != 118.8 || len(report.History) != 4 {
+		t.Fatalf("expected the history to start once the account had equity, got %+v", report)
+	}
+
+	// The benchmark moves half as much as the account, on the same days
+	report.Compare("SPY", series(400, 420, 399, 438.9, 500))
+	if report.Benchmark == nil {
+		t.Fatal("expected a benchmark")
+	}
+
+	if !near(report.Benchmark.TimeWeightedReturn, 0.09725) {
+		t.Fatalf("expected the benchmark to stop with the account, got %f", report.Benchmark.TimeWeightedReturn)
+	}
+
+	if report.Benchmark.Beta == nil || !near(*report.Benchmark.Beta, 2) || !near(*report.Benchmark.Correlation, 1) {
+		t.Fatalf("unexpected beta and correlation %v %v", report.Benchmark.Beta, report.Benchmark.Correlation)
+	}
+
+	if !near(report.Benchmark.ExcessReturn, report.TimeWeightedReturn-report.Benchmark.TimeWeightedReturn) {
+		t.Fatal("unexpected excess return")
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		valid bool
+	}{
+		{"", true},
+		{"?period=3m&timeframe=1D", true},
+		{"?start=2025-01-01&end=2025-02-01", true},
+		{"?timeframe=2D", false},
+		{"?period=M", false},
+		{"?start=01-01-2025", false},
+		{"?period=1M&start=2025-01-01&end=2025-02-01", false},
+		{"?start=2025-02-01&end=2025-01-01", false},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/trading/portfolio/analytics"+tt.query, nil)
+
+		query, err := parseQuery(c)
+		if (err == nil) != tt.valid {
+			t.Fatalf("%q: expected valid=%v, got %v", tt.query, tt.valid, err)
+		}
+
+		if err == nil && query.Get("timeframe") == "" {
+			t.Fatalf("%q: expected a default timeframe", tt.query)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package analytics
+
+import (
+	"math"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// The number of trading days in a year, used to annualize the daily figures
+const tradingDays = 252
+
+// Point is the equity of the account at the end of a period of the history
+type Point struct {
+	Time   time.Time
+	Equity float64
+}
+
+// Flow is money moved into (positive) or out of (negative) the account
+type Flow struct {
+	Time   time.Time
+	Amount float64
+}
+
+// Return is the performance of a single period of the history, without the money moved in or out during it
+type Return struct {
+	Time  time.Time
+	Value float64
+}
+
+type Drawdown struct {
+	Value      float64    `json:"value"`
+	PeakAt     *time.Time `json:"peak_at"`
+	TroughAt   *time.Time `json:"trough_at"`
+	RecoveryAt *time.Time `json:"recovery_at"`
+}
+
+type Day struct {
+	Date   string  `json:"date"`
+	Return float64 `json:"return"`
+}
+
+// Metrics are the risk and return figures of a series of returns
+type Metrics struct {
+	TimeWeightedReturn   float64  `json:"time_weighted_return"`
+	AnnualizedReturn     *float64 `json:"annualized_return"`
+	AnnualizedVolatility float64  `json:"annualized_volatility"`
+	SharpeRatio          *float64 `json:"sharpe_ratio"`
+	SortinoRatio         *float64 `json:"sortino_ratio"`
+	MaxDrawdown          Drawdown `json:"max_drawdown"`
+	BestDay              *Day     `json:"best_day"`
+	WorstDay             *Day     `json:"worst_day"`
+}
+
+// trim drops the points before the account had any equity, there's nothing to measure there
+func trim(points []Point) []Point {
+	for i, p := range points {
+		if p.Equity > 0 {
+			return points[i:]
+		}
+	}
+
+	return nil
+}
+
+// netFlows sums the flows that happened after the previous point and up to (including) each point.
+// The flows before the first point are already a part of its equity and the ones after the last point don't count.
+func netFlows(points []Point, flows []Flow) []float64 {
+	net := make([]float64, len(points))
+
+	for _, f := range flows {
+		for i := 1; i < len(points); i++ {
+			if f.Time.After(points[i-1].Time) && !f.Time.After(points[i].Time) {
+				net[i] += f.Amount
+				break
+			}
+		}
+	}
+
+	return net
+}
+
+// Returns are the period returns of the equity. The flows are assumed to happen at the end of the period,
+// so a deposit isn't counted as a gain.
+func Returns(points []Point, flows []Flow) []Return {
+	net := netFlows(points, flows)
+
+	returns := make([]Return, 0, len(points))
+	for i := 1; i < len(points); i++ {
+		if points[i-1].Equity <= 0 {
+			continue
+		}
+
+		returns = append(returns, Return{
+			Time:  points[i].Time,
+			Value: (points[i].Equity-net[i])/points[i-1].Equity - 1,
+		})
+	}
+
+	return returns
+}
+
+// Cumulative chains the period returns together, which makes it the time weighted return of every period
+func Cumulative(returns []Return) []float64 {
+	cumulative := make([]float64, len(returns))
+
+	growth := 1.0
+	for i, r := range returns {
+		growth *= 1 + r.Value
+		cumulative[i] = growth - 1
+	}
+
+	return cumulative
+}
+
+// MoneyWeightedReturn is the internal rate of return of the account over the whole history, not annualized.
+// Unlike the time weighted return it depends on when the money was moved, so deposits right before a
+// drop weigh more than the ones right after it.
+func MoneyWeightedReturn(points []Point, flows []Flow) *float64 {
+	if len(points) < 2 {
+		return nil
+	}
+
+	first, last := points[0], points[len(points)-1]
+	span := last.Time.Sub(first.Time).Seconds()
+	if span <= 0 || first.Equity <= 0 {
+		return nil
+	}
+
+	net := netFlows(points, flows)
+
+	// The value of every cash flow at the end of the history, if it grew by the given factor over the whole of it
+	futureValue := func(growth float64) float64 {
+		value := last.Equity - first.Equity*growth
+		for i := 1; i < len(points); i++ {
+			if net[i] == 0 {
+				continue
+			}
+
+			remaining := last.Time.Sub(points[i].Time).Seconds() / span
+			value -= net[i] * math.Pow(growth, remaining)
+		}
+
+		return value
+	}
+
+	low, high := 1e-6, 1e6
+	if futureValue(low)*futureValue(high) > 0 {
+		return nil
+	}
+
+	for range 200 {
+		middle := math.Sqrt(low * high)
+		if futureValue(low)*futureValue(middle) <= 0 {
+			high = middle
+		} else {
+			low = middle
+		}
+
+		if high/low-1 < 1e-12 {
+			break
+		}
+	}
+
+	result := math.Sqrt(low*high) - 1
+	return &result
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+
+	return sum / float64(len(values))
+}
+
+// deviation is the sample standard deviation
+func deviation(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	m := mean(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+
+	return math.Sqrt(sum / float64(len(values)-1))
+}
+
+// MaxDrawdown is the biggest fall of the cumulative return from a previous high
+func MaxDrawdown(returns []Return, start time.Time) Drawdown {
+	drawdown := Drawdown{}
+
+	growth, peak := 1.0, 1.0
+	peakAt := start
+	var troughPeak float64
+	for _, r := range returns {
+		growth *= 1 + r.Value
+
+		if growth >= peak {
+			if drawdown.TroughAt != nil && drawdown.RecoveryAt == nil && growth >= troughPeak {
+				recoveryAt := r.Time
+				drawdown.RecoveryAt = &recoveryAt
+			}
+
+			peak = growth
+			peakAt = r.Time
+			continue
+		}
+
+		if value := growth/peak - 1; value < drawdown.Value {
+			drawdown.Value = value
+			peakTime, troughAt := peakAt, r.Time
+			drawdown.PeakAt = &peakTime
+			drawdown.TroughAt = &troughAt
+			drawdown.RecoveryAt = nil
+			troughPeak = peak
+		}
+	}
+
+	return drawdown
+}
+
+// Daily compounds the period returns of every day into a single one
+func Daily(returns []Return) []Day {
+	var days []Day
+
+	for _, r := range returns {
+		date := r.Time.UTC().Format(dateLayout)
+		if len(days) > 0 && days[len(days)-1].Date == date {
+			last := &days[len(days)-1]
+			last.Return = (1+last.Return)*(1+r.Value) - 1
+			continue
+		}
+
+		days = append(days, Day{Date: date, Return: r.Value})
+	}
+
+	return days
+}
+
+func values(returns []Return) []float64 {
+	result := make([]float64, len(returns))
+	for i, r := range returns {
+		result[i] = r.Value
+	}
+
+	return result
+}
+
+func optional(value float64) *float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+
+	return &value
+}
+
+// Calculate measures the returns. The periods per year annualize the volatility and the ratios
+// and the risk free rate is annual.
+func Calculate(returns []Return, start time.Time, periodsPerYear, riskFreeRate float64) Metrics {
+	metrics := Metrics{MaxDrawdown: MaxDrawdown(returns, start)}
+	if len(returns) == 0 {
+		return metrics
+	}
+
+	cumulative := Cumulative(returns)
+	metrics.TimeWeightedReturn = cumulative[len(cumulative)-1]
+
+	if years := returns[len(returns)-1].Time.Sub(start).Hours() / 24 / 365.25; years > 0 {
+		metrics.AnnualizedReturn = optional(math.Pow(1+metrics.TimeWeightedReturn, 1/years) - 1)
+	}
+
+	v := values(returns)
+	riskFree := riskFreeRate / periodsPerYear
+	excess := mean(v) - riskFree
+
+	volatility := deviation(v)
+	metrics.AnnualizedVolatility = volatility * math.Sqrt(periodsPerYear)
+	if volatility > 0 {
+		metrics.SharpeRatio = optional(excess / volatility * math.Sqrt(periodsPerYear))
+	}
+
+	downside := 0.0
+	for _, value := range v {
+		if value < riskFree {
+			downside += (value - riskFree) * (value - riskFree)
+		}
+	}
+
+	if downside > 0 {
+		downside = math.Sqrt(downside / float64(len(v)))
+		metrics.SortinoRatio = optional(excess / downside * math.Sqrt(periodsPerYear))
+	}
+
+	days := Daily(returns)
+	best, worst := days[0], days[0]
+	for _, day := range days[1:] {
+		if day.Return > best.Return {
+			best = day
+		}
+
+		if day.Return < worst.Return {
+			worst = day
+		}
+	}
+	metrics.BestDay = &best
+	metrics.WorstDay = &worst
+
+	return metrics
+}
+
+// Relative compares the returns of the account to the ones of the benchmark over the periods both of them have.
+// Beta is how much the account moves with the benchmark and the correlation is how closely.
+func Relative(returns, benchmark []Return) (beta *float64, correlation *float64) {
+	byTime := make(map[int64]float64, len(benchmark))
+	for _, r := range benchmark {
+		byTime[r.Time.Unix()] = r.Value
+	}
+
+	var account, market []float64
+	for _, r := range returns {
+		if value, ok := byTime[r.Time.Unix()]; ok {
+			account = append(account, r.Value)
+			market = append(market, value)
+		}
+	}
+
+	if len(account) < 2 {
+		return nil, nil
+	}
+
+	accountMean, marketMean := mean(account), mean(market)
+	covariance, accountVariance, marketVariance := 0.0, 0.0, 0.0
+	for i := range account {
+		covariance += (account[i] - accountMean) * (market[i] - marketMean)
+		accountVariance += (account[i] - accountMean) * (account[i] - accountMean)
+		marketVariance += (market[i] - marketMean) * (market[i] - marketMean)
+	}
+
+	if marketVariance > 0 {
+		beta = optional(covariance / marketVariance)
+	}
+
+	if marketVariance > 0 && accountVariance > 0 {
+		correlation = optional(covariance / math.Sqrt(marketVariance*accountVariance))
+	}
+
+	return beta, correlation
+}
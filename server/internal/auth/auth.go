@@ -10,6 +10,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"time"
 
 	. "github.com/Phantomvv1/KayTrade/internal/exit"
@@ -71,18 +72,29 @@ type AlpacaAccount struct {
 }
 
 type TradingDetails struct {
-	AccountBlocked      bool   `json:"account_blocked"`
-	AccountNumber       string `json:"account_number"`
-	Fees                string `json:"accrued_fees"`
-	BuyingPower         string `json:"buying_power"`
-	Cash                string `json:"cash"`
-	CashTransferable    string `json:"cash_transferable"`
-	CashWithdrawable    string `json:"cash_withdrawable"`
-	Currency            string `json:"currency"`
-	Equity              string `json:"equity"`
-	IntradayAdjustments string `json:"intraday_adjustments"`
-	InitialMargin       string `json:"initial_margin"`
-	Status              string `json:"status"`
+	AccountBlocked        bool   `json:"account_blocked"`
+	AccountNumber         string `json:"account_number"`
+	Fees                  string `json:"accrued_fees"`
+	BuyingPower           string `json:"buying_power"`
+	RegTBuyingPower       string `json:"regt_buying_power"`
+	DaytradingBuyingPower string `json:"daytrading_buying_power"`
+	Cash                  string `json:"cash"`
+	CashTransferable      string `json:"cash_transferable"`
+	CashWithdrawable      string `json:"cash_withdrawable"`
+	Currency              string `json:"currency"`
+	Equity                string `json:"equity"`
+	LongMarketValue       string `json:"long_market_value"`
+	ShortMarketValue      string `json:"short_market_value"`
+	IntradayAdjustments   string `json:"intraday_adjustments"`
+	InitialMargin         string `json:"initial_margin"`
+	MaintenanceMargin     string `json:"maintenance_margin"`
+	Multiplier            string `json:"multiplier"`
+	ShortingEnabled       bool   `json:"shorting_enabled"`
+	PatternDayTrader      bool   `json:"pattern_day_trader"`
+	DaytradeCount         int    `json:"daytrade_count"`
+	MarginExcess          string `json:"margin_excess"`
+	MarginCall            bool   `json:"margin_call"`
+	Status                string `json:"status"`
 }
 
 func GenerateJWT(id string, accountType byte, email string) (string, error) {
@@ -180,6 +192,19 @@ func SignUp(c *gin.Context) {
 	acc.Agreements[0]["signed_at"] = time.Now().UTC().Format(time.RFC3339)
 	acc.Agreements[0]["ip_address"] = c.ClientIP()
 
+	if len(acc.Assets) == 0 {
+		acc.Assets = []string{"us_equity"}
+	}
+
+	// Alpaca only enables crypto for the accounts that signed the crypto agreement as well
+	if slices.Contains(acc.Assets, "crypto") {
+		acc.Agreements = append(acc.Agreements, map[string]string{
+			"agreement":  "crypto_agreement",
+			"signed_at":  acc.Agreements[0]["signed_at"],
+			"ip_address": acc.Agreements[0]["ip_address"],
+		})
+	}
+
 	req, err := json.Marshal(acc)
 	if err != nil {
 		ErrorExit(c, http.StatusInternalServerError, "couldn't recreate the request", err)
@@ -586,5 +611,7 @@ func GetAccountTradingDetails(c *gin.Context) {
 		return
 	}
 
+	body.CheckMargin()
+
 	c.JSON(http.StatusOK, body)
 }
@@ -81,3 +81,26 @@ func TestLogInUserNotFound(t *testing.T) {
 		t.Fatalf("expected 500, got %d", w.Code)
 	}
 }
+
+func TestCheckMargin(t *testing.T) {
+	details := TradingDetails{Equity: "10000", MaintenanceMargin: "4000", Multiplier: "2"}
+	details.CheckMargin()
+
+	if details.MarginCall || details.MarginExcess != "6000.00" || !details.Margin() {
+		t.Fatalf("unexpected margin %+v", details)
+	}
+
+	details.Equity = "3500.5"
+	details.CheckMargin()
+
+	if !details.MarginCall || details.MarginExcess != "-499.50" {
+		t.Fatalf("expected a margin call, got %+v", details)
+	}
+
+	cash := TradingDetails{Equity: "500", MaintenanceMargin: "0", Multiplier: "1"}
+	cash.CheckMargin()
+
+	if cash.MarginCall || cash.Margin() {
+		t.Fatalf("expected a cash account without margin, got %+v", cash)
+	}
+}
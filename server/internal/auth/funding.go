@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -301,3 +302,34 @@ func NewTransfer(c *gin.Context) {
 
 	c.JSON(http.StatusOK, body)
 }
+
+// The maximum page size of the transfers
+const transfersPageSize = 100
+
+type Transfer struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Status    string    `json:"status"`
+	Direction string    `json:"direction"`
+	Amount    string    `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GetTransfers returns every transfer of the account, including the ones that didn't go through
+func GetTransfers(id string) ([]Transfer, error) {
+	headers := BasicAuth()
+
+	var transfers []Transfer
+	for offset := 0; ; offset += transfersPageSize {
+		page, err := SendRequest[[]Transfer](http.MethodGet, BaseURL+Accounts+id+"/transfers?limit="+strconv.Itoa(transfersPageSize)+"&offset="+strconv.Itoa(offset), nil, nil, headers)
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, page...)
+		if len(page) < transfersPageSize {
+			return transfers, nil
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package auth
+
+import "strconv"
+
+// Margin reports whether the account trades on margin, which is needed to sell short
+func (t TradingDetails) Margin() bool {
+	multiplier, _ := strconv.ParseFloat(t.Multiplier, 64)
+	return multiplier > 1
+}
+
+// CheckMargin works out how far the equity of the account is from the maintenance margin of its positions.
+// A margin call is issued as soon as the equity falls below it.
+func (t *TradingDetails) CheckMargin() {
+	equity, _ := strconv.ParseFloat(t.Equity, 64)
+	maintenance, _ := strconv.ParseFloat(t.MaintenanceMargin, 64)
+
+	t.MarginExcess = strconv.FormatFloat(equity-maintenance, 'f', 2, 64)
+	t.MarginCall = maintenance > 0 && equity < maintenance
+}
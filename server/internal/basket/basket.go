@@ -0,0 +1,426 @@
+package basket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/Phantomvv1/KayTrade/internal/trading"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxLegs = 50
+
+// How many requests are sent to Alpaca at the same time
+const parallelism = 5
+
+// Statuses of a single leg
+const (
+	LegPlaced   = "placed"
+	LegFailed   = "failed"
+	LegCanceled = "canceled"
+)
+
+// Statuses of the whole basket
+const (
+	BasketPlaced   = "placed"
+	BasketPartial  = "partial"
+	BasketFailed   = "failed"
+	BasketCanceled = "canceled"
+)
+
+type Leg struct {
+	Symbol      string  `json:"symbol"`
+	Side        string  `json:"side"`
+	Qty         float64 `json:"qty,omitempty"`
+	Notional    float64 `json:"notional,omitempty"`
+	Type        string  `json:"type"`
+	LimitPrice  float64 `json:"limit_price,omitempty"`
+	TimeInForce string  `json:"time_in_force"`
+}
+
+type Result struct {
+	Leg
+	OrderID string `json:"order_id,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Basket struct {
+	Legs            []Leg `json:"legs"`
+	CancelOnFailure bool  `json:"cancel_on_failure"`
+}
+
+type asset struct {
+	Symbol       string `json:"symbol"`
+	Status       string `json:"status"`
+	Tradable     bool   `json:"tradable"`
+	Fractionable bool   `json:"fractionable"`
+}
+
+type account struct {
+	BuyingPower string `json:"buying_power"`
+}
+
+type latestTrades struct {
+	Trades map[string]struct {
+		Price float64 `json:"p"`
+	} `json:"trades"`
+}
+
+func (l *Leg) validate() error {
+	l.Symbol = strings.ToUpper(strings.TrimSpace(l.Symbol))
+	if l.Symbol == "" {
+		return errors.New("the symbol is required")
+	}
+
+	if l.Side == "" {
+		l.Side = "buy"
+	}
+	if l.Side != "buy" && l.Side != "sell" {
+		return errors.New("the side should be buy or sell")
+	}
+
+	if l.Type == "" {
+		l.Type = "market"
+	}
+	if l.Type != "market" && l.Type != "limit" {
+		return errors.New("only market and limit orders can be a part of a basket")
+	}
+
+	if l.TimeInForce == "" {
+		l.TimeInForce = "day"
+	}
+
+	if (l.Qty > 0) == (l.Notional > 0) {
+		return errors.New("exactly one of qty and notional should be positive")
+	}
+
+	if l.Notional > 0 && (l.Type != "market" || l.TimeInForce != "day") {
+		return errors.New("notional orders have to be day market orders")
+	}
+
+	if l.Notional > 0 && l.Notional < 1 {
+		return errors.New("the notional should be at least $1")
+	}
+
+	if l.Type == "limit" && l.LimitPrice <= 0 {
+		return errors.New("limit orders need a positive limit price")
+	}
+
+	return nil
+}
+
+func (l Leg) fractional() bool {
+	return l.Notional > 0 || l.Qty != float64(int64(l.Qty))
+}
+
+func (l Leg) order() map[string]any {
+	order := map[string]any{
+		"symbol":        l.Symbol,
+		"side":          l.Side,
+		"type":          l.Type,
+		"time_in_force": l.TimeInForce,
+	}
+
+	if l.Notional > 0 {
+		order["notional"] = strconv.FormatFloat(l.Notional, 'f', 2, 64)
+	} else {
+		order["qty"] = strconv.FormatFloat(l.Qty, 'f', -1, 64)
+	}
+
+	if l.Type == "limit" {
+		order["limit_price"] = strconv.FormatFloat(l.LimitPrice, 'f', -1, 64)
+	}
+
+	return order
+}
+
+func (b *Basket) validate() error {
+	if len(b.Legs) == 0 {
+		return errors.New("the basket needs at least one order")
+	}
+
+	if len(b.Legs) > maxLegs {
+		return fmt.Errorf("a basket can have at most %d orders", maxLegs)
+	}
+
+	for i := range b.Legs {
+		if err := b.Legs[i].validate(); err != nil {
+			return fmt.Errorf("order %d (%s): %s", i+1, b.Legs[i].Symbol, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (b *Basket) symbols() []string {
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, leg := range b.Legs {
+		if !seen[leg.Symbol] {
+			seen[leg.Symbol] = true
+			symbols = append(symbols, leg.Symbol)
+		}
+	}
+
+	return symbols
+}
+
+// Cost estimates how much buying power the buys need. Limit orders are priced at their limit,
+// market orders at the latest trade. The sells aren't counted, as they may fill after the buys.
+func (b *Basket) Cost(prices map[string]float64) (float64, error) {
+	total := 0.0
+	for _, leg := range b.Legs {
+		if leg.Side != "buy" {
+			continue
+		}
+
+		switch {
+		case leg.Notional > 0:
+			total += leg.Notional
+		case leg.Type == "limit":
+			total += leg.Qty * leg.LimitPrice
+		default:
+			price, ok := prices[leg.Symbol]
+			if !ok {
+				return 0, errors.New("couldn't find the latest price of " + leg.Symbol)
+			}
+
+			total += leg.Qty * price
+		}
+	}
+
+	return total, nil
+}
+
+// forEach calls f for every index from 0 to n, running at most limit of them at the same time
+func forEach(n, limit int, f func(i int)) {
+	sem := make(chan struct{}, limit)
+	wg := sync.WaitGroup{}
+
+	for i := range n {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			f(i)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// Place submits all the legs and reports the result of each one of them. When cancelOnFailure is set
+// and a leg fails, the legs that were placed are canceled again.
+func (b *Basket) Place(submit func(Leg) (string, error), cancel func(string) error) ([]Result, string) {
+	results := make([]Result, len(b.Legs))
+	forEach(len(b.Legs), parallelism, func(i int) {
+		results[i] = Result{Leg: b.Legs[i], Status: LegPlaced}
+
+		orderID, err := submit(b.Legs[i])
+		if err != nil {
+			results[i].Status = LegFailed
+			results[i].Error = err.Error()
+			return
+		}
+
+		results[i].OrderID = orderID
+	})
+
+	failed := 0
+	for _, result := range results {
+		if result.Status == LegFailed {
+			failed++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return results, BasketPlaced
+	case failed == len(results):
+		return results, BasketFailed
+	case !b.CancelOnFailure:
+		return results, BasketPartial
+	}
+
+	forEach(len(results), parallelism, func(i int) {
+		if results[i].Status != LegPlaced {
+			return
+		}
+
+		if err := cancel(results[i].OrderID); err != nil {
+			results[i].Error = "couldn't cancel the order: " + err.Error()
+			return
+		}
+
+		results[i].Status = LegCanceled
+	})
+
+	for _, result := range results {
+		if result.Status == LegPlaced {
+			return results, BasketPartial
+		}
+	}
+
+	return results, BasketCanceled
+}
+
+// checkAssets makes sure every symbol can be traded, in fractions if the basket needs them
+func (b *Basket) checkAssets() error {
+	headers := BasicAuth()
+	symbols := b.symbols()
+
+	fractional := make(map[string]bool)
+	for _, leg := range b.Legs {
+		if leg.fractional() {
+			fractional[leg.Symbol] = true
+		}
+	}
+
+	errs := make([]error, len(symbols))
+	forEach(len(symbols), parallelism, func(i int) {
+		a, err := SendRequest[asset](http.MethodGet, BaseURL+Assets+symbols[i], nil, map[int]string{404: symbols[i] + " doesn't exist"}, headers)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+
+		if !a.Tradable || a.Status != "active" {
+			errs[i] = errors.New(symbols[i] + " isn't tradable")
+		} else if fractional[symbols[i]] && !a.Fractionable {
+			errs[i] = errors.New(symbols[i] + " can't be traded in fractions")
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+func getLatestPrices(symbols []string) (map[string]float64, error) {
+	body, err := SendRequest[latestTrades](http.MethodGet, MarketData+"/stocks/trades/latest?symbols="+strings.Join(symbols, ","), nil, nil, BasicAuth())
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]float64, len(body.Trades))
+	for symbol, trade := range body.Trades {
+		prices[symbol] = trade.Price
+	}
+
+	return prices, nil
+}
+
+func PlaceBasket(c *gin.Context) {
+	id := c.GetString("id")
+
+	basket := Basket{}
+	if err := c.ShouldBindJSON(&basket); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	if err := basket.validate(); err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err := basket.checkAssets(); err != nil {
+		ErrorExit(c, http.StatusBadRequest, strings.ReplaceAll(err.Error(), "\n", ", "), nil)
+		return
+	}
+
+	headers := BasicAuth()
+	acc, err := SendRequest[account](http.MethodGet, BaseURL+Trading+id+"/account", nil, nil, headers)
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the buying power of the account")
+		return
+	}
+
+	buyingPower, err := strconv.ParseFloat(acc.BuyingPower, 64)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't parse the buying power of the account", err)
+		return
+	}
+
+	prices, err := getLatestPrices(basket.symbols())
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the latest prices of the symbols")
+		return
+	}
+
+	cost, err := basket.Cost(prices)
+	if err != nil {
+		ErrorExit(c, http.StatusFailedDependency, err.Error(), nil)
+		return
+	}
+
+	if cost > buyingPower {
+		ErrorExit(c, http.StatusBadRequest, fmt.Sprintf("not enough buying power: the basket needs about $%.2f, but only $%.2f is available", cost, buyingPower), nil)
+		return
+	}
+
+	orders := make(map[string]map[string]any)
+	mu := sync.Mutex{}
+
+	submit := func(leg Leg) (string, error) {
+		reqBody, err := json.Marshal(leg.order())
+		if err != nil {
+			return "", err
+		}
+
+		body, err := trading.SubmitOrder(id, bytes.NewReader(reqBody))
+		if err != nil {
+			return "", err
+		}
+
+		orderID, _ := body["id"].(string)
+
+		mu.Lock()
+		orders[orderID] = body
+		mu.Unlock()
+
+		return orderID, nil
+	}
+
+	cancel := func(orderID string) error {
+		_, err := SendRequest[any](http.MethodDelete, BaseURL+Trading+id+"/orders/"+orderID, nil, nil, headers)
+		return err
+	}
+
+	results, status := basket.Place(submit, cancel)
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Println(err)
+	} else {
+		defer conn.Close(context.Background())
+
+		if err = trading.CreateOrdersTable(conn); err != nil {
+			log.Println(err)
+		} else {
+			for _, result := range results {
+				if result.Status != LegPlaced {
+					continue
+				}
+
+				if err = trading.SaveOrder(conn, id, orders[result.OrderID]); err != nil {
+					log.Println(err)
+				}
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": status, "legs": results})
+}
@@ -0,0 +1,165 @@
+package basket
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Basket{Legs: []Leg{{Symbol: " aapl ", Notional: 100}, {Symbol: "MSFT", Side: "sell", Qty: 2, Type: "limit", LimitPrice: 400}}}
+	if err := valid.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	leg := valid.Legs[0]
+	if leg.Symbol != "AAPL" || leg.Side != "buy" || leg.Type != "market" || leg.TimeInForce != "day" {
+		t.Fatalf("expected the defaults to be filled in, got %+v", leg)
+	}
+
+	invalid := [][]Leg{
+		nil,
+		{{Symbol: "AAPL"}},
+		{{Symbol: "AAPL", Qty: 1, Notional: 100}},
+		{{Symbol: "AAPL", Notional: 100, Type: "limit", LimitPrice: 10}},
+		{{Symbol: "AAPL", Notional: 0.5}},
+		{{Symbol: "AAPL", Qty: 1, Type: "limit"}},
+		{{Symbol: "AAPL", Qty: 1, Type: "stop"}},
+		{{Symbol: "AAPL", Qty: 1, Side: "short"}},
+		make([]Leg, maxLegs+1),
+	}
+
+	for i, legs := range invalid {
+		b := Basket{Legs: legs}
+		if err := b.validate(); err == nil {
+			t.Fatalf("expected an error for basket %d", i)
+		}
+	}
+}
+
+func TestCost(t *testing.T) {
+	b := Basket{Legs: []Leg{
+		{Symbol: "AAPL", Side: "buy", Notional: 100},
+		{Symbol: "MSFT", Side: "buy", Qty: 2, Type: "limit", LimitPrice: 300},
+		{Symbol: "VOO", Side: "buy", Qty: 1.5, Type: "market"},
+		{Symbol: "TSLA", Side: "sell", Qty: 10, Type: "market"},
+	}}
+
+	cost, err := b.Cost(map[string]float64{"VOO": 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cost != 100+600+600 {
+		t.Fatalf("unexpected cost %g", cost)
+	}
+
+	if _, err = b.Cost(nil); err == nil {
+		t.Fatal("expected an error without the price of VOO")
+	}
+}
+
+func TestForEach_BoundedParallelism(t *testing.T) {
+	var running, peak int32
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+
+	forEach(20, 3, func(i int) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		mu.Lock()
+		seen[i] = true
+		mu.Unlock()
+	})
+
+	if peak > 3 {
+		t.Fatalf("expected at most 3 at a time, got %d", peak)
+	}
+
+	if len(seen) != 20 {
+		t.Fatalf("expected every index to run, got %d", len(seen))
+	}
+}
+
+func failOn(symbol string) func(Leg) (string, error) {
+	return func(leg Leg) (string, error) {
+		if leg.Symbol == symbol {
+			return "", errors.New("insufficient buying power")
+		}
+
+		return "order-" + leg.Symbol, nil
+	}
+}
+
+func TestPlace_Partial(t *testing.T) {
+	b := Basket{Legs: []Leg{{Symbol: "AAPL"}, {Symbol: "MSFT"}, {Symbol: "VOO"}}}
+
+	results, status := b.Place(failOn("MSFT"), func(string) error {
+		t.Fatal("nothing should be canceled")
+		return nil
+	})
+
+	if status != BasketPartial {
+		t.Fatalf("expected a partial basket, got %s", status)
+	}
+
+	if results[0].OrderID != "order-AAPL" || results[1].Status != LegFailed || results[1].Error == "" || results[2].Status != LegPlaced {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestPlace_CancelOnFailure(t *testing.T) {
+	b := Basket{Legs: []Leg{{Symbol: "AAPL"}, {Symbol: "MSFT"}, {Symbol: "VOO"}}, CancelOnFailure: true}
+
+	var mu sync.Mutex
+	var canceled []string
+	results, status := b.Place(failOn("MSFT"), func(orderID string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		canceled = append(canceled, orderID)
+		return nil
+	})
+
+	if status != BasketCanceled || len(canceled) != 2 {
+		t.Fatalf("expected both placed orders to be canceled, got %s and %v", status, canceled)
+	}
+
+	if results[0].Status != LegCanceled || results[2].Status != LegCanceled {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestPlace_AllPlacedAndAllFailed(t *testing.T) {
+	b := Basket{Legs: []Leg{{Symbol: "AAPL"}}, CancelOnFailure: true}
+
+	if _, status := b.Place(failOn(""), nil); status != BasketPlaced {
+		t.Fatalf("expected the basket to be placed, got %s", status)
+	}
+
+	if _, status := b.Place(failOn("AAPL"), nil); status != BasketFailed {
+		t.Fatalf("expected the basket to fail, got %s", status)
+	}
+}
+
+func TestLegOrder(t *testing.T) {
+	order := Leg{Symbol: "AAPL", Side: "buy", Qty: 1.5, Type: "limit", LimitPrice: 180.25, TimeInForce: "gtc"}.order()
+	if order["qty"] != "1.5" || order["limit_price"] != "180.25" || order["notional"] != nil {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	order = Leg{Symbol: "AAPL", Side: "buy", Notional: 50, Type: "market", TimeInForce: "day"}.order()
+	if order["notional"] != "50.00" || order["qty"] != nil {
+		t.Fatalf("unexpected order %v", order)
+	}
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// Complete is the ttl of the values that don't change anymore, like the bars that are complete. They're still
+// given one, so what nobody asks for again doesn't stay in redis.
+const Complete = 7 * 24 * time.Hour
+
+// How long redis is skipped after it couldn't be reached, so the requests don't wait on it in the meantime
+const cooldown = 30 * time.Second
+
+// All of the keys of the market data are prefixed, so they don't mix with the rest of redis
+const keyPrefix = "market-data:"
+
+var errMiss = errors.New("the value isn't cached")
+
+// store is where the responses are kept. It's redis outside of the tests.
+type store interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+type redisStore struct {
+	rdb *redis.Client
+}
+
+func (s redisStore) Get(key string) ([]byte, error) {
+	value, err := s.rdb.Get(context.Background(), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errMiss
+	}
+
+	return value, err
+}
+
+func (s redisStore) Set(key string, value []byte, ttl time.Duration) error {
+	return s.rdb.Set(context.Background(), key, value, ttl).Err()
+}
+
+type counters struct {
+	hits    atomic.Int64
+	misses  atomic.Int64
+	shared  atomic.Int64
+	errors  atomic.Int64
+	skipped atomic.Int64
+}
+
+// Metrics tells how the cache did for one kind of market data. Shared are the misses that waited for an
+// identical request instead of reaching alpaca, Errors are the times redis couldn't be used and Skipped
+// are the requests that didn't try it, because it couldn't be reached shortly before.
+type Metrics struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	Shared  int64   `json:"shared"`
+	Errors  int64   `json:"errors"`
+	Skipped int64   `json:"skipped"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+var (
+	backend store = redisStore{rdb: redis.NewClient(&redis.Options{
+		Addr:         os.Getenv("REDIS_URL"),
+		DB:           0,
+		DialTimeout:  time.Second,
+		ReadTimeout:  time.Second,
+		WriteTimeout: time.Second,
+	})}
+
+	group singleflight.Group
+
+	// Until when redis is skipped, in unix nanoseconds
+	skipUntil atomic.Int64
+
+	mu      sync.Mutex
+	metrics = make(map[string]*counters)
+)
+
+func available() bool {
+	return time.Now().UnixNano() >= skipUntil.Load()
+}
+
+func unavailable(stats *counters) {
+	stats.errors.Add(1)
+	skipUntil.Store(time.Now().Add(cooldown).UnixNano())
+}
+
+func countersOf(kind string) *counters {
+	mu.Lock()
+	defer mu.Unlock()
+
+	c, ok := metrics[kind]
+	if !ok {
+		c = &counters{}
+		metrics[kind] = c
+	}
+
+	return c
+}
+
+// Fetch returns the cached value of the key or calls fetch and caches what it returns for the ttl. Concurrent
+// calls for the same key share a single fetch, so a popular symbol reaches alpaca once. Errors aren't cached
+// and when redis can't be reached the value is fetched as if it wasn't cached, without trying redis again
+// until the cooldown is over.
+func Fetch[T any](kind, key string, ttl time.Duration, fetch func() (T, error)) (T, error) {
+	stats := countersOf(kind)
+	key = keyPrefix + kind + ":" + key
+
+	if !available() {
+		stats.skipped.Add(1)
+	} else if cached, err := backend.Get(key); err == nil {
+		var value T
+		if err := json.Unmarshal(cached, &value); err == nil {
+			stats.hits.Add(1)
+			return value, nil
+		}
+	} else if !errors.Is(err, errMiss) {
+		unavailable(stats)
+	}
+
+	stats.misses.Add(1)
+
+	value, err, shared := group.Do(key, func() (any, error) {
+		value, err := fetch()
+		if err != nil || !available() {
+			return value, err
+		}
+
+		body, err := json.Marshal(value)
+		if err != nil {
+			stats.errors.Add(1)
+		} else if err = backend.Set(key, body, ttl); err != nil {
+			unavailable(stats)
+		}
+
+		return value, nil
+	})
+
+	if shared {
+		stats.shared.Add(1)
+	}
+
+	result, _ := value.(T)
+	return result, err
+}
+
+// Snapshot returns the metrics of every kind of market data that was asked for
+func Snapshot() map[string]Metrics {
+	mu.Lock()
+	defer mu.Unlock()
+
+	snapshot := make(map[string]Metrics, len(metrics))
+	for kind, c := range metrics {
+		m := Metrics{
+			Hits:    c.hits.Load(),
+			Misses:  c.misses.Load(),
+			Shared:  c.shared.Load(),
+			Errors:  c.errors.Load(),
+			Skipped: c.skipped.Load(),
+		}
+
+		if total := m.Hits + m.Misses; total > 0 {
+			m.HitRate = float64(m.Hits) / float64(total)
+		}
+
+		snapshot[kind] = m
+	}
+
+	return snapshot
+}
+
+func GetMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"cache": Snapshot()})
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	down   bool
+	calls  int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (s *memoryStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.down {
+		return nil, errors.New("connection refused")
+	}
+
+	value, ok := s.values[key]
+	if !ok {
+		return nil, errMiss
+	}
+
+	return value, nil
+}
+
+func (s *memoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.down {
+		return errors.New("connection refused")
+	}
+
+	s.values[key] = value
+	s.ttls[key] = ttl
+
+	return nil
+}
+
+func useMemoryStore(t *testing.T) *memoryStore {
+	store := newMemoryStore()
+
+	previous := backend
+	backend = store
+	skipUntil.Store(0)
+	t.Cleanup(func() {
+		backend = previous
+		skipUntil.Store(0)
+	})
+
+	return store
+}
+
+func TestFetch_CachesTheValue(t *testing.T) {
+	store := useMemoryStore(t)
+
+	calls := 0
+	fetch := func() (map[string]float64, error) {
+		calls++
+		return map[string]float64{"AAPL": 187.5}, nil
+	}
+
+	for range 3 {
+		value, err := Fetch("test_hits", "AAPL", 5*time.Second, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if value["AAPL"] != 187.5 {
+			t.Fatalf("expected 187.5, got %v", value["AAPL"])
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected a single fetch, got %d", calls)
+	}
+
+	if ttl := store.ttls[keyPrefix+"test_hits:AAPL"]; ttl != 5*time.Second {
+		t.Fatalf("expected a 5s ttl, got %v", ttl)
+	}
+
+	metrics := Snapshot()["test_hits"]
+	if metrics.Hits != 2 || metrics.Misses != 1 {
+		t.Fatalf("expected 2 hits and 1 miss, got %+v", metrics)
+	}
+}
+
+func TestFetch_DoesntCacheErrors(t *testing.T) {
+	useMemoryStore(t)
+
+	calls := 0
+	fetch := func() (any, error) {
+		calls++
+		return nil, errors.New("Too many requests")
+	}
+
+	for range 2 {
+		if _, err := Fetch("test_errors", "AAPL", Complete, fetch); err == nil {
+			t.Fatal("expected the error of the fetch")
+		}
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected the failed fetch to be retried, got %d calls", calls)
+	}
+}
+
+func TestFetch_SharesConcurrentFetches(t *testing.T) {
+	useMemoryStore(t)
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	fetch := func() (string, error) {
+		calls.Add(1)
+		<-release
+		return "snapshot", nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if value, err := Fetch("test_shared", "AAPL", time.Second, fetch); err != nil || value != "snapshot" {
+				t.Errorf("expected the shared snapshot, got %v %v", value, err)
+			}
+		}()
+	}
+
+	// Wait for every request to miss, so they all join the same fetch
+	for Snapshot()["test_shared"].Misses < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected a single fetch, got %d", calls.Load())
+	}
+
+	if shared := Snapshot()["test_shared"].Shared; shared == 0 {
+		t.Fatal("expected the requests to be counted as shared")
+	}
+}
+
+func TestFetch_WorksWithoutRedis(t *testing.T) {
+	store := useMemoryStore(t)
+	store.down = true
+
+	value, err := Fetch("test_down", "AAPL", time.Second, func() (int, error) { return 42, nil })
+	if err != nil || value != 42 {
+		t.Fatalf("expected the fetched value, got %v %v", value, err)
+	}
+
+	if metrics := Snapshot()["test_down"]; metrics.Errors == 0 {
+		t.Fatalf("expected the redis errors to be counted, got %+v", metrics)
+	}
+}
+
+func TestFetch_SkipsRedisAfterAnError(t *testing.T) {
+	store := useMemoryStore(t)
+	store.down = true
+
+	fetch := func() (int, error) { return 42, nil }
+	for range 3 {
+		if value, err := Fetch("test_skip", "AAPL", time.Second, fetch); err != nil || value != 42 {
+			t.Fatalf("expected the fetched value, got %v %v", value, err)
+		}
+	}
+
+	if store.calls != 1 {
+		t.Fatalf("expected redis to be tried once before the cooldown, got %d calls", store.calls)
+	}
+
+	if metrics := Snapshot()["test_skip"]; metrics.Errors != 1 || metrics.Skipped != 2 {
+		t.Fatalf("expected 1 error and 2 skipped requests, got %+v", metrics)
+	}
+
+	// Once the cooldown is over redis is tried again
+	store.down = false
+	skipUntil.Store(0)
+	Fetch("test_skip", "AAPL", time.Second, fetch)
+
+	if _, err := store.Get(keyPrefix + "test_skip:AAPL"); err != nil {
+		t.Fatalf("expected the value to be cached after the cooldown, got %v", err)
+	}
+}
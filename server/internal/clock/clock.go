@@ -102,6 +102,78 @@ func GetLastMarketOpenDay(market string) (*time.Time, error) {
 	return nil, errors.New("Error: wasn't able to find the last day the given stock market was open")
 }
 
+// MarketDay is a day the market is open, with the start and end of the core session in UTC. The pre-market
+// starts at PreStart and the after hours session ends at PostEnd, both are zero if the market has no extended hours.
+type MarketDay struct {
+	Date      time.Time
+	CoreStart time.Time
+	CoreEnd   time.Time
+	PreStart  time.Time
+	PostEnd   time.Time
+}
+
+// GetMarketDays returns the days between start and end (inclusive) on which the given market is open
+func GetMarketDays(market string, start, end time.Time) ([]MarketDay, error) {
+	headers := BasicAuth()
+
+	baseUrl := []byte(BaseURL)
+	baseUrl[len(baseUrl)-2] = '2'
+
+	url := string(baseUrl) + Calendar + market + "?timezone=UTC&start=" + start.UTC().Format(time.DateOnly) + "&end=" + end.UTC().Format(time.DateOnly)
+	body, err := SendRequest[map[string]any](http.MethodGet, url, nil, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	calendarInfo, ok := body["calendar"].([]any)
+	if !ok {
+		return nil, errors.New("Error: the calendar has an unexpected format")
+	}
+
+	days := make([]MarketDay, 0, len(calendarInfo))
+	for _, entry := range calendarInfo {
+		info, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		coreStart, _ := info["core_start"].(string)
+		coreEnd, _ := info["core_end"].(string)
+
+		startTs, err := time.Parse(time.RFC3339, coreStart)
+		if err != nil {
+			return nil, err
+		}
+
+		endTs, err := time.Parse(time.RFC3339, coreEnd)
+		if err != nil {
+			return nil, err
+		}
+
+		day := MarketDay{
+			Date:      startTs.UTC().Truncate(time.Hour * 24),
+			CoreStart: startTs.UTC(),
+			CoreEnd:   endTs.UTC(),
+		}
+
+		if preStart, ok := info["pre_start"].(string); ok {
+			if ts, err := time.Parse(time.RFC3339, preStart); err == nil {
+				day.PreStart = ts.UTC()
+			}
+		}
+
+		if postEnd, ok := info["post_end"].(string); ok {
+			if ts, err := time.Parse(time.RFC3339, postEnd); err == nil {
+				day.PostEnd = ts.UTC()
+			}
+		}
+
+		days = append(days, day)
+	}
+
+	return days, nil
+}
+
 func IsStockMarketOpen(market string) (bool, error) {
 	headers := BasicAuth()
 
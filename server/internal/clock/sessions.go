@@ -0,0 +1,159 @@
+package clock
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type Session string
+
+const (
+	Closed     Session = "closed"
+	PreMarket  Session = "pre_market"
+	Core       Session = "core"
+	AfterHours Session = "after_hours"
+)
+
+const (
+	// How long the market days are kept before asking alpaca for them again
+	scheduleTTL = 12 * time.Hour
+	// How many days ahead the schedule knows about, enough to get over long weekends
+	scheduleDays = 14
+)
+
+var errNoMarketDay = errors.New("Error: the market isn't open in the next two weeks")
+
+// Session tells which session of the day is going on at the given time
+func (d MarketDay) Session(t time.Time) Session {
+	switch {
+	case !t.Before(d.CoreStart) && t.Before(d.CoreEnd):
+		return Core
+	case !d.PreStart.IsZero() && !t.Before(d.PreStart) && t.Before(d.CoreStart):
+		return PreMarket
+	case !d.PostEnd.IsZero() && !t.Before(d.CoreEnd) && t.Before(d.PostEnd):
+		return AfterHours
+	}
+
+	return Closed
+}
+
+// Open is when the trading of the day starts. The extended hours start with the pre-market.
+func (d MarketDay) Open(extended bool) time.Time {
+	if extended && !d.PreStart.IsZero() {
+		return d.PreStart
+	}
+
+	return d.CoreStart
+}
+
+// Close is when the trading of the day ends. The extended hours end with the after hours session.
+func (d MarketDay) Close(extended bool) time.Time {
+	if extended && !d.PostEnd.IsZero() {
+		return d.PostEnd
+	}
+
+	return d.CoreEnd
+}
+
+// Schedule caches the market days of the next couple of weeks, so the session can be checked
+// without asking alpaca every time. Holidays and half days come from alpaca's calendar.
+type Schedule struct {
+	market  string
+	fetch   func(market string, start, end time.Time) ([]MarketDay, error)
+	mu      sync.Mutex
+	days    []MarketDay
+	fetched time.Time
+}
+
+func NewSchedule(market string) *Schedule {
+	return &Schedule{market: market, fetch: GetMarketDays}
+}
+
+func (s *Schedule) marketDays(now time.Time) ([]MarketDay, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.days != nil && now.Sub(s.fetched) < scheduleTTL {
+		return s.days, nil
+	}
+
+	// Yesterday is included, so the after hours session that started before midnight UTC is known
+	days, err := s.fetch(s.market, now.AddDate(0, 0, -1), now.AddDate(0, 0, scheduleDays))
+	if err != nil {
+		return nil, err
+	}
+
+	s.days = days
+	s.fetched = now
+
+	return days, nil
+}
+
+// Session returns the session going on at the given time
+func (s *Schedule) Session(now time.Time) (Session, error) {
+	days, err := s.marketDays(now)
+	if err != nil {
+		return Closed, err
+	}
+
+	for _, day := range days {
+		if session := day.Session(now); session != Closed {
+			return session, nil
+		}
+	}
+
+	return Closed, nil
+}
+
+// NextOpen returns when the next session the user can trade in starts. With extended hours that's the
+// next pre-market, otherwise it's the next core session.
+func (s *Schedule) NextOpen(now time.Time, extended bool) (time.Time, error) {
+	days, err := s.marketDays(now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, day := range days {
+		if open := day.Open(extended); open.After(now) {
+			return open, nil
+		}
+	}
+
+	return time.Time{}, errNoMarketDay
+}
+
+// NextClose returns when the trading of the day that is going on or comes next ends, which is when the day
+// orders placed now expire. With extended hours that's the end of the after hours session.
+func (s *Schedule) NextClose(now time.Time, extended bool) (time.Time, error) {
+	days, err := s.marketDays(now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, day := range days {
+		if end := day.Close(extended); end.After(now) {
+			return end, nil
+		}
+	}
+
+	return time.Time{}, errNoMarketDay
+}
+
+// NextChange returns when the session changes next
+func (s *Schedule) NextChange(now time.Time) (time.Time, error) {
+	days, err := s.marketDays(now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, day := range days {
+		for _, boundary := range []time.Time{day.PreStart, day.CoreStart, day.CoreEnd, day.PostEnd} {
+			if boundary.After(now) {
+				return boundary, nil
+			}
+		}
+	}
+
+	return time.Time{}, errNoMarketDay
+}
@@ -0,0 +1,109 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func marketDay(date string, halfDay bool) MarketDay {
+	day, _ := time.Parse(time.DateOnly, date)
+
+	coreEnd := day.Add(20 * time.Hour)
+	if halfDay {
+		coreEnd = day.Add(17 * time.Hour)
+	}
+
+	return MarketDay{
+		Date:      day,
+		PreStart:  day.Add(8 * time.Hour),
+		CoreStart: day.Add(13*time.Hour + 30*time.Minute),
+		CoreEnd:   coreEnd,
+		PostEnd:   day.Add(24 * time.Hour),
+	}
+}
+
+func newTestSchedule(days ...MarketDay) *Schedule {
+	return &Schedule{
+		market: "NYSE",
+		fetch: func(market string, start, end time.Time) ([]MarketDay, error) {
+			return days, nil
+		},
+	}
+}
+
+func TestSchedule_Session(t *testing.T) {
+	// Friday is a half day and the market is closed on the weekend
+	schedule := newTestSchedule(marketDay("2026-11-26", false), marketDay("2026-11-27", true), marketDay("2026-11-30", false))
+
+	tests := []struct {
+		at       string
+		expected Session
+	}{
+		{"2026-11-27T07:00:00Z", Closed},
+		{"2026-11-27T09:00:00Z", PreMarket},
+		{"2026-11-27T14:00:00Z", Core},
+		{"2026-11-27T18:00:00Z", AfterHours},
+		{"2026-11-28T14:00:00Z", Closed},
+	}
+
+	for _, tt := range tests {
+		at, _ := time.Parse(time.RFC3339, tt.at)
+		session, err := schedule.Session(at)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if session != tt.expected {
+			t.Fatalf("expected %s at %s, got %s", tt.expected, tt.at, session)
+		}
+	}
+}
+
+func TestSchedule_NextOpen(t *testing.T) {
+	schedule := newTestSchedule(marketDay("2026-11-27", true), marketDay("2026-11-30", false))
+	saturday, _ := time.Parse(time.RFC3339, "2026-11-28T14:00:00Z")
+
+	open, err := schedule.NextOpen(saturday, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := marketDay("2026-11-30", false).CoreStart; !open.Equal(expected) {
+		t.Fatalf("expected the core session to open at %v, got %v", expected, open)
+	}
+
+	open, _ = schedule.NextOpen(saturday, true)
+	if expected := marketDay("2026-11-30", false).PreStart; !open.Equal(expected) {
+		t.Fatalf("expected the pre-market to open at %v, got %v", expected, open)
+	}
+
+	change, _ := schedule.NextChange(saturday.AddDate(0, 0, -1))
+	if expected := marketDay("2026-11-27", true).CoreEnd; !change.Equal(expected) {
+		t.Fatalf("expected the half day to end at %v, got %v", expected, change)
+	}
+}
+
+func TestSchedule_NextClose(t *testing.T) {
+	schedule := newTestSchedule(marketDay("2026-11-27", true), marketDay("2026-11-30", false))
+	halfDay := marketDay("2026-11-27", true)
+
+	morning, _ := time.Parse(time.RFC3339, "2026-11-27T09:00:00Z")
+	end, err := schedule.NextClose(morning, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !end.Equal(halfDay.CoreEnd) {
+		t.Fatalf("expected the half day to close at %v, got %v", halfDay.CoreEnd, end)
+	}
+
+	// After the core session the regular orders belong to the next day, the extended ones still to this one
+	evening := halfDay.CoreEnd.Add(time.Hour)
+	if end, _ = schedule.NextClose(evening, false); !end.Equal(marketDay("2026-11-30", false).CoreEnd) {
+		t.Fatalf("expected monday's close, got %v", end)
+	}
+
+	if end, _ = schedule.NextClose(evening, true); !end.Equal(halfDay.PostEnd) {
+		t.Fatalf("expected the after hours to close at %v, got %v", halfDay.PostEnd, end)
+	}
+}
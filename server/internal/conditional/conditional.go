@@ -0,0 +1,260 @@
+package conditional
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	PriceCrossesAbove = "price_crosses_above"
+	PriceCrossesBelow = "price_crosses_below"
+	RSIBelow          = "rsi_below"
+	RSIAbove          = "rsi_above"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusTriggered = "triggered"
+	StatusCanceled  = "canceled"
+	StatusFailed    = "failed"
+)
+
+// ConditionalOrder is an order kept by KayTrade until the condition on the watched symbol is met.
+// Orders sharing an OCOGroup cancel each other, even when they watch different symbols.
+type ConditionalOrder struct {
+	ID          string         `json:"id"`
+	UserID      string         `json:"user_id"`
+	Symbol      string         `json:"symbol"`
+	Condition   string         `json:"condition"`
+	Threshold   float64        `json:"threshold"`
+	Order       map[string]any `json:"order"`
+	OCOGroup    string         `json:"oco_group"`
+	Status      string         `json:"status"`
+	OrderID     string         `json:"order_id"`
+	Error       string         `json:"error"`
+	CreatedAt   time.Time      `json:"created_at"`
+	TriggeredAt *time.Time     `json:"triggered_at"`
+}
+
+func CreateConditionalOrdersTable(conn *pgx.Conn) error {
+	_, err := conn.Exec(context.Background(), "create table if not exists conditional_orders(id uuid primary key default gen_random_uuid(), "+
+		"user_id uuid references authentication(id) on delete cascade, symbol text, condition text, threshold double precision, order_body jsonb, "+
+		"oco_group text default '', status text, order_id text default '', error text default '', created_at timestamp default current_timestamp, triggered_at timestamp)")
+	return err
+}
+
+func validCondition(condition string) bool {
+	switch condition {
+	case PriceCrossesAbove, PriceCrossesBelow, RSIBelow, RSIAbove:
+		return true
+	default:
+		return false
+	}
+}
+
+func (o *ConditionalOrder) validate() error {
+	if o.Symbol == "" {
+		return errors.New("no symbol to watch was specified")
+	}
+
+	if !validCondition(o.Condition) {
+		return errors.New("unknown condition")
+	}
+
+	if (o.Condition == RSIBelow || o.Condition == RSIAbove) && (o.Threshold <= 0 || o.Threshold >= 100) {
+		return errors.New("the rsi threshold should be between 0 and 100")
+	}
+
+	if o.Threshold <= 0 {
+		return errors.New("the threshold should be a positive number")
+	}
+
+	if o.Order == nil {
+		return errors.New("no order to place was specified")
+	}
+
+	if _, ok := o.Order["side"].(string); !ok {
+		return errors.New("the side of the order is required")
+	}
+
+	_, hasQty := o.Order["qty"]
+	_, hasNotional := o.Order["notional"]
+	if !hasQty && !hasNotional {
+		return errors.New("the order needs either qty or notional")
+	}
+
+	return nil
+}
+
+const selectConditionalOrders = "select id, user_id, symbol, condition, threshold, order_body, oco_group, status, order_id, error, created_at, triggered_at from conditional_orders"
+
+func collectConditionalOrders(rows pgx.Rows) ([]*ConditionalOrder, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*ConditionalOrder, error) {
+		o := ConditionalOrder{}
+		err := row.Scan(&o.ID, &o.UserID, &o.Symbol, &o.Condition, &o.Threshold, &o.Order, &o.OCOGroup, &o.Status, &o.OrderID, &o.Error, &o.CreatedAt, &o.TriggeredAt)
+		if err != nil {
+			return nil, err
+		}
+
+		return &o, nil
+	})
+}
+
+func CreateConditionalOrder(c *gin.Context, engine *Engine) {
+	id := c.GetString("id")
+
+	order := ConditionalOrder{}
+	if err := c.ShouldBindJSON(&order); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	order.Symbol = strings.ToUpper(order.Symbol)
+	if err := order.validate(); err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	// The order can be for a different symbol than the watched one, otherwise it's for the watched one
+	if _, ok := order.Order["symbol"]; !ok {
+		order.Order["symbol"] = order.Symbol
+	}
+
+	if _, ok := order.Order["type"]; !ok {
+		order.Order["type"] = "market"
+	}
+
+	if _, ok := order.Order["time_in_force"]; !ok {
+		order.Order["time_in_force"] = "day"
+	}
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = CreateConditionalOrdersTable(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create a table for the conditional orders", err)
+		return
+	}
+
+	order.UserID = id
+	order.Status = StatusPending
+	err = conn.QueryRow(context.Background(), "insert into conditional_orders (user_id, symbol, condition, threshold, order_body, oco_group, status) "+
+		"values ($1, $2, $3, $4, $5, $6, $7) returning id, created_at", id, order.Symbol, order.Condition, order.Threshold, order.Order, order.OCOGroup, order.Status).
+		Scan(&order.ID, &order.CreatedAt)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't save the conditional order in the database", err)
+		return
+	}
+
+	watched := order
+	engine.Add <- &watched
+
+	c.JSON(http.StatusOK, order)
+}
+
+func GetConditionalOrders(c *gin.Context) {
+	id := c.GetString("id")
+	status := c.Query("status")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = CreateConditionalOrdersTable(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create a table for the conditional orders", err)
+		return
+	}
+
+	var rows pgx.Rows
+	if status == "" {
+		rows, err = conn.Query(context.Background(), selectConditionalOrders+" where user_id = $1 order by created_at desc", id)
+	} else {
+		rows, err = conn.Query(context.Background(), selectConditionalOrders+" where user_id = $1 and status = $2 order by created_at desc", id, status)
+	}
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the conditional orders from the database", err)
+		return
+	}
+
+	orders, err := collectConditionalOrders(rows)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't work with the information from the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conditional_orders": orders})
+}
+
+func GetConditionalOrder(c *gin.Context) {
+	id := c.GetString("id")
+	orderID := c.Param("conditionalId")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), selectConditionalOrders+" where id = $1 and user_id = $2", orderID, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the conditional order from the database", err)
+		return
+	}
+
+	orders, err := collectConditionalOrders(rows)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't work with the information from the database", err)
+		return
+	}
+
+	if len(orders) == 0 {
+		ErrorExit(c, http.StatusNotFound, "there is no such conditional order", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, orders[0])
+}
+
+func CancelConditionalOrder(c *gin.Context, engine *Engine) {
+	id := c.GetString("id")
+	orderID := c.Param("conditionalId")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	tag, err := conn.Exec(context.Background(), "update conditional_orders set status = $1 where id = $2 and user_id = $3 and status = $4",
+		StatusCanceled, orderID, id, StatusPending)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't cancel the conditional order", err)
+		return
+	}
+
+	if tag.RowsAffected() == 0 {
+		ErrorExit(c, http.StatusNotFound, "there is no pending conditional order with this id", nil)
+		return
+	}
+
+	engine.Cancel <- orderID
+
+	c.JSON(http.StatusOK, gin.H{"id": orderID, "status": StatusCanceled})
+}
@@ -0,0 +1,189 @@
+package conditional
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/indicators"
+	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
+)
+
+func TestValidate(t *testing.T) {
+	valid := ConditionalOrder{
+		Symbol:    "AAPL",
+		Condition: PriceCrossesAbove,
+		Threshold: 200,
+		Order:     map[string]any{"side": "buy", "qty": "10"},
+	}
+	if err := valid.validate(); err != nil {
+		t.Fatalf("expected a valid order, got %v", err)
+	}
+
+	tests := []ConditionalOrder{
+		{Condition: PriceCrossesAbove, Threshold: 200, Order: map[string]any{"side": "buy", "qty": "1"}},
+		{Symbol: "AAPL", Condition: "price_is_nice", Threshold: 200, Order: map[string]any{"side": "buy", "qty": "1"}},
+		{Symbol: "AAPL", Condition: RSIBelow, Threshold: 120, Order: map[string]any{"side": "sell", "qty": "1"}},
+		{Symbol: "AAPL", Condition: PriceCrossesBelow, Threshold: -1, Order: map[string]any{"side": "sell", "qty": "1"}},
+		{Symbol: "AAPL", Condition: PriceCrossesBelow, Threshold: 100},
+		{Symbol: "AAPL", Condition: PriceCrossesBelow, Threshold: 100, Order: map[string]any{"qty": "1"}},
+		{Symbol: "AAPL", Condition: PriceCrossesBelow, Threshold: 100, Order: map[string]any{"side": "sell"}},
+	}
+
+	for i, tt := range tests {
+		if err := tt.validate(); err == nil {
+			t.Fatalf("expected case %d to be invalid", i)
+		}
+	}
+}
+
+func TestRSI(t *testing.T) {
+	if _, ok := RSI([]float64{1, 2, 3}); ok {
+		t.Fatal("expected no rsi without enough closes")
+	}
+
+	rising := make([]float64, rsiPeriod+1)
+	for i := range rising {
+		rising[i] = float64(i + 1)
+	}
+	if rsi, ok := RSI(rising); !ok || rsi != 100 {
+		t.Fatalf("expected 100 for only gains, got %f", rsi)
+	}
+
+	falling := make([]float64, rsiPeriod+1)
+	for i := range falling {
+		falling[i] = float64(100 - i)
+	}
+	if rsi, _ := RSI(falling); rsi != 0 {
+		t.Fatalf("expected 0 for only losses, got %f", rsi)
+	}
+}
+
+func TestRSI_MatchesTheIndicator(t *testing.T) {
+	closes := make([]float64, rsiWindow)
+	for i := range closes {
+		closes[i] = 100 + float64(i%7) - float64(i%5)
+	}
+
+	want, _ := indicators.RSI(closes, rsiPeriod).Last()
+	if rsi, ok := RSI(closes); !ok || rsi != want {
+		t.Fatalf("expected the rsi of the indicator %f, got %f", want, rsi)
+	}
+
+	engine := NewEngine(nil, nil)
+	engine.watch(&ConditionalOrder{ID: "1", Symbol: "AAPL", Condition: RSIBelow, Threshold: 0})
+	for _, close := range append(closes, 101, 102) {
+		engine.evaluate(map[string]any{"T": "b", "S": "AAPL", "c": close})
+	}
+
+	if len(engine.closes["AAPL"]) != rsiWindow {
+		t.Fatalf("expected the engine to keep %d closes, got %d", rsiWindow, len(engine.closes["AAPL"]))
+	}
+}
+
+func TestTriggered(t *testing.T) {
+	above := &ConditionalOrder{Condition: PriceCrossesAbove, Threshold: 200}
+	if above.triggered(tick{price: 201}) {
+		t.Fatal("expected no trigger without a previous price")
+	}
+
+	if !above.triggered(tick{previous: 199, price: 200, seen: true}) {
+		t.Fatal("expected a trigger when crossing above")
+	}
+
+	if above.triggered(tick{previous: 201, price: 202, seen: true}) {
+		t.Fatal("expected no trigger when already above")
+	}
+
+	below := &ConditionalOrder{Condition: RSIBelow, Threshold: 30}
+	if !below.triggered(tick{rsi: 25, hasRSI: true}) || below.triggered(tick{rsi: 35, hasRSI: true}) {
+		t.Fatal("unexpected rsi trigger result")
+	}
+}
+
+func TestEngine_OneCancelsOther(t *testing.T) {
+	engine := NewEngine(nil, nil)
+
+	var mu sync.Mutex
+	var placed *ConditionalOrder
+	var canceled []string
+	done := make(chan struct{})
+	engine.submit = func(order *ConditionalOrder, c []string) {
+		mu.Lock()
+		placed, canceled = order, c
+		mu.Unlock()
+		close(done)
+	}
+
+	engine.watch(&ConditionalOrder{ID: "1", UserID: "u", Symbol: "AAPL", Condition: PriceCrossesAbove, Threshold: 200, OCOGroup: "g"})
+	engine.watch(&ConditionalOrder{ID: "2", UserID: "u", Symbol: "MSFT", Condition: PriceCrossesBelow, Threshold: 300, OCOGroup: "g"})
+	engine.watch(&ConditionalOrder{ID: "3", UserID: "u", Symbol: "MSFT", Condition: PriceCrossesBelow, Threshold: 250})
+
+	engine.evaluate(map[string]any{"T": "t", "S": "AAPL", "p": 199.0})
+	engine.evaluate(map[string]any{"T": "t", "S": "AAPL", "p": 201.0})
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if placed.ID != "1" {
+		t.Fatalf("expected order 1 to be placed, got %s", placed.ID)
+	}
+
+	if len(canceled) != 1 || canceled[0] != "2" {
+		t.Fatalf("expected order 2 to be canceled, got %v", canceled)
+	}
+
+	if _, ok := engine.orders["AAPL"]; ok {
+		t.Fatal("expected AAPL to not be watched anymore")
+	}
+
+	if len(engine.orders["MSFT"]) != 1 {
+		t.Fatalf("expected only order 3 to be left, got %v", engine.orders["MSFT"])
+	}
+}
+
+func TestEngine_WarmsUpTheRSI(t *testing.T) {
+	engine := NewEngine(nil, nil)
+
+	placed := make(chan *ConditionalOrder, 1)
+	engine.submit = func(order *ConditionalOrder, canceled []string) {
+		placed <- order
+	}
+
+	engine.watch(&ConditionalOrder{ID: "1", Symbol: "AAPL", Condition: RSIBelow, Threshold: 30})
+
+	// Only losses, but that's not enough closes to tell without the history
+	start := time.Date(2026, 3, 16, 14, 0, 0, 0, time.UTC)
+	for i := range rsiPeriod + 2 {
+		at := start.Add(time.Duration(i) * time.Minute)
+		engine.evaluate(map[string]any{"T": "b", "S": "AAPL", "t": at.Format(time.RFC3339), "c": 100 - float64(i)})
+	}
+
+	select {
+	case <-placed:
+		t.Fatal("expected the rsi to wait for the history")
+	default:
+	}
+
+	// The history overlaps with the stream by a bar, which is only counted once
+	var bars []marketdata.Bar
+	for i := range rsiWindow {
+		bars = append(bars, marketdata.Bar{Time: start.Add(time.Duration(i-rsiWindow+1) * time.Minute), Close: 100 + float64(rsiWindow-1-i)})
+	}
+	engine.warmUp(seed{symbol: "AAPL", bars: bars})
+
+	closes := engine.closes["AAPL"]
+	if len(closes) != rsiWindow || closes[len(closes)-rsiPeriod-3] != bars[len(bars)-2].Close {
+		t.Fatalf("expected the history before the stream, got %v", closes)
+	}
+
+	engine.evaluate(map[string]any{"T": "t", "S": "AAPL", "p": 80.0})
+	select {
+	case order := <-placed:
+		if order.ID != "1" {
+			t.Fatalf("unexpected order %+v", order)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the order to trigger once the rsi is warmed up")
+	}
+}
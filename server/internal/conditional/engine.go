@@ -0,0 +1,387 @@
+package conditional
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/indicators"
+	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
+	"github.com/Phantomvv1/KayTrade/internal/paper"
+	"github.com/Phantomvv1/KayTrade/internal/trading"
+	"github.com/jackc/pgx/v5"
+)
+
+// The number of bar to bar changes the RSI is calculated from
+const rsiPeriod = 14
+
+// The number of closes kept for the RSI. Wilder's smoothing depends on every close before the last one, so it
+// gets several periods to settle down the same way it does for the indicators API and the chart overlay.
+const rsiWindow = 10 * rsiPeriod
+
+// How far back the minute bars the RSI is warmed up with go, far enough to get over a long weekend
+const rsiLookback = 7 * 24 * time.Hour
+
+// history gets the bars a symbol is warmed up with when it's first watched
+type history func(symbol string, timeframe marketdata.TimeFrame, start, end time.Time) ([]marketdata.Bar, error)
+
+// seed is the history of a symbol, or the error of getting it
+type seed struct {
+	symbol string
+	bars   []marketdata.Bar
+	err    error
+}
+
+// Engine watches the pending conditional orders. It consumes the same upstream stream as the
+// market data hub, subscribing only to the symbols that have pending orders. The orders of the users
+// that trade with their paper account are placed on the paper engine. The RSI of a symbol is only
+// evaluated once it's warmed up with the minute bars from before the symbol was watched.
+type Engine struct {
+	Add       chan *ConditionalOrder
+	Cancel    chan string
+	hub       *marketdata.Hub
+	paper     *paper.Engine
+	updates   chan map[string]any
+	seeds     chan seed
+	orders    map[string]map[string]*ConditionalOrder // symbol -> id -> order
+	feeds     map[string]*marketdata.User
+	prices    map[string]float64
+	closes    map[string][]float64
+	firstBars map[string]time.Time // the first bar of the stream, the history is only taken from before it
+	warm      map[string]bool
+	history   history
+	submit    func(order *ConditionalOrder, canceled []string)
+}
+
+func NewEngine(hub *marketdata.Hub, paperEngine *paper.Engine) *Engine {
+	e := &Engine{
+		Add:       make(chan *ConditionalOrder),
+		Cancel:    make(chan string),
+		hub:       hub,
+		paper:     paperEngine,
+		updates:   make(chan map[string]any),
+		seeds:     make(chan seed),
+		orders:    make(map[string]map[string]*ConditionalOrder),
+		feeds:     make(map[string]*marketdata.User),
+		prices:    make(map[string]float64),
+		closes:    make(map[string][]float64),
+		firstBars: make(map[string]time.Time),
+		warm:      make(map[string]bool),
+		history:   marketdata.GetBars,
+	}
+	e.submit = e.place
+
+	return e
+}
+
+func (e *Engine) Run() {
+	go e.load()
+
+	for {
+		select {
+		case order := <-e.Add:
+			e.watch(order)
+		case id := <-e.Cancel:
+			e.forget(id)
+		case update := <-e.updates:
+			e.evaluate(update)
+		case s := <-e.seeds:
+			e.warmUp(s)
+		}
+	}
+}
+
+// Picks up the orders that were still pending when the server was stopped
+func (e *Engine) load() {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = CreateConditionalOrdersTable(conn); err != nil {
+		log.Println(err)
+		return
+	}
+
+	rows, err := conn.Query(context.Background(), selectConditionalOrders+" where status = $1", StatusPending)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	orders, err := collectConditionalOrders(rows)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, order := range orders {
+		e.Add <- order
+	}
+}
+
+func (e *Engine) watch(order *ConditionalOrder) {
+	if e.orders[order.Symbol] == nil {
+		e.orders[order.Symbol] = make(map[string]*ConditionalOrder)
+	}
+	e.orders[order.Symbol][order.ID] = order
+
+	if _, ok := e.feeds[order.Symbol]; ok || e.hub == nil {
+		return
+	}
+
+	user, updates := marketdata.NewSubscriber(order.Symbol, marketdata.Trades, marketdata.Bars)
+	e.feeds[order.Symbol] = user
+
+	// The hub may be busy, so we never wait on it from the engine loop
+	go func() { e.hub.Register <- user }()
+	go e.forward(updates)
+	go e.seed(order.Symbol)
+}
+
+// seed gets the minute bars of the symbol from before it was watched in the background
+func (e *Engine) seed(symbol string) {
+	end := time.Now()
+	bars, err := e.history(symbol, "1T", end.Add(-rsiLookback), end)
+	e.seeds <- seed{symbol: symbol, bars: bars, err: err}
+}
+
+// warmUp puts the closes of the history before the ones of the stream. Without the history the RSI waits
+// until the stream alone has a full window of closes.
+func (e *Engine) warmUp(s seed) {
+	if _, ok := e.orders[s.symbol]; !ok {
+		return
+	}
+
+	if s.err != nil {
+		log.Println(s.err)
+		return
+	}
+
+	first, streaming := e.firstBars[s.symbol]
+
+	var closes []float64
+	for _, bar := range s.bars {
+		if streaming && !bar.Time.Before(first) {
+			break
+		}
+
+		closes = append(closes, bar.Close)
+	}
+
+	closes = append(closes, e.closes[s.symbol]...)
+	e.closes[s.symbol] = closes[max(len(closes)-rsiWindow, 0):]
+	e.warm[s.symbol] = true
+}
+
+func (e *Engine) forward(updates <-chan map[string]any) {
+	for update := range updates {
+		if msg, ok := update["error"]; ok {
+			log.Println(msg)
+			continue
+		}
+
+		e.updates <- update
+	}
+}
+
+func (e *Engine) forget(id string) {
+	for symbol, orders := range e.orders {
+		if _, ok := orders[id]; !ok {
+			continue
+		}
+
+		delete(orders, id)
+		if len(orders) > 0 {
+			return
+		}
+
+		delete(e.orders, symbol)
+		delete(e.prices, symbol)
+		delete(e.closes, symbol)
+		delete(e.firstBars, symbol)
+		delete(e.warm, symbol)
+
+		if user, ok := e.feeds[symbol]; ok {
+			delete(e.feeds, symbol)
+			go func() { e.hub.Unregister <- user }()
+		}
+
+		return
+	}
+}
+
+type tick struct {
+	previous float64
+	price    float64
+	seen     bool
+	rsi      float64
+	hasRSI   bool
+}
+
+func (e *Engine) evaluate(update map[string]any) {
+	symbol, _ := update["S"].(string)
+	if _, ok := e.orders[symbol]; !ok {
+		return
+	}
+
+	var price float64
+	var ok bool
+	switch update["T"] {
+	case "t":
+		price, ok = update["p"].(float64)
+	case "b":
+		price, ok = update["c"].(float64)
+		if ok {
+			stamp, _ := update["t"].(string)
+			if _, streaming := e.firstBars[symbol]; !streaming {
+				if first, err := time.Parse(time.RFC3339, stamp); err == nil {
+					e.firstBars[symbol] = first
+				}
+			}
+
+			closes := append(e.closes[symbol], price)
+			if len(closes) > rsiWindow {
+				closes = closes[len(closes)-rsiWindow:]
+			}
+			e.closes[symbol] = closes
+		}
+	}
+
+	if !ok {
+		return
+	}
+
+	t := tick{price: price}
+	t.previous, t.seen = e.prices[symbol]
+	if e.warm[symbol] || len(e.closes[symbol]) >= rsiWindow {
+		t.rsi, t.hasRSI = RSI(e.closes[symbol])
+	}
+	e.prices[symbol] = price
+
+	for _, order := range e.orders[symbol] {
+		if order.triggered(t) {
+			e.trigger(order)
+		}
+	}
+}
+
+func (o *ConditionalOrder) triggered(t tick) bool {
+	switch o.Condition {
+	case PriceCrossesAbove:
+		return t.seen && t.previous < o.Threshold && t.price >= o.Threshold
+	case PriceCrossesBelow:
+		return t.seen && t.previous > o.Threshold && t.price <= o.Threshold
+	case RSIBelow:
+		return t.hasRSI && t.rsi < o.Threshold
+	case RSIAbove:
+		return t.hasRSI && t.rsi > o.Threshold
+	default:
+		return false
+	}
+}
+
+// Stops watching the order and the rest of its one-cancels-other group before placing it
+func (e *Engine) trigger(order *ConditionalOrder) {
+	e.forget(order.ID)
+
+	var canceled []string
+	if order.OCOGroup != "" {
+		for _, orders := range e.orders {
+			for _, other := range orders {
+				if other.UserID == order.UserID && other.OCOGroup == order.OCOGroup {
+					canceled = append(canceled, other.ID)
+				}
+			}
+		}
+
+		for _, id := range canceled {
+			e.forget(id)
+		}
+	}
+
+	go e.submit(order, canceled)
+}
+
+func (e *Engine) place(order *ConditionalOrder, canceled []string) {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	reqBody, err := json.Marshal(order.Order)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	// Claims the order before submitting it, so a cancel that lands in the meantime wins
+	tag, err := conn.Exec(context.Background(), "update conditional_orders set status = $1, triggered_at = $2 where id = $3 and status = $4",
+		StatusTriggered, time.Now(), order.ID, StatusPending)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if tag.RowsAffected() == 0 {
+		return
+	}
+
+	if len(canceled) > 0 {
+		_, err = conn.Exec(context.Background(), "update conditional_orders set status = $1 where id = any($2) and status = $3", StatusCanceled, canceled, StatusPending)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	var body map[string]any
+	enabled, err := paper.IsEnabled(order.UserID)
+	if err == nil && enabled {
+		body, err = e.paper.Place(order.UserID, reqBody)
+	} else if err == nil {
+		body, err = trading.SubmitOrder(order.UserID, bytes.NewReader(reqBody))
+	}
+
+	if err != nil {
+		log.Println(err)
+		_, err = conn.Exec(context.Background(), "update conditional_orders set status = $1, error = $2 where id = $3",
+			StatusFailed, err.Error(), order.ID)
+		if err != nil {
+			log.Println(err)
+		}
+
+		return
+	}
+
+	// The paper orders are kept by the paper account
+	if !enabled {
+		if err = trading.CreateOrdersTable(conn); err != nil {
+			log.Println(err)
+		} else if err = trading.SaveOrder(conn, order.UserID, body); err != nil {
+			log.Println(err)
+		}
+	}
+
+	orderID, _ := body["id"].(string)
+	_, err = conn.Exec(context.Background(), "update conditional_orders set order_id = $1 where id = $2", orderID, order.ID)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// RSI calculates the relative strength index from the given closes, oldest first, smoothing it over the last
+// rsiWindow of them. It needs at least rsiPeriod + 1 closes.
+func RSI(closes []float64) (float64, bool) {
+	if len(closes) < rsiPeriod+1 {
+		return 0, false
+	}
+
+	return indicators.RSI(closes[max(len(closes)-rsiWindow, 0):], rsiPeriod).Last()
+}
@@ -0,0 +1,134 @@
+package corporateactions
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeDividend = "dividend"
+	TypeMerger   = "merger"
+	TypeSpinoff  = "spinoff"
+	TypeSplit    = "split"
+)
+
+// The announcements of these sub types replace the symbol of the target with the one of the initiator
+var symbolChanges = []string{"merger_completion", "recapitalization"}
+
+const dateLayout = "2006-01-02"
+
+// Announcement is a corporate action announced for a symbol
+type Announcement struct {
+	ID                string     `json:"id"`
+	CorporateActionID string     `json:"corporate_action_id"`
+	Type              string     `json:"ca_type"`
+	SubType           string     `json:"ca_sub_type"`
+	InitiatingSymbol  string     `json:"initiating_symbol"`
+	TargetSymbol      string     `json:"target_symbol"`
+	DeclarationDate   *time.Time `json:"declaration_date"`
+	ExDate            *time.Time `json:"ex_date"`
+	RecordDate        *time.Time `json:"record_date"`
+	PayableDate       *time.Time `json:"payable_date"`
+	Cash              float64    `json:"cash"`
+	OldRate           float64    `json:"old_rate"`
+	NewRate           float64    `json:"new_rate"`
+}
+
+// announcement is an Announcement as the Broker API returns it, with the numbers and the dates as strings
+type announcement struct {
+	ID                string  `json:"id"`
+	CorporateActionID string  `json:"corporate_action_id"`
+	Type              string  `json:"ca_type"`
+	SubType           string  `json:"ca_sub_type"`
+	InitiatingSymbol  string  `json:"initiating_symbol"`
+	TargetSymbol      string  `json:"target_symbol"`
+	DeclarationDate   *string `json:"declaration_date"`
+	ExDate            *string `json:"ex_date"`
+	RecordDate        *string `json:"record_date"`
+	PayableDate       *string `json:"payable_date"`
+	Cash              string  `json:"cash"`
+	OldRate           string  `json:"old_rate"`
+	NewRate           string  `json:"new_rate"`
+}
+
+func parseDate(date *string) *time.Time {
+	if date == nil || *date == "" {
+		return nil
+	}
+
+	parsed, err := time.Parse(dateLayout, *date)
+	if err != nil {
+		return nil
+	}
+
+	return &parsed
+}
+
+func (a announcement) parse() Announcement {
+	cash, _ := strconv.ParseFloat(a.Cash, 64)
+	oldRate, _ := strconv.ParseFloat(a.OldRate, 64)
+	newRate, _ := strconv.ParseFloat(a.NewRate, 64)
+
+	return Announcement{
+		ID:                a.ID,
+		CorporateActionID: a.CorporateActionID,
+		Type:              strings.ToLower(a.Type),
+		SubType:           strings.ToLower(a.SubType),
+		InitiatingSymbol:  strings.ToUpper(a.InitiatingSymbol),
+		TargetSymbol:      strings.ToUpper(a.TargetSymbol),
+		DeclarationDate:   parseDate(a.DeclarationDate),
+		ExDate:            parseDate(a.ExDate),
+		RecordDate:        parseDate(a.RecordDate),
+		PayableDate:       parseDate(a.PayableDate),
+		Cash:              cash,
+		OldRate:           oldRate,
+		NewRate:           newRate,
+	}
+}
+
+// EffectiveDate is the day the action changes the positions: the ex-date, or the closest date to it that was announced
+func (a Announcement) EffectiveDate() *time.Time {
+	for _, date := range []*time.Time{a.ExDate, a.RecordDate, a.PayableDate} {
+		if date != nil {
+			return date
+		}
+	}
+
+	return nil
+}
+
+// SymbolChange returns the old and the new symbol when the action replaces the ticker of a company
+func (a Announcement) SymbolChange() (string, string, bool) {
+	if !slices.Contains(symbolChanges, a.SubType) {
+		return "", "", false
+	}
+
+	if a.TargetSymbol == "" || a.InitiatingSymbol == "" || a.TargetSymbol == a.InitiatingSymbol {
+		return "", "", false
+	}
+
+	return a.TargetSymbol, a.InitiatingSymbol, true
+}
+
+// Affects tells if the action changes the positions in the symbol
+func (a Announcement) Affects(symbol string) bool {
+	return a.InitiatingSymbol == symbol || a.TargetSymbol == symbol
+}
+
+// Due returns the symbol changes that have taken effect by now
+func Due(announcements []Announcement, now time.Time) []Announcement {
+	var result []Announcement
+	for _, a := range announcements {
+		if _, _, ok := a.SymbolChange(); !ok {
+			continue
+		}
+
+		if date := a.EffectiveDate(); date != nil && !date.After(now) {
+			result = append(result, a)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,66 @@
+package corporateactions
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+func TestParse(t *testing.T) {
+	exDate := "2025-05-12"
+	empty := ""
+	a := announcement{ID: "1", Type: "Dividend", SubType: "cash", InitiatingSymbol: "aapl", ExDate: &exDate, RecordDate: &empty, Cash: "0.26"}.parse()
+
+	if a.Type != TypeDividend || a.InitiatingSymbol != "AAPL" || a.Cash != 0.26 {
+		t.Fatalf("unexpected announcement %+v", a)
+	}
+
+	if a.ExDate == nil || !a.ExDate.Equal(*date(2025, time.May, 12)) || a.RecordDate != nil || a.PayableDate != nil {
+		t.Fatalf("unexpected dates %v %v %v", a.ExDate, a.RecordDate, a.PayableDate)
+	}
+}
+
+func TestSymbolChange(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Announcement
+		ok   bool
+	}{
+		{"merger completion", Announcement{SubType: "merger_completion", InitiatingSymbol: "NEW", TargetSymbol: "OLD"}, true},
+		{"recapitalization", Announcement{SubType: "recapitalization", InitiatingSymbol: "NEW", TargetSymbol: "OLD"}, true},
+		{"same symbol", Announcement{SubType: "recapitalization", InitiatingSymbol: "OLD", TargetSymbol: "OLD"}, false},
+		{"merger update", Announcement{SubType: "merger_update", InitiatingSymbol: "NEW", TargetSymbol: "OLD"}, false},
+		{"split", Announcement{SubType: "forward_split", InitiatingSymbol: "AAPL"}, false},
+	}
+
+	for _, tt := range tests {
+		oldSymbol, newSymbol, ok := tt.a.SymbolChange()
+		if ok != tt.ok {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.ok, ok)
+		}
+
+		if ok && (oldSymbol != "OLD" || newSymbol != "NEW") {
+			t.Fatalf("%s: unexpected change from %s to %s", tt.name, oldSymbol, newSymbol)
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	announcements := []Announcement{
+		{ID: "past", SubType: "recapitalization", InitiatingSymbol: "NEW", TargetSymbol: "OLD", ExDate: date(2025, time.May, 30)},
+		{ID: "future", SubType: "recapitalization", InitiatingSymbol: "NEW", TargetSymbol: "OLD", ExDate: date(2025, time.June, 10)},
+		{ID: "payable", SubType: "merger_completion", InitiatingSymbol: "NEW", TargetSymbol: "OLD", PayableDate: date(2025, time.June, 1)},
+		{ID: "undated", SubType: "merger_completion", InitiatingSymbol: "NEW", TargetSymbol: "OLD"},
+		{ID: "dividend", Type: TypeDividend, SubType: "cash", InitiatingSymbol: "AAPL", ExDate: date(2025, time.May, 1)},
+	}
+
+	due := Due(announcements, now)
+	if len(due) != 2 || due[0].ID != "past" || due[1].ID != "payable" {
+		t.Fatalf("unexpected announcements %+v", due)
+	}
+}
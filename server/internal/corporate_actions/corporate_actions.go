@@ -0,0 +1,172 @@
+package corporateactions
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/Phantomvv1/KayTrade/internal/watchlist"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// How long the announcements stay relevant after their ex-date
+const history = 30 * 24 * time.Hour
+
+var errs = map[int]string{
+	400: "One of the request parameters is invalid",
+	403: "Authentication headers are missing or invalid",
+	404: "Resource doesn't exist",
+	422: "Some parameters are invalid",
+	500: "Internal server error. We recommend retrying these later",
+}
+
+func CreateCorporateActionsTable(conn *pgx.Conn) error {
+	_, err := conn.Exec(context.Background(), "create table if not exists corporate_actions(id text primary key, corporate_action_id text, ca_type text, "+
+		"ca_sub_type text, initiating_symbol text, target_symbol text, declaration_date date, ex_date date, record_date date, payable_date date, "+
+		"cash double precision default 0, old_rate double precision default 0, new_rate double precision default 0, migrated boolean default false, "+
+		"updated_at timestamp default current_timestamp)")
+	return err
+}
+
+const selectAnnouncements = "select id, corporate_action_id, ca_type, ca_sub_type, initiating_symbol, target_symbol, declaration_date, ex_date, " +
+	"record_date, payable_date, cash, old_rate, new_rate from corporate_actions"
+
+func collectAnnouncements(rows pgx.Rows) ([]Announcement, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Announcement, error) {
+		a := Announcement{}
+		err := row.Scan(&a.ID, &a.CorporateActionID, &a.Type, &a.SubType, &a.InitiatingSymbol, &a.TargetSymbol, &a.DeclarationDate, &a.ExDate,
+			&a.RecordDate, &a.PayableDate, &a.Cash, &a.OldRate, &a.NewRate)
+		return a, err
+	})
+}
+
+// GetAnnouncements returns the saved announcements of the symbols whose ex-date isn't older than since
+func GetAnnouncements(conn *pgx.Conn, symbols []string, since time.Time) ([]Announcement, error) {
+	if err := CreateCorporateActionsTable(conn); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(context.Background(), selectAnnouncements+" where (initiating_symbol = any($1) or target_symbol = any($1)) "+
+		"and coalesce(ex_date, record_date, payable_date, $2) >= $2 order by coalesce(ex_date, record_date, payable_date)", symbols, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectAnnouncements(rows)
+}
+
+// GetPayableAnnouncements returns the saved announcements of the symbols that aren't paid before since. The ones that
+// already went ex but aren't paid yet are still owed to the holders.
+func GetPayableAnnouncements(conn *pgx.Conn, symbols []string, since time.Time) ([]Announcement, error) {
+	if err := CreateCorporateActionsTable(conn); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(context.Background(), selectAnnouncements+" where (initiating_symbol = any($1) or target_symbol = any($1)) "+
+		"and coalesce(payable_date, ex_date, $2) >= $2 order by coalesce(payable_date, ex_date)", symbols, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectAnnouncements(rows)
+}
+
+func saveAnnouncement(conn *pgx.Conn, a Announcement) error {
+	_, err := conn.Exec(context.Background(), "insert into corporate_actions (id, corporate_action_id, ca_type, ca_sub_type, initiating_symbol, target_symbol, "+
+		"declaration_date, ex_date, record_date, payable_date, cash, old_rate, new_rate) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) "+
+		"on conflict (id) do update set ca_sub_type = $4, declaration_date = $7, ex_date = $8, record_date = $9, payable_date = $10, cash = $11, "+
+		"old_rate = $12, new_rate = $13, updated_at = current_timestamp",
+		a.ID, a.CorporateActionID, a.Type, a.SubType, a.InitiatingSymbol, a.TargetSymbol, a.DeclarationDate, a.ExDate, a.RecordDate, a.PayableDate,
+		a.Cash, a.OldRate, a.NewRate)
+	return err
+}
+
+type position struct {
+	Symbol string `json:"symbol"`
+}
+
+type allPositions struct {
+	Positions map[string][]position `json:"positions"`
+}
+
+// heldSymbols returns the symbols of the positions of every account
+func heldSymbols() ([]string, error) {
+	body, err := SendRequest[allPositions](http.MethodGet, BaseURL+Accounts+"positions", nil, errs, BasicAuth())
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []string
+	for _, positions := range body.Positions {
+		for _, p := range positions {
+			symbols = append(symbols, p.Symbol)
+		}
+	}
+
+	return symbols, nil
+}
+
+// userSymbols returns the symbols the user holds or watches
+func userSymbols(conn *pgx.Conn, id string) ([]string, error) {
+	if err := watchlist.CreateWatchlistTable(conn); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(context.Background(), "select symbol from wishlist where user_id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := SendRequest[[]position](http.MethodGet, BaseURL+Trading+id+"/positions", nil, errs, BasicAuth())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range positions {
+		symbols = append(symbols, p.Symbol)
+	}
+
+	slices.Sort(symbols)
+	return slices.Compact(symbols), nil
+}
+
+// GetCorporateActions returns the corporate actions of a symbol, or of every symbol the user holds or watches
+func GetCorporateActions(c *gin.Context) {
+	id := c.GetString("id")
+	symbol := strings.ToUpper(c.Query("symbol"))
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	symbols := []string{symbol}
+	if symbol == "" {
+		symbols, err = userSymbols(conn, id)
+		if err != nil {
+			ErrorExit(c, http.StatusFailedDependency, "couldn't get the symbols you hold and watch", err)
+			return
+		}
+	}
+
+	announcements, err := GetAnnouncements(conn, symbols, time.Now().UTC().Add(-history).Truncate(24*time.Hour))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the corporate actions from the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"corporate_actions": announcements})
+}
@@ -0,0 +1,167 @@
+package corporateactions
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/Phantomvv1/KayTrade/internal/watchlist"
+	"github.com/jackc/pgx/v5"
+)
+
+// The Broker API returns the announcements of at most 90 days at once
+const (
+	lookBehind = 7 * 24 * time.Hour
+	lookAhead  = 83 * 24 * time.Hour
+)
+
+var announcementTypes = []string{TypeDividend, TypeMerger, TypeSpinoff, TypeSplit}
+
+// Ingester keeps the announcements of the symbols the users hold or watch up to date
+// and moves the watchlists to the new symbols of the companies that changed them
+type Ingester struct {
+	Interval time.Duration
+}
+
+func NewIngester() *Ingester {
+	return &Ingester{Interval: time.Hour}
+}
+
+// Run ingests right away, so a fresh server has the announcements without waiting for the first interval
+func (i *Ingester) Run() {
+	if err := i.ingest(time.Now().UTC()); err != nil {
+		log.Println(err)
+	}
+
+	ticker := time.NewTicker(i.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := i.ingest(time.Now().UTC()); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (i *Ingester) ingest(now time.Time) error {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if err = CreateCorporateActionsTable(conn); err != nil {
+		return err
+	}
+
+	symbols, err := trackedSymbols(conn)
+	if err != nil {
+		return err
+	}
+
+	for _, symbol := range symbols {
+		announcements, err := fetchAnnouncements(symbol, now)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		for _, a := range announcements {
+			if err := saveAnnouncement(conn, a); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	return migrateWatchlists(conn, now)
+}
+
+// trackedSymbols returns the stocks at least one user holds or watches
+func trackedSymbols(conn *pgx.Conn) ([]string, error) {
+	if err := watchlist.CreateWatchlistTable(conn); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(context.Background(), "select distinct symbol from wishlist")
+	if err != nil {
+		return nil, err
+	}
+
+	symbols, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	held, err := heldSymbols()
+	if err != nil {
+		return nil, err
+	}
+	symbols = append(symbols, held...)
+
+	// Crypto pairs and options contracts don't have corporate actions
+	symbols = slices.DeleteFunc(symbols, func(symbol string) bool {
+		return strings.Contains(symbol, "/") || len(symbol) > 6
+	})
+
+	slices.Sort(symbols)
+	return slices.Compact(symbols), nil
+}
+
+func fetchAnnouncements(symbol string, now time.Time) ([]Announcement, error) {
+	query := url.Values{}
+	query.Set("ca_types", strings.Join(announcementTypes, ","))
+	query.Set("since", now.Add(-lookBehind).Format(dateLayout))
+	query.Set("until", now.Add(lookAhead).Format(dateLayout))
+	query.Set("symbol", symbol)
+
+	body, err := SendRequest[[]announcement](http.MethodGet, BaseURL+CorporateActions+"/announcements?"+query.Encode(), nil, errs, BasicAuth())
+	if err != nil {
+		return nil, err
+	}
+
+	announcements := make([]Announcement, len(body))
+	for i, a := range body {
+		announcements[i] = a.parse()
+	}
+
+	return announcements, nil
+}
+
+// migrateWatchlists applies the symbol changes that have taken effect, each one only once
+func migrateWatchlists(conn *pgx.Conn, now time.Time) error {
+	rows, err := conn.Query(context.Background(), selectAnnouncements+" where migrated = false and ca_sub_type = any($1)", symbolChanges)
+	if err != nil {
+		return err
+	}
+
+	announcements, err := collectAnnouncements(rows)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range Due(announcements, now) {
+		oldSymbol, newSymbol, _ := a.SymbolChange()
+
+		migrated, err := watchlist.MigrateSymbol(conn, oldSymbol, newSymbol)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if migrated > 0 {
+			log.Printf("Moved %d watchlist entries from %s to %s\n", migrated, oldSymbol, newSymbol)
+		}
+
+		if _, err := conn.Exec(context.Background(), "update corporate_actions set migrated = true where id = $1", a.ID); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package dividends
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	corporateactions "github.com/Phantomvv1/KayTrade/internal/corporate_actions"
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const ActivityDividend = "DIV"
+
+// The dividend related activities: the dividends themselves, their capital gains and return of capital parts,
+// the fees and the taxes withheld from them
+var activityTypes = []string{ActivityDividend, "DIVCGL", "DIVCGS", "DIVROC", "DIVTXEX", "DIVFEE", "DIVFT", "DIVNRA", "DIVTW"}
+
+const dateLayout = "2006-01-02"
+
+type activity struct {
+	ID             string `json:"id"`
+	ActivityType   string `json:"activity_type"`
+	Symbol         string `json:"symbol"`
+	Qty            string `json:"qty"`
+	PerShareAmount string `json:"per_share_amount"`
+	NetAmount      string `json:"net_amount"`
+	Date           string `json:"date"`
+	Description    string `json:"description"`
+}
+
+type position struct {
+	Symbol string `json:"symbol"`
+	Qty    string `json:"qty"`
+}
+
+// Report is the dividend income of the user, the received and the projected
+type Report struct {
+	History           []Dividend `json:"history"`
+	Received          []Month    `json:"received"`
+	ReceivedTotal     float64    `json:"received_total"`
+	Projection        []Month    `json:"projection"`
+	ProjectedTotal    float64    `json:"projected_total"`
+	ProjectedPayments []Payment  `json:"projected_payments"`
+}
+
+func CreateDividendsTable(conn *pgx.Conn) error {
+	_, err := conn.Exec(context.Background(), "create table if not exists dividends(id text primary key, user_id uuid references authentication(id) on delete cascade, "+
+		"symbol text, activity_type text, qty double precision default 0, per_share_amount double precision default 0, net_amount double precision, "+
+		"date date, description text default '')")
+	return err
+}
+
+func (a activity) parse() (Dividend, error) {
+	date, err := time.Parse(dateLayout, a.Date)
+	if err != nil {
+		return Dividend{}, err
+	}
+
+	netAmount, err := strconv.ParseFloat(a.NetAmount, 64)
+	if err != nil {
+		return Dividend{}, err
+	}
+
+	// Taxes and fees don't have a quantity or an amount per share
+	qty, _ := strconv.ParseFloat(a.Qty, 64)
+	perShare, _ := strconv.ParseFloat(a.PerShareAmount, 64)
+
+	return Dividend{
+		ID:             a.ID,
+		Symbol:         a.Symbol,
+		ActivityType:   a.ActivityType,
+		Qty:            qty,
+		PerShareAmount: perShare,
+		NetAmount:      netAmount,
+		Date:           date,
+		Description:    a.Description,
+	}, nil
+}
+
+// getActivities returns the dividend activities of the account after the given date
+func getActivities(id string, after *time.Time) ([]Dividend, error) {
+	query := url.Values{}
+	query.Set("account_id", id)
+	query.Set("activity_types", strings.Join(activityTypes, ","))
+	query.Set("direction", "asc")
+	if after != nil {
+		query.Set("after", after.Format(dateLayout))
+	}
+
+	activities, err := GetActivities(query, func(a activity) string { return a.ID })
+	if err != nil {
+		return nil, err
+	}
+
+	dividends := make([]Dividend, 0, len(activities))
+	for _, a := range activities {
+		dividend, err := a.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		dividends = append(dividends, dividend)
+	}
+
+	return dividends, nil
+}
+
+// Sync saves the dividend activities of the user that aren't saved yet. Alpaca is asked only for the days
+// after the last saved activity, that day included, because more activities may have come on it.
+func Sync(conn *pgx.Conn, id string) error {
+	if err := CreateDividendsTable(conn); err != nil {
+		return err
+	}
+
+	var last *time.Time
+	err := conn.QueryRow(context.Background(), "select max(date) from dividends where user_id = $1", id).Scan(&last)
+	if err != nil {
+		return err
+	}
+
+	if last != nil {
+		day := last.AddDate(0, 0, -1)
+		last = &day
+	}
+
+	activities, err := getActivities(id, last)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range activities {
+		_, err := conn.Exec(context.Background(), "insert into dividends (id, user_id, symbol, activity_type, qty, per_share_amount, net_amount, date, description) "+
+			"values ($1, $2, $3, $4, $5, $6, $7, $8, $9) on conflict (id) do nothing",
+			d.ID, id, d.Symbol, d.ActivityType, d.Qty, d.PerShareAmount, d.NetAmount, d.Date, d.Description)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getDividends(conn *pgx.Conn, id string) ([]Dividend, error) {
+	rows, err := conn.Query(context.Background(), "select id, symbol, activity_type, qty, per_share_amount, net_amount, date, description from dividends "+
+		"where user_id = $1 order by date desc", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Dividend, error) {
+		d := Dividend{}
+		err := row.Scan(&d.ID, &d.Symbol, &d.ActivityType, &d.Qty, &d.PerShareAmount, &d.NetAmount, &d.Date, &d.Description)
+		return d, err
+	})
+}
+
+func getHoldings(id string) ([]Holding, error) {
+	positions, err := SendRequest[[]position](http.MethodGet, BaseURL+Trading+id+"/positions", nil, nil, BasicAuth())
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := make([]Holding, 0, len(positions))
+	for _, p := range positions {
+		qty, err := strconv.ParseFloat(p.Qty, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		holdings = append(holdings, Holding{Symbol: p.Symbol, Qty: qty})
+	}
+
+	return holdings, nil
+}
+
+func sum(months []Month) float64 {
+	total := 0.0
+	for _, m := range months {
+		total += m.Amount
+	}
+
+	return round(total)
+}
+
+func GetDividends(c *gin.Context) {
+	id := c.GetString("id")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = Sync(conn, id); err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't get the dividends of the account", err)
+		return
+	}
+
+	history, err := getDividends(conn, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the dividends from the database", err)
+		return
+	}
+
+	holdings, err := getHoldings(id)
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the positions of the account")
+		return
+	}
+
+	symbols := make([]string, len(holdings))
+	for i, h := range holdings {
+		symbols[i] = h.Symbol
+	}
+
+	now := time.Now().UTC()
+	announcements, err := corporateactions.GetPayableAnnouncements(conn, symbols, now.Truncate(24*time.Hour))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the announced dividends from the database", err)
+		return
+	}
+
+	received := Monthly(history, now)
+	payments := Project(holdings, history, announcements, now)
+	projection := ProjectMonthly(payments, now)
+
+	c.JSON(http.StatusOK, Report{
+		History:           history,
+		Received:          received,
+		ReceivedTotal:     sum(received),
+		Projection:        projection,
+		ProjectedTotal:    sum(projection),
+		ProjectedPayments: payments,
+	})
+}
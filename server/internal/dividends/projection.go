@@ -0,0 +1,165 @@
+package dividends
+
+import (
+	"math"
+	"time"
+
+	corporateactions "github.com/Phantomvv1/KayTrade/internal/corporate_actions"
+)
+
+// The number of months the income is projected for
+const projectionMonths = 12
+
+// A payment from the history isn't repeated when a dividend of the same symbol is announced this close to its projected date
+const announcedWindow = 20 * 24 * time.Hour
+
+const monthLayout = "2006-01"
+
+// Dividend is one dividend related activity of the account
+type Dividend struct {
+	ID             string    `json:"id"`
+	Symbol         string    `json:"symbol"`
+	ActivityType   string    `json:"activity_type"`
+	Qty            float64   `json:"qty"`
+	PerShareAmount float64   `json:"per_share_amount"`
+	NetAmount      float64   `json:"net_amount"`
+	Date           time.Time `json:"date"`
+	Description    string    `json:"description"`
+}
+
+type Holding struct {
+	Symbol string
+	Qty    float64
+}
+
+type Month struct {
+	Month  string  `json:"month"`
+	Amount float64 `json:"amount"`
+}
+
+// Payment is a dividend that is expected to be paid
+type Payment struct {
+	Symbol    string    `json:"symbol"`
+	Date      time.Time `json:"date"`
+	Amount    float64   `json:"amount"`
+	Announced bool      `json:"announced"`
+}
+
+// Monthly sums the net amounts of the dividends received in each of the months before now, the oldest first
+func Monthly(history []Dividend, now time.Time) []Month {
+	start := firstOfMonth(now).AddDate(0, -projectionMonths+1, 0)
+	months := emptyMonths(start)
+
+	for _, d := range history {
+		if i := monthIndex(start, d.Date); i >= 0 && i < len(months) {
+			months[i].Amount += d.NetAmount
+		}
+	}
+
+	return roundMonths(months)
+}
+
+// Project estimates the dividends of the next 12 months. The announced cash dividends are paid for the shares held now
+// and every other dividend received in the last 12 months is expected again a year later, for the shares held now.
+func Project(holdings []Holding, history []Dividend, announcements []corporateactions.Announcement, now time.Time) []Payment {
+	today := now.UTC().Truncate(24 * time.Hour)
+	end := today.AddDate(0, projectionMonths, 0)
+
+	qty := make(map[string]float64)
+	for _, h := range holdings {
+		qty[h.Symbol] += h.Qty
+	}
+
+	var payments []Payment
+	for _, a := range announcements {
+		if a.Type != corporateactions.TypeDividend || a.Cash <= 0 || qty[a.InitiatingSymbol] <= 0 {
+			continue
+		}
+
+		date := paymentDate(a)
+		if date == nil || date.Before(today) || !date.Before(end) {
+			continue
+		}
+
+		payments = append(payments, Payment{Symbol: a.InitiatingSymbol, Date: *date, Amount: round(a.Cash * qty[a.InitiatingSymbol]), Announced: true})
+	}
+
+	for _, d := range history {
+		if d.ActivityType != ActivityDividend || d.PerShareAmount <= 0 || qty[d.Symbol] <= 0 {
+			continue
+		}
+
+		date := d.Date.AddDate(1, 0, 0)
+		if date.Before(today) || !date.Before(end) || announced(payments, d.Symbol, date) {
+			continue
+		}
+
+		payments = append(payments, Payment{Symbol: d.Symbol, Date: date, Amount: round(d.PerShareAmount * qty[d.Symbol])})
+	}
+
+	return payments
+}
+
+// ProjectMonthly sums the projected payments of each of the next 12 months
+func ProjectMonthly(payments []Payment, now time.Time) []Month {
+	start := firstOfMonth(now)
+	months := emptyMonths(start)
+
+	for _, p := range payments {
+		if i := monthIndex(start, p.Date); i >= 0 && i < len(months) {
+			months[i].Amount += p.Amount
+		}
+	}
+
+	return roundMonths(months)
+}
+
+// The announcements are paid on the payable date, the ex-date is used when it's not known yet
+func paymentDate(a corporateactions.Announcement) *time.Time {
+	if a.PayableDate != nil {
+		return a.PayableDate
+	}
+
+	return a.ExDate
+}
+
+func announced(payments []Payment, symbol string, date time.Time) bool {
+	for _, p := range payments {
+		if p.Announced && p.Symbol == symbol && math.Abs(float64(p.Date.Sub(date))) <= float64(announcedWindow) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func firstOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func emptyMonths(start time.Time) []Month {
+	months := make([]Month, projectionMonths)
+	for i := range months {
+		months[i].Month = start.AddDate(0, i, 0).Format(monthLayout)
+	}
+
+	return months
+}
+
+func monthIndex(start, date time.Time) int {
+	date = date.UTC()
+	return (date.Year()-start.Year())*12 + int(date.Month()) - int(start.Month())
+}
+
+func roundMonths(months []Month) []Month {
+	for i := range months {
+		months[i].Amount = round(months[i].Amount)
+	}
+
+	return months
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
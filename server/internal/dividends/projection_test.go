@@ -0,0 +1,69 @@
+package dividends
+
+import (
+	"testing"
+	"time"
+
+	corporateactions "github.com/Phantomvv1/KayTrade/internal/corporate_actions"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestMonthly(t *testing.T) {
+	now := day(2025, time.June, 15)
+	history := []Dividend{
+		{Symbol: "AAPL", ActivityType: ActivityDividend, NetAmount: 10, Date: day(2025, time.May, 15)},
+		{Symbol: "AAPL", ActivityType: "DIVNRA", NetAmount: -1.5, Date: day(2025, time.May, 15)},
+		{Symbol: "MSFT", ActivityType: ActivityDividend, NetAmount: 4, Date: day(2024, time.July, 10)},
+		{Symbol: "MSFT", ActivityType: ActivityDividend, NetAmount: 4, Date: day(2024, time.June, 10)},
+	}
+
+	months := Monthly(history, now)
+	if len(months) != 12 || months[0].Month != "2024-07" || months[11].Month != "2025-06" {
+		t.Fatalf("unexpected months %+v", months)
+	}
+
+	if months[0].Amount != 4 || months[10].Amount != 8.5 || sum(months) != 12.5 {
+		t.Fatalf("unexpected amounts %+v", months)
+	}
+}
+
+func TestProject(t *testing.T) {
+	now := day(2025, time.June, 15)
+	payable := day(2025, time.August, 14)
+	holdings := []Holding{{Symbol: "AAPL", Qty: 20}, {Symbol: "MSFT", Qty: 10}}
+	history := []Dividend{
+		// Replaced by the announced dividend
+		{Symbol: "AAPL", ActivityType: ActivityDividend, PerShareAmount: 0.25, Qty: 10, Date: day(2024, time.August, 15)},
+		{Symbol: "AAPL", ActivityType: ActivityDividend, PerShareAmount: 0.25, Qty: 10, Date: day(2024, time.November, 14)},
+		{Symbol: "AAPL", ActivityType: "DIVNRA", NetAmount: -0.5, Date: day(2024, time.November, 14)},
+		// Already paid again this year
+		{Symbol: "MSFT", ActivityType: ActivityDividend, PerShareAmount: 0.8, Qty: 10, Date: day(2024, time.June, 1)},
+		// Not held anymore
+		{Symbol: "KO", ActivityType: ActivityDividend, PerShareAmount: 0.5, Qty: 10, Date: day(2024, time.September, 1)},
+	}
+	announcements := []corporateactions.Announcement{
+		{Type: corporateactions.TypeDividend, InitiatingSymbol: "AAPL", Cash: 0.26, PayableDate: &payable},
+		{Type: corporateactions.TypeSplit, InitiatingSymbol: "MSFT", PayableDate: &payable},
+	}
+
+	payments := Project(holdings, history, announcements, now)
+	if len(payments) != 2 {
+		t.Fatalf("expected the announced and one repeated dividend, got %+v", payments)
+	}
+
+	if !payments[0].Announced || payments[0].Amount != 5.2 || !payments[0].Date.Equal(payable) {
+		t.Fatalf("unexpected announced payment %+v", payments[0])
+	}
+
+	if payments[1].Announced || payments[1].Amount != 5 || !payments[1].Date.Equal(day(2025, time.November, 14)) {
+		t.Fatalf("unexpected repeated payment %+v", payments[1])
+	}
+
+	months := ProjectMonthly(payments, now)
+	if months[0].Month != "2025-06" || months[2].Amount != 5.2 || months[5].Amount != 5 || sum(months) != 10.2 {
+		t.Fatalf("unexpected projection %+v", months)
+	}
+}
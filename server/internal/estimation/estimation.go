@@ -0,0 +1,470 @@
+package estimation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	"github.com/Phantomvv1/KayTrade/internal/options"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+)
+
+// Regulatory fees charged on sells. The SEC fee is per dollar sold and the FINRA trading activity fee is per share.
+const (
+	secFeeRate = 0.0000278
+	tafRate    = 0.000166
+	tafMaximum = 8.30
+)
+
+const roundLot = 100
+
+// The number of shares a single options contract is for
+const contractSize = 100
+
+// Warning codes
+const (
+	WarningInsufficientFunds    = "insufficient_funds"
+	WarningInsufficientPosition = "insufficient_position"
+	WarningOddLot               = "odd_lot"
+	WarningMarketClosed         = "market_closed"
+	WarningMayNotFill           = "may_not_fill"
+	WarningNoQuote              = "no_quote"
+)
+
+// Request is the order to estimate. Both numbers and strings are accepted for the amounts, just like Alpaca does.
+type Request struct {
+	Symbol         string      `json:"symbol"`
+	Side           string      `json:"side"`
+	Type           string      `json:"type"`
+	TimeInForce    string      `json:"time_in_force"`
+	Qty            json.Number `json:"qty"`
+	Notional       json.Number `json:"notional"`
+	LimitPrice     json.Number `json:"limit_price"`
+	StopPrice      json.Number `json:"stop_price"`
+	PositionIntent string      `json:"position_intent"`
+}
+
+type amounts struct {
+	qty      float64
+	notional float64
+	limit    float64
+	stop     float64
+}
+
+type Quote struct {
+	Bid float64 `json:"bp"`
+	Ask float64 `json:"ap"`
+}
+
+type Account struct {
+	BuyingPower float64
+	Cash        float64
+}
+
+// Commission is the commission schedule of the broker. The types are the same as the ones of Alpaca:
+// notional is a flat amount per order, qty is an amount per share and bps is in basis points of the order value.
+type Commission struct {
+	Type   string
+	Amount float64
+}
+
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Fees struct {
+	Commission float64 `json:"commission"`
+	Regulatory float64 `json:"regulatory"`
+	Total      float64 `json:"total"`
+}
+
+type Estimate struct {
+	Symbol               string    `json:"symbol"`
+	Side                 string    `json:"side"`
+	Qty                  float64   `json:"qty"`
+	Bid                  float64   `json:"bid"`
+	Ask                  float64   `json:"ask"`
+	Price                float64   `json:"estimated_price"`
+	Value                float64   `json:"value"`
+	Fees                 Fees      `json:"fees"`
+	Total                float64   `json:"total"`
+	BuyingPower          float64   `json:"buying_power"`
+	PostTradeBuyingPower float64   `json:"post_trade_buying_power"`
+	Cash                 float64   `json:"cash"`
+	Position             float64   `json:"position_qty"`
+	PostTradePosition    float64   `json:"post_trade_position_qty"`
+	MarketOpen           bool      `json:"market_open"`
+	Warnings             []Warning `json:"warnings"`
+}
+
+type position struct {
+	Symbol string `json:"symbol"`
+	Qty    string `json:"qty"`
+}
+
+type account struct {
+	BuyingPower string `json:"buying_power"`
+	Cash        string `json:"cash"`
+}
+
+// CommissionFromEnv reads the commission schedule from COMMISSION_TYPE and COMMISSION. Without them there's no commission.
+func CommissionFromEnv() Commission {
+	amount, err := strconv.ParseFloat(os.Getenv("COMMISSION"), 64)
+	if err != nil {
+		return Commission{}
+	}
+
+	return Commission{Type: os.Getenv("COMMISSION_TYPE"), Amount: amount}
+}
+
+func (c Commission) fee(qty, value float64) float64 {
+	switch c.Type {
+	case "notional":
+		return c.Amount
+	case "qty":
+		return c.Amount * qty
+	case "bps":
+		return value * c.Amount / 10000
+	default:
+		return 0
+	}
+}
+
+func parseOptional(value json.Number, name string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.ParseFloat(string(value), 64)
+	if err != nil || number < 0 {
+		return 0, errors.New("invalid " + name)
+	}
+
+	return number, nil
+}
+
+// estimatePrice is the price the order is expected to fill at, and whether it may not fill right away
+func estimatePrice(req Request, quote Quote, limit, stop float64) (float64, bool) {
+	market := quote.Ask
+	if req.Side == "sell" {
+		market = quote.Bid
+	}
+
+	switch req.Type {
+	case "limit", "stop_limit":
+		if market == 0 {
+			return limit, true
+		}
+
+		if req.Side == "buy" && limit < market || req.Side == "sell" && limit > market {
+			return limit, true
+		}
+
+		if req.Type == "stop_limit" {
+			return limit, true
+		}
+
+		return market, false
+
+	case "stop":
+		return stop, true
+
+	default:
+		return market, req.Type == "trailing_stop"
+	}
+}
+
+func (r *Request) parse() (amounts, error) {
+	r.Symbol = strings.ToUpper(r.Symbol)
+	if r.Symbol == "" {
+		return amounts{}, errors.New("the symbol is required")
+	}
+
+	if r.Side != "buy" && r.Side != "sell" {
+		return amounts{}, errors.New("the side should be buy or sell")
+	}
+
+	if r.Type == "" {
+		r.Type = "market"
+	}
+
+	var a amounts
+	var err error
+	if a.qty, err = parseOptional(r.Qty, "qty"); err != nil {
+		return amounts{}, err
+	}
+
+	if a.notional, err = parseOptional(r.Notional, "notional"); err != nil {
+		return amounts{}, err
+	}
+
+	if (a.qty > 0) == (a.notional > 0) {
+		return amounts{}, errors.New("exactly one of qty and notional should be positive")
+	}
+
+	if a.limit, err = parseOptional(r.LimitPrice, "limit price"); err != nil {
+		return amounts{}, err
+	}
+
+	if a.stop, err = parseOptional(r.StopPrice, "stop price"); err != nil {
+		return amounts{}, err
+	}
+
+	if (r.Type == "limit" || r.Type == "stop_limit") && a.limit == 0 {
+		return amounts{}, errors.New("the limit price is required for " + r.Type + " orders")
+	}
+
+	if (r.Type == "stop" || r.Type == "stop_limit") && a.stop == 0 {
+		return amounts{}, errors.New("the stop price is required for " + r.Type + " orders")
+	}
+
+	return a, nil
+}
+
+// Calculate puts the estimate together from everything the server knows about the account
+func Calculate(req Request, quote Quote, currentPosition float64, acc Account, commission Commission, marketOpen bool) (Estimate, error) {
+	a, err := req.parse()
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	qty := a.qty
+	crypto := isCrypto(req.Symbol)
+	option := options.IsContract(req.Symbol)
+
+	// Options are quoted per share, but every contract is for 100 of them
+	multiplier := 1.0
+	if option {
+		multiplier = contractSize
+	}
+
+	estimate := Estimate{
+		Symbol:      req.Symbol,
+		Side:        req.Side,
+		Bid:         quote.Bid,
+		Ask:         quote.Ask,
+		BuyingPower: acc.BuyingPower,
+		Cash:        acc.Cash,
+		Position:    currentPosition,
+		MarketOpen:  marketOpen,
+		Warnings:    []Warning{},
+	}
+
+	price, mayNotFill := estimatePrice(req, quote, a.limit, a.stop)
+	if price == 0 {
+		// Without a quote the best we have is the other side of the book
+		price = math.Max(quote.Bid, quote.Ask)
+		estimate.Warnings = append(estimate.Warnings, Warning{WarningNoQuote, "there is no recent quote for " + req.Symbol + ", the estimate may be off"})
+	}
+
+	if price == 0 {
+		return Estimate{}, errors.New("couldn't find a price for " + req.Symbol)
+	}
+
+	if qty == 0 {
+		qty = a.notional / (price * multiplier)
+	}
+
+	estimate.Price = round(price)
+	estimate.Qty = qty
+	estimate.Value = round(qty * price * multiplier)
+
+	estimate.Fees.Commission = round(commission.fee(qty, estimate.Value))
+	if req.Side == "sell" && !crypto {
+		estimate.Fees.Regulatory = round(estimate.Value*secFeeRate + math.Min(qty*tafRate, tafMaximum))
+	}
+	estimate.Fees.Total = round(estimate.Fees.Commission + estimate.Fees.Regulatory)
+
+	if req.Side == "buy" {
+		estimate.Total = round(estimate.Value + estimate.Fees.Total)
+		estimate.PostTradeBuyingPower = round(acc.BuyingPower - estimate.Total)
+		estimate.PostTradePosition = currentPosition + qty
+
+		if estimate.Total > acc.BuyingPower {
+			estimate.Warnings = append(estimate.Warnings, Warning{WarningInsufficientFunds,
+				fmt.Sprintf("the order needs about $%.2f, but only $%.2f of buying power is available", estimate.Total, acc.BuyingPower)})
+		}
+	} else if req.PositionIntent == "sell_to_open" {
+		// The proceeds of a short sale are held against the borrowed shares, so it uses up buying power instead
+		estimate.Total = round(estimate.Value - estimate.Fees.Total)
+		estimate.PostTradeBuyingPower = round(acc.BuyingPower - estimate.Value - estimate.Fees.Total)
+		estimate.PostTradePosition = currentPosition - qty
+
+		if estimate.Value+estimate.Fees.Total > acc.BuyingPower {
+			estimate.Warnings = append(estimate.Warnings, Warning{WarningInsufficientFunds,
+				fmt.Sprintf("the short sale needs about $%.2f, but only $%.2f of buying power is available", estimate.Value+estimate.Fees.Total, acc.BuyingPower)})
+		}
+	} else {
+		estimate.Total = round(estimate.Value - estimate.Fees.Total)
+		estimate.PostTradeBuyingPower = round(acc.BuyingPower + estimate.Total)
+		estimate.PostTradePosition = currentPosition - qty
+
+		if qty > currentPosition+1e-9 {
+			estimate.Warnings = append(estimate.Warnings, Warning{WarningInsufficientPosition,
+				fmt.Sprintf("you only hold %g shares of %s", currentPosition, req.Symbol)})
+		}
+	}
+
+	if !crypto && !option && math.Mod(qty, roundLot) != 0 {
+		estimate.Warnings = append(estimate.Warnings, Warning{WarningOddLot,
+			"the order isn't a multiple of 100 shares, so the odd lot may fill at a slightly different price than the quote"})
+	}
+
+	if mayNotFill {
+		estimate.Warnings = append(estimate.Warnings, Warning{WarningMayNotFill, "the order may not fill right away at the current prices"})
+	}
+
+	if !marketOpen {
+		estimate.Warnings = append(estimate.Warnings, Warning{WarningMarketClosed, "the market is closed, the order will be executed once it opens"})
+	}
+
+	return estimate, nil
+}
+
+// isCrypto tells apart the crypto pairs (BTC/USD) from the stocks
+func isCrypto(symbol string) bool {
+	return strings.Contains(symbol, "/")
+}
+
+// GetQuote returns the latest quote of the symbol
+func GetQuote(symbol string) (Quote, error) {
+	if options.IsContract(symbol) {
+		body, err := SendRequest[map[string]map[string]Quote](http.MethodGet, OptionsData+"/quotes/latest?symbols="+symbol, nil, nil, BasicAuth())
+		if err != nil {
+			return Quote{}, err
+		}
+
+		return body["quotes"][symbol], nil
+	}
+
+	if isCrypto(symbol) {
+		body, err := SendRequest[map[string]map[string]Quote](http.MethodGet, CryptoData+"/latest/quotes?symbols="+url.QueryEscape(symbol), nil, nil, BasicAuth())
+		if err != nil {
+			return Quote{}, err
+		}
+
+		return body["quotes"][symbol], nil
+	}
+
+	body, err := SendRequest[map[string]map[string]Quote](http.MethodGet, MarketData+"/stocks/quotes/latest?symbols="+symbol, nil, nil, BasicAuth())
+	if err != nil {
+		return Quote{}, err
+	}
+
+	return body["quotes"][symbol], nil
+}
+
+func getPosition(id, symbol string) (float64, error) {
+	positions, err := SendRequest[[]position](http.MethodGet, BaseURL+Trading+id+"/positions", nil, nil, BasicAuth())
+	if err != nil {
+		return 0, err
+	}
+
+	// The positions of crypto pairs don't have the slash
+	symbol = strings.ReplaceAll(symbol, "/", "")
+	for _, p := range positions {
+		if p.Symbol == symbol {
+			return strconv.ParseFloat(p.Qty, 64)
+		}
+	}
+
+	return 0, nil
+}
+
+func getAccount(id string) (Account, error) {
+	body, err := SendRequest[account](http.MethodGet, BaseURL+Trading+id+"/account", nil, nil, BasicAuth())
+	if err != nil {
+		return Account{}, err
+	}
+
+	buyingPower, err := strconv.ParseFloat(body.BuyingPower, 64)
+	if err != nil {
+		return Account{}, err
+	}
+
+	cash, err := strconv.ParseFloat(body.Cash, 64)
+	if err != nil {
+		return Account{}, err
+	}
+
+	return Account{BuyingPower: buyingPower, Cash: cash}, nil
+}
+
+func EstimateOrder(c *gin.Context) {
+	id := c.GetString("id")
+
+	req := Request{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	if _, err := req.parse(); err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	var quote Quote
+	var currentPosition float64
+	var acc Account
+	var marketOpen bool
+	var err1, err2, err3, err4 error
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		quote, err1 = GetQuote(req.Symbol)
+	}()
+
+	go func() {
+		defer wg.Done()
+		currentPosition, err2 = getPosition(id, req.Symbol)
+	}()
+
+	go func() {
+		defer wg.Done()
+		acc, err3 = getAccount(id)
+	}()
+
+	go func() {
+		defer wg.Done()
+		// Crypto trades around the clock
+		if isCrypto(req.Symbol) {
+			marketOpen = true
+			return
+		}
+
+		marketOpen, err4 = clock.IsStockMarketOpen("NYSE")
+	}()
+
+	wg.Wait()
+
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		RequestExit(c, nil, err, "couldn't get the information needed for the estimate")
+		return
+	}
+
+	estimate, err := Calculate(req, quote, currentPosition, acc, CommissionFromEnv(), marketOpen)
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
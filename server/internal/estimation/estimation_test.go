@@ -0,0 +1,188 @@
+package estimation
+
+import (
+	"testing"
+)
+
+func hasWarning(e Estimate, code string) bool {
+	for _, w := range e.Warnings {
+		if w.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestCalculate_MarketBuy(t *testing.T) {
+	req := Request{Symbol: "aapl", Side: "buy", Type: "market", Qty: "10"}
+	quote := Quote{Bid: 99.9, Ask: 100}
+	acc := Account{BuyingPower: 5000, Cash: 5000}
+
+	e, err := Calculate(req, quote, 5, acc, Commission{Type: "notional", Amount: 1}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Symbol != "AAPL" || e.Price != 100 || e.Value != 1000 || e.Fees.Total != 1 || e.Total != 1001 {
+		t.Fatalf("unexpected estimate %+v", e)
+	}
+
+	if e.PostTradeBuyingPower != 3999 || e.PostTradePosition != 15 {
+		t.Fatalf("unexpected post trade values %+v", e)
+	}
+
+	if !hasWarning(e, WarningOddLot) || hasWarning(e, WarningInsufficientFunds) || hasWarning(e, WarningMarketClosed) {
+		t.Fatalf("unexpected warnings %+v", e.Warnings)
+	}
+}
+
+func TestCalculate_Notional(t *testing.T) {
+	req := Request{Symbol: "AAPL", Side: "buy", Notional: "50"}
+
+	e, err := Calculate(req, Quote{Bid: 199, Ask: 200}, 0, Account{BuyingPower: 100}, Commission{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Qty != 0.25 || e.Total != 50 {
+		t.Fatalf("unexpected estimate %+v", e)
+	}
+}
+
+func TestCalculate_SellFeesAndWarnings(t *testing.T) {
+	req := Request{Symbol: "AAPL", Side: "sell", Type: "market", Qty: "200"}
+	acc := Account{BuyingPower: 100}
+
+	e, err := Calculate(req, Quote{Bid: 50, Ask: 50.1}, 100, acc, Commission{Type: "bps", Amount: 10}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10000 sold: 10 bps commission, 0.28 SEC fee and 0.03 TAF
+	if e.Value != 10000 || e.Fees.Commission != 10 || e.Fees.Regulatory != 0.31 || e.Total != 9989.69 {
+		t.Fatalf("unexpected estimate %+v", e)
+	}
+
+	if e.PostTradePosition != -100 {
+		t.Fatalf("unexpected post trade position %g", e.PostTradePosition)
+	}
+
+	if !hasWarning(e, WarningInsufficientPosition) || !hasWarning(e, WarningMarketClosed) || hasWarning(e, WarningOddLot) {
+		t.Fatalf("unexpected warnings %+v", e.Warnings)
+	}
+}
+
+func TestCalculate_CryptoHasNoRegulatoryFees(t *testing.T) {
+	req := Request{Symbol: "BTC/USD", Side: "sell", Type: "market", Qty: "0.5"}
+
+	e, err := Calculate(req, Quote{Bid: 60000, Ask: 60010}, 1, Account{}, Commission{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Value != 30000 || e.Fees.Regulatory != 0 || e.Total != 30000 {
+		t.Fatalf("unexpected estimate %+v", e)
+	}
+
+	if hasWarning(e, WarningOddLot) {
+		t.Fatalf("unexpected warnings %+v", e.Warnings)
+	}
+}
+
+func TestCalculate_OptionsContractsAreFor100Shares(t *testing.T) {
+	req := Request{Symbol: "AAPL250620C00200000", Side: "buy", Type: "market", Qty: "2"}
+
+	e, err := Calculate(req, Quote{Bid: 1.2, Ask: 1.25}, 0, Account{BuyingPower: 1000}, Commission{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Price != 1.25 || e.Value != 250 || e.Total != 250 {
+		t.Fatalf("unexpected estimate %+v", e)
+	}
+
+	if hasWarning(e, WarningOddLot) {
+		t.Fatalf("unexpected warnings %+v", e.Warnings)
+	}
+}
+
+func TestCalculate_InsufficientFunds(t *testing.T) {
+	req := Request{Symbol: "AAPL", Side: "buy", Type: "limit", Qty: "100", LimitPrice: "90"}
+
+	e, err := Calculate(req, Quote{Bid: 99, Ask: 100}, 0, Account{BuyingPower: 1000}, Commission{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Price != 90 || !hasWarning(e, WarningInsufficientFunds) || !hasWarning(e, WarningMayNotFill) {
+		t.Fatalf("unexpected estimate %+v", e)
+	}
+}
+
+func TestCalculate_LimitThroughTheMarket(t *testing.T) {
+	req := Request{Symbol: "AAPL", Side: "buy", Type: "limit", Qty: "100", LimitPrice: "110"}
+
+	e, err := Calculate(req, Quote{Bid: 99, Ask: 100}, 0, Account{BuyingPower: 100000}, Commission{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Price != 100 || hasWarning(e, WarningMayNotFill) {
+		t.Fatalf("expected the order to fill at the ask, got %+v", e)
+	}
+}
+
+func TestCalculate_Invalid(t *testing.T) {
+	invalid := []Request{
+		{Side: "buy", Qty: "1"},
+		{Symbol: "AAPL", Side: "short", Qty: "1"},
+		{Symbol: "AAPL", Side: "buy"},
+		{Symbol: "AAPL", Side: "buy", Qty: "1", Notional: "10"},
+		{Symbol: "AAPL", Side: "buy", Qty: "abc"},
+		{Symbol: "AAPL", Side: "buy", Type: "limit", Qty: "1"},
+		{Symbol: "AAPL", Side: "buy", Type: "stop", Qty: "1"},
+	}
+
+	for _, req := range invalid {
+		if _, err := Calculate(req, Quote{Bid: 1, Ask: 1}, 0, Account{}, Commission{}, true); err == nil {
+			t.Fatalf("expected an error for %+v", req)
+		}
+	}
+
+	if _, err := Calculate(Request{Symbol: "AAPL", Side: "buy", Qty: "1"}, Quote{}, 0, Account{}, Commission{}, true); err == nil {
+		t.Fatal("expected an error without any price")
+	}
+}
+
+func TestCommissionFromEnv(t *testing.T) {
+	t.Setenv("COMMISSION_TYPE", "qty")
+	t.Setenv("COMMISSION", "0.01")
+
+	c := CommissionFromEnv()
+	if c.fee(300, 0) != 3 {
+		t.Fatalf("unexpected commission %+v", c)
+	}
+
+	t.Setenv("COMMISSION", "")
+	if CommissionFromEnv().fee(300, 1000) != 0 {
+		t.Fatal("expected no commission without a schedule")
+	}
+}
+
+func TestCalculate_ShortSaleUsesBuyingPower(t *testing.T) {
+	req := Request{Symbol: "TSLA", Side: "sell", Type: "market", Qty: "10", PositionIntent: "sell_to_open"}
+
+	e, err := Calculate(req, Quote{Bid: 200, Ask: 200.1}, 0, Account{BuyingPower: 1500}, Commission{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.PostTradePosition != -10 || e.PostTradeBuyingPower >= 0 {
+		t.Fatalf("unexpected estimate %+v", e)
+	}
+
+	if hasWarning(e, WarningInsufficientPosition) || !hasWarning(e, WarningInsufficientFunds) {
+		t.Fatalf("unexpected warnings %+v", e.Warnings)
+	}
+}
@@ -0,0 +1,314 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	StreamTrades    = "trades"
+	StreamTransfers = "transfers"
+	StreamAccounts  = "accounts"
+	StreamJournals  = "journals"
+	StreamOptions   = "options"
+)
+
+// The non trade activities of options contracts: expiration, assignment and exercise
+var optionActivities = []string{"OPEXP", "OPASN", "OPEXC"}
+
+// The path of every Alpaca Broker SSE stream relative to BaseURL + Events
+var streamPaths = map[string]string{
+	StreamTrades:    "trades",
+	StreamTransfers: "transfers/status",
+	StreamAccounts:  "accounts/status",
+	StreamJournals:  "journals/status",
+	StreamOptions:   "nta",
+}
+
+var upgrader websocket.Upgrader
+
+type Event struct {
+	Stream string         `json:"stream"`
+	Data   map[string]any `json:"data"`
+}
+
+type User struct {
+	AccountID string
+	ws        *websocket.Conn
+	send      chan *Event
+}
+
+func (u *User) Read(hub *Hub) {
+	for {
+		_, message, err := u.ws.ReadMessage()
+		if err != nil {
+			log.Println(err)
+			hub.Unregister <- u
+			return
+		}
+
+		if string(message) == "exit" {
+			hub.Unregister <- u
+			return
+		}
+	}
+}
+
+func (u *User) Write(hub *Hub) {
+	defer u.ws.Close()
+
+	for event := range u.send {
+		err := u.ws.WriteJSON(event)
+		if err != nil {
+			log.Println(err)
+			hub.Unregister <- u
+			return
+		}
+	}
+}
+
+type Hub struct {
+	Users       map[string]map[*User]struct{} // account id -> connections
+	Broadcast   chan *Event
+	Register    chan *User
+	Unregister  chan *User
+	IsConnected bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		Users:       make(map[string]map[*User]struct{}),
+		Broadcast:   make(chan *Event),
+		Register:    make(chan *User),
+		Unregister:  make(chan *User),
+		IsConnected: false,
+	}
+}
+
+func (h *Hub) Run() {
+	for {
+		select {
+		case event := <-h.Broadcast:
+			for _, accountID := range recipients(event) {
+				for user := range h.Users[accountID] {
+					select {
+					case user.send <- event:
+					default:
+						// The user isn't keeping up, so we drop him instead of blocking everybody else
+						h.remove(user)
+					}
+				}
+			}
+
+		case user := <-h.Register:
+			if h.Users[user.AccountID] == nil {
+				h.Users[user.AccountID] = make(map[*User]struct{})
+			}
+			h.Users[user.AccountID][user] = struct{}{}
+
+			if !h.IsConnected {
+				h.IsConnected = true
+				for stream := range streamPaths {
+					go h.Consume(stream)
+				}
+			}
+
+		case user := <-h.Unregister:
+			h.remove(user)
+		}
+	}
+}
+
+func (h *Hub) remove(user *User) {
+	users, ok := h.Users[user.AccountID]
+	if !ok {
+		return
+	}
+
+	if _, ok := users[user]; !ok {
+		return
+	}
+
+	delete(users, user)
+	close(user.send)
+
+	if len(users) == 0 {
+		delete(h.Users, user.AccountID)
+	}
+}
+
+// Consume keeps a connection to one of the SSE streams of the Broker API open for as long as the server lives.
+// When the connection drops it reconnects and continues from the last event it has seen.
+func (h *Hub) Consume(stream string) {
+	lastEventID := ""
+	backoff := time.Second
+
+	for {
+		url := BaseURL + Events + streamPaths[stream]
+		if lastEventID != "" {
+			url += "?since_id=" + lastEventID
+		}
+
+		err := h.listen(stream, url, func(event *Event) {
+			if id := eventID(event.Data); id != "" {
+				lastEventID = id
+			}
+
+			backoff = time.Second
+			if !relevant(event) {
+				return
+			}
+
+			h.Broadcast <- event
+		})
+		log.Println("The " + stream + " event stream was closed")
+		if err != nil {
+			log.Println(err)
+		}
+
+		time.Sleep(backoff)
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func (h *Hub) listen(stream, url string, handle func(*Event)) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	for header, value := range BasicAuth() {
+		req.Header.Add(header, value)
+	}
+	req.Header.Add("accept", "text/event-stream")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return errors.New("Error the " + stream + " event stream responded with " + res.Status)
+	}
+
+	return ReadEvents(res.Body, stream, handle)
+}
+
+// ReadEvents parses a text/event-stream body and calls handle for every data frame in it.
+// Comments (heartbeats) and frames that aren't valid json are skipped.
+func ReadEvents(body io.Reader, stream string, handle func(*Event)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data strings.Builder
+	dispatch := func() {
+		if data.Len() == 0 {
+			return
+		}
+
+		var info map[string]any
+		if err := json.Unmarshal([]byte(data.String()), &info); err != nil {
+			log.Println(err)
+		} else {
+			handle(&Event{Stream: stream, Data: info})
+		}
+
+		data.Reset()
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			dispatch()
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// The body can end without the blank line after the last frame
+	dispatch()
+
+	return nil
+}
+
+// The non trade activities stream has everything from dividends to fees, only the options events are sent to the users
+func relevant(event *Event) bool {
+	if event.Stream != StreamOptions {
+		return true
+	}
+
+	activity, _ := event.Data["entry_type"].(string)
+	if activity == "" {
+		activity, _ = event.Data["activity_type"].(string)
+	}
+
+	return slices.Contains(optionActivities, activity)
+}
+
+// Journal events don't carry a single account id so they are sent to both sides of the journal
+func recipients(event *Event) []string {
+	var result []string
+	for _, key := range []string{"account_id", "from_account", "to_account"} {
+		if id, ok := event.Data[key].(string); ok && id != "" {
+			result = append(result, id)
+		}
+	}
+
+	return result
+}
+
+func eventID(data map[string]any) string {
+	switch id := data["event_id"].(type) {
+	case string:
+		return id
+	case float64:
+		return strconv.FormatInt(int64(id), 10)
+	default:
+		return ""
+	}
+}
+
+func GetAccountEvents(c *gin.Context, hub *Hub) {
+	id := c.GetString("id")
+
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't upgrade the connection to a websocket one", err)
+		return
+	}
+
+	user := &User{AccountID: id, ws: ws, send: make(chan *Event, 64)}
+	hub.Register <- user
+
+	go user.Read(hub)
+	go user.Write(hub)
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadEvents_ParsesDataFrames(t *testing.T) {
+	body := ": heartbeat\n\n" +
+		"data: {\"account_id\":\"abc\",\"event\":\"fill\",\"event_id\":12}\n\n" +
+		"data: not json\n\n" +
+		"data: {\"account_id\":\"def\",\n" +
+		"data: \"event\":\"new\"}\n\n"
+
+	var events []*Event
+	err := ReadEvents(strings.NewReader(body), StreamTrades, func(e *Event) {
+		events = append(events, e)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	if events[0].Stream != StreamTrades || events[0].Data["event"] != "fill" {
+		t.Fatalf("unexpected first event: %+v", events[0])
+	}
+
+	if events[1].Data["account_id"] != "def" {
+		t.Fatalf("expected multi line data to be joined, got %+v", events[1])
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	event := &Event{Data: map[string]any{"account_id": "abc"}}
+	if r := recipients(event); len(r) != 1 || r[0] != "abc" {
+		t.Fatalf("expected [abc], got %v", r)
+	}
+
+	journal := &Event{Data: map[string]any{"from_account": "a", "to_account": "b"}}
+	if r := recipients(journal); len(r) != 2 {
+		t.Fatalf("expected both sides of the journal, got %v", r)
+	}
+
+	if r := recipients(&Event{Data: map[string]any{}}); len(r) != 0 {
+		t.Fatalf("expected no recipients, got %v", r)
+	}
+}
+
+func TestRelevant(t *testing.T) {
+	if !relevant(&Event{Stream: StreamTrades, Data: map[string]any{}}) {
+		t.Fatal("expected every trade event to be relevant")
+	}
+
+	if !relevant(&Event{Stream: StreamOptions, Data: map[string]any{"entry_type": "OPEXP"}}) {
+		t.Fatal("expected an option expiration to be relevant")
+	}
+
+	if relevant(&Event{Stream: StreamOptions, Data: map[string]any{"entry_type": "DIV"}}) {
+		t.Fatal("expected a dividend not to be sent as an options event")
+	}
+}
+
+func TestEventID(t *testing.T) {
+	if id := eventID(map[string]any{"event_id": float64(42)}); id != "42" {
+		t.Fatalf("expected 42, got %s", id)
+	}
+
+	if id := eventID(map[string]any{"event_id": "01HX"}); id != "01HX" {
+		t.Fatalf("expected 01HX, got %s", id)
+	}
+
+	if id := eventID(map[string]any{}); id != "" {
+		t.Fatalf("expected empty id, got %s", id)
+	}
+}
+
+func TestHub_RegisterAndUnregister(t *testing.T) {
+	hub := NewHub()
+	hub.IsConnected = true // don't reach out to alpaca
+
+	go hub.Run()
+
+	user := &User{AccountID: "abc", send: make(chan *Event, 1)}
+	hub.Register <- user
+
+	hub.Broadcast <- &Event{Stream: StreamTransfers, Data: map[string]any{"account_id": "abc"}}
+	event := <-user.send
+	if event.Stream != StreamTransfers {
+		t.Fatalf("expected transfer event, got %s", event.Stream)
+	}
+
+	hub.Unregister <- user
+	if _, ok := <-user.send; ok {
+		t.Fatal("expected the send channel to be closed")
+	}
+}
+
+func TestReadEvents_DispatchesTheLastFrameAtTheEnd(t *testing.T) {
+	body := "data: {\"account_id\":\"abc\",\"event\":\"fill\"}\n\n" +
+		"data: {\"account_id\":\"def\",\"event\":\"new\"}"
+
+	var events []*Event
+	err := ReadEvents(strings.NewReader(body), StreamTrades, func(e *Event) {
+		events = append(events, e)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 || events[1].Data["account_id"] != "def" {
+		t.Fatalf("expected the frame without a blank line after it to be read, got %+v", events)
+	}
+}
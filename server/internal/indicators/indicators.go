@@ -0,0 +1,143 @@
+package indicators
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// The most bars a single request can cover, so years of minute bars can't be asked for at once
+	maxBars = 50000
+	// How many times the lookback is widened while looking for enough bars to warm the indicators up with
+	maxLookbacks = 4
+)
+
+// barsFetcher gets the bars the indicators are calculated from
+type barsFetcher func(symbol string, timeframe marketdata.TimeFrame, start, end time.Time) ([]marketdata.Bar, error)
+
+// fetchBars gets the bars of alpaca and fetchSessionBars the ones built from the minutes of the sessions. They're
+// replaced in the tests.
+var (
+	fetchBars        barsFetcher = marketdata.GetBars
+	fetchSessionBars             = marketdata.GetAggregatedBars
+)
+
+// history returns the bars between start and end together with at least warmup bars before start, if the symbol
+// has them, and the index of the first bar from start. The market is closed most of the time, so the lookback is
+// widened until there are enough bars before start. When dayStart is set the bars go back at least to the start
+// of the day of start.
+func history(fetch barsFetcher, symbol string, timeframe marketdata.TimeFrame, start, end time.Time, warmup int, dayStart bool) ([]marketdata.Bar, int, error) {
+	lookback := 2 * time.Duration(warmup) * timeframe.Duration()
+
+	for attempt := 1; ; attempt++ {
+		from := start.Add(-lookback)
+		if day := DayStart(start); dayStart && day.Before(from) {
+			from = day
+		}
+
+		bars, err := fetch(symbol, timeframe, from, end)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		first, _ := slices.BinarySearchFunc(bars, start, func(bar marketdata.Bar, start time.Time) int {
+			return bar.Time.Compare(start)
+		})
+
+		if first >= warmup || attempt == maxLookbacks {
+			return bars, first, nil
+		}
+
+		lookback *= 3
+	}
+}
+
+func GetIndicators(c *gin.Context) {
+	symbol := strings.ToUpper(c.Query("symbol"))
+	if symbol == "" || strings.Contains(symbol, "/") {
+		ErrorExit(c, http.StatusBadRequest, "the symbol of a stock is required", nil)
+		return
+	}
+
+	timeframe := marketdata.TimeFrame(c.Query("timeframe"))
+	if !timeframe.ValidTimeFrame() && !timeframe.Custom() {
+		ErrorExit(c, http.StatusBadRequest, "timeframe was incorrectly provided", nil)
+		return
+	}
+
+	specs, err := ParseSpecs(c.Query("ind"))
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	now := time.Now().UTC()
+	start, end := now.Truncate(24*time.Hour), now
+	if value := c.Query("start"); value != "" {
+		if start, err = ParseTime(value); err != nil {
+			ErrorExit(c, http.StatusBadRequest, "start was incorrectly provided", err)
+			return
+		}
+	}
+
+	if value := c.Query("end"); value != "" {
+		if end, err = ParseTime(value); err != nil {
+			ErrorExit(c, http.StatusBadRequest, "end was incorrectly provided", err)
+			return
+		}
+	}
+
+	if !start.Before(end) {
+		ErrorExit(c, http.StatusBadRequest, "the start should be before the end", nil)
+		return
+	}
+
+	if end.Sub(start)/timeframe.Duration() > maxBars {
+		ErrorExit(c, http.StatusBadRequest, "the range is too long for this timeframe", nil)
+		return
+	}
+
+	// The bars of sessions are the same ones GetHistoricalBars sends with the session
+	fetch := fetchBars
+	if session := c.Query("session"); session != "" {
+		if session != "core" && session != "extended" {
+			ErrorExit(c, http.StatusBadRequest, "the session should be core or extended", nil)
+			return
+		}
+
+		if !timeframe.Aggregatable() {
+			ErrorExit(c, http.StatusBadRequest, "only minutes, hours and days can be aggregated", nil)
+			return
+		}
+
+		fetch = func(symbol string, timeframe marketdata.TimeFrame, start, end time.Time) ([]marketdata.Bar, error) {
+			return fetchSessionBars(symbol, timeframe, start, end, session == "extended")
+		}
+	}
+
+	warmup, vwap := 0, false
+	for _, spec := range specs {
+		warmup = max(warmup, spec.Warmup())
+		vwap = vwap || spec.Name == "vwap"
+	}
+
+	bars, first, err := history(fetch, symbol, timeframe, start, end, warmup, vwap)
+	if err != nil {
+		RequestExit(c, nil, err, "coludn't get the market data for this symbol")
+		return
+	}
+
+	results := make(map[string]any, len(specs))
+	for _, spec := range specs {
+		results[spec.String()] = trim(spec.Compute(bars), first)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"symbol": symbol, "timeframe": timeframe, "bars": bars[first:], "indicators": results})
+}
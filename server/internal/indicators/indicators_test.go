@@ -0,0 +1,135 @@
+package indicators
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseSpecs(t *testing.T) {
+	specs, err := ParseSpecs("sma:20,ema:50,rsi,macd:12:26:9,bbands:20:2.5,vwap,atr:14")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"sma:20", "ema:50", "rsi:14", "macd:12:26:9", "bbands:20:2.5", "vwap", "atr:14"}
+	for i, spec := range specs {
+		if spec.String() != expected[i] {
+			t.Fatalf("expected %s, got %s", expected[i], spec.String())
+		}
+	}
+
+	invalid := []string{"", "obv", "sma:0", "sma:1.5", "sma:20:2", "macd:26:12:9", "rsi:1000", "sma,sma,sma,sma,sma,sma,sma,sma,sma,sma,sma"}
+	for _, raw := range invalid {
+		if _, err := ParseSpecs(raw); err == nil {
+			t.Fatalf("expected %q to be invalid", raw)
+		}
+	}
+}
+
+// fakeBars serves a daily bar for every weekday from the given day, counting the requests
+func fakeBars(t *testing.T, from time.Time, requests *int) {
+	previous := fetchBars
+	t.Cleanup(func() { fetchBars = previous })
+
+	fetchBars = func(symbol string, timeframe marketdata.TimeFrame, start, end time.Time) ([]marketdata.Bar, error) {
+		*requests++
+
+		var bars []marketdata.Bar
+		for day := max(start.Unix(), from.Unix()); day < end.Unix(); day += 24 * 60 * 60 {
+			at := time.Unix(day, 0).UTC()
+			if at.Weekday() == time.Saturday || at.Weekday() == time.Sunday {
+				continue
+			}
+
+			price := float64(len(bars) + 1)
+			bars = append(bars, marketdata.Bar{Time: at, Open: price, High: price, Low: price, Close: price, Volume: 1})
+		}
+
+		return bars, nil
+	}
+}
+
+func TestGetIndicators_WarmsUp(t *testing.T) {
+	requests := 0
+	fakeBars(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), &requests)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/?symbol=aapl&timeframe=1D&start=2026-03-02&end=2026-03-14&ind=sma:50,rsi", nil)
+
+	GetIndicators(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Bars       []marketdata.Bar      `json:"bars"`
+		Indicators map[string][]*float64 `json:"indicators"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body.Bars) != 10 || !body.Bars[0].Time.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the 10 weekdays from the start, got %d", len(body.Bars))
+	}
+
+	for name, values := range body.Indicators {
+		if len(values) != len(body.Bars) || values[0] == nil {
+			t.Fatalf("expected %s to be warmed up for every bar, got %v", name, values)
+		}
+	}
+
+	if requests != 1 {
+		t.Fatalf("expected the first lookback to be enough, got %d requests", requests)
+	}
+}
+
+func TestGetIndicators_WidensTheLookback(t *testing.T) {
+	requests := 0
+	fakeBars(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), &requests)
+
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	bars, first, err := history(fetchBars, "AAPL", "1H", start, start.Add(24*time.Hour), 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake only has a bar a weekday, so the lookback of 200 hours has to be widened a few times
+	if requests != 4 || first < 100 || !bars[first].Time.Equal(start) {
+		t.Fatalf("expected 4 requests and 100 bars before the start, got %d and %d", requests, first)
+	}
+}
+
+func TestGetIndicators_InvalidRequests(t *testing.T) {
+	tests := []string{
+		"/?timeframe=1D&ind=sma",
+		"/?symbol=BTC/USD&timeframe=1D&ind=sma",
+		"/?symbol=AAPL&timeframe=BAD&ind=sma",
+		"/?symbol=AAPL&timeframe=1D&ind=obv",
+		"/?symbol=AAPL&timeframe=1D&ind=sma&start=2026-03-02&end=2026-03-01",
+		"/?symbol=AAPL&timeframe=1T&ind=sma&start=2000-01-01",
+		"/?symbol=AAPL&timeframe=5T&ind=sma&session=overnight",
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", tt, nil)
+
+		GetIndicators(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", tt, w.Code)
+		}
+	}
+}
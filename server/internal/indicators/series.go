@@ -0,0 +1,249 @@
+package indicators
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+	_ "time/tzdata"
+)
+
+// Series is the value of an indicator at every bar, oldest first. The bars before the indicator has enough
+// history to be calculated are NaN and are sent as null.
+type Series []float64
+
+func (s Series) MarshalJSON() ([]byte, error) {
+	values := make([]*float64, len(s))
+	for i := range s {
+		if !math.IsNaN(s[i]) {
+			values[i] = &s[i]
+		}
+	}
+
+	return json.Marshal(values)
+}
+
+// Last returns the latest value of the series, if it has one
+func (s Series) Last() (float64, bool) {
+	if len(s) == 0 || math.IsNaN(s[len(s)-1]) {
+		return 0, false
+	}
+
+	return s[len(s)-1], true
+}
+
+func newSeries(n int) Series {
+	s := make(Series, n)
+	for i := range s {
+		s[i] = math.NaN()
+	}
+
+	return s
+}
+
+// firstValid returns the index of the first value that isn't NaN
+func firstValid(values []float64) int {
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			return i
+		}
+	}
+
+	return len(values)
+}
+
+// SMA is the simple moving average of the last period values
+func SMA(values []float64, period int) Series {
+	s := newSeries(len(values))
+	if period < 1 {
+		return s
+	}
+
+	var sum float64
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+
+		if i >= period-1 {
+			s[i] = sum / float64(period)
+		}
+	}
+
+	return s
+}
+
+// EMA is the exponential moving average of the values. It starts from the simple average of the first period
+// values, so it's only close to what charting sites show after a few periods of history.
+func EMA(values []float64, period int) Series {
+	s := newSeries(len(values))
+
+	start := firstValid(values)
+	if period < 1 || len(values)-start < period {
+		return s
+	}
+
+	var sum float64
+	for _, v := range values[start : start+period] {
+		sum += v
+	}
+
+	k := 2 / float64(period+1)
+	s[start+period-1] = sum / float64(period)
+	for i := start + period; i < len(values); i++ {
+		s[i] = values[i]*k + s[i-1]*(1-k)
+	}
+
+	return s
+}
+
+// RSI is the relative strength index of the values with the smoothing of Wilder. The first value is the
+// average of the first period changes, so it needs period + 1 values.
+func RSI(values []float64, period int) Series {
+	s := newSeries(len(values))
+	if period < 1 || len(values) < period+1 {
+		return s
+	}
+
+	var gains, losses float64
+	for i := 1; i <= period; i++ {
+		change := values[i] - values[i-1]
+		if change > 0 {
+			gains += change
+		} else {
+			losses -= change
+		}
+	}
+
+	gains /= float64(period)
+	losses /= float64(period)
+	s[period] = rsi(gains, losses)
+
+	for i := period + 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain, loss := max(change, 0), max(-change, 0)
+
+		gains = (gains*float64(period-1) + gain) / float64(period)
+		losses = (losses*float64(period-1) + loss) / float64(period)
+		s[i] = rsi(gains, losses)
+	}
+
+	return s
+}
+
+func rsi(gains, losses float64) float64 {
+	if losses == 0 {
+		return 100
+	}
+
+	return 100 - 100/(1+gains/losses)
+}
+
+type MACDSeries struct {
+	MACD      Series `json:"macd"`
+	Signal    Series `json:"signal"`
+	Histogram Series `json:"histogram"`
+}
+
+// MACD is the difference between the fast and the slow EMA of the values, the EMA of that difference as the
+// signal line and how far apart the two are
+func MACD(values []float64, fast, slow, signal int) MACDSeries {
+	fastEMA, slowEMA := EMA(values, fast), EMA(values, slow)
+
+	m := MACDSeries{MACD: newSeries(len(values)), Histogram: newSeries(len(values))}
+	for i := range values {
+		m.MACD[i] = fastEMA[i] - slowEMA[i]
+	}
+
+	m.Signal = EMA(m.MACD, signal)
+	for i := range values {
+		m.Histogram[i] = m.MACD[i] - m.Signal[i]
+	}
+
+	return m
+}
+
+type Bands struct {
+	Upper  Series `json:"upper"`
+	Middle Series `json:"middle"`
+	Lower  Series `json:"lower"`
+}
+
+// BollingerBands are the SMA of the values with a band the given number of standard deviations above and below it
+func BollingerBands(values []float64, period int, deviations float64) Bands {
+	b := Bands{Upper: newSeries(len(values)), Middle: SMA(values, period), Lower: newSeries(len(values))}
+	if period < 1 {
+		return b
+	}
+
+	for i := period - 1; i < len(values); i++ {
+		var variance float64
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - b.Middle[i]) * (v - b.Middle[i])
+		}
+
+		deviation := math.Sqrt(variance/float64(period)) * deviations
+		b.Upper[i] = b.Middle[i] + deviation
+		b.Lower[i] = b.Middle[i] - deviation
+	}
+
+	return b
+}
+
+// ATR is the average true range of the bars with the smoothing of Wilder
+func ATR(high, low, close []float64, period int) Series {
+	s := newSeries(len(close))
+	if period < 1 || len(close) < period {
+		return s
+	}
+
+	ranges := make([]float64, len(close))
+	for i := range close {
+		ranges[i] = high[i] - low[i]
+		if i > 0 {
+			ranges[i] = max(ranges[i], math.Abs(high[i]-close[i-1]), math.Abs(low[i]-close[i-1]))
+		}
+	}
+
+	var sum float64
+	for _, r := range ranges[:period] {
+		sum += r
+	}
+
+	s[period-1] = sum / float64(period)
+	for i := period; i < len(close); i++ {
+		s[i] = (s[i-1]*float64(period-1) + ranges[i]) / float64(period)
+	}
+
+	return s
+}
+
+// The time zone database is embedded, so the days start at the right time on servers without one
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// DayStart is the midnight in New York of the day of t, where the VWAP starts over
+func DayStart(t time.Time) time.Time {
+	year, month, day := t.In(newYork).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, newYork)
+}
+
+// VWAP is the volume weighted average of the typical price of the bars. It starts over every day in New York.
+func VWAP(times []time.Time, high, low, close, volume []float64) Series {
+	s := newSeries(len(close))
+
+	var day time.Time
+	var value, total float64
+	for i := range close {
+		if start := DayStart(times[i]); !start.Equal(day) {
+			day, value, total = start, 0, 0
+		}
+
+		value += (high[i] + low[i] + close[i]) / 3 * volume[i]
+		total += volume[i]
+		if total > 0 {
+			s[i] = value / total
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,92 @@
+package indicators
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSMA(t *testing.T) {
+	s := SMA([]float64{1, 2, 3, 4, 5}, 3)
+
+	if !math.IsNaN(s[1]) {
+		t.Fatalf("expected no value before the period is full, got %f", s[1])
+	}
+
+	if s[2] != 2 || s[4] != 4 {
+		t.Fatalf("expected 2 and 4, got %v", s)
+	}
+}
+
+func TestEMA(t *testing.T) {
+	s := EMA([]float64{2, 4, 6, 8}, 3)
+
+	// Seeded with the average of 2, 4 and 6 and then 8*0.5 + 4*0.5
+	if s[2] != 4 || s[3] != 6 {
+		t.Fatalf("expected 4 and 6, got %v", s)
+	}
+}
+
+func TestRSI(t *testing.T) {
+	s := RSI([]float64{10, 11, 10, 11, 12}, 2)
+
+	// The first average gain and loss are both 0.5 and then the gains take over
+	if s[2] != 50 {
+		t.Fatalf("expected 50, got %f", s[2])
+	}
+
+	if s[3] != 75 || s[4] != 87.5 {
+		t.Fatalf("expected the rsi to rise with the gains, got %v", s)
+	}
+}
+
+func TestMACD(t *testing.T) {
+	values := make([]float64, 40)
+	for i := range values {
+		values[i] = 100
+	}
+
+	m := MACD(values, 12, 26, 9)
+	if !math.IsNaN(m.Signal[32]) || m.Signal[33] != 0 || m.Histogram[39] != 0 {
+		t.Fatalf("expected a flat macd once the signal is warmed up, got %v", m.Signal)
+	}
+}
+
+func TestBollingerBands(t *testing.T) {
+	b := BollingerBands([]float64{1, 3, 1, 3}, 2, 2)
+
+	if b.Middle[3] != 2 || b.Upper[3] != 4 || b.Lower[3] != 0 {
+		t.Fatalf("expected 4, 2 and 0, got %f %f %f", b.Upper[3], b.Middle[3], b.Lower[3])
+	}
+}
+
+func TestATR(t *testing.T) {
+	s := ATR([]float64{11, 12, 15}, []float64{9, 10, 13}, []float64{10, 11, 14}, 2)
+
+	// The last range includes the gap from the previous close
+	if s[1] != 2 || s[2] != 3 {
+		t.Fatalf("expected 2 and 3, got %v", s)
+	}
+}
+
+func TestVWAP_StartsOverEveryDay(t *testing.T) {
+	day, _ := time.Parse(time.RFC3339, "2026-03-02T15:00:00Z")
+	times := []time.Time{day, day.Add(time.Minute), day.Add(24 * time.Hour)}
+
+	s := VWAP(times, []float64{10, 20, 30}, []float64{10, 20, 30}, []float64{10, 20, 30}, []float64{1, 3, 5})
+	if s[1] != 17.5 || s[2] != 30 {
+		t.Fatalf("expected 17.5 and 30, got %v", s)
+	}
+}
+
+func TestSeries_MarshalJSON(t *testing.T) {
+	body, err := json.Marshal(SMA([]float64{1, 2, 3}, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "[null,1.5,2.5]" {
+		t.Fatalf("expected the warm-up to be null, got %s", body)
+	}
+}
@@ -0,0 +1,168 @@
+package indicators
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
+)
+
+const (
+	// The most indicators calculated for a single request
+	maxIndicators = 10
+	// The longest period an indicator can be asked for
+	maxPeriod = 500
+)
+
+// The parameters every indicator has when they aren't given
+var defaults = map[string][]float64{
+	"sma":    {20},
+	"ema":    {20},
+	"rsi":    {14},
+	"macd":   {12, 26, 9},
+	"bbands": {20, 2},
+	"vwap":   {},
+	"atr":    {14},
+}
+
+// Spec is an indicator with its parameters, like sma:20 or macd:12:26:9
+type Spec struct {
+	Name   string
+	Params []float64
+}
+
+func (s Spec) String() string {
+	parts := []string{s.Name}
+	for _, param := range s.Params {
+		parts = append(parts, strconv.FormatFloat(param, 'f', -1, 64))
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// ParseSpecs reads the comma separated indicators, using the default parameters of the ones without any
+func ParseSpecs(raw string) ([]Spec, error) {
+	if raw == "" {
+		return nil, errors.New("at least one indicator is required")
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxIndicators {
+		return nil, errors.New("at most " + strconv.Itoa(maxIndicators) + " indicators can be calculated at once")
+	}
+
+	specs := make([]Spec, 0, len(parts))
+	for _, part := range parts {
+		spec, err := parseSpec(strings.ToLower(strings.TrimSpace(part)))
+		if err != nil {
+			return nil, err
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+func parseSpec(raw string) (Spec, error) {
+	fields := strings.Split(raw, ":")
+
+	params, ok := defaults[fields[0]]
+	if !ok {
+		return Spec{}, errors.New(fields[0] + " isn't a supported indicator")
+	}
+
+	spec := Spec{Name: fields[0], Params: params}
+	if len(fields) == 1 {
+		return spec, nil
+	}
+
+	if len(fields)-1 != len(params) {
+		return Spec{}, errors.New(spec.Name + " takes " + strconv.Itoa(len(params)) + " parameters")
+	}
+
+	spec.Params = make([]float64, len(params))
+	for i, field := range fields[1:] {
+		param, err := strconv.ParseFloat(field, 64)
+		if err != nil || param <= 0 {
+			return Spec{}, errors.New("the parameters of " + spec.Name + " should be positive numbers")
+		}
+
+		// Only the number of standard deviations of the bands can have a fraction
+		if spec.Name != "bbands" || i == 0 {
+			if param != float64(int(param)) || param > maxPeriod {
+				return Spec{}, errors.New("the periods of " + spec.Name + " should be whole numbers up to " + strconv.Itoa(maxPeriod))
+			}
+		}
+
+		spec.Params[i] = param
+	}
+
+	if spec.Name == "macd" && spec.Params[0] >= spec.Params[1] {
+		return Spec{}, errors.New("the fast period of macd should be shorter than the slow one")
+	}
+
+	return spec, nil
+}
+
+func (s Spec) period(i int) int {
+	return int(s.Params[i])
+}
+
+// Warmup is how many bars before the first one the indicator needs. The smoothed indicators depend on all of the
+// bars before them, so they get a few periods to settle down.
+func (s Spec) Warmup() int {
+	switch s.Name {
+	case "sma", "bbands":
+		return s.period(0) - 1
+	case "ema", "rsi", "atr":
+		return 3 * s.period(0)
+	case "macd":
+		return 3*s.period(1) + s.period(2)
+	default:
+		return 0
+	}
+}
+
+// Compute calculates the indicator for every one of the bars. It's a Series for the indicators with a single line
+// and MACDSeries or Bands for the others.
+func (s Spec) Compute(bars []marketdata.Bar) any {
+	times := make([]time.Time, len(bars))
+	high, low, close, volume := make([]float64, len(bars)), make([]float64, len(bars)), make([]float64, len(bars)), make([]float64, len(bars))
+	for i, bar := range bars {
+		times[i], high[i], low[i], close[i], volume[i] = bar.Time, bar.High, bar.Low, bar.Close, bar.Volume
+	}
+
+	switch s.Name {
+	case "sma":
+		return SMA(close, s.period(0))
+	case "ema":
+		return EMA(close, s.period(0))
+	case "rsi":
+		return RSI(close, s.period(0))
+	case "macd":
+		return MACD(close, s.period(0), s.period(1), s.period(2))
+	case "bbands":
+		return BollingerBands(close, s.period(0), s.Params[1])
+	case "atr":
+		return ATR(high, low, close, s.period(0))
+	default:
+		return VWAP(times, high, low, close, volume)
+	}
+}
+
+// trim drops the first n values of the result of Compute
+func trim(result any, n int) any {
+	switch r := result.(type) {
+	case Series:
+		return r[n:]
+	case MACDSeries:
+		return MACDSeries{MACD: r.MACD[n:], Signal: r.Signal[n:], Histogram: r.Histogram[n:]}
+	case Bands:
+		return Bands{Upper: r.Upper[n:], Middle: r.Middle[n:], Lower: r.Lower[n:]}
+	default:
+		return result
+	}
+}
@@ -0,0 +1,223 @@
+package marketdata
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// The minute bars the other timeframes are built from
+	sourceTimeFrame TimeFrame = "1T"
+	// The longest range that can be aggregated, since every minute of it has to be fetched
+	maxAggregatedRange = 366 * 24 * time.Hour
+)
+
+// The longest aggregated timeframes of every unit: a whole extended session for the minutes and the hours and about
+// a month and a half of market days
+var aggregateLimits = map[byte]int{'T': 960, 'H': 16, 'D': 30}
+
+var errAggregatedRange = errors.New("aggregated bars can cover at most a year")
+
+// parse splits the timeframe into its number and unit
+func (t TimeFrame) parse() (int, byte, bool) {
+	if len(t) < 2 {
+		return 0, 0, false
+	}
+
+	n, err := strconv.Atoi(string(t[:len(t)-1]))
+	if err != nil || n < 1 {
+		return 0, 0, false
+	}
+
+	return n, t[len(t)-1], true
+}
+
+// Aggregatable reports whether the timeframe can be built from minute bars, like 5T, 4H or 2D
+func (t TimeFrame) Aggregatable() bool {
+	n, unit, ok := t.parse()
+	return ok && n <= aggregateLimits[unit]
+}
+
+// Custom reports whether the timeframe isn't one of alpaca, so it has to be built from minute bars
+func (t TimeFrame) Custom() bool {
+	return !t.ValidTimeFrame() && t.Aggregatable()
+}
+
+// bucket is where a bar of the given market day starts. Minutes and hours start over with every session, so a bar
+// never spans two days, and the bars of days are counted in market days.
+func (t TimeFrame) bucket(at time.Time, days []clock.MarketDay, day int, extended bool) time.Time {
+	n, unit, _ := t.parse()
+
+	if unit == 'D' {
+		return days[day-day%n].Open(extended)
+	}
+
+	open := days[day].Open(extended)
+	size := t.Duration()
+
+	return open.Add(at.Sub(open) / size * size)
+}
+
+// Aggregate builds bars of the timeframe from the given minute bars, oldest first. Only the minutes of the
+// sessions of days are kept: the core session, or the extended hours as well when extended is set. The bars are
+// aligned to the open of the sessions, which come from the calendar, so they follow the time zone of the exchange.
+func Aggregate(bars []Bar, timeframe TimeFrame, days []clock.MarketDay, extended bool) []Bar {
+	var aggregated []Bar
+	var notional float64
+
+	day := 0
+	for _, bar := range bars {
+		for day < len(days) && !bar.Time.Before(days[day].Close(extended)) {
+			day++
+		}
+
+		if day == len(days) {
+			break
+		}
+
+		if bar.Time.Before(days[day].Open(extended)) {
+			continue
+		}
+
+		start := timeframe.bucket(bar.Time, days, day, extended)
+
+		last := len(aggregated) - 1
+		if last < 0 || !aggregated[last].Time.Equal(start) {
+			if last >= 0 && aggregated[last].Volume > 0 {
+				aggregated[last].VWAP = notional / aggregated[last].Volume
+			}
+
+			aggregated = append(aggregated, Bar{Time: start, Open: bar.Open, High: bar.High, Low: bar.Low})
+			notional = 0
+			last++
+		}
+
+		current := &aggregated[last]
+		current.High = max(current.High, bar.High)
+		current.Low = min(current.Low, bar.Low)
+		current.Close = bar.Close
+		current.Volume += bar.Volume
+		current.Trades += bar.Trades
+		notional += bar.VWAP * bar.Volume
+	}
+
+	if last := len(aggregated) - 1; last >= 0 && aggregated[last].Volume > 0 {
+		aggregated[last].VWAP = notional / aggregated[last].Volume
+	}
+
+	return aggregated
+}
+
+// getMarketDays and getMinuteBars are replaced in the tests
+var (
+	getMarketDays = clock.GetMarketDays
+	getMinuteBars = func(symbol string, start, end time.Time) ([]Bar, error) {
+		return alpacaBars(symbol, sourceTimeFrame, start, end)
+	}
+)
+
+// GetAggregatedBars builds the bars of the timeframe between start and end from the minute bars of the symbol.
+// The minutes are fetched from the open of the first session, so the first bar isn't cut off.
+func GetAggregatedBars(symbol string, timeframe TimeFrame, start, end time.Time, extended bool) ([]Bar, error) {
+	if end.Sub(start) > maxAggregatedRange {
+		return nil, errAggregatedRange
+	}
+
+	n, unit, _ := timeframe.parse()
+
+	// The bars of days are counted from the first market day, so a few more days are needed for the first one
+	from := start
+	if unit == 'D' {
+		from = start.AddDate(0, 0, -2*n)
+	}
+
+	days, err := getMarketDays("NYSE", from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(days) == 0 {
+		return []Bar{}, nil
+	}
+
+	bars, err := getMinuteBars(symbol, days[0].Open(extended), end)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregated := Aggregate(bars, timeframe, days, extended)
+
+	// The bars of minutes and hours are kept from the one start is in and the bars of days from the last one
+	// that started by start
+	first := 0
+	for first < len(aggregated) && aggregated[first].Time.Before(start) {
+		if unit == 'D' && (first+1 == len(aggregated) || aggregated[first+1].Time.After(start)) {
+			break
+		}
+
+		if unit != 'D' && aggregated[first].Time.Add(timeframe.Duration()).After(start) {
+			break
+		}
+
+		first++
+	}
+
+	return aggregated[first:], nil
+}
+
+// sendAggregatedBars responds with the bars built from minute bars in the same format alpaca uses. Only the core
+// session is kept unless session=extended.
+func sendAggregatedBars(c *gin.Context, timeframe TimeFrame) {
+	if !timeframe.Aggregatable() {
+		ErrorExit(c, http.StatusBadRequest, "only minutes, hours and days can be aggregated", nil)
+		return
+	}
+
+	session := c.DefaultQuery("session", "core")
+	if session != "core" && session != "extended" {
+		ErrorExit(c, http.StatusBadRequest, "the session should be core or extended", nil)
+		return
+	}
+
+	query := historicalQuery(c)
+
+	start, err := ParseTime(query.Get("start"))
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, "start was incorrectly provided", err)
+		return
+	}
+
+	end := time.Now().UTC()
+	if query.Has("end") {
+		if end, err = ParseTime(query.Get("end")); err != nil {
+			ErrorExit(c, http.StatusBadRequest, "end was incorrectly provided", err)
+			return
+		}
+	}
+
+	extended := session == "extended"
+
+	bars := make(map[string][]Bar)
+	for _, symbol := range strings.Split(query.Get("symbols"), ",") {
+		bars[symbol], err = GetAggregatedBars(symbol, timeframe, start, end, extended)
+		if errors.Is(err, errAggregatedRange) {
+			ErrorExit(c, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+
+		if err != nil {
+			RequestExit(c, nil, err, "coludn't get the market data for these symbols")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bars": bars, "next_page_token": nil})
+}
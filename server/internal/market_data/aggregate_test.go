@@ -0,0 +1,143 @@
+package marketdata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+)
+
+// testDay is a market day with the pre-market from 4:00, the core session from 9:30 to 16:00 and the after
+// hours until 20:00 in New York, given as the offset of New York from UTC
+func testDay(date string, offset time.Duration) clock.MarketDay {
+	day, _ := time.Parse(time.DateOnly, date)
+	at := func(hours, minutes int) time.Time {
+		return day.Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute - offset)
+	}
+
+	return clock.MarketDay{Date: day, PreStart: at(4, 0), CoreStart: at(9, 30), CoreEnd: at(16, 0), PostEnd: at(20, 0)}
+}
+
+// minuteBars returns a bar for every minute of the days from the pre-market to the end of the after hours
+func minuteBars(days ...clock.MarketDay) []Bar {
+	var bars []Bar
+	for _, day := range days {
+		for at := day.PreStart; at.Before(day.PostEnd); at = at.Add(time.Minute) {
+			price := float64(len(bars) + 1)
+			bars = append(bars, Bar{Time: at, Open: price, High: price + 1, Low: price - 1, Close: price, Volume: 1, Trades: 1, VWAP: price})
+		}
+	}
+
+	return bars
+}
+
+func TestTimeFrame_Custom(t *testing.T) {
+	for _, tt := range []string{"2D", "90T", "5D"} {
+		if !TimeFrame(tt).Custom() {
+			t.Fatalf("expected %s to be custom", tt)
+		}
+	}
+
+	for _, tt := range []string{"5T", "4H", "1D"} {
+		if TimeFrame(tt).Custom() || !TimeFrame(tt).Aggregatable() {
+			t.Fatalf("expected %s to be native and aggregatable", tt)
+		}
+	}
+
+	for _, tt := range []string{"2W", "3M", "0T", "961T", "31D", "T"} {
+		if TimeFrame(tt).Custom() {
+			t.Fatalf("expected %s not to be custom", tt)
+		}
+	}
+}
+
+func TestAggregate_AlignsToTheSession(t *testing.T) {
+	// New York is 5 hours behind UTC in the winter and 4 in the summer, so the core session opens an hour apart
+	winter, summer := testDay("2026-03-06", 5*time.Hour), testDay("2026-03-09", 4*time.Hour)
+	days := []clock.MarketDay{winter, summer}
+
+	bars := Aggregate(minuteBars(winter, summer), "4H", days, false)
+	if len(bars) != 4 {
+		t.Fatalf("expected two bars for each core session, got %d", len(bars))
+	}
+
+	expected := []time.Time{winter.CoreStart, winter.CoreStart.Add(4 * time.Hour), summer.CoreStart, summer.CoreStart.Add(4 * time.Hour)}
+	for i, bar := range bars {
+		if !bar.Time.Equal(expected[i]) {
+			t.Fatalf("expected bar %d to start at %v, got %v", i, expected[i], bar.Time)
+		}
+	}
+
+	// The first bar of the day starts with the minute of the open and the second ends with the close
+	if bars[0].Volume != 240 || bars[1].Volume != 150 {
+		t.Fatalf("expected 240 and 150 minutes, got %v and %v", bars[0].Volume, bars[1].Volume)
+	}
+
+	first := bars[0]
+	if first.Open != 331 || first.Close != 570 || first.High != 571 || first.Low != 330 || first.VWAP != 450.5 {
+		t.Fatalf("unexpected first bar %+v", first)
+	}
+}
+
+func TestAggregate_ExtendedHoursAndDays(t *testing.T) {
+	days := []clock.MarketDay{testDay("2026-03-02", 5*time.Hour), testDay("2026-03-03", 5*time.Hour), testDay("2026-03-04", 5*time.Hour)}
+	minutes := minuteBars(days...)
+
+	extended := Aggregate(minutes, "4H", days, true)
+	if len(extended) != 12 || !extended[0].Time.Equal(days[0].PreStart) {
+		t.Fatalf("expected four bars a day from the pre-market, got %d", len(extended))
+	}
+
+	twoDays := Aggregate(minutes, "2D", days, false)
+	if len(twoDays) != 2 || twoDays[0].Volume != 2*390 || !twoDays[1].Time.Equal(days[2].CoreStart) {
+		t.Fatalf("expected the core sessions grouped by two market days, got %+v", twoDays)
+	}
+}
+
+func TestGetAggregatedBars_StartsFromTheBarOfStart(t *testing.T) {
+	days := []clock.MarketDay{testDay("2026-03-02", 5*time.Hour), testDay("2026-03-03", 5*time.Hour)}
+
+	previousDays, previousBars := getMarketDays, getMinuteBars
+	t.Cleanup(func() { getMarketDays, getMinuteBars = previousDays, previousBars })
+
+	getMarketDays = func(market string, start, end time.Time) ([]clock.MarketDay, error) {
+		return days, nil
+	}
+
+	var fetched time.Time
+	getMinuteBars = func(symbol string, start, end time.Time) ([]Bar, error) {
+		fetched = start
+		return minuteBars(days...), nil
+	}
+
+	start := days[1].CoreStart.Add(45 * time.Minute)
+	bars, err := GetAggregatedBars("AAPL", "30T", start, days[1].PostEnd, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fetched.Equal(days[0].CoreStart) {
+		t.Fatalf("expected the minutes from the first open, got %v", fetched)
+	}
+
+	if len(bars) != 12 || !bars[0].Time.Equal(days[1].CoreStart.Add(30*time.Minute)) {
+		t.Fatalf("expected the bars from the one start is in, got %d from %v", len(bars), bars[0].Time)
+	}
+
+	if _, err := GetAggregatedBars("AAPL", "30T", start.AddDate(-2, 0, 0), start, false); err == nil {
+		t.Fatal("expected ranges longer than a year to be refused")
+	}
+}
+
+func TestGetHistoricalBars_InvalidSession(t *testing.T) {
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest("GET", "/?timeframe=5T&session=overnight", nil)
+
+	GetHistoricalBars(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package marketdata
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/cache"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+)
+
+// The maximum number of bars Alpaca returns in a single page
+const barsPageSize = 10000
+
+type Bar struct {
+	Time   time.Time `json:"t"`
+	Open   float64   `json:"o"`
+	High   float64   `json:"h"`
+	Low    float64   `json:"l"`
+	Close  float64   `json:"c"`
+	Volume float64   `json:"v"`
+	Trades float64   `json:"n"`
+	VWAP   float64   `json:"vw"`
+}
+
+type barsPage struct {
+	Bars          map[string][]Bar `json:"bars"`
+	NextPageToken *string          `json:"next_page_token"`
+}
+
+// GetBars returns the split and dividend adjusted bars of the symbol between start and end, following every page.
+// The custom timeframes are built from the minutes of the core sessions.
+func GetBars(symbol string, timeframe TimeFrame, start, end time.Time) ([]Bar, error) {
+	if timeframe.Custom() {
+		return GetAggregatedBars(symbol, timeframe, start, end, false)
+	}
+
+	return alpacaBars(symbol, timeframe, start, end)
+}
+
+// alpacaBars gets the bars of one of the timeframes of alpaca
+func alpacaBars(symbol string, timeframe TimeFrame, start, end time.Time) ([]Bar, error) {
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		400: "One of the request parameters is invalid",
+		403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
+		429: "Too many requests",
+		500: "Internal server error. We recommend retrying these later",
+	}
+
+	query := url.Values{}
+	query.Set("symbols", symbol)
+	query.Set("timeframe", string(timeframe))
+	query.Set("start", start.UTC().Format(time.RFC3339))
+	query.Set("end", end.UTC().Format(time.RFC3339))
+	query.Set("adjustment", "all")
+	query.Set("limit", strconv.Itoa(barsPageSize))
+
+	// The pages of bars that already closed never change, so they're cached for good
+	ttl := BarsTTL(timeframe, end)
+
+	var bars []Bar
+	for {
+		url := MarketData + "/stocks/bars?" + query.Encode()
+		page, err := cache.Fetch("bars", url, ttl, func() (barsPage, error) {
+			return SendRequest[barsPage](http.MethodGet, url, nil, errs, headers)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		bars = append(bars, page.Bars[symbol]...)
+		if page.NextPageToken == nil || *page.NextPageToken == "" {
+			return bars, nil
+		}
+
+		query.Set("page_token", *page.NextPageToken)
+	}
+}
@@ -0,0 +1,61 @@
+package marketdata
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/cache"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+)
+
+const (
+	// Snapshots change with every trade, so they're only shared by the requests of the same few seconds
+	snapshotTTL = 5 * time.Second
+	// The latest bars, quotes and trades are kept even shorter
+	latestTTL = 2 * time.Second
+)
+
+// Duration is how long a single bar of the timeframe lasts. Months are counted as 30 days.
+func (t TimeFrame) Duration() time.Duration {
+	if !t.ValidTimeFrame() && !t.Aggregatable() {
+		return 0
+	}
+
+	n, err := strconv.Atoi(string(t[:len(t)-1]))
+	if err != nil || n == 0 {
+		n = 1
+	}
+
+	switch t[len(t)-1] {
+	case 'T':
+		return time.Duration(n) * time.Minute
+	case 'H':
+		return time.Duration(n) * time.Hour
+	case 'D':
+		return time.Duration(n) * 24 * time.Hour
+	case 'W':
+		return time.Duration(n) * 7 * 24 * time.Hour
+	default:
+		return time.Duration(n) * 30 * 24 * time.Hour
+	}
+}
+
+// BarsTTL is how long the bars up to end can be cached. Bars that are complete don't change anymore, so they're
+// kept for as long as complete values are. While the last bar is still forming it's refreshed every few seconds for minute bars and every
+// minute for the longer ones. A zero end means the bars go up to now.
+func BarsTTL(timeframe TimeFrame, end time.Time) time.Duration {
+	duration := timeframe.Duration()
+	if !end.IsZero() && !end.Add(duration).After(time.Now().UTC()) {
+		return cache.Complete
+	}
+
+	return min(max(duration/60, 5*time.Second), time.Minute)
+}
+
+// cachedRequest gets the market data through the cache, so identical requests of different users reach alpaca once
+func cachedRequest(kind, url string, ttl time.Duration, errs map[int]string) (map[string]any, error) {
+	return cache.Fetch(kind, url, ttl, func() (map[string]any, error) {
+		return SendRequest[map[string]any](http.MethodGet, url, nil, errs, BasicAuth())
+	})
+}
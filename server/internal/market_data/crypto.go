@@ -0,0 +1,102 @@
+package marketdata
+
+import (
+	"net/http"
+	"strings"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+)
+
+// The currencies crypto pairs are quoted in, the longer ones first so USDT isn't mistaken for USD
+var quoteCurrencies = []string{"USDT", "USDC", "USD", "BTC"}
+
+// CryptoSymbol turns the symbol of a crypto pair into the form the crypto market data expects (BTC/USD).
+// Positions and URL paths use the symbol without the slash (BTCUSD), so both are accepted.
+func CryptoSymbol(symbol string) string {
+	symbol = strings.ToUpper(symbol)
+	if strings.Contains(symbol, "/") {
+		return symbol
+	}
+
+	for _, currency := range quoteCurrencies {
+		if base, ok := strings.CutSuffix(symbol, currency); ok && base != "" {
+			return base + "/" + currency
+		}
+	}
+
+	return symbol
+}
+
+func cryptoSymbols(symbols string) string {
+	pairs := strings.Split(symbols, ",")
+	for i := range pairs {
+		pairs[i] = CryptoSymbol(pairs[i])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// sendCryptoRequest gets the crypto market data at the given path and writes it in the response
+func sendCryptoRequest(c *gin.Context, path, msg string) {
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		400: "One of the request parameters is invalid",
+		403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
+		429: "Too many requests",
+		500: "Internal server error. We recommend retrying these later",
+	}
+
+	body, err := SendRequest[any](http.MethodGet, CryptoData+path, nil, errs, headers)
+	if err != nil {
+		RequestExit(c, body, err, msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+func GetHistoricalCryptoBars(c *gin.Context) {
+	symbols := cryptoSymbols(c.GetString("symbols"))
+	start := c.GetString("start")
+
+	timeframe := TimeFrame(c.Query("timeframe"))
+	if timeframe == "" || !timeframe.ValidTimeFrame() {
+		ErrorExit(c, http.StatusBadRequest, "timeframe was incorrectly provided", nil)
+		return
+	}
+
+	sendCryptoRequest(c, "/bars?symbols="+symbols+start+c.GetString("params")+"&timeframe="+string(timeframe), "coludn't get the market data for these symbols")
+}
+
+func GetLatestCryptoBars(c *gin.Context) {
+	sendCryptoRequest(c, "/latest/bars?symbols="+cryptoSymbols(c.GetString("symbols")), "coludn't get the market data for these symbols")
+}
+
+func GetHistoricalCryptoQuotes(c *gin.Context) {
+	symbols := cryptoSymbols(c.GetString("symbols"))
+	start := c.GetString("start")
+
+	sendCryptoRequest(c, "/quotes?symbols="+symbols+start+c.GetString("params"), "coludn't get the qoutes for these symbols")
+}
+
+func GetLatestCryptoQuotes(c *gin.Context) {
+	sendCryptoRequest(c, "/latest/quotes?symbols="+cryptoSymbols(c.GetString("symbols")), "coludn't get the qoutes for these symbols")
+}
+
+func GetHistoricalCryptoTrades(c *gin.Context) {
+	symbols := cryptoSymbols(c.GetString("symbols"))
+	start := c.GetString("start")
+
+	sendCryptoRequest(c, "/trades?symbols="+symbols+start+c.GetString("params"), "coludn't get the trades for these symbols")
+}
+
+func GetLatestCryptoTrades(c *gin.Context) {
+	sendCryptoRequest(c, "/latest/trades?symbols="+cryptoSymbols(c.GetString("symbols")), "coludn't get the trades for these symbols")
+}
+
+func GetCryptoSnapshots(c *gin.Context) {
+	sendCryptoRequest(c, "/snapshots?symbols="+cryptoSymbols(c.GetString("symbols")), "coludn't get the snapshots for these symbols")
+}
@@ -0,0 +1,475 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/cache"
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Exports ask for the biggest pages alpaca sends, so they take as few requests as possible
+const exportPageSize = "10000"
+
+type Trade struct {
+	Time       time.Time `json:"t"`
+	Price      float64   `json:"p"`
+	Size       float64   `json:"s"`
+	Exchange   string    `json:"x"`
+	ID         int64     `json:"i"`
+	Conditions []string  `json:"c"`
+	Tape       string    `json:"z"`
+}
+
+type Quote struct {
+	Time        time.Time `json:"t"`
+	BidPrice    float64   `json:"bp"`
+	BidSize     float64   `json:"bs"`
+	BidExchange string    `json:"bx"`
+	AskPrice    float64   `json:"ap"`
+	AskSize     float64   `json:"as"`
+	AskExchange string    `json:"ax"`
+	Conditions  []string  `json:"c"`
+	Tape        string    `json:"z"`
+}
+
+// historicalPage is a page of the historical bars, trades or quotes of alpaca. Only the map of its kind is set.
+type historicalPage[T any] struct {
+	Bars          map[string][]T `json:"bars,omitempty"`
+	Trades        map[string][]T `json:"trades,omitempty"`
+	Quotes        map[string][]T `json:"quotes,omitempty"`
+	NextPageToken *string        `json:"next_page_token"`
+}
+
+func (p historicalPage[T]) items() map[string][]T {
+	switch {
+	case p.Bars != nil:
+		return p.Bars
+	case p.Trades != nil:
+		return p.Trades
+	default:
+		return p.Quotes
+	}
+}
+
+// record is a row of an export, which can also be written as the fields of a CSV
+type record interface {
+	header() []string
+	fields() []string
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+type barRow struct {
+	Symbol     string    `json:"symbol" parquet:"symbol,dict"`
+	Time       time.Time `json:"timestamp" parquet:"timestamp,timestamp(microsecond)"`
+	Open       float64   `json:"open" parquet:"open"`
+	High       float64   `json:"high" parquet:"high"`
+	Low        float64   `json:"low" parquet:"low"`
+	Close      float64   `json:"close" parquet:"close"`
+	Volume     float64   `json:"volume" parquet:"volume"`
+	TradeCount float64   `json:"trade_count" parquet:"trade_count"`
+	VWAP       float64   `json:"vwap" parquet:"vwap"`
+}
+
+func newBarRow(symbol string, b Bar) barRow {
+	return barRow{symbol, b.Time, b.Open, b.High, b.Low, b.Close, b.Volume, b.Trades, b.VWAP}
+}
+
+func (barRow) header() []string {
+	return []string{"symbol", "timestamp", "open", "high", "low", "close", "volume", "trade_count", "vwap"}
+}
+
+func (r barRow) fields() []string {
+	return []string{r.Symbol, r.Time.Format(time.RFC3339Nano), formatFloat(r.Open), formatFloat(r.High), formatFloat(r.Low),
+		formatFloat(r.Close), formatFloat(r.Volume), formatFloat(r.TradeCount), formatFloat(r.VWAP)}
+}
+
+type tradeRow struct {
+	Symbol     string    `json:"symbol" parquet:"symbol,dict"`
+	Time       time.Time `json:"timestamp" parquet:"timestamp,timestamp(microsecond)"`
+	Price      float64   `json:"price" parquet:"price"`
+	Size       float64   `json:"size" parquet:"size"`
+	Exchange   string    `json:"exchange" parquet:"exchange,dict"`
+	ID         int64     `json:"id" parquet:"id"`
+	Conditions []string  `json:"conditions" parquet:"conditions,list"`
+	Tape       string    `json:"tape" parquet:"tape,dict"`
+}
+
+func newTradeRow(symbol string, t Trade) tradeRow {
+	return tradeRow{symbol, t.Time, t.Price, t.Size, t.Exchange, t.ID, t.Conditions, t.Tape}
+}
+
+func (tradeRow) header() []string {
+	return []string{"symbol", "timestamp", "price", "size", "exchange", "id", "conditions", "tape"}
+}
+
+func (r tradeRow) fields() []string {
+	return []string{r.Symbol, r.Time.Format(time.RFC3339Nano), formatFloat(r.Price), formatFloat(r.Size), r.Exchange,
+		strconv.FormatInt(r.ID, 10), strings.Join(r.Conditions, " "), r.Tape}
+}
+
+type quoteRow struct {
+	Symbol      string    `json:"symbol" parquet:"symbol,dict"`
+	Time        time.Time `json:"timestamp" parquet:"timestamp,timestamp(microsecond)"`
+	BidPrice    float64   `json:"bid_price" parquet:"bid_price"`
+	BidSize     float64   `json:"bid_size" parquet:"bid_size"`
+	BidExchange string    `json:"bid_exchange" parquet:"bid_exchange,dict"`
+	AskPrice    float64   `json:"ask_price" parquet:"ask_price"`
+	AskSize     float64   `json:"ask_size" parquet:"ask_size"`
+	AskExchange string    `json:"ask_exchange" parquet:"ask_exchange,dict"`
+	Conditions  []string  `json:"conditions" parquet:"conditions,list"`
+	Tape        string    `json:"tape" parquet:"tape,dict"`
+}
+
+func newQuoteRow(symbol string, q Quote) quoteRow {
+	return quoteRow{symbol, q.Time, q.BidPrice, q.BidSize, q.BidExchange, q.AskPrice, q.AskSize, q.AskExchange, q.Conditions, q.Tape}
+}
+
+func (quoteRow) header() []string {
+	return []string{"symbol", "timestamp", "bid_price", "bid_size", "bid_exchange", "ask_price", "ask_size", "ask_exchange", "conditions", "tape"}
+}
+
+func (r quoteRow) fields() []string {
+	return []string{r.Symbol, r.Time.Format(time.RFC3339Nano), formatFloat(r.BidPrice), formatFloat(r.BidSize), r.BidExchange,
+		formatFloat(r.AskPrice), formatFloat(r.AskSize), r.AskExchange, strings.Join(r.Conditions, " "), r.Tape}
+}
+
+type exportFormat struct {
+	contentType string
+	extension   string
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":     {"text/csv", "csv"},
+	"ndjson":  {"application/x-ndjson", "ndjson"},
+	"parquet": {"application/vnd.apache.parquet", "parquet"},
+}
+
+// exportWriter writes the rows of an export in one of the formats. Close finishes the file.
+type exportWriter[R record] interface {
+	write(rows []R) error
+	close() error
+}
+
+type csvExport[R record] struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (e *csvExport[R]) write(rows []R) error {
+	if !e.header {
+		var zero R
+		if err := e.w.Write(zero.header()); err != nil {
+			return err
+		}
+		e.header = true
+	}
+
+	for _, row := range rows {
+		if err := e.w.Write(row.fields()); err != nil {
+			return err
+		}
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport[R]) close() error {
+	// The header is written even when there are no rows
+	return e.write(nil)
+}
+
+type ndjsonExport[R record] struct {
+	encoder *json.Encoder
+}
+
+func (e ndjsonExport[R]) write(rows []R) error {
+	for _, row := range rows {
+		if err := e.encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e ndjsonExport[R]) close() error {
+	return nil
+}
+
+// parquetExport writes a row group for every page, so the file isn't kept in memory until the end
+type parquetExport[R record] struct {
+	w *parquet.GenericWriter[R]
+}
+
+func (e parquetExport[R]) write(rows []R) error {
+	if _, err := e.w.Write(rows); err != nil {
+		return err
+	}
+
+	return e.w.Flush()
+}
+
+func (e parquetExport[R]) close() error {
+	return e.w.Close()
+}
+
+func newExportWriter[R record](format string, w io.Writer) exportWriter[R] {
+	switch format {
+	case "ndjson":
+		return ndjsonExport[R]{encoder: json.NewEncoder(w)}
+	case "parquet":
+		return parquetExport[R]{w: parquet.NewGenericWriter[R](w)}
+	default:
+		return &csvExport[R]{w: csv.NewWriter(w)}
+	}
+}
+
+// rowSource hands the rows of an export to each, a page at a time
+type rowSource[R record] func(each func([]R) error) error
+
+// pageRows turns the items of a page into rows, ordered by symbol like alpaca orders them
+func pageRows[T any, R record](items map[string][]T, row func(string, T) R) []R {
+	symbols := make([]string, 0, len(items))
+	for symbol := range items {
+		symbols = append(symbols, symbol)
+	}
+	slices.Sort(symbols)
+
+	var rows []R
+	for _, symbol := range symbols {
+		for _, item := range items[symbol] {
+			rows = append(rows, row(symbol, item))
+		}
+	}
+
+	return rows
+}
+
+// alpacaRows follows every page of the historical data at the path until there are no more of them or the
+// client goes away. The pages of bars go through the cache like the ones of GetHistoricalBars.
+func alpacaRows[T any, R record](ctx context.Context, path string, query url.Values, ttl time.Duration, row func(string, T) R) rowSource[R] {
+	errs := map[int]string{
+		400: "One of the request parameters is invalid",
+		403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
+		429: "Too many requests",
+		500: "Internal server error. We recommend retrying these later",
+	}
+
+	fetch := func(url string) (historicalPage[T], error) {
+		return SendRequest[historicalPage[T]](http.MethodGet, url, nil, errs, BasicAuth())
+	}
+
+	if strings.HasSuffix(path, "/bars") {
+		uncached := fetch
+		fetch = func(url string) (historicalPage[T], error) {
+			return cache.Fetch("bars", url, ttl, func() (historicalPage[T], error) {
+				return uncached(url)
+			})
+		}
+	}
+
+	return func(each func([]R) error) error {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			page, err := fetch(path + "?" + query.Encode())
+			if err != nil {
+				return err
+			}
+
+			if err := each(pageRows(page.items(), row)); err != nil {
+				return err
+			}
+
+			if page.NextPageToken == nil || *page.NextPageToken == "" {
+				return nil
+			}
+
+			query.Set("page_token", *page.NextPageToken)
+		}
+	}
+}
+
+// aggregatedRows builds the bars of every symbol from their minute bars, a symbol at a time
+func aggregatedRows(symbols []string, timeframe TimeFrame, start, end time.Time, extended bool) rowSource[barRow] {
+	return func(each func([]barRow) error) error {
+		for _, symbol := range symbols {
+			bars, err := GetAggregatedBars(symbol, timeframe, start, end, extended)
+			if err != nil {
+				return err
+			}
+
+			rows := make([]barRow, len(bars))
+			for i, bar := range bars {
+				rows[i] = newBarRow(symbol, bar)
+			}
+
+			if err := each(rows); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// writeExport streams the rows of the source as a file. The file is only started once the first page is here,
+// so a failing request still gets its error. Later errors can't change the status anymore, so the connection is
+// closed before the file is finished and the download fails instead of looking complete.
+func writeExport[R record](c *gin.Context, format, name string, source rowSource[R]) {
+	var writer exportWriter[R]
+
+	err := source(func(rows []R) error {
+		if writer == nil {
+			c.Header("Content-Type", exportFormats[format].contentType)
+			c.Header("Content-Disposition", `attachment; filename="`+name+"."+exportFormats[format].extension+`"`)
+			c.Status(http.StatusOK)
+			writer = newExportWriter[R](format, c.Writer)
+		}
+
+		if err := writer.write(rows); err != nil {
+			return err
+		}
+
+		c.Writer.Flush()
+		return nil
+	})
+
+	switch {
+	case err != nil && writer == nil && errors.Is(err, errAggregatedRange):
+		ErrorExit(c, http.StatusBadRequest, err.Error(), err)
+	case err != nil && writer == nil:
+		RequestExit(c, nil, err, "coludn't export the market data for these symbols")
+	case err != nil:
+		log.Println(err)
+
+		if format == "ndjson" {
+			json.NewEncoder(c.Writer).Encode(gin.H{"error": "coludn't export all of the market data for these symbols"})
+			return
+		}
+
+		// gin doesn't hijack once something is written, so the connection of the server is taken directly
+		if conn, _, err := http.NewResponseController(c.Writer).Hijack(); err == nil {
+			conn.Close()
+		}
+	default:
+		if err := writer.close(); err != nil {
+			log.Println(err)
+		}
+
+		c.Writer.Flush()
+	}
+}
+
+// GetExport streams the historical bars, trades or quotes of the symbols as a CSV, Parquet or NDJSON file,
+// following every page of alpaca
+func GetExport(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if _, ok := exportFormats[format]; !ok {
+		ErrorExit(c, http.StatusBadRequest, "the format should be csv, parquet or ndjson", nil)
+		return
+	}
+
+	symbols := strings.Split(c.GetString("symbols"), ",")
+	crypto := 0
+	for _, symbol := range symbols {
+		if isCrypto(symbol) {
+			crypto++
+		}
+	}
+
+	if crypto > 0 && crypto < len(symbols) {
+		ErrorExit(c, http.StatusBadRequest, "stocks and crypto can't be exported together", nil)
+		return
+	}
+
+	query := historicalQuery(c)
+	if !query.Has("limit") {
+		query.Set("limit", exportPageSize)
+	}
+
+	path := MarketData + "/stocks/"
+	if crypto > 0 {
+		path = CryptoData + "/"
+		query.Set("symbols", cryptoSymbols(strings.Join(symbols, ",")))
+	}
+
+	kind := c.DefaultQuery("kind", "bars")
+	name := strings.NewReplacer(",", "_", "/", "-").Replace(query.Get("symbols")) + "_" + kind
+
+	switch kind {
+	case "bars":
+		timeframe := TimeFrame(c.Query("timeframe"))
+		if !timeframe.ValidTimeFrame() && !timeframe.Aggregatable() {
+			ErrorExit(c, http.StatusBadRequest, "timeframe was incorrectly provided", nil)
+			return
+		}
+
+		name += "_" + string(timeframe)
+
+		// A missing end means the bars go up to now
+		var end time.Time
+		if query.Has("end") {
+			var err error
+			if end, err = ParseTime(query.Get("end")); err != nil {
+				ErrorExit(c, http.StatusBadRequest, "end was incorrectly provided", err)
+				return
+			}
+		}
+
+		session := c.Query("session")
+		if crypto == 0 && (timeframe.Custom() || session != "") {
+			if session != "" && session != "core" && session != "extended" {
+				ErrorExit(c, http.StatusBadRequest, "the session should be core or extended", nil)
+				return
+			}
+
+			start, err := ParseTime(query.Get("start"))
+			if err != nil {
+				ErrorExit(c, http.StatusBadRequest, "start was incorrectly provided", err)
+				return
+			}
+
+			if end.IsZero() {
+				end = time.Now().UTC()
+			}
+
+			writeExport(c, format, name, aggregatedRows(symbols, timeframe, start, end, session == "extended"))
+			return
+		}
+
+		if !timeframe.ValidTimeFrame() {
+			ErrorExit(c, http.StatusBadRequest, "timeframe was incorrectly provided", nil)
+			return
+		}
+
+		query.Set("timeframe", string(timeframe))
+		writeExport(c, format, name, alpacaRows(c.Request.Context(), path+"bars", query, BarsTTL(timeframe, end), newBarRow))
+	case "trades":
+		writeExport(c, format, name, alpacaRows(c.Request.Context(), path+"trades", query, 0, newTradeRow))
+	case "quotes":
+		writeExport(c, format, name, alpacaRows(c.Request.Context(), path+"quotes", query, 0, newQuoteRow))
+	default:
+		ErrorExit(c, http.StatusBadRequest, "the kind should be bars, trades or quotes", nil)
+	}
+}
@@ -0,0 +1,139 @@
+package marketdata
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// pagedRows is a source with the given pages of bars, failing with err after them when it's set
+func pagedRows(pages [][]barRow, err error) rowSource[barRow] {
+	return func(each func([]barRow) error) error {
+		for _, page := range pages {
+			if err := each(page); err != nil {
+				return err
+			}
+		}
+
+		return err
+	}
+}
+
+func exportBars() [][]barRow {
+	at := time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC)
+
+	return [][]barRow{
+		{{Symbol: "AAPL", Time: at, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 100}},
+		{{Symbol: "MSFT", Time: at, Open: 3, High: 4, Low: 2.5, Close: 3.5, Volume: 200}},
+	}
+}
+
+func TestWriteExport_CSV(t *testing.T) {
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest(http.MethodGet, "/data/export", nil)
+
+	writeExport(c, "csv", "AAPL_MSFT_bars_1D", pagedRows(exportBars(), nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, "AAPL_MSFT_bars_1D.csv") {
+		t.Fatalf("unexpected disposition %q", disposition)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 3 || records[0][0] != "symbol" || records[2][0] != "MSFT" || records[2][5] != "3.5" {
+		t.Fatalf("unexpected records %v", records)
+	}
+}
+
+func TestWriteExport_Parquet(t *testing.T) {
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest(http.MethodGet, "/data/export", nil)
+
+	writeExport(c, "parquet", "AAPL_MSFT_bars_1D", pagedRows(exportBars(), nil))
+
+	body := w.Body.Bytes()
+	rows, err := parquet.Read[barRow](bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 || rows[1].Symbol != "MSFT" || rows[1].Close != 3.5 || !rows[0].Time.Equal(exportBars()[0][0].Time) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+}
+
+func TestWriteExport_FirstPageError(t *testing.T) {
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest(http.MethodGet, "/data/export", nil)
+
+	writeExport(c, "csv", "AAPL_bars_1D", pagedRows(nil, errors.New("Too many requests")))
+
+	if w.Code != http.StatusFailedDependency {
+		t.Fatalf("expected 424, got %d", w.Code)
+	}
+}
+
+func TestWriteExport_NDJSONReportsLaterErrors(t *testing.T) {
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest(http.MethodGet, "/data/export", nil)
+
+	writeExport(c, "ndjson", "AAPL_MSFT_bars_1D", pagedRows(exportBars(), errors.New("Too many requests")))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %v", lines)
+	}
+
+	var last map[string]any
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil || last["error"] == nil {
+		t.Fatalf("expected the last line to be an error, got %s", lines[2])
+	}
+}
+
+func TestPageRows_OrdersSymbols(t *testing.T) {
+	page := historicalPage[Trade]{Trades: map[string][]Trade{
+		"MSFT": {{Price: 2}},
+		"AAPL": {{Price: 1}, {Price: 3}},
+	}}
+
+	rows := pageRows(page.items(), newTradeRow)
+	if len(rows) != 3 || rows[0].Symbol != "AAPL" || rows[1].Price != 3 || rows[2].Symbol != "MSFT" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+}
+
+func TestGetExport_InvalidParameters(t *testing.T) {
+	tests := []string{
+		"symbols=AAPL&format=xlsx",
+		"symbols=AAPL&kind=auctions",
+		"symbols=AAPL&timeframe=7Y",
+		"symbols=AAPL,BTC/USD&kind=trades",
+	}
+
+	for _, tt := range tests {
+		c, w := createGinContext()
+		c.Request = httptest.NewRequest(http.MethodGet, "/data/export?"+tt, nil)
+		c.Set("symbols", c.Query("symbols"))
+
+		GetExport(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", tt, w.Code)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package marketdata
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// The number of subscription changes waiting to be sent upstream. The ones that don't fit wait in pending.
+	writeQueueSize = 64
+	// How long alpaca has to accept and authenticate a new connection
+	dialTimeout = 10 * time.Second
+	// After this many failed attempts in a row the users are told that the stream is degraded
+	degradedAfter = 3
+)
+
+// The states of the upstream connection the users are told about
+const (
+	StatusConnected    = "connected"
+	StatusReconnecting = "reconnecting"
+	StatusDegraded     = "degraded"
+)
+
+// The error codes of alpaca's real time streams the feed handles
+const (
+	codeNotAuthenticated = 401
+	codeAuthFailed       = 402
+	codeConnectionLimit  = 406
+)
+
+// streamError is an error message of the upstream stream, like [{"T":"error","code":406,"msg":"connection limit exceeded"}]
+type streamError struct {
+	Code int
+	Msg  string
+}
+
+func (e *streamError) Error() string {
+	return strconv.Itoa(e.Code) + " " + e.Msg
+}
+
+func newStreamError(msg map[string]any) *streamError {
+	code, _ := msg["code"].(float64)
+	text, _ := msg["msg"].(string)
+
+	return &streamError{Code: int(code), Msg: text}
+}
+
+type dialResult struct {
+	ws  *websocket.Conn
+	err error
+}
+
+// feed is the connection to one of alpaca's real time streams. Only run writes to it and only listen
+// reads from it, so the subscriptions are sent upstream one after the other. When the connection is lost
+// run reconnects with an exponential backoff and subscribes to everything the users still listen to.
+// The hub never waits on the feed, which waits on the hub to take its updates.
+type feed struct {
+	hub        *Hub
+	url        string
+	crypto     bool
+	writes     chan Subscription
+	mu         sync.Mutex
+	pending    []Subscription // the changes the hub queued that aren't in writes yet
+	forwarding bool
+	lost       chan *websocket.Conn
+	dialed     chan dialResult
+	ws         *websocket.Conn
+	dialing    bool
+	retry      <-chan time.Time
+	attempts   int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	subscribed map[string]map[string]struct{} // channel -> symbols, what alpaca is sending us
+}
+
+func newFeed(hub *Hub, url string, crypto bool) *feed {
+	return &feed{
+		hub:        hub,
+		url:        url,
+		crypto:     crypto,
+		writes:     make(chan Subscription, writeQueueSize),
+		lost:       make(chan *websocket.Conn),
+		dialed:     make(chan dialResult),
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+		subscribed: make(map[string]map[string]struct{}),
+	}
+}
+
+// queue hands a change over to run without waiting for it. Changes of the same action that wait one after
+// the other are merged into one.
+func (f *feed) queue(subscription Subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if last := len(f.pending) - 1; last >= 0 && f.pending[last].Action == subscription.Action {
+		for channel, symbols := range subscription.channels() {
+			for _, symbol := range symbols {
+				f.pending[last].add(channel, symbol)
+			}
+		}
+	} else {
+		f.pending = append(f.pending, subscription)
+	}
+
+	if !f.forwarding {
+		f.forwarding = true
+		go f.forward()
+	}
+}
+
+// forward moves the pending changes into writes in order and stops once there are none left or the hub stopped
+func (f *feed) forward() {
+	for {
+		f.mu.Lock()
+		if len(f.pending) == 0 {
+			f.forwarding = false
+			f.mu.Unlock()
+			return
+		}
+
+		next := f.pending[0]
+		f.pending = f.pending[1:]
+		f.mu.Unlock()
+
+		select {
+		case f.writes <- next:
+		case <-f.hub.done:
+			return
+		}
+	}
+}
+
+func (f *feed) run() {
+	for {
+		select {
+		case subscription := <-f.writes:
+			f.track(subscription)
+
+			if f.ws != nil {
+				f.write(subscription)
+				continue
+			}
+
+			// The first subscription opens the connection, which then subscribes to everything at once.
+			// While a reconnect is scheduled we wait for it instead.
+			if subscription.Action == "subscribe" && !f.dialing && f.retry == nil {
+				f.connect()
+			}
+
+		case ws := <-f.lost:
+			if ws != f.ws {
+				continue
+			}
+
+			f.ws = nil
+			f.status(StatusReconnecting, "The connection to the real time data stream was lost, reconnecting")
+			f.schedule()
+
+		case <-f.retry:
+			f.retry = nil
+			f.connect()
+
+		case result := <-f.dialed:
+			f.dialing = false
+			if result.err != nil {
+				f.failed(result.err)
+				continue
+			}
+
+			f.connected(result.ws)
+		}
+	}
+}
+
+func (f *feed) track(subscription Subscription) {
+	for channel, symbols := range subscription.channels() {
+		for _, symbol := range symbols {
+			if subscription.Action == "unsubscribe" {
+				delete(f.subscribed[channel], symbol)
+				if len(f.subscribed[channel]) == 0 {
+					delete(f.subscribed, channel)
+				}
+
+				continue
+			}
+
+			if f.subscribed[channel] == nil {
+				f.subscribed[channel] = make(map[string]struct{})
+			}
+			f.subscribed[channel][symbol] = struct{}{}
+		}
+	}
+}
+
+// connect dials in the background, so the subscriptions keep coming in while alpaca answers
+func (f *feed) connect() {
+	f.dialing = true
+	go func() {
+		ws, err := f.dial()
+		f.dialed <- dialResult{ws: ws, err: err}
+	}()
+}
+
+func (f *feed) connected(ws *websocket.Conn) {
+	f.ws = ws
+	f.attempts = 0
+	go f.listen(ws)
+
+	f.status(StatusConnected, "")
+
+	all := Subscription{Action: "subscribe"}
+	for channel, symbols := range f.subscribed {
+		for symbol := range symbols {
+			all.add(channel, symbol)
+		}
+	}
+
+	if !all.empty() {
+		f.write(all)
+	}
+}
+
+// failed decides if connecting again can help. Rejected credentials won't get better by themselves,
+// so the feed waits for the next subscription before trying again.
+func (f *feed) failed(err error) {
+	log.Println(err)
+	f.attempts++
+
+	var streamErr *streamError
+	if errors.As(err, &streamErr) {
+		switch streamErr.Code {
+		case codeNotAuthenticated, codeAuthFailed:
+			f.status(StatusDegraded, "Error the real time data stream rejected the credentials of the server")
+			return
+		case codeConnectionLimit:
+			f.status(StatusDegraded, "Error the real time data stream has too many connections open, reconnecting")
+			f.schedule()
+			return
+		}
+	}
+
+	if f.attempts >= degradedAfter {
+		f.status(StatusDegraded, "Error couldn't reconnect to the real time data stream, still trying")
+	} else {
+		f.status(StatusReconnecting, "Couldn't connect to the real time data stream, trying again")
+	}
+
+	f.schedule()
+}
+
+// schedule waits before reconnecting, twice as long after every failed attempt. Nobody has to be
+// reconnected if there's nothing to listen to.
+func (f *feed) schedule() {
+	if len(f.subscribed) == 0 {
+		return
+	}
+
+	f.retry = time.After(f.backoff())
+}
+
+func (f *feed) backoff() time.Duration {
+	backoff := f.minBackoff
+	for i := 1; i < f.attempts && backoff < f.maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, f.maxBackoff)
+}
+
+func (f *feed) dial() (*websocket.Conn, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: dialTimeout}
+	ws, _, err := dialer.Dial(f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ws.SetReadDeadline(time.Now().Add(dialTimeout))
+
+	if err = expect(ws, "connected"); err != nil {
+		ws.Close()
+		return nil, err
+	}
+
+	authMsg := map[string]string{
+		"action": "auth",
+		"key":    os.Getenv("API_KEY"),
+		"secret": os.Getenv("SECRET_KEY"),
+	}
+
+	if err = ws.WriteJSON(authMsg); err != nil {
+		ws.Close()
+		return nil, err
+	}
+
+	if err = expect(ws, "authenticated"); err != nil {
+		ws.Close()
+		return nil, err
+	}
+
+	ws.SetReadDeadline(time.Time{})
+
+	return ws, nil
+}
+
+// expect reads the next message of the stream, which has to be a success one with the given text
+func expect(ws *websocket.Conn, text string) error {
+	var body []map[string]any
+	if err := ws.ReadJSON(&body); err != nil {
+		return err
+	}
+
+	if len(body) == 0 {
+		return errors.New("the real time data stream sent an empty message")
+	}
+
+	if body[0]["T"] == "error" {
+		return newStreamError(body[0])
+	}
+
+	if body[0]["T"] != "success" || body[0]["msg"] != text {
+		return errors.New("the real time data stream didn't send " + text)
+	}
+
+	return nil
+}
+
+// write closes a connection it can't write to, so listen reports it as lost and it gets replaced
+func (f *feed) write(subscription Subscription) {
+	if err := f.ws.WriteJSON(subscription); err != nil {
+		log.Println(err)
+		f.ws.Close()
+	}
+}
+
+func (f *feed) status(status, msg string) {
+	f.hub.Broadcast <- &Message{Crypto: f.crypto, Status: status, Msg: msg}
+}
+
+func (f *feed) listen(ws *websocket.Conn) {
+	for {
+		var body []map[string]any
+		if err := ws.ReadJSON(&body); err != nil {
+			log.Println(err)
+			ws.Close()
+			f.lost <- ws
+			return
+		}
+
+		for _, msg := range body {
+			switch msg["T"] {
+			case "subscription", "success":
+			case "error":
+				log.Println("There was an error doing the last action")
+				log.Println(msg)
+			default:
+				f.hub.Broadcast <- &Message{Crypto: f.crypto, Data: msg}
+			}
+		}
+	}
+}
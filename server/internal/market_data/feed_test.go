@@ -0,0 +1,221 @@
+package marketdata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	authenticated   = []map[string]any{{"T": "success", "msg": "authenticated"}}
+	authFailed      = []map[string]any{{"T": "error", "code": 402, "msg": "auth failed"}}
+	connectionLimit = []map[string]any{{"T": "error", "code": 406, "msg": "connection limit exceeded"}}
+)
+
+// fakeStream behaves like alpaca's real time stream. It answers the auth of every connection with
+// what auth returns for that attempt and hands over the ones it accepts.
+type fakeStream struct {
+	server        *httptest.Server
+	auth          func(attempt int) []map[string]any
+	conns         chan *websocket.Conn
+	subscriptions chan Subscription
+	mu            sync.Mutex
+	attempts      int
+}
+
+func newFakeStream(t *testing.T, auth func(attempt int) []map[string]any) *fakeStream {
+	f := &fakeStream{
+		auth:          auth,
+		conns:         make(chan *websocket.Conn, 16),
+		subscriptions: make(chan Subscription, 16),
+	}
+
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *fakeStream) handle(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	f.mu.Lock()
+	f.attempts++
+	attempt := f.attempts
+	f.mu.Unlock()
+
+	ws.WriteJSON([]map[string]any{{"T": "success", "msg": "connected"}})
+
+	var auth map[string]string
+	if err := ws.ReadJSON(&auth); err != nil || auth["action"] != "auth" {
+		ws.Close()
+		return
+	}
+
+	reply := f.auth(attempt)
+	ws.WriteJSON(reply)
+	if reply[0]["T"] == "error" {
+		ws.Close()
+		return
+	}
+
+	f.conns <- ws
+
+	for {
+		var subscription Subscription
+		if err := ws.ReadJSON(&subscription); err != nil {
+			return
+		}
+
+		f.subscriptions <- subscription
+	}
+}
+
+func (f *fakeStream) Attempts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.attempts
+}
+
+func newStreamHub(f *fakeStream) *Hub {
+	hub := NewHub()
+	hub.schedule = &fakeSchedule{session: clock.Core}
+	hub.stocks.url = "ws" + strings.TrimPrefix(f.server.URL, "http")
+	hub.stocks.minBackoff = 10 * time.Millisecond
+	hub.stocks.maxBackoff = 50 * time.Millisecond
+
+	go hub.Run()
+
+	return hub
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stream")
+	}
+
+	var zero T
+	return zero
+}
+
+func expectStatus(t *testing.T, user *User, status string) map[string]any {
+	t.Helper()
+
+	msg := receive(t, user.send)
+	if msg["T"] != "status" || msg["status"] != status {
+		t.Fatalf("expected the %s status, got %v", status, msg)
+	}
+
+	return msg
+}
+
+func TestFeed_ReconnectsAndResubscribes(t *testing.T) {
+	stream := newFakeStream(t, func(int) []map[string]any { return authenticated })
+	hub := newStreamHub(stream)
+
+	user := newUser(nil)
+	hub.Register <- user
+	subscribe(t, hub, user, Subscription{Action: "subscribe", Trades: []string{"AAPL"}})
+
+	expectStatus(t, user, StatusConnected)
+	if s := receive(t, stream.subscriptions); len(s.Trades) != 1 || s.Trades[0] != "AAPL" {
+		t.Fatalf("unexpected subscription %+v", s)
+	}
+
+	conn := receive(t, stream.conns)
+	conn.WriteJSON([]map[string]any{{"T": "t", "S": "AAPL", "p": 100.0}})
+	if msg := receive(t, user.send); msg["p"] != 100.0 {
+		t.Fatalf("expected the trade, got %v", msg)
+	}
+
+	conn.Close()
+
+	expectStatus(t, user, StatusReconnecting)
+	expectStatus(t, user, StatusConnected)
+	if s := receive(t, stream.subscriptions); s.Action != "subscribe" || len(s.Trades) != 1 || s.Trades[0] != "AAPL" {
+		t.Fatalf("expected to subscribe to AAPL again, got %+v", s)
+	}
+
+	conn = receive(t, stream.conns)
+	conn.WriteJSON([]map[string]any{{"T": "t", "S": "AAPL", "p": 101.0}})
+	if msg := receive(t, user.send); msg["p"] != 101.0 {
+		t.Fatalf("expected the trade after reconnecting, got %v", msg)
+	}
+}
+
+func TestFeed_StopsRetryingWhenAuthFails(t *testing.T) {
+	stream := newFakeStream(t, func(int) []map[string]any { return authFailed })
+	hub := newStreamHub(stream)
+
+	user := newUser(nil)
+	hub.Register <- user
+	subscribe(t, hub, user, Subscription{Action: "subscribe", Trades: []string{"AAPL"}})
+
+	if msg := expectStatus(t, user, StatusDegraded); msg["msg"] == nil {
+		t.Fatal("expected the degraded status to explain why")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if attempts := stream.Attempts(); attempts != 1 {
+		t.Fatalf("expected a single attempt with rejected credentials, got %d", attempts)
+	}
+}
+
+func TestFeed_RetriesWhenTheConnectionLimitIsExceeded(t *testing.T) {
+	stream := newFakeStream(t, func(attempt int) []map[string]any {
+		if attempt == 1 {
+			return connectionLimit
+		}
+
+		return authenticated
+	})
+	hub := newStreamHub(stream)
+
+	user := newUser(nil)
+	hub.Register <- user
+	subscribe(t, hub, user, Subscription{Action: "subscribe", Trades: []string{"AAPL"}})
+
+	expectStatus(t, user, StatusDegraded)
+	expectStatus(t, user, StatusConnected)
+
+	if s := receive(t, stream.subscriptions); len(s.Trades) != 1 || s.Trades[0] != "AAPL" {
+		t.Fatalf("unexpected subscription %+v", s)
+	}
+}
+
+func TestFeed_Backoff(t *testing.T) {
+	f := newFeed(nil, "", false)
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{10, time.Minute},
+	}
+
+	for _, tt := range tests {
+		f.attempts = tt.attempts
+		if backoff := f.backoff(); backoff != tt.expected {
+			t.Fatalf("expected %v after %d attempts, got %v", tt.expected, tt.attempts, backoff)
+		}
+	}
+}
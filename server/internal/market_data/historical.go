@@ -0,0 +1,165 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	"github.com/gin-gonic/gin"
+)
+
+// The most pages followed for a single request, so years of minute bars can't keep the server busy for minutes.
+// The token of the next page is returned once it's reached, so the client can continue from there.
+const maxPages = 20
+
+// pageFetcher gets a single page of historical data from the url
+type pageFetcher func(url string) (map[string]any, error)
+
+// historicalQuery puts together the query of a historical request from the parsed symbols, start and parameters
+func historicalQuery(c *gin.Context) url.Values {
+	raw := "symbols=" + c.GetString("symbols") + c.GetString("start") + c.GetString("params")
+
+	query, _ := url.ParseQuery(raw)
+	return query
+}
+
+// itemsOf returns the bars, quotes or trades of every symbol in the page
+func itemsOf(page map[string]any, key string) map[string][]any {
+	items := make(map[string][]any)
+
+	bySymbol, _ := page[key].(map[string]any)
+	for symbol, list := range bySymbol {
+		values, _ := list.([]any)
+		items[symbol] = values
+	}
+
+	return items
+}
+
+// followPages gets the pages one after another up to maxPages and hands the items of each one to each. The token
+// of the next page is returned when there are more pages than that.
+func followPages(path, key string, query url.Values, fetch pageFetcher, each func(map[string][]any)) (string, error) {
+	for range maxPages {
+		page, err := fetch(path + "?" + query.Encode())
+		if err != nil {
+			return "", err
+		}
+
+		each(itemsOf(page, key))
+
+		token, _ := page["next_page_token"].(string)
+		if token == "" {
+			return "", nil
+		}
+
+		query.Set("page_token", token)
+	}
+
+	return query.Get("page_token"), nil
+}
+
+// writeLines writes every item on its own line with the symbol it's of, the same way the stream sends them
+func writeLines(encoder *json.Encoder, items map[string][]any) error {
+	symbols := make([]string, 0, len(items))
+	for symbol := range items {
+		symbols = append(symbols, symbol)
+	}
+	slices.Sort(symbols)
+
+	for _, symbol := range symbols {
+		for _, item := range items[symbol] {
+			fields, _ := item.(map[string]any)
+
+			line := make(map[string]any, len(fields)+1)
+			for field, value := range fields {
+				line[field] = value
+			}
+			line["S"] = symbol
+
+			if err := encoder.Encode(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sendHistorical returns the first page of the historical data the same way alpaca sends it. With all=true the
+// pages are followed on the server and merged into one response and with format=ndjson every bar, quote or trade
+// is streamed on its own line as the pages arrive. Either way the token of the next page is sent once maxPages
+// is reached.
+func sendHistorical(c *gin.Context, path, key string, query url.Values, fetch pageFetcher, msg string) {
+	stream := strings.EqualFold(c.Query("format"), "ndjson")
+	if !stream && c.Query("all") != "true" {
+		body, err := fetch(path + "?" + query.Encode())
+		if err != nil {
+			RequestExit(c, body, err, msg)
+			return
+		}
+
+		c.JSON(http.StatusOK, body)
+		return
+	}
+
+	merged := make(map[string][]any)
+	var encoder *json.Encoder
+	var writeErr error
+
+	next, err := followPages(path, key, query, fetch, func(items map[string][]any) {
+		if !stream {
+			for symbol, values := range items {
+				merged[symbol] = append(merged[symbol], values...)
+			}
+
+			return
+		}
+
+		// The status can only be sent once the first page is here, so a failing request still gets its error
+		if encoder == nil {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+			encoder = json.NewEncoder(c.Writer)
+		}
+
+		if writeErr == nil {
+			writeErr = writeLines(encoder, items)
+			c.Writer.Flush()
+		}
+	})
+
+	if !stream {
+		if err != nil {
+			RequestExit(c, nil, err, msg)
+			return
+		}
+
+		var token *string
+		if next != "" {
+			token = &next
+		}
+
+		c.JSON(http.StatusOK, gin.H{key: merged, "next_page_token": token})
+		return
+	}
+
+	if err != nil && encoder == nil {
+		RequestExit(c, nil, err, msg)
+		return
+	}
+
+	if writeErr != nil {
+		return
+	}
+
+	if err != nil {
+		encoder.Encode(gin.H{"error": msg})
+	} else if next != "" {
+		encoder.Encode(gin.H{"next_page_token": next})
+	}
+
+	c.Writer.Flush()
+}
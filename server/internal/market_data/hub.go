@@ -0,0 +1,638 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var upgrader websocket.Upgrader
+
+// The channels of the real time stream a client can subscribe to
+const (
+	Trades   = "trades"
+	Quotes   = "quotes"
+	Bars     = "bars"
+	Statuses = "statuses"
+)
+
+// The channels the messages of the upstream stream are sent on, by their type
+var messageChannels = map[string]string{
+	"t": Trades,
+	"q": Quotes,
+	"b": Bars,
+	"s": Statuses,
+}
+
+const (
+	// The number of updates waiting to be sent to a client before it's considered too slow and evicted.
+	// The internal subscribers are never evicted, the updates they don't keep up with are dropped instead.
+	sendQueueSize = 256
+	// The number of symbols a single client can be subscribed to on each channel
+	maxSymbols = 50
+)
+
+// Subscription is the message clients send to subscribe to the channels of some symbols or to unsubscribe from
+// them, for example {"action":"subscribe","trades":["AAPL","BTC/USD"],"quotes":["AAPL"]}. It's the same message
+// the hub sends upstream.
+type Subscription struct {
+	Action   string   `json:"action"`
+	Trades   []string `json:"trades,omitempty"`
+	Quotes   []string `json:"quotes,omitempty"`
+	Bars     []string `json:"bars,omitempty"`
+	Statuses []string `json:"statuses,omitempty"`
+
+	// The time to seek to and the speed of the controls of replays, which are never sent upstream
+	Time  string  `json:"time,omitempty"`
+	Speed float64 `json:"speed,omitempty"`
+}
+
+func (s Subscription) channels() map[string][]string {
+	return map[string][]string{
+		Trades:   s.Trades,
+		Quotes:   s.Quotes,
+		Bars:     s.Bars,
+		Statuses: s.Statuses,
+	}
+}
+
+func (s *Subscription) add(channel, symbol string) {
+	switch channel {
+	case Trades:
+		s.Trades = append(s.Trades, symbol)
+	case Quotes:
+		s.Quotes = append(s.Quotes, symbol)
+	case Bars:
+		s.Bars = append(s.Bars, symbol)
+	case Statuses:
+		s.Statuses = append(s.Statuses, symbol)
+	}
+}
+
+func (s Subscription) empty() bool {
+	return len(s.Trades)+len(s.Quotes)+len(s.Bars)+len(s.Statuses) == 0
+}
+
+// Symbols are case insensitive and crypto pairs are told apart from stocks by their slash (BTC/USD)
+func (s Subscription) normalized() Subscription {
+	normalized := Subscription{Action: s.Action, Time: s.Time, Speed: s.Speed}
+	for channel, symbols := range s.channels() {
+		for _, symbol := range symbols {
+			normalized.add(channel, strings.ToUpper(strings.TrimSpace(symbol)))
+		}
+	}
+
+	return normalized
+}
+
+func isCrypto(symbol string) bool {
+	return strings.Contains(symbol, "/")
+}
+
+type User struct {
+	ws            *websocket.Conn
+	send          chan map[string]any
+	subscriptions map[string]map[string]struct{} // channel -> symbols, only used by the hub
+	initial       Subscription
+	evicted       bool
+	extended      bool // streams stocks during the pre-market and after hours too
+	internal      bool
+	behind        bool // an internal subscriber that is dropping updates
+}
+
+func newUser(ws *websocket.Conn) *User {
+	return &User{
+		ws:            ws,
+		send:          make(chan map[string]any, sendQueueSize),
+		subscriptions: make(map[string]map[string]struct{}),
+	}
+}
+
+// NewSubscriber creates a user that isn't backed by a websocket, so the rest of the server can
+// consume the upstream stream through the hub. It's subscribed to the given channels of the symbol
+// when it's registered. The updates are sent on the returned channel, which is closed when the user
+// is unregistered. It's never evicted, the updates it doesn't keep up with are dropped instead.
+func NewSubscriber(symbol string, channels ...string) (*User, <-chan map[string]any) {
+	user := newUser(nil)
+	user.internal = true
+	user.initial.Action = "subscribe"
+	for _, channel := range channels {
+		user.initial.add(channel, symbol)
+	}
+
+	return user, user.send
+}
+
+// Read forwards the subscriptions of the client to the hub until the connection is closed
+func (u *User) Read(hub *Hub) {
+	for {
+		_, message, err := u.ws.ReadMessage()
+		if err != nil {
+			hub.unregister(u)
+			return
+		}
+
+		subscription := Subscription{}
+		if err := json.Unmarshal(message, &subscription); err != nil {
+			subscription = Subscription{}
+		}
+
+		select {
+		case hub.requests <- request{user: u, subscription: subscription}:
+		case <-hub.done:
+			return
+		}
+	}
+}
+
+// Write sends the updates to the client. It's the only one writing to the websocket.
+func (u *User) Write(hub *Hub) {
+	defer u.ws.Close()
+
+	for data := range u.send {
+		if err := u.ws.WriteJSON(data); err != nil {
+			log.Println(err)
+			hub.unregister(u)
+			return
+		}
+	}
+
+	if u.evicted {
+		u.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Error the connection couldn't keep up with the updates"))
+		return
+	}
+
+	u.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// The subscriptions of the user in the form of alpaca's acknowledgement, so the client knows what it listens to
+func (u *User) acknowledgement() map[string]any {
+	ack := map[string]any{"T": "subscription"}
+	for channel, symbols := range u.subscriptions {
+		list := make([]string, 0, len(symbols))
+		for symbol := range symbols {
+			list = append(list, symbol)
+		}
+		sort.Strings(list)
+
+		ack[channel] = list
+	}
+
+	return ack
+}
+
+func (u *User) listensTo(crypto bool) bool {
+	for _, symbols := range u.subscriptions {
+		for symbol := range symbols {
+			if isCrypto(symbol) == crypto {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Message is either an update from the upstream stream or a change in the state of one of the streams
+type Message struct {
+	Crypto bool
+	Status string
+	Msg    string
+	Data   map[string]any
+}
+
+type request struct {
+	user         *User
+	subscription Subscription
+}
+
+// Hub shares the upstream streams of stocks and crypto pairs between all of the clients. Every channel of a
+// symbol is subscribed to upstream once, when its first client subscribes, and unsubscribed from when the last
+// one leaves. The state of the hub is only touched by Run, so the clients never wait on each other.
+type Hub struct {
+	Register    chan *User
+	Unregister  chan *User
+	Broadcast   chan *Message
+	requests    chan request
+	users       map[*User]struct{}
+	subscribers map[string]map[string]map[*User]struct{} // channel -> symbol -> users, the number of users is the reference count
+	stocks      *feed
+	crypto      *feed
+	notices     chan notice
+
+	// The stocks only stream during the sessions of the market
+	schedule      sessions
+	session       clock.Session
+	sessionChange <-chan time.Time
+	snapshots     func(symbols []string) (map[string]any, error)
+
+	// Every trade, quote and bar is also sent to the recorder when there is one
+	recorder       chan<- map[string]any
+	recorderBehind bool
+
+	// A hub that replays a past day instead of listening upstream. The one of a single connection stops with it,
+	// which closes done.
+	replay *replay
+	done   chan struct{}
+}
+
+func NewHub() *Hub {
+	h := &Hub{
+		Register:    make(chan *User),
+		Unregister:  make(chan *User),
+		Broadcast:   make(chan *Message, sendQueueSize),
+		requests:    make(chan request),
+		users:       make(map[*User]struct{}),
+		subscribers: make(map[string]map[string]map[*User]struct{}),
+		notices:     make(chan notice),
+		schedule:    clock.NewSchedule("NYSE"),
+		snapshots:   fetchSnapshots,
+	}
+
+	h.stocks = newFeed(h, RealTimeData, false)
+	h.crypto = newFeed(h, RealTimeCrypto, true)
+
+	return h
+}
+
+// Record sends every trade, quote and bar that comes from upstream to records as well, once no matter how many
+// clients get it. The hub never waits on the recorder, so the updates it doesn't keep up with are dropped. It has
+// to be called before the hub runs.
+func (h *Hub) Record(records chan<- map[string]any) {
+	h.recorder = records
+}
+
+func (h *Hub) Run() {
+	if h.replay != nil {
+		go h.replay.run()
+	} else {
+		go h.stocks.run()
+		go h.crypto.run()
+	}
+
+	h.serve()
+}
+
+// unregister never waits on a hub that already stopped
+func (h *Hub) unregister(user *User) {
+	select {
+	case h.Unregister <- user:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) serve() {
+	h.updateSession()
+
+	for {
+		select {
+		case user := <-h.Register:
+			h.users[user] = struct{}{}
+			if !user.initial.empty() {
+				h.subscribe(user, user.initial)
+			}
+
+			// The user is told where the replay is right away
+			if h.replay != nil {
+				h.controlReplay(replayControl{})
+			}
+
+		case user := <-h.Unregister:
+			h.remove(user)
+
+		case req := <-h.requests:
+			h.handle(req.user, req.subscription)
+
+		case msg := <-h.Broadcast:
+			h.dispatch(msg)
+
+		case n := <-h.notices:
+			if _, ok := h.users[n.user]; ok {
+				h.deliver(n.user, n.data)
+			}
+
+		case <-h.sessionChange:
+			h.updateSession()
+
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *Hub) feedOf(symbol string) *feed {
+	if isCrypto(symbol) {
+		return h.crypto
+	}
+
+	return h.stocks
+}
+
+func (h *Hub) handle(user *User, subscription Subscription) {
+	if _, ok := h.users[user]; !ok {
+		return
+	}
+
+	subscription = subscription.normalized()
+
+	if h.replay != nil && slices.Contains(replayActions, subscription.Action) {
+		// The replay of the whole server is shared, so nobody can pause or seek it for everybody else
+		if h.done == nil {
+			h.deliver(user, gin.H{"error": "Error only the replays of a single connection can be controlled"})
+			return
+		}
+
+		control, err := replayControlOf(subscription)
+		if err != nil {
+			h.deliver(user, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The replay tells every user about its new state
+		h.controlReplay(control)
+		return
+	}
+
+	var err error
+	switch subscription.Action {
+	case "subscribe":
+		if err = h.validate(user, subscription); err == nil {
+			h.subscribe(user, subscription)
+		}
+	case "unsubscribe":
+		h.unsubscribe(user, subscription)
+	default:
+		err = errors.New("Error the message has to be either a subscribe or an unsubscribe action")
+	}
+
+	if err != nil {
+		h.deliver(user, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.deliver(user, user.acknowledgement())
+
+	// The stocks won't stream until the session starts, so in the meantime the user gets their last prices
+	if subscription.Action == "subscribe" && !user.streams(h.session) {
+		h.notifyClosed(user, stocks(subscription))
+	}
+}
+
+func (h *Hub) validate(user *User, subscription Subscription) error {
+	if subscription.empty() {
+		return errors.New("Error there are no symbols to subscribe to")
+	}
+
+	for channel, symbols := range subscription.channels() {
+		if len(user.subscriptions[channel])+len(symbols) > maxSymbols {
+			return errors.New("Error you can't subscribe to more than " + strconv.Itoa(maxSymbols) + " symbols on a channel")
+		}
+
+		for _, symbol := range symbols {
+			if symbol == "" || symbol == "*" {
+				return errors.New("Error incorrectly provided symbol")
+			}
+
+			if isCrypto(symbol) && channel == Statuses {
+				return errors.New("Error crypto pairs don't have trading statuses")
+			}
+		}
+	}
+
+	return nil
+}
+
+// upstream collects the channels that have to be subscribed to or unsubscribed from on each feed
+type upstream map[*feed]*Subscription
+
+func (u upstream) add(f *feed, action, channel, symbol string) {
+	if _, ok := u[f]; !ok {
+		u[f] = &Subscription{Action: action}
+	}
+
+	u[f].add(channel, symbol)
+}
+
+// flush never waits on the feeds, which might be waiting on the hub to take a status or an update
+func (u upstream) flush() {
+	for f, subscription := range u {
+		f.queue(*subscription)
+	}
+}
+
+func (h *Hub) subscribe(user *User, subscription Subscription) {
+	changes := upstream{}
+	for channel, symbols := range subscription.channels() {
+		for _, symbol := range symbols {
+			if _, ok := user.subscriptions[channel][symbol]; ok {
+				continue
+			}
+
+			if user.subscriptions[channel] == nil {
+				user.subscriptions[channel] = make(map[string]struct{})
+			}
+			user.subscriptions[channel][symbol] = struct{}{}
+
+			if h.subscribers[channel] == nil {
+				h.subscribers[channel] = make(map[string]map[*User]struct{})
+			}
+			if h.subscribers[channel][symbol] == nil {
+				h.subscribers[channel][symbol] = make(map[*User]struct{})
+				changes.add(h.feedOf(symbol), "subscribe", channel, symbol)
+			}
+			h.subscribers[channel][symbol][user] = struct{}{}
+		}
+	}
+
+	changes.flush()
+}
+
+func (h *Hub) unsubscribe(user *User, subscription Subscription) {
+	changes := upstream{}
+	for channel, symbols := range subscription.channels() {
+		for _, symbol := range symbols {
+			if _, ok := user.subscriptions[channel][symbol]; !ok {
+				continue
+			}
+
+			delete(user.subscriptions[channel], symbol)
+			if len(user.subscriptions[channel]) == 0 {
+				delete(user.subscriptions, channel)
+			}
+
+			delete(h.subscribers[channel][symbol], user)
+			if len(h.subscribers[channel][symbol]) == 0 {
+				delete(h.subscribers[channel], symbol)
+				changes.add(h.feedOf(symbol), "unsubscribe", channel, symbol)
+			}
+		}
+	}
+
+	changes.flush()
+}
+
+func (h *Hub) remove(user *User) {
+	if _, ok := h.users[user]; !ok {
+		return
+	}
+
+	all := Subscription{}
+	for channel, symbols := range user.subscriptions {
+		for symbol := range symbols {
+			all.add(channel, symbol)
+		}
+	}
+	h.unsubscribe(user, all)
+
+	delete(h.users, user)
+	close(user.send)
+
+	if h.done != nil && len(h.users) == 0 {
+		close(h.done)
+	}
+}
+
+// deliver never waits on the user. A client that doesn't keep up is evicted instead of slowing down everybody else.
+// The rest of the server relies on its subscriptions staying in place, so an internal subscriber only misses the update.
+func (h *Hub) deliver(user *User, data map[string]any) {
+	if h.replay != nil {
+		data = replayFrame(data)
+	}
+
+	select {
+	case user.send <- data:
+		user.behind = false
+	default:
+		if user.internal {
+			if !user.behind {
+				log.Println("An internal subscriber of the real time data stream couldn't keep up, so some of its updates were dropped")
+				user.behind = true
+			}
+
+			return
+		}
+
+		log.Println("Evicting a client of the real time data stream that couldn't keep up")
+		user.evicted = true
+		h.remove(user)
+	}
+}
+
+func (h *Hub) dispatch(msg *Message) {
+	if msg.Status != "" {
+		status := gin.H{"T": "status", "status": msg.Status}
+		if msg.Msg != "" {
+			status["msg"] = msg.Msg
+		}
+
+		for user := range h.users {
+			if user.listensTo(msg.Crypto) {
+				h.deliver(user, status)
+			}
+		}
+
+		return
+	}
+
+	kind, _ := msg.Data["T"].(string)
+	symbol, _ := msg.Data["S"].(string)
+	channel := messageChannels[kind]
+
+	if kind == "replay" {
+		for user := range h.users {
+			h.deliver(user, msg.Data)
+		}
+
+		return
+	}
+
+	gated := !isCrypto(symbol) && channel != Statuses
+
+	if h.recorder != nil && channel != "" && channel != Statuses {
+		h.record(msg.Data)
+	}
+
+	for user := range h.subscribers[channel][symbol] {
+		if gated && !user.streams(h.session) {
+			continue
+		}
+
+		h.deliver(user, msg.Data)
+	}
+}
+
+func (h *Hub) record(data map[string]any) {
+	select {
+	case h.recorder <- data:
+		h.recorderBehind = false
+	default:
+		if !h.recorderBehind {
+			log.Println("The recorder couldn't keep up with the real time data stream, so some of the updates weren't recorded")
+			h.recorderBehind = true
+		}
+	}
+}
+
+// GetStream upgrades the connection to a websocket one, through which the client subscribes to the live
+// updates of stocks and crypto pairs. Several symbols and channels can be listened to on the same connection.
+// Stocks stream during the core session, or during the extended hours as well with ?extended_hours=true.
+// With ?replay=2024-03-15 and optionally &speed=10 the connection replays that day instead, which it controls
+// with the pause, resume, seek and speed actions. Only a few replays can run at the same time.
+func GetStream(c *gin.Context, hub *Hub) {
+	config, replay, err := replayOf(c)
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	release := func() {}
+	if replay {
+		if release, err = acquireReplay(c.GetString("id")); err != nil {
+			ErrorExit(c, http.StatusTooManyRequests, err.Error(), nil)
+			return
+		}
+	}
+
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		release()
+		ErrorExit(c, http.StatusInternalServerError, "couldn't upgrade the connection to a websocket one", err)
+		return
+	}
+
+	// A replay of a single connection gets a hub of its own, which stops once the connection is closed
+	if replay {
+		if hub, err = newPrivateReplayHub(config); err != nil {
+			log.Println(err)
+			release()
+			ws.Close()
+			return
+		}
+
+		go func() {
+			<-hub.done
+			release()
+		}()
+
+		go hub.Run()
+	}
+
+	user := newUser(ws)
+	user.extended = c.Query("extended_hours") == "true"
+	hub.Register <- user
+
+	go user.Read(hub)
+	go user.Write(hub)
+}
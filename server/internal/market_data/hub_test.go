@@ -0,0 +1,393 @@
+package marketdata
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+)
+
+// fakeSchedule is in the given session until it's changed. The hub checks it every few milliseconds.
+type fakeSchedule struct {
+	mu      sync.Mutex
+	session clock.Session
+}
+
+func (f *fakeSchedule) Session(now time.Time) (clock.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.session, nil
+}
+
+func (f *fakeSchedule) Set(session clock.Session) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.session = session
+}
+
+func (f *fakeSchedule) NextOpen(now time.Time, extended bool) (time.Time, error) {
+	return now.Add(time.Hour), nil
+}
+
+func (f *fakeSchedule) NextChange(now time.Time) (time.Time, error) {
+	return now.Add(5 * time.Millisecond), nil
+}
+
+func newTestHub() *Hub {
+	return newSessionHub(clock.Core)
+}
+
+func newSessionHub(session clock.Session) *Hub {
+	hub := NewHub()
+	hub.schedule = &fakeSchedule{session: session}
+	hub.snapshots = func(symbols []string) (map[string]any, error) {
+		return map[string]any{symbols[0]: map[string]any{"latestTrade": map[string]any{"p": 99.0}}}, nil
+	}
+
+	// The feeds aren't running, so what the hub sends upstream stays in their queues
+	go hub.serve()
+
+	return hub
+}
+
+func subscribe(t *testing.T, hub *Hub, user *User, subscription Subscription) map[string]any {
+	t.Helper()
+
+	hub.requests <- request{user: user, subscription: subscription}
+	return <-user.send
+}
+
+func TestHub_SubscribesUpstreamOncePerSymbol(t *testing.T) {
+	hub := newTestHub()
+
+	first, second := newUser(nil), newUser(nil)
+	hub.Register <- first
+	hub.Register <- second
+
+	ack := subscribe(t, hub, first, Subscription{Action: "subscribe", Trades: []string{"aapl"}, Quotes: []string{"AAPL"}})
+	if ack["T"] != "subscription" {
+		t.Fatalf("expected a subscription acknowledgement, got %v", ack)
+	}
+
+	upstream := <-hub.stocks.writes
+	if upstream.Action != "subscribe" || len(upstream.Trades) != 1 || upstream.Trades[0] != "AAPL" || len(upstream.Quotes) != 1 {
+		t.Fatalf("unexpected upstream subscription %+v", upstream)
+	}
+
+	subscribe(t, hub, second, Subscription{Action: "subscribe", Trades: []string{"AAPL"}})
+	subscribe(t, hub, first, Subscription{Action: "unsubscribe", Trades: []string{"AAPL"}})
+	if len(hub.stocks.writes) != 0 {
+		t.Fatal("expected no upstream changes while AAPL trades still have a subscriber")
+	}
+
+	subscribe(t, hub, second, Subscription{Action: "unsubscribe", Trades: []string{"AAPL"}})
+	upstream = <-hub.stocks.writes
+	if upstream.Action != "unsubscribe" || len(upstream.Trades) != 1 || len(upstream.Quotes) != 0 {
+		t.Fatalf("expected to unsubscribe from AAPL trades only, got %+v", upstream)
+	}
+}
+
+func TestHub_SendsCryptoToTheCryptoFeed(t *testing.T) {
+	hub := newTestHub()
+
+	user := newUser(nil)
+	hub.Register <- user
+
+	subscribe(t, hub, user, Subscription{Action: "subscribe", Trades: []string{"AAPL", "BTC/USD"}})
+
+	if upstream := <-hub.crypto.writes; len(upstream.Trades) != 1 || upstream.Trades[0] != "BTC/USD" {
+		t.Fatalf("unexpected crypto subscription %+v", upstream)
+	}
+
+	if upstream := <-hub.stocks.writes; len(upstream.Trades) != 1 || upstream.Trades[0] != "AAPL" {
+		t.Fatalf("unexpected stock subscription %+v", upstream)
+	}
+}
+
+func TestHub_DispatchesToTheSubscribersOfTheChannel(t *testing.T) {
+	hub := newTestHub()
+
+	trades, quotes := newUser(nil), newUser(nil)
+	hub.Register <- trades
+	hub.Register <- quotes
+
+	subscribe(t, hub, trades, Subscription{Action: "subscribe", Trades: []string{"AAPL"}, Bars: []string{"MSFT"}})
+	subscribe(t, hub, quotes, Subscription{Action: "subscribe", Quotes: []string{"AAPL"}, Bars: []string{"MSFT"}})
+
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "AAPL", "p": 100.0}}
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "b", "S": "MSFT", "c": 200.0}}
+
+	if update := <-trades.send; update["T"] != "t" {
+		t.Fatalf("expected the trade, got %v", update)
+	}
+
+	if update := <-trades.send; update["T"] != "b" {
+		t.Fatalf("expected the bar, got %v", update)
+	}
+
+	// The trade was sent first, so the quotes subscriber would have received it before the bar
+	if update := <-quotes.send; update["T"] != "b" {
+		t.Fatalf("expected only the bar, got %v", update)
+	}
+}
+
+func TestHub_EvictsSlowConsumers(t *testing.T) {
+	hub := newTestHub()
+
+	slow := &User{send: make(chan map[string]any, 1), subscriptions: make(map[string]map[string]struct{})}
+	slow.initial = Subscription{Action: "subscribe", Trades: []string{"AAPL"}}
+	hub.Register <- slow
+	<-hub.stocks.writes
+
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "AAPL"}}
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "AAPL"}}
+
+	if upstream := <-hub.stocks.writes; upstream.Action != "unsubscribe" {
+		t.Fatalf("expected the evicted user's symbols to be unsubscribed from, got %+v", upstream)
+	}
+
+	<-slow.send
+	if _, ok := <-slow.send; ok {
+		t.Fatal("expected the send channel to be closed")
+	}
+
+	if !slow.evicted {
+		t.Fatal("expected the user to be marked as evicted")
+	}
+}
+
+func TestHub_DropsUpdatesOfSlowInternalSubscribers(t *testing.T) {
+	hub := newTestHub()
+
+	user, updates := NewSubscriber("AAPL", Trades)
+	hub.Register <- user
+	<-hub.stocks.writes
+
+	for i := range sendQueueSize + 10 {
+		hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "AAPL", "p": float64(i)}}
+	}
+
+	// Once the hub is done with the broadcasts the queue is full and nothing was unsubscribed from
+	for len(hub.Broadcast) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	hub.requests <- request{}
+	select {
+	case upstream := <-hub.stocks.writes:
+		t.Fatalf("expected the internal subscriber to stay subscribed, got %+v", upstream)
+	default:
+	}
+
+	for range sendQueueSize {
+		<-updates
+	}
+
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "AAPL", "p": 1000.0}}
+	if update, ok := <-updates; !ok || update["p"] != 1000.0 {
+		t.Fatalf("expected the subscriber to get the updates again once it caught up, got %v", update)
+	}
+
+	if user.evicted {
+		t.Fatal("expected the internal subscriber not to be evicted")
+	}
+}
+
+func TestHub_DoesntWaitOnAFullWriteQueue(t *testing.T) {
+	hub := newTestHub()
+
+	user := newUser(nil)
+	hub.Register <- user
+
+	// Nobody runs the feed, so the subscription changes fill its queue while a disconnect fills the broadcasts
+	changes := 2 * writeQueueSize
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := range changes {
+			action := "subscribe"
+			if i%2 == 1 {
+				action = "unsubscribe"
+			}
+
+			subscribe(t, hub, user, Subscription{Action: action, Trades: []string{"AAPL"}})
+		}
+
+		for range 2 * sendQueueSize {
+			hub.Broadcast <- &Message{Crypto: true, Status: StatusReconnecting}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the hub to keep serving while the write queue is full")
+	}
+
+	for i := range changes {
+		upstream := <-hub.stocks.writes
+		if (i%2 == 0) != (upstream.Action == "subscribe") || len(upstream.Trades) != 1 {
+			t.Fatalf("expected the changes in order, got %+v at %d", upstream, i)
+		}
+	}
+}
+
+func TestHub_RejectsInvalidSubscriptions(t *testing.T) {
+	hub := newTestHub()
+
+	user := newUser(nil)
+	hub.Register <- user
+
+	tests := []Subscription{
+		{Action: "listen", Trades: []string{"AAPL"}},
+		{Action: "subscribe"},
+		{Action: "subscribe", Trades: []string{"*"}},
+		{Action: "subscribe", Statuses: []string{"BTC/USD"}},
+	}
+
+	for _, tt := range tests {
+		if response := subscribe(t, hub, user, tt); response["error"] == nil {
+			t.Fatalf("expected %+v to be rejected, got %v", tt, response)
+		}
+	}
+}
+
+func TestHub_GatesStocksOutsideTheSession(t *testing.T) {
+	hub := newSessionHub(clock.Closed)
+	schedule := hub.schedule.(*fakeSchedule)
+
+	user := newUser(nil)
+	hub.Register <- user
+
+	if ack := subscribe(t, hub, user, Subscription{Action: "subscribe", Trades: []string{"AAPL", "BTC/USD"}}); ack["T"] != "subscription" {
+		t.Fatalf("expected the subscription to be accepted while the market is closed, got %v", ack)
+	}
+
+	closed := receive(t, user.send)
+	if closed["T"] != "closed" || closed["next_open"] == nil || closed["opens_in"] == nil || closed["snapshots"] == nil {
+		t.Fatalf("expected the last snapshot and the next open, got %v", closed)
+	}
+
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "AAPL", "p": 100.0}}
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "BTC/USD", "p": 60000.0}}
+	if msg := receive(t, user.send); msg["S"] != "BTC/USD" {
+		t.Fatalf("expected only crypto to stream while the market is closed, got %v", msg)
+	}
+
+	schedule.Set(clock.Core)
+	if msg := receive(t, user.send); msg["T"] != "status" || msg["status"] != StatusOpen {
+		t.Fatalf("expected to be told that the market opened, got %v", msg)
+	}
+
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "AAPL", "p": 100.0}}
+	if msg := receive(t, user.send); msg["S"] != "AAPL" {
+		t.Fatalf("expected the stock to stream once the market opened, got %v", msg)
+	}
+}
+
+func TestHub_ClosedNoticeDoesntOutliveTheHub(t *testing.T) {
+	hub := NewHub()
+	hub.schedule = &fakeSchedule{session: clock.Closed}
+	hub.done = make(chan struct{})
+
+	sent := make(chan struct{})
+	hub.snapshots = func(symbols []string) (map[string]any, error) {
+		defer close(sent)
+		return nil, nil
+	}
+
+	// The hub stopped, so nobody receives the notice anymore
+	close(hub.done)
+	before := runtime.NumGoroutine()
+	hub.notifyClosed(newUser(nil), []string{"AAPL"})
+	<-sent
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the notice to be dropped once the hub stopped")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHub_ExtendedHoursAreOptIn(t *testing.T) {
+	hub := newSessionHub(clock.PreMarket)
+
+	regular, extended := newUser(nil), newUser(nil)
+	extended.extended = true
+	hub.Register <- regular
+	hub.Register <- extended
+
+	subscribe(t, hub, regular, Subscription{Action: "subscribe", Trades: []string{"AAPL", "MSFT"}})
+	if closed := receive(t, regular.send); closed["T"] != "closed" {
+		t.Fatalf("expected the regular session to be closed, got %v", closed)
+	}
+
+	subscribe(t, hub, extended, Subscription{Action: "subscribe", Trades: []string{"AAPL"}})
+
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "AAPL", "p": 100.0}}
+	if msg := receive(t, extended.send); msg["S"] != "AAPL" {
+		t.Fatalf("expected the pre-market trade, got %v", msg)
+	}
+
+	hub.Unregister <- regular
+	if msg, ok := <-regular.send; ok {
+		t.Fatalf("expected no pre-market trades without extended hours, got %v", msg)
+	}
+}
+
+func TestNewSubscriber(t *testing.T) {
+	hub := newTestHub()
+
+	user, updates := NewSubscriber("AAPL", Trades, Bars)
+	hub.Register <- user
+
+	upstream := <-hub.stocks.writes
+	if len(upstream.Trades) != 1 || len(upstream.Bars) != 1 || len(upstream.Quotes) != 0 {
+		t.Fatalf("unexpected upstream subscription %+v", upstream)
+	}
+
+	hub.Unregister <- user
+	if _, ok := <-updates; ok {
+		t.Fatal("expected the updates channel to be closed")
+	}
+}
+
+func TestHub_RecordsEveryUpdateOnce(t *testing.T) {
+	hub := NewHub()
+	hub.schedule = &fakeSchedule{session: clock.Core}
+
+	records := make(chan map[string]any, 8)
+	hub.Record(records)
+	go hub.serve()
+
+	first, second := newUser(nil), newUser(nil)
+	hub.Register <- first
+	hub.Register <- second
+
+	subscribe(t, hub, first, Subscription{Action: "subscribe", Trades: []string{"AAPL"}})
+	subscribe(t, hub, second, Subscription{Action: "subscribe", Trades: []string{"AAPL"}})
+
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "t", "S": "AAPL", "p": 100.0}}
+	hub.Broadcast <- &Message{Data: map[string]any{"T": "s", "S": "AAPL", "sc": "H"}}
+	hub.Broadcast <- &Message{Status: "open"}
+
+	<-first.send
+	<-second.send
+
+	if record := <-records; record["T"] != "t" {
+		t.Fatalf("expected the trade to be recorded, got %v", record)
+	}
+
+	select {
+	case record := <-records:
+		t.Fatalf("expected only the trade to be recorded, got %v", record)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
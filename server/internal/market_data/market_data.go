@@ -1,9 +1,7 @@
 package marketdata
 
 import (
-	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
@@ -11,11 +9,8 @@ import (
 	. "github.com/Phantomvv1/KayTrade/internal/exit"
 	. "github.com/Phantomvv1/KayTrade/internal/requests"
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/websocket"
 )
 
-var upgrader websocket.Upgrader
-
 type TimeFrame string
 
 func (t TimeFrame) ValidTimeFrame() bool {
@@ -54,10 +49,9 @@ func (t TimeFrame) ValidTimeFrame() bool {
 			return false
 		}
 		return true
-	case 'D':
-		return true
-	case 'W':
-		return true
+	case 'D', 'W':
+		// Longer days and weeks have to be aggregated
+		return number == "1"
 	case 'M':
 		switch number {
 		case "1":
@@ -80,241 +74,6 @@ func (t TimeFrame) ValidTimeFrame() bool {
 	}
 }
 
-type User struct {
-	Symbol string
-	ws     *websocket.Conn
-	send   chan map[string]any
-}
-
-func (u *User) Read(hub *Hub) {
-	for {
-		_, message, err := u.ws.ReadMessage()
-		if err != nil {
-			log.Println(err)
-			hub.Unregister <- u
-			return
-		}
-
-		if string(message) == "exit" {
-			hub.Unregister <- u
-		}
-	}
-}
-
-func (u *User) Write(hub *Hub) {
-	for data := range u.send {
-		err := u.ws.WriteJSON(data)
-		if err != nil {
-			log.Println(err)
-			hub.Unregister <- u
-			return
-		}
-	}
-}
-
-type Message struct {
-	Receiver string
-	Message  string
-	Symbol   string
-	Data     map[string]any
-}
-
-type Hub struct {
-	Users       map[*User]struct{}
-	Broadcast   chan *Message
-	Register    chan *User
-	Unregister  chan *User
-	IsConnected bool
-	IsListening bool
-	ws          *websocket.Conn
-}
-
-func NewHub() *Hub {
-	return &Hub{
-		Users:       make(map[*User]struct{}),
-		Broadcast:   make(chan *Message),
-		Register:    make(chan *User),
-		Unregister:  make(chan *User),
-		IsConnected: false,
-		IsListening: false,
-	}
-}
-
-func (h *Hub) Run() {
-	for {
-		select {
-		case msg := <-h.Broadcast:
-			switch msg.Receiver {
-			case "all":
-				for user := range h.Users {
-					user.send <- gin.H{"error": msg.Message}
-				}
-			case "":
-				for user := range h.Users {
-					if user.Symbol == msg.Symbol {
-						user.send <- msg.Data
-					}
-				}
-			default:
-			}
-
-		case user := <-h.Register:
-			h.Users[user] = struct{}{}
-			if !h.IsConnected {
-				h.IsConnected = true
-				go h.Connect(user.Symbol)
-			}
-
-			alreadySubscribed := false
-			for existingUser := range h.Users {
-				if user.Symbol == existingUser.Symbol {
-					alreadySubscribed = true
-				}
-			}
-
-			if !alreadySubscribed {
-				go h.Subscribe(user.Symbol)
-			}
-
-		case user := <-h.Unregister:
-			if _, ok := h.Users[user]; ok {
-				go h.Unsubscribe(user)
-				delete(h.Users, user)
-				close(user.send)
-			}
-		}
-	}
-}
-
-func (h *Hub) Connect(symbol string) {
-	ws, _, err := websocket.DefaultDialer.Dial(RealTimeData, nil)
-	if err != nil {
-		log.Println(err)
-		h.Broadcast <- &Message{Receiver: "all", Message: "Error dialing the data stream"}
-		return
-	}
-	h.ws = ws
-
-	var body []map[string]string
-	if err = ws.ReadJSON(&body); err != nil {
-		log.Println(err)
-		h.Broadcast <- &Message{Receiver: "all", Message: "Error dialing the data stream"}
-		return
-	}
-
-	if body[0]["T"] != "success" && body[0]["msg"] != "connected" {
-		h.Broadcast <- &Message{Receiver: "all", Message: "Error couldn't connect to the real time data stream. Please check if the market is open. " +
-			"If it's not, please wait for it. Otherwise try again."}
-		return
-	}
-
-	authMsg := map[string]string{
-		"action": "auth",
-		"key":    os.Getenv("API_KEY"),
-		"secret": os.Getenv("SECRET_KEY"),
-	}
-
-	if err = ws.WriteJSON(authMsg); err != nil {
-		log.Println(err)
-		h.Broadcast <- &Message{Receiver: "all", Message: "Error writing in the data stream"}
-		return
-	}
-
-	var fbody []map[string]any
-	if err = ws.ReadJSON(&fbody); err != nil {
-		log.Println(err)
-		h.Broadcast <- &Message{Receiver: "all", Message: "Error reading in the data stream"}
-		return
-	}
-
-	if fbody[0]["T"].(string) != "success" && fbody[0]["msg"].(string) != "authenticated" {
-		h.Broadcast <- &Message{Receiver: "all", Message: "Error couldn't connect to the real time data stream. Please check if the market is open. " +
-			"If it's not, please wait for it to open. Otherwise try again."}
-		return
-	}
-
-	h.Subscribe(symbol)
-}
-
-func (h *Hub) Subscribe(symbol string) {
-	if symbol == "" {
-		return
-	}
-	s := []string{symbol}
-
-	body := map[string]any{
-		"action":   "subscribe",
-		"trades":   s,
-		"quotes":   s,
-		"bars":     s,
-		"statuses": []string{"*"},
-	}
-
-	if err := h.ws.WriteJSON(body); err != nil {
-		log.Println(err)
-		h.Broadcast <- &Message{Receiver: "all", Message: "Error couldn't subscribe to these symbols"}
-		return
-	}
-
-	if !h.IsListening {
-		var resp []map[string]any
-		if err := h.ws.ReadJSON(&resp); err != nil {
-			log.Println(err)
-			h.Broadcast <- &Message{Receiver: "all", Message: "Error couldn't subscribe to these symbols"}
-			return
-		}
-
-		if resp[0]["T"].(string) != "subscription" {
-			h.Broadcast <- &Message{Receiver: "all", Message: "Error couldn't subscribe to these symbols"}
-			return
-		}
-
-		h.IsListening = true
-		go h.Listen()
-	}
-}
-
-func (h *Hub) Listen() {
-	for {
-		var body []map[string]any
-		if err := h.ws.ReadJSON(&body); err != nil {
-			h.Broadcast <- &Message{Receiver: "all", Message: "Error couldn't read the body"}
-			return
-		}
-
-		switch body[0]["T"].(string) {
-		case "subscription":
-		case "error":
-			log.Println("There was an error doing the last action")
-			log.Println(body[0])
-		default:
-			h.Broadcast <- &Message{Receiver: "", Message: "", Symbol: body[0]["S"].(string), Data: body[0]}
-		}
-	}
-}
-
-func (h *Hub) Unsubscribe(user *User) {
-	for u := range h.Users {
-		if u != user && user.Symbol == u.Symbol {
-			return
-		}
-	}
-
-	msg := map[string]any{
-		"action":   "unsubscribe",
-		"trades":   []string{user.Symbol},
-		"quotes":   []string{user.Symbol},
-		"bars":     []string{user.Symbol},
-		"statuses": []string{user.Symbol},
-	}
-
-	err := h.ws.WriteJSON(msg)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-}
-
 func GetHistoricalAuctions(c *gin.Context) {
 	symbols := c.GetString("symbols")
 
@@ -356,17 +115,18 @@ func GetHistoricalAuctions(c *gin.Context) {
 }
 
 func GetHistoricalBars(c *gin.Context) {
-	symbols := c.GetString("symbols")
-	start := c.GetString("start")
-
 	timeframe := TimeFrame(c.Query("timeframe"))
+	// Alpaca's bars include the extended hours and follow the clock, so the bars of sessions are built here
+	if timeframe.Custom() || c.Query("session") != "" {
+		sendAggregatedBars(c, timeframe)
+		return
+	}
+
 	if timeframe == "" || !timeframe.ValidTimeFrame() {
 		ErrorExit(c, http.StatusBadRequest, "timeframe was incorrectly provided", nil)
 		return
 	}
 
-	headers := BasicAuth()
-
 	errs := map[int]string{
 		400: "One of the request parameters is invalid",
 		403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
@@ -374,20 +134,24 @@ func GetHistoricalBars(c *gin.Context) {
 		500: "Internal server error. We recommend retrying these later",
 	}
 
-	body, err := SendRequest[any](http.MethodGet, MarketData+"/stocks/bars?symbols="+symbols+start+"&timeframe="+string(timeframe), nil, errs, headers)
+	query := historicalQuery(c)
+	query.Set("timeframe", string(timeframe))
+
+	// Bars that end in the past are complete, so they're cached for good
+	end, err := time.Parse(time.RFC3339, query.Get("end"))
 	if err != nil {
-		RequestExit(c, body, err, "coludn't get the market data for these symbols")
-		return
+		end, _ = time.Parse(time.DateOnly, query.Get("end"))
 	}
+	ttl := BarsTTL(timeframe, end)
 
-	c.JSON(http.StatusOK, body)
+	sendHistorical(c, MarketData+"/stocks/bars", "bars", query, func(url string) (map[string]any, error) {
+		return cachedRequest("bars", url, ttl, errs)
+	}, "coludn't get the market data for these symbols")
 }
 
 func GetLatestBars(c *gin.Context) {
 	symbols := c.GetString("symbols")
 
-	headers := BasicAuth()
-
 	errs := map[int]string{
 		400: "One of the request parameters is invalid",
 		403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
@@ -395,7 +159,7 @@ func GetLatestBars(c *gin.Context) {
 		500: "Internal server error. We recommend retrying these later",
 	}
 
-	body, err := SendRequest[any](http.MethodGet, MarketData+"/stocks/bars/latest?symbols="+symbols, nil, errs, headers)
+	body, err := cachedRequest("latest_bars", MarketData+"/stocks/bars/latest?symbols="+symbols, latestTTL, errs)
 	if err != nil {
 		RequestExit(c, body, err, "coludn't get the market data for these symbols")
 		return
@@ -460,9 +224,6 @@ func GetExchangeCodes(c *gin.Context) {
 }
 
 func GetHisoticalQuotes(c *gin.Context) {
-	symbols := c.GetString("symbols")
-	start := c.GetString("start")
-
 	headers := BasicAuth()
 
 	errs := map[int]string{
@@ -472,20 +233,14 @@ func GetHisoticalQuotes(c *gin.Context) {
 		500: "Internal server error. We recommend retrying these later",
 	}
 
-	body, err := SendRequest[any](http.MethodGet, MarketData+"/stocks/quotes?symbols="+symbols+start, nil, errs, headers)
-	if err != nil {
-		RequestExit(c, body, err, "coludn't get the qoutes for these symbols")
-		return
-	}
-
-	c.JSON(http.StatusOK, body)
+	sendHistorical(c, MarketData+"/stocks/quotes", "quotes", historicalQuery(c), func(url string) (map[string]any, error) {
+		return SendRequest[map[string]any](http.MethodGet, url, nil, errs, headers)
+	}, "coludn't get the qoutes for these symbols")
 }
 
 func GetLatestQuotes(c *gin.Context) {
 	symbols := c.GetString("symbols")
 
-	headers := BasicAuth()
-
 	errs := map[int]string{
 		400: "One of the request parameters is invalid",
 		403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
@@ -493,7 +248,7 @@ func GetLatestQuotes(c *gin.Context) {
 		500: "Internal server error. We recommend retrying these later",
 	}
 
-	body, err := SendRequest[any](http.MethodGet, MarketData+"/stocks/quotes/latest?symbols="+symbols, nil, errs, headers)
+	body, err := cachedRequest("latest_quotes", MarketData+"/stocks/quotes/latest?symbols="+symbols, latestTTL, errs)
 	if err != nil {
 		RequestExit(c, body, err, "coludn't get the qoutes for these symbols")
 		return
@@ -505,8 +260,6 @@ func GetLatestQuotes(c *gin.Context) {
 func GetSnapshots(c *gin.Context) {
 	symbols := c.GetString("symbols")
 
-	headers := BasicAuth()
-
 	errs := map[int]string{
 		400: "One of the request parameters is invalid",
 		403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
@@ -514,7 +267,7 @@ func GetSnapshots(c *gin.Context) {
 		500: "Internal server error. We recommend retrying these later",
 	}
 
-	body, err := SendRequest[any](http.MethodGet, MarketData+"/stocks/snapshots?symbols="+symbols, nil, errs, headers)
+	body, err := cachedRequest("snapshots", MarketData+"/stocks/snapshots?symbols="+symbols, snapshotTTL, errs)
 	if err != nil {
 		RequestExit(c, body, err, "coludn't get the qoutes for these symbols")
 		return
@@ -524,9 +277,6 @@ func GetSnapshots(c *gin.Context) {
 }
 
 func GetHistoricalTrades(c *gin.Context) {
-	symbols := c.GetString("symbols")
-	start := c.GetString("start")
-
 	headers := BasicAuth()
 
 	errs := map[int]string{
@@ -536,20 +286,14 @@ func GetHistoricalTrades(c *gin.Context) {
 		500: "Internal server error. We recommend retrying these later",
 	}
 
-	body, err := SendRequest[any](http.MethodGet, MarketData+"/stocks/trades?symbols="+symbols+start, nil, errs, headers)
-	if err != nil {
-		RequestExit(c, body, err, "coludn't get the qoutes for these symbols")
-		return
-	}
-
-	c.JSON(http.StatusOK, body)
+	sendHistorical(c, MarketData+"/stocks/trades", "trades", historicalQuery(c), func(url string) (map[string]any, error) {
+		return SendRequest[map[string]any](http.MethodGet, url, nil, errs, headers)
+	}, "coludn't get the qoutes for these symbols")
 }
 
 func GetLatestTrades(c *gin.Context) {
 	symbols := c.GetString("symbols")
 
-	headers := BasicAuth()
-
 	errs := map[int]string{
 		400: "One of the request parameters is invalid",
 		403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
@@ -557,7 +301,7 @@ func GetLatestTrades(c *gin.Context) {
 		500: "Internal server error. We recommend retrying these later",
 	}
 
-	body, err := SendRequest[any](http.MethodGet, MarketData+"/stocks/trades/latest?symbols="+symbols, nil, errs, headers)
+	body, err := cachedRequest("latest_trades", MarketData+"/stocks/trades/latest?symbols="+symbols, latestTTL, errs)
 	if err != nil {
 		RequestExit(c, body, err, "coludn't get the qoutes for these symbols")
 		return
@@ -632,32 +376,3 @@ func GetTopMarketMovers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, body)
 }
-
-func GetRealTimeStocks(c *gin.Context, hub *Hub) {
-	symbol := c.Param("symbol")
-
-	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		ErrorExit(c, http.StatusInternalServerError, "couldn't upgrade the connection to a websocket one", err)
-		return
-	}
-
-	if symbol == "" {
-		ws.WriteJSON(gin.H{"error": "Error incorrectly provided symbol"})
-		ws.Close()
-		return
-	}
-
-	now := time.Now().UTC()
-	if (now.Hour() < 13 || now.Hour() >= 20) || (now.Hour() == 13 && now.Minute() < 30) {
-		ws.WriteJSON(gin.H{"error": "Error the market still hasn't opened. You can't listen to live market updates if it's not open"})
-		ws.Close()
-		return
-	}
-
-	user := &User{Symbol: symbol, ws: ws, send: make(chan map[string]any)}
-	hub.Register <- user
-
-	go user.Read(hub)
-	go user.Write(hub)
-}
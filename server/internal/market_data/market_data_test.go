@@ -1,10 +1,17 @@
 package marketdata
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Phantomvv1/KayTrade/internal/cache"
 	"github.com/gin-gonic/gin"
 )
 
@@ -122,3 +129,134 @@ func TestGetTopMarketMovers_InvalidTop(t *testing.T) {
 		t.Fatalf("expected 400, got %d", w.Code)
 	}
 }
+
+func TestCryptoSymbol(t *testing.T) {
+	tests := map[string]string{
+		"BTC/USD":  "BTC/USD",
+		"btcusd":   "BTC/USD",
+		"ETHUSDT":  "ETH/USDT",
+		"SOLUSDC":  "SOL/USDC",
+		"ETHBTC":   "ETH/BTC",
+		"USD":      "USD",
+		"eth/usdt": "ETH/USDT",
+	}
+
+	for symbol, expected := range tests {
+		if got := CryptoSymbol(symbol); got != expected {
+			t.Fatalf("%s: expected %s, got %s", symbol, expected, got)
+		}
+	}
+
+	if got := cryptoSymbols("BTCUSD,ETH/USD"); got != "BTC/USD,ETH/USD" {
+		t.Fatalf("unexpected symbols %s", got)
+	}
+}
+
+func TestBarsTTL(t *testing.T) {
+	past := time.Now().UTC().AddDate(0, 0, -7)
+
+	if ttl := BarsTTL("1D", past); ttl != cache.Complete {
+		t.Fatalf("expected complete bars to be cached as complete values, got %v", ttl)
+	}
+
+	if ttl := BarsTTL("1D", time.Now().UTC().Add(-time.Hour)); ttl == cache.Complete {
+		t.Fatal("expected today's daily bar to still be forming")
+	}
+
+	tests := []struct {
+		timeframe TimeFrame
+		expected  time.Duration
+	}{
+		{"1T", 5 * time.Second},
+		{"15T", 15 * time.Second},
+		{"1H", time.Minute},
+		{"1D", time.Minute},
+	}
+
+	for _, tt := range tests {
+		if ttl := BarsTTL(tt.timeframe, time.Time{}); ttl != tt.expected {
+			t.Fatalf("expected %v for the forming %s bar, got %v", tt.expected, tt.timeframe, ttl)
+		}
+	}
+}
+
+// pagedBars returns a fetcher with the given number of pages of a single bar, counting the requests it gets
+func pagedBars(pages int, calls *int) pageFetcher {
+	return func(url string) (map[string]any, error) {
+		*calls++
+
+		page := map[string]any{
+			"bars": map[string]any{"AAPL": []any{map[string]any{"c": float64(*calls)}}},
+		}
+		if *calls < pages {
+			page["next_page_token"] = "page" + strconv.Itoa(*calls+1)
+		}
+
+		return page, nil
+	}
+}
+
+func TestGetHistoricalBars_FollowsPages(t *testing.T) {
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest("GET", "/?all=true", nil)
+
+	calls := 0
+	sendHistorical(c, "/stocks/bars", "bars", url.Values{}, pagedBars(3, &calls), "")
+
+	var body struct {
+		Bars          map[string][]map[string]float64 `json:"bars"`
+		NextPageToken *string                         `json:"next_page_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body.Bars["AAPL"]) != 3 || body.NextPageToken != nil {
+		t.Fatalf("expected the 3 pages merged without a next page, got %+v", body)
+	}
+}
+
+func TestGetHistoricalBars_StopsAtMaxPages(t *testing.T) {
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest("GET", "/?format=ndjson", nil)
+
+	calls := 0
+	sendHistorical(c, "/stocks/bars", "bars", url.Values{}, pagedBars(maxPages+5, &calls), "")
+
+	if calls != maxPages {
+		t.Fatalf("expected %d requests, got %d", maxPages, calls)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("expected ndjson, got %s", ct)
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != maxPages+1 {
+		t.Fatalf("expected a line for every bar and the token, got %d", len(lines))
+	}
+
+	var first map[string]any
+	json.Unmarshal([]byte(lines[0]), &first)
+	if first["S"] != "AAPL" || first["c"] != float64(1) {
+		t.Fatalf("expected the first bar of AAPL, got %v", first)
+	}
+
+	expected := `{"next_page_token":"page` + strconv.Itoa(maxPages+1) + `"}`
+	if lines[len(lines)-1] != expected {
+		t.Fatalf("expected %s, got %s", expected, lines[len(lines)-1])
+	}
+}
+
+func TestGetHistoricalBars_StreamReportsFirstPageErrors(t *testing.T) {
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest("GET", "/?format=ndjson", nil)
+
+	sendHistorical(c, "/stocks/bars", "bars", url.Values{}, func(string) (map[string]any, error) {
+		return nil, errors.New("Too many requests")
+	}, "coludn't get the market data for these symbols")
+
+	if w.Code != http.StatusFailedDependency {
+		t.Fatalf("expected 424, got %d", w.Code)
+	}
+}
@@ -0,0 +1,664 @@
+package marketdata
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+)
+
+// The fastest a replay can go
+const maxReplaySpeed = 1000
+
+// The number of connections that can replay a day of their own at the same time, in total and for a single user.
+// Every one of them pulls the whole day from alpaca.
+const (
+	maxPrivateReplays        = 8
+	maxPrivateReplaysPerUser = 1
+)
+
+var errTooManyReplays = errors.New("there are too many replays running, try again later")
+
+// The private replays that are running, by the user that started them
+var privateReplays = struct {
+	sync.Mutex
+	total int
+	users map[string]int
+}{users: make(map[string]int)}
+
+// acquireReplay takes one of the slots for private replays, the release function gives it back
+func acquireReplay(user string) (func(), error) {
+	privateReplays.Lock()
+	defer privateReplays.Unlock()
+
+	if privateReplays.total >= maxPrivateReplays || privateReplays.users[user] >= maxPrivateReplaysPerUser {
+		return nil, errTooManyReplays
+	}
+
+	privateReplays.total++
+	privateReplays.users[user]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			privateReplays.Lock()
+			defer privateReplays.Unlock()
+
+			privateReplays.total--
+			if privateReplays.users[user]--; privateReplays.users[user] == 0 {
+				delete(privateReplays.users, user)
+			}
+		})
+	}, nil
+}
+
+// The states of a replay the users are told about in the frames of type replay
+const (
+	ReplayPlaying  = "playing"
+	ReplayPaused   = "paused"
+	ReplayFinished = "finished"
+)
+
+// The message types of the ticks of every channel that can be replayed
+var replayTypes = map[string]string{
+	Trades: "t",
+	Quotes: "q",
+	Bars:   "b",
+}
+
+// ReplayConfig is what a replay plays: the sessions of a day, at some multiple of the real speed. The ticks
+// come from File when it's set, then from the recorder and then from alpaca's historical data.
+type ReplayConfig struct {
+	Date     time.Time
+	Speed    float64
+	Extended bool
+	File     string
+}
+
+// ReplayRecorded gets the ticks the recorder persisted, when it runs
+var ReplayRecorded func(kind string, symbols []string, start, end time.Time) (map[string][]map[string]any, error)
+
+// ParseReplay reads the day and the speed of a replay, like 2024-03-15 and 10. The speed is 1 when it's empty.
+func ParseReplay(date, speed string) (ReplayConfig, error) {
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return ReplayConfig{}, errors.New("the day of the replay should look like 2024-03-15")
+	}
+
+	config := ReplayConfig{Date: day, Speed: 1}
+	if speed != "" {
+		config.Speed, err = strconv.ParseFloat(speed, 64)
+		if err != nil || config.Speed <= 0 || config.Speed > maxReplaySpeed {
+			return ReplayConfig{}, errors.New("the speed of the replay should be above 0 and at most " + strconv.Itoa(maxReplaySpeed))
+		}
+	}
+
+	return config, nil
+}
+
+// ReplayFromEnv reads the replay the whole server plays instead of the real time streams from REPLAY,
+// REPLAY_SPEED, REPLAY_EXTENDED_HOURS and REPLAY_FILE. It reports false when REPLAY isn't set.
+func ReplayFromEnv() (ReplayConfig, bool, error) {
+	if os.Getenv("REPLAY") == "" {
+		return ReplayConfig{}, false, nil
+	}
+
+	config, err := ParseReplay(os.Getenv("REPLAY"), os.Getenv("REPLAY_SPEED"))
+	if err != nil {
+		return ReplayConfig{}, false, err
+	}
+
+	config.Extended = os.Getenv("REPLAY_EXTENDED_HOURS") == "true"
+	config.File = os.Getenv("REPLAY_FILE")
+
+	return config, true, nil
+}
+
+// NewReplayHub creates a hub that plays the ticks of a past day instead of listening to the real time streams.
+// The replayed day is always open, so the stocks stream no matter what time it really is.
+func NewReplayHub(config ReplayConfig) (*Hub, error) {
+	h := NewHub()
+	h.schedule = replaySchedule{}
+
+	r, err := newReplay(h, config)
+	if err != nil {
+		return nil, err
+	}
+	h.replay = r
+
+	return h, nil
+}
+
+// newPrivateReplayHub creates a replay hub for a single connection, which stops once the connection is closed
+func newPrivateReplayHub(config ReplayConfig) (*Hub, error) {
+	h, err := NewReplayHub(config)
+	if err != nil {
+		return nil, err
+	}
+
+	h.done = make(chan struct{})
+
+	return h, nil
+}
+
+// replaySchedule keeps the market open for the hubs that replay
+type replaySchedule struct{}
+
+func (replaySchedule) Session(now time.Time) (clock.Session, error) {
+	return clock.Core, nil
+}
+
+func (replaySchedule) NextOpen(now time.Time, extended bool) (time.Time, error) {
+	return now, nil
+}
+
+func (replaySchedule) NextChange(now time.Time) (time.Time, error) {
+	return now.Add(24 * time.Hour), nil
+}
+
+type replayTick struct {
+	at      time.Time
+	channel string
+	symbol  string
+	data    map[string]any
+}
+
+// newReplayTick reads the tick of a message of the stream, or of a line of the historical data that only has the
+// symbol, in which case its type is told by its fields
+func newReplayTick(data map[string]any) (replayTick, bool) {
+	if _, ok := data["T"]; !ok {
+		switch {
+		case data["bp"] != nil:
+			data["T"] = "q"
+		case data["o"] != nil:
+			data["T"] = "b"
+		case data["p"] != nil:
+			data["T"] = "t"
+		}
+	}
+
+	kind, _ := data["T"].(string)
+	symbol, _ := data["S"].(string)
+	timestamp, _ := data["t"].(string)
+
+	at, err := time.Parse(time.RFC3339Nano, timestamp)
+	channel := messageChannels[kind]
+	if err != nil || symbol == "" || channel == "" || channel == Statuses {
+		return replayTick{}, false
+	}
+
+	return replayTick{at: at, channel: channel, symbol: symbol, data: data}, true
+}
+
+// readReplayFile reads the ticks of an NDJSON file of the messages of the stream, like the ones /data/recorded
+// returns, or of the lines /data/trades, /data/quotes and /data/bars send with format=ndjson
+func readReplayFile(path string) ([]replayTick, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var ticks []replayTick
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var data map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+			continue
+		}
+
+		if tick, ok := newReplayTick(data); ok {
+			ticks = append(ticks, tick)
+		}
+	}
+
+	return ticks, scanner.Err()
+}
+
+type replayControl struct {
+	action string
+	to     time.Time
+	speed  float64
+}
+
+type replayLoad struct {
+	channel string
+	symbol  string
+	ticks   []replayTick
+	err     error
+}
+
+// replay feeds the hub the ticks of a past day in place of the real time streams. It reads the subscriptions the
+// hub sends upstream and loads the ticks of every symbol as it's subscribed to. The time of the replay moves
+// speed times faster than the real one from where it was last paused, sought to or sped up.
+type replay struct {
+	hub        *Hub
+	config     ReplayConfig
+	start      time.Time
+	end        time.Time
+	file       []replayTick
+	ticks      []replayTick // sorted by time
+	next       int          // the first tick that wasn't sent yet
+	speed      float64
+	paused     bool
+	finished   bool
+	anchor     time.Time // the time of the replay at wallAnchor
+	wallAnchor time.Time
+	subscribed map[string]map[string]struct{} // channel -> symbols
+	controls   chan replayControl
+	loads      chan replayLoad
+	alarm      <-chan time.Time
+	now        func() time.Time
+	fetch      func(channel, symbol string, start, end time.Time) ([]replayTick, error)
+	marketDays func(market string, start, end time.Time) ([]clock.MarketDay, error)
+}
+
+func newReplay(hub *Hub, config ReplayConfig) (*replay, error) {
+	r := &replay{
+		hub:        hub,
+		config:     config,
+		speed:      config.Speed,
+		subscribed: make(map[string]map[string]struct{}),
+		controls:   make(chan replayControl),
+		loads:      make(chan replayLoad),
+		now:        time.Now,
+		marketDays: clock.GetMarketDays,
+	}
+	r.fetch = r.historical
+
+	if config.File != "" {
+		ticks, err := readReplayFile(config.File)
+		if err != nil {
+			return nil, err
+		}
+
+		r.file = ticks
+	}
+
+	return r, nil
+}
+
+// window sets the time the replay covers: the sessions of the day when the market was open and the whole day
+// otherwise, which is enough for crypto
+func (r *replay) window() {
+	r.start, r.end = r.config.Date, r.config.Date.Add(24*time.Hour)
+
+	days, err := r.marketDays("NYSE", r.config.Date, r.config.Date)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if len(days) > 0 {
+		r.start, r.end = days[0].Open(r.config.Extended), days[0].Close(r.config.Extended)
+	}
+}
+
+func (r *replay) run() {
+	r.window()
+	r.anchor = r.start
+	r.wallAnchor = r.now()
+
+	for {
+		select {
+		case subscription := <-r.hub.stocks.writes:
+			r.track(subscription)
+		case subscription := <-r.hub.crypto.writes:
+			r.track(subscription)
+		case load := <-r.loads:
+			r.merge(load)
+		case control := <-r.controls:
+			r.control(control)
+		case <-r.alarm:
+			r.play()
+		case <-r.hub.done:
+			return
+		}
+
+		r.schedule()
+	}
+}
+
+// position is where the replay is now
+func (r *replay) position() time.Time {
+	if r.paused || r.finished {
+		return r.anchor
+	}
+
+	elapsed := time.Duration(float64(r.now().Sub(r.wallAnchor)) * r.speed)
+	if position := r.anchor.Add(elapsed); position.Before(r.end) {
+		return position
+	}
+
+	return r.end
+}
+
+// track loads the ticks of the symbols that were subscribed to and forgets the ones of the symbols that weren't
+// subscribed to anymore
+func (r *replay) track(subscription Subscription) {
+	for channel, symbols := range subscription.channels() {
+		for _, symbol := range symbols {
+			if subscription.Action == "unsubscribe" {
+				delete(r.subscribed[channel], symbol)
+				r.drop(channel, symbol)
+				continue
+			}
+
+			if r.subscribed[channel] == nil {
+				r.subscribed[channel] = make(map[string]struct{})
+			}
+			r.subscribed[channel][symbol] = struct{}{}
+
+			if channel == Statuses {
+				continue
+			}
+
+			go func() {
+				ticks, err := r.fetch(channel, symbol, r.start, r.end)
+
+				select {
+				case r.loads <- replayLoad{channel: channel, symbol: symbol, ticks: ticks, err: err}:
+				case <-r.hub.done:
+				}
+			}()
+		}
+	}
+}
+
+// drop removes the ticks of the channel of the symbol
+func (r *replay) drop(channel, symbol string) {
+	r.ticks = slices.DeleteFunc(r.ticks, func(tick replayTick) bool {
+		return tick.channel == channel && tick.symbol == symbol
+	})
+
+	r.seek(r.position(), false)
+}
+
+// merge adds the loaded ticks to the ones being played. The ones from before the current time were missed, so
+// they aren't sent.
+func (r *replay) merge(load replayLoad) {
+	if _, ok := r.subscribed[load.channel][load.symbol]; !ok {
+		return
+	}
+
+	if load.err != nil {
+		log.Println(load.err)
+		r.state("Error couldn't load the " + load.channel + " of " + load.symbol + " to replay")
+		return
+	}
+
+	r.drop(load.channel, load.symbol)
+	r.ticks = append(r.ticks, load.ticks...)
+	slices.SortStableFunc(r.ticks, func(a, b replayTick) int {
+		return a.at.Compare(b.at)
+	})
+
+	r.seek(r.position(), false)
+}
+
+// seek sets the next tick to the first one after position, or at it when inclusive is set
+func (r *replay) seek(position time.Time, inclusive bool) {
+	r.next, _ = slices.BinarySearchFunc(r.ticks, position, func(tick replayTick, position time.Time) int {
+		if c := tick.at.Compare(position); c != 0 || inclusive {
+			return c
+		}
+
+		return -1
+	})
+}
+
+func (r *replay) control(control replayControl) {
+	position := r.position()
+
+	switch control.action {
+	case "pause":
+		r.paused = true
+	case "resume":
+		r.paused = false
+	case "seek":
+		position = control.to
+		if position.Before(r.start) {
+			position = r.start
+		}
+		if position.After(r.end) {
+			position = r.end
+		}
+
+		r.finished = false
+		r.seek(position, true)
+	case "speed":
+		r.speed = control.speed
+	}
+
+	r.anchor = position
+	r.wallAnchor = r.now()
+	r.state("")
+}
+
+// play sends the ticks up to the current time
+func (r *replay) play() {
+	position := r.position()
+
+	for r.next < len(r.ticks) && !r.ticks[r.next].at.After(position) {
+		tick := r.ticks[r.next]
+		r.next++
+
+		select {
+		case r.hub.Broadcast <- &Message{Crypto: isCrypto(tick.symbol), Data: tick.data}:
+		case <-r.hub.done:
+			return
+		}
+	}
+
+	if !position.Before(r.end) {
+		r.anchor = r.end
+		r.finished = true
+		r.state("")
+	}
+}
+
+// schedule wakes the replay up when the next tick is due, or when the day is over
+func (r *replay) schedule() {
+	if r.paused || r.finished {
+		r.alarm = nil
+		return
+	}
+
+	due := r.end
+	if r.next < len(r.ticks) {
+		due = r.ticks[r.next].at
+	}
+
+	r.alarm = time.After(time.Duration(float64(due.Sub(r.position())) / r.speed))
+}
+
+// state tells the users where the replay is
+func (r *replay) state(msg string) {
+	state := ReplayPlaying
+	if r.paused {
+		state = ReplayPaused
+	}
+	if r.finished {
+		state = ReplayFinished
+	}
+
+	data := map[string]any{
+		"T":     "replay",
+		"state": state,
+		"day":   r.config.Date.Format(time.DateOnly),
+		"time":  r.position().Format(time.RFC3339Nano),
+		"speed": r.speed,
+	}
+	if msg != "" {
+		data["msg"] = msg
+	}
+
+	select {
+	case r.hub.Broadcast <- &Message{Data: data}:
+	case <-r.hub.done:
+	}
+}
+
+// historical gets the ticks of the symbol from the file of the replay, the recorder or alpaca, in that order
+func (r *replay) historical(channel, symbol string, start, end time.Time) ([]replayTick, error) {
+	if r.file != nil {
+		var ticks []replayTick
+		for _, tick := range r.file {
+			if tick.channel == channel && tick.symbol == symbol && !tick.at.Before(start) && tick.at.Before(end) {
+				ticks = append(ticks, tick)
+			}
+		}
+
+		return ticks, nil
+	}
+
+	if ReplayRecorded != nil {
+		recorded, err := ReplayRecorded(channel, []string{symbol}, start, end)
+		if err != nil {
+			log.Println(err)
+		}
+
+		if len(recorded[symbol]) > 0 {
+			var ticks []replayTick
+			for _, data := range recorded[symbol] {
+				if tick, ok := newReplayTick(data); ok {
+					ticks = append(ticks, tick)
+				}
+			}
+
+			return ticks, nil
+		}
+	}
+
+	return alpacaTicks(channel, symbol, start, end)
+}
+
+// alpacaTicks gets the ticks of the symbol from alpaca's historical data, up to maxPages of them
+func alpacaTicks(channel, symbol string, start, end time.Time) ([]replayTick, error) {
+	errs := map[int]string{
+		400: "One of the request parameters is invalid",
+		403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
+		429: "Too many requests",
+		500: "Internal server error. We recommend retrying these later",
+	}
+
+	query := url.Values{}
+	query.Set("start", start.Format(time.RFC3339))
+	query.Set("end", end.Format(time.RFC3339))
+	query.Set("limit", "10000")
+
+	path := MarketData + "/stocks/" + channel
+	query.Set("symbols", symbol)
+	if isCrypto(symbol) {
+		path = CryptoData + "/" + channel
+		query.Set("symbols", CryptoSymbol(symbol))
+	}
+
+	if channel == Bars {
+		query.Set("timeframe", string(sourceTimeFrame))
+	}
+
+	var ticks []replayTick
+	next, err := followPages(path, channel, query, func(url string) (map[string]any, error) {
+		return SendRequest[map[string]any](http.MethodGet, url, nil, errs, BasicAuth())
+	}, func(items map[string][]any) {
+		for _, list := range items {
+			for _, item := range list {
+				data, _ := item.(map[string]any)
+				if data == nil {
+					continue
+				}
+
+				data["T"] = replayTypes[channel]
+				data["S"] = symbol
+
+				if tick, ok := newReplayTick(data); ok {
+					ticks = append(ticks, tick)
+				}
+			}
+		}
+	})
+
+	if next != "" {
+		log.Println("The replay of the " + channel + " of " + symbol + " stops after " + strconv.Itoa(len(ticks)) + " of them")
+	}
+
+	return ticks, err
+}
+
+// replayFrame marks a frame of a replay hub, so the clients can't mistake it for the real time data
+func replayFrame(data map[string]any) map[string]any {
+	frame := make(map[string]any, len(data)+1)
+	for key, value := range data {
+		frame[key] = value
+	}
+	frame["replay"] = true
+
+	return frame
+}
+
+// The actions that control a replay
+var replayActions = []string{"pause", "resume", "seek", "speed"}
+
+// replayControlOf reads a control of the replay from the message of a client, like {"action":"seek","time":"..."}
+// or {"action":"speed","speed":10}
+func replayControlOf(subscription Subscription) (replayControl, error) {
+	control := replayControl{action: subscription.Action}
+
+	switch subscription.Action {
+	case "seek":
+		to, err := time.Parse(time.RFC3339Nano, subscription.Time)
+		if err != nil {
+			return replayControl{}, errors.New("Error the time to seek to should be in RFC3339")
+		}
+
+		control.to = to
+	case "speed":
+		if subscription.Speed <= 0 || subscription.Speed > maxReplaySpeed {
+			return replayControl{}, errors.New("Error the speed of the replay should be above 0 and at most " + strconv.Itoa(maxReplaySpeed))
+		}
+
+		control.speed = subscription.Speed
+	}
+
+	return control, nil
+}
+
+// controlReplay hands the control to the replay without waiting on it, since the replay may be waiting on the hub
+func (h *Hub) controlReplay(control replayControl) {
+	go func() {
+		select {
+		case h.replay.controls <- control:
+		case <-h.done:
+		}
+	}()
+}
+
+// replayOf reads the replay a connection asked for with ?replay=2024-03-15 and optionally &speed=10. It reports
+// false when the connection streams from the shared hub.
+func replayOf(c *gin.Context) (ReplayConfig, bool, error) {
+	if c.Query("replay") == "" {
+		return ReplayConfig{}, false, nil
+	}
+
+	config, err := ParseReplay(c.Query("replay"), c.Query("speed"))
+	if err != nil {
+		return ReplayConfig{}, false, err
+	}
+	config.Extended = c.Query("extended_hours") == "true"
+
+	return config, true, nil
+}
@@ -0,0 +1,245 @@
+package marketdata
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+)
+
+var replayDay = time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+// newTestReplayHub replays AAPL trades a minute apart from a minute after the open, as fast as it can go
+func newTestReplayHub(t *testing.T, private bool) *Hub {
+	t.Helper()
+
+	config := ReplayConfig{Date: replayDay, Speed: maxReplaySpeed}
+
+	var hub *Hub
+	var err error
+	if private {
+		hub, err = newPrivateReplayHub(config)
+	} else {
+		hub, err = NewReplayHub(config)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open := replayDay.Add(13*time.Hour + 30*time.Minute)
+	hub.replay.marketDays = func(market string, start, end time.Time) ([]clock.MarketDay, error) {
+		return []clock.MarketDay{{Date: replayDay, CoreStart: open, CoreEnd: open.Add(5 * time.Minute)}}, nil
+	}
+	hub.replay.fetch = func(channel, symbol string, start, end time.Time) ([]replayTick, error) {
+		var ticks []replayTick
+		for i := range 3 {
+			tick, _ := newReplayTick(map[string]any{"S": symbol, "t": start.Add(time.Duration(i+1) * time.Minute).Format(time.RFC3339), "p": 100.0 + float64(i)})
+			ticks = append(ticks, tick)
+		}
+
+		return ticks, nil
+	}
+
+	go hub.Run()
+
+	return hub
+}
+
+// frameOf reads the frames of the user until one of the given type comes. Errors have no type.
+func frameOf(t *testing.T, user *User, kind string) map[string]any {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case frame := <-user.send:
+			if frame["replay"] != true {
+				t.Fatalf("expected every frame to be flagged as replay, got %v", frame)
+			}
+
+			if T, _ := frame["T"].(string); T == kind {
+				return frame
+			}
+		case <-timeout:
+			t.Fatalf("expected a frame of type %s", kind)
+		}
+	}
+}
+
+func TestReplay_PlaysTheTicksInOrder(t *testing.T) {
+	hub := newTestReplayHub(t, false)
+
+	user := newUser(nil)
+	hub.Register <- user
+	hub.requests <- request{user: user, subscription: Subscription{Action: "subscribe", Trades: []string{"AAPL"}}}
+
+	for i := range 3 {
+		trade := frameOf(t, user, "t")
+		if trade["S"] != "AAPL" || trade["p"] != 100.0+float64(i) {
+			t.Fatalf("unexpected trade %v", trade)
+		}
+	}
+
+	if state := frameOf(t, user, "replay"); state["state"] != ReplayFinished && state["state"] != ReplayPlaying {
+		t.Fatalf("unexpected state %v", state)
+	}
+}
+
+func TestReplay_Controls(t *testing.T) {
+	hub := newTestReplayHub(t, true)
+
+	user := newUser(nil)
+	hub.Register <- user
+	frameOf(t, user, "replay")
+
+	hub.requests <- request{user: user, subscription: Subscription{Action: "pause"}}
+	if state := frameOf(t, user, "replay"); state["state"] != ReplayPaused {
+		t.Fatalf("expected the replay to pause, got %v", state)
+	}
+
+	to := replayDay.Add(13*time.Hour + 30*time.Minute + 5*time.Second)
+	hub.requests <- request{user: user, subscription: Subscription{Action: "seek", Time: to.Format(time.RFC3339)}}
+	if state := frameOf(t, user, "replay"); state["state"] != ReplayPaused || state["time"] != to.Format(time.RFC3339Nano) {
+		t.Fatalf("expected the replay to seek while paused, got %v", state)
+	}
+
+	hub.requests <- request{user: user, subscription: Subscription{Action: "speed", Speed: 5000}}
+	if frame := frameOf(t, user, ""); frame["error"] == nil {
+		t.Fatalf("expected an error for a speed that's too fast, got %v", frame)
+	}
+}
+
+func TestReplay_SharedCantBeControlled(t *testing.T) {
+	hub := newTestReplayHub(t, false)
+
+	user := newUser(nil)
+	hub.Register <- user
+	frameOf(t, user, "replay")
+
+	hub.requests <- request{user: user, subscription: Subscription{Action: "pause"}}
+	if frame := frameOf(t, user, ""); frame["error"] == nil {
+		t.Fatalf("expected the shared replay to refuse the control, got %v", frame)
+	}
+}
+
+func TestReplay_PrivateHubStopsWithItsUser(t *testing.T) {
+	hub := newTestReplayHub(t, true)
+
+	user := newUser(nil)
+	hub.Register <- user
+	hub.Unregister <- user
+
+	select {
+	case <-hub.done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the hub to stop once its user left")
+	}
+}
+
+func TestParseReplay(t *testing.T) {
+	config, err := ParseReplay("2024-03-15", "10")
+	if err != nil || !config.Date.Equal(replayDay) || config.Speed != 10 {
+		t.Fatalf("unexpected config %+v %v", config, err)
+	}
+
+	if config, _ := ParseReplay("2024-03-15", ""); config.Speed != 1 {
+		t.Fatalf("expected the speed to be 1 by default, got %v", config.Speed)
+	}
+
+	for _, invalid := range [][2]string{{"15-03-2024", ""}, {"2024-03-15", "0"}, {"2024-03-15", "5000"}, {"2024-03-15", "fast"}} {
+		if _, err := ParseReplay(invalid[0], invalid[1]); err == nil {
+			t.Fatalf("expected an error for %v", invalid)
+		}
+	}
+}
+
+func TestNewReplayTick_TellsTheTypeByTheFields(t *testing.T) {
+	tests := map[string]map[string]any{
+		Quotes: {"S": "AAPL", "t": "2024-03-15T14:30:00Z", "bp": 1.0},
+		Bars:   {"S": "AAPL", "t": "2024-03-15T14:30:00Z", "o": 1.0},
+		Trades: {"S": "AAPL", "t": "2024-03-15T14:30:00Z", "p": 1.0},
+	}
+
+	for channel, data := range tests {
+		tick, ok := newReplayTick(data)
+		if !ok || tick.channel != channel {
+			t.Fatalf("expected a tick of %s, got %+v", channel, tick)
+		}
+	}
+
+	if _, ok := newReplayTick(map[string]any{"next_page_token": "abc"}); ok {
+		t.Fatal("expected the token line not to be a tick")
+	}
+}
+
+func TestGetStream_InvalidReplay(t *testing.T) {
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest(http.MethodGet, "/data/stream?replay=yesterday", nil)
+
+	GetStream(c, nil)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestAcquireReplay_LimitsConcurrentReplays(t *testing.T) {
+	release, err := acquireReplay("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := acquireReplay("user"); !errors.Is(err, errTooManyReplays) {
+		t.Fatalf("expected a user to replay one day at a time, got %v", err)
+	}
+
+	var others []func()
+	for i := range maxPrivateReplays - 1 {
+		other, err := acquireReplay("other" + strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		others = append(others, other)
+	}
+
+	if _, err := acquireReplay("last"); !errors.Is(err, errTooManyReplays) {
+		t.Fatalf("expected the replays to be limited to %d, got %v", maxPrivateReplays, err)
+	}
+
+	release()
+	release()
+	for _, other := range others {
+		other()
+	}
+
+	again, err := acquireReplay("user")
+	if err != nil {
+		t.Fatalf("expected the released slots to be free again, got %v", err)
+	}
+	again()
+
+	if privateReplays.total != 0 || len(privateReplays.users) != 0 {
+		t.Fatalf("expected no replays to be left, got %d", privateReplays.total)
+	}
+}
+
+func TestGetStream_ReplayLimit(t *testing.T) {
+	release, err := acquireReplay("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	c, w := createGinContext()
+	c.Request = httptest.NewRequest(http.MethodGet, "/data/stream?replay=2024-03-15", nil)
+
+	GetStream(c, nil)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+}
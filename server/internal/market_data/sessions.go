@@ -0,0 +1,153 @@
+package marketdata
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+)
+
+// StatusOpen tells the users that the session started, so the updates of their stocks are coming again
+const StatusOpen = "open"
+
+// How long to wait before asking for the calendar again when it couldn't be fetched
+const scheduleRetry = time.Minute
+
+// sessions tells when the stock market is open. clock.Schedule does it with alpaca's calendar.
+type sessions interface {
+	Session(now time.Time) (clock.Session, error)
+	NextOpen(now time.Time, extended bool) (time.Time, error)
+	NextChange(now time.Time) (time.Time, error)
+}
+
+// notice is sent to a single user once it's ready, if the user is still around
+type notice struct {
+	user *User
+	data map[string]any
+}
+
+// streams tells if the updates of stocks are sent to the user during the session. The subscribers
+// inside the server see every update.
+func (u *User) streams(session clock.Session) bool {
+	switch session {
+	case clock.Core:
+		return true
+	case clock.PreMarket, clock.AfterHours:
+		return u.extended || u.internal
+	}
+
+	return u.internal
+}
+
+// The stocks the user gets the prices of
+func (u *User) stocks() []string {
+	all := Subscription{}
+	for channel, symbols := range u.subscriptions {
+		for symbol := range symbols {
+			all.add(channel, symbol)
+		}
+	}
+
+	return stocks(all)
+}
+
+func stocks(subscription Subscription) []string {
+	seen := make(map[string]struct{})
+	var symbols []string
+	for channel, list := range subscription.channels() {
+		if channel == Statuses {
+			continue
+		}
+
+		for _, symbol := range list {
+			if _, ok := seen[symbol]; ok || isCrypto(symbol) {
+				continue
+			}
+
+			seen[symbol] = struct{}{}
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols
+}
+
+// updateSession checks which session is going on and tells the users whose stocks started or stopped
+// streaming. It runs again when the session changes next.
+func (h *Hub) updateSession() {
+	now := time.Now().UTC()
+
+	session, err := h.schedule.Session(now)
+	if err != nil {
+		// Without the calendar nobody is kept from the updates until it's back
+		log.Println(err)
+		session = clock.Core
+	}
+
+	next, err := h.schedule.NextChange(now)
+	if err != nil {
+		log.Println(err)
+		next = now.Add(scheduleRetry)
+	}
+	h.sessionChange = time.After(next.Sub(now))
+
+	previous := h.session
+	h.session = session
+	if previous == "" || previous == session {
+		return
+	}
+
+	for user := range h.users {
+		if user.internal || !user.listensTo(false) {
+			continue
+		}
+
+		was, is := user.streams(previous), user.streams(session)
+		switch {
+		case is && !was:
+			h.deliver(user, gin.H{"T": "status", "status": StatusOpen, "session": session})
+		case was && !is:
+			h.notifyClosed(user, user.stocks())
+		}
+	}
+}
+
+// notifyClosed sends the user the last snapshot of the stocks and when they start streaming again.
+// The snapshots are fetched in the background, so the hub doesn't wait on alpaca.
+func (h *Hub) notifyClosed(user *User, symbols []string) {
+	if len(symbols) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	closed := gin.H{"T": "closed", "session": h.session}
+	if open, err := h.schedule.NextOpen(now, user.extended); err == nil {
+		closed["next_open"] = open.Format(time.RFC3339)
+		closed["opens_in"] = int(open.Sub(now).Seconds())
+	} else {
+		log.Println(err)
+	}
+
+	go func() {
+		snapshots, err := h.snapshots(symbols)
+		if err != nil {
+			log.Println(err)
+		} else {
+			closed["snapshots"] = snapshots
+		}
+
+		// A replay hub of a single connection may have stopped in the meantime
+		select {
+		case h.notices <- notice{user: user, data: closed}:
+		case <-h.done:
+		}
+	}()
+}
+
+func fetchSnapshots(symbols []string) (map[string]any, error) {
+	return SendRequest[map[string]any](http.MethodGet, MarketData+"/stocks/snapshots?symbols="+strings.Join(symbols, ","), nil, nil, BasicAuth())
+}
@@ -4,14 +4,17 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	ratelimit "github.com/JGLTechnologies/gin-rate-limit"
 	. "github.com/Phantomvv1/KayTrade/internal/auth"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/time/rate"
@@ -42,6 +45,17 @@ func AuthMiddleware(c *gin.Context) {
 	c.Next()
 }
 
+// ReplayAuthMiddleware lets only the users replay a past day on the real time stream, as the replay pulls its
+// history from alpaca. The live stream stays open to everybody.
+func ReplayAuthMiddleware(c *gin.Context) {
+	if c.Query("replay") == "" {
+		c.Next()
+		return
+	}
+
+	AuthMiddleware(c)
+}
+
 func AdminOnlyMiddleware(c *gin.Context) {
 	accType, _ := c.Get("accountType")
 	accountType := accType.(byte)
@@ -108,6 +122,82 @@ func StartParserMiddleware(c *gin.Context) {
 	c.Next()
 }
 
+var (
+	feeds       = []string{"sip", "iex", "otc", "boats", "overnight", "delayed_sip"}
+	adjustments = []string{"raw", "split", "dividend", "spin-off", "all"}
+	sorts       = []string{"asc", "desc"}
+)
+
+// The most items alpaca returns in a single page
+const maxLimit = 10000
+
+// HistoricalParamsMiddleware checks the optional parameters of the historical endpoints and sets them, already
+// encoded, the same way StartParserMiddleware sets the start
+func HistoricalParamsMiddleware(c *gin.Context) {
+	params := url.Values{}
+
+	if end := c.Query("end"); end != "" {
+		if !validDate(end) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error end was incorrectly provided"})
+			return
+		}
+
+		params.Set("end", end)
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxLimit {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error limit has to be between 1 and 10000"})
+			return
+		}
+
+		params.Set("limit", limit)
+	}
+
+	options := []struct {
+		name    string
+		allowed []string
+	}{
+		{"feed", feeds},
+		{"adjustment", adjustments},
+		{"sort", sorts},
+	}
+
+	for _, option := range options {
+		value := c.Query(option.name)
+		if value == "" {
+			continue
+		}
+
+		if !slices.Contains(option.allowed, value) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error " + option.name + " was incorrectly provided"})
+			return
+		}
+
+		params.Set(option.name, value)
+	}
+
+	if token := c.Query("page_token"); token != "" {
+		params.Set("page_token", token)
+	}
+
+	encoded := ""
+	if len(params) > 0 {
+		encoded = "&" + params.Encode()
+	}
+
+	c.Set("params", encoded)
+
+	c.Next()
+}
+
+// validDate accepts the two formats alpaca accepts for the start and the end
+func validDate(date string) bool {
+	_, err := ParseTime(date)
+	return err == nil
+}
+
 func RateLimiterMiddleware(c *gin.Context) {
 	ip := c.ClientIP()
 
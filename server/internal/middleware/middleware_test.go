@@ -136,6 +136,31 @@ func TestStartParserMiddleware_DefaultStart(t *testing.T) {
 	}
 }
 
+func TestHistoricalParamsMiddleware_Valid(t *testing.T) {
+	c, _ := createTestContext("GET", "/?end=2024-02-01&limit=500&feed=iex&adjustment=all&sort=desc&page_token=abc", nil)
+
+	HistoricalParamsMiddleware(c)
+
+	expected := "&adjustment=all&end=2024-02-01&feed=iex&limit=500&page_token=abc&sort=desc"
+	if v := c.GetString("params"); v != expected {
+		t.Fatalf("expected %s, got %s", expected, v)
+	}
+}
+
+func TestHistoricalParamsMiddleware_Invalid(t *testing.T) {
+	tests := []string{"/?end=yesterday", "/?limit=0", "/?limit=10001", "/?feed=nasdaq", "/?sort=up"}
+
+	for _, tt := range tests {
+		c, w := createTestContext("GET", tt, nil)
+
+		HistoricalParamsMiddleware(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", tt, w.Code)
+		}
+	}
+}
+
 func resetRateLimiter() {
 	mu.Lock()
 	defer mu.Unlock()
@@ -0,0 +1,236 @@
+package options
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeCall = "call"
+	TypePut  = "put"
+)
+
+const dateLayout = "2006-01-02"
+
+// The OCC symbol of a contract: the root, the expiration (YYMMDD), C or P and the strike times 1000 in 8 digits
+var contractPattern = regexp.MustCompile(`^([A-Z]{1,6})(\d{6})([CP])(\d{8})$`)
+
+// The order types and the times in force Alpaca accepts for options
+var (
+	orderTypes   = []string{"market", "limit", "stop", "stop_limit"}
+	timesInForce = []string{"day", "gtc"}
+)
+
+// Contract is what can be read from the symbol of an options contract
+type Contract struct {
+	Symbol     string    `json:"symbol"`
+	Underlying string    `json:"underlying"`
+	Expiration time.Time `json:"expiration"`
+	Type       string    `json:"type"`
+	Strike     float64   `json:"strike"`
+}
+
+// IsContract tells apart the symbols of options contracts from the ones of stocks and crypto pairs
+func IsContract(symbol string) bool {
+	return contractPattern.MatchString(strings.ToUpper(symbol))
+}
+
+// ParseContract reads the underlying, the expiration, the type and the strike from the OCC symbol of a contract
+func ParseContract(symbol string) (Contract, error) {
+	symbol = strings.ToUpper(symbol)
+
+	match := contractPattern.FindStringSubmatch(symbol)
+	if match == nil {
+		return Contract{}, errors.New(symbol + " isn't a valid options contract symbol")
+	}
+
+	expiration, err := time.Parse("060102", match[2])
+	if err != nil {
+		return Contract{}, errors.New(symbol + " doesn't have a valid expiration date")
+	}
+
+	strike, err := strconv.Atoi(match[4])
+	if err != nil {
+		return Contract{}, err
+	}
+
+	contractType := TypeCall
+	if match[3] == "P" {
+		contractType = TypePut
+	}
+
+	return Contract{
+		Symbol:     symbol,
+		Underlying: match[1],
+		Expiration: expiration,
+		Type:       contractType,
+		Strike:     float64(strike) / 1000,
+	}, nil
+}
+
+// ValidateOrder checks a single leg order for an options contract. Contracts are traded in whole units,
+// without notional amounts, extended hours or advanced order classes.
+func ValidateOrder(order map[string]any, now time.Time) error {
+	symbol, _ := order["symbol"].(string)
+
+	contract, err := ParseContract(symbol)
+	if err != nil {
+		return err
+	}
+
+	if contract.Expiration.Before(now.UTC().Truncate(24 * time.Hour)) {
+		return fmt.Errorf("the contract %s expired on %s", contract.Symbol, contract.Expiration.Format(dateLayout))
+	}
+	order["symbol"] = contract.Symbol
+
+	if _, ok := order["notional"]; ok {
+		return errors.New("options can't be bought with a notional amount")
+	}
+
+	qty, err := strconv.ParseFloat(fmt.Sprint(order["qty"]), 64)
+	if err != nil || qty <= 0 || qty != math.Trunc(qty) {
+		return errors.New("the quantity of an options order should be a whole number of contracts")
+	}
+
+	if orderType, _ := order["type"].(string); !slices.Contains(orderTypes, orderType) {
+		return errors.New("the type of an options order should be one of " + strings.Join(orderTypes, ", "))
+	}
+
+	if timeInForce, _ := order["time_in_force"].(string); !slices.Contains(timesInForce, timeInForce) {
+		return errors.New("the time in force of an options order should be one of " + strings.Join(timesInForce, ", "))
+	}
+
+	if extended, _ := order["extended_hours"].(bool); extended {
+		return errors.New("options can't be traded in the extended hours")
+	}
+
+	if class, ok := order["order_class"].(string); ok && class != "" && class != "simple" {
+		return errors.New("only single leg options orders are supported")
+	}
+
+	if order["take_profit"] != nil || order["stop_loss"] != nil {
+		return errors.New("options orders can't have a take profit or a stop loss")
+	}
+
+	return nil
+}
+
+type Greeks struct {
+	Delta float64 `json:"delta"`
+	Gamma float64 `json:"gamma"`
+	Theta float64 `json:"theta"`
+	Vega  float64 `json:"vega"`
+	Rho   float64 `json:"rho"`
+}
+
+// Snapshot is the latest market data of a contract as the options market data API returns it
+type Snapshot struct {
+	LatestQuote *struct {
+		Bid float64 `json:"bp"`
+		Ask float64 `json:"ap"`
+	} `json:"latestQuote"`
+	LatestTrade *struct {
+		Price float64 `json:"p"`
+	} `json:"latestTrade"`
+	Greeks            *Greeks  `json:"greeks"`
+	ImpliedVolatility *float64 `json:"impliedVolatility"`
+}
+
+// Quote is one side (call or put) of a strike of the chain
+type Quote struct {
+	Symbol            string   `json:"symbol"`
+	Bid               float64  `json:"bid"`
+	Ask               float64  `json:"ask"`
+	Last              float64  `json:"last"`
+	ImpliedVolatility *float64 `json:"implied_volatility"`
+	Greeks            *Greeks  `json:"greeks"`
+}
+
+type Strike struct {
+	Strike float64 `json:"strike"`
+	Call   *Quote  `json:"call"`
+	Put    *Quote  `json:"put"`
+}
+
+type Chain struct {
+	Underlying  string   `json:"underlying"`
+	Expirations []string `json:"expirations"`
+	Expiration  string   `json:"expiration"`
+	Strikes     []Strike `json:"strikes"`
+}
+
+// Expirations returns the distinct expirations of the contracts, the nearest first
+func Expirations(symbols []string) []string {
+	var expirations []string
+	for _, symbol := range symbols {
+		contract, err := ParseContract(symbol)
+		if err != nil {
+			continue
+		}
+
+		date := contract.Expiration.Format(dateLayout)
+		if !slices.Contains(expirations, date) {
+			expirations = append(expirations, date)
+		}
+	}
+
+	slices.Sort(expirations)
+	return expirations
+}
+
+// BuildChain puts the snapshots of the contracts of a single expiration in a grid of strikes with the calls and the puts next to each other
+func BuildChain(expiration string, snapshots map[string]Snapshot) []Strike {
+	byStrike := make(map[float64]*Strike)
+
+	for symbol, snapshot := range snapshots {
+		contract, err := ParseContract(symbol)
+		if err != nil || contract.Expiration.Format(dateLayout) != expiration {
+			continue
+		}
+
+		quote := &Quote{
+			Symbol:            contract.Symbol,
+			ImpliedVolatility: snapshot.ImpliedVolatility,
+			Greeks:            snapshot.Greeks,
+		}
+
+		if snapshot.LatestQuote != nil {
+			quote.Bid = snapshot.LatestQuote.Bid
+			quote.Ask = snapshot.LatestQuote.Ask
+		}
+
+		if snapshot.LatestTrade != nil {
+			quote.Last = snapshot.LatestTrade.Price
+		}
+
+		strike, ok := byStrike[contract.Strike]
+		if !ok {
+			strike = &Strike{Strike: contract.Strike}
+			byStrike[contract.Strike] = strike
+		}
+
+		if contract.Type == TypeCall {
+			strike.Call = quote
+		} else {
+			strike.Put = quote
+		}
+	}
+
+	strikes := make([]Strike, 0, len(byStrike))
+	for _, strike := range byStrike {
+		strikes = append(strikes, *strike)
+	}
+
+	slices.SortFunc(strikes, func(a, b Strike) int {
+		return cmp.Compare(a.Strike, b.Strike)
+	})
+
+	return strikes
+}
@@ -0,0 +1,135 @@
+package options
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseContract(t *testing.T) {
+	contract, err := ParseContract("aapl250620c00192500")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contract.Symbol != "AAPL250620C00192500" || contract.Underlying != "AAPL" || contract.Type != TypeCall || contract.Strike != 192.5 {
+		t.Fatalf("unexpected contract %+v", contract)
+	}
+
+	if !contract.Expiration.Equal(time.Date(2025, time.June, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected expiration %v", contract.Expiration)
+	}
+
+	for _, symbol := range []string{"AAPL", "BTC/USD", "AAPL250620X00192500", "AAPL251320C00192500", "TOOLONGR250620C00192500"} {
+		if _, err := ParseContract(symbol); err == nil {
+			t.Fatalf("expected %s to be invalid", symbol)
+		}
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 15, 0, 0, 0, time.UTC)
+	valid := func() map[string]any {
+		return map[string]any{"symbol": "spy250620p00500000", "qty": "2", "side": "buy", "type": "limit", "time_in_force": "day", "limit_price": "1.25"}
+	}
+
+	order := valid()
+	if err := ValidateOrder(order, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if order["symbol"] != "SPY250620P00500000" {
+		t.Fatalf("expected the symbol to be upper case, got %v", order["symbol"])
+	}
+
+	tests := []struct {
+		name   string
+		change func(map[string]any)
+	}{
+		{"expired", func(o map[string]any) { o["symbol"] = "SPY250530P00500000" }},
+		{"fractional", func(o map[string]any) { o["qty"] = "1.5" }},
+		{"notional", func(o map[string]any) { delete(o, "qty"); o["notional"] = "100" }},
+		{"trailing stop", func(o map[string]any) { o["type"] = "trailing_stop" }},
+		{"ioc", func(o map[string]any) { o["time_in_force"] = "ioc" }},
+		{"extended hours", func(o map[string]any) { o["extended_hours"] = true }},
+		{"bracket", func(o map[string]any) { o["order_class"] = "bracket" }},
+		{"take profit", func(o map[string]any) { o["take_profit"] = map[string]any{"limit_price": "2"} }},
+	}
+
+	for _, tt := range tests {
+		order := valid()
+		tt.change(order)
+
+		if err := ValidateOrder(order, now); err == nil {
+			t.Fatalf("%s: expected the order to be rejected", tt.name)
+		}
+	}
+
+	// A contract can still be traded on the day it expires
+	order = valid()
+	order["symbol"] = "SPY250601P00500000"
+	order["qty"] = float64(1)
+	if err := ValidateOrder(order, now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpirations(t *testing.T) {
+	expirations := Expirations([]string{"AAPL250718C00200000", "AAPL250620P00200000", "AAPL250620C00210000", "AAPL"})
+	if len(expirations) != 2 || expirations[0] != "2025-06-20" || expirations[1] != "2025-07-18" {
+		t.Fatalf("unexpected expirations %v", expirations)
+	}
+}
+
+func TestContractSymbols_FollowsPages(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page_token") == "" {
+			w.Write([]byte(`{"option_contracts":[{"symbol":"SPY250620C00500000"}],"next_page_token":"next"}`))
+			return
+		}
+
+		w.Write([]byte(`{"option_contracts":[{"symbol":"SPY251219C00500000"}],"next_page_token":null}`))
+	}))
+	defer ts.Close()
+
+	symbols, err := contractSymbols(ts.URL, url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expirations := Expirations(symbols); len(expirations) != 2 || expirations[1] != "2025-12-19" {
+		t.Fatalf("expected the expirations of both pages, got %v", expirations)
+	}
+}
+
+func TestBuildChain(t *testing.T) {
+	iv := 0.3
+	snapshots := map[string]Snapshot{
+		"AAPL250620C00210000": {Greeks: &Greeks{Delta: 0.4}, ImpliedVolatility: &iv},
+		"AAPL250620P00210000": {},
+		"AAPL250620C00200000": {},
+		"AAPL250718C00200000": {},
+	}
+	snapshot := snapshots["AAPL250620C00200000"]
+	snapshot.LatestQuote = &struct {
+		Bid float64 `json:"bp"`
+		Ask float64 `json:"ap"`
+	}{Bid: 5.1, Ask: 5.3}
+	snapshots["AAPL250620C00200000"] = snapshot
+
+	strikes := BuildChain("2025-06-20", snapshots)
+	if len(strikes) != 2 || strikes[0].Strike != 200 || strikes[1].Strike != 210 {
+		t.Fatalf("expected 2 strikes of the expiration in order, got %+v", strikes)
+	}
+
+	if strikes[0].Call == nil || strikes[0].Call.Bid != 5.1 || strikes[0].Put != nil {
+		t.Fatalf("unexpected first strike %+v", strikes[0])
+	}
+
+	if strikes[1].Call.Greeks.Delta != 0.4 || *strikes[1].Call.ImpliedVolatility != 0.3 || strikes[1].Put == nil {
+		t.Fatalf("unexpected second strike %+v", strikes[1])
+	}
+}
@@ -0,0 +1,215 @@
+package options
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+)
+
+// The most contracts and snapshots Alpaca returns in a single page
+const (
+	contractsLimit = 10000
+	snapshotsLimit = 1000
+)
+
+// The most pages of snapshots fetched for a single expiration
+const maxSnapshotPages = 5
+
+var ErrNoContracts = errors.New("Error there are no options contracts for this symbol")
+
+// ContractInfo is a contract as the Broker API returns it
+type ContractInfo struct {
+	ID               string `json:"id"`
+	Symbol           string `json:"symbol"`
+	Name             string `json:"name"`
+	Status           string `json:"status"`
+	Tradable         bool   `json:"tradable"`
+	ExpirationDate   string `json:"expiration_date"`
+	RootSymbol       string `json:"root_symbol"`
+	UnderlyingSymbol string `json:"underlying_symbol"`
+	Type             string `json:"type"`
+	Style            string `json:"style"`
+	StrikePrice      string `json:"strike_price"`
+	Size             string `json:"size"`
+	OpenInterest     string `json:"open_interest"`
+	ClosePrice       string `json:"close_price"`
+}
+
+type contracts struct {
+	Contracts     []ContractInfo `json:"option_contracts"`
+	NextPageToken *string        `json:"next_page_token"`
+}
+
+type snapshots struct {
+	Snapshots     map[string]Snapshot `json:"snapshots"`
+	NextPageToken *string             `json:"next_page_token"`
+}
+
+var errs = map[int]string{
+	400: "One of the request parameters is invalid",
+	403: "Authentication headers are missing or invalid. Make sure you authenticate your request with a valid API key",
+	404: "Resource doesn't exist",
+	422: "Some parameters are invalid",
+	429: "Too many requests",
+	500: "Internal server error. We recommend retrying these later",
+}
+
+// GetContract returns the contract with the given symbol
+func GetContract(symbol string) (ContractInfo, error) {
+	return SendRequest[ContractInfo](http.MethodGet, BaseURL+Options+"/"+strings.ToUpper(symbol), nil, errs, BasicAuth())
+}
+
+// getExpirations returns the dates the active contracts of the underlying expire on, the nearest first
+func getExpirations(underlying string, now time.Time) ([]string, error) {
+	query := url.Values{}
+	query.Set("underlying_symbols", underlying)
+	query.Set("status", "active")
+	query.Set("expiration_date_gte", now.UTC().Format(dateLayout))
+	query.Set("limit", strconv.Itoa(contractsLimit))
+
+	symbols, err := contractSymbols(BaseURL+Options, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return Expirations(symbols), nil
+}
+
+// contractSymbols returns the symbols of every contract that matches the query, following every page, since the
+// underlyings with the most strikes have more contracts than fit in one
+func contractSymbols(endpoint string, query url.Values) ([]string, error) {
+	var symbols []string
+	for {
+		body, err := SendRequest[contracts](http.MethodGet, endpoint+"?"+query.Encode(), nil, errs, BasicAuth())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, contract := range body.Contracts {
+			symbols = append(symbols, contract.Symbol)
+		}
+
+		if body.NextPageToken == nil || *body.NextPageToken == "" {
+			return symbols, nil
+		}
+		query.Set("page_token", *body.NextPageToken)
+	}
+}
+
+// getSnapshots returns the snapshots of every contract of the underlying that expires on the given date
+func getSnapshots(underlying, expiration string) (map[string]Snapshot, error) {
+	result := make(map[string]Snapshot)
+
+	query := url.Values{}
+	query.Set("expiration_date", expiration)
+	query.Set("limit", strconv.Itoa(snapshotsLimit))
+
+	for range maxSnapshotPages {
+		body, err := SendRequest[snapshots](http.MethodGet, OptionsData+"/snapshots/"+underlying+"?"+query.Encode(), nil, errs, BasicAuth())
+		if err != nil {
+			return nil, err
+		}
+
+		for symbol, snapshot := range body.Snapshots {
+			result[symbol] = snapshot
+		}
+
+		if body.NextPageToken == nil || *body.NextPageToken == "" {
+			break
+		}
+		query.Set("page_token", *body.NextPageToken)
+	}
+
+	return result, nil
+}
+
+// GetChain returns the options chain of the underlying for one expiration, the nearest one if it isn't given
+func GetChain(c *gin.Context) {
+	underlying := strings.ToUpper(c.Param("symbol"))
+
+	expiration := c.Query("expiration")
+	if expiration != "" {
+		if _, err := time.Parse(dateLayout, expiration); err != nil {
+			ErrorExit(c, http.StatusBadRequest, "the expiration should be a date in the format YYYY-MM-DD", err)
+			return
+		}
+	}
+
+	expirations, err := getExpirations(underlying, time.Now())
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the options contracts of "+underlying)
+		return
+	}
+
+	if len(expirations) == 0 {
+		ErrorExit(c, http.StatusNotFound, "there are no options contracts for "+underlying, ErrNoContracts)
+		return
+	}
+
+	if expiration == "" {
+		expiration = expirations[0]
+	} else if !slices.Contains(expirations, expiration) {
+		ErrorExit(c, http.StatusNotFound, "there are no contracts of "+underlying+" that expire on "+expiration, nil)
+		return
+	}
+
+	data, err := getSnapshots(underlying, expiration)
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the market data of the options contracts")
+		return
+	}
+
+	c.JSON(http.StatusOK, Chain{
+		Underlying:  underlying,
+		Expirations: expirations,
+		Expiration:  expiration,
+		Strikes:     BuildChain(expiration, data),
+	})
+}
+
+func GetOptionContract(c *gin.Context) {
+	symbol := c.Param("symbol")
+	if !IsContract(symbol) {
+		ErrorExit(c, http.StatusBadRequest, symbol+" isn't a valid options contract symbol", nil)
+		return
+	}
+
+	contract, err := GetContract(symbol)
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the options contract")
+		return
+	}
+
+	c.JSON(http.StatusOK, contract)
+}
+
+func GetLatestOptionQuotes(c *gin.Context) {
+	symbols := strings.ToUpper(c.Query("symbols"))
+	if symbols == "" {
+		ErrorExit(c, http.StatusBadRequest, "the symbols of the contracts are required", nil)
+		return
+	}
+
+	for _, symbol := range strings.Split(symbols, ",") {
+		if !IsContract(symbol) {
+			ErrorExit(c, http.StatusBadRequest, symbol+" isn't a valid options contract symbol", nil)
+			return
+		}
+	}
+
+	body, err := SendRequest[any](http.MethodGet, OptionsData+"/quotes/latest?symbols="+symbols, nil, errs, BasicAuth())
+	if err != nil {
+		RequestExit(c, body, err, "couldn't get the quotes of the options contracts")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
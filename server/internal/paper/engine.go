@@ -0,0 +1,283 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
+	"github.com/jackc/pgx/v5"
+)
+
+// How long the engine waits before asking for the sessions again when the calendar couldn't be read
+const scheduleRetry = time.Minute
+
+// settlement is a fill of the order, or its expiry when expired is set
+type settlement struct {
+	order   Order
+	fill    Fill
+	expired bool
+}
+
+type sessions interface {
+	Session(now time.Time) (clock.Session, error)
+	NextChange(now time.Time) (time.Time, error)
+	NextClose(now time.Time, extended bool) (time.Time, error)
+}
+
+// Engine fills the open paper orders against the trades from the market data hub, subscribing only
+// to the symbols that have open orders. The fills are matched in memory and written to the database
+// one at a time, in the order they happened. Orders only fill during the core session, unless they
+// are extended hours ones, and day orders expire when their trading day closes.
+type Engine struct {
+	Add           chan *Order
+	Cancel        chan string
+	hub           *marketdata.Hub
+	updates       chan map[string]any
+	settlements   chan settlement
+	orders        map[string]map[string]*Order // symbol -> id -> order
+	feeds         map[string]*marketdata.User
+	record        func(order Order, fill Fill) error
+	expire        func(order Order) error
+	schedule      sessions
+	session       clock.Session
+	sessionChange <-chan time.Time
+}
+
+func NewEngine(hub *marketdata.Hub) *Engine {
+	return &Engine{
+		Add:         make(chan *Order),
+		Cancel:      make(chan string),
+		hub:         hub,
+		updates:     make(chan map[string]any),
+		settlements: make(chan settlement, 256),
+		orders:      make(map[string]map[string]*Order),
+		feeds:       make(map[string]*marketdata.User),
+		record:      record,
+		expire:      expire,
+		schedule:    clock.NewSchedule("NYSE"),
+		session:     clock.Closed,
+	}
+}
+
+func (e *Engine) Run() {
+	go e.load()
+	go e.settle()
+
+	e.updateSession()
+
+	for {
+		select {
+		case order := <-e.Add:
+			e.watch(order)
+		case id := <-e.Cancel:
+			e.forget(id)
+		case update := <-e.updates:
+			e.evaluate(update)
+		case <-e.sessionChange:
+			e.updateSession()
+		}
+	}
+}
+
+// updateSession follows the sessions of the market and expires the day orders whose trading day is over
+func (e *Engine) updateSession() {
+	now := time.Now().UTC()
+
+	session, err := e.schedule.Session(now)
+	if err != nil {
+		// Without the calendar the orders keep filling until it's back
+		log.Println(err)
+		session = clock.Core
+	}
+	e.session = session
+
+	next, err := e.schedule.NextChange(now)
+	if err != nil {
+		log.Println(err)
+		next = now.Add(scheduleRetry)
+	}
+	e.sessionChange = time.After(time.Until(next))
+
+	for _, orders := range e.orders {
+		for _, order := range orders {
+			// The calendar might not have been there when the order was watched
+			e.expiry(order)
+			if order.expires.IsZero() || now.Before(order.expires) {
+				continue
+			}
+
+			e.forget(order.ID)
+			e.settlements <- settlement{order: *order, expired: true}
+		}
+	}
+}
+
+// expiry sets when a day order expires, the close of the trading day it was placed for
+func (e *Engine) expiry(order *Order) {
+	if order.TimeInForce != "day" || !order.expires.IsZero() {
+		return
+	}
+
+	expires, err := e.schedule.NextClose(order.CreatedAt, order.ExtendedHours)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	order.expires = expires
+}
+
+// fills tells if the order can be filled during the current session
+func (e *Engine) fills(order *Order) bool {
+	switch e.session {
+	case clock.Core:
+		return true
+	case clock.PreMarket, clock.AfterHours:
+		return order.ExtendedHours
+	}
+
+	return false
+}
+
+// Picks up the orders that were still open when the server was stopped
+func (e *Engine) load() {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		log.Println(err)
+		return
+	}
+
+	rows, err := conn.Query(context.Background(), selectOrders+" where o.status = any($1)", []string{StatusNew, StatusPartiallyFilled})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	orders, err := collectOrders(rows)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, order := range orders {
+		e.Add <- order
+	}
+}
+
+func (e *Engine) watch(order *Order) {
+	e.expiry(order)
+
+	if e.orders[order.Symbol] == nil {
+		e.orders[order.Symbol] = make(map[string]*Order)
+	}
+	e.orders[order.Symbol][order.ID] = order
+
+	if _, ok := e.feeds[order.Symbol]; ok || e.hub == nil {
+		return
+	}
+
+	user, updates := marketdata.NewSubscriber(order.Symbol, marketdata.Trades)
+	e.feeds[order.Symbol] = user
+
+	// The hub may be busy, so we never wait on it from the engine loop
+	go func() { e.hub.Register <- user }()
+	go e.forward(updates)
+}
+
+func (e *Engine) forward(updates <-chan map[string]any) {
+	for update := range updates {
+		if msg, ok := update["error"]; ok {
+			log.Println(msg)
+			continue
+		}
+
+		e.updates <- update
+	}
+}
+
+func (e *Engine) forget(id string) {
+	for symbol, orders := range e.orders {
+		if _, ok := orders[id]; !ok {
+			continue
+		}
+
+		delete(orders, id)
+		if len(orders) > 0 {
+			return
+		}
+
+		delete(e.orders, symbol)
+
+		if user, ok := e.feeds[symbol]; ok {
+			delete(e.feeds, symbol)
+			go func() { e.hub.Unregister <- user }()
+		}
+
+		return
+	}
+}
+
+func (e *Engine) evaluate(update map[string]any) {
+	if update["T"] != "t" {
+		return
+	}
+
+	symbol, _ := update["S"].(string)
+	price, ok := update["p"].(float64)
+	if !ok {
+		return
+	}
+
+	size, _ := update["s"].(float64)
+	tick := Tick{Price: price, Size: size}
+
+	for _, order := range e.orders[symbol] {
+		if !e.fills(order) {
+			continue
+		}
+
+		fill, ok := order.simulation.Match(order, tick)
+		if !ok {
+			continue
+		}
+
+		order.apply(fill, time.Now())
+		if !order.open() {
+			e.forget(order.ID)
+		}
+
+		e.settlements <- settlement{order: *order, fill: fill}
+	}
+}
+
+func (e *Engine) settle() {
+	for s := range e.settlements {
+		if s.expired {
+			if err := e.expire(s.order); err != nil {
+				log.Println(err)
+			}
+
+			continue
+		}
+
+		err := e.record(s.order, s.fill)
+		if err == nil {
+			continue
+		}
+
+		log.Println(err)
+		if errors.Is(err, errInsufficientCash) || errors.Is(err, errInsufficientShares) || errors.Is(err, errNotOpen) {
+			go func() { e.Cancel <- s.order.ID }()
+		}
+	}
+}
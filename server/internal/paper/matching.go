@@ -0,0 +1,342 @@
+package paper
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/options"
+)
+
+// Order statuses, the same ones Alpaca uses
+const (
+	StatusNew             = "new"
+	StatusPartiallyFilled = "partially_filled"
+	StatusFilled          = "filled"
+	StatusCanceled        = "canceled"
+	StatusExpired         = "expired"
+)
+
+// The smallest quantity that is still considered a position
+const epsilon = 1e-9
+
+var (
+	errInsufficientCash   = errors.New("insufficient buying power")
+	errInsufficientShares = errors.New("insufficient qty available for order")
+)
+
+// Simulation is how realistic the fills of the paper account are. The slippage is in basis points and moves
+// the price of market orders against the user. With a fill ratio a single trade fills at most that share of its size,
+// so big orders get partial fills.
+type Simulation struct {
+	SlippageBps float64 `json:"slippage_bps"`
+	FillRatio   float64 `json:"fill_ratio"`
+}
+
+type Order struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	Symbol         string     `json:"symbol"`
+	Side           string     `json:"side"`
+	Type           string     `json:"type"`
+	TimeInForce    string     `json:"time_in_force"`
+	Qty            float64    `json:"qty"`
+	FilledQty      float64    `json:"filled_qty"`
+	FilledAvgPrice float64    `json:"filled_avg_price"`
+	LimitPrice     *float64   `json:"limit_price"`
+	StopPrice      *float64   `json:"stop_price"`
+	TrailPrice     *float64   `json:"trail_price"`
+	TrailPercent   *float64   `json:"trail_percent"`
+	HighWaterMark  *float64   `json:"hwm"`
+	Triggered      bool       `json:"triggered"`
+	Status         string     `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	FilledAt       *time.Time `json:"filled_at"`
+	CanceledAt     *time.Time `json:"canceled_at"`
+	ExpiredAt      *time.Time `json:"expired_at"`
+	ExtendedHours  bool       `json:"extended_hours"`
+	simulation     Simulation
+	expires        time.Time // when a day order expires, zero for the other ones
+}
+
+// Tick is a trade of the symbol the order is matched against
+type Tick struct {
+	Price float64
+	Size  float64
+}
+
+type Fill struct {
+	Qty   float64
+	Price float64
+}
+
+type Position struct {
+	Symbol        string
+	Qty           float64
+	AvgEntryPrice float64
+}
+
+// request is the body of a new order. It accepts the same fields as the order endpoint of Alpaca.
+type request struct {
+	Symbol        string      `json:"symbol"`
+	Side          string      `json:"side"`
+	Type          string      `json:"type"`
+	TimeInForce   string      `json:"time_in_force"`
+	Qty           json.Number `json:"qty"`
+	Notional      json.Number `json:"notional"`
+	LimitPrice    json.Number `json:"limit_price"`
+	StopPrice     json.Number `json:"stop_price"`
+	TrailPrice    json.Number `json:"trail_price"`
+	TrailPercent  json.Number `json:"trail_percent"`
+	ExtendedHours bool        `json:"extended_hours"`
+}
+
+func parseAmount(value json.Number, name string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	number, err := strconv.ParseFloat(string(value), 64)
+	if err != nil || number <= 0 {
+		return nil, errors.New("invalid " + name)
+	}
+
+	return &number, nil
+}
+
+// parse validates the request the way Alpaca does and returns the order with its notional, if it has one.
+// The quantity of a notional order is only known once the price is.
+func (r request) parse() (Order, float64, error) {
+	o := Order{
+		Symbol:        strings.ToUpper(r.Symbol),
+		Side:          r.Side,
+		Type:          r.Type,
+		TimeInForce:   r.TimeInForce,
+		ExtendedHours: r.ExtendedHours,
+		Status:        StatusNew,
+	}
+
+	if o.Symbol == "" {
+		return Order{}, 0, errors.New("the symbol is required")
+	}
+
+	// The fill engine only knows the prices of stocks
+	if options.IsContract(o.Symbol) {
+		return Order{}, 0, errors.New("options can't be traded on the paper account")
+	}
+
+	if o.Side != "buy" && o.Side != "sell" {
+		return Order{}, 0, errors.New("the side should be buy or sell")
+	}
+
+	if o.Type == "" {
+		o.Type = "market"
+	}
+
+	if o.TimeInForce == "" {
+		o.TimeInForce = "day"
+	}
+
+	switch o.Type {
+	case "market", "limit", "stop", "stop_limit", "trailing_stop":
+	default:
+		return Order{}, 0, errors.New("unknown order type")
+	}
+
+	// The paper account doesn't take part in the opening and closing auctions
+	switch o.TimeInForce {
+	case "day", "gtc", "ioc", "fok":
+	case "opg", "cls":
+		return Order{}, 0, errors.New("opening and closing auction orders aren't supported on the paper account")
+	default:
+		return Order{}, 0, errors.New("unknown time in force")
+	}
+
+	if o.ExtendedHours && (o.Type != "limit" || o.TimeInForce != "day" && o.TimeInForce != "gtc") {
+		return Order{}, 0, errors.New("extended hours orders have to be limit day or gtc orders")
+	}
+
+	qty, err := parseAmount(r.Qty, "qty")
+	if err != nil {
+		return Order{}, 0, err
+	}
+
+	notional, err := parseAmount(r.Notional, "notional")
+	if err != nil {
+		return Order{}, 0, err
+	}
+
+	if (qty == nil) == (notional == nil) {
+		return Order{}, 0, errors.New("exactly one of qty and notional is required")
+	}
+
+	fractional := qty != nil && *qty != math.Trunc(*qty)
+	if (notional != nil || fractional) && (o.Type != "market" || o.TimeInForce != "day") {
+		return Order{}, 0, errors.New("fractional and notional orders have to be market day orders")
+	}
+
+	if o.LimitPrice, err = parseAmount(r.LimitPrice, "limit price"); err != nil {
+		return Order{}, 0, err
+	}
+
+	if o.StopPrice, err = parseAmount(r.StopPrice, "stop price"); err != nil {
+		return Order{}, 0, err
+	}
+
+	if o.TrailPrice, err = parseAmount(r.TrailPrice, "trail price"); err != nil {
+		return Order{}, 0, err
+	}
+
+	if o.TrailPercent, err = parseAmount(r.TrailPercent, "trail percent"); err != nil {
+		return Order{}, 0, err
+	}
+
+	if (o.Type == "limit" || o.Type == "stop_limit") && o.LimitPrice == nil {
+		return Order{}, 0, errors.New("the limit price is required for " + o.Type + " orders")
+	}
+
+	if (o.Type == "stop" || o.Type == "stop_limit") && o.StopPrice == nil {
+		return Order{}, 0, errors.New("the stop price is required for " + o.Type + " orders")
+	}
+
+	if o.Type == "trailing_stop" && (o.TrailPrice == nil) == (o.TrailPercent == nil) {
+		return Order{}, 0, errors.New("exactly one of trail price and trail percent is required for trailing stop orders")
+	}
+
+	if qty != nil {
+		o.Qty = *qty
+		return o, 0, nil
+	}
+
+	return o, *notional, nil
+}
+
+func (o *Order) open() bool {
+	return o.Status == StatusNew || o.Status == StatusPartiallyFilled
+}
+
+func (o *Order) remaining() float64 {
+	return o.Qty - o.FilledQty
+}
+
+// trail moves the high water mark of a trailing stop. For buy orders it's the lowest price seen.
+func (o *Order) trail(price float64) {
+	if o.HighWaterMark == nil || o.Side == "sell" && price > *o.HighWaterMark || o.Side == "buy" && price < *o.HighWaterMark {
+		o.HighWaterMark = &price
+	}
+}
+
+// stop is the price at which the stop of the order is reached
+func (o *Order) stop() float64 {
+	if o.Type != "trailing_stop" {
+		return *o.StopPrice
+	}
+
+	hwm := *o.HighWaterMark
+	if o.TrailPrice != nil {
+		if o.Side == "sell" {
+			return hwm - *o.TrailPrice
+		}
+
+		return hwm + *o.TrailPrice
+	}
+
+	if o.Side == "sell" {
+		return hwm * (1 - *o.TrailPercent/100)
+	}
+
+	return hwm * (1 + *o.TrailPercent/100)
+}
+
+// Match fills the order against the trade. It also keeps track of the stops, so it should see every trade of the symbol.
+func (s Simulation) Match(o *Order, t Tick) (Fill, bool) {
+	if !o.open() || t.Price <= 0 {
+		return Fill{}, false
+	}
+
+	if o.Type == "trailing_stop" {
+		o.trail(t.Price)
+	}
+
+	if (o.Type == "stop" || o.Type == "stop_limit" || o.Type == "trailing_stop") && !o.Triggered {
+		stop := o.stop()
+		if o.Side == "buy" && t.Price < stop || o.Side == "sell" && t.Price > stop {
+			return Fill{}, false
+		}
+
+		o.Triggered = true
+	}
+
+	price := t.Price
+	if o.Type == "limit" || o.Type == "stop_limit" {
+		limit := *o.LimitPrice
+		if o.Side == "buy" && price > limit || o.Side == "sell" && price < limit {
+			return Fill{}, false
+		}
+	} else {
+		slippage := price * s.SlippageBps / 10000
+		if o.Side == "buy" {
+			price += slippage
+		} else {
+			price -= slippage
+		}
+	}
+
+	qty := o.remaining()
+	if s.FillRatio > 0 && t.Size > 0 {
+		qty = math.Min(qty, math.Max(math.Floor(t.Size*s.FillRatio), 1))
+	}
+
+	return Fill{Qty: qty, Price: round(price)}, true
+}
+
+func (o *Order) apply(f Fill, now time.Time) {
+	o.FilledAvgPrice = round((o.FilledAvgPrice*o.FilledQty + f.Price*f.Qty) / (o.FilledQty + f.Qty))
+	o.FilledQty += f.Qty
+	o.UpdatedAt = now
+
+	if o.remaining() > epsilon {
+		o.Status = StatusPartiallyFilled
+		return
+	}
+
+	o.Status = StatusFilled
+	o.FilledAt = &now
+}
+
+// settle moves the cash and the shares of the fill between the account and the position.
+// Paper accounts can't go short or on margin, so the fill is refused instead.
+func settle(cash float64, p Position, side string, f Fill) (float64, Position, error) {
+	value := f.Qty * f.Price
+
+	if side == "buy" {
+		if value > cash+epsilon {
+			return cash, p, errInsufficientCash
+		}
+
+		p.AvgEntryPrice = round((p.AvgEntryPrice*p.Qty + value) / (p.Qty + f.Qty))
+		p.Qty += f.Qty
+		return round(cash - value), p, nil
+	}
+
+	if f.Qty > p.Qty+epsilon {
+		return cash, p, errInsufficientShares
+	}
+
+	p.Qty -= f.Qty
+	if p.Qty < epsilon {
+		p.Qty = 0
+		p.AvgEntryPrice = 0
+	}
+
+	return round(cash + value), p, nil
+}
+
+// round keeps 4 decimal places, the precision of the prices of Alpaca
+func round(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
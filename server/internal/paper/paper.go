@@ -0,0 +1,1260 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/auth"
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	"github.com/Phantomvv1/KayTrade/internal/estimation"
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// The cash a new paper account starts with
+const defaultStartingCash = 100000
+
+// How long the mode of the account is cached. Switching the mode on this server takes effect right away,
+// on the other servers after at most this long.
+const modeTTL = 30 * time.Second
+
+var errNotOpen = errors.New("the order isn't open anymore")
+
+// Account is the paper account of the user. Changes to the starting cash take effect from the next reset,
+// changes to the simulation from the next order.
+type Account struct {
+	Enabled      bool    `json:"enabled"`
+	StartingCash float64 `json:"starting_cash"`
+	Cash         float64 `json:"cash"`
+	Simulation
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// orderError is an error of an order that is shown to the user as it is
+type orderError struct {
+	status  int
+	message string
+}
+
+func (e orderError) Error() string {
+	return e.message
+}
+
+type snapshot struct {
+	LatestTrade struct {
+		Price float64 `json:"p"`
+		Size  float64 `json:"s"`
+	} `json:"latestTrade"`
+	PrevDailyBar struct {
+		Close float64 `json:"c"`
+	} `json:"prevDailyBar"`
+}
+
+func createAccountsTable(conn *pgx.Conn) error {
+	_, err := conn.Exec(context.Background(), "create table if not exists paper_accounts(user_id uuid primary key references authentication(id) on delete cascade, "+
+		"enabled boolean default false, starting_cash double precision, cash double precision, slippage_bps double precision default 0, "+
+		"fill_ratio double precision default 0, created_at timestamp default current_timestamp)")
+	return err
+}
+
+func createTables(conn *pgx.Conn) error {
+	if err := createAccountsTable(conn); err != nil {
+		return err
+	}
+
+	_, err := conn.Exec(context.Background(), "create table if not exists paper_orders(id uuid primary key default gen_random_uuid(), "+
+		"user_id uuid references authentication(id) on delete cascade, symbol text, side text, type text, time_in_force text, qty double precision, "+
+		"filled_qty double precision default 0, filled_avg_price double precision default 0, limit_price double precision, stop_price double precision, "+
+		"trail_price double precision, trail_percent double precision, hwm double precision, triggered boolean default false, status text, "+
+		"created_at timestamp default current_timestamp, updated_at timestamp default current_timestamp, filled_at timestamp, canceled_at timestamp, "+
+		"expired_at timestamp, extended_hours boolean default false)")
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(context.Background(), "create table if not exists paper_positions(user_id uuid references authentication(id) on delete cascade, "+
+		"symbol text, qty double precision, avg_entry_price double precision, primary key (user_id, symbol))")
+	return err
+}
+
+const selectOrders = "select o.id, o.user_id, o.symbol, o.side, o.type, o.time_in_force, o.qty, o.filled_qty, o.filled_avg_price, o.limit_price, " +
+	"o.stop_price, o.trail_price, o.trail_percent, o.hwm, o.triggered, o.status, o.created_at, o.updated_at, o.filled_at, o.canceled_at, " +
+	"o.expired_at, o.extended_hours, a.slippage_bps, a.fill_ratio from paper_orders o join paper_accounts a on a.user_id = o.user_id"
+
+func collectOrders(rows pgx.Rows) ([]*Order, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Order, error) {
+		o := Order{}
+		err := row.Scan(&o.ID, &o.UserID, &o.Symbol, &o.Side, &o.Type, &o.TimeInForce, &o.Qty, &o.FilledQty, &o.FilledAvgPrice, &o.LimitPrice,
+			&o.StopPrice, &o.TrailPrice, &o.TrailPercent, &o.HighWaterMark, &o.Triggered, &o.Status, &o.CreatedAt, &o.UpdatedAt, &o.FilledAt, &o.CanceledAt,
+			&o.ExpiredAt, &o.ExtendedHours, &o.simulation.SlippageBps, &o.simulation.FillRatio)
+		if err != nil {
+			return nil, err
+		}
+
+		return &o, nil
+	})
+}
+
+// getAccount returns the paper account of the user, opening one if the user doesn't have it yet
+func getAccount(conn *pgx.Conn, id string) (Account, error) {
+	_, err := conn.Exec(context.Background(), "insert into paper_accounts (user_id, starting_cash, cash) values ($1, $2, $2) on conflict (user_id) do nothing",
+		id, defaultStartingCash)
+	if err != nil {
+		return Account{}, err
+	}
+
+	acc := Account{}
+	err = conn.QueryRow(context.Background(), "select enabled, starting_cash, cash, slippage_bps, fill_ratio, created_at from paper_accounts where user_id = $1", id).
+		Scan(&acc.Enabled, &acc.StartingCash, &acc.Cash, &acc.SlippageBps, &acc.FillRatio, &acc.CreatedAt)
+	return acc, err
+}
+
+// Enabled tells if the user trades with the paper account
+func Enabled(conn *pgx.Conn, id string) (bool, error) {
+	if err := createAccountsTable(conn); err != nil {
+		return false, err
+	}
+
+	var enabled bool
+	err := conn.QueryRow(context.Background(), "select enabled from paper_accounts where user_id = $1", id).Scan(&enabled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+
+	return enabled, err
+}
+
+type mode struct {
+	enabled bool
+	expires time.Time
+}
+
+// The mode of every user is checked on every trading request, so it's cached instead of read from the database each time
+var modes = struct {
+	sync.Mutex
+	users map[string]mode
+}{users: make(map[string]mode)}
+
+func remember(id string, enabled bool) {
+	modes.Lock()
+	defer modes.Unlock()
+
+	modes.users[id] = mode{enabled: enabled, expires: time.Now().Add(modeTTL)}
+}
+
+// IsEnabled tells if the user trades with the paper account, like Enabled, but only goes to the database
+// when the mode of the user isn't cached
+func IsEnabled(id string) (bool, error) {
+	modes.Lock()
+	m, ok := modes.users[id]
+	modes.Unlock()
+
+	if ok && time.Now().Before(m.expires) {
+		return m.enabled, nil
+	}
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	enabled, err := Enabled(conn, id)
+	if err != nil {
+		return false, err
+	}
+
+	remember(id, enabled)
+	return enabled, nil
+}
+
+func getSnapshots(symbols []string) (map[string]snapshot, error) {
+	return SendRequest[map[string]snapshot](http.MethodGet, MarketData+"/stocks/snapshots?symbols="+strings.Join(symbols, ","), nil, nil, BasicAuth())
+}
+
+// record writes the order after the fill, together with the cash and the position it changed. If the account can't
+// take the fill, the order is canceled instead.
+func record(order Order, fill Fill) error {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	var cash float64
+	err = tx.QueryRow(context.Background(), "select cash from paper_accounts where user_id = $1 for update", order.UserID).Scan(&cash)
+	if err != nil {
+		return err
+	}
+
+	var status string
+	err = tx.QueryRow(context.Background(), "select status from paper_orders where id = $1 for update", order.ID).Scan(&status)
+	if err != nil {
+		return err
+	}
+
+	if status != StatusNew && status != StatusPartiallyFilled {
+		return errNotOpen
+	}
+
+	position := Position{Symbol: order.Symbol}
+	err = tx.QueryRow(context.Background(), "select qty, avg_entry_price from paper_positions where user_id = $1 and symbol = $2", order.UserID, order.Symbol).
+		Scan(&position.Qty, &position.AvgEntryPrice)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	cash, position, err = settle(cash, position, order.Side, fill)
+	if err != nil {
+		now := time.Now()
+		_, cancelErr := tx.Exec(context.Background(), "update paper_orders set status = $1, canceled_at = $2, updated_at = $2 where id = $3", StatusCanceled, now, order.ID)
+		if cancelErr != nil {
+			return cancelErr
+		}
+
+		if cancelErr = tx.Commit(context.Background()); cancelErr != nil {
+			return cancelErr
+		}
+
+		return err
+	}
+
+	_, err = tx.Exec(context.Background(), "update paper_accounts set cash = $1 where user_id = $2", cash, order.UserID)
+	if err != nil {
+		return err
+	}
+
+	if position.Qty == 0 {
+		_, err = tx.Exec(context.Background(), "delete from paper_positions where user_id = $1 and symbol = $2", order.UserID, order.Symbol)
+	} else {
+		_, err = tx.Exec(context.Background(), "insert into paper_positions (user_id, symbol, qty, avg_entry_price) values ($1, $2, $3, $4) "+
+			"on conflict (user_id, symbol) do update set qty = excluded.qty, avg_entry_price = excluded.avg_entry_price",
+			order.UserID, order.Symbol, position.Qty, position.AvgEntryPrice)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(context.Background(), "update paper_orders set filled_qty = $1, filled_avg_price = $2, hwm = $3, triggered = $4, status = $5, "+
+		"updated_at = $6, filled_at = $7 where id = $8",
+		order.FilledQty, order.FilledAvgPrice, order.HighWaterMark, order.Triggered, order.Status, order.UpdatedAt, order.FilledAt, order.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(context.Background())
+}
+
+func cancel(conn *pgx.Conn, id, orderID string) (bool, error) {
+	now := time.Now()
+	tag, err := conn.Exec(context.Background(), "update paper_orders set status = $1, canceled_at = $2, updated_at = $2 where id = $3 and user_id = $4 and status = any($5)",
+		StatusCanceled, now, orderID, id, []string{StatusNew, StatusPartiallyFilled})
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// expire closes the day order at the end of its trading day, unless it was filled or canceled in the meantime
+func expire(order Order) error {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	now := time.Now()
+	_, err = conn.Exec(context.Background(), "update paper_orders set status = $1, expired_at = $2, updated_at = $2 where id = $3 and status = any($4)",
+		StatusExpired, now, order.ID, []string{StatusNew, StatusPartiallyFilled})
+	return err
+}
+
+// availableQty is the quantity of the position that isn't already being sold by an open order
+func availableQty(conn *pgx.Conn, id, symbol string) (float64, error) {
+	var available float64
+	err := conn.QueryRow(context.Background(), "select coalesce((select qty from paper_positions where user_id = $1 and symbol = $2), 0) - "+
+		"coalesce((select sum(qty - filled_qty) from paper_orders where user_id = $1 and symbol = $2 and side = 'sell' and status = any($3)), 0)",
+		id, symbol, []string{StatusNew, StatusPartiallyFilled}).Scan(&available)
+	return available, err
+}
+
+// submit places the order on the paper account. When the market is open the order is matched against the latest trade
+// right away, whatever is left of it is filled by the engine.
+func submit(conn *pgx.Conn, order Order, notional float64, engine *Engine) (Order, error) {
+	acc, err := getAccount(conn, order.UserID)
+	if err != nil {
+		return Order{}, err
+	}
+	order.simulation = acc.Simulation
+
+	snapshots, err := getSnapshots([]string{order.Symbol})
+	if err != nil {
+		return Order{}, orderError{http.StatusFailedDependency, "couldn't get the latest price of " + order.Symbol}
+	}
+
+	latest := snapshots[order.Symbol].LatestTrade
+	if latest.Price == 0 {
+		return Order{}, orderError{http.StatusUnprocessableEntity, "couldn't find a price for " + order.Symbol}
+	}
+
+	if notional > 0 {
+		order.Qty = math.Floor(notional/latest.Price*1e9) / 1e9
+	}
+
+	if order.Side == "buy" {
+		reference := latest.Price
+		if order.LimitPrice != nil {
+			reference = *order.LimitPrice
+		}
+
+		if order.Qty*reference > acc.Cash+epsilon {
+			return Order{}, orderError{http.StatusForbidden, errInsufficientCash.Error()}
+		}
+	} else {
+		available, err := availableQty(conn, order.UserID, order.Symbol)
+		if err != nil {
+			return Order{}, err
+		}
+
+		if order.Qty > available+epsilon {
+			return Order{}, orderError{http.StatusForbidden, errInsufficientShares.Error()}
+		}
+	}
+
+	marketOpen, err := clock.IsStockMarketOpen("NYSE")
+	if err != nil {
+		return Order{}, orderError{http.StatusFailedDependency, "couldn't check if the market is open"}
+	}
+
+	err = conn.QueryRow(context.Background(), "insert into paper_orders (user_id, symbol, side, type, time_in_force, qty, limit_price, stop_price, "+
+		"trail_price, trail_percent, status, extended_hours) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) returning id, created_at, updated_at",
+		order.UserID, order.Symbol, order.Side, order.Type, order.TimeInForce, order.Qty, order.LimitPrice, order.StopPrice, order.TrailPrice,
+		order.TrailPercent, order.Status, order.ExtendedHours).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return Order{}, err
+	}
+
+	if marketOpen {
+		fill, ok := order.simulation.Match(&order, Tick{Price: latest.Price, Size: latest.Size})
+
+		// Fill or kill orders are only filled when all of it can be filled at once
+		if ok && (order.TimeInForce != "fok" || fill.Qty >= order.remaining()-epsilon) {
+			order.apply(fill, time.Now())
+			if err = record(order, fill); err != nil {
+				if errors.Is(err, errInsufficientCash) || errors.Is(err, errInsufficientShares) {
+					return Order{}, orderError{http.StatusForbidden, err.Error()}
+				}
+
+				return Order{}, err
+			}
+		}
+	}
+
+	if !order.open() {
+		return order, nil
+	}
+
+	// Immediate or cancel and fill or kill orders don't wait for the next trades
+	if order.TimeInForce == "ioc" || order.TimeInForce == "fok" {
+		if _, err = cancel(conn, order.UserID, order.ID); err != nil {
+			return Order{}, err
+		}
+
+		now := time.Now()
+		order.Status = StatusCanceled
+		order.CanceledAt = &now
+		return order, nil
+	}
+
+	watched := order
+	engine.Add <- &watched
+
+	return order, nil
+}
+
+func submitExit(c *gin.Context, err error) {
+	var oe orderError
+	if errors.As(err, &oe) {
+		ErrorExit(c, oe.status, oe.message, nil)
+		return
+	}
+
+	ErrorExit(c, http.StatusInternalServerError, "couldn't place the paper order", err)
+}
+
+func format(value float64) string {
+	return strconv.FormatFloat(round(value), 'f', -1, 64)
+}
+
+func formatOptional(value *float64) *string {
+	if value == nil {
+		return nil
+	}
+
+	formatted := format(*value)
+	return &formatted
+}
+
+// alpaca returns the order in the same shape as the orders of Alpaca
+func (o Order) alpaca() gin.H {
+	var filledAvgPrice *string
+	if o.FilledQty > 0 {
+		filledAvgPrice = formatOptional(&o.FilledAvgPrice)
+	}
+
+	intent := "buy_to_open"
+	if o.Side == "sell" {
+		intent = "sell_to_close"
+	}
+
+	return gin.H{
+		"id":               o.ID,
+		"client_order_id":  o.ID,
+		"created_at":       o.CreatedAt,
+		"updated_at":       o.UpdatedAt,
+		"submitted_at":     o.CreatedAt,
+		"filled_at":        o.FilledAt,
+		"expired_at":       o.ExpiredAt,
+		"canceled_at":      o.CanceledAt,
+		"failed_at":        nil,
+		"asset_id":         "",
+		"symbol":           o.Symbol,
+		"asset_class":      "us_equity",
+		"notional":         nil,
+		"qty":              format(o.Qty),
+		"filled_qty":       format(o.FilledQty),
+		"filled_avg_price": filledAvgPrice,
+		"order_class":      "",
+		"order_type":       o.Type,
+		"type":             o.Type,
+		"side":             o.Side,
+		"position_intent":  intent,
+		"time_in_force":    o.TimeInForce,
+		"limit_price":      formatOptional(o.LimitPrice),
+		"stop_price":       formatOptional(o.StopPrice),
+		"trail_price":      formatOptional(o.TrailPrice),
+		"trail_percent":    formatOptional(o.TrailPercent),
+		"hwm":              formatOptional(o.HighWaterMark),
+		"status":           o.Status,
+		"extended_hours":   o.ExtendedHours,
+	}
+}
+
+// alpacaPosition returns the position in the same shape as the positions of Alpaca
+func alpacaPosition(p Position, available float64, s snapshot) gin.H {
+	current := s.LatestTrade.Price
+	if current == 0 {
+		current = p.AvgEntryPrice
+	}
+
+	lastday := s.PrevDailyBar.Close
+	if lastday == 0 {
+		lastday = current
+	}
+
+	costBasis := p.Qty * p.AvgEntryPrice
+	marketValue := p.Qty * current
+
+	var plpc, intradayPLPC float64
+	if costBasis != 0 {
+		plpc = (marketValue - costBasis) / costBasis
+	}
+
+	if lastday != 0 {
+		intradayPLPC = (current - lastday) / lastday
+	}
+
+	return gin.H{
+		"asset_id":                 "",
+		"symbol":                   p.Symbol,
+		"exchange":                 "",
+		"asset_class":              "us_equity",
+		"asset_marginable":         false,
+		"qty":                      format(p.Qty),
+		"qty_available":            format(available),
+		"avg_entry_price":          format(p.AvgEntryPrice),
+		"side":                     "long",
+		"market_value":             format(marketValue),
+		"cost_basis":               format(costBasis),
+		"unrealized_pl":            format(marketValue - costBasis),
+		"unrealized_plpc":          format(plpc),
+		"unrealized_intraday_pl":   format((current - lastday) * p.Qty),
+		"unrealized_intraday_plpc": format(intradayPLPC),
+		"current_price":            format(current),
+		"lastday_price":            format(lastday),
+		"change_today":             format(intradayPLPC),
+	}
+}
+
+func getPositions(conn *pgx.Conn, id, symbol string) ([]Position, error) {
+	rows, err := conn.Query(context.Background(), "select symbol, qty, avg_entry_price from paper_positions where user_id = $1 and ($2 = '' or symbol = $2) order by symbol",
+		id, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Position, error) {
+		p := Position{}
+		err := row.Scan(&p.Symbol, &p.Qty, &p.AvgEntryPrice)
+		return p, err
+	})
+}
+
+// positionsWithPrices returns the positions in the shape of Alpaca, priced with the latest trades
+func positionsWithPrices(conn *pgx.Conn, id string, positions []Position) ([]gin.H, error) {
+	result := []gin.H{}
+	if len(positions) == 0 {
+		return result, nil
+	}
+
+	symbols := make([]string, len(positions))
+	for i, p := range positions {
+		symbols[i] = p.Symbol
+	}
+
+	snapshots, err := getSnapshots(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range positions {
+		available, err := availableQty(conn, id, p.Symbol)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, alpacaPosition(p, available, snapshots[p.Symbol]))
+	}
+
+	return result, nil
+}
+
+func connect(c *gin.Context) *pgx.Conn {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return nil
+	}
+
+	if err = createTables(conn); err != nil {
+		conn.Close(context.Background())
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create the tables for paper trading", err)
+		return nil
+	}
+
+	return conn
+}
+
+func CreateOrder(c *gin.Context, engine *Engine) {
+	id := c.GetString("id")
+
+	req := request{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	order, notional, err := req.parse()
+	if err != nil {
+		ErrorExit(c, http.StatusUnprocessableEntity, err.Error(), nil)
+		return
+	}
+	order.UserID = id
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	order, err = submit(conn, order, notional, engine)
+	if err != nil {
+		submitExit(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order.alpaca())
+}
+
+// Place submits an order the server places on behalf of the user, like the conditional and the recurring ones,
+// on the paper account. The order is returned the same way Alpaca returns it.
+func (e *Engine) Place(id string, body []byte) (map[string]any, error) {
+	req := request{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	order, notional, err := req.parse()
+	if err != nil {
+		return nil, err
+	}
+	order.UserID = id
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		return nil, err
+	}
+
+	order, err = submit(conn, order, notional, e)
+	if err != nil {
+		return nil, err
+	}
+
+	return order.alpaca(), nil
+}
+
+// GetOrders returns the orders the same way the orders table does for the live account
+func GetOrders(c *gin.Context) {
+	id := c.GetString("id")
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), selectOrders+" where o.user_id = $1 order by o.created_at", id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper orders from the database", err)
+		return
+	}
+
+	orders, err := collectOrders(rows)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't work with the information from the database", err)
+		return
+	}
+
+	result := make([]gin.H, len(orders))
+	for i, o := range orders {
+		result[i] = gin.H{"id": o.ID, "user_id": o.UserID, "symbol": o.Symbol, "side": o.Side, "created_at": o.CreatedAt, "updated_at": o.UpdatedAt}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"orders": result})
+}
+
+func GetOrdersAlpaca(c *gin.Context) {
+	id := c.GetString("id")
+
+	var statuses []string
+	switch c.DefaultQuery("status", "open") {
+	case "open":
+		statuses = []string{StatusNew, StatusPartiallyFilled}
+	case "closed":
+		statuses = []string{StatusFilled, StatusCanceled, StatusExpired}
+	case "all":
+		statuses = []string{StatusNew, StatusPartiallyFilled, StatusFilled, StatusCanceled, StatusExpired}
+	default:
+		ErrorExit(c, http.StatusBadRequest, "the status should be open, closed or all", nil)
+		return
+	}
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), selectOrders+" where o.user_id = $1 and o.status = any($2) order by o.created_at desc", id, statuses)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper orders from the database", err)
+		return
+	}
+
+	orders, err := collectOrders(rows)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't work with the information from the database", err)
+		return
+	}
+
+	result := make([]gin.H, len(orders))
+	for i, o := range orders {
+		result[i] = o.alpaca()
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func GetOrder(c *gin.Context) {
+	id := c.GetString("id")
+	orderID := c.Param("orderId")
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), selectOrders+" where o.id::text = $1 and o.user_id = $2", orderID, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper order from the database", err)
+		return
+	}
+
+	orders, err := collectOrders(rows)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't work with the information from the database", err)
+		return
+	}
+
+	if len(orders) == 0 {
+		ErrorExit(c, http.StatusNotFound, "there is no paper order with this id", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, orders[0].alpaca())
+}
+
+func CancelOrder(c *gin.Context, engine *Engine) {
+	id := c.GetString("id")
+	orderID := c.Param("orderId")
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	canceled, err := cancel(conn, id, orderID)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't cancel the paper order", err)
+		return
+	}
+
+	if !canceled {
+		ErrorExit(c, http.StatusNotFound, "there is no open paper order with this id", nil)
+		return
+	}
+
+	engine.Cancel <- orderID
+
+	c.JSON(http.StatusOK, gin.H{"id": orderID, "status": StatusCanceled})
+}
+
+// EstimateOrder estimates the order against the paper account. There are no commissions on paper.
+func EstimateOrder(c *gin.Context) {
+	id := c.GetString("id")
+
+	body, err := c.GetRawData()
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't read the body of the request", err)
+		return
+	}
+
+	req := estimation.Request{}
+	if err = json.Unmarshal(body, &req); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	paperReq := request{}
+	if err = json.Unmarshal(body, &paperReq); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	order, _, err := paperReq.parse()
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	acc, err := getAccount(conn, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper account", err)
+		return
+	}
+
+	positions, err := getPositions(conn, id, order.Symbol)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper positions", err)
+		return
+	}
+
+	var currentPosition float64
+	if len(positions) > 0 {
+		currentPosition = positions[0].Qty
+	}
+
+	quote, err := estimation.GetQuote(order.Symbol)
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the latest quote")
+		return
+	}
+
+	marketOpen, err := clock.IsStockMarketOpen("NYSE")
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't check if the market is open")
+		return
+	}
+
+	estimate, err := estimation.Calculate(req, quote, currentPosition, estimation.Account{BuyingPower: acc.Cash, Cash: acc.Cash}, estimation.Commission{}, marketOpen)
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
+
+func GetPositions(c *gin.Context) {
+	id := c.GetString("id")
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	positions, err := getPositions(conn, id, "")
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper positions", err)
+		return
+	}
+
+	result, err := positionsWithPrices(conn, id, positions)
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the prices of the paper positions")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func GetPosition(c *gin.Context) {
+	id := c.GetString("id")
+	symbol := strings.ToUpper(c.Param("symbol_or_asset_id"))
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	positions, err := getPositions(conn, id, symbol)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper position", err)
+		return
+	}
+
+	if len(positions) == 0 {
+		ErrorExit(c, http.StatusNotFound, "the paper account doesn't have a position for this symbol", nil)
+		return
+	}
+
+	result, err := positionsWithPrices(conn, id, positions)
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the price of the paper position")
+		return
+	}
+
+	c.JSON(http.StatusOK, result[0])
+}
+
+// closingOrder is the market order that sells the given quantity of the position
+func closingOrder(id, symbol string, qty float64) Order {
+	return Order{UserID: id, Symbol: symbol, Side: "sell", Type: "market", TimeInForce: "day", Qty: qty, Status: StatusNew}
+}
+
+func ClosePosition(c *gin.Context, engine *Engine) {
+	id := c.GetString("id")
+	symbol := strings.ToUpper(c.Param("symbol_or_asset_id"))
+
+	var body struct {
+		Qty        float64 `json:"qty"`
+		Percentage float64 `json:"percentage"`
+	}
+
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	if body.Qty != 0 && body.Percentage != 0 {
+		ErrorExit(c, http.StatusBadRequest, "only 1 of the bonus parameters can be specified at a time", nil)
+		return
+	}
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	available, err := availableQty(conn, id, symbol)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper position", err)
+		return
+	}
+
+	qty := available
+	if body.Qty != 0 {
+		qty = body.Qty
+	} else if body.Percentage != 0 {
+		qty = math.Floor(available*body.Percentage/100*1e9) / 1e9
+	}
+
+	if qty <= 0 {
+		ErrorExit(c, http.StatusNotFound, "the paper account doesn't have a position for this symbol", nil)
+		return
+	}
+
+	order, err := submit(conn, closingOrder(id, symbol, qty), 0, engine)
+	if err != nil {
+		submitExit(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order.alpaca())
+}
+
+func CloseAllPositions(c *gin.Context, engine *Engine) {
+	id := c.GetString("id")
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	positions, err := getPositions(conn, id, "")
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper positions", err)
+		return
+	}
+
+	result := []gin.H{}
+	for _, p := range positions {
+		available, err := availableQty(conn, id, p.Symbol)
+		if err != nil {
+			ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper position", err)
+			return
+		}
+
+		if available <= epsilon {
+			continue
+		}
+
+		order, err := submit(conn, closingOrder(id, p.Symbol, available), 0, engine)
+		if err != nil {
+			result = append(result, gin.H{"symbol": p.Symbol, "status": http.StatusInternalServerError, "body": gin.H{"error": "Error " + err.Error()}})
+			continue
+		}
+
+		result = append(result, gin.H{"symbol": p.Symbol, "status": http.StatusOK, "body": order.alpaca()})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetTradingDetails returns the paper account in the shape of the trading details of the live account
+func GetTradingDetails(c *gin.Context) {
+	id := c.GetString("id")
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	acc, err := getAccount(conn, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper account", err)
+		return
+	}
+
+	positions, err := getPositions(conn, id, "")
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper positions", err)
+		return
+	}
+
+	equity := acc.Cash
+	if len(positions) > 0 {
+		symbols := make([]string, len(positions))
+		for i, p := range positions {
+			symbols[i] = p.Symbol
+		}
+
+		snapshots, err := getSnapshots(symbols)
+		if err != nil {
+			RequestExit(c, nil, err, "couldn't get the prices of the paper positions")
+			return
+		}
+
+		for _, p := range positions {
+			price := snapshots[p.Symbol].LatestTrade.Price
+			if price == 0 {
+				price = p.AvgEntryPrice
+			}
+
+			equity += p.Qty * price
+		}
+	}
+
+	// The paper accounts are cash accounts, so they don't have any margin
+	details := auth.TradingDetails{
+		AccountNumber:         "PAPER",
+		Fees:                  "0",
+		BuyingPower:           format(acc.Cash),
+		RegTBuyingPower:       format(acc.Cash),
+		DaytradingBuyingPower: "0",
+		Cash:                  format(acc.Cash),
+		CashTransferable:      "0",
+		CashWithdrawable:      "0",
+		Currency:              "USD",
+		Equity:                format(equity),
+		LongMarketValue:       format(equity - acc.Cash),
+		ShortMarketValue:      "0",
+		IntradayAdjustments:   "0",
+		InitialMargin:         "0",
+		MaintenanceMargin:     "0",
+		Multiplier:            "1",
+		Status:                "ACTIVE",
+	}
+	details.CheckMargin()
+
+	c.JSON(http.StatusOK, details)
+}
+
+func GetAccount(c *gin.Context) {
+	id := c.GetString("id")
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	acc, err := getAccount(conn, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper account", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, acc)
+}
+
+// UpdateAccount switches between the paper and the live account and changes the settings of the paper account
+func UpdateAccount(c *gin.Context) {
+	id := c.GetString("id")
+
+	var settings struct {
+		Enabled      *bool    `json:"enabled"`
+		StartingCash *float64 `json:"starting_cash"`
+		SlippageBps  *float64 `json:"slippage_bps"`
+		FillRatio    *float64 `json:"fill_ratio"`
+	}
+
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	if settings.StartingCash != nil && *settings.StartingCash <= 0 {
+		ErrorExit(c, http.StatusBadRequest, "the starting cash should be a positive number", nil)
+		return
+	}
+
+	if settings.SlippageBps != nil && (*settings.SlippageBps < 0 || *settings.SlippageBps > 1000) {
+		ErrorExit(c, http.StatusBadRequest, "the slippage should be between 0 and 1000 basis points", nil)
+		return
+	}
+
+	if settings.FillRatio != nil && (*settings.FillRatio < 0 || *settings.FillRatio > 1) {
+		ErrorExit(c, http.StatusBadRequest, "the fill ratio should be between 0 and 1", nil)
+		return
+	}
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := getAccount(conn, id); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper account", err)
+		return
+	}
+
+	_, err := conn.Exec(context.Background(), "update paper_accounts set enabled = coalesce($1, enabled), starting_cash = coalesce($2, starting_cash), "+
+		"slippage_bps = coalesce($3, slippage_bps), fill_ratio = coalesce($4, fill_ratio) where user_id = $5",
+		settings.Enabled, settings.StartingCash, settings.SlippageBps, settings.FillRatio, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't update the paper account", err)
+		return
+	}
+
+	acc, err := getAccount(conn, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper account", err)
+		return
+	}
+	remember(id, acc.Enabled)
+
+	c.JSON(http.StatusOK, acc)
+}
+
+// ResetAccount cancels the open orders, closes the positions without trading them and puts the starting cash back
+func ResetAccount(c *gin.Context, engine *Engine) {
+	id := c.GetString("id")
+
+	conn := connect(c)
+	if conn == nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := getAccount(conn, id); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper account", err)
+		return
+	}
+
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't start a transaction", err)
+		return
+	}
+	defer tx.Rollback(context.Background())
+
+	_, err = tx.Exec(context.Background(), "update paper_accounts set cash = starting_cash where user_id = $1", id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't reset the cash of the paper account", err)
+		return
+	}
+
+	_, err = tx.Exec(context.Background(), "delete from paper_positions where user_id = $1", id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't close the paper positions", err)
+		return
+	}
+
+	now := time.Now()
+	rows, err := tx.Query(context.Background(), "update paper_orders set status = $1, canceled_at = $2, updated_at = $2 where user_id = $3 and status = any($4) returning id",
+		StatusCanceled, now, id, []string{StatusNew, StatusPartiallyFilled})
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't cancel the paper orders", err)
+		return
+	}
+
+	canceled, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't cancel the paper orders", err)
+		return
+	}
+
+	if err = tx.Commit(context.Background()); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't reset the paper account", err)
+		return
+	}
+
+	for _, orderID := range canceled {
+		engine.Cancel <- orderID
+	}
+
+	acc, err := getAccount(conn, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the paper account", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, acc)
+}
+
+// Middleware sends the requests of users that trade with their paper account to the paper handlers, so the API
+// stays the same for both accounts. The requests that would trade on the live account or show its numbers and don't
+// have a paper handler are refused, so a paper user never trades live by accident or mistakes the live account for
+// the paper one. Everything else only manages what the server places later, and those orders are sent to the paper
+// account when they're placed.
+func Middleware(engine *Engine) gin.HandlerFunc {
+	handlers := map[string]gin.HandlerFunc{
+		"POST /trading":                                 func(c *gin.Context) { CreateOrder(c, engine) },
+		"GET /trading":                                  GetOrders,
+		"GET /trading/alpaca":                           GetOrdersAlpaca,
+		"GET /trading/orders/:orderId":                  GetOrder,
+		"DELETE /trading/orders/:orderId":               func(c *gin.Context) { CancelOrder(c, engine) },
+		"POST /trading/orders/estimation":               EstimateOrder,
+		"GET /trading/positions":                        GetPositions,
+		"DELETE /trading/positions":                     func(c *gin.Context) { CloseAllPositions(c, engine) },
+		"GET /trading/positions/:symbol_or_asset_id":    GetPosition,
+		"DELETE /trading/positions/:symbol_or_asset_id": func(c *gin.Context) { ClosePosition(c, engine) },
+		"GET /users/trading-details":                    GetTradingDetails,
+	}
+
+	refused := map[string]struct{}{
+		"PATCH /trading/orders/:orderId":   {},
+		"POST /trading/rebalance":          {},
+		"POST /trading/baskets":            {},
+		"GET /trading/portfolio":           {},
+		"GET /trading/portfolio/analytics": {},
+		"GET /trading/pnl":                 {},
+		"GET /trading/pnl/lots":            {},
+		"GET /trading/pnl/export":          {},
+		"POST /trading/pnl/designations":   {},
+		"GET /trading/dividends":           {},
+	}
+
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.FullPath(), "/trading/paper") {
+			c.Next()
+			return
+		}
+
+		enabled, err := IsEnabled(c.GetString("id"))
+		if err != nil {
+			log.Println(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error couldn't check if paper trading is enabled"})
+			return
+		}
+
+		if !enabled {
+			c.Next()
+			return
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		if _, ok := refused[route]; ok {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Error this isn't available while paper trading"})
+			return
+		}
+
+		handler, ok := handlers[route]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		handler(c)
+		c.Abort()
+	}
+}
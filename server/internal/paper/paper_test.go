@@ -0,0 +1,325 @@
+package paper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	"github.com/gin-gonic/gin"
+)
+
+func price(value float64) *float64 {
+	return &value
+}
+
+func TestParse(t *testing.T) {
+	order, notional, err := request{Symbol: "aapl", Side: "buy", Notional: "50"}.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order.Symbol != "AAPL" || order.Type != "market" || order.TimeInForce != "day" || notional != 50 {
+		t.Fatalf("unexpected order %+v %g", order, notional)
+	}
+
+	invalid := []request{
+		{Side: "buy", Qty: "1"},
+		{Symbol: "AAPL", Side: "short", Qty: "1"},
+		{Symbol: "AAPL", Side: "buy"},
+		{Symbol: "AAPL", Side: "buy", Qty: "1", Notional: "10"},
+		{Symbol: "AAPL", Side: "buy", Type: "limit", Qty: "1"},
+		{Symbol: "AAPL", Side: "buy", Type: "limit", Qty: "1.5", LimitPrice: "10"},
+		{Symbol: "AAPL", Side: "buy", Type: "stop_limit", Qty: "1", LimitPrice: "10"},
+		{Symbol: "AAPL", Side: "sell", Type: "trailing_stop", Qty: "1"},
+		{Symbol: "AAPL", Side: "sell", Type: "trailing_stop", Qty: "1", TrailPrice: "1", TrailPercent: "2"},
+		{Symbol: "AAPL", Side: "buy", Qty: "1", TimeInForce: "forever"},
+		{Symbol: "AAPL250620C00200000", Side: "buy", Qty: "1"},
+		{Symbol: "AAPL", Side: "buy", Qty: "1", TimeInForce: "opg"},
+		{Symbol: "AAPL", Side: "buy", Qty: "1", TimeInForce: "cls"},
+		{Symbol: "AAPL", Side: "buy", Qty: "1", ExtendedHours: true},
+		{Symbol: "AAPL", Side: "buy", Type: "limit", Qty: "1", LimitPrice: "10", TimeInForce: "ioc", ExtendedHours: true},
+	}
+
+	for _, req := range invalid {
+		if _, _, err := req.parse(); err == nil {
+			t.Fatalf("expected an error for %+v", req)
+		}
+	}
+}
+
+func TestParse_NumbersAndStrings(t *testing.T) {
+	req := request{}
+	if err := json.Unmarshal([]byte(`{"symbol":"AAPL","side":"sell","qty":2.5}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	order, _, err := req.parse()
+	if err != nil || order.Qty != 2.5 {
+		t.Fatalf("unexpected order %+v %v", order, err)
+	}
+}
+
+func TestMatch_MarketWithSlippage(t *testing.T) {
+	s := Simulation{SlippageBps: 10}
+
+	buy := &Order{Side: "buy", Type: "market", Qty: 10, Status: StatusNew}
+	fill, ok := s.Match(buy, Tick{Price: 100})
+	if !ok || fill.Qty != 10 || fill.Price != 100.1 {
+		t.Fatalf("unexpected fill %+v", fill)
+	}
+
+	sell := &Order{Side: "sell", Type: "market", Qty: 10, Status: StatusNew}
+	fill, _ = s.Match(sell, Tick{Price: 100})
+	if fill.Price != 99.9 {
+		t.Fatalf("unexpected fill %+v", fill)
+	}
+}
+
+func TestMatch_Limit(t *testing.T) {
+	s := Simulation{SlippageBps: 50}
+	order := &Order{Side: "buy", Type: "limit", Qty: 5, LimitPrice: price(100), Status: StatusNew}
+
+	if _, ok := s.Match(order, Tick{Price: 101}); ok {
+		t.Fatal("expected no fill above the limit")
+	}
+
+	fill, ok := s.Match(order, Tick{Price: 99.5})
+	if !ok || fill.Price != 99.5 {
+		t.Fatalf("expected a fill at the trade price without slippage, got %+v", fill)
+	}
+}
+
+func TestMatch_StopLimit(t *testing.T) {
+	s := Simulation{}
+	order := &Order{Side: "sell", Type: "stop_limit", Qty: 5, StopPrice: price(95), LimitPrice: price(94), Status: StatusNew}
+
+	if _, ok := s.Match(order, Tick{Price: 96}); ok || order.Triggered {
+		t.Fatal("expected the stop to not be reached")
+	}
+
+	if _, ok := s.Match(order, Tick{Price: 93}); ok || !order.Triggered {
+		t.Fatal("expected the stop to be reached without a fill under the limit")
+	}
+
+	if fill, ok := s.Match(order, Tick{Price: 94.5}); !ok || fill.Price != 94.5 {
+		t.Fatalf("expected the triggered order to fill as a limit order, got %+v", fill)
+	}
+}
+
+func TestMatch_TrailingStop(t *testing.T) {
+	s := Simulation{}
+	order := &Order{Side: "sell", Type: "trailing_stop", Qty: 1, TrailPercent: price(10), Status: StatusNew}
+
+	for _, p := range []float64{100, 120, 109} {
+		if _, ok := s.Match(order, Tick{Price: p}); ok {
+			t.Fatalf("unexpected fill at %g", p)
+		}
+	}
+
+	if *order.HighWaterMark != 120 {
+		t.Fatalf("expected the high water mark to be 120, got %g", *order.HighWaterMark)
+	}
+
+	if fill, ok := s.Match(order, Tick{Price: 108}); !ok || fill.Price != 108 {
+		t.Fatalf("expected a fill once the price is 10%% below the high, got %+v", fill)
+	}
+
+	buy := &Order{Side: "buy", Type: "trailing_stop", Qty: 1, TrailPrice: price(2), Status: StatusNew}
+	s.Match(buy, Tick{Price: 50})
+	s.Match(buy, Tick{Price: 45})
+	if _, ok := s.Match(buy, Tick{Price: 46.5}); ok {
+		t.Fatal("expected no fill under the trail")
+	}
+
+	if _, ok := s.Match(buy, Tick{Price: 47}); !ok {
+		t.Fatal("expected a fill 2 above the low")
+	}
+}
+
+func TestMatch_PartialFills(t *testing.T) {
+	s := Simulation{FillRatio: 0.5}
+	order := &Order{Side: "buy", Type: "market", Qty: 100, Status: StatusNew}
+
+	fill, _ := s.Match(order, Tick{Price: 10, Size: 50})
+	if fill.Qty != 25 {
+		t.Fatalf("expected 25 shares to fill, got %g", fill.Qty)
+	}
+
+	order.apply(fill, time.Now())
+	if order.Status != StatusPartiallyFilled || order.FilledQty != 25 {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	fill, _ = s.Match(order, Tick{Price: 12, Size: 1000})
+	order.apply(fill, time.Now())
+	if order.Status != StatusFilled || order.FilledQty != 100 || order.FilledAvgPrice != 11.5 || order.FilledAt == nil {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	if _, ok := s.Match(order, Tick{Price: 10}); ok {
+		t.Fatal("expected a filled order to not match again")
+	}
+}
+
+func TestSettle(t *testing.T) {
+	cash, p, err := settle(1000, Position{Symbol: "AAPL", Qty: 2, AvgEntryPrice: 100}, "buy", Fill{Qty: 2, Price: 200})
+	if err != nil || cash != 600 || p.Qty != 4 || p.AvgEntryPrice != 150 {
+		t.Fatalf("unexpected buy %g %+v %v", cash, p, err)
+	}
+
+	if _, _, err = settle(100, p, "buy", Fill{Qty: 1, Price: 200}); err != errInsufficientCash {
+		t.Fatalf("expected insufficient cash, got %v", err)
+	}
+
+	cash, p, err = settle(cash, p, "sell", Fill{Qty: 4, Price: 160})
+	if err != nil || cash != 1240 || p.Qty != 0 || p.AvgEntryPrice != 0 {
+		t.Fatalf("unexpected sell %g %+v %v", cash, p, err)
+	}
+
+	if _, _, err = settle(cash, p, "sell", Fill{Qty: 1, Price: 160}); err != errInsufficientShares {
+		t.Fatalf("expected insufficient shares, got %v", err)
+	}
+}
+
+func TestEngine_FillsAndForgets(t *testing.T) {
+	engine := NewEngine(nil)
+	engine.session = clock.Core
+
+	recorded := make(chan settlement, 2)
+	engine.record = func(order Order, fill Fill) error {
+		recorded <- settlement{order: order, fill: fill}
+		return nil
+	}
+	go engine.settle()
+
+	engine.watch(&Order{ID: "1", Symbol: "AAPL", Side: "buy", Type: "limit", Qty: 1, LimitPrice: price(100), Status: StatusNew})
+	engine.watch(&Order{ID: "2", Symbol: "AAPL", Side: "buy", Type: "limit", Qty: 1, LimitPrice: price(90), Status: StatusNew})
+
+	engine.evaluate(map[string]any{"T": "q", "S": "AAPL", "bp": 95.0})
+	engine.evaluate(map[string]any{"T": "t", "S": "AAPL", "p": 95.0, "s": 10.0})
+
+	s := <-recorded
+	if s.order.ID != "1" || s.order.Status != StatusFilled || s.fill.Price != 95 {
+		t.Fatalf("unexpected settlement %+v", s)
+	}
+
+	if len(engine.orders["AAPL"]) != 1 || engine.orders["AAPL"]["2"] == nil {
+		t.Fatalf("expected only order 2 to be left, got %v", engine.orders["AAPL"])
+	}
+}
+
+// fakeSessions is always in the given session and the day orders expire at close
+type fakeSessions struct {
+	session clock.Session
+	close   time.Time
+}
+
+func (f fakeSessions) Session(now time.Time) (clock.Session, error) {
+	return f.session, nil
+}
+
+func (f fakeSessions) NextChange(now time.Time) (time.Time, error) {
+	return now.Add(time.Hour), nil
+}
+
+func (f fakeSessions) NextClose(now time.Time, extended bool) (time.Time, error) {
+	return f.close, nil
+}
+
+func TestEngine_ExtendedHoursOnly(t *testing.T) {
+	engine := NewEngine(nil)
+	engine.schedule = fakeSessions{session: clock.AfterHours, close: time.Now().Add(time.Hour)}
+	engine.updateSession()
+
+	recorded := make(chan settlement, 2)
+	engine.record = func(order Order, fill Fill) error {
+		recorded <- settlement{order: order, fill: fill}
+		return nil
+	}
+	go engine.settle()
+
+	engine.watch(&Order{ID: "1", Symbol: "AAPL", Side: "buy", Type: "limit", TimeInForce: "day", Qty: 1, LimitPrice: price(100), Status: StatusNew})
+	engine.watch(&Order{ID: "2", Symbol: "AAPL", Side: "buy", Type: "limit", TimeInForce: "day", Qty: 1, LimitPrice: price(100), Status: StatusNew,
+		ExtendedHours: true})
+
+	engine.evaluate(map[string]any{"T": "t", "S": "AAPL", "p": 95.0, "s": 10.0})
+
+	if s := <-recorded; s.order.ID != "2" {
+		t.Fatalf("expected only the extended hours order to fill, got %+v", s)
+	}
+
+	if engine.orders["AAPL"]["1"] == nil {
+		t.Fatal("expected the regular order to wait for the core session")
+	}
+}
+
+func TestEngine_ExpiresDayOrders(t *testing.T) {
+	engine := NewEngine(nil)
+	engine.schedule = fakeSessions{session: clock.AfterHours, close: time.Now().Add(-time.Minute)}
+
+	expired := make(chan Order, 2)
+	engine.expire = func(order Order) error {
+		expired <- order
+		return nil
+	}
+	go engine.settle()
+
+	engine.watch(&Order{ID: "1", Symbol: "AAPL", Side: "buy", Type: "limit", TimeInForce: "day", Qty: 1, LimitPrice: price(100), Status: StatusNew})
+	engine.watch(&Order{ID: "2", Symbol: "AAPL", Side: "buy", Type: "limit", TimeInForce: "gtc", Qty: 1, LimitPrice: price(100), Status: StatusNew})
+	engine.updateSession()
+
+	if order := <-expired; order.ID != "1" {
+		t.Fatalf("expected the day order to expire, got %+v", order)
+	}
+
+	if len(engine.orders["AAPL"]) != 1 || engine.orders["AAPL"]["2"] == nil {
+		t.Fatalf("expected only the gtc order to be left, got %v", engine.orders["AAPL"])
+	}
+}
+
+func TestIsEnabled_Cached(t *testing.T) {
+	remember("paper-user", true)
+
+	enabled, err := IsEnabled("paper-user")
+	if err != nil || !enabled {
+		t.Fatalf("expected the cached mode, got %v, %v", enabled, err)
+	}
+
+	remember("paper-user", false)
+
+	enabled, err = IsEnabled("paper-user")
+	if err != nil || enabled {
+		t.Fatalf("expected the switched mode, got %v, %v", enabled, err)
+	}
+}
+
+func TestMiddleware_RefusesTheLiveAccountNumbers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	remember("paper-middleware", true)
+
+	r := gin.New()
+	trade := r.Group("/trading", func(c *gin.Context) { c.Set("id", "paper-middleware") }, Middleware(NewEngine(nil)))
+	for _, path := range []string{"/portfolio", "/portfolio/analytics", "/pnl", "/dividends", "/conditional"} {
+		trade.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+
+	tests := map[string]int{
+		"/trading/portfolio":           http.StatusConflict,
+		"/trading/portfolio/analytics": http.StatusConflict,
+		"/trading/pnl":                 http.StatusConflict,
+		"/trading/dividends":           http.StatusConflict,
+		"/trading/conditional":         http.StatusOK,
+	}
+
+	for path, expected := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != expected {
+			t.Fatalf("expected %d for %s, got %d", expected, path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package pnl
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Methods of matching the sells to the tax lots
+const (
+	MethodFIFO        = "fifo"
+	MethodLIFO        = "lifo"
+	MethodHighestCost = "highest_cost"
+	MethodSpecific    = "specific"
+)
+
+// Quantities smaller than this are leftovers from float arithmetic
+const epsilon = 1e-9
+
+// Fill is a single execution of an order, as reported by the FILL account activities
+type Fill struct {
+	ID      string
+	OrderID string
+	Symbol  string
+	Side    string
+	Qty     float64
+	Price   float64
+	Time    time.Time
+}
+
+// Lot is a fill that's still (at least partly) open. The ID of the lot is the ID of the fill.
+// A buy opens a long lot, a sell of more than what's held opens a short lot, whose quantities are negative.
+type Lot struct {
+	ID         string    `json:"id"`
+	Symbol     string    `json:"symbol"`
+	Qty        float64   `json:"qty"`
+	Remaining  float64   `json:"remaining_qty"`
+	Price      float64   `json:"price"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// Realized is the part of a fill that was matched to a single lot. For a short lot the proceeds are
+// from the short sale and the cost basis is what it took to buy the shares back.
+type Realized struct {
+	FillID     string    `json:"fill_id"`
+	LotID      string    `json:"lot_id"`
+	Symbol     string    `json:"symbol"`
+	Qty        float64   `json:"qty"`
+	CostBasis  float64   `json:"cost_basis"`
+	Proceeds   float64   `json:"proceeds"`
+	Gain       float64   `json:"gain"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	SoldAt     time.Time `json:"sold_at"`
+	LongTerm   bool      `json:"long_term"`
+}
+
+func ValidMethod(method string) bool {
+	return method == MethodFIFO || method == MethodLIFO || method == MethodHighestCost || method == MethodSpecific
+}
+
+// LongTerm reports whether a lot was held for more than a year
+func LongTerm(acquiredAt, soldAt time.Time) bool {
+	return soldAt.After(acquiredAt.AddDate(1, 0, 0))
+}
+
+// orderLots sorts the lots in the order they are sold in. With the specific method the designated lots
+// go first and whatever is left over falls back to FIFO.
+func orderLots(lots []*Lot, method string, designated []string) []*Lot {
+	ordered := slices.Clone(lots)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+
+		switch method {
+		case MethodLIFO:
+			return a.AcquiredAt.After(b.AcquiredAt)
+		case MethodHighestCost:
+			// For short lots the sale price is the proceeds, so the cheapest ones realize the least
+			if a.Price != b.Price {
+				return (a.Price > b.Price) == (a.Remaining > 0)
+			}
+		case MethodSpecific:
+			ia, ib := slices.Index(designated, a.ID), slices.Index(designated, b.ID)
+			if ia != ib && (ia == -1 || ib == -1) {
+				return ib == -1
+			}
+
+			if ia != ib {
+				return ia < ib
+			}
+		}
+
+		return a.AcquiredAt.Before(b.AcquiredAt)
+	})
+
+	return ordered
+}
+
+// Match closes the open lots of the symbol the fill trades against: the long lots for a sell and the short lots
+// for a buy. The remaining quantity of the lots is updated in place. Whatever couldn't be matched to a lot is returned
+// as unmatched, it opens a new lot.
+func Match(lots []*Lot, fill Fill, method string, designated []string) ([]Realized, float64) {
+	cover := fill.Side == "buy"
+
+	var open []*Lot
+	for _, lot := range lots {
+		if lot.Symbol == fill.Symbol && (cover && lot.Remaining < -epsilon || !cover && lot.Remaining > epsilon) {
+			open = append(open, lot)
+		}
+	}
+
+	remaining := fill.Qty
+	var realized []Realized
+	for _, lot := range orderLots(open, method, designated) {
+		if remaining <= epsilon {
+			break
+		}
+
+		qty := math.Min(remaining, math.Abs(lot.Remaining))
+		remaining -= qty
+
+		costBasis := round(qty * lot.Price)
+		proceeds := round(qty * fill.Price)
+		longTerm := LongTerm(lot.AcquiredAt, fill.Time)
+		if cover {
+			lot.Remaining += qty
+			costBasis, proceeds = proceeds, costBasis
+			// Gains from short sales are short term however long the position was open
+			longTerm = false
+		} else {
+			lot.Remaining -= qty
+		}
+
+		if math.Abs(lot.Remaining) <= epsilon {
+			lot.Remaining = 0
+		}
+
+		realized = append(realized, Realized{
+			FillID:     fill.ID,
+			LotID:      lot.ID,
+			Symbol:     fill.Symbol,
+			Qty:        qty,
+			CostBasis:  costBasis,
+			Proceeds:   proceeds,
+			Gain:       round(proceeds - costBasis),
+			AcquiredAt: lot.AcquiredAt,
+			SoldAt:     fill.Time,
+			LongTerm:   longTerm,
+		})
+	}
+
+	if remaining <= epsilon {
+		remaining = 0
+	}
+
+	return realized, remaining
+}
+
+// Open returns the lot opened by the part of the fill that didn't close another lot
+func Open(fill Fill, qty float64) *Lot {
+	if fill.Side != "buy" {
+		qty = -qty
+	}
+
+	return &Lot{ID: fill.ID, Symbol: fill.Symbol, Qty: qty, Remaining: qty, Price: fill.Price, AcquiredAt: fill.Time}
+}
+
+type SymbolReport struct {
+	Symbol    string     `json:"symbol"`
+	ShortTerm float64    `json:"short_term"`
+	LongTerm  float64    `json:"long_term"`
+	Total     float64    `json:"total"`
+	Lots      []Realized `json:"lots"`
+}
+
+type Report struct {
+	Method    string         `json:"method"`
+	ShortTerm float64        `json:"short_term"`
+	LongTerm  float64        `json:"long_term"`
+	Total     float64        `json:"total"`
+	Symbols   []SymbolReport `json:"symbols"`
+}
+
+// Summarize groups the realized gains by symbol and splits them into short and long term
+func Summarize(method string, realized []Realized) Report {
+	report := Report{Method: method, Symbols: []SymbolReport{}}
+
+	indexes := make(map[string]int)
+	for _, r := range realized {
+		i, ok := indexes[r.Symbol]
+		if !ok {
+			i = len(report.Symbols)
+			indexes[r.Symbol] = i
+			report.Symbols = append(report.Symbols, SymbolReport{Symbol: r.Symbol})
+		}
+
+		symbol := &report.Symbols[i]
+		symbol.Lots = append(symbol.Lots, r)
+		if r.LongTerm {
+			symbol.LongTerm += r.Gain
+			report.LongTerm += r.Gain
+		} else {
+			symbol.ShortTerm += r.Gain
+			report.ShortTerm += r.Gain
+		}
+	}
+
+	for i := range report.Symbols {
+		symbol := &report.Symbols[i]
+		symbol.ShortTerm = round(symbol.ShortTerm)
+		symbol.LongTerm = round(symbol.LongTerm)
+		symbol.Total = round(symbol.ShortTerm + symbol.LongTerm)
+	}
+
+	sort.Slice(report.Symbols, func(i, j int) bool {
+		return report.Symbols[i].Symbol < report.Symbols[j].Symbol
+	})
+
+	report.ShortTerm = round(report.ShortTerm)
+	report.LongTerm = round(report.LongTerm)
+	report.Total = round(report.ShortTerm + report.LongTerm)
+
+	return report
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,525 @@
+package pnl
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const dateLayout = "2006-01-02"
+
+type activity struct {
+	ID              string    `json:"id"`
+	OrderID         string    `json:"order_id"`
+	Symbol          string    `json:"symbol"`
+	Side            string    `json:"side"`
+	Qty             string    `json:"qty"`
+	Price           string    `json:"price"`
+	TransactionTime time.Time `json:"transaction_time"`
+}
+
+func createTables(conn *pgx.Conn) error {
+	_, err := conn.Exec(context.Background(), "create table if not exists pnl_settings(user_id uuid primary key references authentication(id) on delete cascade, "+
+		"method text default 'fifo', synced_until timestamp)")
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(context.Background(), "create table if not exists pnl_fills(id text primary key, user_id uuid references authentication(id) on delete cascade)")
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(context.Background(), "create table if not exists tax_lots(id text primary key, user_id uuid references authentication(id) on delete cascade, "+
+		"symbol text, qty double precision, remaining_qty double precision, price double precision, acquired_at timestamp)")
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(context.Background(), "create table if not exists realized_gains(id serial primary key, user_id uuid references authentication(id) on delete cascade, "+
+		"fill_id text, lot_id text, symbol text, qty double precision, cost_basis double precision, proceeds double precision, gain double precision, "+
+		"acquired_at timestamp, sold_at timestamp, long_term boolean)")
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(context.Background(), "create table if not exists lot_designations(order_id text primary key, user_id uuid references authentication(id) on delete cascade, "+
+		"lot_ids text[])")
+	return err
+}
+
+// getFills returns the fills of the account in the order they happened
+func getFills(id string, after *time.Time) ([]Fill, error) {
+	query := url.Values{}
+	query.Set("account_id", id)
+	query.Set("activity_types", "FILL")
+	query.Set("direction", "asc")
+	if after != nil {
+		// Going a bit back doesn't hurt, the fills we already processed are skipped
+		query.Set("after", after.Add(-time.Minute).Format(time.RFC3339))
+	}
+
+	activities, err := GetActivities(query, func(a activity) string { return a.ID })
+	if err != nil {
+		return nil, err
+	}
+
+	fills := make([]Fill, 0, len(activities))
+	for _, a := range activities {
+		qty, err := strconv.ParseFloat(a.Qty, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		price, err := strconv.ParseFloat(a.Price, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		fills = append(fills, Fill{
+			ID:      a.ID,
+			OrderID: a.OrderID,
+			Symbol:  a.Symbol,
+			Side:    a.Side,
+			Qty:     qty,
+			Price:   price,
+			Time:    a.TransactionTime.UTC(),
+		})
+	}
+
+	return fills, nil
+}
+
+// Sync brings the tax lots and the realized gains of the user up to date with the fills on Alpaca
+// and returns the matching method of the user. It runs in a transaction that locks the settings of the user,
+// so the same fill is never matched twice.
+func Sync(conn *pgx.Conn, id string) (string, error) {
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(context.Background())
+
+	_, err = tx.Exec(context.Background(), "insert into pnl_settings (user_id, method) values ($1, $2) on conflict (user_id) do nothing", id, MethodFIFO)
+	if err != nil {
+		return "", err
+	}
+
+	var method string
+	var syncedUntil *time.Time
+	err = tx.QueryRow(context.Background(), "select method, synced_until from pnl_settings where user_id = $1 for update", id).Scan(&method, &syncedUntil)
+	if err != nil {
+		return "", err
+	}
+
+	fills, err := getFills(id, syncedUntil)
+	if err != nil {
+		return "", err
+	}
+
+	if len(fills) == 0 {
+		return method, tx.Commit(context.Background())
+	}
+
+	rows, err := tx.Query(context.Background(), "select id, symbol, qty, remaining_qty, price, acquired_at from tax_lots where user_id = $1 and remaining_qty <> 0", id)
+	if err != nil {
+		return "", err
+	}
+
+	lots, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Lot, error) {
+		lot := Lot{}
+		err := row.Scan(&lot.ID, &lot.Symbol, &lot.Qty, &lot.Remaining, &lot.Price, &lot.AcquiredAt)
+		return &lot, err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	designations := make(map[string][]string)
+	rows, err = tx.Query(context.Background(), "select order_id, lot_ids from lot_designations where user_id = $1", id)
+	if err != nil {
+		return "", err
+	}
+
+	for rows.Next() {
+		var orderID string
+		var lotIDs []string
+		if err = rows.Scan(&orderID, &lotIDs); err != nil {
+			return "", err
+		}
+
+		designations[orderID] = lotIDs
+	}
+
+	if err = rows.Err(); err != nil {
+		return "", err
+	}
+
+	changed := make(map[string]bool)
+	for _, fill := range fills {
+		tag, err := tx.Exec(context.Background(), "insert into pnl_fills (id, user_id) values ($1, $2) on conflict (id) do nothing", fill.ID, id)
+		if err != nil {
+			return "", err
+		}
+
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+
+		realized, unmatched := Match(lots, fill, method, designations[fill.OrderID])
+		for _, r := range realized {
+			_, err = tx.Exec(context.Background(), "insert into realized_gains (user_id, fill_id, lot_id, symbol, qty, cost_basis, proceeds, gain, acquired_at, sold_at, long_term) "+
+				"values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+				id, r.FillID, r.LotID, r.Symbol, r.Qty, r.CostBasis, r.Proceeds, r.Gain, r.AcquiredAt, r.SoldAt, r.LongTerm)
+			if err != nil {
+				return "", err
+			}
+
+			changed[r.LotID] = true
+		}
+
+		// A buy that covered every short opens a long lot, a sell of more than what's held opens a short one
+		if unmatched > 0 {
+			lot := Open(fill, unmatched)
+			_, err = tx.Exec(context.Background(), "insert into tax_lots (id, user_id, symbol, qty, remaining_qty, price, acquired_at) values ($1, $2, $3, $4, $5, $6, $7)",
+				lot.ID, id, lot.Symbol, lot.Qty, lot.Remaining, lot.Price, lot.AcquiredAt)
+			if err != nil {
+				return "", err
+			}
+
+			lots = append(lots, lot)
+		}
+	}
+
+	for _, lot := range lots {
+		if !changed[lot.ID] {
+			continue
+		}
+
+		_, err = tx.Exec(context.Background(), "update tax_lots set remaining_qty = $1 where id = $2", lot.Remaining, lot.ID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	_, err = tx.Exec(context.Background(), "update pnl_settings set synced_until = $1 where user_id = $2", fills[len(fills)-1].Time, id)
+	if err != nil {
+		return "", err
+	}
+
+	return method, tx.Commit(context.Background())
+}
+
+func getRealized(conn *pgx.Conn, id string, from, to *time.Time) ([]Realized, error) {
+	rows, err := conn.Query(context.Background(), "select fill_id, lot_id, symbol, qty, cost_basis, proceeds, gain, acquired_at, sold_at, long_term from realized_gains "+
+		"where user_id = $1 and ($2::timestamp is null or sold_at >= $2) and ($3::timestamp is null or sold_at < $3) order by sold_at, id", id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Realized, error) {
+		r := Realized{}
+		err := row.Scan(&r.FillID, &r.LotID, &r.Symbol, &r.Qty, &r.CostBasis, &r.Proceeds, &r.Gain, &r.AcquiredAt, &r.SoldAt, &r.LongTerm)
+		return r, err
+	})
+}
+
+// parseRange reads the from and to dates of the query. Both are optional and to is inclusive.
+func parseRange(from, to string) (*time.Time, *time.Time, error) {
+	var start, end *time.Time
+
+	if from != "" {
+		t, err := time.Parse(dateLayout, from)
+		if err != nil {
+			return nil, nil, errors.New("from should be a date in the format YYYY-MM-DD")
+		}
+		start = &t
+	}
+
+	if to != "" {
+		t, err := time.Parse(dateLayout, to)
+		if err != nil {
+			return nil, nil, errors.New("to should be a date in the format YYYY-MM-DD")
+		}
+		t = t.AddDate(0, 0, 1)
+		end = &t
+	}
+
+	if start != nil && end != nil && !start.Before(*end) {
+		return nil, nil, errors.New("from can't be after to")
+	}
+
+	return start, end, nil
+}
+
+func GetPnL(c *gin.Context) {
+	id := c.GetString("id")
+
+	from, to, err := parseRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create the tables for the tax lots", err)
+		return
+	}
+
+	method, err := Sync(conn, id)
+	if err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't update the tax lots with the fills of the account", err)
+		return
+	}
+
+	realized, err := getRealized(conn, id, from, to)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the realized gains from the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Summarize(method, realized))
+}
+
+func GetLots(c *gin.Context) {
+	id := c.GetString("id")
+	symbol := c.Query("symbol")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create the tables for the tax lots", err)
+		return
+	}
+
+	if _, err = Sync(conn, id); err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't update the tax lots with the fills of the account", err)
+		return
+	}
+
+	rows, err := conn.Query(context.Background(), "select id, symbol, qty, remaining_qty, price, acquired_at from tax_lots "+
+		"where user_id = $1 and remaining_qty <> 0 and ($2 = '' or symbol = $2) order by acquired_at", id, symbol)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the tax lots from the database", err)
+		return
+	}
+
+	lots, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Lot, error) {
+		lot := Lot{}
+		err := row.Scan(&lot.ID, &lot.Symbol, &lot.Qty, &lot.Remaining, &lot.Price, &lot.AcquiredAt)
+		return lot, err
+	})
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't read the tax lots", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lots": lots})
+}
+
+// SetMethod changes the matching method. It only applies to the sells from now on,
+// so the fills up until now are matched with the old one first.
+func SetMethod(c *gin.Context) {
+	id := c.GetString("id")
+
+	var info struct {
+		Method string `json:"method"`
+	}
+	if err := c.ShouldBindJSON(&info); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	if !ValidMethod(info.Method) {
+		ErrorExit(c, http.StatusBadRequest, "the method should be one of fifo, lifo, highest_cost or specific", nil)
+		return
+	}
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create the tables for the tax lots", err)
+		return
+	}
+
+	if _, err = Sync(conn, id); err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't update the tax lots with the fills of the account", err)
+		return
+	}
+
+	_, err = conn.Exec(context.Background(), "update pnl_settings set method = $1 where user_id = $2", info.Method, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't change the method in the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"method": info.Method})
+}
+
+// DesignateLots chooses the lots a sell order is matched to, when the specific lot method is used.
+// The designation has to be made before the order fills.
+func DesignateLots(c *gin.Context) {
+	id := c.GetString("id")
+
+	var info struct {
+		OrderID string   `json:"order_id"`
+		LotIDs  []string `json:"lot_ids"`
+	}
+	if err := c.ShouldBindJSON(&info); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	if info.OrderID == "" || len(info.LotIDs) == 0 {
+		ErrorExit(c, http.StatusBadRequest, "an order and at least one lot are required", nil)
+		return
+	}
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create the tables for the tax lots", err)
+		return
+	}
+
+	if _, err = Sync(conn, id); err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't update the tax lots with the fills of the account", err)
+		return
+	}
+
+	var count int
+	err = conn.QueryRow(context.Background(), "select count(*) from tax_lots where user_id = $1 and id = any($2) and remaining_qty <> 0", id, info.LotIDs).Scan(&count)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the tax lots from the database", err)
+		return
+	}
+
+	if count != len(info.LotIDs) {
+		ErrorExit(c, http.StatusBadRequest, "some of the lots don't exist or are already sold", nil)
+		return
+	}
+
+	_, err = conn.Exec(context.Background(), "insert into lot_designations (order_id, user_id, lot_ids) values ($1, $2, $3) "+
+		"on conflict (order_id) do update set lot_ids = excluded.lot_ids where lot_designations.user_id = excluded.user_id", info.OrderID, id, info.LotIDs)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't save the designated lots in the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order_id": info.OrderID, "lot_ids": info.LotIDs})
+}
+
+// ExportYear writes the realized gains of a tax year as a CSV with the columns of form 8949
+func ExportYear(c *gin.Context) {
+	id := c.GetString("id")
+
+	year := time.Now().UTC().Year()
+	if value := c.Query("year"); value != "" {
+		var err error
+		year, err = strconv.Atoi(value)
+		if err != nil || year < 1900 {
+			ErrorExit(c, http.StatusBadRequest, "invalid year", err)
+			return
+		}
+	}
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create the tables for the tax lots", err)
+		return
+	}
+
+	if _, err = Sync(conn, id); err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't update the tax lots with the fills of the account", err)
+		return
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+	realized, err := getRealized(conn, id, &from, &to)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the realized gains from the database", err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=realized-%d.csv", year))
+	c.Status(http.StatusOK)
+
+	if err = WriteCSV(c.Writer, realized); err != nil {
+		log.Println(err)
+	}
+}
+
+func WriteCSV(w io.Writer, realized []Realized) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{"Description", "Date acquired", "Date sold", "Proceeds", "Cost basis", "Gain or loss", "Term"})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range realized {
+		term := "short"
+		if r.LongTerm {
+			term = "long"
+		}
+
+		err = writer.Write([]string{
+			strconv.FormatFloat(r.Qty, 'f', -1, 64) + " sh. " + r.Symbol,
+			r.AcquiredAt.Format(dateLayout),
+			r.SoldAt.Format(dateLayout),
+			strconv.FormatFloat(r.Proceeds, 'f', 2, 64),
+			strconv.FormatFloat(r.CostBasis, 'f', 2, 64),
+			strconv.FormatFloat(r.Gain, 'f', 2, 64),
+			term,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,183 @@
+package pnl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 15, 0, 0, 0, time.UTC)
+}
+
+func testLots() []*Lot {
+	return []*Lot{
+		{ID: "a", Symbol: "VOO", Qty: 10, Remaining: 10, Price: 100, AcquiredAt: day(2023, time.January, 10)},
+		{ID: "b", Symbol: "VOO", Qty: 10, Remaining: 10, Price: 150, AcquiredAt: day(2024, time.March, 5)},
+		{ID: "c", Symbol: "VOO", Qty: 10, Remaining: 10, Price: 120, AcquiredAt: day(2024, time.June, 1)},
+		{ID: "d", Symbol: "BND", Qty: 5, Remaining: 5, Price: 70, AcquiredAt: day(2023, time.May, 1)},
+	}
+}
+
+func TestMatch_Methods(t *testing.T) {
+	sell := Fill{ID: "s", OrderID: "o", Symbol: "VOO", Side: "sell", Qty: 15, Price: 200, Time: day(2024, time.September, 1)}
+
+	tests := []struct {
+		method     string
+		designated []string
+		lots       []string
+	}{
+		{MethodFIFO, nil, []string{"a", "b"}},
+		{MethodLIFO, nil, []string{"c", "b"}},
+		{MethodHighestCost, nil, []string{"b", "c"}},
+		{MethodSpecific, []string{"c"}, []string{"c", "a"}},
+	}
+
+	for _, test := range tests {
+		lots := testLots()
+		realized, unmatched := Match(lots, sell, test.method, test.designated)
+		if unmatched != 0 {
+			t.Fatalf("%s: unexpected unmatched quantity %g", test.method, unmatched)
+		}
+
+		if len(realized) != len(test.lots) {
+			t.Fatalf("%s: expected %d lots, got %d", test.method, len(test.lots), len(realized))
+		}
+
+		for i, id := range test.lots {
+			if realized[i].LotID != id {
+				t.Fatalf("%s: expected lot %s at %d, got %s", test.method, id, i, realized[i].LotID)
+			}
+		}
+
+		if realized[0].Qty != 10 || realized[1].Qty != 5 {
+			t.Fatalf("%s: unexpected quantities %g and %g", test.method, realized[0].Qty, realized[1].Qty)
+		}
+	}
+}
+
+func TestMatch_UpdatesLotsAndTerms(t *testing.T) {
+	lots := testLots()
+	sell := Fill{ID: "s", Symbol: "VOO", Side: "sell", Qty: 12, Price: 110, Time: day(2024, time.February, 1)}
+
+	realized, unmatched := Match(lots, sell, MethodFIFO, nil)
+	if unmatched != 0 {
+		t.Fatalf("unexpected unmatched quantity %g", unmatched)
+	}
+
+	if lots[0].Remaining != 0 || lots[1].Remaining != 8 {
+		t.Fatalf("unexpected remaining quantities %g and %g", lots[0].Remaining, lots[1].Remaining)
+	}
+
+	if !realized[0].LongTerm || realized[1].LongTerm {
+		t.Fatal("expected the first lot to be long term and the second short term")
+	}
+
+	if realized[0].Gain != 100 || realized[1].Gain != -80 {
+		t.Fatalf("unexpected gains %g and %g", realized[0].Gain, realized[1].Gain)
+	}
+}
+
+func TestMatch_Unmatched(t *testing.T) {
+	lots := testLots()
+	sell := Fill{ID: "s", Symbol: "BND", Side: "sell", Qty: 8, Price: 75, Time: day(2024, time.February, 1)}
+
+	realized, unmatched := Match(lots, sell, MethodFIFO, nil)
+	if len(realized) != 1 || unmatched != 3 {
+		t.Fatalf("expected 3 unmatched shares, got %g", unmatched)
+	}
+}
+
+func TestMatch_ShortLots(t *testing.T) {
+	lots := testLots()
+	sell := Fill{ID: "s", Symbol: "BND", Side: "sell_short", Qty: 8, Price: 75, Time: day(2023, time.February, 1)}
+
+	_, unmatched := Match(lots, sell, MethodFIFO, nil)
+	short := Open(sell, unmatched)
+	if short.Qty != -3 || short.Remaining != -3 {
+		t.Fatalf("expected a short lot of 3 shares, got %+v", short)
+	}
+	lots = append(lots, short)
+
+	cover := Fill{ID: "c", Symbol: "BND", Side: "buy", Qty: 4, Price: 60, Time: day(2024, time.March, 1)}
+	realized, unmatched := Match(lots, cover, MethodFIFO, nil)
+	if len(realized) != 1 || realized[0].LotID != "s" || unmatched != 1 {
+		t.Fatalf("expected the short lot to be covered with 1 share left, got %+v and %g", realized, unmatched)
+	}
+
+	r := realized[0]
+	if r.Proceeds != 225 || r.CostBasis != 180 || r.Gain != 45 || r.LongTerm {
+		t.Fatalf("unexpected realized gain of the cover %+v", r)
+	}
+
+	if short.Remaining != 0 {
+		t.Fatalf("expected the short lot to be closed, got %g", short.Remaining)
+	}
+
+	if long := Open(cover, unmatched); long.Qty != 1 {
+		t.Fatalf("expected a long lot of 1 share, got %+v", long)
+	}
+}
+
+func TestLongTerm(t *testing.T) {
+	acquired := day(2023, time.January, 10)
+	if LongTerm(acquired, day(2024, time.January, 10)) {
+		t.Fatal("exactly a year isn't long term")
+	}
+
+	if !LongTerm(acquired, day(2024, time.January, 11)) {
+		t.Fatal("more than a year should be long term")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	report := Summarize(MethodFIFO, []Realized{
+		{Symbol: "VOO", Gain: 100, LongTerm: true},
+		{Symbol: "BND", Gain: -20.5},
+		{Symbol: "VOO", Gain: -30},
+	})
+
+	if report.ShortTerm != -50.5 || report.LongTerm != 100 || report.Total != 49.5 {
+		t.Fatalf("unexpected totals %+v", report)
+	}
+
+	if len(report.Symbols) != 2 || report.Symbols[0].Symbol != "BND" || report.Symbols[1].Total != 70 {
+		t.Fatalf("unexpected symbols %+v", report.Symbols)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	from, to, err := parseRange("2024-01-01", "2024-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !from.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected range %v - %v", from, to)
+	}
+
+	if _, _, err = parseRange("2024-02-01", "2024-01-01"); err == nil {
+		t.Fatal("expected an error when from is after to")
+	}
+
+	if _, _, err = parseRange("01/01/2024", ""); err == nil {
+		t.Fatal("expected an error for an invalid date")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []Realized{{
+		Symbol: "VOO", Qty: 2.5, Proceeds: 500, CostBasis: 250, Gain: 250, LongTerm: true,
+		AcquiredAt: day(2023, time.January, 10), SoldAt: day(2024, time.February, 1),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[1] != "2.5 sh. VOO,2023-01-10,2024-02-01,500.00,250.00,250.00,long" {
+		t.Fatalf("unexpected csv %q", buf.String())
+	}
+}
@@ -0,0 +1,466 @@
+package rebalance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/Phantomvv1/KayTrade/internal/trading"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Alpaca doesn't accept orders for less than $1
+const minimumNotional = 1.0
+
+// How long we wait for the sells and the covers to fill before placing the buys
+const sellTimeout = 30 * time.Second
+
+// ModelPortfolio is the target allocation of the whole account. Weights are fractions of the equity,
+// whatever isn't allocated stays in cash and symbols outside of the model have a target of 0.
+type ModelPortfolio struct {
+	Weights   map[string]float64 `json:"weights"`
+	DriftBand float64            `json:"drift_band"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+type Holding struct {
+	Symbol      string
+	Qty         float64
+	MarketValue float64
+}
+
+type Allocation struct {
+	Symbol      string  `json:"symbol"`
+	MarketValue float64 `json:"market_value"`
+	Current     float64 `json:"current"`
+	Target      float64 `json:"target"`
+	Drift       float64 `json:"drift"`
+}
+
+type Trade struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Notional float64 `json:"notional,omitempty"`
+	Qty      float64 `json:"qty,omitempty"`
+	OrderID  string  `json:"order_id,omitempty"`
+	Status   string  `json:"status,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type Proposal struct {
+	Equity      float64      `json:"equity"`
+	Cash        float64      `json:"cash"`
+	Allocations []Allocation `json:"allocations"`
+	Trades      []Trade      `json:"trades"`
+}
+
+func CreateModelPortfoliosTable(conn *pgx.Conn) error {
+	_, err := conn.Exec(context.Background(), "create table if not exists model_portfolios(user_id uuid primary key references authentication(id) on delete cascade, "+
+		"weights jsonb, drift_band double precision, updated_at timestamp default current_timestamp)")
+	return err
+}
+
+func (m *ModelPortfolio) validate() error {
+	if len(m.Weights) == 0 {
+		return errors.New("the model portfolio needs at least one symbol")
+	}
+
+	total := 0.0
+	weights := make(map[string]float64, len(m.Weights))
+	for symbol, weight := range m.Weights {
+		if symbol == "" || weight <= 0 {
+			return errors.New("every symbol in the model portfolio needs a positive weight")
+		}
+
+		weights[strings.ToUpper(symbol)] = weight
+		total += weight
+	}
+
+	if total > 1+1e-9 {
+		return errors.New("the weights of the model portfolio can't add up to more than 1")
+	}
+
+	if m.DriftBand < 0 || m.DriftBand >= 1 {
+		return errors.New("the drift band should be between 0 and 1")
+	}
+
+	m.Weights = weights
+	return nil
+}
+
+// Propose calculates the current weights and the trades needed to get back to the model portfolio.
+// Only symbols that drifted outside of the band are traded. Sells come first, as the buys are paid with them.
+// Short positions are bought back before anything else is bought, the model portfolio only holds long ones.
+func Propose(model ModelPortfolio, holdings []Holding, cash float64) Proposal {
+	equity := cash
+	values := make(map[string]Holding)
+	for _, holding := range holdings {
+		equity += holding.MarketValue
+		values[holding.Symbol] = holding
+	}
+
+	proposal := Proposal{Equity: equity, Cash: cash}
+	if equity <= 0 {
+		return proposal
+	}
+
+	symbols := make([]string, 0, len(values)+len(model.Weights))
+	for symbol := range values {
+		symbols = append(symbols, symbol)
+	}
+	for symbol := range model.Weights {
+		if _, ok := values[symbol]; !ok {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+
+	var sells, covers, buys []Trade
+	available := cash
+	for _, symbol := range symbols {
+		holding := values[symbol]
+		target := model.Weights[symbol]
+		current := holding.MarketValue / equity
+
+		proposal.Allocations = append(proposal.Allocations, Allocation{
+			Symbol:      symbol,
+			MarketValue: round(holding.MarketValue),
+			Current:     current,
+			Target:      target,
+			Drift:       current - target,
+		})
+
+		if math.Abs(current-target) <= model.DriftBand {
+			continue
+		}
+
+		// Alpaca only covers shorts in whole shares, so the short is bought back by quantity before buying the target
+		if holding.Qty < 0 {
+			covers = append(covers, Trade{Symbol: symbol, Side: "buy", Qty: -holding.Qty})
+			available += holding.MarketValue
+			if target*equity >= minimumNotional {
+				buys = append(buys, Trade{Symbol: symbol, Side: "buy", Notional: target * equity})
+			}
+
+			continue
+		}
+
+		difference := target*equity - holding.MarketValue
+		if math.Abs(difference) < minimumNotional {
+			continue
+		}
+
+		if difference > 0 {
+			buys = append(buys, Trade{Symbol: symbol, Side: "buy", Notional: difference})
+			continue
+		}
+
+		// Closing the whole position by quantity doesn't leave a fraction of a share behind
+		if target == 0 {
+			sells = append(sells, Trade{Symbol: symbol, Side: "sell", Qty: holding.Qty})
+		} else {
+			sells = append(sells, Trade{Symbol: symbol, Side: "sell", Notional: round(-difference)})
+		}
+		available -= difference
+	}
+
+	// When some symbols are inside the band the buys can cost more than what's available
+	needed := 0.0
+	for _, buy := range buys {
+		needed += buy.Notional
+	}
+
+	scale := 1.0
+	if needed > available && needed > 0 {
+		scale = max(available, 0) / needed
+	}
+
+	trades := append(sells, covers...)
+	for _, buy := range buys {
+		buy.Notional = round(buy.Notional * scale)
+		if buy.Notional >= minimumNotional {
+			trades = append(trades, buy)
+		}
+	}
+
+	proposal.Trades = trades
+	return proposal
+}
+
+func round(value float64) float64 {
+	return math.Floor(value*100) / 100
+}
+
+type position struct {
+	Symbol      string `json:"symbol"`
+	Qty         string `json:"qty"`
+	MarketValue string `json:"market_value"`
+}
+
+type account struct {
+	Cash string `json:"cash"`
+}
+
+func getHoldings(id string) ([]Holding, float64, error) {
+	headers := BasicAuth()
+
+	positions, err := SendRequest[[]position](http.MethodGet, BaseURL+Trading+id+"/positions", nil, nil, headers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	acc, err := SendRequest[account](http.MethodGet, BaseURL+Trading+id+"/account", nil, nil, headers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cash, err := strconv.ParseFloat(acc.Cash, 64)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	holdings := make([]Holding, 0, len(positions))
+	for _, p := range positions {
+		qty, err := strconv.ParseFloat(p.Qty, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		marketValue, err := strconv.ParseFloat(p.MarketValue, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		holdings = append(holdings, Holding{Symbol: p.Symbol, Qty: qty, MarketValue: marketValue})
+	}
+
+	return holdings, cash, nil
+}
+
+func getModelPortfolio(conn *pgx.Conn, id string) (*ModelPortfolio, error) {
+	model := ModelPortfolio{}
+	err := conn.QueryRow(context.Background(), "select weights, drift_band, updated_at from model_portfolios where user_id = $1", id).
+		Scan(&model.Weights, &model.DriftBand, &model.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model, nil
+}
+
+func SetModelPortfolio(c *gin.Context) {
+	id := c.GetString("id")
+
+	model := ModelPortfolio{}
+	if err := c.ShouldBindJSON(&model); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	if err := model.validate(); err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = CreateModelPortfoliosTable(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create a table for the model portfolios", err)
+		return
+	}
+
+	model.UpdatedAt = time.Now().UTC()
+	_, err = conn.Exec(context.Background(), "insert into model_portfolios (user_id, weights, drift_band, updated_at) values ($1, $2, $3, $4) "+
+		"on conflict (user_id) do update set weights = excluded.weights, drift_band = excluded.drift_band, updated_at = excluded.updated_at",
+		id, model.Weights, model.DriftBand, model.UpdatedAt)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't save the model portfolio in the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model)
+}
+
+func GetModelPortfolio(c *gin.Context) {
+	id := c.GetString("id")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = CreateModelPortfoliosTable(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create a table for the model portfolios", err)
+		return
+	}
+
+	model, err := getModelPortfolio(conn, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		ErrorExit(c, http.StatusNotFound, "you haven't set a model portfolio yet", nil)
+		return
+	} else if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the model portfolio from the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model)
+}
+
+// Rebalance previews the trades when dry_run is set and places them otherwise
+func Rebalance(c *gin.Context) {
+	id := c.GetString("id")
+	dryRun := c.Query("dry_run") == "true"
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = CreateModelPortfoliosTable(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create a table for the model portfolios", err)
+		return
+	}
+
+	model, err := getModelPortfolio(conn, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		ErrorExit(c, http.StatusNotFound, "you haven't set a model portfolio yet", nil)
+		return
+	} else if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the model portfolio from the database", err)
+		return
+	}
+
+	holdings, cash, err := getHoldings(id)
+	if err != nil {
+		RequestExit(c, nil, err, "couldn't get the positions of the account")
+		return
+	}
+
+	proposal := Propose(*model, holdings, cash)
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "proposal": proposal})
+		return
+	}
+
+	if err = trading.CreateOrdersTable(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create a table for the orders", err)
+		return
+	}
+
+	var placed []string
+	for i := range proposal.Trades {
+		trade := &proposal.Trades[i]
+		if waits(proposal.Trades, i) && len(placed) > 0 {
+			waitForFills(id, placed)
+			placed = nil
+		}
+
+		placeTrade(conn, id, trade)
+		if trade.OrderID != "" {
+			placed = append(placed, trade.OrderID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dry_run": false, "proposal": proposal})
+}
+
+// The trades of a proposal come in groups, the sells, the covers of the shorts and the buys
+func (t Trade) group() int {
+	switch {
+	case t.Side == "sell":
+		return 0
+	case t.Qty != 0:
+		return 1
+	}
+
+	return 2
+}
+
+// waits tells if the trade starts a new group, which waits for the orders of the previous groups to fill.
+// The covers are paid with the sells and a short has to be covered before its target is bought.
+func waits(trades []Trade, i int) bool {
+	return i > 0 && trades[i].group() != trades[i-1].group()
+}
+
+func placeTrade(conn *pgx.Conn, id string, trade *Trade) {
+	order := map[string]any{
+		"symbol":        trade.Symbol,
+		"side":          trade.Side,
+		"type":          "market",
+		"time_in_force": "day",
+	}
+
+	if trade.Qty != 0 {
+		order["qty"] = strconv.FormatFloat(trade.Qty, 'f', -1, 64)
+	} else {
+		order["notional"] = strconv.FormatFloat(trade.Notional, 'f', 2, 64)
+	}
+
+	reqBody, err := json.Marshal(order)
+	if err != nil {
+		trade.Error = err.Error()
+		return
+	}
+
+	body, err := trading.SubmitOrder(id, bytes.NewReader(reqBody))
+	if err != nil {
+		trade.Error = err.Error()
+		return
+	}
+
+	trade.OrderID, _ = body["id"].(string)
+	trade.Status, _ = body["status"].(string)
+
+	if err = trading.SaveOrder(conn, id, body); err != nil {
+		trade.Error = "the order was placed but couldn't be saved"
+	}
+}
+
+// The buys are paid with the money from the sells and a short is covered before it's bought, so we give
+// the orders a chance to fill first
+func waitForFills(id string, orderIDs []string) {
+	headers := BasicAuth()
+	deadline := time.Now().Add(sellTimeout)
+
+	pending := orderIDs
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		var stillPending []string
+		for _, orderID := range pending {
+			order, err := SendRequest[map[string]any](http.MethodGet, BaseURL+Trading+id+"/orders/"+orderID, nil, nil, headers)
+			if err != nil {
+				continue
+			}
+
+			switch order["status"] {
+			case "filled", "canceled", "expired", "rejected":
+			default:
+				stillPending = append(stillPending, orderID)
+			}
+		}
+
+		pending = stillPending
+		if len(pending) > 0 {
+			time.Sleep(time.Second)
+		}
+	}
+}
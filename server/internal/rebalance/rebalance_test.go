@@ -0,0 +1,162 @@
+package rebalance
+
+import (
+	"math"
+	"testing"
+)
+
+func findTrade(trades []Trade, symbol string) *Trade {
+	for i := range trades {
+		if trades[i].Symbol == symbol {
+			return &trades[i]
+		}
+	}
+
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	model := ModelPortfolio{Weights: map[string]float64{"voo": 0.6, "BND": 0.4}, DriftBand: 0.05}
+	if err := model.validate(); err != nil {
+		t.Fatalf("expected a valid model, got %v", err)
+	}
+
+	if _, ok := model.Weights["VOO"]; !ok {
+		t.Fatal("expected the symbols to be upper cased")
+	}
+
+	invalid := []ModelPortfolio{
+		{},
+		{Weights: map[string]float64{"VOO": 0.7, "BND": 0.4}},
+		{Weights: map[string]float64{"VOO": -0.1}},
+		{Weights: map[string]float64{"VOO": 0.5}, DriftBand: 1},
+	}
+
+	for _, m := range invalid {
+		if err := m.validate(); err == nil {
+			t.Fatalf("expected %+v to be invalid", m)
+		}
+	}
+}
+
+func TestPropose_SellsBeforeBuys(t *testing.T) {
+	model := ModelPortfolio{Weights: map[string]float64{"VOO": 0.5, "BND": 0.5}, DriftBand: 0.02}
+	holdings := []Holding{
+		{Symbol: "VOO", Qty: 2, MarketValue: 800},
+		{Symbol: "BND", Qty: 3, MarketValue: 200},
+	}
+
+	proposal := Propose(model, holdings, 0)
+
+	if proposal.Equity != 1000 {
+		t.Fatalf("expected equity of 1000, got %f", proposal.Equity)
+	}
+
+	if len(proposal.Trades) != 2 {
+		t.Fatalf("expected 2 trades, got %+v", proposal.Trades)
+	}
+
+	if proposal.Trades[0].Side != "sell" || proposal.Trades[0].Symbol != "VOO" || proposal.Trades[0].Notional != 300 {
+		t.Fatalf("expected to sell $300 of VOO first, got %+v", proposal.Trades[0])
+	}
+
+	if proposal.Trades[1].Side != "buy" || proposal.Trades[1].Symbol != "BND" || proposal.Trades[1].Notional != 300 {
+		t.Fatalf("expected to buy $300 of BND, got %+v", proposal.Trades[1])
+	}
+}
+
+func TestPropose_DriftBand(t *testing.T) {
+	model := ModelPortfolio{Weights: map[string]float64{"VOO": 0.5, "BND": 0.5}, DriftBand: 0.05}
+	holdings := []Holding{
+		{Symbol: "VOO", MarketValue: 520},
+		{Symbol: "BND", MarketValue: 480},
+	}
+
+	if proposal := Propose(model, holdings, 0); len(proposal.Trades) != 0 {
+		t.Fatalf("expected no trades inside the band, got %+v", proposal.Trades)
+	}
+}
+
+func TestPropose_ClosesSymbolsOutsideTheModel(t *testing.T) {
+	model := ModelPortfolio{Weights: map[string]float64{"VOO": 1}}
+	holdings := []Holding{{Symbol: "TSLA", Qty: 1.5, MarketValue: 300}}
+
+	proposal := Propose(model, holdings, 100)
+
+	sell := findTrade(proposal.Trades, "TSLA")
+	if sell == nil || sell.Side != "sell" || sell.Qty != 1.5 {
+		t.Fatalf("expected the whole TSLA position to be sold, got %+v", sell)
+	}
+
+	buy := findTrade(proposal.Trades, "VOO")
+	if buy == nil || buy.Notional != 400 {
+		t.Fatalf("expected to buy $400 of VOO, got %+v", buy)
+	}
+}
+
+func TestPropose_CoversShorts(t *testing.T) {
+	model := ModelPortfolio{Weights: map[string]float64{"VOO": 1}}
+	holdings := []Holding{{Symbol: "TSLA", Qty: -2, MarketValue: -300}}
+
+	proposal := Propose(model, holdings, 1300)
+
+	if len(proposal.Trades) != 2 {
+		t.Fatalf("expected to cover TSLA and buy VOO, got %+v", proposal.Trades)
+	}
+
+	cover := proposal.Trades[0]
+	if cover.Symbol != "TSLA" || cover.Side != "buy" || cover.Qty != 2 || cover.Notional != 0 {
+		t.Fatalf("expected to buy back 2 shares of TSLA first, got %+v", cover)
+	}
+
+	buy := proposal.Trades[1]
+	if buy.Symbol != "VOO" || buy.Side != "buy" || buy.Notional != 1000 {
+		t.Fatalf("expected to buy $1000 of VOO, got %+v", buy)
+	}
+}
+
+func TestWaits_ForTheCoversBeforeTheBuys(t *testing.T) {
+	model := ModelPortfolio{Weights: map[string]float64{"TSLA": 0.5, "VOO": 0.5}}
+	holdings := []Holding{{Symbol: "TSLA", Qty: -2, MarketValue: -300}, {Symbol: "AAPL", Qty: 1, MarketValue: 500}}
+
+	proposal := Propose(model, holdings, 1000)
+
+	expected := []struct {
+		symbol string
+		waits  bool
+	}{
+		{"AAPL", false},
+		{"TSLA", true},
+		{"TSLA", true},
+		{"VOO", false},
+	}
+
+	if len(proposal.Trades) != len(expected) {
+		t.Fatalf("expected a sell, a cover and two buys, got %+v", proposal.Trades)
+	}
+
+	for i, e := range expected {
+		if proposal.Trades[i].Symbol != e.symbol || waits(proposal.Trades, i) != e.waits {
+			t.Fatalf("expected %s to wait %v, got %+v at %d", e.symbol, e.waits, proposal.Trades[i], i)
+		}
+	}
+}
+
+func TestPropose_ScalesBuysToAvailableCash(t *testing.T) {
+	// BND is inside the band, so only the cash can pay for VOO and SCHD
+	model := ModelPortfolio{Weights: map[string]float64{"VOO": 0.4, "SCHD": 0.4, "BND": 0.2}, DriftBand: 0.25}
+	holdings := []Holding{{Symbol: "BND", MarketValue: 300}}
+
+	proposal := Propose(model, holdings, 700)
+
+	total := 0.0
+	for _, trade := range proposal.Trades {
+		if trade.Side == "buy" {
+			total += trade.Notional
+		}
+	}
+
+	if math.Abs(total-700) > 0.01 {
+		t.Fatalf("expected the buys to use the $700 of cash, got %f", total)
+	}
+}
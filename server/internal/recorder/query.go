@@ -0,0 +1,187 @@
+package recorder
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// The most ticks of every symbol a single request returns when there's no limit
+const defaultLimit = 1000
+
+// Blocks are flushed every few seconds, so the ticks of one never reach further back than this from its start
+// unless they arrived out of order
+const blockReach = time.Minute
+
+// Ticks returns the recorded ticks of the kind of every symbol between start and end, oldest first
+func Ticks(kind string, symbols []string, start, end time.Time) (map[string][]map[string]any, error) {
+	return queryTicks(kind, symbols, start, end, 0, false)
+}
+
+// queryTicks returns at most limit ticks of every symbol, all of them when limit is 0, sorted oldest first or
+// newest first when descending. The blocks are read in order and only until the ones left can't have any of
+// the ticks that are kept, so a long range doesn't have to fit in memory.
+func queryTicks(kind string, symbols []string, start, end time.Time, limit int, descending bool) (map[string][]map[string]any, error) {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+
+	if err = CreateRecordedTables(conn); err != nil {
+		return nil, err
+	}
+
+	ticks := make(map[string][]map[string]any, len(symbols))
+	for _, symbol := range symbols {
+		if ticks[symbol], err = symbolTicks(conn, kind, symbol, start, end, limit, descending); err != nil {
+			return nil, err
+		}
+	}
+
+	return ticks, nil
+}
+
+func symbolTicks(conn *pgx.Conn, kind, symbol string, start, end time.Time, limit int, descending bool) ([]map[string]any, error) {
+	order := "start_time"
+	if descending {
+		order = "end_time desc"
+	}
+
+	rows, err := conn.Query(context.Background(), "select start_time, end_time, data from "+table(kind)+
+		" where symbol = $1 and start_time >= $2 and start_time <= $3 and end_time >= $4 order by "+order,
+		symbol, start.Add(-blockReach), end, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []map[string]any{}
+	for rows.Next() {
+		var blockStart, blockEnd time.Time
+		var data []byte
+		if err := rows.Scan(&blockStart, &blockEnd, &data); err != nil {
+			return nil, err
+		}
+
+		if limit > 0 && len(list) == limit && beyond(list[limit-1], blockStart, blockEnd, descending) {
+			break
+		}
+
+		decoded, err := decompress(data)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, within(decoded, start, end)...)
+		if limit > 0 {
+			list = keep(list, limit, descending)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keep(list, limit, descending), nil
+}
+
+// beyond tells if the block comes after the last tick that is kept, so neither it nor the ones after it can
+// have any of the ticks that are kept
+func beyond(last map[string]any, blockStart, blockEnd time.Time, descending bool) bool {
+	at, err := time.Parse(time.RFC3339Nano, stringOf(last["t"]))
+	if err != nil {
+		return false
+	}
+
+	if descending {
+		return blockEnd.Before(at)
+	}
+
+	return blockStart.After(at)
+}
+
+// within keeps the ticks between start and end
+func within(ticks []map[string]any, start, end time.Time) []map[string]any {
+	kept := ticks[:0]
+	for _, tick := range ticks {
+		at, err := time.Parse(time.RFC3339Nano, stringOf(tick["t"]))
+		if err != nil || at.Before(start) || at.After(end) {
+			continue
+		}
+
+		kept = append(kept, tick)
+	}
+
+	return kept
+}
+
+// keep sorts the ticks oldest first, or newest first when descending, and keeps the first limit of them.
+// A limit of 0 keeps all of them.
+func keep(ticks []map[string]any, limit int, descending bool) []map[string]any {
+	slices.SortStableFunc(ticks, func(a, b map[string]any) int {
+		if descending {
+			return strings.Compare(stringOf(b["t"]), stringOf(a["t"]))
+		}
+
+		return strings.Compare(stringOf(a["t"]), stringOf(b["t"]))
+	})
+
+	if limit > 0 {
+		return ticks[:min(limit, len(ticks))]
+	}
+
+	return ticks
+}
+
+// GetRecorded returns the trades, quotes or minute bars the recorder persisted for the symbols, in the form of
+// the messages of the stream, so a client can rebuild its live chart after reconnecting. The ticks of every
+// symbol are sorted oldest first unless sort=desc and there are at most limit of them.
+func GetRecorded(c *gin.Context) {
+	kind := c.DefaultQuery("kind", "trades")
+	if !slices.Contains([]string{"trades", "quotes", "bars"}, kind) {
+		ErrorExit(c, http.StatusBadRequest, "the kind should be trades, quotes or bars", nil)
+		return
+	}
+
+	query, _ := url.ParseQuery("symbols=" + c.GetString("symbols") + c.GetString("start") + c.GetString("params"))
+
+	start, err := ParseTime(query.Get("start"))
+	if err != nil {
+		ErrorExit(c, http.StatusBadRequest, "start was incorrectly provided", err)
+		return
+	}
+
+	end := time.Now().UTC()
+	if query.Has("end") {
+		if end, err = ParseTime(query.Get("end")); err != nil {
+			ErrorExit(c, http.StatusBadRequest, "end was incorrectly provided", err)
+			return
+		}
+	}
+
+	limit := defaultLimit
+	if query.Has("limit") {
+		limit, _ = strconv.Atoi(query.Get("limit"))
+	}
+
+	symbols := strings.Split(strings.ToUpper(query.Get("symbols")), ",")
+
+	ticks, err := queryTicks(kind, symbols, start, end, limit, query.Get("sort") == "desc")
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the recorded market data", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{kind: ticks})
+}
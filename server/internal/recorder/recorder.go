@@ -0,0 +1,234 @@
+package recorder
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
+)
+
+const (
+	// How often the ticks of every symbol are written, which is also the longest a block can span
+	flushInterval = 5 * time.Second
+	// The most ticks of a symbol in a single block
+	blockSize = 2000
+	// How often the partitions that are past their retention are dropped
+	retentionInterval = time.Hour
+	// The number of ticks waiting to be recorded before the hub starts dropping them
+	queueSize = 4096
+)
+
+// The kinds of ticks that are recorded, by the type of their messages
+var kinds = map[string]string{
+	"t": marketdata.Trades,
+	"q": marketdata.Quotes,
+	"b": marketdata.Bars,
+}
+
+// How long every kind is kept by default. There are far more quotes than trades and far fewer minute bars than
+// either, so they're kept for different amounts of time.
+var defaultRetention = map[string]time.Duration{
+	marketdata.Trades: 30 * 24 * time.Hour,
+	marketdata.Quotes: 7 * 24 * time.Hour,
+	marketdata.Bars:   365 * 24 * time.Hour,
+}
+
+// Enabled reports whether the recorder should run, which is set with RECORDER=true
+func Enabled() bool {
+	return os.Getenv("RECORDER") == "true"
+}
+
+// block is a run of ticks of a symbol, oldest first, which is stored compressed as a single row
+type block struct {
+	kind   string
+	symbol string
+	start  time.Time
+	end    time.Time
+	ticks  []map[string]any
+}
+
+// Recorder persists the trades, quotes and minute bars that go through the hub. Whatever the clients watch is
+// recorded, and the symbols in RECORDER_SYMBOLS are subscribed to so they're recorded even when no one watches
+// them. The ticks are grouped into blocks per symbol, which are written to the database by a goroutine of their
+// own, so a slow database never holds up the hub.
+type Recorder struct {
+	hub       *marketdata.Hub
+	symbols   []string
+	retention map[string]time.Duration
+	ticks     chan map[string]any
+	blocks    chan block
+	buffers   map[string]map[string]*block // kind -> symbol -> the block being filled
+	store     func(b block) error
+	prune     func(retention map[string]time.Duration, now time.Time) error
+}
+
+// NewRecorder creates a recorder of the updates of the hub. It has to be created before the hub runs.
+func NewRecorder(hub *marketdata.Hub) (*Recorder, error) {
+	retention, err := parseRetention(os.Getenv("RECORDER_RETENTION"))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		hub:       hub,
+		symbols:   parseSymbols(os.Getenv("RECORDER_SYMBOLS")),
+		retention: retention,
+		ticks:     make(chan map[string]any, queueSize),
+		blocks:    make(chan block, 256),
+		buffers:   make(map[string]map[string]*block),
+		store:     (&database{}).store,
+		prune:     prune,
+	}
+
+	hub.Record(r.ticks)
+
+	return r, nil
+}
+
+// parseSymbols reads a comma separated list of symbols, like AAPL,MSFT,BTC/USD
+func parseSymbols(value string) []string {
+	var symbols []string
+	for _, symbol := range strings.Split(value, ",") {
+		if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols
+}
+
+// parseRetention reads how many days every kind is kept for, like trades=30,quotes=7,bars=365. The kinds that
+// aren't given keep their default.
+func parseRetention(value string) (map[string]time.Duration, error) {
+	retention := make(map[string]time.Duration, len(defaultRetention))
+	for kind, duration := range defaultRetention {
+		retention[kind] = duration
+	}
+
+	for _, policy := range strings.Split(value, ",") {
+		if strings.TrimSpace(policy) == "" {
+			continue
+		}
+
+		kind, days, ok := strings.Cut(strings.TrimSpace(policy), "=")
+		if _, known := defaultRetention[kind]; !ok || !known {
+			return nil, errors.New("Error the retention should look like trades=30,quotes=7,bars=365")
+		}
+
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return nil, errors.New("Error the retention of " + kind + " should be a positive number of days")
+		}
+
+		retention[kind] = time.Duration(n) * 24 * time.Hour
+	}
+
+	return retention, nil
+}
+
+func (r *Recorder) Run() {
+	go r.write()
+
+	for _, symbol := range r.symbols {
+		user, updates := marketdata.NewSubscriber(symbol, marketdata.Trades, marketdata.Quotes, marketdata.Bars)
+		go func() { r.hub.Register <- user }()
+
+		// The hub already sends every tick to the recorder, so the subscriber only keeps the symbol subscribed
+		go func() {
+			for range updates {
+			}
+		}()
+	}
+
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+
+	retention := time.NewTicker(retentionInterval)
+	defer retention.Stop()
+
+	for {
+		select {
+		case tick := <-r.ticks:
+			r.add(tick)
+		case <-flush.C:
+			r.flush()
+		case now := <-retention.C:
+			go func() {
+				if err := r.prune(r.retention, now.UTC()); err != nil {
+					log.Println(err)
+				}
+			}()
+		}
+	}
+}
+
+// add puts the tick in the block of its symbol, which is written once it's full
+func (r *Recorder) add(tick map[string]any) {
+	kind := kinds[stringOf(tick["T"])]
+	symbol := stringOf(tick["S"])
+	at, err := time.Parse(time.RFC3339Nano, stringOf(tick["t"]))
+	if kind == "" || symbol == "" || err != nil {
+		return
+	}
+
+	if r.buffers[kind] == nil {
+		r.buffers[kind] = make(map[string]*block)
+	}
+
+	b := r.buffers[kind][symbol]
+	if b == nil {
+		b = &block{kind: kind, symbol: symbol, start: at, end: at}
+		r.buffers[kind][symbol] = b
+	}
+
+	b.ticks = append(b.ticks, tick)
+	if at.Before(b.start) {
+		b.start = at
+	}
+	if at.After(b.end) {
+		b.end = at
+	}
+
+	if len(b.ticks) >= blockSize {
+		r.send(*b)
+		delete(r.buffers[kind], symbol)
+	}
+}
+
+// flush sends the blocks of every symbol to be written
+func (r *Recorder) flush() {
+	for kind, symbols := range r.buffers {
+		for symbol, b := range symbols {
+			r.send(*b)
+			delete(symbols, symbol)
+		}
+
+		delete(r.buffers, kind)
+	}
+}
+
+// send never waits on the database. The blocks it can't keep up with are dropped, like the hub drops the ticks.
+func (r *Recorder) send(b block) {
+	select {
+	case r.blocks <- b:
+	default:
+		log.Println("Dropping a block of " + strconv.Itoa(len(b.ticks)) + " " + b.kind + " of " + b.symbol + " since the database couldn't keep up")
+	}
+}
+
+func (r *Recorder) write() {
+	for b := range r.blocks {
+		if err := r.store(b); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func stringOf(value any) string {
+	s, _ := value.(string)
+	return s
+}
@@ -0,0 +1,156 @@
+package recorder
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestRecorder() *Recorder {
+	return &Recorder{
+		blocks:  make(chan block, 8),
+		buffers: make(map[string]map[string]*block),
+	}
+}
+
+func tick(kind, symbol, at string) map[string]any {
+	return map[string]any{"T": kind, "S": symbol, "t": at, "p": 100.0}
+}
+
+func TestRecorder_GroupsTicksIntoBlocks(t *testing.T) {
+	r := newTestRecorder()
+
+	r.add(tick("t", "AAPL", "2025-01-02T14:30:01Z"))
+	r.add(tick("t", "AAPL", "2025-01-02T14:30:00.5Z"))
+	r.add(tick("q", "AAPL", "2025-01-02T14:30:02Z"))
+	r.add(tick("s", "AAPL", "2025-01-02T14:30:02Z"))
+	r.add(tick("t", "MSFT", "not a time"))
+
+	if len(r.blocks) != 0 {
+		t.Fatal("expected nothing to be written before the flush")
+	}
+
+	r.flush()
+
+	if len(r.blocks) != 2 {
+		t.Fatalf("expected a block of trades and one of quotes, got %d", len(r.blocks))
+	}
+
+	for range 2 {
+		b := <-r.blocks
+		if b.kind == "quotes" {
+			continue
+		}
+
+		if b.symbol != "AAPL" || len(b.ticks) != 2 || b.start.Format(time.RFC3339Nano) != "2025-01-02T14:30:00.5Z" {
+			t.Fatalf("unexpected block %+v", b)
+		}
+	}
+
+	if len(r.buffers) != 0 {
+		t.Fatal("expected the buffers to be empty after the flush")
+	}
+}
+
+func TestRecorder_WritesFullBlocks(t *testing.T) {
+	r := newTestRecorder()
+
+	at := time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC)
+	for i := range blockSize + 1 {
+		r.add(tick("t", "AAPL", at.Add(time.Duration(i)*time.Millisecond).Format(time.RFC3339Nano)))
+	}
+
+	if len(r.blocks) != 1 {
+		t.Fatalf("expected a full block, got %d", len(r.blocks))
+	}
+
+	if b := <-r.blocks; len(b.ticks) != blockSize {
+		t.Fatalf("expected %d ticks, got %d", blockSize, len(b.ticks))
+	}
+
+	if len(r.buffers["trades"]["AAPL"].ticks) != 1 {
+		t.Fatal("expected the last tick to start a new block")
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	retention, err := parseRetention("trades=10, bars=100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retention["trades"] != 10*24*time.Hour || retention["bars"] != 100*24*time.Hour || retention["quotes"] != defaultRetention["quotes"] {
+		t.Fatalf("unexpected retention %v", retention)
+	}
+
+	for _, invalid := range []string{"trades", "auctions=5", "quotes=0", "bars=a"} {
+		if _, err := parseRetention(invalid); err == nil {
+			t.Fatalf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	ticks := []map[string]any{tick("t", "AAPL", "2025-01-02T14:30:00Z"), tick("t", "AAPL", "2025-01-02T14:30:01Z")}
+
+	data, err := compress(ticks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decompress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded) != 2 || decoded[1]["t"] != "2025-01-02T14:30:01Z" || decoded[0]["p"] != 100.0 {
+		t.Fatalf("unexpected ticks %v", decoded)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	partitions := []string{"recorded_trades_20250101", "recorded_trades_20250108", "recorded_trades_20250110", "recorded_quotes_20240101", "recorded_trades_default"}
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	names := expired(partitions, "trades", 2*24*time.Hour, now)
+	if len(names) != 1 || names[0] != "recorded_trades_20250101" {
+		t.Fatalf("unexpected expired partitions %v", names)
+	}
+
+	// A day is dropped as soon as the whole of it is older than the retention
+	names = expired(partitions, "trades", 24*time.Hour+12*time.Hour, now)
+	if len(names) != 2 {
+		t.Fatalf("unexpected expired partitions %v", names)
+	}
+}
+
+func TestWithinAndKeep(t *testing.T) {
+	start := time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC)
+	ticks := within([]map[string]any{
+		tick("t", "AAPL", "2025-01-02T14:29:59Z"),
+		tick("t", "AAPL", "2025-01-02T14:30:00Z"),
+		tick("t", "AAPL", "2025-01-02T14:30:01Z"),
+		tick("t", "AAPL", "2025-01-02T14:30:02Z"),
+	}, start, start.Add(time.Second))
+
+	if len(ticks) != 2 {
+		t.Fatalf("expected 2 ticks, got %v", ticks)
+	}
+
+	if kept := keep(ticks, 1, true); len(kept) != 1 || kept[0]["t"] != "2025-01-02T14:30:01Z" {
+		t.Fatalf("expected the newest tick, got %v", kept)
+	}
+}
+
+func TestBeyond(t *testing.T) {
+	last := tick("t", "AAPL", "2025-01-02T14:30:00Z")
+	at := time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC)
+
+	// Oldest first the blocks that start after the last kept tick are left out, newest first the ones that end before it
+	if !beyond(last, at.Add(time.Second), at.Add(time.Minute), false) || beyond(last, at, at.Add(time.Minute), false) {
+		t.Fatal("unexpected result for the blocks oldest first")
+	}
+
+	if !beyond(last, at.Add(-time.Minute), at.Add(-time.Second), true) || beyond(last, at.Add(-time.Minute), at, true) {
+		t.Fatal("unexpected result for the blocks newest first")
+	}
+}
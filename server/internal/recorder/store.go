@@ -0,0 +1,195 @@
+package recorder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const partitionLayout = "20060102"
+
+// table is where the ticks of the kind are recorded, like recorded_trades
+func table(kind string) string {
+	return "recorded_" + kind
+}
+
+// partition is the table of the ticks of the kind that were recorded on the day, like recorded_trades_20250102
+func partition(kind string, day time.Time) string {
+	return table(kind) + "_" + day.UTC().Format(partitionLayout)
+}
+
+// CreateRecordedTables creates the tables of the recorded ticks. They're partitioned by the day of their blocks,
+// so the days that are past their retention are dropped at once.
+func CreateRecordedTables(conn *pgx.Conn) error {
+	for _, kind := range kinds {
+		_, err := conn.Exec(context.Background(), "create table if not exists "+table(kind)+"(symbol text not null, "+
+			"start_time timestamptz not null, end_time timestamptz not null, count integer, data bytea) partition by range (start_time)")
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.Exec(context.Background(), "create index if not exists "+table(kind)+"_symbol_start_time on "+table(kind)+"(symbol, start_time)")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createPartition(conn *pgx.Conn, kind string, day time.Time) error {
+	day = day.UTC().Truncate(24 * time.Hour)
+
+	_, err := conn.Exec(context.Background(), "create table if not exists "+partition(kind, day)+" partition of "+table(kind)+
+		" for values from ('"+day.Format(time.RFC3339)+"') to ('"+day.AddDate(0, 0, 1).Format(time.RFC3339)+"')")
+	return err
+}
+
+// compress encodes the ticks as JSON and gzips them. Consecutive ticks of a symbol repeat most of their fields,
+// so they shrink several times over.
+func compress(ticks []map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(w).Encode(ticks); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]map[string]any, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ticks []map[string]any
+	err = json.Unmarshal(raw, &ticks)
+	return ticks, err
+}
+
+// database writes the blocks over a single connection, which is opened again after an error
+type database struct {
+	conn       *pgx.Conn
+	partitions map[string]bool
+}
+
+func (d *database) store(b block) error {
+	if d.conn == nil || d.conn.IsClosed() {
+		conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+		if err != nil {
+			return err
+		}
+
+		if err = CreateRecordedTables(conn); err != nil {
+			conn.Close(context.Background())
+			return err
+		}
+
+		d.conn = conn
+		d.partitions = make(map[string]bool)
+	}
+
+	err := d.insert(b)
+	if err != nil {
+		d.conn.Close(context.Background())
+	}
+
+	return err
+}
+
+func (d *database) insert(b block) error {
+	name := partition(b.kind, b.start)
+	if !d.partitions[name] {
+		if err := createPartition(d.conn, b.kind, b.start); err != nil {
+			return err
+		}
+
+		d.partitions[name] = true
+	}
+
+	data, err := compress(b.ticks)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.conn.Exec(context.Background(), "insert into "+table(b.kind)+"(symbol, start_time, end_time, count, data) values ($1, $2, $3, $4, $5)",
+		b.symbol, b.start, b.end, len(b.ticks), data)
+	return err
+}
+
+// expired returns the partitions whose whole day is older than the retention
+func expired(partitions []string, kind string, retention time.Duration, now time.Time) []string {
+	cutoff := now.Add(-retention)
+
+	var names []string
+	for _, name := range partitions {
+		suffix, ok := strings.CutPrefix(name, table(kind)+"_")
+		if !ok {
+			continue
+		}
+
+		day, err := time.Parse(partitionLayout, suffix)
+		if err != nil {
+			continue
+		}
+
+		if !day.AddDate(0, 0, 1).After(cutoff) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// prune drops the partitions of every kind that are past its retention
+func prune(retention map[string]time.Duration, now time.Time) error {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if err = CreateRecordedTables(conn); err != nil {
+		return err
+	}
+
+	for kind, duration := range retention {
+		rows, err := conn.Query(context.Background(), "select c.relname from pg_inherits i join pg_class c on c.oid = i.inhrelid "+
+			"join pg_class p on p.oid = i.inhparent where p.relname = $1", table(kind))
+		if err != nil {
+			return err
+		}
+
+		partitions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+
+		for _, name := range expired(partitions, kind, duration, now) {
+			if _, err := conn.Exec(context.Background(), "drop table if exists "+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
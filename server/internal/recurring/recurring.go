@@ -0,0 +1,413 @@
+package recurring
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+const (
+	StatusActive   = "active"
+	StatusPaused   = "paused"
+	StatusCanceled = "canceled"
+)
+
+// Plans are executed during the core session of this market
+const market = "NYSE"
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Plan buys a fixed dollar amount of a symbol every week or every month
+type Plan struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Symbol     string     `json:"symbol"`
+	Notional   float64    `json:"notional"`
+	Frequency  string     `json:"frequency"`
+	Weekday    string     `json:"weekday,omitempty"`
+	DayOfMonth int        `json:"day_of_month,omitempty"`
+	Status     string     `json:"status"`
+	NextRunAt  *time.Time `json:"next_run_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type Execution struct {
+	ID         string    `json:"id"`
+	PlanID     string    `json:"plan_id"`
+	OrderID    string    `json:"order_id"`
+	Notional   float64   `json:"notional"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error"`
+	ExecutedAt time.Time `json:"executed_at"`
+}
+
+func CreateRecurringPlansTable(conn *pgx.Conn) error {
+	_, err := conn.Exec(context.Background(), "create table if not exists recurring_plans(id uuid primary key default gen_random_uuid(), "+
+		"user_id uuid references authentication(id) on delete cascade, symbol text, notional double precision, frequency text, weekday text default '', "+
+		"day_of_month int default 0, status text, next_run_at timestamp, created_at timestamp default current_timestamp)")
+	return err
+}
+
+func CreateRecurringExecutionsTable(conn *pgx.Conn) error {
+	_, err := conn.Exec(context.Background(), "create table if not exists recurring_executions(id uuid primary key default gen_random_uuid(), "+
+		"plan_id uuid references recurring_plans(id) on delete cascade, order_id text default '', notional double precision, status text, "+
+		"error text default '', executed_at timestamp default current_timestamp)")
+	return err
+}
+
+func createTables(conn *pgx.Conn) error {
+	if err := CreateRecurringPlansTable(conn); err != nil {
+		return err
+	}
+
+	return CreateRecurringExecutionsTable(conn)
+}
+
+func (p *Plan) validate() error {
+	if p.Symbol == "" {
+		return errors.New("no symbol was specified")
+	}
+
+	if p.Notional < 1 {
+		return errors.New("the amount of every purchase should be at least $1")
+	}
+
+	switch p.Frequency {
+	case Weekly:
+		if _, ok := weekdays[p.Weekday]; !ok {
+			return errors.New("weekly plans need a valid weekday")
+		}
+		p.DayOfMonth = 0
+	case Monthly:
+		if p.DayOfMonth < 1 || p.DayOfMonth > 31 {
+			return errors.New("monthly plans need a day of the month between 1 and 31")
+		}
+		p.Weekday = ""
+	default:
+		return errors.New("the frequency should be either weekly or monthly")
+	}
+
+	return nil
+}
+
+// nextDate returns the first date, on or after the given one, on which the plan is scheduled.
+// Monthly plans for days a month doesn't have are moved to the last day of that month.
+func (p *Plan) nextDate(from time.Time) time.Time {
+	date := from.UTC().Truncate(time.Hour * 24)
+
+	if p.Frequency == Weekly {
+		weekday := weekdays[p.Weekday]
+		for date.Weekday() != weekday {
+			date = date.AddDate(0, 0, 1)
+		}
+
+		return date
+	}
+
+	for {
+		lastDay := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		candidate := time.Date(date.Year(), date.Month(), min(p.DayOfMonth, lastDay), 0, 0, 0, 0, time.UTC)
+		if !candidate.Before(date) {
+			return candidate
+		}
+
+		date = time.Date(date.Year(), date.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// NextRun returns the first time after the given one at which the plan should be executed.
+// When the scheduled date is a holiday or a weekend the plan runs at the open of the next market day.
+func (p *Plan) NextRun(after time.Time, days []clock.MarketDay) (time.Time, bool) {
+	date := p.nextDate(after)
+
+	for _, day := range days {
+		if day.Date.Before(date) {
+			continue
+		}
+
+		if day.CoreStart.After(after) {
+			return day.CoreStart, true
+		}
+
+		// Today's open has already passed, so we continue from the next scheduled date
+		date = p.nextDate(day.Date.AddDate(0, 0, 1))
+	}
+
+	return time.Time{}, false
+}
+
+// The calendar is fetched far enough ahead to always contain the next run of a monthly plan
+func nextRun(plan *Plan, after time.Time) (time.Time, error) {
+	days, err := clock.GetMarketDays(market, after, after.AddDate(0, 0, 45))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next, ok := plan.NextRun(after, days)
+	if !ok {
+		return time.Time{}, errors.New("Error: couldn't find a market day for the next run of the plan")
+	}
+
+	return next, nil
+}
+
+const selectPlans = "select id, user_id, symbol, notional, frequency, weekday, day_of_month, status, next_run_at, created_at from recurring_plans"
+
+func collectPlans(rows pgx.Rows) ([]*Plan, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Plan, error) {
+		p := Plan{}
+		err := row.Scan(&p.ID, &p.UserID, &p.Symbol, &p.Notional, &p.Frequency, &p.Weekday, &p.DayOfMonth, &p.Status, &p.NextRunAt, &p.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		return &p, nil
+	})
+}
+
+func CreatePlan(c *gin.Context) {
+	id := c.GetString("id")
+
+	plan := Plan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	plan.Symbol = strings.ToUpper(plan.Symbol)
+	plan.Weekday = strings.ToLower(plan.Weekday)
+	if err := plan.validate(); err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	next, err := nextRun(&plan, time.Now().UTC())
+	if err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't get the market calendar to schedule the plan", err)
+		return
+	}
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create the tables for the recurring plans", err)
+		return
+	}
+
+	plan.UserID = id
+	plan.Status = StatusActive
+	plan.NextRunAt = &next
+	err = conn.QueryRow(context.Background(), "insert into recurring_plans (user_id, symbol, notional, frequency, weekday, day_of_month, status, next_run_at) "+
+		"values ($1, $2, $3, $4, $5, $6, $7, $8) returning id, created_at", id, plan.Symbol, plan.Notional, plan.Frequency, plan.Weekday, plan.DayOfMonth, plan.Status, next).
+		Scan(&plan.ID, &plan.CreatedAt)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't save the plan in the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func GetPlans(c *gin.Context) {
+	id := c.GetString("id")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't create the tables for the recurring plans", err)
+		return
+	}
+
+	rows, err := conn.Query(context.Background(), selectPlans+" where user_id = $1 and status != $2 order by created_at desc", id, StatusCanceled)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the plans from the database", err)
+		return
+	}
+
+	plans, err := collectPlans(rows)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't work with the information from the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+func GetPlan(c *gin.Context) {
+	id := c.GetString("id")
+	planID := c.Param("planId")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), selectPlans+" where id = $1 and user_id = $2", planID, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the plan from the database", err)
+		return
+	}
+
+	plans, err := collectPlans(rows)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't work with the information from the database", err)
+		return
+	}
+
+	if len(plans) == 0 {
+		ErrorExit(c, http.StatusNotFound, "there is no such plan", nil)
+		return
+	}
+
+	rows, err = conn.Query(context.Background(), "select id, plan_id, order_id, notional, status, error, executed_at from recurring_executions "+
+		"where plan_id = $1 order by executed_at desc", planID)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the history of the plan from the database", err)
+		return
+	}
+
+	executions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Execution, error) {
+		e := Execution{}
+		err := row.Scan(&e.ID, &e.PlanID, &e.OrderID, &e.Notional, &e.Status, &e.Error, &e.ExecutedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		return &e, nil
+	})
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't work with the information from the database", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plan": plans[0], "executions": executions})
+}
+
+func PausePlan(c *gin.Context) {
+	id := c.GetString("id")
+	planID := c.Param("planId")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	tag, err := conn.Exec(context.Background(), "update recurring_plans set status = $1, next_run_at = null where id = $2 and user_id = $3 and status = $4",
+		StatusPaused, planID, id, StatusActive)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't pause the plan", err)
+		return
+	}
+
+	if tag.RowsAffected() == 0 {
+		ErrorExit(c, http.StatusNotFound, "there is no active plan with this id", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": planID, "status": StatusPaused})
+}
+
+func ResumePlan(c *gin.Context) {
+	id := c.GetString("id")
+	planID := c.Param("planId")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), selectPlans+" where id = $1 and user_id = $2 and status = $3", planID, id, StatusPaused)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't get the plan from the database", err)
+		return
+	}
+
+	plans, err := collectPlans(rows)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't work with the information from the database", err)
+		return
+	}
+
+	if len(plans) == 0 {
+		ErrorExit(c, http.StatusNotFound, "there is no paused plan with this id", nil)
+		return
+	}
+
+	// The runs missed while the plan was paused are skipped
+	next, err := nextRun(plans[0], time.Now().UTC())
+	if err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't get the market calendar to schedule the plan", err)
+		return
+	}
+
+	_, err = conn.Exec(context.Background(), "update recurring_plans set status = $1, next_run_at = $2 where id = $3", StatusActive, next, planID)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't resume the plan", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": planID, "status": StatusActive, "next_run_at": next})
+}
+
+func CancelPlan(c *gin.Context) {
+	id := c.GetString("id")
+	planID := c.Param("planId")
+
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	tag, err := conn.Exec(context.Background(), "update recurring_plans set status = $1, next_run_at = null where id = $2 and user_id = $3 and status != $1",
+		StatusCanceled, planID, id)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't cancel the plan", err)
+		return
+	}
+
+	if tag.RowsAffected() == 0 {
+		ErrorExit(c, http.StatusNotFound, "there is no such plan", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": planID, "status": StatusCanceled})
+}
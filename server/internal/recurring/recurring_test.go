@@ -0,0 +1,130 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Every weekday between start and end is a market day, except for the holidays
+func marketDays(start, end time.Time, holidays ...time.Time) []clock.MarketDay {
+	var days []clock.MarketDay
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+
+		holiday := false
+		for _, h := range holidays {
+			if h.Equal(d) {
+				holiday = true
+			}
+		}
+
+		if !holiday {
+			days = append(days, clock.MarketDay{
+				Date:      d,
+				CoreStart: d.Add(13*time.Hour + 30*time.Minute),
+				CoreEnd:   d.Add(20 * time.Hour),
+			})
+		}
+	}
+
+	return days
+}
+
+func TestValidate(t *testing.T) {
+	valid := []Plan{
+		{Symbol: "VOO", Notional: 50, Frequency: Weekly, Weekday: "monday"},
+		{Symbol: "VOO", Notional: 50, Frequency: Monthly, DayOfMonth: 1},
+	}
+
+	for _, plan := range valid {
+		if err := plan.validate(); err != nil {
+			t.Fatalf("expected %+v to be valid, got %v", plan, err)
+		}
+	}
+
+	invalid := []Plan{
+		{Notional: 50, Frequency: Weekly, Weekday: "monday"},
+		{Symbol: "VOO", Notional: 0.5, Frequency: Weekly, Weekday: "monday"},
+		{Symbol: "VOO", Notional: 50, Frequency: Weekly, Weekday: "someday"},
+		{Symbol: "VOO", Notional: 50, Frequency: Monthly, DayOfMonth: 32},
+		{Symbol: "VOO", Notional: 50, Frequency: "daily"},
+	}
+
+	for _, plan := range invalid {
+		if err := plan.validate(); err == nil {
+			t.Fatalf("expected %+v to be invalid", plan)
+		}
+	}
+}
+
+func TestNextDate_Monthly(t *testing.T) {
+	plan := Plan{Frequency: Monthly, DayOfMonth: 31}
+
+	if got := plan.nextDate(date(2025, time.February, 10)); !got.Equal(date(2025, time.February, 28)) {
+		t.Fatalf("expected the last day of February, got %s", got)
+	}
+
+	if got := plan.nextDate(date(2025, time.March, 31).Add(time.Hour)); !got.Equal(date(2025, time.March, 31)) {
+		t.Fatalf("expected the same day, got %s", got)
+	}
+}
+
+func TestNextRun_Weekly(t *testing.T) {
+	plan := Plan{Frequency: Weekly, Weekday: "monday"}
+	days := marketDays(date(2025, time.January, 1), date(2025, time.February, 28))
+
+	// Wednesday the 8th
+	next, ok := plan.NextRun(date(2025, time.January, 8).Add(15*time.Hour), days)
+	if !ok || !next.Equal(date(2025, time.January, 13).Add(13*time.Hour+30*time.Minute)) {
+		t.Fatalf("expected the open of the next monday, got %s", next)
+	}
+
+	// On a monday after the open the plan runs the next week
+	next, _ = plan.NextRun(date(2025, time.January, 13).Add(14*time.Hour), days)
+	if !next.Equal(date(2025, time.January, 20).Add(13*time.Hour + 30*time.Minute)) {
+		t.Fatalf("expected the monday after, got %s", next)
+	}
+}
+
+func TestNextRun_SkipsHolidays(t *testing.T) {
+	plan := Plan{Frequency: Weekly, Weekday: "monday"}
+	holiday := date(2025, time.January, 20)
+	days := marketDays(date(2025, time.January, 1), date(2025, time.February, 28), holiday)
+
+	next, ok := plan.NextRun(date(2025, time.January, 17), days)
+	if !ok || !next.Equal(date(2025, time.January, 21).Add(13*time.Hour+30*time.Minute)) {
+		t.Fatalf("expected the open of the tuesday after the holiday, got %s", next)
+	}
+
+	// After the delayed run the plan goes back to mondays
+	next, _ = plan.NextRun(next.Add(time.Minute), days)
+	if !next.Equal(date(2025, time.January, 27).Add(13*time.Hour + 30*time.Minute)) {
+		t.Fatalf("expected the next monday, got %s", next)
+	}
+}
+
+func TestNextRun_Weekend(t *testing.T) {
+	plan := Plan{Frequency: Monthly, DayOfMonth: 1}
+	days := marketDays(date(2025, time.January, 1), date(2025, time.March, 31))
+
+	// The 1st of February 2025 is a saturday
+	next, ok := plan.NextRun(date(2025, time.January, 15), days)
+	if !ok || !next.Equal(date(2025, time.February, 3).Add(13*time.Hour+30*time.Minute)) {
+		t.Fatalf("expected the monday after, got %s", next)
+	}
+}
+
+func TestNextRun_NoCalendar(t *testing.T) {
+	plan := Plan{Frequency: Weekly, Weekday: "monday"}
+	if _, ok := plan.NextRun(date(2025, time.January, 1), nil); ok {
+		t.Fatal("expected no run without market days")
+	}
+}
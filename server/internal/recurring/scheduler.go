@@ -0,0 +1,149 @@
+package recurring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Phantomvv1/KayTrade/internal/clock"
+	"github.com/Phantomvv1/KayTrade/internal/paper"
+	"github.com/Phantomvv1/KayTrade/internal/trading"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	ExecutionPlaced = "placed"
+	ExecutionFailed = "failed"
+)
+
+// Scheduler places the orders of the plans that are due. The orders of the users that trade with
+// their paper account are placed on the paper engine.
+type Scheduler struct {
+	Interval time.Duration
+	paper    *paper.Engine
+}
+
+func NewScheduler(paperEngine *paper.Engine) *Scheduler {
+	return &Scheduler{Interval: time.Minute, paper: paperEngine}
+}
+
+func (s *Scheduler) Run() {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.runDuePlans(time.Now().UTC()); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (s *Scheduler) runDuePlans(now time.Time) error {
+	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if err = createTables(conn); err != nil {
+		return err
+	}
+
+	rows, err := conn.Query(context.Background(), selectPlans+" where status = $1 and next_run_at <= $2", StatusActive, now)
+	if err != nil {
+		return err
+	}
+
+	plans, err := collectPlans(rows)
+	if err != nil {
+		return err
+	}
+
+	if len(plans) == 0 {
+		return nil
+	}
+
+	days, err := clock.GetMarketDays(market, now, now.AddDate(0, 0, 45))
+	if err != nil {
+		return err
+	}
+
+	for _, plan := range plans {
+		next, ok := plan.NextRun(now, days)
+		if !ok {
+			log.Println("Couldn't find the next run of plan " + plan.ID)
+			continue
+		}
+
+		// Claiming the run first makes sure the plan is executed only once even with more than one server running
+		tag, err := conn.Exec(context.Background(), "update recurring_plans set next_run_at = $1 where id = $2 and next_run_at = $3", next, plan.ID, plan.NextRunAt)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+
+		s.execute(conn, plan)
+	}
+
+	return nil
+}
+
+// Plans are always executed as notional market orders
+func (s *Scheduler) execute(conn *pgx.Conn, plan *Plan) {
+	order := map[string]any{
+		"symbol":        plan.Symbol,
+		"notional":      strconv.FormatFloat(plan.Notional, 'f', 2, 64),
+		"side":          "buy",
+		"type":          "market",
+		"time_in_force": "day",
+	}
+
+	reqBody, err := json.Marshal(order)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	status := ExecutionPlaced
+	errMsg := ""
+	orderID := ""
+
+	var body map[string]any
+	enabled, err := paper.IsEnabled(plan.UserID)
+	if err == nil && enabled {
+		body, err = s.paper.Place(plan.UserID, reqBody)
+	} else if err == nil {
+		body, err = trading.SubmitOrder(plan.UserID, bytes.NewReader(reqBody))
+	}
+
+	if err != nil {
+		log.Println(err)
+		status = ExecutionFailed
+		errMsg = err.Error()
+	} else {
+		orderID, _ = body["id"].(string)
+
+		// The paper orders are kept by the paper account
+		if !enabled {
+			if err = trading.CreateOrdersTable(conn); err != nil {
+				log.Println(err)
+			} else if err = trading.SaveOrder(conn, plan.UserID, body); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	_, err = conn.Exec(context.Background(), "insert into recurring_executions (plan_id, order_id, notional, status, error) values ($1, $2, $3, $4, $5)",
+		plan.ID, orderID, plan.Notional, status, errMsg)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -16,7 +19,11 @@ const (
 	BaseURL          = "https://broker-api.sandbox.alpaca.markets/v1/"
 	MarketData       = "https://data.sandbox.alpaca.markets/v2"
 	RealTimeData     = "wss://stream.data.sandbox.alpaca.markets/v2/iex"
+	CryptoData       = "https://data.sandbox.alpaca.markets/v1beta3/crypto/us"
+	RealTimeCrypto   = "wss://stream.data.sandbox.alpaca.markets/v1beta3/crypto/us"
+	OptionsData      = "https://data.sandbox.alpaca.markets/v1beta1/options"
 	Accounts         = "accounts/"
+	Activities       = "accounts/activities/"
 	Documents        = "documents/"        // Accounts + ":accountId" + Documents
 	Trading          = "trading/accounts/" // :accountId
 	Assets           = "assets/"
@@ -34,8 +41,12 @@ const (
 	CashInterest     = "cash_interest/apr_tiers" //1 endpoint
 	CountryInfo      = "country-info"
 	Crypto           = "wallets/" // Accounts + :accountId + Crypto
+	Options          = "options/contracts"
 )
 
+// The maximum page size of the account activities
+const ActivitiesPageSize = 100
+
 func SendRequest[T any](method, url string, body io.Reader, errs map[int]string, headers map[string]string) (T, error) {
 	var zero T
 	req, err := http.NewRequest(method, url, body)
@@ -106,3 +117,38 @@ func BasicAuth() map[string]string {
 	m := map[string]string{"Authorization": "Basic " + out}
 	return m
 }
+
+// GetActivities returns all the account activities that match the query, following their pages. Only the page size
+// and the page token are set on the query, the id function returns the id of an activity, which is the token of the next page.
+func GetActivities[T any](query url.Values, id func(T) string) ([]T, error) {
+	return getActivities(BaseURL+strings.TrimSuffix(Activities, "/"), query, id)
+}
+
+func getActivities[T any](endpoint string, query url.Values, id func(T) string) ([]T, error) {
+	headers := BasicAuth()
+	query.Set("page_size", strconv.Itoa(ActivitiesPageSize))
+
+	var result []T
+	for {
+		activities, err := SendRequest[[]T](http.MethodGet, endpoint+"?"+query.Encode(), nil, nil, headers)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, activities...)
+		if len(activities) < ActivitiesPageSize {
+			return result, nil
+		}
+
+		query.Set("page_token", id(activities[len(activities)-1]))
+	}
+}
+
+// ParseTime reads a time given either as RFC3339 or as a date, the two formats alpaca accepts
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, value)
+}
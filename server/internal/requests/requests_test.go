@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
 	"testing"
 )
 
@@ -178,3 +180,61 @@ func TestBasicAuth_EmptyEnv(t *testing.T) {
 		t.Fatal("Authorization header should still exist")
 	}
 }
+
+func TestGetActivities_FollowsPages(t *testing.T) {
+	type activity struct {
+		ID string `json:"id"`
+	}
+
+	var tokens []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("activity_types") != "FILL" || r.URL.Query().Get("page_size") != strconv.Itoa(ActivitiesPageSize) {
+			t.Fatalf("unexpected query: %s", r.URL.RawQuery)
+		}
+
+		token := r.URL.Query().Get("page_token")
+		tokens = append(tokens, token)
+
+		size := ActivitiesPageSize
+		if token != "" {
+			size = 1
+		}
+
+		page := make([]activity, size)
+		for i := range page {
+			page[i].ID = token + strconv.Itoa(i)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer ts.Close()
+
+	query := url.Values{}
+	query.Set("activity_types", "FILL")
+
+	activities, err := getActivities(ts.URL, query, func(a activity) string { return a.ID })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(activities) != ActivitiesPageSize+1 {
+		t.Fatalf("expected %d activities, got %d", ActivitiesPageSize+1, len(activities))
+	}
+
+	if len(tokens) != 2 || tokens[0] != "" || tokens[1] != strconv.Itoa(ActivitiesPageSize-1) {
+		t.Fatalf("unexpected page tokens: %v", tokens)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	for _, value := range []string{"2024-03-15", "2024-03-15T13:30:00Z", "2024-03-15T09:30:00-04:00"} {
+		if _, err := ParseTime(value); err != nil {
+			t.Fatalf("expected %s to be parsed, got %v", value, err)
+		}
+	}
+
+	if _, err := ParseTime("15/03/2024"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
@@ -1,16 +1,33 @@
 package routes
 
 import (
+	"log"
 	"net/http"
 	"os"
 
+	"github.com/Phantomvv1/KayTrade/internal/analytics"
 	. "github.com/Phantomvv1/KayTrade/internal/auth"
+	"github.com/Phantomvv1/KayTrade/internal/basket"
+	"github.com/Phantomvv1/KayTrade/internal/cache"
 	"github.com/Phantomvv1/KayTrade/internal/clock"
+	"github.com/Phantomvv1/KayTrade/internal/conditional"
+	corporateactions "github.com/Phantomvv1/KayTrade/internal/corporate_actions"
+	"github.com/Phantomvv1/KayTrade/internal/dividends"
 	"github.com/Phantomvv1/KayTrade/internal/documents"
+	"github.com/Phantomvv1/KayTrade/internal/estimation"
+	"github.com/Phantomvv1/KayTrade/internal/events"
+	"github.com/Phantomvv1/KayTrade/internal/indicators"
 	"github.com/Phantomvv1/KayTrade/internal/journals"
 	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
 	. "github.com/Phantomvv1/KayTrade/internal/middleware"
+	"github.com/Phantomvv1/KayTrade/internal/options"
+	"github.com/Phantomvv1/KayTrade/internal/paper"
+	"github.com/Phantomvv1/KayTrade/internal/pnl"
+	"github.com/Phantomvv1/KayTrade/internal/rebalance"
+	"github.com/Phantomvv1/KayTrade/internal/recorder"
+	"github.com/Phantomvv1/KayTrade/internal/recurring"
 	"github.com/Phantomvv1/KayTrade/internal/trading"
+	"github.com/Phantomvv1/KayTrade/internal/wallets"
 	"github.com/Phantomvv1/KayTrade/internal/watchlist"
 	"github.com/gin-gonic/gin"
 )
@@ -33,6 +50,47 @@ func NewRouter() *gin.Engine {
 	r.GET("/last-market-open-day", clock.GetLastMarketOpenDayEndpoint)
 	r.GET("/search", AuthMiddleware, watchlist.SearchCompanies)
 	r.GET("/company-information/:symbol", AuthMiddleware, watchlist.GetCompanyInformation)
+	r.GET("/assets/:symbol", AuthMiddleware, trading.GetAsset)
+	r.GET("/metrics/cache", AuthMiddleware, AdminOnlyMiddleware, cache.GetMetrics)
+
+	hub := marketdata.NewHub()
+
+	// In development the whole server can replay a past day instead of streaming the real time data
+	replay, replaying, err := marketdata.ReplayFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	replaying = replaying && gin.Mode() != gin.ReleaseMode
+
+	// The clients stream the replay, while the engines keep trading on the real time data, so a past day never
+	// triggers an order
+	stream := hub
+	if replaying {
+		if stream, err = marketdata.NewReplayHub(replay); err != nil {
+			log.Fatal(err)
+		}
+
+		go stream.Run()
+	}
+
+	// Replays play what was recorded before falling back to the historical data. The recorder has to be hooked
+	// up before the hub runs and it only records the real time data, never a replay.
+	if recorder.Enabled() {
+		marketdata.ReplayRecorded = recorder.Ticks
+
+		rec, err := recorder.NewRecorder(hub)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go rec.Run()
+	}
+
+	go hub.Run()
+
+	paperEngine := paper.NewEngine(hub)
+	go paperEngine.Run()
+	paperMiddleware := paper.Middleware(paperEngine)
 
 	users := r.Group("/users")
 	users.Use(AuthMiddleware)
@@ -40,7 +98,7 @@ func NewRouter() *gin.Engine {
 	users.GET("/alpaca", GetUserAlpaca)
 	users.GET("/all", AdminOnlyMiddleware, GetAllUsers)
 	users.GET("/all/alpaca", AdminOnlyMiddleware, GetAllUsersAlpaca)
-	users.GET("/trading-details", GetAccountTradingDetails)
+	users.GET("/trading-details", paperMiddleware, GetAccountTradingDetails)
 	users.PATCH("", JSONParserMiddleware, UpdateUser)
 	users.PATCH("/alpaca", UpdateUserAlpaca)
 	users.DELETE("", DeleteUser)
@@ -61,20 +119,61 @@ func NewRouter() *gin.Engine {
 	t.POST("", NewTransfer)
 
 	trade := r.Group("/trading")
-	trade.Use(AuthMiddleware)
+	trade.Use(AuthMiddleware, paperMiddleware)
 	trade.POST("", trading.CreateOrder)
 	trade.GET("", trading.GetOrders)
 	trade.GET("/alpaca", trading.GetOrdersAlpaca)
 	trade.PATCH("/orders/:orderId", trading.ReplaceOrder)
 	trade.DELETE("/orders/:orderId", trading.CancelOrder)
-	trade.POST("/orders/estimation", trading.EstimateOrder)
+	trade.POST("/orders/estimation", estimation.EstimateOrder)
 	trade.GET("/orders/:orderId", trading.GetOrderByID)
 	trade.GET("/portfolio", trading.GetAccountProtfolioHistory)
+	trade.GET("/portfolio/analytics", analytics.GetAnalytics)
 	trade.GET("/positions", trading.GetOpenPositions)
 	trade.DELETE("/positions", trading.CloseAllOpenPositions)
 	trade.GET("/positions/:symbol_or_asset_id", trading.GetOpenPosition)
 	trade.DELETE("/positions/:symbol_or_asset_id", JSONParserMiddleware, trading.ClosePosition)
 
+	engine := conditional.NewEngine(hub, paperEngine)
+	go engine.Run()
+	trade.POST("/conditional", func(c *gin.Context) {
+		conditional.CreateConditionalOrder(c, engine)
+	})
+	trade.GET("/conditional", conditional.GetConditionalOrders)
+	trade.GET("/conditional/:conditionalId", conditional.GetConditionalOrder)
+	trade.DELETE("/conditional/:conditionalId", func(c *gin.Context) {
+		conditional.CancelConditionalOrder(c, engine)
+	})
+
+	scheduler := recurring.NewScheduler(paperEngine)
+	go scheduler.Run()
+	trade.POST("/recurring", recurring.CreatePlan)
+	trade.GET("/recurring", recurring.GetPlans)
+	trade.GET("/recurring/:planId", recurring.GetPlan)
+	trade.PATCH("/recurring/:planId/pause", recurring.PausePlan)
+	trade.PATCH("/recurring/:planId/resume", recurring.ResumePlan)
+	trade.DELETE("/recurring/:planId", recurring.CancelPlan)
+
+	trade.GET("/rebalance/model", rebalance.GetModelPortfolio)
+	trade.PUT("/rebalance/model", rebalance.SetModelPortfolio)
+	trade.POST("/rebalance", rebalance.Rebalance)
+
+	trade.POST("/baskets", basket.PlaceBasket)
+
+	trade.GET("/paper", paper.GetAccount)
+	trade.PUT("/paper", paper.UpdateAccount)
+	trade.POST("/paper/reset", func(c *gin.Context) {
+		paper.ResetAccount(c, paperEngine)
+	})
+
+	trade.GET("/pnl", pnl.GetPnL)
+	trade.GET("/pnl/lots", pnl.GetLots)
+	trade.GET("/pnl/export", pnl.ExportYear)
+	trade.PUT("/pnl/method", pnl.SetMethod)
+	trade.POST("/pnl/designations", pnl.DesignateLots)
+
+	trade.GET("/dividends", dividends.GetDividends)
+
 	docs := r.Group("/documents")
 	docs.Use(AuthMiddleware)
 	docs.GET("", documents.GetAllDocuments)
@@ -87,6 +186,10 @@ func NewRouter() *gin.Engine {
 	journ.DELETE("/:journal_id", journals.CancelJournal)
 	journ.GET("/:journal_id", journals.GetJournalByID)
 
+	ingester := corporateactions.NewIngester()
+	go ingester.Run()
+	r.GET("/corporate-actions", AuthMiddleware, corporateactions.GetCorporateActions)
+
 	watch := r.Group("/watchlist")
 	watch.Use(AuthMiddleware)
 	watch.POST("/alpaca", watchlist.CreateWatchlistAlpaca)
@@ -104,22 +207,51 @@ func NewRouter() *gin.Engine {
 
 	data := r.Group("/data")
 	data.GET("/auctions", SymbolsParserMiddleware, marketdata.GetHistoricalAuctions)
-	data.GET("/bars", SymbolsParserMiddleware, StartParserMiddleware, marketdata.GetHistoricalBars)
+	data.GET("/bars", SymbolsParserMiddleware, StartParserMiddleware, HistoricalParamsMiddleware, marketdata.GetHistoricalBars)
 	data.GET("/bars/latest", SymbolsParserMiddleware, marketdata.GetLatestBars)
 	data.GET("/conditions/:ticktype", marketdata.GetConditionCodes)
 	data.GET("/exchanges", marketdata.GetExchangeCodes)
-	data.GET("/quotes", SymbolsParserMiddleware, StartParserMiddleware, marketdata.GetHisoticalQuotes)
+	data.GET("/export", SymbolsParserMiddleware, StartParserMiddleware, HistoricalParamsMiddleware, marketdata.GetExport)
+	data.GET("/indicators", indicators.GetIndicators)
+	data.GET("/quotes", SymbolsParserMiddleware, StartParserMiddleware, HistoricalParamsMiddleware, marketdata.GetHisoticalQuotes)
 	data.GET("/quotes/latest", SymbolsParserMiddleware, marketdata.GetLatestQuotes)
+	data.GET("/recorded", SymbolsParserMiddleware, StartParserMiddleware, HistoricalParamsMiddleware, recorder.GetRecorded)
 	data.GET("/snapshots", SymbolsParserMiddleware, marketdata.GetSnapshots)
-	data.GET("/trades", SymbolsParserMiddleware, StartParserMiddleware, marketdata.GetHistoricalTrades)
+	data.GET("/trades", SymbolsParserMiddleware, StartParserMiddleware, HistoricalParamsMiddleware, marketdata.GetHistoricalTrades)
 	data.GET("/trades/latest", SymbolsParserMiddleware, marketdata.GetLatestTrades)
 	data.GET("/stocks/most-active", marketdata.GetMostActiveStocks)
 	data.GET("/stocks/top-market-movers", marketdata.GetTopMarketMovers)
 
-	hub := marketdata.NewHub()
-	go hub.Run()
-	data.GET("/stocks/live/:symbol", func(c *gin.Context) {
-		marketdata.GetRealTimeStocks(c, hub)
+	data.GET("/stream", ReplayAuthMiddleware, func(c *gin.Context) {
+		marketdata.GetStream(c, stream)
+	})
+
+	data.GET("/crypto/bars", SymbolsParserMiddleware, StartParserMiddleware, HistoricalParamsMiddleware, marketdata.GetHistoricalCryptoBars)
+	data.GET("/crypto/bars/latest", SymbolsParserMiddleware, marketdata.GetLatestCryptoBars)
+	data.GET("/crypto/quotes", SymbolsParserMiddleware, StartParserMiddleware, HistoricalParamsMiddleware, marketdata.GetHistoricalCryptoQuotes)
+	data.GET("/crypto/quotes/latest", SymbolsParserMiddleware, marketdata.GetLatestCryptoQuotes)
+	data.GET("/crypto/trades", SymbolsParserMiddleware, StartParserMiddleware, HistoricalParamsMiddleware, marketdata.GetHistoricalCryptoTrades)
+	data.GET("/crypto/trades/latest", SymbolsParserMiddleware, marketdata.GetLatestCryptoTrades)
+	data.GET("/crypto/snapshots", SymbolsParserMiddleware, marketdata.GetCryptoSnapshots)
+
+	data.GET("/options/chain/:symbol", options.GetChain)
+	data.GET("/options/contracts/:symbol", options.GetOptionContract)
+	data.GET("/options/quotes/latest", options.GetLatestOptionQuotes)
+
+	wallet := r.Group("/crypto/wallets")
+	wallet.Use(AuthMiddleware)
+	wallet.GET("", wallets.GetWallet)
+	wallet.GET("/transfers", wallets.GetWalletTransfers)
+	wallet.GET("/transfers/:transferId", wallets.GetWalletTransfer)
+	wallet.POST("/transfers", wallets.Withdraw)
+	wallet.GET("/whitelists", wallets.GetWhitelist)
+	wallet.POST("/whitelists", wallets.AddToWhitelist)
+	wallet.DELETE("/whitelists/:whitelistId", wallets.RemoveFromWhitelist)
+
+	eventHub := events.NewHub()
+	go eventHub.Run()
+	r.GET("/events", AuthMiddleware, func(c *gin.Context) {
+		events.GetAccountEvents(c, eventHub)
 	})
 
 	return r
@@ -68,6 +68,30 @@ func TestAdminRoute_WithoutAuth(t *testing.T) {
 	}
 }
 
+func TestReplayStream_WithoutAuth(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, http.MethodGet, "/data/stream?replay=2024-03-15", nil)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestCryptoHistoricalRoutes_CheckTheParams(t *testing.T) {
+	r := setupRouter()
+
+	for _, path := range []string{
+		"/data/crypto/bars?symbols=BTCUSD&timeframe=1Min&limit=0",
+		"/data/crypto/quotes?symbols=BTCUSD&end=yesterday",
+		"/data/crypto/trades?symbols=BTCUSD&sort=up",
+	} {
+		w := performRequest(r, http.MethodGet, path, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", path, w.Code)
+		}
+	}
+}
+
 func TestRateLimiterIsApplied(t *testing.T) {
 	r := setupRouter()
 
@@ -104,10 +128,245 @@ func TestMarketDataRoutesExist(t *testing.T) {
 
 func TestWebSocketRouteExists(t *testing.T) {
 	r := setupRouter()
-	w := performRequest(r, http.MethodGet, "/data/stocks/live/AAPL", nil)
+	w := performRequest(r, http.MethodGet, "/data/stream", nil)
 
 	// Will fail because it's not a WS upgrade — but route exists
 	if w.Code == http.StatusNotFound {
 		t.Fatal("websocket route not registered")
 	}
 }
+
+func TestCacheMetricsRouteExists(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, http.MethodGet, "/metrics/cache", nil)
+
+	if w.Code == http.StatusNotFound {
+		t.Fatal("cache metrics route not registered")
+	}
+}
+
+func TestIndicatorsRouteExists(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, http.MethodGet, "/data/indicators", nil)
+
+	if w.Code == http.StatusNotFound {
+		t.Fatal("indicators route not registered")
+	}
+}
+
+func TestExportRouteExists(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, http.MethodGet, "/data/export", nil)
+
+	if w.Code == http.StatusNotFound {
+		t.Fatal("export route not registered")
+	}
+}
+
+func TestRecordedRouteExists(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, http.MethodGet, "/data/recorded", nil)
+
+	if w.Code == http.StatusNotFound {
+		t.Fatal("recorded route not registered")
+	}
+}
+
+func TestEventsRouteExists(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, http.MethodGet, "/events", nil)
+
+	if w.Code == http.StatusNotFound {
+		t.Fatal("events route not registered")
+	}
+}
+
+func TestConditionalOrderRoutesExist(t *testing.T) {
+	r := setupRouter()
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/trading/conditional"},
+		{http.MethodGet, "/trading/conditional"},
+		{http.MethodGet, "/trading/conditional/123"},
+		{http.MethodDelete, "/trading/conditional/123"},
+	}
+
+	for _, route := range routes {
+		w := performRequest(r, route.method, route.path, nil)
+		if w.Code == http.StatusNotFound {
+			t.Fatalf("route %s %s not registered", route.method, route.path)
+		}
+	}
+}
+
+func TestRecurringPlanRoutesExist(t *testing.T) {
+	r := setupRouter()
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/trading/recurring"},
+		{http.MethodGet, "/trading/recurring"},
+		{http.MethodGet, "/trading/recurring/123"},
+		{http.MethodPatch, "/trading/recurring/123/pause"},
+		{http.MethodPatch, "/trading/recurring/123/resume"},
+		{http.MethodDelete, "/trading/recurring/123"},
+	}
+
+	for _, route := range routes {
+		w := performRequest(r, route.method, route.path, nil)
+		if w.Code == http.StatusNotFound {
+			t.Fatalf("route %s %s not registered", route.method, route.path)
+		}
+	}
+}
+
+func TestRebalanceRoutesExist(t *testing.T) {
+	r := setupRouter()
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/trading/rebalance/model"},
+		{http.MethodPut, "/trading/rebalance/model"},
+		{http.MethodPost, "/trading/rebalance?dry_run=true"},
+	}
+
+	for _, route := range routes {
+		w := performRequest(r, route.method, route.path, nil)
+		if w.Code == http.StatusNotFound {
+			t.Fatalf("route %s %s not registered", route.method, route.path)
+		}
+	}
+}
+
+func TestPnLRoutesExist(t *testing.T) {
+	r := setupRouter()
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/trading/pnl?from=2024-01-01&to=2024-12-31"},
+		{http.MethodGet, "/trading/pnl/lots"},
+		{http.MethodGet, "/trading/pnl/export?year=2024"},
+		{http.MethodPut, "/trading/pnl/method"},
+		{http.MethodPost, "/trading/pnl/designations"},
+	}
+
+	for _, route := range routes {
+		w := performRequest(r, route.method, route.path, nil)
+		if w.Code == http.StatusNotFound {
+			t.Fatalf("route %s %s not registered", route.method, route.path)
+		}
+	}
+}
+
+func TestBasketRouteExists(t *testing.T) {
+	r := setupRouter()
+
+	w := performRequest(r, http.MethodPost, "/trading/baskets", nil)
+	if w.Code == http.StatusNotFound {
+		t.Fatal("route POST /trading/baskets not registered")
+	}
+}
+
+func TestPaperRoutesExist(t *testing.T) {
+	r := setupRouter()
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/trading/paper"},
+		{http.MethodPut, "/trading/paper"},
+		{http.MethodPost, "/trading/paper/reset"},
+	}
+
+	for _, route := range routes {
+		w := performRequest(r, route.method, route.path, nil)
+		if w.Code == http.StatusNotFound {
+			t.Fatalf("route %s %s not registered", route.method, route.path)
+		}
+	}
+}
+
+func TestAnalyticsRoutesExist(t *testing.T) {
+	r := setupRouter()
+
+	w := performRequest(r, http.MethodGet, "/trading/portfolio/analytics", nil)
+	if w.Code == http.StatusNotFound {
+		t.Fatal("route GET /trading/portfolio/analytics not registered")
+	}
+}
+
+func TestCryptoRoutesExist(t *testing.T) {
+	r := setupRouter()
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/data/crypto/bars?symbols=BTCUSD"},
+		{http.MethodGet, "/crypto/wallets?asset=BTC"},
+		{http.MethodGet, "/crypto/wallets/transfers"},
+		{http.MethodPost, "/crypto/wallets/transfers"},
+		{http.MethodGet, "/crypto/wallets/whitelists"},
+		{http.MethodPost, "/crypto/wallets/whitelists"},
+		{http.MethodDelete, "/crypto/wallets/whitelists/1"},
+	}
+
+	for _, route := range routes {
+		w := performRequest(r, route.method, route.path, nil)
+		if w.Code == http.StatusNotFound {
+			t.Fatalf("route %s %s not registered", route.method, route.path)
+		}
+	}
+}
+
+func TestOptionsRoutesExist(t *testing.T) {
+	r := setupRouter()
+
+	for _, path := range []string{
+		"/data/options/chain/AAPL?expiration=june",
+		"/data/options/contracts/AAPL",
+		"/data/options/quotes/latest?symbols=AAPL",
+	} {
+		w := performRequest(r, http.MethodGet, path, nil)
+		if w.Code == http.StatusNotFound {
+			t.Fatalf("route GET %s not registered", path)
+		}
+	}
+}
+
+func TestCorporateActionsRouteExists(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, http.MethodGet, "/corporate-actions", nil)
+
+	if w.Code == http.StatusNotFound {
+		t.Fatal("GET /corporate-actions route not registered")
+	}
+}
+
+func TestDividendsRouteExists(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, http.MethodGet, "/trading/dividends", nil)
+
+	if w.Code == http.StatusNotFound {
+		t.Fatal("GET /trading/dividends route not registered")
+	}
+}
+
+func TestAssetRouteExists(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, http.MethodGet, "/assets/AAPL", nil)
+
+	if w.Code == http.StatusNotFound {
+		t.Fatal("GET /assets/:symbol route not registered")
+	}
+}
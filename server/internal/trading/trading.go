@@ -3,19 +3,50 @@ package trading
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Phantomvv1/KayTrade/internal/auth"
 	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	"github.com/Phantomvv1/KayTrade/internal/options"
 	. "github.com/Phantomvv1/KayTrade/internal/requests"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 )
 
+// The position intent of the orders that open a short position
+const IntentSellToOpen = "sell_to_open"
+
+type asset struct {
+	Symbol       string `json:"symbol"`
+	Shortable    bool   `json:"shortable"`
+	EasyToBorrow bool   `json:"easy_to_borrow"`
+}
+
+// upstreamError is an error of a request to Alpaca made while validating an order, as opposed to an invalid order
+type upstreamError struct {
+	err error
+}
+
+func (e upstreamError) Error() string {
+	return e.err.Error()
+}
+
+func (e upstreamError) Unwrap() error {
+	return e.err
+}
+
 type Order struct {
 	ID        string    `json:"id"`
 	UserID    string    `json:"user_id"`
@@ -62,36 +93,22 @@ func CreateOrder(c *gin.Context) {
 	// 	reader = bytes.NewReader(reqBody)
 	// }
 
-	headers := BasicAuth()
-
-	errs := map[int]string{
-		400: "Malformed input",
-		403: "Request is forbidden",
-		404: "Resource doesn't exist",
-		422: "Some parameters are invalid",
-	}
-
-	var body map[string]any
-	var err error
-	if reader != nil {
-		body, err = SendRequest[map[string]any](http.MethodPost, BaseURL+Trading+id+"/orders", reader, errs, headers)
-		log.Println("Reader")
-	} else {
-		body, err = SendRequest[map[string]any](http.MethodPost, BaseURL+Trading+id+"/orders", c.Request.Body, errs, headers)
-		log.Println("Req body")
+	reader, err := validateOrder(c)
+	var upstream upstreamError
+	if errors.As(err, &upstream) {
+		RequestExit(c, nil, upstream.err, "couldn't check the order")
+		return
+	} else if err != nil {
+		ErrorExit(c, http.StatusBadRequest, err.Error(), nil)
+		return
 	}
 
+	body, err := SubmitOrder(id, reader)
 	if err != nil {
 		RequestExit(c, body, err, "couldn't place an order for the given stock")
 		return
 	}
 
-	orderID := body["id"].(string)
-	createdAt := body["created_at"].(string)
-	updatedAt := body["updated_at"].(string)
-	symbol := body["symbol"].(string)
-	side := body["side"].(string)
-
 	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
 	if err != nil {
 		ErrorExit(c, http.StatusInternalServerError, "couldn't connect to the database", err)
@@ -104,8 +121,7 @@ func CreateOrder(c *gin.Context) {
 		return
 	}
 
-	_, err = conn.Exec(context.Background(), "insert into orders (id, user_id, symbol, side, created_at, updated_at) values ($1, $2, $3, $4, $5, $6)", orderID, id, symbol, side, createdAt, updatedAt)
-	if err != nil {
+	if err = SaveOrder(conn, id, body); err != nil {
 		ErrorExit(c, http.StatusInternalServerError, "couldn't put the information about your order in the database", err)
 		return
 	}
@@ -113,6 +129,154 @@ func CreateOrder(c *gin.Context) {
 	c.JSON(http.StatusOK, body)
 }
 
+// validateOrder checks the orders for options contracts, the short sales and the notional orders before they reach Alpaca. The body
+// of the request is read in the process, so the order is returned to be sent instead of it. Other orders are left alone.
+func validateOrder(c *gin.Context) (*bytes.Reader, error) {
+	reqBody, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var order map[string]any
+	if err := json.Unmarshal(reqBody, &order); err != nil {
+		// Alpaca responds with the right error for a malformed body
+		return bytes.NewReader(reqBody), nil
+	}
+
+	symbol, _ := order["symbol"].(string)
+	if options.IsContract(symbol) {
+		if err := options.ValidateOrder(order, time.Now()); err != nil {
+			return nil, err
+		}
+
+		reqBody, err = json.Marshal(order)
+		if err != nil {
+			return nil, err
+		}
+	} else if intent, _ := order["position_intent"].(string); intent == IntentSellToOpen {
+		if err := validateShortOrder(c.GetString("id"), order); err != nil {
+			return nil, err
+		}
+	} else if _, ok := order["notional"]; ok {
+		if err := validateNotionalOrder(order); err != nil {
+			return nil, err
+		}
+	}
+
+	return bytes.NewReader(reqBody), nil
+}
+
+// validateNotionalOrder checks the orders for a dollar amount instead of a quantity. Alpaca fills them only
+// at the market price and, for stocks, only on the day they were placed.
+func validateNotionalOrder(order map[string]any) error {
+	if order["qty"] != nil {
+		return errors.New("an order can have either a quantity or a notional amount, not both")
+	}
+
+	notional, err := strconv.ParseFloat(fmt.Sprint(order["notional"]), 64)
+	if err != nil || notional <= 0 {
+		return errors.New("the notional amount should be a positive number")
+	}
+
+	if orderType, _ := order["type"].(string); orderType != "market" {
+		return errors.New("only market orders can have a notional amount")
+	}
+
+	symbol, _ := order["symbol"].(string)
+	if timeInForce, _ := order["time_in_force"].(string); !strings.Contains(symbol, "/") && timeInForce != "day" {
+		return errors.New("notional orders for stocks should have a time in force of day")
+	}
+
+	if order["take_profit"] != nil || order["stop_loss"] != nil {
+		return errors.New("notional orders can't have a take profit or a stop loss")
+	}
+
+	return nil
+}
+
+// validateShortOrder gets the asset and the account the short sale depends on and checks it
+func validateShortOrder(id string, order map[string]any) error {
+	symbol, _ := order["symbol"].(string)
+	if strings.Contains(symbol, "/") {
+		return errors.New("crypto can't be sold short")
+	}
+
+	a, err := SendRequest[asset](http.MethodGet, BaseURL+Assets+url.PathEscape(symbol), nil, map[int]string{404: symbol + " doesn't exist"}, BasicAuth())
+	if err != nil {
+		return upstreamError{err}
+	}
+
+	account, err := SendRequest[auth.TradingDetails](http.MethodGet, BaseURL+Trading+id+"/account", nil, nil, BasicAuth())
+	if err != nil {
+		return upstreamError{err}
+	}
+	account.CheckMargin()
+
+	return checkShortSale(order, a, account)
+}
+
+// checkShortSale makes sure the short sale can be placed. Alpaca only lends the shares of easy to borrow assets,
+// in whole shares and only to margin accounts that have shorting enabled and aren't in a margin call.
+func checkShortSale(order map[string]any, a asset, account auth.TradingDetails) error {
+	if side, _ := order["side"].(string); side != "sell" {
+		return errors.New("only sell orders can open a short position")
+	}
+
+	if order["notional"] != nil {
+		return errors.New("short sales can't be for a notional amount")
+	}
+
+	qty, err := strconv.ParseFloat(fmt.Sprint(order["qty"]), 64)
+	if err != nil || qty <= 0 || qty != math.Trunc(qty) {
+		return errors.New("short sales should be for a whole number of shares")
+	}
+
+	if !a.Shortable {
+		return errors.New(a.Symbol + " can't be sold short")
+	}
+
+	if !a.EasyToBorrow {
+		return errors.New(a.Symbol + " is hard to borrow, so it can't be sold short")
+	}
+
+	if !account.Margin() || !account.ShortingEnabled {
+		return errors.New("short selling needs a margin account with shorting enabled")
+	}
+
+	if account.MarginCall {
+		return errors.New("short sales aren't allowed while the account is in a margin call")
+	}
+
+	return nil
+}
+
+// SubmitOrder places the order on Alpaca for the given account. It's shared between the order endpoint
+// and everything on the server that places orders on behalf of the user.
+func SubmitOrder(id string, order io.Reader) (map[string]any, error) {
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		400: "Malformed input",
+		403: "Request is forbidden",
+		404: "Resource doesn't exist",
+		422: "Some parameters are invalid",
+	}
+
+	return SendRequest[map[string]any](http.MethodPost, BaseURL+Trading+id+"/orders", order, errs, headers)
+}
+
+// SaveOrder keeps the order placed by SubmitOrder in the orders table
+func SaveOrder(conn *pgx.Conn, id string, order map[string]any) error {
+	orderID, _ := order["id"].(string)
+	createdAt, _ := order["created_at"].(string)
+	updatedAt, _ := order["updated_at"].(string)
+	symbol, _ := order["symbol"].(string)
+	side, _ := order["side"].(string)
+
+	_, err := conn.Exec(context.Background(), "insert into orders (id, user_id, symbol, side, created_at, updated_at) values ($1, $2, $3, $4, $5, $6)", orderID, id, symbol, side, createdAt, updatedAt)
+	return err
+}
+
 func GetOrders(c *gin.Context) {
 	id := c.GetString("id")
 
@@ -265,20 +429,6 @@ func CancelOrder(c *gin.Context) {
 	c.JSON(http.StatusOK, resBody)
 }
 
-func EstimateOrder(c *gin.Context) {
-	id := c.GetString("id")
-
-	headers := BasicAuth()
-
-	body, err := SendRequest[any](http.MethodPost, BaseURL+Trading+id+"/orders/estimation", c.Request.Body, nil, headers)
-	if err != nil {
-		RequestExit(c, body, err, "couldn't estimate the order")
-		return
-	}
-
-	c.JSON(http.StatusOK, body)
-}
-
 func GetOrderByID(c *gin.Context) {
 	id := c.GetString("id")
 	orderID := c.Param("orderId")
@@ -304,9 +454,14 @@ func GetAccountProtfolioHistory(c *gin.Context) {
 
 	headers := BasicAuth()
 
-	body, err := SendRequest[any](http.MethodGet, BaseURL+Trading+id+"/account/portfolio/history", c.Request.Body, nil, headers)
+	url := BaseURL + Trading + id + "/account/portfolio/history"
+	if c.Request.URL.RawQuery != "" {
+		url += "?" + c.Request.URL.RawQuery
+	}
+
+	body, err := SendRequest[any](http.MethodGet, url, nil, nil, headers)
 	if err != nil {
-		RequestExit(c, body, err, "couldn't get the order")
+		RequestExit(c, body, err, "couldn't get the portfolio history")
 		return
 	}
 
@@ -345,6 +500,23 @@ func CloseAllOpenPositions(c *gin.Context) {
 	c.JSON(http.StatusOK, body)
 }
 
+// GetAsset returns the trading attributes of the asset, like whether it can be traded in fractions
+func GetAsset(c *gin.Context) {
+	symbol := c.Param("symbol")
+
+	errs := map[int]string{
+		404: "Asset not found",
+	}
+
+	body, err := SendRequest[any](http.MethodGet, BaseURL+Assets+url.PathEscape(symbol), nil, errs, BasicAuth())
+	if err != nil {
+		RequestExit(c, body, err, "couldn't get the asset")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
 func GetOpenPosition(c *gin.Context) {
 	id := c.GetString("id")
 	symbolOrAssetID := c.Param("symbol_or_asset_id")
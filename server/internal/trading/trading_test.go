@@ -0,0 +1,75 @@
+package trading
+
+import (
+	"testing"
+
+	"github.com/Phantomvv1/KayTrade/internal/auth"
+)
+
+func TestValidateNotionalOrder(t *testing.T) {
+	valid := func() map[string]any {
+		return map[string]any{"symbol": "AAPL", "notional": "150.50", "side": "buy", "type": "market", "time_in_force": "day"}
+	}
+
+	if err := validateNotionalOrder(valid()); err != nil {
+		t.Fatal(err)
+	}
+
+	crypto := valid()
+	crypto["symbol"] = "BTC/USD"
+	crypto["time_in_force"] = "gtc"
+	if err := validateNotionalOrder(crypto); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []func(map[string]any){
+		func(o map[string]any) { o["qty"] = "1" },
+		func(o map[string]any) { o["notional"] = "-5" },
+		func(o map[string]any) { o["notional"] = "a lot" },
+		func(o map[string]any) { o["type"] = "limit"; o["limit_price"] = "100" },
+		func(o map[string]any) { o["time_in_force"] = "gtc" },
+		func(o map[string]any) { o["take_profit"] = map[string]any{"limit_price": "200"} },
+	}
+
+	for i, change := range invalid {
+		order := valid()
+		change(order)
+		if err := validateNotionalOrder(order); err == nil {
+			t.Fatalf("expected order %d to be rejected: %v", i, order)
+		}
+	}
+}
+
+func TestCheckShortSale(t *testing.T) {
+	order := func() map[string]any {
+		return map[string]any{"symbol": "TSLA", "qty": "3", "side": "sell", "type": "market", "time_in_force": "day", "position_intent": IntentSellToOpen}
+	}
+	etb := asset{Symbol: "TSLA", Shortable: true, EasyToBorrow: true}
+	margin := auth.TradingDetails{Multiplier: "2", ShortingEnabled: true}
+
+	if err := checkShortSale(order(), etb, margin); err != nil {
+		t.Fatal(err)
+	}
+
+	fractional := order()
+	fractional["qty"] = "1.5"
+	if err := checkShortSale(fractional, etb, margin); err == nil {
+		t.Fatal("expected a fractional short sale to be rejected")
+	}
+
+	if err := checkShortSale(order(), asset{Symbol: "TSLA", Shortable: true}, margin); err == nil {
+		t.Fatal("expected a hard to borrow asset to be rejected")
+	}
+
+	if err := checkShortSale(order(), asset{Symbol: "TSLA"}, margin); err == nil {
+		t.Fatal("expected an asset that isn't shortable to be rejected")
+	}
+
+	if err := checkShortSale(order(), etb, auth.TradingDetails{Multiplier: "1", ShortingEnabled: true}); err == nil {
+		t.Fatal("expected a cash account to be rejected")
+	}
+
+	if err := checkShortSale(order(), etb, auth.TradingDetails{Multiplier: "4", ShortingEnabled: true, MarginCall: true}); err == nil {
+		t.Fatal("expected an account in a margin call to be rejected")
+	}
+}
@@ -0,0 +1,204 @@
+package wallets
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	. "github.com/Phantomvv1/KayTrade/internal/requests"
+	"github.com/gin-gonic/gin"
+)
+
+type withdrawal struct {
+	Asset   string `json:"asset"`
+	Amount  string `json:"amount"`
+	Address string `json:"address"`
+}
+
+type whitelistedAddress struct {
+	Asset   string `json:"asset"`
+	Address string `json:"address"`
+}
+
+// GetWallet returns the wallet of the account for the given asset, with the address crypto can be deposited to
+func GetWallet(c *gin.Context) {
+	id := c.GetString("id")
+	asset := strings.ToUpper(c.Query("asset"))
+	if asset == "" {
+		ErrorExit(c, http.StatusBadRequest, "the asset is required", nil)
+		return
+	}
+
+	query := url.Values{}
+	query.Set("asset", asset)
+	if network := c.Query("network"); network != "" {
+		query.Set("network", network)
+	}
+
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		400: "Malformed input",
+		404: "There isn't a wallet for this asset",
+	}
+
+	body, err := SendRequest[any](http.MethodGet, BaseURL+Accounts+id+"/"+strings.TrimSuffix(Crypto, "/")+"?"+query.Encode(), nil, errs, headers)
+	if err != nil {
+		RequestExit(c, body, err, "couldn't get the wallet for this asset")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+func GetWalletTransfers(c *gin.Context) {
+	id := c.GetString("id")
+
+	headers := BasicAuth()
+
+	body, err := SendRequest[any](http.MethodGet, BaseURL+Accounts+id+"/"+Crypto+"transfers", nil, nil, headers)
+	if err != nil {
+		RequestExit(c, body, err, "couldn't get the crypto transfers of the account")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+func GetWalletTransfer(c *gin.Context) {
+	id := c.GetString("id")
+	transferID := c.Param("transferId")
+
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		404: "The transfer doesn't exist",
+	}
+
+	body, err := SendRequest[any](http.MethodGet, BaseURL+Accounts+id+"/"+Crypto+"transfers/"+transferID, nil, errs, headers)
+	if err != nil {
+		RequestExit(c, body, err, "couldn't get the crypto transfer")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// Withdraw sends crypto from the wallet of the account to an address on the whitelist
+func Withdraw(c *gin.Context) {
+	id := c.GetString("id")
+
+	w := withdrawal{}
+	if err := c.ShouldBindJSON(&w); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	w.Asset = strings.ToUpper(w.Asset)
+	if w.Asset == "" || w.Address == "" {
+		ErrorExit(c, http.StatusBadRequest, "the asset and the address are required", nil)
+		return
+	}
+
+	if amount, err := strconv.ParseFloat(w.Amount, 64); err != nil || amount <= 0 {
+		ErrorExit(c, http.StatusBadRequest, "the amount should be a positive number", err)
+		return
+	}
+
+	req, err := json.Marshal(w)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't recreate the request", err)
+		return
+	}
+
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		400: "Malformed input",
+		403: "The address isn't on the whitelist of the account",
+		422: "The amount isn't available for withdrawal",
+	}
+
+	body, err := SendRequest[any](http.MethodPost, BaseURL+Accounts+id+"/"+Crypto+"transfers", bytes.NewReader(req), errs, headers)
+	if err != nil {
+		RequestExit(c, body, err, "couldn't withdraw the crypto")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+func GetWhitelist(c *gin.Context) {
+	id := c.GetString("id")
+
+	headers := BasicAuth()
+
+	body, err := SendRequest[any](http.MethodGet, BaseURL+Accounts+id+"/"+Crypto+"whitelists", nil, nil, headers)
+	if err != nil {
+		RequestExit(c, body, err, "couldn't get the whitelisted addresses")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// AddToWhitelist allows withdrawals to the address. Alpaca approves new addresses only after a waiting period.
+func AddToWhitelist(c *gin.Context) {
+	id := c.GetString("id")
+
+	address := whitelistedAddress{}
+	if err := c.ShouldBindJSON(&address); err != nil {
+		ErrorExit(c, http.StatusBadRequest, "couldn't parse the body of the request correctly", err)
+		return
+	}
+
+	address.Asset = strings.ToUpper(address.Asset)
+	if address.Asset == "" || address.Address == "" {
+		ErrorExit(c, http.StatusBadRequest, "the asset and the address are required", nil)
+		return
+	}
+
+	req, err := json.Marshal(address)
+	if err != nil {
+		ErrorExit(c, http.StatusInternalServerError, "couldn't recreate the request", err)
+		return
+	}
+
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		400: "Malformed input",
+		409: "The address is already on the whitelist",
+	}
+
+	body, err := SendRequest[any](http.MethodPost, BaseURL+Accounts+id+"/"+Crypto+"whitelists", bytes.NewReader(req), errs, headers)
+	if err != nil {
+		RequestExit(c, body, err, "couldn't add the address to the whitelist")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+func RemoveFromWhitelist(c *gin.Context) {
+	id := c.GetString("id")
+	whitelistID := c.Param("whitelistId")
+
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		404: "The address isn't on the whitelist",
+	}
+
+	body, err := SendRequest[any](http.MethodDelete, BaseURL+Accounts+id+"/"+Crypto+"whitelists/"+whitelistID, nil, errs, headers)
+	if err != nil {
+		RequestExit(c, body, err, "couldn't remove the address from the whitelist")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
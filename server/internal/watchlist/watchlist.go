@@ -14,8 +14,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Phantomvv1/KayTrade/internal/cache"
 	"github.com/Phantomvv1/KayTrade/internal/clock"
 	. "github.com/Phantomvv1/KayTrade/internal/exit"
+	marketdata "github.com/Phantomvv1/KayTrade/internal/market_data"
+	"github.com/Phantomvv1/KayTrade/internal/options"
 	. "github.com/Phantomvv1/KayTrade/internal/requests"
 	"github.com/agnivade/levenshtein"
 	"github.com/gin-gonic/gin"
@@ -34,19 +37,31 @@ type CompanyInfo struct {
 	Description    string  `json:"description"`
 	FoundedYear    int     `json:"founded_year"`
 	Domain         string  `json:"domain"`
+	Class          string  `json:"class,omitempty"`
 	expirationDate time.Time
 }
 
+// Asset classes of Alpaca
+const (
+	ClassEquity = "us_equity"
+	ClassCrypto = "crypto"
+	ClassOption = "us_option"
+)
+
 type Asset struct {
 	Symbol     string `json:"symbol"`
 	Name       string `json:"name"`
 	Exchange   string `json:"exchange"`
+	Class      string `json:"class"`
 	distance   int
 	Expiration *time.Time `json:"expiration,omitempty"`
 }
 
 var assetCache []Asset
 
+// The key of the stocks and crypto pairs in redis
+const assetsKey = "assets:all"
+
 var missingInfo = errors.New("There is no information for this company in redis")
 
 func CreateWatchlistTable(conn *pgx.Conn) error {
@@ -593,7 +608,10 @@ func getPriceInformation(symbols []string, start string, res chan<- result) {
 		500: "Internal server error. We recommend retrying these later",
 	}
 
-	body, err := SendRequest[map[string]map[string][]map[string]any](http.MethodGet, MarketData+"/stocks/bars?timeframe=1D&start="+start+"&symbols="+s, nil, errs, headers)
+	url := MarketData + "/stocks/bars?timeframe=1D&start=" + start + "&symbols=" + s
+	body, err := cache.Fetch("bars", url, marketdata.BarsTTL("1D", time.Time{}), func() (map[string]map[string][]map[string]any, error) {
+		return SendRequest[map[string]map[string][]map[string]any](http.MethodGet, url, nil, errs, headers)
+	})
 	if err != nil {
 		res <- result{information: nil, result: 1, symbol: "", err: err}
 		return
@@ -703,8 +721,20 @@ func SearchCompanies(c *gin.Context) {
 			assets[i].distance = levenshtein.ComputeDistance(assets[i].Symbol, symbol)
 		}
 
-		result := make([]Asset, 5)
-		for i := range 5 {
+		// Options contracts aren't a part of the assets, so they can only be found by their exact symbol
+		var contracts []Asset
+		if options.IsContract(symbol) {
+			contract, err := options.GetContract(symbol)
+			if err != nil {
+				RequestExit(c, nil, err, "couldn't get the options contract")
+				return
+			}
+
+			contracts = append(contracts, Asset{Symbol: contract.Symbol, Name: contract.Name, Class: ClassOption})
+		}
+
+		result := make([]Asset, 5-len(contracts))
+		for i := range result {
 			asset := slices.MinFunc(assets, func(a, b Asset) int {
 				return cmp.Compare(a.distance, b.distance)
 			})
@@ -715,7 +745,7 @@ func SearchCompanies(c *gin.Context) {
 			result[i] = asset
 		}
 
-		c.JSON(http.StatusOK, gin.H{"result": result})
+		c.JSON(http.StatusOK, gin.H{"result": append(contracts, result...)})
 		return
 	} else {
 		result := make([]Asset, 0, 5)
@@ -750,7 +780,7 @@ func getAssets() ([]Asset, error) {
 		})
 		defer rdb.Close()
 
-		assetString, err := rdb.Get(context.Background(), "assets").Result()
+		assetString, err := rdb.Get(context.Background(), assetsKey).Result()
 		if err != nil {
 			if errors.Is(err, redis.Nil) {
 				assets, exp, err := fetchAssets()
@@ -765,7 +795,7 @@ func getAssets() ([]Asset, error) {
 					return nil, err
 				}
 
-				err = rdb.Set(context.Background(), "assets", res, exp.Sub(time.Now().UTC())).Err()
+				err = rdb.Set(context.Background(), assetsKey, res, exp.Sub(time.Now().UTC())).Err()
 				if err != nil {
 					return nil, err
 				}
@@ -809,7 +839,7 @@ func getAssets() ([]Asset, error) {
 			return nil, err
 		}
 
-		err = rdb.Set(context.Background(), "assets", res, exp.Sub(time.Now().UTC())).Err()
+		err = rdb.Set(context.Background(), assetsKey, res, exp.Sub(time.Now().UTC())).Err()
 		if err != nil {
 			return nil, err
 		}
@@ -843,6 +873,13 @@ func fetchAssets() ([]Asset, *time.Time, error) {
 		return nil, nil, err
 	}
 
+	// The assets are only stocks by default, so the crypto pairs are searched for separately
+	cryptoAssets, err := SendRequest[[]Asset](http.MethodGet, BaseURL+Assets+"?asset_class="+ClassCrypto, nil, nil, headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	assets = append(assets, cryptoAssets...)
+
 	exp := time.Now().UTC().Add(24 * time.Hour * 5) // 5 days epiration
 	for i := range assets {
 		assets[i].Expiration = &exp
@@ -872,13 +909,19 @@ func GetCompanyInformation(c *gin.Context) {
 		return
 	}
 
+	// Crypto pairs can't have a slash in the path, so BTC/USD is also accepted as BTCUSD
 	index := slices.IndexFunc(assets, func(e Asset) bool {
-		if e.Symbol == symbol {
-			return true
-		}
-
-		return false
+		return e.Symbol == symbol || e.Class == ClassCrypto && strings.ReplaceAll(e.Symbol, "/", "") == strings.ToUpper(symbol)
 	})
+	if index == -1 {
+		ErrorExit(c, http.StatusNotFound, "there is no asset with this symbol", nil)
+		return
+	}
+
+	if assets[index].Class == ClassCrypto {
+		getCryptoInformation(c, assets[index])
+		return
+	}
 
 	exchange := assets[index].Exchange
 
@@ -1001,3 +1044,51 @@ func fetchAndCacheResponse(c *gin.Context, symbol string, start string) {
 
 	c.JSON(http.StatusOK, gin.H{"information": innerResponse})
 }
+
+// getCryptoInformation responds with the information about a crypto pair. Crypto trades all the time,
+// so the prices are the ones of the current day and there is no company behind it to get a logo for.
+func getCryptoInformation(c *gin.Context, asset Asset) {
+	headers := BasicAuth()
+
+	errs := map[int]string{
+		400: "One of the request parameters is invalid",
+		429: "Too many requests",
+		500: "Internal server error. We recommend retrying these later",
+	}
+
+	start := time.Now().UTC().Truncate(time.Hour * 24).Format(time.RFC3339)
+	body, err := SendRequest[map[string]map[string][]map[string]any](http.MethodGet, CryptoData+"/bars?timeframe=1D&start="+start+"&symbols="+asset.Symbol, nil, errs, headers)
+	if err != nil {
+		ErrorExit(c, http.StatusFailedDependency, "couldn't get the opening and closing price", err)
+		return
+	}
+
+	response := CompanyInfo{Symbol: asset.Symbol, Name: asset.Name, Class: ClassCrypto}
+	if bars := body["bars"][asset.Symbol]; len(bars) != 0 {
+		response.OpeningPrice, _ = bars[len(bars)-1]["o"].(float64)
+		response.ClosingPrice, _ = bars[len(bars)-1]["c"].(float64)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"information": response})
+}
+
+// MigrateSymbol moves every watchlist from the old symbol of a company to its new one.
+// Users who already watch the new symbol simply lose the old one.
+func MigrateSymbol(conn *pgx.Conn, oldSymbol, newSymbol string) (int64, error) {
+	if err := CreateWatchlistTable(conn); err != nil {
+		return 0, err
+	}
+
+	_, err := conn.Exec(context.Background(), "delete from wishlist w where w.symbol = $1 and exists "+
+		"(select 1 from wishlist n where n.user_id = w.user_id and n.symbol = $2)", oldSymbol, newSymbol)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := conn.Exec(context.Background(), "update wishlist set symbol = $1 where symbol = $2", newSymbol, oldSymbol)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,7 @@
+-- +goose Up
+create table if not exists conditional_orders(id uuid primary key default gen_random_uuid(), user_id uuid references authentication(id) on delete cascade,
+symbol text, condition text, threshold double precision, order_body jsonb, oco_group text default '', status text,
+order_id text default '', error text default '', created_at timestamp default current_timestamp, triggered_at timestamp);
+
+-- +goose Down
+drop table conditional_orders;
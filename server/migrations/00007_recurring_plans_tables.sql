@@ -0,0 +1,11 @@
+-- +goose Up
+create table if not exists recurring_plans(id uuid primary key default gen_random_uuid(), user_id uuid references authentication(id) on delete cascade,
+symbol text, notional double precision, frequency text, weekday text default '', day_of_month int default 0, status text,
+next_run_at timestamp, created_at timestamp default current_timestamp);
+
+create table if not exists recurring_executions(id uuid primary key default gen_random_uuid(), plan_id uuid references recurring_plans(id) on delete cascade,
+order_id text default '', notional double precision, status text, error text default '', executed_at timestamp default current_timestamp);
+
+-- +goose Down
+drop table recurring_executions;
+drop table recurring_plans;
@@ -0,0 +1,6 @@
+-- +goose Up
+create table if not exists model_portfolios(user_id uuid primary key references authentication(id) on delete cascade,
+weights jsonb, drift_band double precision, updated_at timestamp default current_timestamp);
+
+-- +goose Down
+drop table model_portfolios;
@@ -0,0 +1,22 @@
+-- +goose Up
+create table if not exists pnl_settings(user_id uuid primary key references authentication(id) on delete cascade,
+method text default 'fifo', synced_until timestamp);
+
+create table if not exists pnl_fills(id text primary key, user_id uuid references authentication(id) on delete cascade);
+
+create table if not exists tax_lots(id text primary key, user_id uuid references authentication(id) on delete cascade,
+symbol text, qty double precision, remaining_qty double precision, price double precision, acquired_at timestamp);
+
+create table if not exists realized_gains(id serial primary key, user_id uuid references authentication(id) on delete cascade,
+fill_id text, lot_id text, symbol text, qty double precision, cost_basis double precision, proceeds double precision, gain double precision,
+acquired_at timestamp, sold_at timestamp, long_term boolean);
+
+create table if not exists lot_designations(order_id text primary key, user_id uuid references authentication(id) on delete cascade,
+lot_ids text[]);
+
+-- +goose Down
+drop table lot_designations;
+drop table realized_gains;
+drop table tax_lots;
+drop table pnl_fills;
+drop table pnl_settings;
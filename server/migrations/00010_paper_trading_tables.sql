@@ -0,0 +1,18 @@
+-- +goose Up
+create table if not exists paper_accounts(user_id uuid primary key references authentication(id) on delete cascade,
+enabled boolean default false, starting_cash double precision, cash double precision, slippage_bps double precision default 0,
+fill_ratio double precision default 0, created_at timestamp default current_timestamp);
+
+create table if not exists paper_orders(id uuid primary key default gen_random_uuid(), user_id uuid references authentication(id) on delete cascade,
+symbol text, side text, type text, time_in_force text, qty double precision, filled_qty double precision default 0,
+filled_avg_price double precision default 0, limit_price double precision, stop_price double precision, trail_price double precision,
+trail_percent double precision, hwm double precision, triggered boolean default false, status text,
+created_at timestamp default current_timestamp, updated_at timestamp default current_timestamp, filled_at timestamp, canceled_at timestamp);
+
+create table if not exists paper_positions(user_id uuid references authentication(id) on delete cascade,
+symbol text, qty double precision, avg_entry_price double precision, primary key (user_id, symbol));
+
+-- +goose Down
+drop table paper_positions;
+drop table paper_orders;
+drop table paper_accounts;
@@ -0,0 +1,8 @@
+-- +goose Up
+create table if not exists corporate_actions(id text primary key, corporate_action_id text, ca_type text, ca_sub_type text,
+initiating_symbol text, target_symbol text, declaration_date date, ex_date date, record_date date, payable_date date,
+cash double precision default 0, old_rate double precision default 0, new_rate double precision default 0,
+migrated boolean default false, updated_at timestamp default current_timestamp);
+
+-- +goose Down
+drop table corporate_actions;
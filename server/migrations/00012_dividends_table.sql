@@ -0,0 +1,7 @@
+-- +goose Up
+create table if not exists dividends(id text primary key, user_id uuid references authentication(id) on delete cascade, symbol text,
+activity_type text, qty double precision default 0, per_share_amount double precision default 0, net_amount double precision,
+date date, description text default '');
+
+-- +goose Down
+drop table dividends;
@@ -0,0 +1,17 @@
+-- +goose Up
+create table if not exists recorded_trades(symbol text not null, start_time timestamptz not null, end_time timestamptz not null,
+count integer, data bytea) partition by range (start_time);
+create index if not exists recorded_trades_symbol_start_time on recorded_trades(symbol, start_time);
+
+create table if not exists recorded_quotes(symbol text not null, start_time timestamptz not null, end_time timestamptz not null,
+count integer, data bytea) partition by range (start_time);
+create index if not exists recorded_quotes_symbol_start_time on recorded_quotes(symbol, start_time);
+
+create table if not exists recorded_bars(symbol text not null, start_time timestamptz not null, end_time timestamptz not null,
+count integer, data bytea) partition by range (start_time);
+create index if not exists recorded_bars_symbol_start_time on recorded_bars(symbol, start_time);
+
+-- +goose Down
+drop table recorded_bars;
+drop table recorded_quotes;
+drop table recorded_trades;